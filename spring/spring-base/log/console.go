@@ -72,5 +72,8 @@ func (c *ConsoleAppender) Append(msg *Message) {
 	}
 	strTime := msg.Time().Format("2006-01-02T15:04:05.000")
 	fileLine := util.Contract(fmt.Sprintf("%s:%d", msg.File(), msg.Line()), 48)
+	if fields := msg.Fields(); len(fields) > 0 {
+		fileLine += "][" + strings.Join(fields, " ")
+	}
 	_, _ = fmt.Printf("[%s][%s][%s] %s\n", strLevel, strTime, fileLine, buf.String())
 }
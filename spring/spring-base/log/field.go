@@ -0,0 +1,65 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"context"
+	"sync"
+)
+
+// ContextField 从 ctx 中提取需要输出到每条日志中的字段值，例如请求 ID ，返回空字
+// 符串时不输出该字段。
+type ContextField func(ctx context.Context) string
+
+type contextField struct {
+	name string
+	fn   ContextField
+}
+
+var (
+	fieldsMutex   sync.RWMutex
+	contextFields []contextField
+)
+
+// RegisterContextField 注册上下文字段，同名的字段会被替换。
+func RegisterContextField(name string, fn ContextField) {
+	fieldsMutex.Lock()
+	defer fieldsMutex.Unlock()
+	for i, f := range contextFields {
+		if f.name == name {
+			contextFields[i].fn = fn
+			return
+		}
+	}
+	contextFields = append(contextFields, contextField{name: name, fn: fn})
+}
+
+// Fields 返回日志的 context.Context 中携带的上下文字段，格式为 name=value 。
+func (msg *Message) Fields() []string {
+	if msg.ctx == nil {
+		return nil
+	}
+	fieldsMutex.RLock()
+	defer fieldsMutex.RUnlock()
+	var fields []string
+	for _, f := range contextFields {
+		if v := f.fn(msg.ctx); v != "" {
+			fields = append(fields, f.name+"="+v)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-base/log"
+)
+
+type fieldKey string
+
+func TestMessage_Fields(t *testing.T) {
+
+	log.RegisterContextField("trace_id", func(ctx context.Context) string {
+		v, _ := ctx.Value(fieldKey("trace_id")).(string)
+		return v
+	})
+	log.RegisterContextField("user", func(ctx context.Context) string {
+		return "nobody"
+	})
+	log.RegisterContextField("user", func(ctx context.Context) string {
+		v, _ := ctx.Value(fieldKey("user")).(string)
+		return v
+	})
+
+	msg := log.NewMessageBuilder().Build()
+	assert.Nil(t, msg.Fields())
+
+	ctx := context.WithValue(context.Background(), fieldKey("user"), "jim")
+	msg = log.NewMessageBuilder().WithContext(ctx).Build()
+	assert.Equal(t, msg.Fields(), []string{"user=jim"})
+
+	ctx = context.WithValue(ctx, fieldKey("trace_id"), "0689")
+	msg = log.NewMessageBuilder().WithContext(ctx).Build()
+	assert.Equal(t, msg.Fields(), []string{"trace_id=0689", "user=jim"})
+}
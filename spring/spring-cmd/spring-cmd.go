@@ -0,0 +1,116 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package SpringCmd 实现和服务共享 bean 定义的命令行工具，子命令注册为 bean ，
+// 命令行参数中的 -name value 和 --name=value 形式的选项作为属性通过 value 标签
+// 绑定到子命令上。
+package SpringCmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-spring/spring-core/gs"
+)
+
+// Command 子命令，需要导出为 Command 接口才能被发现，例如
+// gs.Object(new(MigrateCommand)).Export((*SpringCmd.Command)(nil)) 。
+type Command interface {
+	Name() string  // 命令名称
+	Usage() string // 命令的一行说明
+	Run(ctx gs.Context, args []string) error
+}
+
+// Output 帮助信息的输出位置。
+var Output io.Writer = os.Stdout
+
+// Run 执行命令行参数中的第一个位置参数指定的子命令，剩余的位置参数传递给子命令。
+// 没有指定子命令或者子命令为 help 时输出所有子命令的说明。
+func Run() error {
+	return run(gs.Execute)
+}
+
+// RunApp 参考 Run 的解释，在指定的应用上执行子命令。
+func RunApp(app *gs.App) error {
+	return run(app.Execute)
+}
+
+type executor func(command string, fn func(ctx gs.Context) error) error
+
+func run(execute executor) error {
+
+	args := positionalArgs(os.Args[1:])
+	name := "help"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	return execute(name, func(ctx gs.Context) error {
+
+		var commands []Command
+		if err := ctx.Get(&commands, "*?"); err != nil {
+			return err
+		}
+
+		sort.Slice(commands, func(i, j int) bool {
+			return commands[i].Name() < commands[j].Name()
+		})
+
+		for _, c := range commands {
+			if c.Name() == name {
+				return c.Run(ctx, args)
+			}
+		}
+
+		if name == "help" {
+			return usage(Output, commands)
+		}
+		return fmt.Errorf("unknown command %q, run 'help' for usage", name)
+	})
+}
+
+// positionalArgs 返回命令行参数中的位置参数，和 gs 解析命令行选项的规则一致，
+// 即 -name 后面不以 - 开头的参数是该选项的值。
+func positionalArgs(args []string) []string {
+	var ret []string
+	for i := 0; i < len(args); i++ {
+		s := args[i]
+		if strings.HasPrefix(s, "--") {
+			continue
+		}
+		if strings.HasPrefix(s, "-") {
+			if i < len(args)-1 && !strings.HasPrefix(args[i+1], "-") {
+				i++
+			}
+			continue
+		}
+		ret = append(ret, s)
+	}
+	return ret
+}
+
+func usage(w io.Writer, commands []Command) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Available commands:")
+	for _, c := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", c.Name(), c.Usage())
+	}
+	return tw.Flush()
+}
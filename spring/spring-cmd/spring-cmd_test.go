@@ -0,0 +1,95 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package SpringCmd_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-cmd"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
+)
+
+type Server struct{}
+
+type MigrateCommand struct {
+	Server *Server `autowire:"?"`
+	Table  string  `value:"${table:=users}"`
+	DryRun bool    `value:"${dry-run:=false}"`
+
+	args []string
+}
+
+func (c *MigrateCommand) Name() string  { return "migrate" }
+func (c *MigrateCommand) Usage() string { return "migrate database tables" }
+
+func (c *MigrateCommand) Run(ctx gs.Context, args []string) error {
+	c.args = args
+	return nil
+}
+
+type VersionCommand struct{}
+
+func (c *VersionCommand) Name() string                            { return "version" }
+func (c *VersionCommand) Usage() string                           { return "print the version" }
+func (c *VersionCommand) Run(ctx gs.Context, args []string) error { return nil }
+
+func newApp(cmd *MigrateCommand) *gs.App {
+	app := gs.NewApp()
+	app.Object(new(Server)).On(cond.OnMissingProperty(gs.SpringCommand))
+	app.Object(cmd).Export((*SpringCmd.Command)(nil))
+	app.Object(new(VersionCommand)).Export((*SpringCmd.Command)(nil))
+	return app
+}
+
+func TestRun(t *testing.T) {
+	os.Clearenv()
+	args := os.Args
+	defer func() { os.Args = args }()
+
+	t.Run("command", func(t *testing.T) {
+		os.Args = []string{"tool", "migrate", "-table", "orders", "--dry-run=true", "v1", "v2"}
+		cmd := new(MigrateCommand)
+		err := SpringCmd.RunApp(newApp(cmd))
+		assert.Nil(t, err)
+		assert.Nil(t, cmd.Server)
+		assert.Equal(t, cmd.Table, "orders")
+		assert.True(t, cmd.DryRun)
+		assert.Equal(t, cmd.args, []string{"v1", "v2"})
+	})
+
+	t.Run("help", func(t *testing.T) {
+		os.Args = []string{"tool"}
+		buf := bytes.NewBuffer(nil)
+		SpringCmd.Output = buf
+		defer func() { SpringCmd.Output = os.Stdout }()
+		err := SpringCmd.RunApp(newApp(new(MigrateCommand)))
+		assert.Nil(t, err)
+		assert.Equal(t, buf.String(), "Available commands:\n"+
+			"  migrate  migrate database tables\n"+
+			"  version  print the version\n")
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		os.Args = []string{"tool", "seed"}
+		err := SpringCmd.RunApp(newApp(new(MigrateCommand)))
+		assert.Error(t, err, "unknown command \"seed\", run 'help' for usage")
+	})
+}
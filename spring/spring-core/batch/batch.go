@@ -0,0 +1,218 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package batch 提供了轻量的批处理功能。作业(Job)由若干个步骤(Step)组成，每个步骤
+// 从 Reader 读取数据，经过 Processor 处理之后按块(chunk)交给 Writer 写入，每写入
+// 一块就是一个提交点，步骤的进度会被保存到 Repository 中。读取、处理和写入失败时
+// 可以按照重试和跳过策略继续执行，失败的作业再次运行时会跳过已经完成的步骤。
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Reader 逐条读取数据，没有更多数据时返回 io.EOF 。
+type Reader interface {
+	Read(ctx context.Context) (interface{}, error)
+}
+
+// Processor 处理读取的数据，返回 nil 时该条数据被过滤掉不会被写入。
+type Processor interface {
+	Process(ctx context.Context, item interface{}) (interface{}, error)
+}
+
+// Writer 批量写入处理后的数据。
+type Writer interface {
+	Write(ctx context.Context, items []interface{}) error
+}
+
+type ReaderFunc func(ctx context.Context) (interface{}, error)
+
+func (f ReaderFunc) Read(ctx context.Context) (interface{}, error) {
+	return f(ctx)
+}
+
+type ProcessorFunc func(ctx context.Context, item interface{}) (interface{}, error)
+
+func (f ProcessorFunc) Process(ctx context.Context, item interface{}) (interface{}, error) {
+	return f(ctx, item)
+}
+
+type WriterFunc func(ctx context.Context, items []interface{}) error
+
+func (f WriterFunc) Write(ctx context.Context, items []interface{}) error {
+	return f(ctx, items)
+}
+
+// Step 作业中的一个步骤。
+type Step struct {
+	Name          string
+	Reader        Reader
+	Processor     Processor // 可以为空，读取的数据直接写入
+	Writer        Writer
+	ChunkSize     int                  // 每次写入的数据条数，默认为 10
+	RetryLimit    int                  // 处理和写入失败时的重试次数
+	RetryInterval time.Duration        // 重试的间隔
+	Retryable     func(err error) bool // 是否重试该错误，为空时重试所有错误
+	SkipLimit     int                  // 整个步骤最多跳过的数据条数
+	Skippable     func(err error) bool // 是否可以跳过该错误，为空时可以跳过所有错误
+}
+
+// Job 由若干个按顺序执行的步骤组成的作业。
+type Job struct {
+	Name  string
+	Steps []*Step
+}
+
+// stepRunner 执行一个步骤，并在每个提交点保存步骤的进度。
+type stepRunner struct {
+	step *Step
+	exec *StepExecution
+	repo Repository
+}
+
+func (r *stepRunner) run(ctx context.Context) error {
+	chunkSize := r.step.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 10
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		items, n, end, err := r.readChunk(ctx, chunkSize)
+		if err != nil {
+			return err
+		}
+		if len(items) > 0 {
+			if err = r.writeChunk(ctx, items); err != nil {
+				return err
+			}
+		}
+		if n > 0 {
+			r.exec.CommitCount++
+			if err = r.repo.UpdateStepExecution(ctx, r.exec); err != nil {
+				return err
+			}
+		}
+		if end {
+			return nil
+		}
+	}
+}
+
+// readChunk 读取并处理一块数据，返回需要写入的数据、读取的条数以及是否读取结束。
+func (r *stepRunner) readChunk(ctx context.Context, chunkSize int) ([]interface{}, int, bool, error) {
+	var items []interface{}
+	for n := 0; n < chunkSize; n++ {
+		item, err := r.step.Reader.Read(ctx)
+		if err == io.EOF {
+			return items, n, true, nil
+		}
+		if err != nil {
+			if r.skip(err) {
+				continue
+			}
+			return nil, n, false, fmt.Errorf("read error: %w", err)
+		}
+		r.exec.ReadCount++
+		if r.step.Processor != nil {
+			err = r.retry(ctx, func() error {
+				var e error
+				item, e = r.step.Processor.Process(ctx, item)
+				return e
+			})
+			if err != nil {
+				if r.skip(err) {
+					continue
+				}
+				return nil, n, false, fmt.Errorf("process error: %w", err)
+			}
+			if item == nil {
+				r.exec.FilterCount++
+				continue
+			}
+		}
+		items = append(items, item)
+	}
+	return items, chunkSize, false, nil
+}
+
+// writeChunk 写入一块数据，写入失败并且允许跳过时逐条写入以找出失败的数据。
+func (r *stepRunner) writeChunk(ctx context.Context, items []interface{}) error {
+	err := r.retry(ctx, func() error { return r.step.Writer.Write(ctx, items) })
+	if err == nil {
+		r.exec.WriteCount += len(items)
+		return nil
+	}
+	if len(items) == 1 || !r.skippable(err) {
+		if len(items) == 1 && r.skip(err) {
+			return nil
+		}
+		return fmt.Errorf("write error: %w", err)
+	}
+	for _, item := range items {
+		one := []interface{}{item}
+		err = r.retry(ctx, func() error { return r.step.Writer.Write(ctx, one) })
+		if err == nil {
+			r.exec.WriteCount++
+			continue
+		}
+		if !r.skip(err) {
+			return fmt.Errorf("write error: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *stepRunner) retry(ctx context.Context, fn func() error) error {
+	for i := 0; ; i++ {
+		err := fn()
+		if err == nil || i >= r.step.RetryLimit || errors.Is(err, context.Canceled) {
+			return err
+		}
+		if r.step.Retryable != nil && !r.step.Retryable(err) {
+			return err
+		}
+		if r.step.RetryInterval > 0 {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(r.step.RetryInterval):
+			}
+		}
+	}
+}
+
+func (r *stepRunner) skippable(err error) bool {
+	if r.exec.SkipCount >= r.step.SkipLimit {
+		return false
+	}
+	return r.step.Skippable == nil || r.step.Skippable(err)
+}
+
+// skip 跳过出错的数据，超过跳过次数或者错误不可跳过时返回 false 。
+func (r *stepRunner) skip(err error) bool {
+	if !r.skippable(err) {
+		return false
+	}
+	r.exec.SkipCount++
+	return true
+}
@@ -0,0 +1,294 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package batch_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/batch"
+	"github.com/go-spring/spring-core/report"
+)
+
+// sliceReader 依次返回 items 中的元素，元素为 error 时返回该错误。
+func sliceReader(items ...interface{}) batch.Reader {
+	i := 0
+	return batch.ReaderFunc(func(ctx context.Context) (interface{}, error) {
+		if i >= len(items) {
+			return nil, io.EOF
+		}
+		item := items[i]
+		i++
+		if err, ok := item.(error); ok {
+			return nil, err
+		}
+		return item, nil
+	})
+}
+
+type sliceWriter struct {
+	chunks [][]interface{}
+	fail   func(items []interface{}) error
+}
+
+func (w *sliceWriter) Write(ctx context.Context, items []interface{}) error {
+	if w.fail != nil {
+		if err := w.fail(items); err != nil {
+			return err
+		}
+	}
+	w.chunks = append(w.chunks, append([]interface{}(nil), items...))
+	return nil
+}
+
+func (w *sliceWriter) items() []interface{} {
+	var ret []interface{}
+	for _, c := range w.chunks {
+		ret = append(ret, c...)
+	}
+	return ret
+}
+
+func newLauncher(t *testing.T, jobs ...*batch.Job) *batch.Launcher {
+	l, err := batch.NewLauncher(batch.Config{}, nil, jobs)
+	assert.Nil(t, err)
+	return l
+}
+
+func TestLauncher_Chunk(t *testing.T) {
+
+	w := &sliceWriter{}
+	step := &batch.Step{
+		Name:   "double",
+		Reader: sliceReader(1, 2, 3, 4, 5),
+		Processor: batch.ProcessorFunc(func(ctx context.Context, item interface{}) (interface{}, error) {
+			if item.(int) == 3 {
+				return nil, nil
+			}
+			return item.(int) * 2, nil
+		}),
+		Writer:    w,
+		ChunkSize: 2,
+	}
+	l := newLauncher(t, &batch.Job{Name: "job", Steps: []*batch.Step{step}})
+
+	exec, err := l.Run(context.Background(), "job", map[string]string{"date": "2021-12-01"})
+	assert.Nil(t, err)
+	assert.Equal(t, exec.Status, batch.StatusCompleted)
+	assert.Equal(t, w.chunks, [][]interface{}{{2, 4}, {8}, {10}})
+
+	s := exec.Steps[0]
+	assert.Equal(t, s.Status, batch.StatusCompleted)
+	assert.Equal(t, s.ReadCount, 5)
+	assert.Equal(t, s.WriteCount, 4)
+	assert.Equal(t, s.FilterCount, 1)
+	assert.Equal(t, s.CommitCount, 3)
+
+	last, err := l.LastExecution(context.Background(), "job", map[string]string{"date": "2021-12-01"})
+	assert.Nil(t, err)
+	assert.Equal(t, last, exec)
+
+	last, err = l.LastExecution(context.Background(), "job", nil)
+	assert.Nil(t, err)
+	assert.Nil(t, last)
+
+	_, err = l.Run(context.Background(), "unknown", nil)
+	assert.True(t, errors.Is(err, batch.ErrJobNotFound))
+}
+
+func TestLauncher_RetryAndSkip(t *testing.T) {
+
+	t.Run("retry", func(t *testing.T) {
+		count := 0
+		w := &sliceWriter{fail: func(items []interface{}) error {
+			if count++; count < 3 {
+				return errors.New("timeout")
+			}
+			return nil
+		}}
+		step := &batch.Step{Name: "s", Reader: sliceReader(1, 2), Writer: w, RetryLimit: 2}
+		l := newLauncher(t, &batch.Job{Name: "job", Steps: []*batch.Step{step}})
+		_, err := l.Run(context.Background(), "job", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, count, 3)
+		assert.Equal(t, w.items(), []interface{}{1, 2})
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		w := &sliceWriter{fail: func(items []interface{}) error {
+			for _, item := range items {
+				if item == 4 {
+					return errors.New("bad item")
+				}
+			}
+			return nil
+		}}
+		step := &batch.Step{
+			Name:      "s",
+			Reader:    sliceReader(1, errors.New("bad line"), 3, 4, 5),
+			Writer:    w,
+			ChunkSize: 3,
+			SkipLimit: 2,
+		}
+		l := newLauncher(t, &batch.Job{Name: "job", Steps: []*batch.Step{step}})
+		exec, err := l.Run(context.Background(), "job", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, w.items(), []interface{}{1, 3, 5})
+		assert.Equal(t, exec.Steps[0].SkipCount, 2)
+		assert.Equal(t, exec.Steps[0].WriteCount, 3)
+	})
+
+	t.Run("skip limit", func(t *testing.T) {
+		step := &batch.Step{
+			Name:      "s",
+			Reader:    sliceReader(errors.New("e1"), errors.New("e2")),
+			Writer:    &sliceWriter{},
+			SkipLimit: 1,
+		}
+		l := newLauncher(t, &batch.Job{Name: "job", Steps: []*batch.Step{step}})
+		exec, err := l.Run(context.Background(), "job", nil)
+		assert.Error(t, err, "job job failed: step s: read error: e2")
+		assert.Equal(t, exec.Status, batch.StatusFailed)
+		assert.Equal(t, exec.Steps[0].Status, batch.StatusFailed)
+	})
+}
+
+func TestLauncher_Restart(t *testing.T) {
+
+	w1 := &sliceWriter{}
+	fail := true
+	w2 := &sliceWriter{fail: func(items []interface{}) error {
+		if fail {
+			return errors.New("db down")
+		}
+		return nil
+	}}
+	job := &batch.Job{
+		Name: "job",
+		Steps: []*batch.Step{
+			{Name: "s1", Reader: sliceReader(1), Writer: w1},
+			{Name: "s2", Reader: sliceReader(2), Writer: w2},
+		},
+	}
+	l := newLauncher(t, job)
+
+	exec, err := l.Run(context.Background(), "job", nil)
+	assert.Error(t, err, "db down")
+	assert.Equal(t, len(exec.Steps), 2)
+
+	fail = false
+	job.Steps[1].Reader = sliceReader(2)
+	exec, err = l.Run(context.Background(), "job", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, len(exec.Steps), 1)
+	assert.Equal(t, exec.Steps[0].StepName, "s2")
+	assert.Equal(t, w1.items(), []interface{}{1})
+	assert.Equal(t, w2.items(), []interface{}{2})
+
+	// 上一次执行成功之后从头开始执行
+	job.Steps[0].Reader = sliceReader(3)
+	job.Steps[1].Reader = sliceReader(4)
+	exec, err = l.Run(context.Background(), "job", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, len(exec.Steps), 2)
+}
+
+type reporter struct {
+	events []*report.Event
+}
+
+func (r *reporter) Report(ctx context.Context, e *report.Event) {
+	r.events = append(r.events, e)
+}
+
+func TestLauncher_Panic(t *testing.T) {
+
+	r := &reporter{}
+	report.Register(r)
+	defer report.Unregister(r)
+
+	step := &batch.Step{
+		Name:   "s",
+		Reader: sliceReader(1),
+		Writer: batch.WriterFunc(func(ctx context.Context, items []interface{}) error {
+			panic("boom")
+		}),
+	}
+	l := newLauncher(t, &batch.Job{Name: "job", Steps: []*batch.Step{step}})
+
+	exec, err := l.Run(context.Background(), "job", nil)
+	assert.Error(t, err, "panic: boom")
+	assert.Equal(t, exec.Status, batch.StatusFailed)
+	assert.Equal(t, len(r.events), 1)
+	assert.Equal(t, r.events[0].Source, report.SourceTask)
+	assert.Equal(t, r.events[0].Tags["batch.job"], "job")
+	assert.Equal(t, r.events[0].Tags["batch.step"], "s")
+}
+
+func TestLauncher_Start(t *testing.T) {
+
+	release := make(chan struct{})
+	step := &batch.Step{
+		Name:   "s",
+		Reader: sliceReader(1),
+		Writer: batch.WriterFunc(func(ctx context.Context, items []interface{}) error {
+			<-release
+			return nil
+		}),
+	}
+	l := newLauncher(t, &batch.Job{Name: "job", Steps: []*batch.Step{step}})
+
+	exec, err := l.Start(context.Background(), "job", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, exec.Status, batch.StatusStarted)
+
+	_, err = l.Start(context.Background(), "job", nil)
+	assert.True(t, errors.Is(err, batch.ErrJobRunning))
+
+	close(release)
+	var last *batch.JobExecution
+	for i := 0; i < 100; i++ {
+		last, err = l.LastExecution(context.Background(), "job", nil)
+		assert.Nil(t, err)
+		if last.Status != batch.StatusStarted {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, last.Status, batch.StatusCompleted)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	l.OnAppStop(ctx)
+}
+
+func TestNewLauncher(t *testing.T) {
+	step := &batch.Step{Name: "s", Reader: sliceReader(), Writer: &sliceWriter{}}
+	_, err := batch.NewLauncher(batch.Config{}, nil, []*batch.Job{
+		{Name: "job", Steps: []*batch.Step{step}},
+		{Name: "job", Steps: []*batch.Step{step}},
+	})
+	assert.Error(t, err, "duplicate job \"job\"")
+	config := batch.Config{Schedules: []batch.Schedule{{Job: "other", Interval: time.Minute}}}
+	_, err = batch.NewLauncher(config, nil, []*batch.Job{{Name: "job", Steps: []*batch.Step{step}}})
+	assert.Error(t, err, fmt.Sprintf("%s: other", batch.ErrJobNotFound))
+}
@@ -0,0 +1,335 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/report"
+	"github.com/go-spring/spring-core/web"
+)
+
+var logger = log.GetLogger("GS_BATCH")
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobRunning  = errors.New("job is running")
+)
+
+type Config struct {
+	Run       []string          `value:"${run:=}"`       // 应用启动后运行的作业，如 --batch.run=job1,job2
+	Params    map[string]string `value:"${params:=}"`    // 应用启动后运行的作业的参数
+	Exit      bool              `value:"${exit:=false}"` // 应用启动后运行的作业结束后是否退出应用
+	Schedules []Schedule        `value:"${schedules:=}"`
+}
+
+// Schedule 周期性运行的作业，上一次运行还未结束时跳过本次运行。
+type Schedule struct {
+	Job      string        `value:"${job}"`
+	Interval time.Duration `value:"${interval:=1h}"`
+}
+
+// Launcher 运行作业并保存执行记录，同一个作业同时只能有一个正在运行的实例。
+type Launcher struct {
+	config  Config
+	repo    Repository
+	jobs    map[string]*Job
+	mutex   sync.Mutex
+	running map[string]bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewLauncher 创建 Launcher ，repo 为空时使用 MemoryRepository 。
+func NewLauncher(config Config, repo Repository, jobs []*Job) (*Launcher, error) {
+	if repo == nil {
+		repo = NewMemoryRepository()
+	}
+	l := &Launcher{
+		config:  config,
+		repo:    repo,
+		jobs:    make(map[string]*Job),
+		running: make(map[string]bool),
+	}
+	for _, job := range jobs {
+		if job.Name == "" {
+			return nil, errors.New("job name can't be empty")
+		}
+		if _, ok := l.jobs[job.Name]; ok {
+			return nil, fmt.Errorf("duplicate job %q", job.Name)
+		}
+		for _, step := range job.Steps {
+			if step.Name == "" || step.Reader == nil || step.Writer == nil {
+				return nil, fmt.Errorf("job %q has invalid step %q", job.Name, step.Name)
+			}
+		}
+		l.jobs[job.Name] = job
+	}
+	for _, s := range config.Schedules {
+		if _, ok := l.jobs[s.Job]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrJobNotFound, s.Job)
+		}
+		if s.Interval <= 0 {
+			return nil, fmt.Errorf("job %q has invalid schedule interval %s", s.Job, s.Interval)
+		}
+	}
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	return l, nil
+}
+
+// Run 运行作业并等待作业结束，作业执行失败时返回执行记录和错误。上一次相同参数的
+// 执行失败时跳过已经完成的步骤，否则从头开始执行。
+func (l *Launcher) Run(ctx context.Context, name string, params map[string]string) (*JobExecution, error) {
+	job, exec, completed, err := l.prepare(ctx, name, params)
+	if err != nil {
+		return nil, err
+	}
+	l.execute(ctx, job, exec, completed)
+	if exec.Status == StatusFailed {
+		return exec, fmt.Errorf("job %s failed: %s", name, exec.Error)
+	}
+	return exec, nil
+}
+
+// Start 在后台运行作业，返回作业刚开始运行时的执行记录。
+func (l *Launcher) Start(ctx context.Context, name string, params map[string]string) (*JobExecution, error) {
+	job, exec, completed, err := l.prepare(ctx, name, params)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := exec.clone()
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		l.execute(l.ctx, job, exec, completed)
+	}()
+	return snapshot, nil
+}
+
+// LastExecution 返回作业最近一次的执行记录。
+func (l *Launcher) LastExecution(ctx context.Context, name string, params map[string]string) (*JobExecution, error) {
+	if _, ok := l.jobs[name]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	return l.repo.LastJobExecution(ctx, name, params)
+}
+
+func (l *Launcher) prepare(ctx context.Context, name string, params map[string]string) (*Job, *JobExecution, map[string]bool, error) {
+
+	job, ok := l.jobs[name]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+
+	l.mutex.Lock()
+	if l.running[name] {
+		l.mutex.Unlock()
+		return nil, nil, nil, fmt.Errorf("%w: %s", ErrJobRunning, name)
+	}
+	l.running[name] = true
+	l.mutex.Unlock()
+
+	last, err := l.repo.LastJobExecution(ctx, name, params)
+	if err == nil {
+		exec := &JobExecution{JobName: name, Params: params, Status: StatusStarted, StartTime: time.Now()}
+		if err = l.repo.CreateJobExecution(ctx, exec); err == nil {
+			completed := make(map[string]bool)
+			if last != nil && last.Status == StatusFailed {
+				for _, s := range last.Steps {
+					if s.Status == StatusCompleted {
+						completed[s.StepName] = true
+					}
+				}
+			}
+			return job, exec, completed, nil
+		}
+	}
+
+	l.mutex.Lock()
+	delete(l.running, name)
+	l.mutex.Unlock()
+	return nil, nil, nil, err
+}
+
+func (l *Launcher) execute(ctx context.Context, job *Job, exec *JobExecution, completed map[string]bool) {
+
+	defer func() {
+		l.mutex.Lock()
+		delete(l.running, job.Name)
+		l.mutex.Unlock()
+	}()
+
+	logger.WithContext(ctx).Infof("job %s started", job.Name)
+	exec.Status = StatusCompleted
+	for _, step := range job.Steps {
+		if completed[step.Name] {
+			continue
+		}
+		if err := l.executeStep(ctx, exec, step); err != nil {
+			exec.Status = StatusFailed
+			exec.Error = fmt.Sprintf("step %s: %v", step.Name, err)
+			break
+		}
+	}
+	exec.EndTime = time.Now()
+	// 作业被停止时 ctx 已经被取消，仍然需要保存作业的最终状态。
+	if err := l.repo.UpdateJobExecution(context.Background(), exec); err != nil {
+		logger.WithContext(ctx).Error(log.ERROR, err)
+	}
+	if exec.Status == StatusFailed {
+		logger.WithContext(ctx).Errorf(log.ERROR, "job %s failed: %s", job.Name, exec.Error)
+	} else {
+		logger.WithContext(ctx).Infof("job %s completed", job.Name)
+	}
+}
+
+func (l *Launcher) executeStep(ctx context.Context, exec *JobExecution, step *Step) error {
+	s := &StepExecution{
+		JobExecutionID: exec.ID,
+		StepName:       step.Name,
+		Status:         StatusStarted,
+		StartTime:      time.Now(),
+	}
+	if err := l.repo.CreateStepExecution(ctx, s); err != nil {
+		return err
+	}
+	exec.Steps = append(exec.Steps, s)
+	r := &stepRunner{step: step, exec: s, repo: l.repo}
+	err := l.runStep(report.WithTags(ctx, "batch.job", exec.JobName, "batch.step", step.Name), r)
+	s.Status, s.EndTime = StatusCompleted, time.Now()
+	if err != nil {
+		s.Status, s.Error = StatusFailed, err.Error()
+	}
+	if e := l.repo.UpdateStepExecution(context.Background(), s); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+// runStep 运行步骤，步骤中的 panic 会被上报并且作为步骤的错误返回。
+func (l *Launcher) runStep(ctx context.Context, r *stepRunner) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			report.Panic(ctx, report.SourceTask, v)
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return r.run(ctx)
+}
+
+// OnAppStart 运行命令行指定的作业，并开始周期性地运行作业。
+func (l *Launcher) OnAppStart(ctx gs.Context) {
+	if len(l.config.Run) > 0 {
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+			for _, name := range l.config.Run {
+				if _, err := l.Run(l.ctx, name, l.config.Params); err != nil {
+					logger.WithContext(l.ctx).Error(log.ERROR, err)
+					break
+				}
+			}
+			if l.config.Exit {
+				gs.ShutDown("batch jobs finished")
+			}
+		}()
+	}
+	for _, s := range l.config.Schedules {
+		l.wg.Add(1)
+		go func(s Schedule) {
+			defer l.wg.Done()
+			ticker := time.NewTicker(s.Interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-l.ctx.Done():
+					return
+				case <-ticker.C:
+				}
+				if _, err := l.Run(l.ctx, s.Job, nil); err != nil && !errors.Is(err, ErrJobRunning) {
+					logger.WithContext(l.ctx).Error(log.ERROR, err)
+				}
+			}
+		}(s)
+	}
+}
+
+// OnAppStop 停止正在运行的作业并等待它们结束，被停止的作业状态为失败，可以再次运行。
+func (l *Launcher) OnAppStop(ctx context.Context) {
+	l.cancel()
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// queryParams 将请求的查询参数作为作业参数。
+func queryParams(ctx web.Context) map[string]string {
+	params := make(map[string]string)
+	for k, v := range ctx.QueryParams() {
+		if len(v) > 0 {
+			params[k] = v[0]
+		}
+	}
+	return params
+}
+
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrJobRunning):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// HandleStart 在后台运行路径参数 name 指定的作业，查询参数作为作业参数。
+func (l *Launcher) HandleStart(ctx web.Context) {
+	exec, err := l.Start(ctx.Context(), ctx.PathParam("name"), queryParams(ctx))
+	if err != nil {
+		panic(web.NewHttpError(errorStatus(err), err.Error()))
+	}
+	ctx.SetStatus(http.StatusAccepted)
+	ctx.JSON(exec)
+}
+
+// HandleStatus 返回路径参数 name 指定的作业最近一次的执行记录。
+func (l *Launcher) HandleStatus(ctx web.Context) {
+	exec, err := l.LastExecution(ctx.Context(), ctx.PathParam("name"), queryParams(ctx))
+	if err != nil {
+		panic(web.NewHttpError(errorStatus(err), err.Error()))
+	}
+	if exec == nil {
+		panic(web.NewHttpError(http.StatusNotFound, "no execution"))
+	}
+	ctx.JSON(exec)
+}
@@ -0,0 +1,321 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package batch
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusStarted   = Status("STARTED")
+	StatusCompleted = Status("COMPLETED")
+	StatusFailed    = Status("FAILED")
+)
+
+// JobExecution 作业的一次执行记录。
+type JobExecution struct {
+	ID        int64             `json:"id"`
+	JobName   string            `json:"jobName"`
+	Params    map[string]string `json:"params,omitempty"`
+	Status    Status            `json:"status"`
+	StartTime time.Time         `json:"startTime"`
+	EndTime   time.Time         `json:"endTime"`
+	Error     string            `json:"error,omitempty"`
+	Steps     []*StepExecution  `json:"steps,omitempty"`
+}
+
+func (e *JobExecution) clone() *JobExecution {
+	c := *e
+	c.Params = make(map[string]string, len(e.Params))
+	for k, v := range e.Params {
+		c.Params[k] = v
+	}
+	c.Steps = make([]*StepExecution, len(e.Steps))
+	for i, s := range e.Steps {
+		step := *s
+		c.Steps[i] = &step
+	}
+	return &c
+}
+
+// StepExecution 步骤的一次执行记录。
+type StepExecution struct {
+	ID             int64     `json:"id"`
+	JobExecutionID int64     `json:"jobExecutionId"`
+	StepName       string    `json:"stepName"`
+	Status         Status    `json:"status"`
+	ReadCount      int       `json:"readCount"`
+	WriteCount     int       `json:"writeCount"`
+	FilterCount    int       `json:"filterCount"`
+	SkipCount      int       `json:"skipCount"`
+	CommitCount    int       `json:"commitCount"`
+	StartTime      time.Time `json:"startTime"`
+	EndTime        time.Time `json:"endTime"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// Repository 保存作业和步骤的执行记录。
+type Repository interface {
+
+	// CreateJobExecution 保存新的作业执行记录并设置它的 ID 。
+	CreateJobExecution(ctx context.Context, e *JobExecution) error
+
+	// UpdateJobExecution 更新作业执行记录的状态。
+	UpdateJobExecution(ctx context.Context, e *JobExecution) error
+
+	// CreateStepExecution 保存新的步骤执行记录并设置它的 ID 。
+	CreateStepExecution(ctx context.Context, e *StepExecution) error
+
+	// UpdateStepExecution 更新步骤执行记录的状态和进度。
+	UpdateStepExecution(ctx context.Context, e *StepExecution) error
+
+	// LastJobExecution 返回相同作业名称和参数的最近一次执行记录及其步骤，
+	// 没有执行记录时返回 nil 。
+	LastJobExecution(ctx context.Context, jobName string, params map[string]string) (*JobExecution, error)
+}
+
+// jobKey 返回作业参数的唯一标识，参数按照名称排序。
+func jobKey(params map[string]string) string {
+	v := url.Values{}
+	for key, val := range params {
+		v.Set(key, val)
+	}
+	return v.Encode()
+}
+
+// MemoryRepository 在内存中保存执行记录，应用重启之后执行记录会丢失。
+type MemoryRepository struct {
+	mutex sync.Mutex
+	id    int64
+	jobs  []*JobExecution
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{}
+}
+
+func (r *MemoryRepository) nextID() int64 {
+	r.id++
+	return r.id
+}
+
+func (r *MemoryRepository) CreateJobExecution(ctx context.Context, e *JobExecution) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	e.ID = r.nextID()
+	c := e.clone()
+	c.Steps = nil
+	r.jobs = append(r.jobs, c)
+	return nil
+}
+
+func (r *MemoryRepository) findJob(id int64) *JobExecution {
+	for _, j := range r.jobs {
+		if j.ID == id {
+			return j
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) UpdateJobExecution(ctx context.Context, e *JobExecution) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if j := r.findJob(e.ID); j != nil {
+		j.Status, j.EndTime, j.Error = e.Status, e.EndTime, e.Error
+	}
+	return nil
+}
+
+func (r *MemoryRepository) CreateStepExecution(ctx context.Context, e *StepExecution) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	e.ID = r.nextID()
+	if j := r.findJob(e.JobExecutionID); j != nil {
+		c := *e
+		j.Steps = append(j.Steps, &c)
+	}
+	return nil
+}
+
+func (r *MemoryRepository) UpdateStepExecution(ctx context.Context, e *StepExecution) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if j := r.findJob(e.JobExecutionID); j != nil {
+		for i, s := range j.Steps {
+			if s.ID == e.ID {
+				c := *e
+				j.Steps[i] = &c
+			}
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) LastJobExecution(ctx context.Context, jobName string, params map[string]string) (*JobExecution, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	key := jobKey(params)
+	for i := len(r.jobs) - 1; i >= 0; i-- {
+		if j := r.jobs[i]; j.JobName == jobName && jobKey(j.Params) == key {
+			return j.clone(), nil
+		}
+	}
+	return nil, nil
+}
+
+// SQLRepository 在数据库中保存执行记录，表结构如下(MySQL):
+//
+//	CREATE TABLE batch_job_execution (
+//	    id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+//	    job_name   VARCHAR(255) NOT NULL,
+//	    job_key    CHAR(40) NOT NULL,
+//	    params     TEXT,
+//	    status     VARCHAR(16) NOT NULL,
+//	    start_time BIGINT NOT NULL,
+//	    end_time   BIGINT NOT NULL DEFAULT 0,
+//	    error      TEXT,
+//	    INDEX idx_job (job_name, job_key)
+//	);
+//
+//	CREATE TABLE batch_step_execution (
+//	    id               BIGINT AUTO_INCREMENT PRIMARY KEY,
+//	    job_execution_id BIGINT NOT NULL,
+//	    step_name        VARCHAR(255) NOT NULL,
+//	    status           VARCHAR(16) NOT NULL,
+//	    read_count       INT NOT NULL DEFAULT 0,
+//	    write_count      INT NOT NULL DEFAULT 0,
+//	    filter_count     INT NOT NULL DEFAULT 0,
+//	    skip_count       INT NOT NULL DEFAULT 0,
+//	    commit_count     INT NOT NULL DEFAULT 0,
+//	    start_time       BIGINT NOT NULL,
+//	    end_time         BIGINT NOT NULL DEFAULT 0,
+//	    error            TEXT,
+//	    INDEX idx_job_execution_id (job_execution_id)
+//	);
+type SQLRepository struct {
+	db *sql.DB
+}
+
+func NewSQLRepository(db *sql.DB) *SQLRepository {
+	return &SQLRepository{db: db}
+}
+
+func toMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func fromMillis(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+func (r *SQLRepository) CreateJobExecution(ctx context.Context, e *JobExecution) error {
+	params, err := json.Marshal(e.Params)
+	if err != nil {
+		return err
+	}
+	sum := sha1.Sum([]byte(jobKey(e.Params)))
+	query := "INSERT INTO batch_job_execution (job_name, job_key, params, status, start_time, end_time, error) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	result, err := r.db.ExecContext(ctx, query, e.JobName, hex.EncodeToString(sum[:]), string(params), string(e.Status), toMillis(e.StartTime), toMillis(e.EndTime), e.Error)
+	if err != nil {
+		return err
+	}
+	e.ID, err = result.LastInsertId()
+	return err
+}
+
+func (r *SQLRepository) UpdateJobExecution(ctx context.Context, e *JobExecution) error {
+	query := "UPDATE batch_job_execution SET status = ?, end_time = ?, error = ? WHERE id = ?"
+	_, err := r.db.ExecContext(ctx, query, string(e.Status), toMillis(e.EndTime), e.Error, e.ID)
+	return err
+}
+
+func (r *SQLRepository) CreateStepExecution(ctx context.Context, e *StepExecution) error {
+	query := "INSERT INTO batch_step_execution (job_execution_id, step_name, status, start_time) VALUES (?, ?, ?, ?)"
+	result, err := r.db.ExecContext(ctx, query, e.JobExecutionID, e.StepName, string(e.Status), toMillis(e.StartTime))
+	if err != nil {
+		return err
+	}
+	e.ID, err = result.LastInsertId()
+	return err
+}
+
+func (r *SQLRepository) UpdateStepExecution(ctx context.Context, e *StepExecution) error {
+	query := "UPDATE batch_step_execution SET status = ?, read_count = ?, write_count = ?, filter_count = ?, skip_count = ?, commit_count = ?, end_time = ?, error = ? WHERE id = ?"
+	_, err := r.db.ExecContext(ctx, query, string(e.Status), e.ReadCount, e.WriteCount, e.FilterCount, e.SkipCount, e.CommitCount, toMillis(e.EndTime), e.Error, e.ID)
+	return err
+}
+
+func (r *SQLRepository) LastJobExecution(ctx context.Context, jobName string, params map[string]string) (*JobExecution, error) {
+
+	sum := sha1.Sum([]byte(jobKey(params)))
+	query := "SELECT id, params, status, start_time, end_time, error FROM batch_job_execution WHERE job_name = ? AND job_key = ? ORDER BY id DESC LIMIT 1"
+	row := r.db.QueryRowContext(ctx, query, jobName, hex.EncodeToString(sum[:]))
+
+	var (
+		e                  = &JobExecution{JobName: jobName}
+		p, status, errStr  sql.NullString
+		startTime, endTime int64
+	)
+	err := row.Scan(&e.ID, &p, &status, &startTime, &endTime, &errStr)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.String != "" {
+		if err = json.Unmarshal([]byte(p.String), &e.Params); err != nil {
+			return nil, err
+		}
+	}
+	e.Status, e.Error = Status(status.String), errStr.String
+	e.StartTime, e.EndTime = fromMillis(startTime), fromMillis(endTime)
+
+	query = "SELECT id, step_name, status, read_count, write_count, filter_count, skip_count, commit_count, start_time, end_time, error FROM batch_step_execution WHERE job_execution_id = ? ORDER BY id"
+	rows, err := r.db.QueryContext(ctx, query, e.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		s := &StepExecution{JobExecutionID: e.ID}
+		if err = rows.Scan(&s.ID, &s.StepName, &status, &s.ReadCount, &s.WriteCount, &s.FilterCount,
+			&s.SkipCount, &s.CommitCount, &startTime, &endTime, &errStr); err != nil {
+			return nil, err
+		}
+		s.Status, s.Error = Status(status.String), errStr.String
+		s.StartTime, s.EndTime = fromMillis(startTime), fromMillis(endTime)
+		e.Steps = append(e.Steps, s)
+	}
+	return e, rows.Err()
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package batch_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/batch"
+)
+
+func TestSQLRepository(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	r := batch.NewSQLRepository(db)
+	ctx := context.Background()
+	start := time.Unix(1638316800, 0)
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO batch_job_execution (job_name, job_key, params, status, start_time, end_time, error) VALUES (?, ?, ?, ?, ?, ?, ?)")).
+		WithArgs("job", sqlmock.AnyArg(), `{"date":"2021-12-01"}`, "STARTED", int64(1638316800000), int64(0), "").
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO batch_step_execution (job_execution_id, step_name, status, start_time) VALUES (?, ?, ?, ?)")).
+		WithArgs(int64(7), "s", "STARTED", int64(1638316800000)).
+		WillReturnResult(sqlmock.NewResult(9, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE batch_step_execution SET status = ?, read_count = ?, write_count = ?, filter_count = ?, skip_count = ?, commit_count = ?, end_time = ?, error = ? WHERE id = ?")).
+		WithArgs("FAILED", 3, 2, 0, 1, 1, int64(1638316801000), "boom", int64(9)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	e := &batch.JobExecution{JobName: "job", Params: map[string]string{"date": "2021-12-01"}, Status: batch.StatusStarted, StartTime: start}
+	assert.Nil(t, r.CreateJobExecution(ctx, e))
+	assert.Equal(t, e.ID, int64(7))
+
+	s := &batch.StepExecution{JobExecutionID: e.ID, StepName: "s", Status: batch.StatusStarted, StartTime: start}
+	assert.Nil(t, r.CreateStepExecution(ctx, s))
+	assert.Equal(t, s.ID, int64(9))
+
+	s.Status, s.Error, s.EndTime = batch.StatusFailed, "boom", start.Add(time.Second)
+	s.ReadCount, s.WriteCount, s.SkipCount, s.CommitCount = 3, 2, 1, 1
+	assert.Nil(t, r.UpdateStepExecution(ctx, s))
+
+	mock.ExpectQuery("SELECT id, params, status, start_time, end_time, error FROM batch_job_execution").
+		WithArgs("job", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "params", "status", "start_time", "end_time", "error"}).
+			AddRow(7, `{"date":"2021-12-01"}`, "FAILED", 1638316800000, 1638316801000, "step s: boom"))
+	mock.ExpectQuery("SELECT .* FROM batch_step_execution WHERE job_execution_id = ?").
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "step_name", "status", "read_count", "write_count", "filter_count", "skip_count", "commit_count", "start_time", "end_time", "error"}).
+			AddRow(9, "s", "FAILED", 3, 2, 0, 1, 1, 1638316800000, 1638316801000, "boom"))
+
+	last, err := r.LastJobExecution(ctx, "job", map[string]string{"date": "2021-12-01"})
+	assert.Nil(t, err)
+	assert.Equal(t, last.Status, batch.StatusFailed)
+	assert.Equal(t, last.Params, map[string]string{"date": "2021-12-01"})
+	assert.Equal(t, last.Steps, []*batch.StepExecution{s})
+
+	mock.ExpectQuery("SELECT id, params, status, start_time, end_time, error FROM batch_job_execution").
+		WithArgs("other", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "params", "status", "start_time", "end_time", "error"}))
+	last, err = r.LastJobExecution(ctx, "other", nil)
+	assert.Nil(t, err)
+	assert.Nil(t, last)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
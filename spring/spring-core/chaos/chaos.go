@@ -0,0 +1,120 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package chaos 提供了用于韧性测试的故障注入功能，可以按照配置的比例为请求注入延迟、
+// 错误和连接重置，用于在预发环境验证重试和熔断等机制。故障注入只应该在 chaos 配置
+// 环境下开启，例如：
+//
+//	gs.Provide(chaos.NewFilter, "${chaos}").On(cond.OnProfile(chaos.Profile))
+package chaos
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Profile 开启故障注入的配置环境。
+const Profile = "chaos"
+
+// ErrInjected 注入的错误。
+var ErrInjected = errors.New("chaos: fault injected")
+
+// ErrConnReset 注入的连接重置错误。
+var ErrConnReset = errors.New("chaos: connection reset by peer")
+
+// Fault 注入的故障类型。
+type Fault int
+
+const (
+	None  = Fault(iota) // 不注入故障
+	Error               // 返回错误
+	Reset               // 重置连接
+)
+
+// Config 故障注入配置，各个比例的取值范围都是 0~1 。
+type Config struct {
+	Latency     time.Duration `value:"${latency:=0}"`      // 注入的延迟
+	LatencyRate float64       `value:"${latency-rate:=0}"` // 注入延迟的比例
+	ErrorRate   float64       `value:"${error-rate:=0}"`   // 返回错误的比例
+	ErrorStatus int           `value:"${error-status:=500}"`
+	ResetRate   float64       `value:"${reset-rate:=0}"` // 重置连接的比例
+	Paths       []string      `value:"${paths:=}"`       // 只对这些前缀的路径注入故障，为空时不限制
+}
+
+// Injector 根据配置决定每个请求注入的故障。
+type Injector struct {
+	config Config
+	mutex  sync.Mutex
+	rand   *rand.Rand
+}
+
+// NewInjector 创建故障注入器。
+func NewInjector(config Config) *Injector {
+	return &Injector{
+		config: config,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Config 返回故障注入配置。
+func (i *Injector) Config() Config {
+	return i.config
+}
+
+func (i *Injector) hit(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.rand.Float64() < rate
+}
+
+// Match 路径是否需要注入故障，Paths 为空时总是返回 true 。
+func (i *Injector) Match(path string) bool {
+	if len(i.config.Paths) == 0 {
+		return true
+	}
+	for _, p := range i.config.Paths {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Inject 按照比例执行延迟注入，然后返回需要注入的故障，上下文取消时提前结束延迟。
+func (i *Injector) Inject(ctx context.Context) Fault {
+	if i.config.Latency > 0 && i.hit(i.config.LatencyRate) {
+		t := time.NewTimer(i.config.Latency)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+		}
+	}
+	switch {
+	case i.hit(i.config.ResetRate):
+		return Reset
+	case i.hit(i.config.ErrorRate):
+		return Error
+	}
+	return None
+}
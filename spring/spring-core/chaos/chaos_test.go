@@ -0,0 +1,113 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chaos_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/chaos"
+	"github.com/go-spring/spring-core/web"
+)
+
+func TestInjector(t *testing.T) {
+
+	i := chaos.NewInjector(chaos.Config{})
+	assert.Equal(t, i.Inject(context.Background()), chaos.None)
+
+	i = chaos.NewInjector(chaos.Config{Latency: 20 * time.Millisecond, LatencyRate: 1, ErrorRate: 1})
+	start := time.Now()
+	assert.Equal(t, i.Inject(context.Background()), chaos.Error)
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+
+	i = chaos.NewInjector(chaos.Config{ResetRate: 1, ErrorRate: 1, Paths: []string{"/api"}})
+	assert.Equal(t, i.Inject(context.Background()), chaos.Reset)
+	assert.True(t, i.Match("/api/users"))
+	assert.False(t, i.Match("/health"))
+}
+
+func TestFilter(t *testing.T) {
+
+	f := chaos.NewFilter(chaos.Config{ErrorRate: 1, ErrorStatus: 503, Paths: []string{"/api"}})
+	handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		ctx.String("ok")
+	})
+
+	for _, c := range []struct {
+		path   string
+		status int
+	}{
+		{"/api/users", 503},
+		{"/health", 200},
+	} {
+		r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:8080"+c.path, nil)
+		w := httptest.NewRecorder()
+		ctx := web.NewBaseContext("", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+		web.NewFilterChain([]web.Filter{f, handler}).Next(ctx)
+		assert.Equal(t, w.Code, c.status)
+	}
+}
+
+func TestTransport(t *testing.T) {
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer svr.Close()
+
+	client := &http.Client{Transport: chaos.NewTransport(chaos.Config{ErrorRate: 1, ErrorStatus: 502}, nil)}
+	resp, err := client.Get(svr.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, resp.StatusCode, 502)
+
+	client = &http.Client{Transport: chaos.NewTransport(chaos.Config{ResetRate: 1}, nil)}
+	_, err = client.Get(svr.URL)
+	assert.Error(t, err, "connection reset by peer")
+
+	client = &http.Client{Transport: chaos.NewTransport(chaos.Config{}, nil)}
+	resp, err = client.Get(svr.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, resp.StatusCode, 200)
+	resp.Body.Close()
+
+	// 注入故障时请求体也要被关闭。
+	for _, config := range []chaos.Config{{ErrorRate: 1, ErrorStatus: 502}, {ResetRate: 1}} {
+		body := &trackBody{Reader: strings.NewReader("data")}
+		r, _ := http.NewRequest(http.MethodPost, svr.URL, body)
+		resp, _ = chaos.NewTransport(config, nil).RoundTrip(r)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		assert.True(t, body.closed)
+	}
+}
+
+type trackBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackBody) Close() error {
+	b.closed = true
+	return nil
+}
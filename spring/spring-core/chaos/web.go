@@ -0,0 +1,102 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chaos
+
+import (
+	"net/http"
+
+	"github.com/go-spring/spring-core/web"
+)
+
+// NewFilter 创建为请求注入故障的过滤器。重置连接时优先劫持底层连接并关闭，不支持
+// 劫持时通过 http.ErrAbortHandler 让 http 服务器中断连接。
+func NewFilter(config Config) web.Filter {
+	i := NewInjector(config)
+	return web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		if !i.Match(ctx.Request().URL.Path) {
+			chain.Continue(ctx)
+			return
+		}
+		switch i.Inject(ctx.Context()) {
+		case Error:
+			ctx.SetStatus(i.config.ErrorStatus)
+			ctx.String(ErrInjected.Error())
+		case Reset:
+			resetConn(ctx.ResponseWriter())
+		default:
+			chain.Continue(ctx)
+		}
+	})
+}
+
+func resetConn(w http.ResponseWriter) {
+	if h, ok := w.(http.Hijacker); ok {
+		if conn, _, err := h.Hijack(); err == nil {
+			_ = conn.Close()
+			return
+		}
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// Transport 为 http 客户端请求注入故障的 http.RoundTripper 。
+type Transport struct {
+	Base     http.RoundTripper // 为 nil 时使用 http.DefaultTransport
+	injector *Injector
+}
+
+// NewTransport 创建为客户端请求注入故障的 Transport 。
+func NewTransport(config Config, base http.RoundTripper) *Transport {
+	return &Transport{Base: base, injector: NewInjector(config)}
+}
+
+// RoundTrip 注入故障时不会把请求交给 Base ，按照 http.RoundTripper 的约定需要自己
+// 关闭请求体。
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if !t.injector.Match(r.URL.Path) {
+		return base.RoundTrip(r)
+	}
+	switch t.injector.Inject(r.Context()) {
+	case Error:
+		closeBody(r)
+		status := t.injector.config.ErrorStatus
+		return &http.Response{
+			Status:     http.StatusText(status),
+			StatusCode: status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     make(http.Header),
+			Body:       http.NoBody,
+			Request:    r,
+		}, nil
+	case Reset:
+		closeBody(r)
+		return nil, ErrConnReset
+	}
+	return base.RoundTrip(r)
+}
+
+func closeBody(r *http.Request) {
+	if r.Body != nil {
+		_ = r.Body.Close()
+	}
+}
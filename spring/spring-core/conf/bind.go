@@ -26,6 +26,7 @@ import (
 	"github.com/go-spring/spring-base/code"
 	"github.com/go-spring/spring-base/log"
 	"github.com/go-spring/spring-base/util"
+	"github.com/go-spring/spring-core/expr"
 )
 
 var (
@@ -134,7 +135,8 @@ func BindValue(p *Properties, v reflect.Value, param BindParam) error {
 		fnValue := reflect.ValueOf(fn)
 		out := fnValue.Call([]reflect.Value{reflect.ValueOf(val)})
 		if !out[1].IsNil() {
-			return out[1].Interface().(error)
+			err = out[1].Interface().(error)
+			return util.Wrapf(err, code.FileLine(), "property %q bind error", param.Key)
 		}
 		v.Set(out[0])
 		return nil
@@ -282,20 +284,25 @@ func bindSlice(p *Properties, v reflect.Value, param BindParam) error {
 	return nil
 }
 
+// bindMap 绑定 map 类型的属性。map 类型只能指定空的默认值，如 ${a:=} ，此时属性
+// 不存在时 map 保持为 nil ，属性存在时和没有默认值一样绑定所有的子属性。
 func bindMap(p *Properties, v reflect.Value, param BindParam) error {
 
 	if param.Tag.HasDef {
-		if param.Tag.Def == "" {
+		if param.Tag.Def != "" {
+			return util.Errorf(code.FileLine(), "%s map 类型不能指定非空默认值", param.Path)
+		}
+		if param.Tag.Key == "" || !p.Has(param.Key) {
 			return nil
 		}
-		return util.Errorf(code.FileLine(), "%s map 类型不能指定非空默认值", param.Path)
 	}
 
 	var keys []string
 	{
 		var keyPath []string
 		if param.Key != "" {
-			keyPath = strings.Split(param.Key, ".")
+			// 切片元素中的 map 的 key 形如 s[0].m ，需要转换成属性树中的路径 s.0.m 。
+			keyPath = strings.Split(p.convertKey(param.Key), ".")
 		}
 		t := p.t
 		for i, s := range keyPath {
@@ -367,6 +374,11 @@ func bindStruct(p *Properties, v reflect.Value, param BindParam) error {
 			if err := BindValue(p, fv, subParam); err != nil {
 				return err
 			}
+			if s, ok := ft.Tag.Lookup("expr"); ok {
+				if err := validate(p, fv, s, subParam); err != nil {
+					return err
+				}
+			}
 			continue
 		}
 
@@ -448,6 +460,10 @@ func resolveString(p *Properties, s string) (string, error) {
 					count++
 				}
 			}
+		case '#':
+			if count > 0 && i < n-1 && s[i+1] == '{' {
+				count++
+			}
 		case '}':
 			count--
 			if count == 0 {
@@ -494,7 +510,38 @@ func resolve(p *Properties, param BindParam) (string, error) {
 		return resolveString(p, val)
 	}
 	if param.Tag.HasDef {
+		if def := param.Tag.Def; strings.HasPrefix(def, "#{") && strings.HasSuffix(def, "}") {
+			return evalString(p, def[2:len(def)-1])
+		}
 		return resolveString(p, param.Tag.Def)
 	}
 	return "", util.Errorf(code.FileLine(), "property %q %w", param.Key, ErrNotExist)
 }
+
+// evalString 计算 #{expr} 形式的默认值表达式，表达式中可以使用 ${key:=def} 属性引用。
+func evalString(p *Properties, s string) (string, error) {
+	v, err := expr.Eval(s, expr.Env{Resolve: p.Resolve})
+	if err != nil {
+		return "", err
+	}
+	switch r := v.(type) {
+	case nil:
+		return "", nil
+	case float64:
+		return strconv.FormatFloat(r, 'f', -1, 64), nil
+	default:
+		return fmt.Sprint(r), nil
+	}
+}
+
+// validate 使用 expr 标签的表达式校验绑定的属性值，表达式中使用 $ 表示属性值。
+func validate(p *Properties, v reflect.Value, s string, param BindParam) error {
+	ok, err := expr.EvalBool(s, expr.Env{Value: v.Interface(), Resolve: p.Resolve})
+	if err != nil {
+		return util.Wrapf(err, code.FileLine(), "%s validate error", param.Path)
+	}
+	if !ok {
+		return util.Errorf(code.FileLine(), "%s validate failed, %q is not satisfied by %v", param.Path, s, v.Interface())
+	}
+	return nil
+}
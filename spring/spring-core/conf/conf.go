@@ -34,6 +34,7 @@ import (
 func init() {
 	RegisterConverter(TimeConverter)
 	RegisterConverter(DurationConverter)
+	RegisterConverter(ByteSizeConverter)
 }
 
 // Properties 提供创建和读取属性列表的方法。它使用扁平的 map[string]string 结
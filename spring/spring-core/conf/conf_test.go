@@ -616,6 +616,68 @@ func TestBindMap(t *testing.T) {
 		assert.Equal(t, r["a"].B1, "ab1")
 	})
 
+	t.Run("", func(t *testing.T) {
+		var r struct {
+			A map[string]string `value:"${a:=}"`
+			C map[string]string `value:"${c:=}"`
+		}
+		p := conf.Map(m)
+		err := p.Bind(&r)
+		assert.Nil(t, err)
+		assert.Equal(t, r.A, map[string]string{"b1": "ab1", "b2": "ab2", "b3": "ab3"})
+		assert.Nil(t, r.C)
+	})
+
+	t.Run("", func(t *testing.T) {
+		var r struct {
+			A map[string]string `value:"${a:=x}"`
+		}
+		err := conf.Map(m).Bind(&r)
+		assert.Error(t, err, "A map 类型不能指定非空默认值")
+	})
+
+	t.Run("", func(t *testing.T) {
+		var r struct {
+			M map[string]struct {
+				B1 string `value:"${b1}"`
+				B4 string `value:"${b4:=def}"`
+			} `value:"${m:=}"`
+		}
+		p := conf.Map(map[string]interface{}{"m.a.b1": "ab1", "m.b.b1": "bb1"})
+		err := p.Bind(&r)
+		assert.Nil(t, err)
+		assert.Equal(t, len(r.M), 2)
+		assert.Equal(t, r.M["b"].B1, "bb1")
+		assert.Equal(t, r.M["b"].B4, "def")
+	})
+
+	t.Run("", func(t *testing.T) {
+		var r struct {
+			A map[string]string `value:"${a:=}"`
+		}
+		p := conf.Map(map[string]interface{}{"a": "1"})
+		err := p.Bind(&r)
+		assert.Error(t, err, "property \"a\" has a value but want another sub key \"a\\.\\*\"")
+	})
+
+	// 切片元素中的 map 字段，key 为 s[0].m 这种带下标的形式。
+	t.Run("", func(t *testing.T) {
+		var r struct {
+			S []struct {
+				K string            `value:"${k}"`
+				M map[string]string `value:"${m:=}"`
+			} `value:"${s:=}"`
+		}
+		p := conf.Map(map[string]interface{}{
+			"s[0].k": "a", "s[0].m.x": "1", "s[1].k": "b",
+		})
+		err := p.Bind(&r)
+		assert.Nil(t, err)
+		assert.Equal(t, len(r.S), 2)
+		assert.Equal(t, r.S[0].M, map[string]string{"x": "1"})
+		assert.Nil(t, r.S[1].M)
+	})
+
 	t.Run("", func(t *testing.T) {
 		p := conf.Map(map[string]interface{}{"a.b1": "ab1"})
 		var r map[string]string
@@ -663,6 +725,36 @@ func TestInterpolate(t *testing.T) {
 	assert.Equal(t, str, "my name is Jim my name is Jim")
 }
 
+func TestExpression(t *testing.T) {
+
+	p := conf.New()
+	err := p.Set("base.timeout", "10")
+	assert.Nil(t, err)
+
+	t.Run("default", func(t *testing.T) {
+		str, err := p.Resolve("${timeout:=#{${base.timeout} * 2}}")
+		assert.Nil(t, err)
+		assert.Equal(t, str, "20")
+		str, err = p.Resolve("${name:=#{hasPrefix(\"go-spring\", \"go\") || false}}")
+		assert.Nil(t, err)
+		assert.Equal(t, str, "true")
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		var s struct {
+			Timeout int `value:"${base.timeout}" expr:"$ > 0 && $ <= 60"`
+		}
+		err = p.Bind(&s)
+		assert.Nil(t, err)
+		assert.Equal(t, s.Timeout, 10)
+		var f struct {
+			Timeout int `value:"${base.timeout}" expr:"$ > 30"`
+		}
+		err = p.Bind(&f)
+		assert.Error(t, err, "validate failed")
+	})
+}
+
 func TestProperties_Has(t *testing.T) {
 
 	t.Run("", func(t *testing.T) {
@@ -761,3 +853,63 @@ func TestSplitter(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, points, []image.Point{{X: 1, Y: 2}, {X: 3, Y: 4}})
 }
+
+func TestByteSize(t *testing.T) {
+
+	data := []struct {
+		str  string
+		size conf.ByteSize
+	}{
+		{"1024", 1024},
+		{"512B", 512},
+		{"4KB", 4 * conf.KiloByte},
+		{"512MB", 512 * conf.MegaByte},
+		{"512 mb", 512 * conf.MegaByte},
+		{"1GiB", conf.GigaByte},
+		{"1.5G", conf.GigaByte + 512*conf.MegaByte},
+		{"2TB", 2 * conf.TeraByte},
+	}
+	for _, d := range data {
+		size, err := conf.ParseByteSize(d.str)
+		assert.Nil(t, err)
+		assert.Equal(t, size, d.size)
+	}
+
+	for _, s := range []string{"", "MB", "1PB", "1.2.3KB", "-1KB"} {
+		_, err := conf.ParseByteSize(s)
+		assert.Error(t, err, "invalid byte size")
+	}
+
+	assert.Equal(t, conf.ByteSize(0).String(), "0B")
+	assert.Equal(t, conf.ByteSize(1000).String(), "1000B")
+	assert.Equal(t, (512 * conf.MegaByte).String(), "512MB")
+	assert.Equal(t, (1536 * conf.MegaByte).String(), "1536MB")
+
+	type Config struct {
+		MaxBody conf.ByteSize   `value:"${max-body:=1MB}"`
+		Cache   conf.ByteSize   `value:"${cache-size}"`
+		Timeout time.Duration   `value:"${timeout:=10s}"`
+		Ticks   []time.Duration `value:"${ticks}"`
+	}
+
+	p := conf.New()
+	_ = p.Set("cache-size", "1GiB")
+	_ = p.Set("ticks", "5m,1h")
+
+	var c Config
+	err := p.Bind(&c)
+	assert.Nil(t, err)
+	assert.Equal(t, c.MaxBody, conf.MegaByte)
+	assert.Equal(t, c.Cache, conf.GigaByte)
+	assert.Equal(t, c.Timeout, 10*time.Second)
+	assert.Equal(t, c.Ticks, []time.Duration{5 * time.Minute, time.Hour})
+
+	_ = p.Set("cache-size", "1XB")
+	err = p.Bind(&c)
+	assert.Error(t, err, `property "cache-size" bind error\ninvalid byte size "1XB"`)
+
+	_ = p.Set("cache-size", "1GB")
+	_ = p.Set("timeout", "10x")
+	err = p.Bind(&c)
+	assert.Error(t, err, `property "timeout" bind error`)
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dotenv
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Read 将 .env 格式的字节数组解析成 map 数据。每行一个 KEY=VALUE ，支持 # 开头
+// 的注释行和 export 前缀，双引号包裹的值支持转义字符，单引号包裹的值保持原样，没有
+// 引号的值中空白之后的 # 开始的内容是注释。
+func Read(b []byte) (map[string]interface{}, error) {
+
+	ret := make(map[string]interface{})
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+
+	for n := 1; scanner.Scan(); n++ {
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: expect KEY=VALUE but got %q", n, line)
+		}
+		key := strings.TrimSpace(line[:i])
+		val := strings.TrimSpace(line[i+1:])
+
+		switch {
+		case len(val) >= 2 && val[0] == '"' && val[len(val)-1] == '"':
+			s, err := strconv.Unquote(val)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			val = s
+		case len(val) >= 2 && val[0] == '\'' && val[len(val)-1] == '\'':
+			val = val[1 : len(val)-1]
+		default:
+			if j := strings.Index(val, " #"); j >= 0 {
+				val = strings.TrimSpace(val[:j])
+			}
+		}
+		ret[key] = val
+	}
+	return ret, scanner.Err()
+}
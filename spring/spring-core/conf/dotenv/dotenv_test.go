@@ -0,0 +1,53 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dotenv_test
+
+import (
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/conf"
+)
+
+func TestProperties_ReadDotenv(t *testing.T) {
+
+	str := `
+# database
+DB_HOST=localhost
+export DB_PORT=3306 # comment
+DB_USER = "root\tadmin"
+DB_PASS='p#ss\n'
+EMPTY=
+`
+
+	p, err := conf.Bytes([]byte(str), ".env")
+	assert.Nil(t, err)
+
+	data := map[string]string{
+		"DB_HOST": "localhost",
+		"DB_PORT": "3306",
+		"DB_USER": "root\tadmin",
+		"DB_PASS": `p#ss\n`,
+		"EMPTY":   "",
+	}
+	for k, expect := range data {
+		assert.Equal(t, p.Get(k), expect)
+	}
+
+	_, err = conf.Bytes([]byte("DB_HOST"), ".env")
+	assert.Error(t, err, `line 1: expect KEY=VALUE but got "DB_HOST"`)
+}
@@ -17,8 +17,10 @@
 package conf
 
 import (
+	"github.com/go-spring/spring-core/conf/dotenv"
 	"github.com/go-spring/spring-core/conf/prop"
 	"github.com/go-spring/spring-core/conf/toml"
+	"github.com/go-spring/spring-core/conf/xml"
 	"github.com/go-spring/spring-core/conf/yaml"
 )
 
@@ -26,6 +28,8 @@ func init() {
 	NewReader(prop.Read, ".properties")
 	NewReader(yaml.Read, ".yaml", ".yml")
 	NewReader(toml.Read, ".toml", ".tml")
+	NewReader(dotenv.Read, ".env")
+	NewReader(xml.Read, ".xml")
 }
 
 var readers = make(map[string]Reader)
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conf
+
+import (
+	"strings"
+)
+
+// MaskedValue 敏感属性被替换成的值。
+const MaskedValue = "******"
+
+// DefaultSanitizeKeys 默认的敏感属性关键字，属性名包含其中任意一个时属性值被隐藏。
+var DefaultSanitizeKeys = []string{
+	"password", "secret", "token", "credential", "private-key", "access-key", "api-key",
+}
+
+// Sanitizer 在诊断接口、启动信息等输出属性值的地方隐藏敏感属性的值。
+type Sanitizer interface {
+	Sanitize(key, value string) string
+}
+
+// keySanitizer 根据属性名中包含的关键字隐藏属性值，关键字不区分大小写。
+type keySanitizer struct {
+	keys []string
+}
+
+// NewSanitizer 返回根据属性名隐藏属性值的 Sanitizer ，keys 为空时使用
+// DefaultSanitizeKeys 。
+func NewSanitizer(keys ...string) Sanitizer {
+	if len(keys) == 0 {
+		keys = DefaultSanitizeKeys
+	}
+	s := &keySanitizer{}
+	for _, k := range keys {
+		if k = strings.TrimSpace(k); k != "" {
+			s.keys = append(s.keys, strings.ToLower(k))
+		}
+	}
+	return s
+}
+
+func (s *keySanitizer) Sanitize(key, value string) string {
+	if value == "" {
+		return value
+	}
+	// 同时匹配 private-key 、private_key 和 privateKey 等写法。
+	k := strings.ToLower(key)
+	n := strings.NewReplacer("_", "-").Replace(k)
+	for _, m := range s.keys {
+		if strings.Contains(k, m) || strings.Contains(n, m) || strings.Contains(k, strings.Replace(m, "-", "", -1)) {
+			return MaskedValue
+		}
+	}
+	return value
+}
+
+// Sanitized 返回经过 s 处理之后的所有属性。
+func (p *Properties) Sanitized(s Sanitizer) map[string]string {
+	m := make(map[string]string)
+	for _, k := range p.Keys() {
+		m[k] = s.Sanitize(k, p.Get(k))
+	}
+	return m
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conf_test
+
+import (
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/conf"
+)
+
+func TestSanitizer(t *testing.T) {
+
+	s := conf.NewSanitizer()
+	assert.Equal(t, s.Sanitize("db.password", "123"), conf.MaskedValue)
+	assert.Equal(t, s.Sanitize("vault.TOKEN", "abc"), conf.MaskedValue)
+	assert.Equal(t, s.Sanitize("tls.private_key", "abc"), conf.MaskedValue)
+	assert.Equal(t, s.Sanitize("tls.privateKey", "abc"), conf.MaskedValue)
+	assert.Equal(t, s.Sanitize("db.password", ""), "")
+	assert.Equal(t, s.Sanitize("db.url", "mysql://"), "mysql://")
+
+	s = conf.NewSanitizer("url", " ")
+	assert.Equal(t, s.Sanitize("db.url", "mysql://"), conf.MaskedValue)
+	assert.Equal(t, s.Sanitize("db.password", "123"), "123")
+
+	p := conf.New()
+	_ = p.Set("db.url", "mysql://")
+	_ = p.Set("db.password", "123")
+	assert.Equal(t, p.Sanitized(conf.NewSanitizer()), map[string]string{
+		"db.url":      "mysql://",
+		"db.password": conf.MaskedValue,
+	})
+}
@@ -0,0 +1,210 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conf
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// PropSpec 描述单个属性的约束。
+type PropSpec struct {
+	Key        string       // 属性名
+	Required   bool         // 属性是否必须存在
+	Type       reflect.Type // 属性值必须能够绑定到该类型，为 nil 时不检查
+	Enum       []string     // 属性值的可选范围，为空时不检查
+	Deprecated bool         // 属性是否已废弃
+	ReplacedBy string       // 替代已废弃属性的新属性名
+	Renamed    bool         // 属性是否已改名为 ReplacedBy ，旧属性的值可以直接迁移
+}
+
+// Schema 描述一个模块声明的属性约束，通常由 starter 在 init 函数中声明。
+type Schema struct {
+	module string
+	specs  []*PropSpec
+}
+
+// NewSchema 返回 module 模块的属性约束。
+func NewSchema(module string) *Schema {
+	return &Schema{module: module}
+}
+
+// Module 返回声明约束的模块名。
+func (s *Schema) Module() string {
+	return s.module
+}
+
+// Specs 返回按照声明顺序排列的属性约束。
+func (s *Schema) Specs() []*PropSpec {
+	return s.specs
+}
+
+func (s *Schema) spec(key string) *PropSpec {
+	for _, p := range s.specs {
+		if p.Key == key {
+			return p
+		}
+	}
+	p := &PropSpec{Key: key}
+	s.specs = append(s.specs, p)
+	return p
+}
+
+// Required 声明属性必须存在。
+func (s *Schema) Required(keys ...string) *Schema {
+	for _, key := range keys {
+		s.spec(key).Required = true
+	}
+	return s
+}
+
+// Type 声明属性值必须能够绑定到 i 的类型，如 0 、time.Second 、[]string{} 等。
+func (s *Schema) Type(key string, i interface{}) *Schema {
+	s.spec(key).Type = reflect.TypeOf(i)
+	return s
+}
+
+// Enum 声明属性值只能是 values 中的一个。
+func (s *Schema) Enum(key string, values ...string) *Schema {
+	s.spec(key).Enum = values
+	return s
+}
+
+// Deprecated 声明属性已废弃，replacedBy 是替代它的新属性名，可以为空。
+func (s *Schema) Deprecated(key string, replacedBy string) *Schema {
+	p := s.spec(key)
+	p.Deprecated = true
+	p.ReplacedBy = replacedBy
+	return s
+}
+
+// Renamed 声明属性 oldKey 已经改名为 newKey ，Migrate 会将 oldKey 的值迁移到
+// newKey 上，从而兼容升级之前的属性文件。
+func (s *Schema) Renamed(oldKey string, newKey string) *Schema {
+	p := s.spec(oldKey)
+	p.Deprecated = true
+	p.ReplacedBy = newKey
+	p.Renamed = true
+	return s
+}
+
+// Migrate 将已改名属性的值迁移到新属性上，新属性已经存在时不迁移。返回值记录了被
+// 迁移的属性，key 是旧属性名，value 是新属性名，slice 和 map 类型的属性按照展开
+// 之后的属性名记录。
+func (s *Schema) Migrate(p *Properties) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, spec := range s.specs {
+		if !spec.Renamed || !p.Has(spec.Key) || p.Has(spec.ReplacedBy) {
+			continue
+		}
+		for _, k := range p.Keys() {
+			var suffix string
+			switch {
+			case k == spec.Key:
+			case strings.HasPrefix(k, spec.Key+"."), strings.HasPrefix(k, spec.Key+"["):
+				suffix = k[len(spec.Key):]
+			default:
+				continue
+			}
+			newKey := spec.ReplacedBy + suffix
+			if err := p.Set(newKey, p.Get(k)); err != nil {
+				return nil, err
+			}
+			m[k] = newKey
+		}
+	}
+	return m, nil
+}
+
+// Violation 属性违反约束的详细信息。
+type Violation struct {
+	Module  string // 声明约束的模块
+	Key     string // 违反约束的属性名
+	Source  string // 属性的来源，如属性文件的路径，未知时为空
+	Reason  string // 违反约束的原因
+	Warning bool   // 是否仅需要警告，如使用了已废弃的属性
+}
+
+func (v Violation) String() string {
+	s := fmt.Sprintf("[%s] property %q %s", v.Module, v.Key, v.Reason)
+	if v.Source != "" {
+		s += " (" + v.Source + ")"
+	}
+	return s
+}
+
+// Validate 校验属性列表是否满足约束，返回所有违反约束的属性。
+func (s *Schema) Validate(p *Properties) []Violation {
+	var ret []Violation
+	for _, spec := range s.specs {
+		reason, warning := validateSpec(p, spec)
+		if reason == "" {
+			continue
+		}
+		ret = append(ret, Violation{
+			Module:  s.module,
+			Key:     spec.Key,
+			Reason:  reason,
+			Warning: warning,
+		})
+	}
+	return ret
+}
+
+func validateSpec(p *Properties, spec *PropSpec) (reason string, warning bool) {
+
+	if !p.Has(spec.Key) {
+		if spec.Required {
+			return "is required", false
+		}
+		return "", false
+	}
+
+	if spec.Type != nil {
+		v := reflect.New(spec.Type).Elem()
+		if err := p.Bind(v, Key(spec.Key)); err != nil {
+			return fmt.Sprintf("is not a valid %s", spec.Type), false
+		}
+	}
+
+	if len(spec.Enum) > 0 {
+		val := p.Get(spec.Key)
+		found := false
+		for _, e := range spec.Enum {
+			if e == val {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("value %q is not one of [%s]", val, strings.Join(spec.Enum, ",")), false
+		}
+	}
+
+	if spec.Renamed {
+		return fmt.Sprintf("has been renamed to %q", spec.ReplacedBy), true
+	}
+
+	if spec.Deprecated {
+		if spec.ReplacedBy != "" {
+			return fmt.Sprintf("is deprecated, use %q instead", spec.ReplacedBy), true
+		}
+		return "is deprecated", true
+	}
+	return "", false
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conf_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/conf"
+)
+
+func TestSchema(t *testing.T) {
+
+	s := conf.NewSchema("redis").
+		Required("redis.host").
+		Type("redis.port", 0).
+		Type("redis.timeout", time.Second).
+		Enum("redis.mode", "single", "cluster").
+		Deprecated("redis.addr", "redis.host").
+		Deprecated("redis.pool", "")
+
+	p := conf.Map(map[string]interface{}{
+		"redis.host":    "127.0.0.1",
+		"redis.port":    "6379",
+		"redis.timeout": "1s",
+		"redis.mode":    "cluster",
+	})
+	assert.Equal(t, len(s.Validate(p)), 0)
+
+	p = conf.Map(map[string]interface{}{
+		"redis.port":    "abc",
+		"redis.timeout": "1s",
+		"redis.mode":    "sentinel",
+		"redis.addr":    "127.0.0.1:6379",
+		"redis.pool":    "10",
+	})
+	var ss []string
+	for _, v := range s.Validate(p) {
+		assert.Equal(t, v.Module, "redis")
+		ss = append(ss, v.String())
+	}
+	assert.Equal(t, ss, []string{
+		`[redis] property "redis.host" is required`,
+		`[redis] property "redis.port" is not a valid int`,
+		`[redis] property "redis.mode" value "sentinel" is not one of [single,cluster]`,
+		`[redis] property "redis.addr" is deprecated, use "redis.host" instead`,
+		`[redis] property "redis.pool" is deprecated`,
+	})
+
+	v := s.Validate(p)[3]
+	assert.True(t, v.Warning)
+	v.Source = "config/application.properties"
+	assert.Equal(t, v.String(), `[redis] property "redis.addr" is deprecated, use "redis.host" instead (config/application.properties)`)
+
+	s = conf.NewSchema("redis").Renamed("redis.addr", "redis.host").Renamed("redis.nodes", "redis.cluster.nodes")
+	p = conf.Map(map[string]interface{}{
+		"redis.addr":  "127.0.0.1",
+		"redis.nodes": []string{"a", "b"},
+	})
+	m, err := s.Migrate(p)
+	assert.Nil(t, err)
+	assert.Equal(t, m, map[string]string{
+		"redis.addr":     "redis.host",
+		"redis.nodes[0]": "redis.cluster.nodes[0]",
+		"redis.nodes[1]": "redis.cluster.nodes[1]",
+	})
+	assert.Equal(t, p.Get("redis.host"), "127.0.0.1")
+	var nodes []string
+	assert.Nil(t, p.Bind(&nodes, conf.Key("redis.cluster.nodes")))
+	assert.Equal(t, nodes, []string{"a", "b"})
+	vs := s.Validate(p)
+	assert.Equal(t, len(vs), 2)
+	assert.True(t, vs[0].Warning)
+	assert.Equal(t, vs[0].String(), `[redis] property "redis.addr" has been renamed to "redis.host"`)
+
+	// 新属性已经存在时不迁移
+	p = conf.Map(map[string]interface{}{
+		"redis.addr": "127.0.0.1",
+		"redis.host": "localhost",
+	})
+	m, err = s.Migrate(p)
+	assert.Nil(t, err)
+	assert.Equal(t, len(m), 0)
+	assert.Equal(t, p.Get("redis.host"), "localhost")
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ByteSize 字节数，绑定属性时支持 512MB、1GiB 等形式，常用于请求体大小、缓存容量
+// 等配置项。和 Java Spring 的 DataSize 一样，KB、MB 等单位按照 1024 进制计算，和
+// KiB、MiB 等单位相同。
+type ByteSize int64
+
+const (
+	Byte     ByteSize = 1
+	KiloByte          = 1024 * Byte
+	MegaByte          = 1024 * KiloByte
+	GigaByte          = 1024 * MegaByte
+	TeraByte          = 1024 * GigaByte
+)
+
+var byteSizeUnits = map[string]ByteSize{
+	"":    Byte,
+	"b":   Byte,
+	"k":   KiloByte,
+	"kb":  KiloByte,
+	"kib": KiloByte,
+	"m":   MegaByte,
+	"mb":  MegaByte,
+	"mib": MegaByte,
+	"g":   GigaByte,
+	"gb":  GigaByte,
+	"gib": GigaByte,
+	"t":   TeraByte,
+	"tb":  TeraByte,
+	"tib": TeraByte,
+}
+
+// ParseByteSize 解析 1024、512MB、1.5GiB 等形式的字节数，单位不区分大小写，没有
+// 单位时表示字节。
+func ParseByteSize(s string) (ByteSize, error) {
+	str := strings.TrimSpace(s)
+	i := strings.IndexFunc(str, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(str)
+	}
+	num, unit := str[:i], strings.ToLower(strings.TrimSpace(str[i:]))
+	m, ok := byteSizeUnits[unit]
+	if !ok || num == "" {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	if !strings.Contains(num, ".") {
+		n, err := strconv.ParseInt(num, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid byte size %q", s)
+		}
+		return ByteSize(n) * m, nil
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	return ByteSize(f * float64(m)), nil
+}
+
+// ByteSizeConverter 转换函数，支持 "B", "KB", "MB", "GB", "TB" 以及 "KiB" 等。
+func ByteSizeConverter(s string) (ByteSize, error) {
+	return ParseByteSize(s)
+}
+
+// String 返回能够整除的最大单位的表示形式，例如 512MB 。
+func (s ByteSize) String() string {
+	units := []struct {
+		size ByteSize
+		name string
+	}{
+		{TeraByte, "TB"},
+		{GigaByte, "GB"},
+		{MegaByte, "MB"},
+		{KiloByte, "KB"},
+	}
+	for _, u := range units {
+		if s != 0 && s%u.size == 0 {
+			return strconv.FormatInt(int64(s/u.size), 10) + u.name
+		}
+	}
+	return strconv.FormatInt(int64(s), 10) + "B"
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// node xml 元素。
+type node struct {
+	name     string
+	attrs    []xml.Attr
+	children []*node
+	text     strings.Builder
+}
+
+// Read 将简单的 xml 格式的字节数组解析成 map 数据。根元素被忽略，嵌套的元素名使用
+// . 连接成属性名，属性和子元素的处理方式相同，同名的兄弟元素组成数组。同时兼容 Java
+// 的 properties xml 格式，即 <properties><entry key="a.b">v</entry></properties> 。
+// 应用默认不加载 application.xml ，需要在 spring.config.extensions 中添加 .xml 。
+func Read(b []byte) (map[string]interface{}, error) {
+
+	root, err := parse(b)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]interface{})
+	if root == nil {
+		return ret, nil
+	}
+
+	if root.name == "properties" {
+		for _, c := range root.children {
+			if c.name != "entry" {
+				continue
+			}
+			key, ok := attr(c, "key")
+			if !ok {
+				return nil, fmt.Errorf("xml: entry without key attribute")
+			}
+			ret[key] = strings.TrimSpace(c.text.String())
+		}
+		return ret, nil
+	}
+
+	if m, ok := value(root).(map[string]interface{}); ok {
+		return m, nil
+	}
+	return ret, nil
+}
+
+// parse 将 xml 解析成元素树，返回根元素，没有元素时返回 nil 。
+func parse(b []byte) (*node, error) {
+
+	var (
+		root  *node
+		stack []*node
+	)
+
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch v := t.(type) {
+		case xml.StartElement:
+			n := &node{name: v.Name.Local, attrs: v.Attr}
+			if len(stack) == 0 {
+				if root != nil {
+					return nil, fmt.Errorf("xml: multiple root elements")
+				}
+				root = n
+			} else {
+				p := stack[len(stack)-1]
+				p.children = append(p.children, n)
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(v)
+			}
+		}
+	}
+	return root, nil
+}
+
+func attr(n *node, name string) (string, bool) {
+	for _, a := range n.attrs {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// value 返回元素的值，没有属性和子元素时返回去掉首尾空白的文本，否则返回 map 。
+func value(n *node) interface{} {
+
+	if len(n.attrs) == 0 && len(n.children) == 0 {
+		return strings.TrimSpace(n.text.String())
+	}
+
+	m := make(map[string]interface{})
+	for _, a := range n.attrs {
+		if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+			continue
+		}
+		m[a.Name.Local] = a.Value
+	}
+
+	var names []string
+	groups := make(map[string][]interface{})
+	for _, c := range n.children {
+		if _, ok := groups[c.name]; !ok {
+			names = append(names, c.name)
+		}
+		groups[c.name] = append(groups[c.name], value(c))
+	}
+	for _, name := range names {
+		if g := groups[name]; len(g) == 1 {
+			m[name] = g[0]
+		} else {
+			m[name] = g
+		}
+	}
+	return m
+}
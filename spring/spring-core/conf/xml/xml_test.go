@@ -0,0 +1,79 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xml_test
+
+import (
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/conf"
+)
+
+func TestProperties_ReadXml(t *testing.T) {
+
+	t.Run("element", func(t *testing.T) {
+
+		str := `<?xml version="1.0" encoding="UTF-8"?>
+          <config>
+            <server port="8080">
+              <host> localhost </host>
+            </server>
+            <hosts>a</hosts>
+            <hosts>b</hosts>
+            <db><user>root</user><pass/></db>
+          </config>`
+
+		p, err := conf.Bytes([]byte(str), ".xml")
+		assert.Nil(t, err)
+
+		data := map[string]string{
+			"server.port": "8080",
+			"server.host": "localhost",
+			"hosts[0]":    "a",
+			"hosts[1]":    "b",
+			"db.user":     "root",
+			"db.pass":     "",
+		}
+		for k, expect := range data {
+			assert.Equal(t, p.Get(k), expect)
+		}
+	})
+
+	t.Run("java properties", func(t *testing.T) {
+
+		str := `<?xml version="1.0" encoding="UTF-8"?>
+          <!DOCTYPE properties SYSTEM "http://java.sun.com/dtd/properties.dtd">
+          <properties>
+            <comment>legacy</comment>
+            <entry key="spring.application.name">demo</entry>
+            <entry key="server.port">8080</entry>
+          </properties>`
+
+		p, err := conf.Bytes([]byte(str), ".xml")
+		assert.Nil(t, err)
+		assert.Equal(t, p.Get("spring.application.name"), "demo")
+		assert.Equal(t, p.Get("server.port"), "8080")
+		assert.False(t, p.Has("comment"))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := conf.Bytes([]byte(`<properties><entry>v</entry></properties>`), ".xml")
+		assert.Error(t, err, "xml: entry without key attribute")
+		_, err = conf.Bytes([]byte(`<a></b>`), ".xml")
+		assert.NotNil(t, err)
+	})
+}
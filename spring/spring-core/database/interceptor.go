@@ -0,0 +1,157 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Conn 执行 SQL 的连接，*sql.DB、*sql.Tx 和 *RoutingDB 都实现了该接口。
+type Conn interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Statement 一次 SQL 执行，拦截器可以修改语句和参数。
+type Statement struct {
+	Query string
+	Args  []interface{}
+}
+
+// Invoker 执行 SQL 。
+type Invoker func(ctx context.Context, s *Statement) error
+
+// Interceptor 拦截 SQL 的执行，用于统计、审计和改写语句等场景。拦截器必须调用 next
+// 才能继续执行，注册为 bean 之后由 starter 自动使用。注意，QueryRowContext 的错误
+// 在 Scan 时才能获取，拦截器无法感知。
+type Interceptor interface {
+	Intercept(ctx context.Context, s *Statement, next Invoker) error
+}
+
+// InterceptorFunc 函数形式的 Interceptor 实现。
+type InterceptorFunc func(ctx context.Context, s *Statement, next Invoker) error
+
+func (f InterceptorFunc) Intercept(ctx context.Context, s *Statement, next Invoker) error {
+	return f(ctx, s, next)
+}
+
+// Chain 将多个拦截器组合成一个 Invoker ，排在前面的拦截器先执行。
+func Chain(interceptors []Interceptor, last Invoker) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], last
+		last = func(ctx context.Context, s *Statement) error {
+			return interceptor.Intercept(ctx, s, next)
+		}
+	}
+	return last
+}
+
+// InterceptedConn 使用拦截器执行 SQL 的连接。
+type InterceptedConn struct {
+	conn         Conn
+	interceptors []Interceptor
+}
+
+// Intercept 返回使用 interceptors 拦截 conn 上所有 SQL 的连接。
+func Intercept(conn Conn, interceptors ...Interceptor) *InterceptedConn {
+	return &InterceptedConn{conn: conn, interceptors: interceptors}
+}
+
+// Conn 返回原始的连接。
+func (c *InterceptedConn) Conn() Conn {
+	return c.conn
+}
+
+func (c *InterceptedConn) invoke(ctx context.Context, query string, args []interface{}, fn Invoker) error {
+	return Chain(c.interceptors, fn)(ctx, &Statement{Query: query, Args: args})
+}
+
+func (c *InterceptedConn) PrepareContext(ctx context.Context, query string) (stmt *sql.Stmt, err error) {
+	err = c.invoke(ctx, query, nil, func(ctx context.Context, s *Statement) error {
+		stmt, err = c.conn.PrepareContext(ctx, s.Query)
+		return err
+	})
+	return
+}
+
+func (c *InterceptedConn) ExecContext(ctx context.Context, query string, args ...interface{}) (result sql.Result, err error) {
+	err = c.invoke(ctx, query, args, func(ctx context.Context, s *Statement) error {
+		result, err = c.conn.ExecContext(ctx, s.Query, s.Args...)
+		return err
+	})
+	return
+}
+
+func (c *InterceptedConn) QueryContext(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, err error) {
+	err = c.invoke(ctx, query, args, func(ctx context.Context, s *Statement) error {
+		rows, err = c.conn.QueryContext(ctx, s.Query, s.Args...)
+		return err
+	})
+	return
+}
+
+func (c *InterceptedConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) (row *sql.Row) {
+	_ = c.invoke(ctx, query, args, func(ctx context.Context, s *Statement) error {
+		row = c.conn.QueryRowContext(ctx, s.Query, s.Args...)
+		return nil
+	})
+	return
+}
+
+// BeginTx 开启事务，事务中的 SQL 同样经过拦截器，conn 必须支持事务。
+func (c *InterceptedConn) BeginTx(ctx context.Context, opts *sql.TxOptions) (*InterceptedTx, error) {
+	beginner, ok := c.conn.(interface {
+		BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	})
+	if !ok {
+		return nil, errors.New("database: conn does not support transactions")
+	}
+	tx, err := beginner.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &InterceptedTx{InterceptedConn: Intercept(tx, c.interceptors...), tx: tx}, nil
+}
+
+// GetDBConn 返回底层的 *sql.DB ，gorm 通过该方法获取 *sql.DB 。
+func (c *InterceptedConn) GetDBConn() (*sql.DB, error) {
+	switch conn := c.conn.(type) {
+	case *sql.DB:
+		return conn, nil
+	case interface{ GetDBConn() (*sql.DB, error) }:
+		return conn.GetDBConn()
+	}
+	return nil, errors.New("database: conn is not a *sql.DB")
+}
+
+// InterceptedTx 使用拦截器执行 SQL 的事务。
+type InterceptedTx struct {
+	*InterceptedConn
+	tx *sql.Tx
+}
+
+func (t *InterceptedTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *InterceptedTx) Rollback() error {
+	return t.tx.Rollback()
+}
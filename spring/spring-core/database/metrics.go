@@ -0,0 +1,265 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets 执行时间直方图默认的桶。
+var DefaultBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// OtherDigest 语句摘要的数量超过限制之后，新的语句统计在该摘要下。
+const OtherDigest = "other"
+
+// maxDigestLen 语句摘要的最大长度。
+const maxDigestLen = 512
+
+var (
+	inList = regexp.MustCompile(`\(\?(\s*,\s*\?)+\)`)
+	tuples = regexp.MustCompile(`\((\?|\.\.\.)\)(\s*,\s*\((\?|\.\.\.)\))+`)
+)
+
+// Digest 返回语句的摘要，将字面量和占位符替换成 ?，合并空白字符，去掉注释，
+// 并且将 IN 列表和批量插入的多组值合并成 (...) ，相同结构的语句具有相同的摘要。
+func Digest(query string) string {
+	var b strings.Builder
+	space := false
+	write := func(s string) {
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteString(s)
+	}
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			space = true
+			i++
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+			space = true
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 4
+			}
+			space = true
+		case c == '\'':
+			i++
+			for i < len(query) {
+				if query[i] == '\\' {
+					i += 2
+					continue
+				}
+				if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i++
+			write("?")
+		case c == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			i++
+			for i < len(query) && isDigit(query[i]) {
+				i++
+			}
+			write("?")
+		case isDigit(c) && (i == 0 || !isIdent(query[i-1])):
+			for i < len(query) && (isDigit(query[i]) || query[i] == '.') {
+				i++
+			}
+			write("?")
+		default:
+			j := i + 1
+			if isIdent(c) {
+				for j < len(query) && isIdent(query[j]) {
+					j++
+				}
+			}
+			write(query[i:j])
+			i = j
+		}
+	}
+	s := inList.ReplaceAllString(b.String(), "(...)")
+	s = tuples.ReplaceAllString(s, "(...)")
+	if len(s) > maxDigestLen {
+		s = s[:maxDigestLen]
+	}
+	return s
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdent(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+// MetricsConfig SQL 统计的配置，通常配合 db.metrics 前缀一起使用。
+type MetricsConfig struct {
+	SlowThreshold time.Duration `value:"${slow-threshold:=500ms}"` // 执行时间超过该值的语句记录日志，为 0 时不记录
+	MaxDigests    int           `value:"${max-digests:=1000}"`     // 最多统计的语句摘要的数量
+}
+
+// Bucket 直方图的桶，Count 为执行时间不超过 Le 的次数。
+type Bucket struct {
+	Le    time.Duration `json:"le"`
+	Count int64         `json:"count"`
+}
+
+// QueryStats 一类语句的统计数据。
+type QueryStats struct {
+	Digest  string        `json:"digest"`
+	Count   int64         `json:"count"`
+	Errors  int64         `json:"errors"`
+	Total   time.Duration `json:"total"`
+	Max     time.Duration `json:"max"`
+	Buckets []Bucket      `json:"buckets"`
+}
+
+type histogram struct {
+	count  int64
+	errors int64
+	total  time.Duration
+	max    time.Duration
+	counts []int64
+}
+
+// Metrics 按照语句摘要统计执行时间的拦截器，同时记录慢查询日志。
+type Metrics struct {
+	config  MetricsConfig
+	buckets []time.Duration
+
+	mutex  sync.Mutex
+	digest map[string]*histogram
+}
+
+// NewMetrics 创建 Metrics ，使用 DefaultBuckets 作为直方图的桶。
+func NewMetrics(config MetricsConfig) *Metrics {
+	return &Metrics{
+		config:  config,
+		buckets: DefaultBuckets,
+		digest:  make(map[string]*histogram),
+	}
+}
+
+// Intercept 统计语句的执行时间。
+func (m *Metrics) Intercept(ctx context.Context, s *Statement, next Invoker) error {
+	start := time.Now()
+	err := next(ctx, s)
+	d := time.Since(start)
+	digest := Digest(s.Query)
+	m.observe(digest, d, err != nil && !errors.Is(err, sql.ErrNoRows))
+	if m.config.SlowThreshold > 0 && d >= m.config.SlowThreshold {
+		logger.WithContext(ctx).Warnf("database: slow query took %s: %s", d, digest)
+	}
+	return err
+}
+
+func (m *Metrics) observe(digest string, d time.Duration, failed bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	h, ok := m.digest[digest]
+	if !ok {
+		if m.config.MaxDigests > 0 && len(m.digest) >= m.config.MaxDigests {
+			digest = OtherDigest
+			h = m.digest[digest]
+		}
+		if h == nil {
+			h = &histogram{counts: make([]int64, len(m.buckets))}
+			m.digest[digest] = h
+		}
+	}
+	h.count++
+	if failed {
+		h.errors++
+	}
+	h.total += d
+	if d > h.max {
+		h.max = d
+	}
+	for i, le := range m.buckets {
+		if d <= le {
+			h.counts[i]++
+		}
+	}
+}
+
+// Stats 返回所有语句的统计数据，按照总执行时间从大到小排列。
+func (m *Metrics) Stats() []QueryStats {
+	m.mutex.Lock()
+	ret := make([]QueryStats, 0, len(m.digest))
+	for digest, h := range m.digest {
+		s := QueryStats{
+			Digest: digest,
+			Count:  h.count,
+			Errors: h.errors,
+			Total:  h.total,
+			Max:    h.max,
+		}
+		for i, le := range m.buckets {
+			s.Buckets = append(s.Buckets, Bucket{Le: le, Count: h.counts[i]})
+		}
+		ret = append(ret, s)
+	}
+	m.mutex.Unlock()
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Total != ret[j].Total {
+			return ret[i].Total > ret[j].Total
+		}
+		return ret[i].Digest < ret[j].Digest
+	})
+	return ret
+}
+
+// Reset 清空所有的统计数据。
+func (m *Metrics) Reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.digest = make(map[string]*histogram)
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/database"
+)
+
+func TestDigest(t *testing.T) {
+	for query, expect := range map[string]string{
+		"SELECT * FROM users WHERE id = 1":                             "SELECT * FROM users WHERE id = ?",
+		"select name\n\tfrom  users where name = 'it''s' -- comment\n": "select name from users where name = ?",
+		"SELECT /* hint */ a FROM t2 WHERE b = $1 AND c = 'x\\'y'":     "SELECT a FROM t2 WHERE b = ? AND c = ?",
+		"SELECT * FROM users WHERE id IN (1, 2, 3)":                    "SELECT * FROM users WHERE id IN (...)",
+		"SELECT * FROM users WHERE id IN (?,?)":                        "SELECT * FROM users WHERE id IN (...)",
+		"INSERT INTO t (a,b) VALUES (?,?),(?,?),(?,?)":                 "INSERT INTO t (a,b) VALUES (...)",
+		"INSERT INTO t (a) VALUES (1), (2)":                            "INSERT INTO t (a) VALUES (...)",
+		"SELECT price * 1.5 FROM items LIMIT 10 OFFSET 20":             "SELECT price * ? FROM items LIMIT ? OFFSET ?",
+	} {
+		assert.Equal(t, database.Digest(query), expect)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	m := database.NewMetrics(database.MetricsConfig{MaxDigests: 2})
+	ctx := context.Background()
+
+	fail := errors.New("fail")
+	run := func(query string, d time.Duration, err error) {
+		_ = m.Intercept(ctx, &database.Statement{Query: query}, func(ctx context.Context, s *database.Statement) error {
+			time.Sleep(d)
+			return err
+		})
+	}
+	run("SELECT * FROM users WHERE id = 1", 0, nil)
+	run("SELECT * FROM users WHERE id = 2", 30*time.Millisecond, fail)
+	run("SELECT * FROM users WHERE id = 3", 0, sql.ErrNoRows)
+	run("DELETE FROM users", 0, nil)
+	run("UPDATE users SET name = 'a'", 0, nil)
+
+	stats := m.Stats()
+	assert.Equal(t, len(stats), 3)
+	s := stats[0]
+	assert.Equal(t, s.Digest, "SELECT * FROM users WHERE id = ?")
+	assert.Equal(t, s.Count, int64(3))
+	assert.Equal(t, s.Errors, int64(1))
+	assert.True(t, s.Max >= 30*time.Millisecond)
+	assert.Equal(t, len(s.Buckets), len(database.DefaultBuckets))
+	assert.Equal(t, s.Buckets[len(s.Buckets)-1].Count, int64(3))
+	assert.True(t, s.Buckets[0].Count < 3)
+
+	digests := []string{stats[1].Digest, stats[2].Digest}
+	assert.True(t, digests[0] == database.OtherDigest || digests[1] == database.OtherDigest)
+
+	m.Reset()
+	assert.Equal(t, len(m.Stats()), 0)
+}
+
+func TestIntercept(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+
+	var queries []string
+	audit := database.InterceptorFunc(func(ctx context.Context, s *database.Statement, next database.Invoker) error {
+		queries = append(queries, s.Query)
+		return next(ctx, s)
+	})
+	rewrite := database.InterceptorFunc(func(ctx context.Context, s *database.Statement, next database.Invoker) error {
+		s.Query += " /* app */"
+		return next(ctx, s)
+	})
+	conn := database.Intercept(db, audit, rewrite)
+	ctx := context.Background()
+
+	mock.ExpectExec("UPDATE users SET name = \\? /\\* app \\*/").WithArgs("jim").WillReturnResult(sqlmock.NewResult(0, 1))
+	result, err := conn.ExecContext(ctx, "UPDATE users SET name = ?", "jim")
+	assert.Nil(t, err)
+	n, _ := result.RowsAffected()
+	assert.Equal(t, n, int64(1))
+
+	mock.ExpectBegin()
+	tx, err := conn.BeginTx(ctx, nil)
+	assert.Nil(t, err)
+	mock.ExpectQuery("SELECT name FROM users /\\* app \\*/").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("jim"))
+	rows, err := tx.QueryContext(ctx, "SELECT name FROM users")
+	assert.Nil(t, err)
+	assert.Nil(t, rows.Close())
+	mock.ExpectCommit()
+	assert.Nil(t, tx.Commit())
+
+	assert.Equal(t, queries, []string{"UPDATE users SET name = ?", "SELECT name FROM users"})
+	sqlDB, err := conn.GetDBConn()
+	assert.Nil(t, err)
+	assert.Equal(t, sqlDB, db)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
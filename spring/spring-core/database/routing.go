@@ -0,0 +1,322 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+)
+
+var logger = log.GetLogger("GS_DATABASE")
+
+// ReplicaConfig 只读副本的配置，通常配合 db.replicas 前缀一起使用。
+type ReplicaConfig struct {
+	Urls          []string      `value:"${urls:=}"`
+	MaxOpenConns  int           `value:"${max-open-conns:=0}"`  // 每个副本的最大连接数，为 0 时不限制
+	MaxIdleConns  int           `value:"${max-idle-conns:=2}"`  // 每个副本的最大空闲连接数
+	MaxLag        time.Duration `value:"${max-lag:=0}"`         // 复制延迟超过该值的副本不再接收读请求，为 0 时不检查延迟
+	LagQuery      string        `value:"${lag-query:=}"`        // 查询复制延迟秒数的语句，为空时使用数据库默认的方式
+	CheckInterval time.Duration `value:"${check-interval:=5s}"` // 检查副本状态的间隔
+}
+
+// LagFunc 返回副本的复制延迟。
+type LagFunc func(ctx context.Context, db *sql.DB) (time.Duration, error)
+
+// QueryLag 返回执行 query 查询复制延迟的 LagFunc ，query 返回延迟的秒数，例如
+// PostgreSQL 可以使用:
+//
+//	SELECT EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+func QueryLag(query string) LagFunc {
+	return func(ctx context.Context, db *sql.DB) (time.Duration, error) {
+		var seconds sql.NullFloat64
+		if err := db.QueryRowContext(ctx, query).Scan(&seconds); err != nil {
+			return 0, err
+		}
+		if !seconds.Valid {
+			return 0, errors.New("replication is not running")
+		}
+		return time.Duration(seconds.Float64 * float64(time.Second)), nil
+	}
+}
+
+// MySQLLag 通过 SHOW SLAVE STATUS 查询 MySQL 副本的复制延迟。
+func MySQLLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	rows, err := db.QueryContext(ctx, "SHOW SLAVE STATUS")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return 0, err
+		}
+		return 0, errors.New("not a replica")
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err = rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+	for i, c := range columns {
+		if c != "Seconds_Behind_Master" && c != "Seconds_Behind_Source" {
+			continue
+		}
+		if values[i] == nil {
+			return 0, errors.New("replication is not running")
+		}
+		seconds, err := strconv.ParseFloat(string(values[i]), 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return 0, errors.New("no Seconds_Behind_Master column")
+}
+
+type primaryKey struct{}
+
+// UsePrimary 返回读请求也使用主库的上下文，用于需要读到刚刚写入的数据的场景。
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// usePrimary 返回上下文是否要求使用主库。
+func usePrimary(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryKey{}).(bool)
+	return v
+}
+
+// IsReadQuery 返回 query 是否是可以在副本上执行的只读查询，不能确定时返回 false 。
+func IsReadQuery(query string) bool {
+	s := strings.TrimLeft(query, " \t\r\n(")
+	if len(s) < 6 || !strings.EqualFold(s[:6], "SELECT") {
+		return false
+	}
+	s = strings.ToUpper(s)
+	return !strings.Contains(s, " FOR UPDATE") &&
+		!strings.Contains(s, " FOR SHARE") &&
+		!strings.Contains(s, " LOCK IN SHARE MODE")
+}
+
+type replica struct {
+	db      *sql.DB
+	healthy int32
+}
+
+// RoutingDB 读写分离的数据库，写操作和事务使用主库，只读查询轮流使用状态正常的
+// 副本，所有副本都不可用时使用主库。RoutingDB 实现了 gorm.ConnPool 接口，可以
+// 作为 gorm 的连接池使用。
+type RoutingDB struct {
+	primary  *sql.DB
+	replicas []*replica
+	config   ReplicaConfig
+	lag      LagFunc
+	next     uint32
+
+	once sync.Once
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewRoutingDB 创建 RoutingDB ，lag 为 nil 或者 config.MaxLag 为 0 时只检查副本
+// 是否可以连接。创建时检查一次副本的状态，之后在后台定时检查。
+func NewRoutingDB(primary *sql.DB, replicas []*sql.DB, config ReplicaConfig, lag LagFunc) *RoutingDB {
+	r := &RoutingDB{
+		primary: primary,
+		config:  config,
+		lag:     lag,
+		stop:    make(chan struct{}),
+	}
+	for _, db := range replicas {
+		r.replicas = append(r.replicas, &replica{db: db, healthy: 1})
+	}
+	r.check()
+	if len(r.replicas) > 0 && config.CheckInterval > 0 {
+		r.wg.Add(1)
+		go r.run()
+	}
+	return r
+}
+
+// OpenRoutingDB 使用 driverName 驱动打开主库和所有的副本。
+func OpenRoutingDB(driverName string, primary ClientConfig, replicas ReplicaConfig, lag LagFunc) (*RoutingDB, error) {
+	p, err := sql.Open(driverName, primary.Url)
+	if err != nil {
+		return nil, err
+	}
+	p.SetMaxOpenConns(primary.MaxOpenConns)
+	p.SetMaxIdleConns(primary.MaxIdleConns)
+	var dbs []*sql.DB
+	for _, url := range replicas.Urls {
+		db, err := sql.Open(driverName, url)
+		if err != nil {
+			for _, d := range dbs {
+				_ = d.Close()
+			}
+			_ = p.Close()
+			return nil, err
+		}
+		db.SetMaxOpenConns(replicas.MaxOpenConns)
+		db.SetMaxIdleConns(replicas.MaxIdleConns)
+		dbs = append(dbs, db)
+	}
+	return NewRoutingDB(p, dbs, replicas, lag), nil
+}
+
+func (r *RoutingDB) run() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.config.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.check()
+		}
+	}
+}
+
+// check 检查所有副本的状态。
+func (r *RoutingDB) check() {
+	timeout := r.config.CheckInterval
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	for i, s := range r.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := r.checkReplica(ctx, s.db)
+		cancel()
+		if err == nil {
+			if atomic.SwapInt32(&s.healthy, 1) == 0 {
+				logger.Infof("database: replica %d recovered", i)
+			}
+			continue
+		}
+		if atomic.SwapInt32(&s.healthy, 0) == 1 {
+			logger.Warnf("database: replica %d is unavailable: %v", i, err)
+		}
+	}
+}
+
+func (r *RoutingDB) checkReplica(ctx context.Context, db *sql.DB) error {
+	if err := db.PingContext(ctx); err != nil {
+		return err
+	}
+	if r.lag == nil || r.config.MaxLag <= 0 {
+		return nil
+	}
+	lag, err := r.lag(ctx, db)
+	if err != nil {
+		return err
+	}
+	if lag > r.config.MaxLag {
+		return fmt.Errorf("replication lag %s exceeds %s", lag, r.config.MaxLag)
+	}
+	return nil
+}
+
+// Primary 返回主库。
+func (r *RoutingDB) Primary() *sql.DB {
+	return r.primary
+}
+
+// Replica 返回执行只读查询的数据库，上下文要求使用主库或者没有可用的副本时返回主库。
+func (r *RoutingDB) Replica(ctx context.Context) *sql.DB {
+	if len(r.replicas) == 0 || usePrimary(ctx) {
+		return r.primary
+	}
+	n := atomic.AddUint32(&r.next, 1)
+	for i := range r.replicas {
+		s := r.replicas[(int(n)+i)%len(r.replicas)]
+		if atomic.LoadInt32(&s.healthy) == 1 {
+			return s.db
+		}
+	}
+	return r.primary
+}
+
+// route 根据语句选择数据库。
+func (r *RoutingDB) route(ctx context.Context, query string) *sql.DB {
+	if IsReadQuery(query) {
+		return r.Replica(ctx)
+	}
+	return r.primary
+}
+
+// PrepareContext 在主库上创建预编译语句。
+func (r *RoutingDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return r.primary.PrepareContext(ctx, query)
+}
+
+// ExecContext 在主库上执行语句。
+func (r *RoutingDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.primary.ExecContext(ctx, query, args...)
+}
+
+// QueryContext 执行查询，只读查询使用副本，其他语句使用主库。
+func (r *RoutingDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return r.route(ctx, query).QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext 执行查询，只读查询使用副本，其他语句使用主库。
+func (r *RoutingDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return r.route(ctx, query).QueryRowContext(ctx, query, args...)
+}
+
+// BeginTx 开启事务，事务中的所有语句都在同一个数据库上执行。只读事务使用副本，
+// 其他事务使用主库。
+func (r *RoutingDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	if opts != nil && opts.ReadOnly {
+		return r.Replica(ctx).BeginTx(ctx, opts)
+	}
+	return r.primary.BeginTx(ctx, opts)
+}
+
+// GetDBConn 返回主库，gorm 通过该方法获取 *sql.DB 。
+func (r *RoutingDB) GetDBConn() (*sql.DB, error) {
+	return r.primary, nil
+}
+
+// Close 停止检查副本的状态并关闭所有的数据库。
+func (r *RoutingDB) Close() error {
+	r.once.Do(func() { close(r.stop) })
+	r.wg.Wait()
+	err := r.primary.Close()
+	for _, s := range r.replicas {
+		if e := s.db.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
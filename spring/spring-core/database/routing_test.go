@@ -0,0 +1,142 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/database"
+)
+
+func TestIsReadQuery(t *testing.T) {
+	for query, expect := range map[string]bool{
+		"SELECT * FROM users":                      true,
+		"  select id from users":                   true,
+		"(SELECT 1) UNION (SELECT 2)":              true,
+		"SELECT * FROM users FOR UPDATE":           false,
+		"select * from users for share":            false,
+		"SELECT * FROM t LOCK IN SHARE MODE":       false,
+		"INSERT INTO users VALUES (1)":             false,
+		"WITH t AS (DELETE FROM users) SELECT 1":   false,
+		"UPDATE users SET name = 'select' WHERE 1": false,
+	} {
+		assert.Equal(t, database.IsReadQuery(query), expect, query)
+	}
+}
+
+func newMock(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	assert.Nil(t, err)
+	return db, mock
+}
+
+func TestRoutingDB(t *testing.T) {
+	primary, pmock := newMock(t)
+	replica, rmock := newMock(t)
+	rmock.ExpectPing()
+
+	r := database.NewRoutingDB(primary, []*sql.DB{replica}, database.ReplicaConfig{}, nil)
+	ctx := context.Background()
+
+	rmock.ExpectQuery("SELECT name FROM users").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("jim"))
+	var name string
+	assert.Nil(t, r.QueryRowContext(ctx, "SELECT name FROM users").Scan(&name))
+	assert.Equal(t, name, "jim")
+
+	pmock.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err := r.ExecContext(ctx, "UPDATE users SET name = ?", "tom")
+	assert.Nil(t, err)
+
+	pmock.ExpectQuery("SELECT name FROM users").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("tom"))
+	assert.Nil(t, r.QueryRowContext(database.UsePrimary(ctx), "SELECT name FROM users").Scan(&name))
+	assert.Equal(t, name, "tom")
+
+	pmock.ExpectBegin()
+	tx, err := r.BeginTx(ctx, nil)
+	assert.Nil(t, err)
+	pmock.ExpectRollback()
+	assert.Nil(t, tx.Rollback())
+
+	rmock.ExpectBegin()
+	tx, err = r.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	assert.Nil(t, err)
+	rmock.ExpectRollback()
+	assert.Nil(t, tx.Rollback())
+
+	db, err := r.GetDBConn()
+	assert.Nil(t, err)
+	assert.Equal(t, db, primary)
+
+	assert.Nil(t, pmock.ExpectationsWereMet())
+	assert.Nil(t, rmock.ExpectationsWereMet())
+}
+
+func TestRoutingDB_Fallback(t *testing.T) {
+	primary, _ := newMock(t)
+	down, dmock := newMock(t)
+	dmock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	lagging, lmock := newMock(t)
+	lmock.ExpectPing()
+
+	config := database.ReplicaConfig{MaxLag: time.Second}
+	lag := func(ctx context.Context, db *sql.DB) (time.Duration, error) {
+		return 10 * time.Second, nil
+	}
+	r := database.NewRoutingDB(primary, []*sql.DB{down, lagging}, config, lag)
+	assert.Equal(t, r.Replica(context.Background()), primary)
+
+	r = database.NewRoutingDB(primary, nil, config, lag)
+	assert.Equal(t, r.Replica(context.Background()), primary)
+}
+
+func TestQueryLag(t *testing.T) {
+	db, mock := newMock(t)
+
+	mock.ExpectQuery("SELECT lag").WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(1.5))
+	lag, err := database.QueryLag("SELECT lag")(context.Background(), db)
+	assert.Nil(t, err)
+	assert.Equal(t, lag, 1500*time.Millisecond)
+
+	mock.ExpectQuery("SELECT lag").WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(nil))
+	_, err = database.QueryLag("SELECT lag")(context.Background(), db)
+	assert.Error(t, err, "replication is not running")
+}
+
+func TestMySQLLag(t *testing.T) {
+	db, mock := newMock(t)
+
+	mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(
+		sqlmock.NewRows([]string{"Slave_IO_State", "Seconds_Behind_Master"}).AddRow("Waiting for master", "3"))
+	lag, err := database.MySQLLag(context.Background(), db)
+	assert.Nil(t, err)
+	assert.Equal(t, lag, 3*time.Second)
+
+	mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(
+		sqlmock.NewRows([]string{"Slave_IO_State", "Seconds_Behind_Master"}).AddRow("", nil))
+	_, err = database.MySQLLag(context.Background(), db)
+	assert.Error(t, err, "replication is not running")
+
+	mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(sqlmock.NewRows([]string{"Seconds_Behind_Master"}))
+	_, err = database.MySQLLag(context.Background(), db)
+	assert.Error(t, err, "not a replica")
+}
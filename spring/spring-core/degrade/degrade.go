@@ -0,0 +1,291 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package degrade 根据运行时指标自动降级。规则将路由的请求耗时 p99 或者错误率的阈值
+// 绑定到降级动作，例如关闭功能开关、改用缓存或者兜底的处理器响应路由的请求，后台定期
+// 检查规则，指标超过阈值时执行降级，经过恢复时间之后自动解除并重新判断，例如：
+//
+//	gs.Provide(degrade.New, "${degrade}").Export((*gs.AppEvent)(nil), (*web.Filter)(nil))
+package degrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/web"
+)
+
+var logger = log.GetLogger("GS_DEGRADE")
+
+// maxSamples 每个规则在一个检查周期内保留的耗时样本数量的上限，超过时随机替换。
+const maxSamples = 1024
+
+// Action 自定义的降级动作，规则触发时调用 Degrade ，解除降级时调用 Recover 。
+type Action interface {
+	Degrade(rule string)
+	Recover(rule string)
+}
+
+// RuleConfig 降级规则，P99 和 ErrorRate 至少需要配置一个，任意一个超过阈值时触发。
+type RuleConfig struct {
+	Name        string        `value:"${name}"`
+	Route       string        `value:"${route}"`            // 统计指标的路由，精确匹配或者以 /* 结尾按照前缀匹配
+	P99         time.Duration `value:"${p99:=0}"`           // 请求耗时的 p99 超过该值时触发，0 表示不检查
+	ErrorRate   float64       `value:"${error-rate:=0}"`    // 5xx 响应的比例超过该值时触发，0 表示不检查
+	MinRequests int           `value:"${min-requests:=20}"` // 检查周期内的请求少于该值时不做判断
+	Recovery    time.Duration `value:"${recovery:=1m}"`     // 降级持续该时间后自动解除
+	Features    []string      `value:"${features:=}"`       // 降级时关闭的功能开关
+	Fallback    string        `value:"${fallback:=}"`       // 降级时改用该名称注册的处理器响应路由的请求
+	Actions     []string      `value:"${actions:=}"`        // 降级和解除时执行的自定义动作
+}
+
+// Config 自动降级配置。
+type Config struct {
+	Interval time.Duration `value:"${interval:=10s}"` // 检查规则的间隔，也是统计指标的时间窗口
+	Rules    []RuleConfig  `value:"${rules:=}"`
+}
+
+// RuleStatus 规则最近一次检查的结果。
+type RuleStatus struct {
+	Name      string        `json:"name"`
+	Degraded  bool          `json:"degraded"`
+	Since     time.Time     `json:"since"` // 开始降级的时间
+	Requests  int           `json:"requests"`
+	P99       time.Duration `json:"p99"`
+	ErrorRate float64       `json:"error_rate"`
+}
+
+// metrics 一个检查周期内的请求指标。
+type metrics struct {
+	mutex    sync.Mutex
+	requests int
+	errors   int
+	samples  []time.Duration
+}
+
+func (m *metrics) add(d time.Duration, failed bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.requests++
+	if failed {
+		m.errors++
+	}
+	if len(m.samples) < maxSamples {
+		m.samples = append(m.samples, d)
+	} else if i := rand.Intn(m.requests); i < maxSamples {
+		m.samples[i] = d
+	}
+}
+
+// reset 返回当前周期的请求数量、p99 以及错误率，然后开始新的周期。
+func (m *metrics) reset() (requests int, p99 time.Duration, errorRate float64) {
+	m.mutex.Lock()
+	requests, errs, samples := m.requests, m.errors, m.samples
+	m.requests, m.errors, m.samples = 0, 0, nil
+	m.mutex.Unlock()
+	if requests == 0 {
+		return 0, 0, 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	p99 = samples[int(math.Ceil(float64(len(samples))*0.99))-1]
+	return requests, p99, float64(errs) / float64(requests)
+}
+
+type rule struct {
+	config  RuleConfig
+	metrics metrics
+	status  RuleStatus
+}
+
+// Degrader 根据规则自动降级，同时也是统计路由指标和切换兜底处理器的过滤器。
+type Degrader struct {
+	config    Config
+	rules     []*rule
+	mutex     sync.RWMutex
+	disabled  map[string]int // 功能开关被多少个规则关闭
+	fallbacks map[string]web.HandlerFunc
+	actions   map[string]Action
+	stop      context.CancelFunc
+	done      chan struct{}
+}
+
+// New 创建自动降级器。
+func New(config Config) (*Degrader, error) {
+	if config.Interval <= 0 {
+		return nil, errors.New("degrade: interval must be positive")
+	}
+	d := &Degrader{
+		config:    config,
+		disabled:  make(map[string]int),
+		fallbacks: make(map[string]web.HandlerFunc),
+		actions:   make(map[string]Action),
+	}
+	for _, c := range config.Rules {
+		if c.P99 <= 0 && c.ErrorRate <= 0 {
+			return nil, fmt.Errorf("degrade: rule %q needs p99 or error-rate", c.Name)
+		}
+		d.rules = append(d.rules, &rule{config: c, status: RuleStatus{Name: c.Name}})
+	}
+	return d, nil
+}
+
+// Fallback 注册降级时使用的处理器，例如返回缓存数据或者默认数据的处理器。
+func (d *Degrader) Fallback(name string, fn web.HandlerFunc) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.fallbacks[name] = fn
+}
+
+// Action 注册自定义的降级动作。
+func (d *Degrader) Action(name string, a Action) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.actions[name] = a
+}
+
+// Enabled 返回功能开关是否打开，被降级规则关闭的功能返回 false ，业务代码据此跳过
+// 非核心的功能，例如推荐或者统计。
+func (d *Degrader) Enabled(feature string) bool {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.disabled[feature] == 0
+}
+
+// Status 返回所有规则最近一次检查的结果。
+func (d *Degrader) Status() []RuleStatus {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	ret := make([]RuleStatus, 0, len(d.rules))
+	for _, r := range d.rules {
+		ret = append(ret, r.status)
+	}
+	return ret
+}
+
+// Evaluate 根据上一个检查周期的指标检查所有规则，通常由后台的 Run 定期调用。自定义
+// 的降级动作在释放锁之后执行，因此可以调用 Enabled 等方法。
+func (d *Degrader) Evaluate(now time.Time) {
+	var calls []func()
+	defer func() {
+		for _, fn := range calls {
+			fn()
+		}
+	}()
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, r := range d.rules {
+		requests, p99, errorRate := r.metrics.reset()
+		s := &r.status
+		if s.Degraded {
+			if now.Sub(s.Since) >= r.config.Recovery {
+				calls = append(calls, d.recover(r)...)
+			}
+			continue
+		}
+		s.Requests, s.P99, s.ErrorRate = requests, p99, errorRate
+		if requests == 0 || requests < r.config.MinRequests {
+			continue
+		}
+		c := r.config
+		if (c.P99 > 0 && p99 >= c.P99) || (c.ErrorRate > 0 && errorRate >= c.ErrorRate) {
+			calls = append(calls, d.degrade(r, now)...)
+		}
+	}
+}
+
+// degrade 将规则切换到降级状态，返回需要执行的自定义动作。
+func (d *Degrader) degrade(r *rule, now time.Time) (calls []func()) {
+	s := &r.status
+	s.Degraded, s.Since = true, now
+	logger.Warnf("degrade rule %q triggered: requests=%d p99=%s error-rate=%.3f",
+		r.config.Name, s.Requests, s.P99, s.ErrorRate)
+	for _, f := range r.config.Features {
+		d.disabled[f]++
+	}
+	if name := r.config.Fallback; name != "" && d.fallbacks[name] == nil {
+		logger.Errorf("degrade rule %q: fallback %q not found", r.config.Name, name)
+	}
+	for _, name := range r.config.Actions {
+		if a := d.actions[name]; a != nil {
+			calls = append(calls, func() { a.Degrade(r.config.Name) })
+		} else {
+			logger.Errorf("degrade rule %q: action %q not found", r.config.Name, name)
+		}
+	}
+	return calls
+}
+
+// recover 解除规则的降级状态，返回需要执行的自定义动作。
+func (d *Degrader) recover(r *rule) (calls []func()) {
+	r.status.Degraded, r.status.Since = false, time.Time{}
+	logger.Infof("degrade rule %q recovered", r.config.Name)
+	for _, f := range r.config.Features {
+		if d.disabled[f]--; d.disabled[f] <= 0 {
+			delete(d.disabled, f)
+		}
+	}
+	for _, name := range r.config.Actions {
+		if a := d.actions[name]; a != nil {
+			calls = append(calls, func() { a.Recover(r.config.Name) })
+		}
+	}
+	return calls
+}
+
+// Run 周期性地检查规则，直到 ctx 被取消。
+func (d *Degrader) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			d.Evaluate(now)
+		}
+	}
+}
+
+// OnAppStart 应用启动后在后台检查规则。
+func (d *Degrader) OnAppStart(ctx gs.Context) {
+	var c context.Context
+	c, d.stop = context.WithCancel(ctx.Context())
+	d.done = make(chan struct{})
+	go func() {
+		defer close(d.done)
+		d.Run(c)
+	}()
+}
+
+// OnAppStop 停止检查规则。
+func (d *Degrader) OnAppStop(ctx context.Context) {
+	if d.stop == nil {
+		return
+	}
+	d.stop()
+	select {
+	case <-d.done:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package degrade_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/degrade"
+	"github.com/go-spring/spring-core/web"
+)
+
+type action struct {
+	events  []string
+	d       *degrade.Degrader
+	enabled bool
+}
+
+func (a *action) Degrade(rule string) {
+	a.events = append(a.events, "degrade:"+rule)
+	a.enabled = a.d.Enabled("coupon")
+}
+
+func (a *action) Recover(rule string) { a.events = append(a.events, "recover:"+rule) }
+
+func serve(d *degrade.Degrader, path string, status int, delay time.Duration) *httptest.ResponseRecorder {
+	handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		time.Sleep(delay)
+		ctx.SetStatus(status)
+		ctx.String("origin")
+	})
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	w := httptest.NewRecorder()
+	ctx := web.NewBaseContext("", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+	web.NewFilterChain([]web.Filter{d, handler}).Next(ctx)
+	return w
+}
+
+func TestDegrader(t *testing.T) {
+
+	p, err := conf.Bytes([]byte(`
+		degrade.rules[0].name=slow-search
+		degrade.rules[0].route=/api/search
+		degrade.rules[0].p99=20ms
+		degrade.rules[0].min-requests=5
+		degrade.rules[0].features=recommend
+		degrade.rules[0].fallback=cached-search
+		degrade.rules[1].name=failing-orders
+		degrade.rules[1].route=/api/orders/*
+		degrade.rules[1].error-rate=0.5
+		degrade.rules[1].min-requests=4
+		degrade.rules[1].recovery=30s
+		degrade.rules[1].features=recommend,coupon
+		degrade.rules[1].actions=alert
+	`), ".properties")
+	assert.Nil(t, err)
+
+	var config degrade.Config
+	err = p.Bind(&config, conf.Key("degrade"))
+	assert.Nil(t, err)
+	assert.Equal(t, config.Interval, 10*time.Second)
+	assert.Equal(t, config.Rules[0].Recovery, time.Minute)
+
+	d, err := degrade.New(config)
+	assert.Nil(t, err)
+
+	a := &action{d: d, enabled: true}
+	d.Action("alert", a)
+	d.Fallback("cached-search", func(ctx web.Context) {
+		ctx.String("cached")
+	})
+
+	// 请求数量不足时不做判断。
+	for i := 0; i < 4; i++ {
+		serve(d, "/api/search", http.StatusOK, 25*time.Millisecond)
+	}
+	now := time.Now()
+	d.Evaluate(now)
+	assert.False(t, d.Status()[0].Degraded)
+	assert.Equal(t, d.Status()[0].Requests, 4)
+
+	for i := 0; i < 5; i++ {
+		serve(d, "/api/search", http.StatusOK, 25*time.Millisecond)
+	}
+	for i := 0; i < 4; i++ {
+		status := http.StatusOK
+		if i%2 == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		serve(d, "/api/orders/1", status, 0)
+	}
+	serve(d, "/health", http.StatusInternalServerError, 0)
+
+	d.Evaluate(now)
+	status := d.Status()
+	assert.True(t, status[0].Degraded)
+	assert.True(t, status[0].P99 >= 20*time.Millisecond)
+	assert.True(t, status[1].Degraded)
+	assert.Equal(t, status[1].Requests, 4)
+	assert.Equal(t, status[1].ErrorRate, 0.5)
+	assert.False(t, d.Enabled("recommend"))
+	assert.False(t, d.Enabled("coupon"))
+	assert.True(t, d.Enabled("other"))
+	assert.Equal(t, a.events, []string{"degrade:failing-orders"})
+	assert.False(t, a.enabled)
+
+	// 降级期间使用兜底处理器，没有兜底处理器的路由继续由原来的处理器响应。
+	w := serve(d, "/api/search", http.StatusOK, 0)
+	assert.Equal(t, w.Body.String(), "cached")
+	w = serve(d, "/api/orders/1", http.StatusOK, 0)
+	assert.Equal(t, w.Body.String(), "origin")
+
+	// 按照各自的恢复时间解除降级，功能开关在所有关闭它的规则解除之后才会打开。
+	d.Evaluate(now.Add(30 * time.Second))
+	assert.True(t, d.Status()[0].Degraded)
+	assert.False(t, d.Status()[1].Degraded)
+	assert.False(t, d.Enabled("recommend"))
+	assert.True(t, d.Enabled("coupon"))
+	assert.Equal(t, a.events, []string{"degrade:failing-orders", "recover:failing-orders"})
+
+	d.Evaluate(now.Add(time.Minute))
+	assert.False(t, d.Status()[0].Degraded)
+	assert.True(t, d.Enabled("recommend"))
+	w = serve(d, "/api/search", http.StatusOK, 0)
+	assert.Equal(t, w.Body.String(), "origin")
+
+	_, err = degrade.New(degrade.Config{Interval: time.Second, Rules: []degrade.RuleConfig{{Name: "x"}}})
+	assert.Error(t, err, "degrade: rule \"x\" needs p99 or error-rate")
+}
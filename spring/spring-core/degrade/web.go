@@ -0,0 +1,83 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package degrade
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-spring/spring-core/web"
+)
+
+// Invoke 统计匹配的规则的路由指标，规则处于降级状态并且配置了兜底处理器时使用兜底
+// 处理器响应请求。返回 5xx 状态码或者发生 panic 的请求计为失败。
+func (d *Degrader) Invoke(ctx web.Context, chain web.FilterChain) {
+
+	var matched []*rule
+	for _, r := range d.rules {
+		if matchRoute(ctx, r.config.Route) {
+			matched = append(matched, r)
+		}
+	}
+	if len(matched) == 0 {
+		chain.Continue(ctx)
+		return
+	}
+
+	if fn := d.fallback(matched); fn != nil {
+		fn(ctx)
+		return
+	}
+
+	start := time.Now()
+	failed := true
+	defer func() {
+		elapsed := time.Since(start)
+		for _, r := range matched {
+			r.metrics.add(elapsed, failed)
+		}
+	}()
+	chain.Next(ctx)
+	failed = ctx.ResponseWriter().Status() >= http.StatusInternalServerError
+}
+
+// fallback 返回处于降级状态的规则的兜底处理器。
+func (d *Degrader) fallback(matched []*rule) web.HandlerFunc {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	for _, r := range matched {
+		if r.status.Degraded && r.config.Fallback != "" {
+			if fn := d.fallbacks[r.config.Fallback]; fn != nil {
+				return fn
+			}
+		}
+	}
+	return nil
+}
+
+// matchRoute 判断请求是否属于规则的路由，匹配规则和 web.NewTimeoutFilter 相同。
+func matchRoute(ctx web.Context, route string) bool {
+	path := ctx.Request().URL.Path
+	if route == path || route == ctx.Path() {
+		return true
+	}
+	if strings.HasSuffix(route, "/*") {
+		return strings.HasPrefix(path, strings.TrimSuffix(route, "*"))
+	}
+	return false
+}
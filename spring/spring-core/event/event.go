@@ -0,0 +1,375 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package event 实现了进程内的事件总线。监听器可以同步处理事件，也可以使用有界队列
+// 异步处理事件，队列满时按照监听器的溢出策略阻塞发布者、丢弃事件或者交给死信处理器。
+//
+//	gs.Provide(event.NewBus, "*?", "?").Export((*gs.AppEvent)(nil))
+//	gs.Object(event.Listen("audit", func(ctx context.Context, e *OrderCreated) error {
+//		return audit.Save(ctx, e)
+//	}).Async(1000, 4, event.DeadLetter))
+//
+// 应用启动时事件总线会发布容器刷新过程中的装配决策，例如：
+//
+//	gs.Object(event.Listen("wiring-lint", func(ctx context.Context, e *gs.WiringEvent) error {
+//		return lint.Check(e)
+//	}))
+//
+// 事务性监听器在事务提交或者回滚之后才处理事务中发布的事件，例如：
+//
+//	gs.Object(event.Listen("notify", notify).Transactional(event.AfterCommit, false))
+//	err := event.RunInTx(ctx, db, nil, func(ctx context.Context, tx *sql.Tx) error {
+//		// 写入订单 ...
+//		return bus.Publish(ctx, &OrderCreated{ID: id})
+//	})
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-base/util"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/report"
+)
+
+var logger = log.GetLogger("GS_EVENT")
+
+var (
+	ErrQueueFull = errors.New("event queue is full")
+	ErrBusClosed = errors.New("event bus is closed")
+)
+
+// Overflow 异步监听器的队列满时的处理策略。
+type Overflow int
+
+const (
+	Block      Overflow = iota // 阻塞发布者直到队列有空位
+	Drop                       // 丢弃事件
+	DeadLetter                 // 将事件交给死信处理器
+)
+
+// DeadLetterHandler 处理因为队列满而没有投递的事件，以及异步处理失败的事件。
+type DeadLetterHandler interface {
+	HandleDeadLetter(ctx context.Context, listener string, e interface{}, err error)
+}
+
+// Listener 事件监听器。
+type Listener struct {
+	name        string
+	t           reflect.Type
+	fn          reflect.Value
+	async       bool
+	queueSize   int
+	concurrency int
+	overflow    Overflow
+	phase       Phase
+	fallback    bool
+}
+
+// Listen 创建同步的事件监听器，fn 的形式为 func(ctx context.Context, e T) error ，
+// 发布的事件可以赋值给 T 时被 fn 处理，T 可以是接口类型。
+func Listen(name string, fn interface{}) *Listener {
+	t := reflect.TypeOf(fn)
+	if !util.IsFuncType(t) || !util.ReturnOnlyError(t) || t.NumIn() != 2 || !util.IsContextType(t.In(0)) {
+		panic(errors.New("fn should be func(context.Context, T) error"))
+	}
+	return &Listener{name: name, t: t.In(1), fn: reflect.ValueOf(fn)}
+}
+
+// Async 使用 concurrency 个 worker 异步处理事件，同一类型的事件总是由同一个 worker
+// 按照发布的顺序处理。queueSize 是监听器的队列长度，平均分配给所有的 worker 。
+func (l *Listener) Async(queueSize, concurrency int, overflow Overflow) *Listener {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if queueSize < concurrency {
+		queueSize = concurrency
+	}
+	l.async = true
+	l.queueSize = queueSize
+	l.concurrency = concurrency
+	l.overflow = overflow
+	return l
+}
+
+func (l *Listener) call(ctx context.Context, e interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			report.Panic(report.WithTags(ctx, "event.listener", l.name), report.SourceEvent, r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	out := l.fn.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(e)})
+	err, _ = out[0].Interface().(error)
+	return err
+}
+
+// Stats 监听器的运行状态。
+type Stats struct {
+	Listener     string        `json:"listener"`
+	Queued       int           `json:"queued"`
+	Processed    int64         `json:"processed"`
+	Failed       int64         `json:"failed"`
+	Dropped      int64         `json:"dropped"`
+	DeadLettered int64         `json:"dead_lettered"`
+	Lag          time.Duration `json:"lag"` // 最近一个事件从发布到开始处理的时间
+	MaxLag       time.Duration `json:"max_lag"`
+}
+
+type envelope struct {
+	ctx  context.Context
+	e    interface{}
+	time time.Time
+}
+
+// subscriber 保存监听器的队列和统计数据，int64 的字段放在最前面以保证原子操作的对齐。
+type subscriber struct {
+	processed    int64
+	failed       int64
+	dropped      int64
+	deadLettered int64
+	lag          int64
+	maxLag       int64
+
+	*Listener
+	queues []chan envelope
+}
+
+func (s *subscriber) queue(e interface{}) chan envelope {
+	if len(s.queues) == 1 {
+		return s.queues[0]
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(reflect.TypeOf(e).String()))
+	return s.queues[h.Sum32()%uint32(len(s.queues))]
+}
+
+// Bus 事件总线。
+type Bus struct {
+	subscribers []*subscriber
+	deadLetter  DeadLetterHandler
+
+	mutex    sync.RWMutex
+	closed   bool
+	once     sync.Once
+	stopping chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewBus 创建事件总线并启动异步监听器的 worker ，deadLetter 可以为 nil 。
+func NewBus(listeners []*Listener, deadLetter DeadLetterHandler) *Bus {
+	b := &Bus{deadLetter: deadLetter, stopping: make(chan struct{})}
+	for _, l := range listeners {
+		s := &subscriber{Listener: l}
+		b.subscribers = append(b.subscribers, s)
+		if !l.async {
+			continue
+		}
+		size := (l.queueSize + l.concurrency - 1) / l.concurrency
+		for i := 0; i < l.concurrency; i++ {
+			q := make(chan envelope, size)
+			s.queues = append(s.queues, q)
+			b.wg.Add(1)
+			go b.work(s, q)
+		}
+	}
+	return b
+}
+
+// Publish 发布事件。同步监听器在当前 goroutine 中依次处理事件，返回第一个处理失败
+// 的错误；异步监听器只返回投递的错误。异步监听器使用 ctx 处理事件，需要在发布者
+// 返回之后继续处理的事件不应该使用会被取消的 ctx 。
+func (b *Bus) Publish(ctx context.Context, e interface{}) error {
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	if b.closed {
+		return ErrBusClosed
+	}
+
+	var ret error
+	t := reflect.TypeOf(e)
+	for _, s := range b.subscribers {
+		if t == nil || !t.AssignableTo(s.t) {
+			continue
+		}
+		if s.phase != 0 {
+			if tx := txFromContext(ctx); tx != nil && tx.add(b, s, ctx, e) {
+				continue
+			}
+			if !s.fallback {
+				continue
+			}
+		}
+		if err := b.deliver(ctx, s, e); err != nil && ret == nil {
+			ret = err
+		}
+	}
+	return ret
+}
+
+// deliver 将事件交给监听器处理，调用者需要持有读锁。
+func (b *Bus) deliver(ctx context.Context, s *subscriber, e interface{}) error {
+	var err error
+	if s.async {
+		err = b.enqueue(ctx, s, e)
+	} else if err = s.call(ctx, e); err != nil {
+		atomic.AddInt64(&s.failed, 1)
+	} else {
+		atomic.AddInt64(&s.processed, 1)
+	}
+	if err != nil {
+		return fmt.Errorf("listener %s: %w", s.name, err)
+	}
+	return nil
+}
+
+func (b *Bus) enqueue(ctx context.Context, s *subscriber, e interface{}) error {
+	q := s.queue(e)
+	v := envelope{ctx: ctx, e: e, time: time.Now()}
+	select {
+	case q <- v:
+		return nil
+	default:
+	}
+	switch s.overflow {
+	case Drop:
+		atomic.AddInt64(&s.dropped, 1)
+		logger.WithContext(ctx).Warnf("event queue of listener %s is full, %T dropped", s.name, e)
+		return nil
+	case DeadLetter:
+		b.handleDeadLetter(ctx, s, e, ErrQueueFull)
+		return nil
+	}
+	select {
+	case q <- v:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-b.stopping:
+		return ErrBusClosed
+	}
+}
+
+func (b *Bus) work(s *subscriber, q chan envelope) {
+	defer b.wg.Done()
+	for v := range q {
+		lag := int64(time.Since(v.time))
+		atomic.StoreInt64(&s.lag, lag)
+		for {
+			max := atomic.LoadInt64(&s.maxLag)
+			if lag <= max || atomic.CompareAndSwapInt64(&s.maxLag, max, lag) {
+				break
+			}
+		}
+		if err := s.call(v.ctx, v.e); err != nil {
+			atomic.AddInt64(&s.failed, 1)
+			b.handleDeadLetter(v.ctx, s, v.e, err)
+			continue
+		}
+		atomic.AddInt64(&s.processed, 1)
+	}
+}
+
+func (b *Bus) handleDeadLetter(ctx context.Context, s *subscriber, e interface{}, err error) {
+	if b.deadLetter == nil {
+		logger.WithContext(ctx).Errorf(log.ERROR, "listener %s handle %T error: %v", s.name, e, err)
+		return
+	}
+	atomic.AddInt64(&s.deadLettered, 1)
+	b.deadLetter.HandleDeadLetter(ctx, s.name, e, err)
+}
+
+// Stats 返回所有监听器的运行状态。
+func (b *Bus) Stats() []Stats {
+	var ret []Stats
+	for _, s := range b.subscribers {
+		st := Stats{
+			Listener:     s.name,
+			Processed:    atomic.LoadInt64(&s.processed),
+			Failed:       atomic.LoadInt64(&s.failed),
+			Dropped:      atomic.LoadInt64(&s.dropped),
+			DeadLettered: atomic.LoadInt64(&s.deadLettered),
+			Lag:          time.Duration(atomic.LoadInt64(&s.lag)),
+			MaxLag:       time.Duration(atomic.LoadInt64(&s.maxLag)),
+		}
+		for _, q := range s.queues {
+			st.Queued += len(q)
+		}
+		ret = append(ret, st)
+	}
+	return ret
+}
+
+// Close 关闭事件总线，不再接受新的事件，然后等待队列中的事件处理完成。
+func (b *Bus) Close(ctx context.Context) error {
+
+	b.once.Do(func() {
+		// 先唤醒阻塞的发布者，然后才能获得写锁。
+		close(b.stopping)
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		b.closed = true
+		for _, s := range b.subscribers {
+			for _, q := range s.queues {
+				close(q)
+			}
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// OnAppStart 发布容器刷新过程中的装配决策 (*gs.WiringEvent) ，监听这些事件可以
+// 集中检查 bean 被排除、覆盖或者使用默认实现的情况。
+func (b *Bus) OnAppStart(ctx gs.Context) {
+	r, err := ctx.Report()
+	if err != nil {
+		return // 容器的临时数据已经被清理
+	}
+	for i := range r.Events {
+		if err = b.Publish(ctx.Context(), &r.Events[i]); err != nil {
+			logger.WithContext(ctx.Context()).Error(log.ERROR, err)
+		}
+	}
+}
+
+// OnAppStop 关闭事件总线。
+func (b *Bus) OnAppStop(ctx context.Context) {
+	if err := b.Close(ctx); err != nil {
+		logger.WithContext(ctx).Error(log.ERROR, err)
+	}
+}
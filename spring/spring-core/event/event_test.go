@@ -0,0 +1,228 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/event"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
+)
+
+type Named interface {
+	Name() string
+}
+
+type OrderCreated struct {
+	ID int
+}
+
+func (e *OrderCreated) Name() string { return "order.created" }
+
+type OrderPaid struct {
+	ID int
+}
+
+func (e *OrderPaid) Name() string { return "order.paid" }
+
+type deadLetters struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (d *deadLetters) HandleDeadLetter(ctx context.Context, listener string, e interface{}, err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.events = append(d.events, listener+":"+e.(Named).Name()+":"+err.Error())
+}
+
+func (d *deadLetters) get() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]string(nil), d.events...)
+}
+
+func TestBus_Sync(t *testing.T) {
+
+	var names []string
+	b := event.NewBus([]*event.Listener{
+		event.Listen("created", func(ctx context.Context, e *OrderCreated) error {
+			if e.ID < 0 {
+				return errors.New("invalid id")
+			}
+			return nil
+		}),
+		event.Listen("all", func(ctx context.Context, e Named) error {
+			names = append(names, e.Name())
+			return nil
+		}),
+		event.Listen("panic", func(ctx context.Context, e *OrderPaid) error {
+			panic("boom")
+		}),
+	}, nil)
+	defer b.Close(context.Background())
+
+	assert.Nil(t, b.Publish(context.Background(), &OrderCreated{ID: 1}))
+	assert.Error(t, b.Publish(context.Background(), &OrderCreated{ID: -1}), "listener created: invalid id")
+	assert.Error(t, b.Publish(context.Background(), &OrderPaid{ID: 1}), "listener panic: panic: boom")
+	assert.Nil(t, b.Publish(context.Background(), "ignored"))
+	assert.Equal(t, names, []string{"order.created", "order.created", "order.paid"})
+
+	stats := b.Stats()
+	assert.Equal(t, stats[0].Processed, int64(1))
+	assert.Equal(t, stats[0].Failed, int64(1))
+	assert.Equal(t, stats[1].Processed, int64(3))
+	assert.Equal(t, stats[2].Failed, int64(1))
+}
+
+func TestBus_Async(t *testing.T) {
+
+	var (
+		mutex   sync.Mutex
+		created []int
+		paid    []int
+	)
+
+	b := event.NewBus([]*event.Listener{
+		event.Listen("orders", func(ctx context.Context, e Named) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			switch v := e.(type) {
+			case *OrderCreated:
+				created = append(created, v.ID)
+			case *OrderPaid:
+				paid = append(paid, v.ID)
+			}
+			return nil
+		}).Async(100, 4, event.Block),
+	}, nil)
+
+	for i := 0; i < 50; i++ {
+		assert.Nil(t, b.Publish(context.Background(), &OrderCreated{ID: i}))
+		assert.Nil(t, b.Publish(context.Background(), &OrderPaid{ID: i}))
+	}
+	assert.Nil(t, b.Close(context.Background()))
+	assert.Equal(t, b.Publish(context.Background(), &OrderPaid{}), event.ErrBusClosed)
+
+	// 同一类型的事件按照发布的顺序处理。
+	for i := 0; i < 50; i++ {
+		assert.Equal(t, created[i], i)
+		assert.Equal(t, paid[i], i)
+	}
+	assert.Equal(t, b.Stats()[0].Processed, int64(100))
+}
+
+func TestBus_Overflow(t *testing.T) {
+
+	// newBus 创建队列长度为 1 的监听器，第一个事件开始处理之后才返回，这时第二个
+	// 事件会进入队列，之后的事件触发溢出策略。
+	newBus := func(overflow event.Overflow, d event.DeadLetterHandler) (*event.Bus, chan struct{}) {
+		started := make(chan struct{}, 10)
+		release := make(chan struct{})
+		b := event.NewBus([]*event.Listener{
+			event.Listen("slow", func(ctx context.Context, e *OrderCreated) error {
+				started <- struct{}{}
+				<-release
+				if e.ID == 2 {
+					return errors.New("failed")
+				}
+				return nil
+			}).Async(1, 1, overflow),
+		}, d)
+		assert.Nil(t, b.Publish(context.Background(), &OrderCreated{ID: 1}))
+		<-started
+		assert.Nil(t, b.Publish(context.Background(), &OrderCreated{ID: 2}))
+		return b, release
+	}
+
+	t.Run("drop", func(t *testing.T) {
+		b, release := newBus(event.Drop, nil)
+		assert.Nil(t, b.Publish(context.Background(), &OrderCreated{ID: 3}))
+		close(release)
+		assert.Nil(t, b.Close(context.Background()))
+		stats := b.Stats()[0]
+		assert.Equal(t, stats.Dropped, int64(1))
+		assert.Equal(t, stats.Processed, int64(1))
+		assert.Equal(t, stats.Failed, int64(1))
+	})
+
+	t.Run("dead-letter", func(t *testing.T) {
+		d := &deadLetters{}
+		b, release := newBus(event.DeadLetter, d)
+		assert.Nil(t, b.Publish(context.Background(), &OrderCreated{ID: 3}))
+		close(release)
+		assert.Nil(t, b.Close(context.Background()))
+		assert.Equal(t, d.get(), []string{
+			"slow:order.created:event queue is full",
+			"slow:order.created:failed",
+		})
+		assert.Equal(t, b.Stats()[0].DeadLettered, int64(2))
+	})
+
+	t.Run("block", func(t *testing.T) {
+		b, release := newBus(event.Block, nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.True(t, errors.Is(b.Publish(ctx, &OrderCreated{ID: 3}), context.DeadlineExceeded))
+
+		// 关闭事件总线时唤醒阻塞的发布者。
+		errCh := make(chan error)
+		go func() {
+			errCh <- b.Publish(context.Background(), &OrderCreated{ID: 4})
+		}()
+		time.Sleep(10 * time.Millisecond)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+		}()
+		assert.Nil(t, b.Close(context.Background()))
+		assert.True(t, errors.Is(<-errCh, event.ErrBusClosed))
+		assert.True(t, b.Stats()[0].MaxLag > 0)
+	})
+}
+
+func TestBus_WiringEvents(t *testing.T) {
+
+	type Dao struct{}
+
+	var events []*gs.WiringEvent
+	c := gs.New()
+	c.Object(&Dao{}).Name("mock-dao").On(cond.OnProperty("mock"))
+	c.Provide(event.NewBus, "*?", "?")
+	c.Object(event.Listen("wiring", func(ctx context.Context, e *gs.WiringEvent) error {
+		events = append(events, e)
+		return nil
+	}))
+	err := c.Refresh(gs.AutoClear(false))
+	assert.Nil(t, err)
+
+	var b *event.Bus
+	ctx := c.(gs.Context)
+	assert.Nil(t, ctx.Get(&b))
+	b.OnAppStart(ctx)
+	b.OnAppStop(context.Background())
+
+	assert.Equal(t, len(events), 1)
+	assert.Equal(t, events[0].Kind, gs.WiringExcluded)
+	assert.Equal(t, events[0].Reason, "condition not matched")
+}
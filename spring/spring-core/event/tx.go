@@ -0,0 +1,183 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"github.com/go-spring/spring-base/log"
+)
+
+// Phase 事务性监听器处理事件的阶段。
+type Phase int
+
+const (
+	BeforeCommit    Phase = iota + 1 // 提交之前，处理失败时事务会被回滚
+	AfterCommit                      // 提交之后
+	AfterRollback                    // 回滚之后，可以用于补偿
+	AfterCompletion                  // 提交或者回滚之后
+)
+
+// Transactional 将监听器设置为事务性监听器，事务中发布的事件先被缓存起来，到达 phase
+// 时才被处理。事务之外发布的事件只有在 fallback 为 true 时才会被立即处理。
+func (l *Listener) Transactional(phase Phase, fallback bool) *Listener {
+	l.phase = phase
+	l.fallback = fallback
+	return l
+}
+
+type txEvent struct {
+	b   *Bus
+	s   *subscriber
+	ctx context.Context
+	e   interface{}
+}
+
+// Tx 事务中发布的事件，由事务的管理者在提交之前调用 BeforeCommit ，在提交或者回滚
+// 之后调用 Complete 。
+type Tx struct {
+	mutex  sync.Mutex
+	events []txEvent
+	done   bool
+}
+
+type txKey struct{}
+
+// BeginTx 开始缓存事件，返回的 ctx 需要传递给事务中的 Publish 调用。
+func BeginTx(ctx context.Context) (context.Context, *Tx) {
+	tx := new(Tx)
+	return context.WithValue(ctx, txKey{}, tx), tx
+}
+
+func txFromContext(ctx context.Context) *Tx {
+	tx, _ := ctx.Value(txKey{}).(*Tx)
+	return tx
+}
+
+// add 缓存事件，事务已经结束时返回 false 。
+func (tx *Tx) add(b *Bus, s *subscriber, ctx context.Context, e interface{}) bool {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	if tx.done {
+		return false
+	}
+	tx.events = append(tx.events, txEvent{b: b, s: s, ctx: ctx, e: e})
+	return true
+}
+
+// take 取出第 i 个及之后的事件，处理事件的过程中可能会发布新的事件。
+func (tx *Tx) take(i int, done bool) []txEvent {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	if done && i >= len(tx.events) {
+		tx.done = true
+	}
+	if i >= len(tx.events) {
+		return nil
+	}
+	return tx.events[i:]
+}
+
+// BeforeCommit 处理 BeforeCommit 阶段的事件，返回第一个处理失败的错误，此时应该
+// 回滚事务。
+func (tx *Tx) BeforeCommit() error {
+	for i := 0; ; {
+		events := tx.take(i, false)
+		if len(events) == 0 {
+			return nil
+		}
+		for _, v := range events {
+			i++
+			if v.s.phase != BeforeCommit {
+				continue
+			}
+			if err := v.b.deliverTx(v); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Complete 在事务提交或者回滚之后处理对应阶段的事件，处理失败的事件交给死信处理器。
+// 此后发布的事件不再属于该事务。
+func (tx *Tx) Complete(committed bool) {
+	for i := 0; ; {
+		events := tx.take(i, true)
+		if len(events) == 0 {
+			return
+		}
+		for _, v := range events {
+			i++
+			switch v.s.phase {
+			case AfterCompletion:
+			case AfterCommit:
+				if !committed {
+					continue
+				}
+			case AfterRollback:
+				if committed {
+					continue
+				}
+			default:
+				continue
+			}
+			if err := v.b.deliverTx(v); err != nil {
+				v.b.handleDeadLetter(v.ctx, v.s, v.e, err)
+			}
+		}
+	}
+}
+
+func (b *Bus) deliverTx(v txEvent) error {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	if b.closed {
+		return ErrBusClosed
+	}
+	return b.deliver(v.ctx, v.s, v.e)
+}
+
+// RunInTx 在数据库事务中执行 fn ，fn 中使用 ctx 发布的事务性事件在事务提交或者回滚
+// 的对应阶段被处理。fn 返回错误或者 BeforeCommit 阶段处理失败时事务被回滚。
+func RunInTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(ctx context.Context, tx *sql.Tx) error) error {
+
+	sqlTx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, tx := BeginTx(ctx)
+	if err = fn(ctx, sqlTx); err == nil {
+		if err = tx.BeforeCommit(); err == nil {
+			if err = sqlTx.Commit(); err == nil {
+				tx.Complete(true)
+				return nil
+			}
+			tx.Complete(false)
+			return err
+		}
+	}
+
+	if e := sqlTx.Rollback(); e != nil && !errors.Is(e, sql.ErrTxDone) {
+		logger.WithContext(ctx).Error(log.ERROR, e)
+	}
+	tx.Complete(false)
+	return err
+}
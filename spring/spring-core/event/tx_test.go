@@ -0,0 +1,127 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/event"
+)
+
+// newTxBus 创建各个阶段的事务性监听器，返回的切片记录事件的处理顺序。
+func newTxBus(beforeCommitErr error) (*event.Bus, *[]string) {
+	var calls []string
+	record := func(name string, err error) func(ctx context.Context, e *OrderCreated) error {
+		return func(ctx context.Context, e *OrderCreated) error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+	b := event.NewBus([]*event.Listener{
+		event.Listen("now", record("now", nil)),
+		event.Listen("before-commit", record("before-commit", beforeCommitErr)).Transactional(event.BeforeCommit, false),
+		event.Listen("after-commit", record("after-commit", nil)).Transactional(event.AfterCommit, false),
+		event.Listen("after-rollback", record("after-rollback", nil)).Transactional(event.AfterRollback, false),
+		event.Listen("after-completion", record("after-completion", nil)).Transactional(event.AfterCompletion, true),
+	}, nil)
+	return b, &calls
+}
+
+func TestRunInTx(t *testing.T) {
+
+	t.Run("commit", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.Nil(t, err)
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+
+		b, calls := newTxBus(nil)
+		defer b.Close(context.Background())
+		err = event.RunInTx(context.Background(), db, nil, func(ctx context.Context, tx *sql.Tx) error {
+			if err := b.Publish(ctx, &OrderCreated{ID: 1}); err != nil {
+				return err
+			}
+			*calls = append(*calls, "fn")
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Equal(t, *calls, []string{"now", "fn", "before-commit", "after-commit", "after-completion"})
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.Nil(t, err)
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		b, calls := newTxBus(nil)
+		defer b.Close(context.Background())
+		err = event.RunInTx(context.Background(), db, nil, func(ctx context.Context, tx *sql.Tx) error {
+			_ = b.Publish(ctx, &OrderCreated{ID: 1})
+			return errors.New("insufficient stock")
+		})
+		assert.Error(t, err, "insufficient stock")
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Equal(t, *calls, []string{"now", "after-rollback", "after-completion"})
+	})
+
+	t.Run("before commit error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.Nil(t, err)
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		b, calls := newTxBus(errors.New("validation failed"))
+		defer b.Close(context.Background())
+		err = event.RunInTx(context.Background(), db, nil, func(ctx context.Context, tx *sql.Tx) error {
+			return b.Publish(ctx, &OrderCreated{ID: 1})
+		})
+		assert.Error(t, err, "listener before-commit: validation failed")
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Equal(t, *calls, []string{"now", "before-commit", "after-rollback", "after-completion"})
+	})
+
+	t.Run("no transaction", func(t *testing.T) {
+		b, calls := newTxBus(nil)
+		defer b.Close(context.Background())
+		assert.Nil(t, b.Publish(context.Background(), &OrderCreated{ID: 1}))
+		assert.Equal(t, *calls, []string{"now", "after-completion"})
+	})
+}
+
+func TestTx(t *testing.T) {
+	b, calls := newTxBus(nil)
+	defer b.Close(context.Background())
+
+	ctx, tx := event.BeginTx(context.Background())
+	assert.Nil(t, b.Publish(ctx, &OrderCreated{ID: 1}))
+	assert.Nil(t, tx.BeforeCommit())
+	tx.Complete(true)
+
+	// 事务结束之后发布的事件不再被缓存。
+	assert.Nil(t, b.Publish(ctx, &OrderCreated{ID: 2}))
+	assert.Equal(t, *calls, []string{"now", "before-commit", "after-commit", "after-completion", "now", "after-completion"})
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventstore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/event"
+)
+
+// Aggregate 使用事件溯源的聚合，通常嵌入 AggregateRoot 并实现 Apply 方法，例如：
+//
+//	type Order struct {
+//		eventstore.AggregateRoot
+//		Paid bool
+//	}
+//
+//	func (o *Order) Apply(e eventstore.Event) error {
+//		switch e.(type) {
+//		case *OrderPaid:
+//			o.Paid = true
+//		}
+//		return nil
+//	}
+//
+//	func (o *Order) Pay(amount int) error {
+//		if o.Paid {
+//			return errors.New("order is paid")
+//		}
+//		return eventstore.Raise(o, &OrderPaid{Amount: amount})
+//	}
+type Aggregate interface {
+	Root() *AggregateRoot
+
+	// Apply 根据事件修改聚合的状态，重建状态和产生新的事件时都会被调用，不应该有
+	// 副作用。
+	Apply(e Event) error
+}
+
+// AggregateRoot 保存聚合的 ID 、版本和尚未保存的事件。
+type AggregateRoot struct {
+	id      string
+	version int64
+	changes []Event
+}
+
+// Root 返回 AggregateRoot 本身，嵌入之后聚合自动实现 Aggregate 的 Root 方法。
+func (r *AggregateRoot) Root() *AggregateRoot {
+	return r
+}
+
+// ID 返回聚合的 ID ，也就是事件流的 ID 。
+func (r *AggregateRoot) ID() string {
+	return r.id
+}
+
+// SetID 设置新建的聚合的 ID 。
+func (r *AggregateRoot) SetID(id string) {
+	r.id = id
+}
+
+// Version 返回聚合已经保存的版本。
+func (r *AggregateRoot) Version() int64 {
+	return r.version
+}
+
+// Changes 返回尚未保存的事件。
+func (r *AggregateRoot) Changes() []Event {
+	return r.changes
+}
+
+// Raise 应用新的事件并将其记录为尚未保存的事件。
+func Raise(a Aggregate, e Event) error {
+	if err := a.Apply(e); err != nil {
+		return err
+	}
+	root := a.Root()
+	root.changes = append(root.changes, e)
+	return nil
+}
+
+// Rehydrate 依次应用已经保存的事件重建聚合的状态。
+func Rehydrate(a Aggregate, events []*RecordedEvent) error {
+	root := a.Root()
+	for _, r := range events {
+		e, err := r.Decode()
+		if err != nil {
+			return err
+		}
+		if err = a.Apply(e); err != nil {
+			return err
+		}
+		if root.id == "" {
+			root.id = r.StreamID
+		}
+		root.version = r.Version
+	}
+	return nil
+}
+
+// Repository 加载和保存聚合。
+type Repository struct {
+	store Store
+	bus   *event.Bus
+}
+
+// NewRepository 创建 Repository ，bus 不为 nil 时将保存成功的事件发布到事件总线。
+func NewRepository(store Store, bus *event.Bus) *Repository {
+	return &Repository{store: store, bus: bus}
+}
+
+// Load 读取聚合的事件流并重建状态，事件流不存在时返回 ErrStreamNotFound 。
+func (r *Repository) Load(ctx context.Context, id string, a Aggregate) error {
+	events, err := r.store.ReadStream(ctx, id, 0)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return ErrStreamNotFound
+	}
+	a.Root().id = id
+	return Rehydrate(a, events)
+}
+
+// Save 保存聚合尚未保存的事件，其他请求已经修改了聚合时返回 ErrWrongVersion 。事件
+// 保存之后才会被发布到事件总线，发布失败只记录日志，需要可靠处理的事件应该使用订阅。
+func (r *Repository) Save(ctx context.Context, a Aggregate) error {
+	root := a.Root()
+	if len(root.changes) == 0 {
+		return nil
+	}
+	if root.id == "" {
+		return errors.New("eventstore: aggregate id is empty")
+	}
+	version, err := r.store.Append(ctx, root.id, root.version, root.changes...)
+	if err != nil {
+		return err
+	}
+	changes := root.changes
+	root.version = version
+	root.changes = nil
+	if r.bus == nil {
+		return nil
+	}
+	for _, e := range changes {
+		if err = r.bus.Publish(ctx, e); err != nil {
+			logger.WithContext(ctx).Errorf(log.ERROR, "eventstore: publish %s of %s error: %v", e.EventType(), root.id, err)
+		}
+	}
+	return nil
+}
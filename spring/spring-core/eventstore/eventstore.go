@@ -0,0 +1,172 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package eventstore 为使用事件溯源的聚合提供事件存储。聚合的每次修改都以事件的形式
+// 追加到以聚合 ID 命名的流中，加载聚合时依次应用流中的事件重建状态，例如：
+//
+//	eventstore.Register(&OrderCreated{}, &OrderPaid{})
+//
+//	order := new(Order)
+//	if err := repo.Load(ctx, id, order); err != nil {
+//		return err
+//	}
+//	if err := order.Pay(amount); err != nil {
+//		return err
+//	}
+//	return repo.Save(ctx, order)
+//
+// 订阅按照位置顺序处理所有流中的事件，处理进度保存在事件存储中，适用于构建读模型。
+package eventstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+)
+
+var logger = log.GetLogger("GS_EVENTSTORE")
+
+const (
+	AnyVersion int64 = -1 // 追加事件时不检查流的版本
+	NoStream   int64 = 0  // 追加事件时流必须不存在
+)
+
+var (
+	ErrWrongVersion     = errors.New("eventstore: wrong expected version")
+	ErrStreamNotFound   = errors.New("eventstore: stream not found")
+	ErrUnknownEventType = errors.New("eventstore: unknown event type")
+)
+
+// Event 领域事件，EventType 返回保存在事件存储中的事件类型。
+type Event interface {
+	EventType() string
+}
+
+var registry = struct {
+	sync.RWMutex
+	types map[string]reflect.Type
+}{types: make(map[string]reflect.Type)}
+
+// Register 注册事件类型，读取事件时根据事件类型将数据解码为注册的类型。
+func Register(events ...Event) {
+	registry.Lock()
+	defer registry.Unlock()
+	for _, e := range events {
+		registry.types[e.EventType()] = reflect.TypeOf(e)
+	}
+}
+
+// RecordedEvent 保存在事件存储中的事件。
+type RecordedEvent struct {
+	Position   int64             `json:"position"` // 在所有事件中的位置，从 1 开始递增
+	StreamID   string            `json:"stream_id"`
+	Version    int64             `json:"version"` // 在流中的版本，从 1 开始递增
+	Type       string            `json:"type"`
+	Data       json.RawMessage   `json:"data"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	RecordedAt time.Time         `json:"recorded_at"`
+}
+
+// Decode 将事件数据解码为注册的事件类型，事件类型没有注册时返回 ErrUnknownEventType 。
+func (e *RecordedEvent) Decode() (Event, error) {
+	registry.RLock()
+	t, ok := registry.types[e.Type]
+	registry.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownEventType, e.Type)
+	}
+	var v reflect.Value
+	if t.Kind() == reflect.Ptr {
+		v = reflect.New(t.Elem())
+		if err := json.Unmarshal(e.Data, v.Interface()); err != nil {
+			return nil, err
+		}
+	} else {
+		ptr := reflect.New(t)
+		if err := json.Unmarshal(e.Data, ptr.Interface()); err != nil {
+			return nil, err
+		}
+		v = ptr.Elem()
+	}
+	return v.Interface().(Event), nil
+}
+
+// Store 事件存储。
+type Store interface {
+
+	// Append 将事件追加到流的末尾，返回追加之后流的版本。expectedVersion 是流当前的
+	// 版本，不一致时返回 ErrWrongVersion ，为 AnyVersion 时不检查。
+	Append(ctx context.Context, streamID string, expectedVersion int64, events ...Event) (int64, error)
+
+	// ReadStream 按照版本顺序返回流中版本大于 from 的事件。
+	ReadStream(ctx context.Context, streamID string, from int64) ([]*RecordedEvent, error)
+
+	// ReadAll 按照位置顺序返回所有流中位置大于 from 的最多 limit 个事件。
+	ReadAll(ctx context.Context, from int64, limit int) ([]*RecordedEvent, error)
+
+	// LoadCheckpoint 返回订阅已经处理到的位置，没有记录时返回 0 。
+	LoadCheckpoint(ctx context.Context, name string) (int64, error)
+
+	// SaveCheckpoint 保存订阅已经处理到的位置。
+	SaveCheckpoint(ctx context.Context, name string, position int64) error
+}
+
+type metadataKey struct{}
+
+// WithMetadata 返回携带元数据的 ctx ，使用该 ctx 追加的事件会保存这些元数据，通常用来
+// 记录操作人、关联 ID 等信息。
+func WithMetadata(ctx context.Context, key, value string) context.Context {
+	m := make(map[string]string)
+	for k, v := range Metadata(ctx) {
+		m[k] = v
+	}
+	m[key] = value
+	return context.WithValue(ctx, metadataKey{}, m)
+}
+
+// Metadata 返回 ctx 携带的元数据。
+func Metadata(ctx context.Context) map[string]string {
+	m, _ := ctx.Value(metadataKey{}).(map[string]string)
+	return m
+}
+
+// newRecords 将事件转换为从 version+1 开始的记录，Position 由 Store 填写。
+func newRecords(ctx context.Context, streamID string, version int64, events []Event) ([]*RecordedEvent, error) {
+	now := time.Now()
+	metadata := Metadata(ctx)
+	records := make([]*RecordedEvent, 0, len(events))
+	for i, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, &RecordedEvent{
+			StreamID:   streamID,
+			Version:    version + int64(i) + 1,
+			Type:       e.EventType(),
+			Data:       data,
+			Metadata:   metadata,
+			RecordedAt: now,
+		})
+	}
+	return records, nil
+}
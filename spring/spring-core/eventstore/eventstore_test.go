@@ -0,0 +1,183 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/event"
+	"github.com/go-spring/spring-core/eventstore"
+)
+
+type OrderCreated struct {
+	Amount int `json:"amount"`
+}
+
+func (e *OrderCreated) EventType() string { return "order.created" }
+
+type OrderPaid struct {
+	Amount int `json:"amount"`
+}
+
+func (e *OrderPaid) EventType() string { return "order.paid" }
+
+type OrderShipped struct{}
+
+func (e OrderShipped) EventType() string { return "order.shipped" }
+
+func init() {
+	eventstore.Register(&OrderCreated{}, &OrderPaid{}, OrderShipped{})
+}
+
+type Order struct {
+	eventstore.AggregateRoot
+	Amount  int
+	Paid    int
+	Shipped bool
+}
+
+func (o *Order) Apply(e eventstore.Event) error {
+	switch v := e.(type) {
+	case *OrderCreated:
+		o.Amount = v.Amount
+	case *OrderPaid:
+		o.Paid += v.Amount
+	case OrderShipped:
+		o.Shipped = true
+	default:
+		return errors.New("unexpected event " + e.EventType())
+	}
+	return nil
+}
+
+func (o *Order) Pay(amount int) error {
+	if o.Paid+amount > o.Amount {
+		return errors.New("overpaid")
+	}
+	return eventstore.Raise(o, &OrderPaid{Amount: amount})
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := eventstore.NewMemoryStore()
+	ctx := eventstore.WithMetadata(context.Background(), "user", "jim")
+
+	v, err := s.Append(ctx, "order-1", eventstore.NoStream, &OrderCreated{Amount: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, v, int64(1))
+
+	_, err = s.Append(ctx, "order-1", eventstore.NoStream, &OrderCreated{Amount: 10})
+	assert.Equal(t, err, eventstore.ErrWrongVersion)
+
+	v, err = s.Append(ctx, "order-2", eventstore.AnyVersion, &OrderCreated{Amount: 20}, OrderShipped{})
+	assert.Nil(t, err)
+	assert.Equal(t, v, int64(2))
+
+	v, err = s.Append(ctx, "order-1", 1, &OrderPaid{Amount: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, v, int64(2))
+
+	events, err := s.ReadStream(ctx, "order-1", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, len(events), 1)
+	assert.Equal(t, events[0].Version, int64(2))
+	assert.Equal(t, events[0].Position, int64(4))
+	assert.Equal(t, events[0].Type, "order.paid")
+	assert.Equal(t, string(events[0].Data), `{"amount":10}`)
+	assert.Equal(t, events[0].Metadata, map[string]string{"user": "jim"})
+
+	e, err := events[0].Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, e, &OrderPaid{Amount: 10})
+
+	events, err = s.ReadAll(ctx, 1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, len(events), 2)
+	assert.Equal(t, events[0].StreamID, "order-2")
+	assert.Equal(t, events[1].Type, "order.shipped")
+
+	e, err = events[1].Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, e, OrderShipped{})
+
+	events, err = s.ReadAll(ctx, 4, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, len(events), 0)
+
+	_, err = (&eventstore.RecordedEvent{Type: "unknown"}).Decode()
+	assert.True(t, errors.Is(err, eventstore.ErrUnknownEventType))
+
+	position, err := s.LoadCheckpoint(ctx, "sub")
+	assert.Nil(t, err)
+	assert.Equal(t, position, int64(0))
+	assert.Nil(t, s.SaveCheckpoint(ctx, "sub", 3))
+	position, err = s.LoadCheckpoint(ctx, "sub")
+	assert.Nil(t, err)
+	assert.Equal(t, position, int64(3))
+}
+
+func TestRepository(t *testing.T) {
+
+	var published []eventstore.Event
+	bus := event.NewBus([]*event.Listener{
+		event.Listen("orders", func(ctx context.Context, e eventstore.Event) error {
+			published = append(published, e)
+			return nil
+		}),
+	}, nil)
+
+	s := eventstore.NewMemoryStore()
+	repo := eventstore.NewRepository(s, bus)
+	ctx := context.Background()
+
+	err := repo.Load(ctx, "order-1", new(Order))
+	assert.Equal(t, err, eventstore.ErrStreamNotFound)
+
+	order := new(Order)
+	order.SetID("order-1")
+	assert.Nil(t, eventstore.Raise(order, &OrderCreated{Amount: 10}))
+	assert.Nil(t, order.Pay(4))
+	assert.Equal(t, len(order.Changes()), 2)
+	assert.Nil(t, repo.Save(ctx, order))
+	assert.Equal(t, order.Version(), int64(2))
+	assert.Equal(t, len(order.Changes()), 0)
+	assert.Equal(t, published, []eventstore.Event{&OrderCreated{Amount: 10}, &OrderPaid{Amount: 4}})
+
+	o1, o2 := new(Order), new(Order)
+	assert.Nil(t, repo.Load(ctx, "order-1", o1))
+	assert.Nil(t, repo.Load(ctx, "order-1", o2))
+	assert.Equal(t, o1.ID(), "order-1")
+	assert.Equal(t, o1.Version(), int64(2))
+	assert.Equal(t, o1.Amount, 10)
+	assert.Equal(t, o1.Paid, 4)
+
+	assert.Nil(t, o1.Pay(6))
+	assert.Nil(t, repo.Save(ctx, o1))
+	assert.Equal(t, o1.Version(), int64(3))
+
+	// o2 加载之后 order-1 已经被修改。
+	assert.Nil(t, o2.Pay(1))
+	assert.Equal(t, repo.Save(ctx, o2), eventstore.ErrWrongVersion)
+
+	assert.Error(t, o1.Pay(1), "overpaid")
+	assert.Nil(t, repo.Save(ctx, new(Order)))
+	o := new(Order)
+	assert.Nil(t, eventstore.Raise(o, OrderShipped{}))
+	assert.Error(t, repo.Save(ctx, o), "aggregate id is empty")
+}
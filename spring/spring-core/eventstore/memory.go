@@ -0,0 +1,110 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventstore
+
+import (
+	"context"
+	"sync"
+)
+
+// memoryStore 基于内存的 Store ，事件不会被持久化，适用于开发和测试。
+type memoryStore struct {
+	mutex       sync.RWMutex
+	events      []*RecordedEvent
+	streams     map[string][]*RecordedEvent
+	checkpoints map[string]int64
+}
+
+// NewMemoryStore 创建基于内存的 Store 。
+func NewMemoryStore() Store {
+	return &memoryStore{
+		streams:     make(map[string][]*RecordedEvent),
+		checkpoints: make(map[string]int64),
+	}
+}
+
+func (s *memoryStore) Append(ctx context.Context, streamID string, expectedVersion int64, events ...Event) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stream := s.streams[streamID]
+	version := int64(len(stream))
+	if expectedVersion != AnyVersion && expectedVersion != version {
+		return 0, ErrWrongVersion
+	}
+	records, err := newRecords(ctx, streamID, version, events)
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range records {
+		r.Position = int64(len(s.events)) + 1
+		s.events = append(s.events, r)
+		stream = append(stream, r)
+	}
+	s.streams[streamID] = stream
+	return int64(len(stream)), nil
+}
+
+func (s *memoryStore) ReadStream(ctx context.Context, streamID string, from int64) ([]*RecordedEvent, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	stream := s.streams[streamID]
+	if from < 0 {
+		from = 0
+	}
+	if from >= int64(len(stream)) {
+		return nil, nil
+	}
+	return copyRecords(stream[from:]), nil
+}
+
+func (s *memoryStore) ReadAll(ctx context.Context, from int64, limit int) ([]*RecordedEvent, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if from < 0 {
+		from = 0
+	}
+	if from >= int64(len(s.events)) {
+		return nil, nil
+	}
+	events := s.events[from:]
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+	return copyRecords(events), nil
+}
+
+func copyRecords(records []*RecordedEvent) []*RecordedEvent {
+	r := make([]*RecordedEvent, len(records))
+	for i, e := range records {
+		c := *e
+		r[i] = &c
+	}
+	return r
+}
+
+func (s *memoryStore) LoadCheckpoint(ctx context.Context, name string) (int64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.checkpoints[name], nil
+}
+
+func (s *memoryStore) SaveCheckpoint(ctx context.Context, name string, position int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.checkpoints[name] = position
+	return nil
+}
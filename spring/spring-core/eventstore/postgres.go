@@ -0,0 +1,188 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+// PostgresConfig 基于 PostgreSQL 的 Store 的配置。
+type PostgresConfig struct {
+	Table           string `value:"${table:=events}"`
+	CheckpointTable string `value:"${checkpoint-table:=event_checkpoints}"`
+}
+
+// postgresStore 基于 PostgreSQL 的 Store 。追加事件时使用事务级的 advisory lock 串行化
+// 写入，保证事件的位置按照提交的顺序递增，订阅不会因为并发事务的提交顺序而遗漏事件。
+type postgresStore struct {
+	db      *sql.DB
+	config  PostgresConfig
+	lockKey int64
+}
+
+// NewPostgresStore 创建基于 PostgreSQL 的 Store ，表结构如下：
+//
+//	CREATE TABLE events (
+//	    position    BIGSERIAL PRIMARY KEY,
+//	    stream_id   VARCHAR(255) NOT NULL,
+//	    version     BIGINT NOT NULL,
+//	    type        VARCHAR(255) NOT NULL,
+//	    data        JSONB NOT NULL,
+//	    metadata    JSONB,
+//	    recorded_at BIGINT NOT NULL,
+//	    UNIQUE (stream_id, version)
+//	);
+//
+//	CREATE TABLE event_checkpoints (
+//	    name     VARCHAR(255) PRIMARY KEY,
+//	    position BIGINT NOT NULL
+//	);
+func NewPostgresStore(config PostgresConfig, db *sql.DB) Store {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("eventstore:" + config.Table))
+	return &postgresStore{db: db, config: config, lockKey: int64(h.Sum64())}
+}
+
+func (s *postgresStore) Append(ctx context.Context, streamID string, expectedVersion int64, events ...Event) (_ int64, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", s.lockKey); err != nil {
+		return 0, err
+	}
+
+	var version int64
+	query := fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s WHERE stream_id = $1", s.config.Table)
+	if err = tx.QueryRowContext(ctx, query, streamID).Scan(&version); err != nil {
+		return 0, err
+	}
+	if expectedVersion != AnyVersion && expectedVersion != version {
+		return 0, ErrWrongVersion
+	}
+
+	records, err := newRecords(ctx, streamID, version, events)
+	if err != nil {
+		return 0, err
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (stream_id, version, type, data, metadata, recorded_at) VALUES ($1, $2, $3, $4, $5, $6)", s.config.Table)
+	for _, r := range records {
+		var metadata interface{}
+		if len(r.Metadata) > 0 {
+			var b []byte
+			if b, err = json.Marshal(r.Metadata); err != nil {
+				return 0, err
+			}
+			metadata = string(b)
+		}
+		// jsonb 字段使用字符串传参，[]byte 会被驱动当作 bytea 编码。
+		_, err = tx.ExecContext(ctx, insert, r.StreamID, r.Version, r.Type, string(r.Data), metadata, millis(r.RecordedAt))
+		if err != nil {
+			if isUniqueViolation(err) {
+				err = ErrWrongVersion
+			}
+			return 0, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return version + int64(len(records)), nil
+}
+
+// isUniqueViolation 判断是否违反了唯一约束，兼容 lib/pq 和 pgx 的错误类型。
+func isUniqueViolation(err error) bool {
+	var e interface{ SQLState() string }
+	if errors.As(err, &e) {
+		return e.SQLState() == "23505"
+	}
+	return strings.Contains(err.Error(), "23505")
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+const columns = "position, stream_id, version, type, data, metadata, recorded_at"
+
+func (s *postgresStore) ReadStream(ctx context.Context, streamID string, from int64) ([]*RecordedEvent, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE stream_id = $1 AND version > $2 ORDER BY version", columns, s.config.Table)
+	return s.query(ctx, query, streamID, from)
+}
+
+func (s *postgresStore) ReadAll(ctx context.Context, from int64, limit int) ([]*RecordedEvent, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE position > $1 ORDER BY position LIMIT $2", columns, s.config.Table)
+	return s.query(ctx, query, from, limit)
+}
+
+func (s *postgresStore) query(ctx context.Context, query string, args ...interface{}) ([]*RecordedEvent, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []*RecordedEvent
+	for rows.Next() {
+		var (
+			r          = new(RecordedEvent)
+			data       []byte
+			metadata   []byte
+			recordedAt int64
+		)
+		if err = rows.Scan(&r.Position, &r.StreamID, &r.Version, &r.Type, &data, &metadata, &recordedAt); err != nil {
+			return nil, err
+		}
+		r.Data = data
+		if len(metadata) > 0 {
+			if err = json.Unmarshal(metadata, &r.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		r.RecordedAt = time.Unix(0, recordedAt*int64(time.Millisecond))
+		events = append(events, r)
+	}
+	return events, rows.Err()
+}
+
+func (s *postgresStore) LoadCheckpoint(ctx context.Context, name string) (int64, error) {
+	var position int64
+	query := fmt.Sprintf("SELECT position FROM %s WHERE name = $1", s.config.CheckpointTable)
+	err := s.db.QueryRowContext(ctx, query, name).Scan(&position)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return position, err
+}
+
+func (s *postgresStore) SaveCheckpoint(ctx context.Context, name string, position int64) error {
+	query := fmt.Sprintf("INSERT INTO %s (name, position) VALUES ($1, $2) ON CONFLICT (name) DO UPDATE SET position = EXCLUDED.position", s.config.CheckpointTable)
+	_, err := s.db.ExecContext(ctx, query, name, position)
+	return err
+}
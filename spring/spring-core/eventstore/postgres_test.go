@@ -0,0 +1,132 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/eventstore"
+)
+
+// pgError 模拟驱动返回的带有 SQLSTATE 的错误。
+type pgError struct{ code string }
+
+func (e *pgError) Error() string    { return "pq: duplicate key value violates unique constraint" }
+func (e *pgError) SQLState() string { return e.code }
+
+func TestPostgresStore_Append(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	config := eventstore.PostgresConfig{Table: "events", CheckpointTable: "event_checkpoints"}
+	s := eventstore.NewPostgresStore(config, db)
+	ctx := eventstore.WithMetadata(context.Background(), "user", "jim")
+
+	lock := `SELECT pg_advisory_xact_lock\(\$1\)`
+	max := `SELECT COALESCE\(MAX\(version\), 0\) FROM events WHERE stream_id = \$1`
+	insert := `INSERT INTO events \(stream_id, version, type, data, metadata, recorded_at\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6\)`
+
+	mock.ExpectBegin()
+	mock.ExpectExec(lock).WithArgs(sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(max).WithArgs("order-1").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(1))
+	mock.ExpectExec(insert).WithArgs("order-1", 2, "order.paid", `{"amount":3}`, `{"user":"jim"}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec(insert).WithArgs("order-1", 3, "order.shipped", `{}`, `{"user":"jim"}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectCommit()
+
+	v, err := s.Append(ctx, "order-1", 1, &OrderPaid{Amount: 3}, OrderShipped{})
+	assert.Nil(t, err)
+	assert.Equal(t, v, int64(3))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(lock).WithArgs(sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(max).WithArgs("order-1").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(3))
+	mock.ExpectRollback()
+
+	_, err = s.Append(ctx, "order-1", eventstore.NoStream, &OrderCreated{Amount: 10})
+	assert.Equal(t, err, eventstore.ErrWrongVersion)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(lock).WithArgs(sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(max).WithArgs("order-2").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(0))
+	mock.ExpectExec(insert).WithArgs("order-2", 1, "order.created", `{"amount":10}`, nil, sqlmock.AnyArg()).
+		WillReturnError(&pgError{code: "23505"})
+	mock.ExpectRollback()
+
+	_, err = s.Append(context.Background(), "order-2", eventstore.AnyVersion, &OrderCreated{Amount: 10})
+	assert.Equal(t, err, eventstore.ErrWrongVersion)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(lock).WithArgs(sqlmock.AnyArg()).WillReturnError(errors.New("conn closed"))
+	mock.ExpectRollback()
+
+	_, err = s.Append(context.Background(), "order-2", eventstore.AnyVersion, &OrderCreated{Amount: 10})
+	assert.Error(t, err, "conn closed")
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresStore_Read(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	config := eventstore.PostgresConfig{Table: "events", CheckpointTable: "event_checkpoints"}
+	s := eventstore.NewPostgresStore(config, db)
+	ctx := context.Background()
+
+	columns := []string{"position", "stream_id", "version", "type", "data", "metadata", "recorded_at"}
+	mock.ExpectQuery(`SELECT position, stream_id, version, type, data, metadata, recorded_at FROM events WHERE stream_id = \$1 AND version > \$2 ORDER BY version`).
+		WithArgs("order-1", 0).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "order-1", 1, "order.created", []byte(`{"amount":10}`), []byte(`{"user":"jim"}`), 1600000000000).
+			AddRow(3, "order-1", 2, "order.paid", []byte(`{"amount":10}`), nil, 1600000000000))
+
+	order := new(Order)
+	assert.Nil(t, eventstore.NewRepository(s, nil).Load(ctx, "order-1", order))
+	assert.Equal(t, order.Version(), int64(2))
+	assert.Equal(t, order.Paid, 10)
+
+	mock.ExpectQuery(`SELECT position, stream_id, version, type, data, metadata, recorded_at FROM events WHERE position > \$1 ORDER BY position LIMIT \$2`).
+		WithArgs(1, 100).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(2, "order-2", 1, "order.created", []byte(`{"amount":5}`), []byte(`{"user":"tom"}`), 1600000000000))
+
+	events, err := s.ReadAll(ctx, 1, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, len(events), 1)
+	assert.Equal(t, events[0].Metadata, map[string]string{"user": "tom"})
+	assert.Equal(t, events[0].RecordedAt.Unix(), int64(1600000000))
+
+	mock.ExpectQuery(`SELECT position FROM event_checkpoints WHERE name = \$1`).WithArgs("sub").
+		WillReturnRows(sqlmock.NewRows([]string{"position"}))
+	position, err := s.LoadCheckpoint(ctx, "sub")
+	assert.Nil(t, err)
+	assert.Equal(t, position, int64(0))
+
+	mock.ExpectExec(`INSERT INTO event_checkpoints \(name, position\) VALUES \(\$1, \$2\) ON CONFLICT \(name\) DO UPDATE SET position = EXCLUDED.position`).
+		WithArgs("sub", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.Nil(t, s.SaveCheckpoint(ctx, "sub", 2))
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,239 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-base/util"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/report"
+)
+
+var recordedEventType = reflect.TypeOf((*RecordedEvent)(nil))
+
+// Subscription 按照位置顺序处理所有流中的事件，处理进度保存在 Store 中，应用重启之后
+// 从上次的位置继续处理。事件至少被处理一次，处理函数需要保证幂等。
+type Subscription struct {
+	name string
+	t    reflect.Type
+	fn   reflect.Value
+}
+
+// Subscribe 创建订阅，fn 的形式为 func(ctx context.Context, e T) error 。T 为
+// *RecordedEvent 时传入所有的事件，否则只传入解码之后可以赋值给 T 的事件，T 可以是
+// 接口类型。fn 返回错误时订阅停止前进，等待一段时间之后重新处理该事件。
+func Subscribe(name string, fn interface{}) *Subscription {
+	t := reflect.TypeOf(fn)
+	if !util.IsFuncType(t) || !util.ReturnOnlyError(t) || t.NumIn() != 2 || !util.IsContextType(t.In(0)) {
+		panic(errors.New("fn should be func(context.Context, T) error"))
+	}
+	return &Subscription{name: name, t: t.In(1), fn: reflect.ValueOf(fn)}
+}
+
+func (s *Subscription) handle(ctx context.Context, r *RecordedEvent) error {
+	var arg interface{} = r
+	if s.t != recordedEventType {
+		e, err := r.Decode()
+		if errors.Is(err, ErrUnknownEventType) {
+			return nil // 没有注册的事件类型不可能赋值给 T
+		}
+		if err != nil {
+			return err
+		}
+		if !reflect.TypeOf(e).AssignableTo(s.t) {
+			return nil
+		}
+		arg = e
+	}
+	return s.call(ctx, arg)
+}
+
+func (s *Subscription) call(ctx context.Context, e interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			report.Panic(report.WithTags(ctx, "eventstore.subscription", s.name), report.SourceEvent, r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	out := s.fn.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(e)})
+	err, _ = out[0].Interface().(error)
+	return err
+}
+
+// Config 订阅的配置，通常配合 eventstore 前缀一起使用。
+type Config struct {
+	PollInterval time.Duration `value:"${poll-interval:=1s}"` // 没有新事件或者处理失败时等待的时间
+	BatchSize    int           `value:"${batch-size:=100}"`   // 每次读取的事件数量
+}
+
+// Processor 在后台运行所有的订阅。
+type Processor struct {
+	config        Config
+	store         Store
+	subscriptions []*Subscription
+
+	mutex  sync.Mutex
+	closed bool
+	stop   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewProcessor 创建 Processor ，同一个名称只能有一个订阅。
+func NewProcessor(config Config, store Store, subscriptions []*Subscription) (*Processor, error) {
+	names := make(map[string]bool)
+	for _, s := range subscriptions {
+		if names[s.name] {
+			return nil, fmt.Errorf("eventstore: duplicate subscription %s", s.name)
+		}
+		names[s.name] = true
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	p := &Processor{
+		config:        config,
+		store:         store,
+		subscriptions: subscriptions,
+		stop:          make(chan struct{}),
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	return p, nil
+}
+
+// Start 在后台运行所有的订阅。
+func (p *Processor) Start() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return
+	}
+	for _, s := range p.subscriptions {
+		p.wg.Add(1)
+		go p.run(s)
+	}
+}
+
+// run 不断地读取新的事件交给订阅处理，每处理完一批事件保存一次处理进度。
+func (p *Processor) run(s *Subscription) {
+	defer p.wg.Done()
+	ctx := p.ctx
+	position, err := p.load(ctx, s.name)
+	for {
+		if err == nil {
+			var n int
+			n, err = p.process(ctx, s, &position)
+			if err == nil && n == p.config.BatchSize {
+				select {
+				case <-p.stop:
+					return
+				default:
+					continue
+				}
+			}
+		}
+		if err != nil && ctx.Err() == nil {
+			logger.WithContext(ctx).Errorf(log.ERROR, "eventstore: subscription %s at %d error: %v", s.name, position, err)
+		}
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(p.config.PollInterval):
+		}
+		if position < 0 {
+			position, err = p.load(ctx, s.name)
+		} else {
+			err = nil
+		}
+	}
+}
+
+// load 读取订阅的处理进度，失败时返回 -1 以便重新读取。
+func (p *Processor) load(ctx context.Context, name string) (int64, error) {
+	position, err := p.store.LoadCheckpoint(ctx, name)
+	if err != nil {
+		return -1, err
+	}
+	return position, nil
+}
+
+// process 处理一批事件，返回读取的事件数量。
+func (p *Processor) process(ctx context.Context, s *Subscription, position *int64) (int, error) {
+	events, err := p.store.ReadAll(ctx, *position, p.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	from := *position
+	for _, e := range events {
+		if err = s.handle(ctx, e); err != nil {
+			err = fmt.Errorf("event %d: %w", e.Position, err)
+			break
+		}
+		*position = e.Position
+	}
+	if *position > from {
+		if e := p.store.SaveCheckpoint(context.Background(), s.name, *position); e != nil && err == nil {
+			err = e
+		}
+	}
+	return len(events), err
+}
+
+// Close 停止所有的订阅，ctx 结束时取消正在处理的事件。
+func (p *Processor) Close(ctx context.Context) error {
+	p.mutex.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.stop)
+	}
+	p.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// OnAppStart 应用启动后运行所有的订阅。
+func (p *Processor) OnAppStart(ctx gs.Context) {
+	p.Start()
+}
+
+// OnAppStop 应用退出时停止所有的订阅。
+func (p *Processor) OnAppStop(ctx context.Context) {
+	if err := p.Close(ctx); err != nil {
+		logger.WithContext(ctx).Errorf(log.ERROR, "eventstore: close processor error: %v", err)
+	}
+}
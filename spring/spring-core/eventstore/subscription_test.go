@@ -0,0 +1,118 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventstore_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/eventstore"
+)
+
+// unregistered 没有注册的事件类型，只会被处理 *RecordedEvent 的订阅处理。
+type unregistered struct{}
+
+func (e *unregistered) EventType() string { return "unregistered" }
+
+func TestProcessor(t *testing.T) {
+
+	var (
+		mutex  sync.Mutex
+		all    []int64
+		paid   int
+		failed bool
+	)
+	subscriptions := []*eventstore.Subscription{
+		eventstore.Subscribe("all", func(ctx context.Context, e *eventstore.RecordedEvent) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			all = append(all, e.Position)
+			return nil
+		}),
+		eventstore.Subscribe("paid", func(ctx context.Context, e *OrderPaid) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if !failed {
+				failed = true
+				return errors.New("unavailable")
+			}
+			paid += e.Amount
+			return nil
+		}),
+	}
+
+	s := eventstore.NewMemoryStore()
+	ctx := context.Background()
+	_, err := s.Append(ctx, "order-1", eventstore.NoStream, &OrderCreated{Amount: 10}, &OrderPaid{Amount: 3})
+	assert.Nil(t, err)
+	_, err = s.Append(ctx, "order-2", eventstore.NoStream, &OrderCreated{Amount: 5})
+	assert.Nil(t, err)
+	_, err = s.Append(ctx, "order-3", eventstore.NoStream, &unregistered{})
+	assert.Nil(t, err)
+
+	config := eventstore.Config{PollInterval: 10 * time.Millisecond, BatchSize: 2}
+	p, err := eventstore.NewProcessor(config, s, subscriptions)
+	assert.Nil(t, err)
+	p.Start()
+
+	_, err = s.Append(ctx, "order-2", 1, &OrderPaid{Amount: 5})
+	assert.Nil(t, err)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mutex.Lock()
+		done := len(all) == 5 && paid == 8
+		mutex.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Nil(t, p.Close(ctx))
+	assert.Equal(t, all, []int64{1, 2, 3, 4, 5})
+
+	position, err := s.LoadCheckpoint(ctx, "paid")
+	assert.Nil(t, err)
+	assert.Equal(t, position, int64(5))
+
+	// 重启之后从保存的位置继续处理。
+	p, err = eventstore.NewProcessor(config, s, subscriptions)
+	assert.Nil(t, err)
+	p.Start()
+	time.Sleep(50 * time.Millisecond)
+	assert.Nil(t, p.Close(ctx))
+	assert.Equal(t, len(all), 5)
+	assert.Equal(t, paid, 8)
+}
+
+func TestNewProcessor(t *testing.T) {
+	fn := func(ctx context.Context, e *eventstore.RecordedEvent) error { return nil }
+	subscriptions := []*eventstore.Subscription{eventstore.Subscribe("a", fn), eventstore.Subscribe("a", fn)}
+	_, err := eventstore.NewProcessor(eventstore.Config{}, eventstore.NewMemoryStore(), subscriptions)
+	assert.Error(t, err, "duplicate subscription a")
+
+	assert.Panic(t, func() {
+		eventstore.Subscribe("a", func(e *eventstore.RecordedEvent) {})
+	}, "fn should be func\\(context.Context, T\\) error")
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// column 导出的一列数据。
+type column struct {
+	name   string // 字段名
+	header string // 表头
+	index  []int
+	format string // 时间类型为 layout ，其他类型为 fmt 的格式
+}
+
+// parseColumns 根据结构体的 export 标签解析导出的列，标签的格式为 "表头[,format=格式]"，
+// 表头为空时使用字段名，"-" 表示不导出该字段，嵌入的结构体的字段被展开。
+func parseColumns(t reflect.Type, index []int) []*column {
+	var columns []*column
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag, ok := f.Tag.Lookup("export")
+		if tag == "-" {
+			continue
+		}
+		idx := append(append([]int{}, index...), i)
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && !ok && ft.Kind() == reflect.Struct && ft != timeType {
+			columns = append(columns, parseColumns(ft, idx)...)
+			continue
+		}
+		c := &column{name: f.Name, header: f.Name, index: idx}
+		for i, s := range strings.Split(tag, ",") {
+			if i == 0 {
+				if s != "" {
+					c.header = s
+				}
+			} else if strings.HasPrefix(s, "format=") {
+				c.format = strings.TrimPrefix(s, "format=")
+			}
+		}
+		columns = append(columns, c)
+	}
+	return columns
+}
+
+// cell 一个单元格，number 表示数值类型，XLSX 格式中保存为数字。
+type cell struct {
+	value  string
+	number bool
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// field 返回 v 中该列的值，经过的指针为 nil 时返回无效值。
+func (c *column) field(v reflect.Value) reflect.Value {
+	for i, x := range c.index {
+		if i > 0 {
+			for v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					return reflect.Value{}
+				}
+				v = v.Elem()
+			}
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// cell 将该列的值格式化为单元格。
+func (c *column) cell(v reflect.Value, timeFormat string) cell {
+	v = c.field(v)
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return cell{}
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return cell{}
+	}
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return cell{}
+		}
+		if c.format != "" {
+			timeFormat = c.format
+		}
+		return cell{value: t.Format(timeFormat)}
+	}
+	if c.format != "" {
+		return cell{value: fmt.Sprintf(c.format, v.Interface())}
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return cell{value: s.String()}
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return cell{value: v.String()}
+	case reflect.Bool:
+		return cell{value: strconv.FormatBool(v.Bool())}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cell{value: strconv.FormatInt(v.Int(), 10), number: true}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cell{value: strconv.FormatUint(v.Uint(), 10), number: true}
+	case reflect.Float32, reflect.Float64:
+		return cell{value: strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), number: true}
+	}
+	return cell{value: fmt.Sprint(v.Interface())}
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// writer 按行写入导出的数据。
+type writer interface {
+	writeRow(cells []cell) error
+	flush() error
+	close() error
+}
+
+// csvWriter 写入 CSV 格式的数据。
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer, bom bool) (*csvWriter, error) {
+	if bom {
+		if _, err := io.WriteString(w, "\xEF\xBB\xBF"); err != nil {
+			return nil, err
+		}
+	}
+	return &csvWriter{w: csv.NewWriter(w)}, nil
+}
+
+func (w *csvWriter) writeRow(cells []cell) error {
+	record := make([]string, len(cells))
+	for i, c := range cells {
+		record[i] = c.value
+		// 以 = + - @ 开头的文本会被表格软件当作公式执行，加上单引号防止 CSV 注入。
+		if !c.number && c.value != "" && strings.ContainsRune("=+-@\t\r", rune(c.value[0])) {
+			record[i] = "'" + c.value
+		}
+	}
+	return w.w.Write(record)
+}
+
+func (w *csvWriter) flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvWriter) close() error {
+	return w.flush()
+}
@@ -0,0 +1,318 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package export 将大量的查询结果以 CSV 或者 XLSX 格式流式地写入 HTTP 响应。数据按照
+// 批次读取，每写完一批数据刷新一次响应，客户端接收得慢时写入会被阻塞，从而不会继续
+// 查询下一批数据，内存中最多只保存一批数据。列根据结构体的 export 标签生成，例如：
+//
+//	type Order struct {
+//		ID        int64     `export:"订单号"`
+//		Amount    float64   `export:"金额,format=%.2f"`
+//		CreatedAt time.Time `export:"下单时间,format=2006-01-02"`
+//		Remark    string    `export:"-"`
+//	}
+//
+//	var orders = export.New(Order{}).Filename("orders")
+//
+//	func (c *Controller) Export(ctx web.Context) {
+//		format, err := export.ParseFormat(ctx.QueryParam("format"))
+//		...
+//		src := export.Paged(1000, web.Sort{{Field: "id"}}, func(ctx context.Context, p web.Pageable) (interface{}, error) {
+//			var rows []Order
+//			err := db.WithContext(ctx).Scopes(page.Paginate(p)).Find(&rows).Error
+//			return rows, err
+//		})
+//		if err = orders.Serve(ctx, format, src); err != nil {
+//			...
+//		}
+//	}
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-spring/spring-core/web"
+)
+
+// ErrTooManyRows 导出的行数超过了限制。
+var ErrTooManyRows = errors.New("export: too many rows")
+
+// Format 导出的文件格式。
+type Format string
+
+const (
+	CSV  Format = "csv"
+	XLSX Format = "xlsx"
+)
+
+// ParseFormat 解析文件格式，空字符串返回 CSV 。
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "", "csv":
+		return CSV, nil
+	case "xlsx", "excel":
+		return XLSX, nil
+	}
+	return "", fmt.Errorf("export: unsupported format %q", s)
+}
+
+// ContentType 返回文件格式对应的 MIME 类型。
+func (f Format) ContentType() string {
+	if f == XLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Source 按照批次读取导出的数据，每次调用返回下一批数据，数据为结构体或者结构体指针
+// 的切片，返回空切片或者 nil 时结束。
+type Source func(ctx context.Context) (interface{}, error)
+
+// Slice 返回只有一批数据的 Source 。
+func Slice(rows interface{}) Source {
+	done := false
+	return func(ctx context.Context) (interface{}, error) {
+		if done {
+			return nil, nil
+		}
+		done = true
+		return rows, nil
+	}
+}
+
+// Paged 返回从第 0 页开始依次查询的 Source ，某一页的数据少于 size 时结束。sort 需要
+// 保证顺序稳定，通常包含主键，否则翻页时可能重复或者遗漏数据。size 不大于 0 时使用
+// web.DefaultPageSize 。
+func Paged(size int, sort web.Sort, fn func(ctx context.Context, p web.Pageable) (interface{}, error)) Source {
+	if size <= 0 {
+		size = web.DefaultPageSize
+	}
+	p := web.Pageable{Size: size, Sort: sort}
+	done := false
+	return func(ctx context.Context) (interface{}, error) {
+		if done {
+			return nil, nil
+		}
+		rows, err := fn(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		if v := reflect.ValueOf(rows); v.Kind() != reflect.Slice || v.Len() < size {
+			done = true
+		}
+		p.Page++
+		return rows, nil
+	}
+}
+
+// Exporter 根据结构体的 export 标签导出数据。
+type Exporter struct {
+	t          reflect.Type
+	columns    []*column
+	filename   string
+	sheet      string
+	timeFormat string
+	bom        bool
+	maxRows    int
+}
+
+// New 创建 Exporter ，model 为导出的结构体或者结构体指针。
+func New(model interface{}) *Exporter {
+	t := reflect.TypeOf(model)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		panic(errors.New("model should be a struct or a pointer to struct"))
+	}
+	return &Exporter{
+		t:          t,
+		columns:    parseColumns(t, nil),
+		filename:   "export",
+		sheet:      "Sheet1",
+		timeFormat: "2006-01-02 15:04:05",
+	}
+}
+
+// Filename 下载的文件名，不包括扩展名，默认为 export 。
+func (e *Exporter) Filename(name string) *Exporter {
+	e.filename = name
+	return e
+}
+
+// Sheet XLSX 格式的工作表名称，默认为 Sheet1 。
+func (e *Exporter) Sheet(name string) *Exporter {
+	e.sheet = name
+	return e
+}
+
+// TimeFormat 没有指定 format 的时间类型的格式，默认为 2006-01-02 15:04:05 。
+func (e *Exporter) TimeFormat(layout string) *Exporter {
+	e.timeFormat = layout
+	return e
+}
+
+// BOM CSV 格式是否以 UTF-8 BOM 开头，Excel 需要 BOM 才能正确识别中文。
+func (e *Exporter) BOM(bom bool) *Exporter {
+	e.bom = bom
+	return e
+}
+
+// MaxRows 最多导出的行数，超过时返回 ErrTooManyRows ，为 0 时不限制。
+func (e *Exporter) MaxRows(n int) *Exporter {
+	e.maxRows = n
+	return e
+}
+
+// Select 返回只导出指定列的 Exporter ，列可以使用字段名或者表头指定，导出的顺序和
+// 参数的顺序相同，通常用于让用户选择导出的列。
+func (e *Exporter) Select(names ...string) (*Exporter, error) {
+	r := *e
+	r.columns = nil
+	for _, name := range names {
+		var found *column
+		for _, c := range e.columns {
+			if c.name == name || c.header == name {
+				found = c
+				break
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("export: unknown column %q", name)
+		}
+		r.columns = append(r.columns, found)
+	}
+	return &r, nil
+}
+
+// Headers 返回导出的表头。
+func (e *Exporter) Headers() []string {
+	headers := make([]string, len(e.columns))
+	for i, c := range e.columns {
+		headers[i] = c.header
+	}
+	return headers
+}
+
+// Write 将 src 中的数据写入 w ，返回写入的行数，不包括表头。
+func (e *Exporter) Write(ctx context.Context, w io.Writer, format Format, src Source) (int, error) {
+	rows, err := src(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return e.write(ctx, w, format, rows, src, nil)
+}
+
+// Serve 将 src 中的数据作为附件写入 HTTP 响应。第一批数据在写入响应之前读取，读取
+// 失败时返回错误，处理函数可以正常地返回错误响应；之后的错误发生时响应已经开始发送，
+// 只能中断响应，客户端收到的文件是不完整的。
+func (e *Exporter) Serve(ctx web.Context, format Format, src Source) error {
+	c := ctx.Context()
+	rows, err := src(c)
+	if err != nil {
+		return err
+	}
+	ctx.SetContentType(format.ContentType())
+	ctx.SetHeader(web.HeaderContentDisposition, web.ContentDisposition("attachment", e.filename+"."+string(format)))
+	w := ctx.ResponseWriter()
+	flush := func() {}
+	if f, ok := w.(http.Flusher); ok {
+		flush = f.Flush
+	}
+	_, err = e.write(c, w, format, rows, src, flush)
+	return err
+}
+
+func (e *Exporter) newWriter(w io.Writer, format Format) (writer, error) {
+	switch format {
+	case CSV:
+		return newCSVWriter(w, e.bom)
+	case XLSX:
+		return newXLSXWriter(w, e.sheet)
+	}
+	return nil, fmt.Errorf("export: unsupported format %q", format)
+}
+
+// write 依次写入表头和每一批数据，每写完一批数据调用一次 flush 。
+func (e *Exporter) write(ctx context.Context, w io.Writer, format Format, rows interface{}, src Source, flush func()) (int, error) {
+	out, err := e.newWriter(w, format)
+	if err != nil {
+		return 0, err
+	}
+	headers := make([]cell, len(e.columns))
+	for i, c := range e.columns {
+		headers[i] = cell{value: c.header}
+	}
+	if err = out.writeRow(headers); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	cells := make([]cell, len(e.columns))
+	for {
+		v := reflect.ValueOf(rows)
+		if !v.IsValid() {
+			break
+		}
+		if v.Kind() != reflect.Slice {
+			return n, fmt.Errorf("export: rows should be a slice but got %s", v.Type())
+		}
+		if v.Len() == 0 {
+			break
+		}
+		for i := 0; i < v.Len(); i++ {
+			row := v.Index(i)
+			for row.Kind() == reflect.Ptr || row.Kind() == reflect.Interface {
+				row = row.Elem()
+			}
+			if !row.IsValid() {
+				continue
+			}
+			if row.Type() != e.t {
+				return n, fmt.Errorf("export: row should be %s but got %s", e.t, row.Type())
+			}
+			if e.maxRows > 0 && n >= e.maxRows {
+				return n, ErrTooManyRows
+			}
+			for j, c := range e.columns {
+				cells[j] = c.cell(row, e.timeFormat)
+			}
+			if err = out.writeRow(cells); err != nil {
+				return n, err
+			}
+			n++
+		}
+		if err = out.flush(); err != nil {
+			return n, err
+		}
+		if flush != nil {
+			flush()
+		}
+		if err = ctx.Err(); err != nil {
+			return n, err
+		}
+		if rows, err = src(ctx); err != nil {
+			return n, err
+		}
+	}
+	return n, out.close()
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/export"
+	"github.com/go-spring/spring-core/web"
+)
+
+type Status int
+
+func (s Status) String() string {
+	if s == 1 {
+		return "paid"
+	}
+	return "created"
+}
+
+type Base struct {
+	ID int64 `export:"订单号"`
+}
+
+type Order struct {
+	Base
+	Amount    float64   `export:"金额,format=%.2f"`
+	Count     int       `export:"数量"`
+	Status    Status    `export:"状态"`
+	Remark    string    `export:"备注"`
+	Coupon    *string   `export:"优惠券"`
+	Paid      bool      `export:"已支付"`
+	CreatedAt time.Time `export:"下单时间,format=2006-01-02"`
+	UpdatedAt time.Time
+	Secret    string `export:"-"`
+	internal  string
+}
+
+func newOrders() []*Order {
+	coupon := "NEW"
+	created := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	return []*Order{
+		{Base: Base{ID: 1}, Amount: 9.5, Count: 2, Status: 1, Remark: "=1+1", Coupon: &coupon, Paid: true, CreatedAt: created, UpdatedAt: created, Secret: "s"},
+		{Base: Base{ID: 2}, Amount: 10, Count: -1, Remark: `say "hi", bye`},
+	}
+}
+
+func TestExporter_CSV(t *testing.T) {
+	e := export.New(Order{})
+	assert.Equal(t, e.Headers(), []string{"订单号", "金额", "数量", "状态", "备注", "优惠券", "已支付", "下单时间", "UpdatedAt"})
+
+	var buf bytes.Buffer
+	n, err := e.Write(context.Background(), &buf, export.CSV, export.Slice(newOrders()))
+	assert.Nil(t, err)
+	assert.Equal(t, n, 2)
+	assert.Equal(t, buf.String(), "订单号,金额,数量,状态,备注,优惠券,已支付,下单时间,UpdatedAt\n"+
+		"1,9.50,2,paid,'=1+1,NEW,true,2021-06-01,2021-06-01 12:00:00\n"+
+		"2,10.00,-1,created,\"say \"\"hi\"\", bye\",,false,,\n")
+
+	s, err := e.Select("ID", "备注")
+	assert.Nil(t, err)
+	buf.Reset()
+	_, err = s.BOM(true).Write(context.Background(), &buf, export.CSV, export.Slice(newOrders()))
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), "\xEF\xBB\xBF订单号,备注\n1,'=1+1\n2,\"say \"\"hi\"\", bye\"\n")
+
+	_, err = e.Select("Secret")
+	assert.Error(t, err, "unknown column \"Secret\"")
+}
+
+func TestExporter_Errors(t *testing.T) {
+	ctx := context.Background()
+	e := export.New(&Order{}).MaxRows(1)
+
+	var buf bytes.Buffer
+	n, err := e.Write(ctx, &buf, export.CSV, export.Slice(newOrders()))
+	assert.Equal(t, err, export.ErrTooManyRows)
+	assert.Equal(t, n, 1)
+
+	_, err = e.Write(ctx, &buf, export.CSV, export.Slice([]string{"a"}))
+	assert.Error(t, err, "row should be export_test.Order but got string")
+
+	_, err = e.Write(ctx, &buf, export.CSV, export.Slice(1))
+	assert.Error(t, err, "rows should be a slice but got int")
+
+	_, err = e.Write(ctx, &buf, export.Format("pdf"), export.Slice(newOrders()))
+	assert.Error(t, err, "unsupported format \"pdf\"")
+
+	_, err = export.ParseFormat("pdf")
+	assert.Error(t, err, "unsupported format \"pdf\"")
+	f, err := export.ParseFormat("Excel")
+	assert.Nil(t, err)
+	assert.Equal(t, f, export.XLSX)
+
+	assert.Panic(t, func() { export.New(1) }, "model should be a struct or a pointer to struct")
+}
+
+func TestPaged(t *testing.T) {
+	var pages []web.Pageable
+	src := export.Paged(2, web.Sort{{Field: "id"}}, func(ctx context.Context, p web.Pageable) (interface{}, error) {
+		pages = append(pages, p)
+		var orders []Order
+		for i := p.Offset(); i < p.Offset()+p.Size && i < 5; i++ {
+			orders = append(orders, Order{Base: Base{ID: int64(i)}})
+		}
+		return orders, nil
+	})
+
+	e, err := export.New(Order{}).Select("ID")
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	n, err := e.Write(context.Background(), &buf, export.CSV, src)
+	assert.Nil(t, err)
+	assert.Equal(t, n, 5)
+	assert.Equal(t, buf.String(), "订单号\n0\n1\n2\n3\n4\n")
+	assert.Equal(t, len(pages), 3)
+	assert.Equal(t, pages[2], web.Pageable{Page: 2, Size: 2, Sort: web.Sort{{Field: "id"}}})
+}
+
+func TestExporter_Serve(t *testing.T) {
+	e := export.New(Order{}).Filename("订单")
+
+	serve := func(src export.Source) (*httptest.ResponseRecorder, error) {
+		r := httptest.NewRequest(http.MethodGet, "/orders/export", nil)
+		w := httptest.NewRecorder()
+		ctx := web.NewBaseContext("/orders/export", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+		return w, e.Serve(ctx, export.CSV, src)
+	}
+
+	batches := 0
+	w, err := serve(func(ctx context.Context) (interface{}, error) {
+		if batches++; batches > 3 {
+			return nil, nil
+		}
+		return newOrders(), nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, w.Header().Get(web.HeaderContentType), "text/csv; charset=utf-8")
+	assert.Equal(t, w.Header().Get(web.HeaderContentDisposition), web.ContentDisposition("attachment", "订单.csv"))
+	assert.True(t, w.Flushed)
+	assert.Equal(t, bytes.Count(w.Body.Bytes(), []byte("\n")), 7)
+
+	// 第一批数据读取失败时还没有写入响应。
+	w, err = serve(func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("db error")
+	})
+	assert.Error(t, err, "db error")
+	assert.Equal(t, w.Header().Get(web.HeaderContentDisposition), "")
+	assert.Equal(t, w.Body.Len(), 0)
+}
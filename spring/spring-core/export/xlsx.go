@@ -0,0 +1,156 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xlsxMaxRows XLSX 格式一个工作表最多的行数。
+const xlsxMaxRows = 1048576
+
+const xlsxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+var xlsxParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", xlsxHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xlsxHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/_rels/workbook.xml.rels", xlsxHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// xlsxWriter 写入只有一个工作表的 XLSX 格式的数据。文本使用内联字符串保存，不需要
+// 在内存中维护共享字符串表，因此可以边查询边写入。
+type xlsxWriter struct {
+	zw    *zip.Writer
+	sheet io.Writer
+	rows  int
+	buf   bytes.Buffer
+}
+
+func newXLSXWriter(w io.Writer, sheet string) (*xlsxWriter, error) {
+	zw := zip.NewWriter(w)
+	for _, p := range xlsxParts {
+		if err := writePart(zw, p.name, p.content); err != nil {
+			return nil, err
+		}
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xlsxHeader)
+	buf.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="`)
+	_ = xml.EscapeText(&buf, []byte(sheetName(sheet)))
+	buf.WriteString(`" sheetId="1" r:id="rId1"/></sheets></workbook>`)
+	if err := writePart(zw, "xl/workbook.xml", buf.String()); err != nil {
+		return nil, err
+	}
+	s, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.WriteString(s, xlsxHeader+`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxWriter{zw: zw, sheet: s}, nil
+}
+
+func writePart(zw *zip.Writer, name, content string) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, content)
+	return err
+}
+
+// sheetName 工作表的名称不能超过 31 个字符并且不能包含 []:*?/\ 。
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	if name == "" {
+		return "Sheet1"
+	}
+	return name
+}
+
+// columnName 返回从 0 开始的第 i 列的列名，例如 A、Z、AA 。
+func columnName(i int) string {
+	var b []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		b = append([]byte{byte('A' + (i-1)%26)}, b...)
+	}
+	return string(b)
+}
+
+func (w *xlsxWriter) writeRow(cells []cell) error {
+	if w.rows >= xlsxMaxRows {
+		return ErrTooManyRows
+	}
+	w.rows++
+	row := strconv.Itoa(w.rows)
+	w.buf.Reset()
+	w.buf.WriteString(`<row r="` + row + `">`)
+	for i, c := range cells {
+		if c.value == "" {
+			continue
+		}
+		ref := columnName(i) + row
+		if c.number {
+			w.buf.WriteString(`<c r="` + ref + `"><v>` + c.value + `</v></c>`)
+			continue
+		}
+		w.buf.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+		_ = xml.EscapeText(&w.buf, []byte(c.value))
+		w.buf.WriteString(`</t></is></c>`)
+	}
+	w.buf.WriteString(`</row>`)
+	_, err := w.sheet.Write(w.buf.Bytes())
+	return err
+}
+
+func (w *xlsxWriter) flush() error {
+	return w.zw.Flush()
+}
+
+func (w *xlsxWriter) close() error {
+	if _, err := io.WriteString(w.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/export"
+)
+
+type sheetXML struct {
+	Rows []struct {
+		R     string `xml:"r,attr"`
+		Cells []struct {
+			R  string `xml:"r,attr"`
+			T  string `xml:"t,attr"`
+			V  string `xml:"v"`
+			Is string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readXLSX(t *testing.T, b []byte) map[string]string {
+	t.Helper()
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	assert.Nil(t, err)
+	parts := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		assert.Nil(t, err)
+		data, err := ioutil.ReadAll(rc)
+		assert.Nil(t, err)
+		rc.Close()
+		parts[f.Name] = string(data)
+	}
+	return parts
+}
+
+func TestExporter_XLSX(t *testing.T) {
+	e, err := export.New(Order{}).Sheet("订单/2021").Select("ID", "Amount", "Count", "Remark", "Coupon")
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	n, err := e.Write(context.Background(), &buf, export.XLSX, export.Slice(newOrders()))
+	assert.Nil(t, err)
+	assert.Equal(t, n, 2)
+
+	parts := readXLSX(t, buf.Bytes())
+	assert.Equal(t, len(parts), 5)
+	assert.True(t, strings.Contains(parts["xl/workbook.xml"], `<sheet name="订单_2021"`))
+
+	var sheet sheetXML
+	assert.Nil(t, xml.Unmarshal([]byte(parts["xl/worksheets/sheet1.xml"]), &sheet))
+	assert.Equal(t, len(sheet.Rows), 3)
+	assert.Equal(t, sheet.Rows[0].R, "1")
+	assert.Equal(t, sheet.Rows[0].Cells[1].Is, "金额")
+
+	// 数值保存为数字，文本保存为内联字符串，空值不写入单元格。
+	row := sheet.Rows[2]
+	assert.Equal(t, len(row.Cells), 4)
+	assert.Equal(t, row.Cells[0].R, "A3")
+	assert.Equal(t, row.Cells[0].T, "")
+	assert.Equal(t, row.Cells[0].V, "2")
+	assert.Equal(t, row.Cells[1].T, "inlineStr")
+	assert.Equal(t, row.Cells[1].Is, "10.00")
+	assert.Equal(t, row.Cells[2].V, "-1")
+	assert.Equal(t, row.Cells[3].R, "D3")
+	assert.Equal(t, row.Cells[3].Is, `say "hi", bye`)
+
+	// 公式不会被执行，不需要转义。
+	assert.Equal(t, sheet.Rows[1].Cells[3].Is, "=1+1")
+	assert.Equal(t, sheet.Rows[1].Cells[4].R, "E2")
+}
+
+func TestExporter_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	batches := 0
+	src := func(ctx context.Context) (interface{}, error) {
+		batches++
+		cancel()
+		return newOrders(), nil
+	}
+	var buf bytes.Buffer
+	n, err := export.New(Order{}).Write(ctx, &buf, export.XLSX, src)
+	assert.Equal(t, err, context.Canceled)
+	assert.Equal(t, n, 2)
+	assert.Equal(t, batches, 1)
+}
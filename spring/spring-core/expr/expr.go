@@ -0,0 +1,452 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package expr 提供了统一的表达式引擎，供条件注册、属性默认值、属性校验等场景使用。
+// 表达式采用 go 语言的表达式语法，并在此基础上支持 ${key:=def} 形式的属性引用以及
+// 使用 $ 表示的当前值。
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Func 表达式中可以调用的函数。
+type Func func(args ...interface{}) (interface{}, error)
+
+// Env 表达式的求值环境。
+type Env struct {
+	Value   interface{}                    // $ 对应的当前值
+	Vars    map[string]interface{}         // 变量
+	Funcs   map[string]Func                // 函数，优先于全局注册的函数
+	Resolve func(s string) (string, error) // 解析 ${key:=def} 属性引用
+}
+
+const (
+	dollar = "_dollar_" // 表达式中 $ 被替换成的变量名
+	prop   = "_prop_"   // 表达式中属性引用被替换成的变量名前缀
+)
+
+var (
+	funcsMutex sync.RWMutex
+	funcs      = map[string]Func{
+		"len":       fnLen,
+		"contains":  stringFunc(strings.Contains),
+		"hasPrefix": stringFunc(strings.HasPrefix),
+		"hasSuffix": stringFunc(strings.HasSuffix),
+		"matches":   fnMatches,
+	}
+)
+
+// RegisterFunc 注册全局的表达式函数，可以和表达式的计算并发执行。
+func RegisterFunc(name string, fn Func) {
+	funcsMutex.Lock()
+	defer funcsMutex.Unlock()
+	funcs[name] = fn
+}
+
+// globalFunc 返回全局注册的表达式函数。
+func globalFunc(name string) (Func, bool) {
+	funcsMutex.RLock()
+	defer funcsMutex.RUnlock()
+	fn, ok := funcs[name]
+	return fn, ok
+}
+
+// EvalBool 计算表达式的值，要求结果必须是 bool 类型。
+func EvalBool(expr string, env Env) (bool, error) {
+	v, err := Eval(expr, env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression %q returns %v, not a bool", expr, v)
+	}
+	return b, nil
+}
+
+// Eval 计算表达式的值，结果只可能是 bool、float64、string 或者 nil 。
+func Eval(expr string, env Env) (interface{}, error) {
+	src, props, err := preprocess(expr, env)
+	if err != nil {
+		return nil, err
+	}
+	node, err := parser.ParseExpr(src)
+	if err != nil {
+		return nil, fmt.Errorf("parse expression %q error: %v", expr, err)
+	}
+	e := &evaluator{env: env, props: props}
+	v, err := e.eval(node)
+	if err != nil {
+		return nil, fmt.Errorf("eval expression %q error: %v", expr, err)
+	}
+	return v, nil
+}
+
+// preprocess 将表达式中的属性引用以及 $ 替换成内部变量。
+func preprocess(expr string, env Env) (string, []interface{}, error) {
+	var (
+		buf   strings.Builder
+		props []interface{}
+		quote byte
+	)
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		if quote != 0 {
+			buf.WriteByte(c)
+			if c == '\\' && quote != '`' && i+1 < len(expr) {
+				i++
+				buf.WriteByte(expr[i])
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+			buf.WriteByte(c)
+		case '$':
+			if i+1 < len(expr) && expr[i+1] == '{' {
+				end := matchBrace(expr, i+1)
+				if end < 0 {
+					return "", nil, fmt.Errorf("expression %q syntax error", expr)
+				}
+				if env.Resolve == nil {
+					return "", nil, fmt.Errorf("expression %q can't resolve property", expr)
+				}
+				s, err := env.Resolve(expr[i : end+1])
+				if err != nil {
+					return "", nil, err
+				}
+				buf.WriteString(prop + strconv.Itoa(len(props)))
+				props = append(props, Literal(s))
+				i = end
+				continue
+			}
+			buf.WriteString(dollar)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String(), props, nil
+}
+
+// matchBrace 返回与 start 位置的 { 匹配的 } 的位置。
+func matchBrace(s string, start int) int {
+	count := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			count++
+		case '}':
+			count--
+			if count == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Literal 推断字符串字面量的类型，依次尝试 bool、float64，否则返回字符串本身。
+func Literal(s string) interface{} {
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+type evaluator struct {
+	env   Env
+	props []interface{}
+}
+
+func (e *evaluator) eval(node ast.Expr) (interface{}, error) {
+	switch n := node.(type) {
+	case *ast.ParenExpr:
+		return e.eval(n.X)
+	case *ast.BasicLit:
+		return evalLit(n)
+	case *ast.Ident:
+		return e.evalIdent(n.Name)
+	case *ast.UnaryExpr:
+		return e.evalUnary(n)
+	case *ast.BinaryExpr:
+		return e.evalBinary(n)
+	case *ast.CallExpr:
+		return e.evalCall(n)
+	}
+	return nil, fmt.Errorf("unsupported expression %T", node)
+}
+
+func evalLit(n *ast.BasicLit) (interface{}, error) {
+	switch n.Kind {
+	case token.INT, token.FLOAT:
+		return strconv.ParseFloat(n.Value, 64)
+	case token.STRING, token.CHAR:
+		return strconv.Unquote(n.Value)
+	}
+	return nil, fmt.Errorf("unsupported literal %s", n.Value)
+}
+
+func (e *evaluator) evalIdent(name string) (interface{}, error) {
+	switch name {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "nil":
+		return nil, nil
+	case dollar:
+		return normalize(e.env.Value), nil
+	}
+	if strings.HasPrefix(name, prop) {
+		i, err := strconv.Atoi(name[len(prop):])
+		if err == nil && i < len(e.props) {
+			return e.props[i], nil
+		}
+	}
+	if v, ok := e.env.Vars[name]; ok {
+		return normalize(v), nil
+	}
+	return nil, fmt.Errorf("undefined: %s", name)
+}
+
+func (e *evaluator) evalUnary(n *ast.UnaryExpr) (interface{}, error) {
+	x, err := e.eval(n.X)
+	if err != nil {
+		return nil, err
+	}
+	switch n.Op {
+	case token.NOT:
+		if b, ok := x.(bool); ok {
+			return !b, nil
+		}
+	case token.SUB:
+		if f, ok := x.(float64); ok {
+			return -f, nil
+		}
+	case token.ADD:
+		if f, ok := x.(float64); ok {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid operation: %s%v", n.Op, x)
+}
+
+func (e *evaluator) evalBinary(n *ast.BinaryExpr) (interface{}, error) {
+
+	x, err := e.eval(n.X)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.Op == token.LAND || n.Op == token.LOR {
+		b, ok := x.(bool)
+		if !ok {
+			return nil, fmt.Errorf("invalid operation: %v %s", x, n.Op)
+		}
+		if (n.Op == token.LAND && !b) || (n.Op == token.LOR && b) {
+			return b, nil
+		}
+		y, err := e.eval(n.Y)
+		if err != nil {
+			return nil, err
+		}
+		if b, ok = y.(bool); !ok {
+			return nil, fmt.Errorf("invalid operation: %s %v", n.Op, y)
+		}
+		return b, nil
+	}
+
+	y, err := e.eval(n.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.Op {
+	case token.EQL:
+		return equal(x, y)
+	case token.NEQ:
+		b, err := equal(x, y)
+		return !b, err
+	}
+
+	switch a := x.(type) {
+	case float64:
+		if b, ok := y.(float64); ok {
+			return numberOp(n.Op, a, b)
+		}
+	case string:
+		if b, ok := y.(string); ok {
+			return stringOp(n.Op, a, b)
+		}
+	}
+	return nil, fmt.Errorf("invalid operation: %v %s %v", x, n.Op, y)
+}
+
+func equal(x, y interface{}) (bool, error) {
+	if x == nil || y == nil {
+		return x == y, nil
+	}
+	if reflect.TypeOf(x) != reflect.TypeOf(y) {
+		return false, fmt.Errorf("mismatched types: %v == %v", x, y)
+	}
+	return x == y, nil
+}
+
+func numberOp(op token.Token, a, b float64) (interface{}, error) {
+	switch op {
+	case token.ADD:
+		return a + b, nil
+	case token.SUB:
+		return a - b, nil
+	case token.MUL:
+		return a * b, nil
+	case token.QUO:
+		if b == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return a / b, nil
+	case token.REM:
+		if b == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return float64(int64(a) % int64(b)), nil
+	case token.LSS:
+		return a < b, nil
+	case token.LEQ:
+		return a <= b, nil
+	case token.GTR:
+		return a > b, nil
+	case token.GEQ:
+		return a >= b, nil
+	}
+	return nil, fmt.Errorf("invalid operation: %v %s %v", a, op, b)
+}
+
+func stringOp(op token.Token, a, b string) (interface{}, error) {
+	switch op {
+	case token.ADD:
+		return a + b, nil
+	case token.LSS:
+		return a < b, nil
+	case token.LEQ:
+		return a <= b, nil
+	case token.GTR:
+		return a > b, nil
+	case token.GEQ:
+		return a >= b, nil
+	}
+	return nil, fmt.Errorf("invalid operation: %q %s %q", a, op, b)
+}
+
+func (e *evaluator) evalCall(n *ast.CallExpr) (interface{}, error) {
+	ident, ok := n.Fun.(*ast.Ident)
+	if !ok {
+		return nil, fmt.Errorf("unsupported function call %T", n.Fun)
+	}
+	fn, ok := e.env.Funcs[ident.Name]
+	if !ok {
+		if fn, ok = globalFunc(ident.Name); !ok {
+			return nil, fmt.Errorf("undefined function: %s", ident.Name)
+		}
+	}
+	args := make([]interface{}, len(n.Args))
+	for i, arg := range n.Args {
+		v, err := e.eval(arg)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	v, err := fn(args...)
+	if err != nil {
+		return nil, err
+	}
+	return normalize(v), nil
+}
+
+// normalize 将数值类型统一转换为 float64 。
+func normalize(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.String:
+		return rv.String()
+	}
+	return v
+}
+
+func fnLen(args ...interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, errors.New("len requires 1 argument")
+	}
+	switch v := reflect.ValueOf(args[0]); v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len(), nil
+	}
+	return nil, fmt.Errorf("invalid argument %v for len", args[0])
+}
+
+func stringFunc(fn func(s, t string) bool) Func {
+	return func(args ...interface{}) (interface{}, error) {
+		if len(args) != 2 {
+			return nil, errors.New("requires 2 string arguments")
+		}
+		s, ok1 := args[0].(string)
+		t, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return nil, errors.New("requires 2 string arguments")
+		}
+		return fn(s, t), nil
+	}
+}
+
+func fnMatches(args ...interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, errors.New("matches requires 2 string arguments")
+	}
+	s, ok1 := args[0].(string)
+	pattern, ok2 := args[1].(string)
+	if !ok1 || !ok2 {
+		return nil, errors.New("matches requires 2 string arguments")
+	}
+	return regexp.MatchString(pattern, s)
+}
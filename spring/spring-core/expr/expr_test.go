@@ -0,0 +1,76 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package expr_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/expr"
+)
+
+func TestEval(t *testing.T) {
+
+	env := expr.Env{
+		Value: 3,
+		Vars:  map[string]interface{}{"name": "go-spring"},
+		Resolve: func(s string) (string, error) {
+			if s == "${port}" {
+				return "8080", nil
+			}
+			return "", errors.New("not exist")
+		},
+	}
+
+	testcases := []struct {
+		expr   string
+		expect interface{}
+	}{
+		{"1 + 2 * 3", float64(7)},
+		{"$ > 1 && $ < 5", true},
+		{"!($ == 3)", false},
+		{`name + "!"`, "go-spring!"},
+		{`"$" + name`, "$go-spring"},
+		{"${port} >= 1024", true},
+		{`len(name) == 9`, true},
+		{`matches(name, "^go-")`, true},
+		{"nil == nil", true},
+	}
+
+	for _, c := range testcases {
+		v, err := expr.Eval(c.expr, env)
+		assert.Nil(t, err)
+		assert.Equal(t, v, c.expect)
+	}
+
+	_, err := expr.Eval("${host} == 1", env)
+	assert.Error(t, err, "not exist")
+
+	_, err = expr.Eval(`$ == "3"`, env)
+	assert.Error(t, err, "mismatched types")
+
+	_, err = expr.EvalBool("1 + 1", env)
+	assert.Error(t, err, "not a bool")
+
+	expr.RegisterFunc("double", func(args ...interface{}) (interface{}, error) {
+		return args[0].(float64) * 2, nil
+	})
+	ok, err := expr.EvalBool("double($) == 6", env)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
@@ -0,0 +1,401 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package fixture 在开发和测试环境启动时向数据源加载种子数据。每个 fixture 对应一个
+// SQL、YAML 或者 JSON 文件，按照 order 从小到大依次加载，例如：
+//
+//	fixtures.items[0].file=fixtures/schema.sql
+//	fixtures.items[1].file=fixtures/users.yaml
+//	fixtures.items[1].order=1
+//	fixtures.items[1].profiles=test
+//
+// YAML 和 JSON 文件的顶层为表名，值为该表的行列表，按照文件中的顺序插入：
+//
+//	users:
+//	  - id: 1
+//	    name: jim
+//
+// 加载成功的 fixture 的名称和内容摘要记录在数据源的标记表中，再次启动时跳过已经加载
+// 的 fixture ，因此数据不会被重复插入。
+package fixture
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/expr"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/internal/sqlutil"
+	"gopkg.in/yaml.v2"
+)
+
+var logger = log.GetLogger("GS_FIXTURE")
+
+// Config fixture 加载的配置。
+type Config struct {
+	Table       string          `value:"${table:=fixtures}"` // 记录已加载 fixture 的标记表
+	Placeholder string          `value:"${placeholder:=?}"`  // 参数占位符，PostgreSQL 使用 $
+	Items       []FixtureConfig `value:"${items:=}"`
+}
+
+// FixtureConfig 单个 fixture 的配置。
+type FixtureConfig struct {
+	Name       string   `value:"${name:=}"`       // 默认为不带扩展名的文件名
+	File       string   `value:"${file}"`         // .sql、.yaml、.yml 或者 .json 文件
+	DataSource string   `value:"${datasource:=}"` // 只有一个数据源时可以省略
+	Order      int      `value:"${order:=0}"`
+	Profiles   []string `value:"${profiles:=}"`    // 只在这些 profile 下加载，为空时不限制
+	Condition  string   `value:"${condition:=}"`   // 加载条件表达式，属性引用在绑定时已被替换
+	Always     bool     `value:"${always:=false}"` // 每次启动都重新加载，不检查标记
+}
+
+// DataSource 可以加载 fixture 的数据源。
+type DataSource struct {
+	Name string
+	DB   *sql.DB
+}
+
+// NewDataSource 创建名为 name 的数据源。
+func NewDataSource(name string, db *sql.DB) *DataSource {
+	return &DataSource{Name: name, DB: db}
+}
+
+// Loader 按照配置加载 fixture 。
+type Loader struct {
+	config      Config
+	dataSources []*DataSource
+}
+
+// NewLoader 创建 fixture 加载器。
+func NewLoader(config Config, dataSources []*DataSource) *Loader {
+	return &Loader{config: config, dataSources: dataSources}
+}
+
+// OnInit 按照当前激活的 profile 加载 fixture ，加载失败时应用启动失败。
+func (l *Loader) OnInit(ctx gs.Context) error {
+	var profiles []string
+	for _, s := range strings.Split(ctx.Prop("spring.profiles.active"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			profiles = append(profiles, s)
+		}
+	}
+	return l.Load(ctx.Context(), profiles)
+}
+
+// Load 按照 order 依次加载在 profiles 下生效的 fixture 。
+func (l *Loader) Load(ctx context.Context, profiles []string) error {
+	items := make([]FixtureConfig, len(l.config.Items))
+	copy(items, l.config.Items)
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Order < items[j].Order
+	})
+	prepared := make(map[*sql.DB]bool)
+	for _, item := range items {
+		name := item.Name
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(item.File), filepath.Ext(item.File))
+		}
+		ok, err := l.enabled(item, profiles)
+		if err != nil {
+			return fmt.Errorf("fixture %s: %w", name, err)
+		}
+		if !ok {
+			logger.Infof("fixture %s skipped", name)
+			continue
+		}
+		ds, err := l.dataSource(item.DataSource)
+		if err != nil {
+			return fmt.Errorf("fixture %s: %w", name, err)
+		}
+		if !prepared[ds.DB] {
+			if err = l.createTable(ctx, ds.DB); err != nil {
+				return fmt.Errorf("fixture %s: create table %s error: %w", name, l.config.Table, err)
+			}
+			prepared[ds.DB] = true
+		}
+		if err = l.load(ctx, ds.DB, name, item); err != nil {
+			return fmt.Errorf("fixture %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// enabled 返回 fixture 在 profiles 下是否满足加载条件。
+func (l *Loader) enabled(item FixtureConfig, profiles []string) (bool, error) {
+	if len(item.Profiles) > 0 && !intersects(item.Profiles, profiles) {
+		return false, nil
+	}
+	if item.Condition == "" {
+		return true, nil
+	}
+	return expr.EvalBool(item.Condition, expr.Env{})
+}
+
+func intersects(a, b []string) bool {
+	for _, s := range a {
+		for _, t := range b {
+			if s == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dataSource 返回名为 name 的数据源，name 为空时要求只有一个数据源。
+func (l *Loader) dataSource(name string) (*DataSource, error) {
+	if name == "" {
+		if len(l.dataSources) != 1 {
+			return nil, fmt.Errorf("datasource must be specified, found %d", len(l.dataSources))
+		}
+		return l.dataSources[0], nil
+	}
+	for _, ds := range l.dataSources {
+		if ds.Name == name {
+			return ds, nil
+		}
+	}
+	return nil, fmt.Errorf("datasource %s not found", name)
+}
+
+// bind 将 SQL 语句中的 ? 替换为配置的占位符。
+func (l *Loader) bind(query string) string {
+	return sqlutil.Bind(query, l.config.Placeholder)
+}
+
+func (l *Loader) createTable(ctx context.Context, db *sql.DB) error {
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (name VARCHAR(255) PRIMARY KEY, checksum VARCHAR(64) NOT NULL, loaded_at BIGINT NOT NULL)", l.config.Table)
+	_, err := db.ExecContext(ctx, query)
+	return err
+}
+
+// load 在一个事务中执行 fixture 的语句并更新标记表。
+func (l *Loader) load(ctx context.Context, db *sql.DB, name string, item FixtureConfig) error {
+
+	data, err := ioutil.ReadFile(item.File)
+	if err != nil {
+		return err
+	}
+	statements, err := parse(item.File, data)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	var loaded string
+	query := fmt.Sprintf("SELECT checksum FROM %s WHERE name = ?", l.config.Table)
+	err = db.QueryRowContext(ctx, l.bind(query), name).Scan(&loaded)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	exists := err == nil
+	if exists && !item.Always {
+		if loaded != checksum {
+			logger.Warnf("fixture %s has changed since it was loaded, remove it from %s to reload", name, l.config.Table)
+		}
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	for _, s := range statements {
+		q := s.query
+		if len(s.args) > 0 {
+			q = l.bind(q)
+		}
+		if _, err = tx.ExecContext(ctx, q, s.args...); err != nil {
+			return fmt.Errorf("exec %q error: %w", s.query, err)
+		}
+	}
+	if exists {
+		query = fmt.Sprintf("UPDATE %s SET checksum = ?, loaded_at = ? WHERE name = ?", l.config.Table)
+		_, err = tx.ExecContext(ctx, l.bind(query), checksum, time.Now().Unix(), name)
+	} else {
+		query = fmt.Sprintf("INSERT INTO %s (name, checksum, loaded_at) VALUES (?, ?, ?)", l.config.Table)
+		_, err = tx.ExecContext(ctx, l.bind(query), name, checksum, time.Now().Unix())
+	}
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	logger.Infof("fixture %s loaded, %d statements", name, len(statements))
+	return nil
+}
+
+type statement struct {
+	query string
+	args  []interface{}
+}
+
+// parse 按照文件扩展名解析出需要执行的语句。
+func parse(file string, data []byte) ([]statement, error) {
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".sql":
+		var statements []statement
+		for _, s := range splitSQL(string(data)) {
+			statements = append(statements, statement{query: s})
+		}
+		return statements, nil
+	case ".yaml", ".yml", ".json":
+		return parseRows(data)
+	default:
+		return nil, fmt.Errorf("unsupported fixture file %s", file)
+	}
+}
+
+// parseRows 将 YAML 或者 JSON 格式的表数据转换成 INSERT 语句，JSON 是 YAML 的子集，
+// 因此统一使用 YAML 解析，同时保持表和列的顺序。
+func parseRows(data []byte) ([]statement, error) {
+	var tables yaml.MapSlice
+	if err := yaml.Unmarshal(data, &tables); err != nil {
+		return nil, err
+	}
+	var statements []statement
+	for _, t := range tables {
+		table := fmt.Sprint(t.Key)
+		rows, ok := t.Value.([]interface{})
+		if !ok && t.Value != nil {
+			return nil, fmt.Errorf("table %s should be a list of rows", table)
+		}
+		for i, r := range rows {
+			row, ok := r.(yaml.MapSlice)
+			if !ok || len(row) == 0 {
+				return nil, fmt.Errorf("row %d of table %s should be a non-empty map", i, table)
+			}
+			columns := make([]string, len(row))
+			marks := make([]string, len(row))
+			args := make([]interface{}, len(row))
+			for j, c := range row {
+				v, err := column(c.Value)
+				if err != nil {
+					return nil, err
+				}
+				columns[j] = fmt.Sprint(c.Key)
+				marks[j] = "?"
+				args[j] = v
+			}
+			query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), strings.Join(marks, ", "))
+			statements = append(statements, statement{query: query, args: args})
+		}
+	}
+	return statements, nil
+}
+
+// column 返回列的值，嵌套的对象和列表序列化为 JSON 字符串。
+func column(v interface{}) (interface{}, error) {
+	switch v.(type) {
+	case yaml.MapSlice, []interface{}:
+		b, err := json.Marshal(plain(v))
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	default:
+		return v, nil
+	}
+}
+
+func plain(v interface{}) interface{} {
+	switch r := v.(type) {
+	case yaml.MapSlice:
+		m := make(map[string]interface{}, len(r))
+		for _, item := range r {
+			m[fmt.Sprint(item.Key)] = plain(item.Value)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(r))
+		for i, item := range r {
+			s[i] = plain(item)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// splitSQL 按照分号拆分 SQL 脚本，忽略注释以及字符串和标识符中的分号。
+func splitSQL(script string) []string {
+	var (
+		statements []string
+		buf        strings.Builder
+		quote      byte
+	)
+	flush := func() {
+		if s := strings.TrimSpace(buf.String()); s != "" {
+			statements = append(statements, s)
+		}
+		buf.Reset()
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		if quote != 0 {
+			buf.WriteByte(c)
+			if c == '\\' && quote != '`' && i+1 < len(script) {
+				i++
+				buf.WriteByte(script[i])
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			buf.WriteByte(c)
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			buf.WriteByte('\n')
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 3
+			}
+			buf.WriteByte(' ')
+		case c == ';':
+			flush()
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	flush()
+	return statements
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fixture_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/fixture"
+)
+
+const (
+	createTable = "CREATE TABLE IF NOT EXISTS fixtures (name VARCHAR(255) PRIMARY KEY, checksum VARCHAR(64) NOT NULL, loaded_at BIGINT NOT NULL)"
+	selectMark  = "SELECT checksum FROM fixtures WHERE name = ?"
+	insertMark  = "INSERT INTO fixtures (name, checksum, loaded_at) VALUES (?, ?, ?)"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	file := filepath.Join(dir, name)
+	err := ioutil.WriteFile(file, []byte(content), os.ModePerm)
+	assert.Nil(t, err)
+	return file
+}
+
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestLoader(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "fixture")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	schema := `
+		-- 用户表
+		CREATE TABLE users (id INT, name VARCHAR(32), tags TEXT);
+		/* 默认数据; 注释中的分号被忽略 */
+		INSERT INTO users VALUES (0, 'a;b', NULL);
+	`
+	users := `
+users:
+  - id: 1
+    name: jim
+    tags: [a, b]
+  - name: tom
+    id: 2
+`
+	orders := `{"orders": [{"id": 1, "user_id": 1}]}`
+
+	config := fixture.Config{
+		Table:       "fixtures",
+		Placeholder: "?",
+		Items: []fixture.FixtureConfig{
+			{File: writeFile(t, dir, "users.yaml", users), Order: 1},
+			{File: writeFile(t, dir, "schema.sql", schema)},
+			{File: writeFile(t, dir, "orders.json", orders), Order: 2},
+			{File: writeFile(t, dir, "demo.sql", "DELETE FROM users"), Order: 3, Profiles: []string{"test"}},
+			{File: writeFile(t, dir, "cond.sql", "DELETE FROM users"), Order: 3, Condition: "1 > 2"},
+		},
+	}
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.Nil(t, err)
+
+	mock.ExpectExec(createTable).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	mock.ExpectQuery(selectMark).WithArgs("schema").WillReturnRows(sqlmock.NewRows([]string{"checksum"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE users (id INT, name VARCHAR(32), tags TEXT)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO users VALUES (0, 'a;b', NULL)").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(insertMark).WithArgs("schema", checksum(schema), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectQuery(selectMark).WithArgs("users").WillReturnRows(sqlmock.NewRows([]string{"checksum"}).AddRow(checksum(users)))
+
+	mock.ExpectQuery(selectMark).WithArgs("orders").WillReturnRows(sqlmock.NewRows([]string{"checksum"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO orders (id, user_id) VALUES (?, ?)").WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(insertMark).WithArgs("orders", checksum(orders), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	l := fixture.NewLoader(config, []*fixture.DataSource{fixture.NewDataSource("db", db)})
+	err = l.Load(context.Background(), []string{"dev"})
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+
+	t.Run("rows", func(t *testing.T) {
+		config.Items = []fixture.FixtureConfig{{Name: "seed", File: config.Items[0].File, DataSource: "db", Always: true}}
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		assert.Nil(t, err)
+		mock.ExpectExec(createTable).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(selectMark).WithArgs("seed").WillReturnRows(sqlmock.NewRows([]string{"checksum"}).AddRow("old"))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO users (id, name, tags) VALUES (?, ?, ?)").WithArgs(1, "jim", `["a","b"]`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO users (name, id) VALUES (?, ?)").WithArgs("tom", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE fixtures SET checksum = ?, loaded_at = ? WHERE name = ?").WithArgs(checksum(users), sqlmock.AnyArg(), "seed").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		l := fixture.NewLoader(config, []*fixture.DataSource{fixture.NewDataSource("db", db)})
+		err = l.Load(context.Background(), []string{"test"})
+		assert.Nil(t, err)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		config.Items = []fixture.FixtureConfig{{File: writeFile(t, dir, "bad.sql", "INSERT INTO users VALUES (1); BAD")}}
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		assert.Nil(t, err)
+		mock.ExpectExec(createTable).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(selectMark).WithArgs("bad").WillReturnRows(sqlmock.NewRows([]string{"checksum"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO users VALUES (1)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("BAD").WillReturnError(os.ErrInvalid)
+		mock.ExpectRollback()
+		l := fixture.NewLoader(config, []*fixture.DataSource{fixture.NewDataSource("db", db)})
+		err = l.Load(context.Background(), nil)
+		assert.Error(t, err, "fixture bad: exec \"BAD\" error: invalid argument")
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("datasource", func(t *testing.T) {
+		config.Items = []fixture.FixtureConfig{{File: "users.yaml"}}
+		l := fixture.NewLoader(config, nil)
+		err = l.Load(context.Background(), nil)
+		assert.Error(t, err, "fixture users: datasource must be specified, found 0")
+		config.Items[0].DataSource = "other"
+		l = fixture.NewLoader(config, []*fixture.DataSource{fixture.NewDataSource("db", nil)})
+		err = l.Load(context.Background(), nil)
+		assert.Error(t, err, "fixture users: datasource other not found")
+	})
+}
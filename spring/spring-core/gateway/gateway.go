@@ -0,0 +1,552 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package gateway 实现轻量级的 API 网关，根据配置的路由规则将请求转发到上游服务。
+package gateway
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/expr"
+	"github.com/go-spring/spring-core/web"
+)
+
+var logger = log.GetLogger("GS_GATEWAY")
+
+const (
+	SchemeLoadBalance = "lb"      // 形如 lb://user-service 的上游地址通过服务发现获取实例列表
+	SchemeForward     = "forward" // 形如 forward:/v2 的地址表示修改请求路径后交给应用自身的处理器
+)
+
+// 负载均衡策略。
+const (
+	RoundRobin = "round-robin"
+	Random     = "random"
+)
+
+// ErrNoInstance 服务发现没有返回可用的实例。
+var ErrNoInstance = errors.New("no available instance")
+
+// Discovery 服务发现接口，返回服务的实例地址列表，如 http://127.0.0.1:8080 。
+type Discovery interface {
+	Instances(service string) ([]string, error)
+}
+
+type ServiceConfig struct {
+	Name      string   `value:"${name}"`
+	Instances []string `value:"${instances}"`
+}
+
+// StaticDiscovery 使用配置文件中的实例列表实现的服务发现。
+type StaticDiscovery struct {
+	Services []ServiceConfig `value:"${gateway.services:=}"`
+}
+
+func (d *StaticDiscovery) Instances(service string) ([]string, error) {
+	for _, s := range d.Services {
+		if s.Name == service {
+			return s.Instances, nil
+		}
+	}
+	return nil, fmt.Errorf("service %q not found", service)
+}
+
+type RouteConfig struct {
+	ID                    string            `value:"${id:=}"`
+	URI                   string            `value:"${uri}"`  // 上游地址，如 http://127.0.0.1:8080 或者 lb://user-service
+	Path                  string            `value:"${path}"` // 精确匹配，或者以 /* 结尾进行前缀匹配
+	Methods               []string          `value:"${methods:=}"`
+	Headers               map[string]string `value:"${headers:=}"`   // 请求头需要匹配的正则表达式
+	Cookies               map[string]string `value:"${cookies:=}"`   // Cookie 需要匹配的正则表达式
+	Predicate             string            `value:"${predicate:=}"` // 额外匹配条件的 expr 表达式，如 query("beta") == "1"
+	WeightGroup           string            `value:"${weight-group:=}"`
+	Weight                int               `value:"${weight:=0}"`       // 同组路由按照权重分配流量，为 0 时不分配
+	StripPrefix           int               `value:"${strip-prefix:=0}"` // 转发前去掉的路径段数
+	RewriteRegexp         string            `value:"${rewrite.regexp:=}"`
+	RewriteReplacement    string            `value:"${rewrite.replacement:=}"` // 使用 $1 形式引用分组
+	AddRequestHeaders     map[string]string `value:"${add-request-headers:=}"`
+	RemoveRequestHeaders  []string          `value:"${remove-request-headers:=}"`
+	AddResponseHeaders    map[string]string `value:"${add-response-headers:=}"`
+	RemoveResponseHeaders []string          `value:"${remove-response-headers:=}"`
+	Retries               int               `value:"${retries:=0}"` // 幂等请求失败后的重试次数
+	RetryStatuses         []int             `value:"${retry-statuses:=502,503,504}"`
+	MaxRetryBody          conf.ByteSize     `value:"${max-retry-body:=1MB}"` // 需要重试时缓存的请求体的上限，超过时返回 413
+	LoadBalancer          string            `value:"${load-balancer:=round-robin}"`
+}
+
+type Config struct {
+	Routes []RouteConfig `value:"${routes:=}"`
+}
+
+type route struct {
+	config  RouteConfig
+	target  *url.URL
+	service string // 非空时表示需要通过服务发现获取实例
+	prefix  string
+	rewrite *regexp.Regexp
+	headers map[string]*regexp.Regexp
+	cookies map[string]*regexp.Regexp
+	counter uint32
+}
+
+func newRoute(config RouteConfig) (*route, error) {
+	target, err := url.Parse(config.URI)
+	if err != nil {
+		return nil, err
+	}
+	r := &route{config: config, target: target}
+	if target.Scheme == SchemeLoadBalance {
+		r.service = target.Host
+	}
+	if strings.HasSuffix(config.Path, "/*") {
+		r.prefix = strings.TrimSuffix(config.Path, "*")
+	}
+	if config.RewriteRegexp != "" {
+		if r.rewrite, err = regexp.Compile(config.RewriteRegexp); err != nil {
+			return nil, err
+		}
+	}
+	if r.headers, err = compileMap(config.Headers); err != nil {
+		return nil, err
+	}
+	if r.cookies, err = compileMap(config.Cookies); err != nil {
+		return nil, err
+	}
+	if config.Predicate != "" {
+		// 使用空的请求检查表达式的语法和类型错误。
+		req := &http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/"}, Header: http.Header{}}
+		if _, err = expr.EvalBool(config.Predicate, requestEnv(req)); err != nil {
+			return nil, fmt.Errorf("invalid predicate: %w", err)
+		}
+	}
+	if config.Weight < 0 {
+		return nil, fmt.Errorf("invalid weight %d", config.Weight)
+	}
+	switch config.LoadBalancer {
+	case RoundRobin, Random:
+	default:
+		return nil, fmt.Errorf("unsupported load balancer %q", config.LoadBalancer)
+	}
+	return r, nil
+}
+
+func compileMap(m map[string]string) (map[string]*regexp.Regexp, error) {
+	ret := make(map[string]*regexp.Regexp)
+	for k, v := range m {
+		exp, err := regexp.Compile(v)
+		if err != nil {
+			return nil, err
+		}
+		ret[k] = exp
+	}
+	return ret, nil
+}
+
+func (r *route) match(req *http.Request) bool {
+	if len(r.config.Methods) > 0 {
+		found := false
+		for _, m := range r.config.Methods {
+			if strings.EqualFold(m, req.Method) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for k, exp := range r.headers {
+		if !exp.MatchString(req.Header.Get(k)) {
+			return false
+		}
+	}
+	for k, exp := range r.cookies {
+		c, err := req.Cookie(k)
+		if err != nil || !exp.MatchString(c.Value) {
+			return false
+		}
+	}
+	path := req.URL.Path
+	if r.prefix == "" {
+		if path != r.config.Path {
+			return false
+		}
+	} else if path+"/" != r.prefix && !strings.HasPrefix(path, r.prefix) {
+		return false
+	}
+	if r.config.Predicate == "" {
+		return true
+	}
+	ok, err := expr.EvalBool(r.config.Predicate, requestEnv(req))
+	if err != nil {
+		logger.WithContext(req.Context()).Errorf(log.ERROR, "route %q predicate: %v", r.config.ID, err)
+		return false
+	}
+	return ok
+}
+
+// requestEnv 返回路由条件表达式的求值环境，变量 method 、path 、host 分别是请求方法、
+// 路径和主机名，函数 header(name) 、cookie(name) 、query(name) 返回对应的值，不存在
+// 时返回空字符串，例如：
+//
+//	gateway.routes[0].predicate=method == "GET" && query("beta") == "1"
+func requestEnv(req *http.Request) expr.Env {
+	return expr.Env{
+		Vars: map[string]interface{}{
+			"method": req.Method,
+			"path":   req.URL.Path,
+			"host":   req.Host,
+		},
+		Funcs: map[string]expr.Func{
+			"header": requestFunc(func(name string) string {
+				return req.Header.Get(name)
+			}),
+			"cookie": requestFunc(func(name string) string {
+				if c, err := req.Cookie(name); err == nil {
+					return c.Value
+				}
+				return ""
+			}),
+			"query": requestFunc(func(name string) string {
+				return req.URL.Query().Get(name)
+			}),
+		},
+	}
+}
+
+func requestFunc(fn func(name string) string) expr.Func {
+	return func(args ...interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, errors.New("need one argument")
+		}
+		name, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("argument %v is not a string", args[0])
+		}
+		return fn(name), nil
+	}
+}
+
+// rewritePath 依次执行 StripPrefix 和正则重写。
+func (r *route) rewritePath(path string) string {
+	if n := r.config.StripPrefix; n > 0 {
+		ss := strings.Split(strings.TrimPrefix(path, "/"), "/")
+		if n >= len(ss) {
+			path = "/"
+		} else {
+			path = "/" + strings.Join(ss[n:], "/")
+		}
+	}
+	if r.rewrite != nil {
+		path = r.rewrite.ReplaceAllString(path, r.config.RewriteReplacement)
+	}
+	return path
+}
+
+// instances 返回可用的上游实例，使用服务发现时按照负载均衡策略确定起始实例。
+func (r *route) instances(discovery Discovery) ([]*url.URL, error) {
+	if r.service == "" {
+		return []*url.URL{r.target}, nil
+	}
+	if discovery == nil {
+		return nil, errors.New("discovery not configured")
+	}
+	ss, err := discovery.Instances(r.service)
+	if err != nil {
+		return nil, err
+	}
+	if len(ss) == 0 {
+		return nil, ErrNoInstance
+	}
+	var start int
+	if r.config.LoadBalancer == Random {
+		start = rand.Intn(len(ss))
+	} else {
+		start = int(atomic.AddUint32(&r.counter, 1)-1) % len(ss)
+	}
+	ret := make([]*url.URL, 0, len(ss))
+	for i := range ss {
+		u, err := url.Parse(ss[(start+i)%len(ss)])
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, u)
+	}
+	return ret, nil
+}
+
+func (r *route) retryable(method string, status int) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	if status == 0 {
+		return true
+	}
+	for _, s := range r.config.RetryStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// hopHeaders 逐跳首部，不能转发给上游服务或者客户端。
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+func copyHeader(dst, src http.Header) {
+	for k, vv := range src {
+		for _, v := range vv {
+			dst.Add(k, v)
+		}
+	}
+	for _, h := range hopHeaders {
+		dst.Del(h)
+	}
+}
+
+type routeTable struct {
+	routes  []*route
+	weights map[string][]*route // 权重分组
+}
+
+func newRouteTable(config Config) (*routeTable, error) {
+	t := &routeTable{weights: make(map[string][]*route)}
+	for _, c := range config.Routes {
+		r, err := newRoute(c)
+		if err != nil {
+			return nil, fmt.Errorf("gateway route %q error: %w", c.ID, err)
+		}
+		t.routes = append(t.routes, r)
+		if c.WeightGroup != "" {
+			t.weights[c.WeightGroup] = append(t.weights[c.WeightGroup], r)
+		}
+	}
+	return t, nil
+}
+
+// choose 按照权重从分组中选择一条路由，所有路由的权重都为 0 时返回 nil 。
+func (t *routeTable) choose(group string) *route {
+	total := 0
+	for _, r := range t.weights[group] {
+		total += r.config.Weight
+	}
+	if total == 0 {
+		return nil
+	}
+	n := rand.Intn(total)
+	for _, r := range t.weights[group] {
+		if n < r.config.Weight {
+			return r
+		}
+		n -= r.config.Weight
+	}
+	return nil
+}
+
+// Gateway 根据路由规则将请求转发到上游服务，没有匹配的路由时交给后续的过滤器处理。
+type Gateway struct {
+	table     atomic.Value
+	discovery Discovery
+	Transport http.RoundTripper
+}
+
+// New 创建网关，discovery 可以为空，此时不支持 lb:// 形式的上游地址。
+func New(config Config, discovery Discovery) (*Gateway, error) {
+	g := &Gateway{discovery: discovery, Transport: http.DefaultTransport}
+	if err := g.Reload(config); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Reload 在运行时替换路由规则，例如调整灰度路由的权重，正在处理的请求不受影响。
+func (g *Gateway) Reload(config Config) error {
+	t, err := newRouteTable(config)
+	if err != nil {
+		return err
+	}
+	g.table.Store(t)
+	return nil
+}
+
+// NewPrefilter 将网关封装为前置过滤器，在路由决策之前完成请求的转发。
+func NewPrefilter(g *Gateway) *web.Prefilter {
+	return web.NewPrefilter(g)
+}
+
+func (g *Gateway) Invoke(ctx web.Context, chain web.FilterChain) {
+	t := g.table.Load().(*routeTable)
+	req := ctx.Request()
+	var chosen map[string]*route
+	for _, r := range t.routes {
+		if !r.match(req) {
+			continue
+		}
+		// 同一个请求在每个权重分组中只选择一次。
+		if group := r.config.WeightGroup; group != "" {
+			if chosen == nil {
+				chosen = make(map[string]*route)
+			}
+			c, ok := chosen[group]
+			if !ok {
+				c = t.choose(group)
+				chosen[group] = c
+			}
+			if c != r {
+				continue
+			}
+		}
+		if r.target.Scheme == SchemeForward {
+			req.URL.Path = strings.TrimSuffix(r.target.Path, "/") + r.rewritePath(req.URL.Path)
+			req.URL.RawPath = ""
+			chain.Continue(ctx)
+			return
+		}
+		g.forward(ctx, r)
+		return
+	}
+	chain.Continue(ctx)
+}
+
+func (g *Gateway) forward(ctx web.Context, r *route) {
+
+	req := ctx.Request()
+	targets, err := r.instances(g.discovery)
+	if err != nil {
+		logger.WithContext(ctx.Context()).Errorf(log.ERROR, "route %q: %v", r.config.ID, err)
+		ctx.SetStatus(http.StatusServiceUnavailable)
+		ctx.String(http.StatusText(http.StatusServiceUnavailable))
+		return
+	}
+
+	// 可以重试的请求需要缓存请求体，非幂等的请求不会重试，直接转发请求体。
+	var body []byte
+	if req.Body != nil && r.config.Retries > 0 && r.retryable(req.Method, 0) {
+		max := int64(r.config.MaxRetryBody)
+		reader := http.MaxBytesReader(ctx.ResponseWriter(), req.Body, max)
+		if body, err = ioutil.ReadAll(reader); err != nil {
+			if int64(len(body)) >= max {
+				ctx.SetStatus(http.StatusRequestEntityTooLarge)
+				ctx.String(http.StatusText(http.StatusRequestEntityTooLarge))
+				return
+			}
+			ctx.SetStatus(http.StatusBadRequest)
+			ctx.String(err.Error())
+			return
+		}
+	}
+
+	path := r.rewritePath(req.URL.Path)
+	var resp *http.Response
+	for i := 0; i <= r.config.Retries; i++ {
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		target := targets[i%len(targets)]
+		resp, err = g.roundTrip(ctx, r, target, path, body)
+		status := 0 // 0 表示网络错误
+		if err == nil {
+			status = resp.StatusCode
+		}
+		if !r.retryable(req.Method, status) {
+			break
+		}
+	}
+
+	if err != nil {
+		logger.WithContext(ctx.Context()).Errorf(log.ERROR, "route %q: %v", r.config.ID, err)
+		ctx.SetStatus(http.StatusBadGateway)
+		ctx.String(http.StatusText(http.StatusBadGateway))
+		return
+	}
+	defer resp.Body.Close()
+
+	w := ctx.ResponseWriter()
+	copyHeader(w.Header(), resp.Header)
+	for _, h := range r.config.RemoveResponseHeaders {
+		w.Header().Del(h)
+	}
+	for k, v := range r.config.AddResponseHeaders {
+		w.Header().Set(k, v)
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err = io.Copy(w, resp.Body); err != nil {
+		logger.WithContext(ctx.Context()).Errorf(log.ERROR, "route %q: %v", r.config.ID, err)
+	}
+}
+
+func (g *Gateway) roundTrip(ctx web.Context, r *route, target *url.URL, path string, body []byte) (*http.Response, error) {
+
+	req := ctx.Request()
+	u := *req.URL
+	u.Scheme = target.Scheme
+	u.Host = target.Host
+	u.Path = strings.TrimSuffix(target.Path, "/") + path
+	u.RawPath = ""
+
+	var reader io.Reader = req.Body
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	out, err := http.NewRequestWithContext(ctx.Context(), req.Method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		out.ContentLength = req.ContentLength
+	}
+
+	copyHeader(out.Header, req.Header)
+	for _, h := range r.config.RemoveRequestHeaders {
+		out.Header.Del(h)
+	}
+	for k, v := range r.config.AddRequestHeaders {
+		out.Header.Set(k, v)
+	}
+
+	clientIP := ctx.ClientIP()
+	if prior := req.Header.Get(web.HeaderXForwardedFor); prior != "" {
+		clientIP = prior + ", " + clientIP
+	}
+	out.Header.Set(web.HeaderXForwardedFor, clientIP)
+	out.Header.Set("X-Forwarded-Host", req.Host)
+	out.Header.Set(web.HeaderXForwardedProto, ctx.Scheme())
+	return g.Transport.RoundTrip(out)
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gateway_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/gateway"
+	"github.com/go-spring/spring-core/web"
+)
+
+func TestGateway(t *testing.T) {
+
+	var failures int
+	newUpstream := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/flaky" && failures > 0 {
+				failures--
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("X-Internal", "1")
+			w.Header().Set("X-Upstream", name)
+			_, _ = fmt.Fprintf(w, "%s %s %s %s", name, r.URL.RequestURI(), r.Header.Get("X-Gateway"), r.Header.Get("Cookie"))
+		}))
+	}
+	s1, s2 := newUpstream("s1"), newUpstream("s2")
+	defer s1.Close()
+	defer s2.Close()
+
+	p, err := conf.Bytes([]byte(`
+gateway.services[0].name=user-service
+gateway.services[0].instances=`+s1.URL+`,`+s2.URL+`
+gateway.routes[0].id=users
+gateway.routes[0].uri=lb://user-service
+gateway.routes[0].path=/api/users/*
+gateway.routes[0].strip-prefix=1
+gateway.routes[0].add-request-headers.X-Gateway=go-spring
+gateway.routes[0].remove-request-headers=Cookie
+gateway.routes[0].remove-response-headers=X-Internal
+gateway.routes[1].id=orders
+gateway.routes[1].uri=`+s1.URL+`/v2
+gateway.routes[1].path=/orders/*
+gateway.routes[1].methods=GET
+gateway.routes[1].rewrite.regexp=^/orders/(.*)$
+gateway.routes[1].rewrite.replacement=/order/$1
+gateway.routes[2].id=flaky
+gateway.routes[2].uri=lb://user-service
+gateway.routes[2].path=/flaky
+gateway.routes[2].retries=2
+gateway.routes[2].max-retry-body=8B
+`), ".properties")
+	assert.Nil(t, err)
+
+	discovery := &gateway.StaticDiscovery{}
+	err = p.Bind(discovery)
+	assert.Nil(t, err)
+
+	var config gateway.Config
+	err = p.Bind(&config, conf.Key("gateway"))
+	assert.Nil(t, err)
+	assert.Equal(t, len(config.Routes), 3)
+
+	g, err := gateway.New(config, discovery)
+	assert.Nil(t, err)
+
+	serveBody := func(method, path, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://127.0.0.1:8080"+path, strings.NewReader(body))
+		r.Header.Set("Cookie", "a=b")
+		w := httptest.NewRecorder()
+		ctx := web.NewBaseContext("", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+		next := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+			ctx.SetStatus(http.StatusNotFound)
+		})
+		web.NewFilterChain([]web.Filter{gateway.NewPrefilter(g), next}).Next(ctx)
+		return w
+	}
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		return serveBody(method, path, "")
+	}
+
+	w := serve(http.MethodGet, "/api/users/1?x=y")
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Body.String(), "s1 /users/1?x=y go-spring ")
+	assert.Equal(t, w.Header().Get("X-Internal"), "")
+
+	w = serve(http.MethodGet, "/api/users/2")
+	assert.Equal(t, w.Body.String(), "s2 /users/2 go-spring ")
+
+	w = serve(http.MethodGet, "/orders/9")
+	assert.Equal(t, w.Body.String(), "s1 /v2/order/9  a=b")
+
+	w = serve(http.MethodPost, "/orders/9")
+	assert.Equal(t, w.Code, http.StatusNotFound)
+
+	w = serve(http.MethodGet, "/unknown")
+	assert.Equal(t, w.Code, http.StatusNotFound)
+
+	failures = 2
+	w = serve(http.MethodGet, "/flaky")
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, failures, 0)
+
+	failures = 2
+	w = serve(http.MethodPost, "/flaky")
+	assert.Equal(t, w.Code, http.StatusServiceUnavailable)
+
+	// 需要重试的请求体超过上限时不转发。
+	failures = 1
+	w = serveBody(http.MethodPut, "/flaky", "12345678")
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, failures, 0)
+
+	failures = 1
+	w = serveBody(http.MethodPut, "/flaky", "123456789")
+	assert.Equal(t, w.Code, http.StatusRequestEntityTooLarge)
+	assert.Equal(t, failures, 1)
+}
+
+func TestGateway_Canary(t *testing.T) {
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "stable")
+	}))
+	defer upstream.Close()
+
+	newConfig := func(weight int) string {
+		return fmt.Sprintf(`
+gateway.routes[0].id=canary-header
+gateway.routes[0].uri=forward:/canary
+gateway.routes[0].path=/users/*
+gateway.routes[0].headers.X-Canary=^true$
+gateway.routes[1].id=canary-cookie
+gateway.routes[1].uri=forward:/canary
+gateway.routes[1].path=/users/*
+gateway.routes[1].cookies.canary=^1$
+gateway.routes[1].predicate=method == "GET" && query("stable") != "1"
+gateway.routes[2].id=canary-weight
+gateway.routes[2].uri=forward:/canary
+gateway.routes[2].path=/users/*
+gateway.routes[2].weight-group=users
+gateway.routes[2].weight=%d
+gateway.routes[3].id=stable
+gateway.routes[3].uri=%s
+gateway.routes[3].path=/users/*
+gateway.routes[3].weight-group=users
+gateway.routes[3].weight=%d
+`, weight, upstream.URL, 100-weight)
+	}
+
+	bind := func(weight int) gateway.Config {
+		p, err := conf.Bytes([]byte(newConfig(weight)), ".properties")
+		assert.Nil(t, err)
+		var config gateway.Config
+		err = p.Bind(&config, conf.Key("gateway"))
+		assert.Nil(t, err)
+		return config
+	}
+
+	g, err := gateway.New(bind(0), nil)
+	assert.Nil(t, err)
+
+	serve := func(fn func(r *http.Request)) string {
+		r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:8080/users/1", nil)
+		fn(r)
+		w := httptest.NewRecorder()
+		ctx := web.NewBaseContext("", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+		next := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+			ctx.String(ctx.Request().URL.Path)
+		})
+		web.NewFilterChain([]web.Filter{gateway.NewPrefilter(g), next}).Next(ctx)
+		return w.Body.String()
+	}
+
+	assert.Equal(t, serve(func(r *http.Request) {}), "stable")
+	assert.Equal(t, serve(func(r *http.Request) { r.Header.Set("X-Canary", "true") }), "/canary/users/1")
+	assert.Equal(t, serve(func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "canary", Value: "1"}) }), "/canary/users/1")
+	assert.Equal(t, serve(func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "canary", Value: "0"}) }), "stable")
+	assert.Equal(t, serve(func(r *http.Request) {
+		r.URL.RawQuery = "stable=1"
+		r.AddCookie(&http.Cookie{Name: "canary", Value: "1"})
+	}), "stable")
+
+	err = g.Reload(bind(100))
+	assert.Nil(t, err)
+	assert.Equal(t, serve(func(r *http.Request) {}), "/canary/users/1")
+
+	err = g.Reload(bind(50))
+	assert.Nil(t, err)
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		counts[serve(func(r *http.Request) {})]++
+	}
+	assert.True(t, counts["stable"] > 300 && counts["/canary/users/1"] > 300)
+
+	config := bind(0)
+	config.Routes[0].Predicate = `unknown("x") == 1`
+	_, err = gateway.New(config, nil)
+	assert.Error(t, err, "invalid predicate: .*undefined function: unknown")
+}
@@ -3,6 +3,8 @@ module github.com/go-spring/spring-core
 go 1.14
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.0
+	github.com/andybalholm/brotli v1.0.4
 	github.com/go-spring/spring-base v1.1.0-rc3
 	github.com/golang/mock v1.6.0
 	github.com/google/uuid v1.3.0
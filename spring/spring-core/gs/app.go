@@ -27,6 +27,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/go-spring/spring-base/log"
@@ -34,7 +35,9 @@ import (
 	"github.com/go-spring/spring-core/grpc"
 	"github.com/go-spring/spring-core/gs/arg"
 	"github.com/go-spring/spring-core/gs/internal"
+	"github.com/go-spring/spring-core/internal/cgroup"
 	"github.com/go-spring/spring-core/mq"
+	"github.com/go-spring/spring-core/report"
 	"github.com/go-spring/spring-core/web"
 )
 
@@ -66,10 +69,18 @@ type App struct {
 	c *container
 	b *bootstrap
 
-	exitChan chan struct{}
+	exitChan  chan struct{}
+	ready     int32 // 就绪状态，参见 appStarting 等常量
+	shutdown  shutdownConfig
+	drainOnce sync.Once
+	snapshot  *snapshotReporter // 发生致命错误时写入上下文快照
+	namespace string            // 属性命名空间，参见 Supervisor.Add
 
-	Events  []AppEvent  `autowire:"${application-event.collection:=*?}"`
-	Runners []AppRunner `autowire:"${command-line-runner.collection:=*?}"`
+	Events        []AppEvent     `autowire:"${application-event.collection:=*?}"`
+	Runners       []AppRunner    `autowire:"${command-line-runner.collection:=*?}"`
+	WarmUps       []WarmUp       `autowire:"${spring.warm-up.collection:=*?}"`
+	ReadyHooks    []ReadyHook    `autowire:"${spring.ready.hook-collection:=*?}"`
+	ShutdownHooks []ShutdownHook `autowire:"${spring.shutdown.hook-collection:=*?}"`
 }
 
 type Consumers struct {
@@ -135,14 +146,25 @@ func (app *App) Run() error {
 	}
 
 	<-app.exitChan
+	app.stop()
+	return nil
+}
+
+// stop 排空应用，执行关闭钩子，然后关闭容器。
+func (app *App) stop() {
+
+	app.Drain()
+	app.runShutdownHooks()
 
 	if app.b != nil {
 		app.b.c.Close()
 	}
 
 	app.c.Close()
+	if app.snapshot != nil {
+		report.Unregister(app.snapshot)
+	}
 	log.Info("application exited")
-	return nil
 }
 
 func (app *App) clear() {
@@ -153,7 +175,8 @@ func (app *App) clear() {
 	app.tempApp = nil
 }
 
-func (app *App) start() error {
+// prepare 注册内置的 bean ，然后从环境变量、命令行参数和属性文件加载属性。
+func (app *App) prepare() error {
 
 	app.Object(app)
 	app.Object(app.consumers)
@@ -165,7 +188,7 @@ func (app *App) start() error {
 		resourceLocator: new(defaultResourceLocator),
 	}
 
-	if err := e.prepare(); err != nil {
+	if err := e.prepare(app.namespace); err != nil {
 		return err
 	}
 
@@ -184,20 +207,55 @@ func (app *App) start() error {
 		return err
 	}
 
+	if err := app.loadConfigSources(e); err != nil {
+		return err
+	}
+
 	// 保存从环境变量和命令行解析的属性
 	for _, k := range e.p.Keys() {
-		app.c.p.Set(k, e.p.Get(k))
+		app.c.setProperty(k, e.p.Get(k), e.source(k))
+	}
+
+	return loadRuntimeProperties(app.c.p, cgroup.Detect())
+}
+
+func (app *App) start() error {
+
+	if err := app.prepare(); err != nil {
+		return err
 	}
 
 	if err := app.c.Refresh(internal.AutoClear(false)); err != nil {
 		return err
 	}
 
+	// 只输出 bean 报告，不启动应用。
+	if file := app.c.p.Get(SpringReportFile); file != "" {
+		if err := app.c.writeReport(file); err != nil {
+			return err
+		}
+		app.ShutDown("bean report has been written to " + file)
+		return nil
+	}
+
 	// 执行命令行启动器
 	for _, r := range app.Runners {
 		r.Run(app.c)
 	}
 
+	var warmUp warmUpConfig
+	if err := app.c.p.Bind(&warmUp); err != nil {
+		return err
+	}
+	if err := app.c.p.Bind(&app.shutdown); err != nil {
+		return err
+	}
+
+	if dir := app.c.p.Get(SpringSnapshotDir); dir != "" {
+		app.snapshot = &snapshotReporter{c: app.c, dir: dir}
+		report.Register(app.snapshot)
+	}
+
 	// 通知应用启动事件
 	for _, event := range app.Events {
 		event.OnAppStart(app.c)
@@ -205,6 +263,11 @@ func (app *App) start() error {
 
 	app.clear()
 
+	// 执行预热钩子，结束之后应用进入就绪状态
+	app.c.Go(func(ctx context.Context) {
+		app.warmUp(ctx, warmUp)
+	})
+
 	// 通知应用停止事件
 	app.c.Go(func(ctx context.Context) {
 		<-ctx.Done()
@@ -303,8 +366,10 @@ func (app *App) loadProperties(e *configuration) error {
 		if err != nil {
 			return err
 		}
-		for _, key := range p.Keys() {
-			app.c.p.Set(key, p.Get(key))
+		dir := filepath.Dir(resource.Name())
+		visited := map[string]bool{resource.Name(): true}
+		if err = app.mergeProperties(e, p, resource.Name(), dir, visited); err != nil {
+			return err
 		}
 	}
 
@@ -364,6 +429,21 @@ func (app *App) Property(key string, value interface{}) {
 	app.c.Property(key, value)
 }
 
+// Schema 参考 Container.Schema 的解释。
+func (app *App) Schema(s *conf.Schema) {
+	app.c.Schema(s)
+}
+
+// Defaults 参考 Container.Defaults 的解释。
+func (app *App) Defaults(module string, props map[string]interface{}, profiles ...string) {
+	app.c.Defaults(module, props, profiles...)
+}
+
+// Documentation 参考 Container.Documentation 的解释。
+func (app *App) Documentation() (*BeanDocumentation, error) {
+	return app.c.Documentation()
+}
+
 // Object 参考 Container.Object 的解释。
 func (app *App) Object(i interface{}) *BeanDefinition {
 	return app.c.register(NewBean(reflect.ValueOf(i)))
@@ -464,6 +544,11 @@ func (app *App) StaticFS(prefix string, fs http.FileSystem) *web.Mapper {
 	return app.router.StaticFS(prefix, fs)
 }
 
+// Versioning 返回按照 API 版本注册处理函数的路由器。
+func (app *App) Versioning(config web.VersionConfig) *web.VersionRouter {
+	return web.NewVersionRouter(app.router, config)
+}
+
 // Consume 注册 MQ 消费者。
 func (app *App) Consume(fn interface{}, topics ...string) {
 	app.consumers.Add(mq.Bind(fn, topics...))
@@ -0,0 +1,49 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"github.com/go-spring/spring-core/gs/internal"
+)
+
+// SpringCommand 以命令方式执行应用时该属性的值为命令的名称，不需要在命令中使用
+// 的 bean 例如 web 服务器、数据库连接等可以通过 cond.OnMissingProperty 跳过。
+const SpringCommand = "spring.command"
+
+// Execute 以命令方式执行应用，通常用于和服务共享 bean 定义的管理工具。加载属性
+// 并刷新容器之后执行 fn ，执行结束后关闭容器。和 Run 不同的是，该函数不执行命令
+// 行启动器，不通知应用启动和停止事件，也不执行预热和关闭钩子。
+func (app *App) Execute(command string, fn func(ctx Context) error) error {
+
+	app.Property(SpringCommand, command)
+	if err := app.prepare(); err != nil {
+		return err
+	}
+
+	defer func() {
+		if app.b != nil {
+			app.b.c.Close()
+		}
+	}()
+
+	if err := app.c.Refresh(internal.AutoClear(false)); err != nil {
+		return err
+	}
+
+	defer app.c.Close()
+	return fn(app.c)
+}
@@ -34,11 +34,14 @@ const IncludeEnvPatterns = "INCLUDE_ENV_PATTERNS"
 const ExcludeEnvPatterns = "EXCLUDE_ENV_PATTERNS"
 
 type configuration struct {
-	p *conf.Properties
+	p    *conf.Properties
+	args *conf.Properties // 命令行参数，用于区分属性的来源
 
 	resourceLocator  ResourceLocator
 	ActiveProfiles   []string `value:"${spring.profiles.active:=}"`
 	ConfigExtensions []string `value:"${spring.config.extensions:=.properties,.yaml,.yml,.toml,.tml}"`
+	DotenvFiles      []string `value:"${spring.config.dotenv:=}"`
+	ConfigDirs       []string `value:"${spring.config.dirs:=}"`
 }
 
 // loadCmdArgs 加载 -name value 形式的命令行参数。
@@ -134,13 +137,32 @@ func loadSystemEnv(p *conf.Properties) error {
 	return nil
 }
 
-func (e *configuration) prepare() error {
+// prepare 加载环境变量和命令行参数，namespace 不为空时以其为前缀的属性去掉前缀之
+// 后覆盖同名属性。
+func (e *configuration) prepare(namespace string) error {
 	if err := loadSystemEnv(e.p); err != nil {
 		return err
 	}
-	if err := loadCmdArgs(e.p); err != nil {
+	e.args = conf.New()
+	if err := loadCmdArgs(e.args); err != nil {
 		return err
 	}
+	for _, k := range e.args.Keys() {
+		e.p.Set(k, e.args.Get(k))
+	}
+	if namespace != "" {
+		prefix := namespace + "."
+		for _, k := range e.p.Keys() {
+			if !strings.HasPrefix(k, prefix) {
+				continue
+			}
+			key := strings.TrimPrefix(k, prefix)
+			e.p.Set(key, e.p.Get(k))
+			if e.args.Has(k) {
+				e.args.Set(key, e.p.Get(k))
+			}
+		}
+	}
 	if err := e.p.Bind(e); err != nil {
 		return err
 	}
@@ -149,3 +171,11 @@ func (e *configuration) prepare() error {
 	}
 	return nil
 }
+
+// source 返回环境变量或者命令行参数中属性的来源。
+func (e *configuration) source(key string) string {
+	if e.args.Has(key) {
+		return "command line"
+	}
+	return "system environment"
+}
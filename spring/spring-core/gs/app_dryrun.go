@@ -0,0 +1,73 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/go-spring/spring-core/gs/internal"
+)
+
+// DryRun 试运行应用，通常用于在 CI 中校验属性变更。加载属性之后以试运行的方式刷新
+// 容器，即执行条件判断、属性绑定校验和依赖查找，但不执行构造函数和初始化函数，也不
+// 启动任何服务，最后输出将会创建的 bean 列表。需要注意的是 bootstrap 阶段仍然正常
+// 执行，因为属性的加载可能依赖其中的 bean 。
+func (app *App) DryRun() error {
+
+	if err := app.prepare(); err != nil {
+		return err
+	}
+
+	defer func() {
+		if app.b != nil {
+			app.b.c.Close()
+		}
+	}()
+
+	err := app.c.Refresh(internal.AutoClear(false), internal.DryRun(true))
+	if err != nil {
+		return err
+	}
+
+	r, err := app.c.Report()
+	if err != nil {
+		return err
+	}
+	app.clear()
+	return writeDryRun(os.Stdout, r)
+}
+
+// writeDryRun 输出试运行的 bean 列表，被排除的 bean 同时输出排除的原因。
+func writeDryRun(w io.Writer, r *Report) error {
+
+	var created, excluded int
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, b := range r.Beans {
+		if b.Status == getStatusString(Deleted) {
+			excluded++
+			fmt.Fprintf(tw, "-\t%s\t%s\t%s\t(%s)\n", b.ID, b.Type, b.Source, b.Reason)
+			continue
+		}
+		created++
+		fmt.Fprintf(tw, "+\t%s\t%s\t%s\t\n", b.ID, b.Type, b.Source)
+	}
+	fmt.Fprintf(tw, "dry run: %d beans would be created, %d excluded\n", created, excluded)
+	return tw.Flush()
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/conf"
+)
+
+// SpringConfigImport 属性文件通过该属性导入其他的属性文件，多个导入项使用逗号分
+// 隔，导入项的格式为 [optional:][scheme:]location，scheme 默认为 file 。
+const SpringConfigImport = "spring.config.import"
+
+// SpringConfigActivateOnProfile 属性文件通过该属性声明仅在指定的 profile 激活
+// 时才生效，多个 profile 使用逗号分隔。
+const SpringConfigActivateOnProfile = "spring.config.activate.on-profile"
+
+// ConfigImporter 导入 location 指定的属性列表，location 不包含 scheme 部分，
+// 导入项不存在时应该返回 os.ErrNotExist 错误。
+type ConfigImporter func(location string) (*conf.Properties, error)
+
+var configImporters = map[string]ConfigImporter{}
+
+// RegisterConfigImporter 注册 scheme 对应的属性导入器，如 configserver 等。
+func RegisterConfigImporter(scheme string, importer ConfigImporter) {
+	configImporters[scheme] = importer
+}
+
+// configImport 导入项的分解式。
+type configImport struct {
+	optional bool
+	scheme   string
+	location string
+}
+
+func parseConfigImport(s string) configImport {
+	var i configImport
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "optional:") {
+		i.optional = true
+		s = strings.TrimPrefix(s, "optional:")
+	}
+	i.scheme = "file"
+	if n := strings.Index(s, ":"); n > 1 { // 避免把 Windows 的盘符当作 scheme
+		i.scheme = s[:n]
+		s = s[n+1:]
+	}
+	i.location = s
+	return i
+}
+
+// mergeProperties 将属性文件的内容合并到容器中，然后递归处理属性文件的导入项，导
+// 入的属性值会覆盖导入它的属性文件中的同名属性值。dir 是属性文件所在的目录，用于解
+// 析相对路径，source 是属性的来源，visited 用于防止循环导入。
+func (app *App) mergeProperties(e *configuration, p *conf.Properties, source string, dir string, visited map[string]bool) error {
+
+	if !activeOnProfile(e, p) {
+		return nil
+	}
+
+	var imports []string
+	if err := p.Bind(&imports, conf.Key(SpringConfigImport+":=")); err != nil {
+		return err
+	}
+
+	for _, key := range p.Keys() {
+		if isConfigDirective(key) {
+			continue
+		}
+		app.c.setProperty(key, p.Get(key), source)
+	}
+
+	for _, s := range imports {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		if err := app.importProperties(e, parseConfigImport(s), dir, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (app *App) importProperties(e *configuration, i configImport, dir string, visited map[string]bool) error {
+
+	if i.scheme != "file" {
+		importer, ok := configImporters[i.scheme]
+		if !ok {
+			if i.optional {
+				log.Warnf("unsupported config import scheme %q", i.scheme)
+				return nil
+			}
+			return fmt.Errorf("unsupported config import scheme %q", i.scheme)
+		}
+		p, err := importer(i.location)
+		if os.IsNotExist(err) && i.optional {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("import %s:%s error: %w", i.scheme, i.location, err)
+		}
+		return app.mergeProperties(e, p, i.scheme+":"+i.location, dir, visited)
+	}
+
+	file := i.location
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+
+	// 先导入文件本身，然后导入 profile 对应的文件，后者总是可选的。
+	files := []string{file}
+	ext := filepath.Ext(file)
+	for _, profile := range e.ActiveProfiles {
+		files = append(files, strings.TrimSuffix(file, ext)+"-"+profile+ext)
+	}
+
+	for n, f := range files {
+		if visited[f] {
+			return fmt.Errorf("found circle config import %q", f)
+		}
+		if _, err := os.Stat(f); os.IsNotExist(err) {
+			if n == 0 && !i.optional {
+				return fmt.Errorf("config import %q not found", f)
+			}
+			continue
+		}
+		p, err := conf.Load(f)
+		if err != nil {
+			return err
+		}
+		visited[f] = true
+		err = app.mergeProperties(e, p, f, filepath.Dir(f), visited)
+		delete(visited, f)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// activeOnProfile 返回属性文件在当前激活的 profile 下是否生效。
+func activeOnProfile(e *configuration, p *conf.Properties) bool {
+	s := p.Get(SpringConfigActivateOnProfile)
+	if s == "" {
+		return true
+	}
+	for _, profile := range strings.Split(s, ",") {
+		for _, active := range e.ActiveProfiles {
+			if strings.TrimSpace(profile) == active {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isConfigDirective 返回 key 是否是控制属性文件加载的指令。
+func isConfigDirective(key string) bool {
+	if key == SpringConfigActivateOnProfile {
+		return true
+	}
+	return key == SpringConfigImport || strings.HasPrefix(key, SpringConfigImport+"[")
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/internal/cgroup"
+)
+
+const (
+	RuntimeCPULimit    = "runtime.cpu.limit"    // 可以使用的 CPU 核数，可以是小数，没有限制时为机器的核数
+	RuntimeCPUCount    = "runtime.cpu.count"    // 向下取整的 CPU 核数，至少为 1 ，同时用于设置 GOMAXPROCS
+	RuntimeMemoryLimit = "runtime.memory.limit" // 可以使用的内存字节数，没有限制时为 0
+	RuntimePoolSize    = "runtime.pool.size"    // 连接池的默认大小，为 CPU 核数的 2 倍加 1
+)
+
+// loadRuntimeProperties 根据容器的资源限制设置运行时属性，配置文件、环境变量或者
+// 命令行中已经设置的属性不会被覆盖，其他属性在此基础上计算。没有设置 GOMAXPROCS
+// 环境变量时按照 runtime.cpu.count 设置 GOMAXPROCS 。
+func loadRuntimeProperties(p *conf.Properties, limits cgroup.Limits) error {
+
+	setDefault := func(key string, val interface{}) error {
+		if p.Has(key) {
+			return nil
+		}
+		return p.Set(key, val)
+	}
+
+	cpuLimit := limits.CPU
+	if cpuLimit <= 0 {
+		cpuLimit = float64(runtime.NumCPU())
+	}
+	if err := setDefault(RuntimeCPULimit, strconv.FormatFloat(cpuLimit, 'f', -1, 64)); err != nil {
+		return err
+	}
+	if err := setDefault(RuntimeMemoryLimit, limits.Memory); err != nil {
+		return err
+	}
+
+	cpuLimit, err := strconv.ParseFloat(p.Get(RuntimeCPULimit), 64)
+	if err != nil {
+		return err
+	}
+	cpuCount := int(math.Max(1, math.Floor(cpuLimit)))
+	if err = setDefault(RuntimeCPUCount, cpuCount); err != nil {
+		return err
+	}
+
+	if cpuCount, err = strconv.Atoi(p.Get(RuntimeCPUCount)); err != nil {
+		return err
+	}
+	if err = setDefault(RuntimePoolSize, 2*cpuCount+1); err != nil {
+		return err
+	}
+
+	if _, ok := os.LookupEnv("GOMAXPROCS"); !ok && cpuCount > 0 && cpuCount != runtime.GOMAXPROCS(0) {
+		log.Infof("set GOMAXPROCS to %d", cpuCount)
+		runtime.GOMAXPROCS(cpuCount)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+)
+
+// ShutdownHook 关闭钩子，收到退出信号并且排空流量之后、停止 AppEvent 之前执行，
+// 多个钩子按照 bean 的 Order 依次执行，序号越小越先执行。
+type ShutdownHook interface {
+	OnShutdown(ctx context.Context)
+}
+
+// shutdownConfig 关闭过程的配置。
+type shutdownConfig struct {
+	DrainPeriod time.Duration `value:"${spring.shutdown.drain-period:=0s}"`  // 排空流量的等待时间
+	HookTimeout time.Duration `value:"${spring.shutdown.hook-timeout:=10s}"` // 每个关闭钩子的超时时间
+}
+
+// Drain 使应用退出就绪状态，然后等待 spring.shutdown.drain-period 配置的时间，
+// 让负载均衡有机会摘除当前实例。只有第一次调用会等待，并发的调用会等待第一次
+// 调用结束。收到退出信号时会自动调用，也可以在 Kubernetes 的 preStop 中提前调用。
+func (app *App) Drain() {
+	app.drainOnce.Do(func() {
+		atomic.StoreInt32(&app.ready, appDraining)
+		if period := app.shutdown.DrainPeriod; period > 0 {
+			log.Infof("draining for %v", period)
+			time.Sleep(period)
+		}
+	})
+}
+
+// runShutdownHooks 依次执行所有关闭钩子，钩子超时之后不再等待它返回。
+func (app *App) runShutdownHooks() {
+	for _, hook := range app.ShutdownHooks {
+		if err := runShutdownHook(hook, app.shutdown.HookTimeout); err != nil {
+			log.Errorf("shutdown hook %T: %v", hook, err)
+		}
+	}
+}
+
+func runShutdownHook(hook ShutdownHook, timeout time.Duration) error {
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		hook.OnShutdown(ctx)
+		done <- nil
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timeout after %v", timeout)
+	}
+}
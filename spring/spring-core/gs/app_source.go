@@ -0,0 +1,135 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-spring/spring-core/conf"
+)
+
+func init() {
+	RegisterConfigImporter("dotenv", importDotenv)
+	RegisterConfigImporter("configtree", importConfigTree)
+}
+
+// SpringConfigDotenv 需要加载的 .env 文件，多个文件使用逗号分隔，可以通过环境变量
+// 或者命令行参数设置，文件名可以携带 optional: 前缀。
+const SpringConfigDotenv = "spring.config.dotenv"
+
+// SpringConfigDirs 需要加载的配置目录，多个目录使用逗号分隔，目录中的每个文件都是
+// 一个属性，文件的相对路径是属性名，文件的内容是属性值，和 Kubernetes 挂载 ConfigMap
+// 或者 Secret 的方式相同。
+const SpringConfigDirs = "spring.config.dirs"
+
+// importDotenv 导入 .env 文件，和环境变量一样，以 GS_ 开头的变量名转换成属性名。
+func importDotenv(location string) (*conf.Properties, error) {
+	if _, err := os.Stat(location); err != nil {
+		return nil, err
+	}
+	p, err := conf.Load(location)
+	if err != nil {
+		return nil, err
+	}
+	ret := conf.New()
+	for _, k := range p.Keys() {
+		key := k
+		if strings.HasPrefix(k, EnvPrefix) {
+			key = strings.TrimPrefix(k, EnvPrefix)
+			key = strings.ReplaceAll(key, "_", ".")
+			key = strings.ToLower(key)
+		}
+		if err = ret.Set(key, p.Get(k)); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// importConfigTree 导入配置目录，子目录的名字作为属性名的一部分，忽略以 . 开头的
+// 文件和目录，例如 Kubernetes 创建的 ..data 目录，文件内容末尾的换行会被去掉。
+func importConfigTree(location string) (*conf.Properties, error) {
+	if _, err := os.Stat(location); err != nil {
+		return nil, err
+	}
+	p := conf.New()
+	if err := walkConfigTree(p, location, ""); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func walkConfigTree(p *conf.Properties, dir string, prefix string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		file := filepath.Join(dir, f.Name())
+		// Kubernetes 挂载的文件是符号链接，需要获取链接目标的信息。
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		key := prefix + f.Name()
+		if info.IsDir() {
+			if err = walkConfigTree(p, file, key+"."); err != nil {
+				return err
+			}
+			continue
+		}
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		s := strings.TrimSuffix(strings.TrimSuffix(string(b), "\n"), "\r")
+		if err = p.Set(key, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadConfigSources 加载 spring.config.dotenv 和 spring.config.dirs 指定的属性，
+// 它们覆盖配置文件中的同名属性，但是会被环境变量和命令行参数覆盖。
+func (app *App) loadConfigSources(e *configuration) error {
+	var imports []configImport
+	for _, s := range e.DotenvFiles {
+		if s = strings.TrimSpace(s); s != "" {
+			i := parseConfigImport(s)
+			imports = append(imports, configImport{optional: i.optional, scheme: "dotenv", location: i.location})
+		}
+	}
+	for _, s := range e.ConfigDirs {
+		if s = strings.TrimSpace(s); s != "" {
+			i := parseConfigImport(s)
+			imports = append(imports, configImport{optional: i.optional, scheme: "configtree", location: i.location})
+		}
+	}
+	for _, i := range imports {
+		if err := app.importProperties(e, i, ".", map[string]bool{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/go-spring/spring-base/log"
+)
+
+// Supervisor 在同一个进程中运行多个相互独立的应用，每个应用拥有自己的 IoC 容器、
+// 属性和 web 服务器。应用按照添加的顺序依次启动，任意一个应用退出或者收到退出信号
+// 时，所有应用按照相反的顺序依次退出。适用于以 sidecar 方式嵌入其他应用或者测试。
+type Supervisor struct {
+	apps     []*App
+	exitChan chan struct{}
+	exitOnce sync.Once
+}
+
+// NewSupervisor 创建 Supervisor 对象。
+func NewSupervisor() *Supervisor {
+	return &Supervisor{exitChan: make(chan struct{})}
+}
+
+// Add 添加名为 name 的应用，name 同时作为应用的属性命名空间，以 name. 为前缀的环
+// 境变量和命令行参数去掉前缀之后覆盖该应用的同名属性，例如 GS_ADMIN_SPRING_CONFIG_LOCATIONS
+// 和 -admin.web.server.port 分别设置 admin 应用的配置目录和端口。
+func (s *Supervisor) Add(name string, app *App) *Supervisor {
+	for _, a := range s.apps {
+		if a.namespace == name {
+			panic(fmt.Errorf("duplicate app %q", name))
+		}
+	}
+	app.namespace = name
+	s.apps = append(s.apps, app)
+	return s
+}
+
+// Run 依次启动所有应用，任意一个应用启动失败时已经启动的应用会按照相反的顺序退出。
+// 启动完成之后一直阻塞，直到某个应用退出或者调用了 ShutDown 方法。
+func (s *Supervisor) Run() error {
+
+	// 响应控制台的 Ctrl+C 及 kill 命令。
+	go func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+		sig := <-ch
+		s.ShutDown(fmt.Sprintf("signal %v", sig))
+	}()
+
+	for i, app := range s.apps {
+		if err := app.start(); err != nil {
+			s.stop(s.apps[:i])
+			return fmt.Errorf("app %q start error: %w", app.namespace, err)
+		}
+		go func(app *App) {
+			<-app.exitChan
+			s.ShutDown(fmt.Sprintf("app %q exited", app.namespace))
+		}(app)
+	}
+
+	<-s.exitChan
+	s.stop(s.apps)
+	return nil
+}
+
+// stop 按照启动顺序相反的顺序关闭应用。
+func (s *Supervisor) stop(apps []*App) {
+	for i := len(apps) - 1; i >= 0; i-- {
+		app := apps[i]
+		app.ShutDown("supervisor is stopping")
+		app.stop()
+	}
+}
+
+// ShutDown 使所有应用退出。
+func (s *Supervisor) ShutDown(msg ...string) {
+	s.exitOnce.Do(func() {
+		log.Infof("supervisor will exit %s", strings.Join(msg, " "))
+		close(s.exitChan)
+	})
+}
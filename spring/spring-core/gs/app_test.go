@@ -17,12 +17,24 @@
 package gs_test
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/conf"
 	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
 )
 
 func startApplication(cfgLocation string, fn func(gs.Context)) *gs.App {
@@ -81,3 +93,399 @@ func TestConfig(t *testing.T) {
 		defer app.ShutDown("run test end")
 	})
 }
+
+func TestConfigImport(t *testing.T) {
+	os.Clearenv()
+	gs.Setenv("GS_SPRING_PROFILES_ACTIVE", "dev")
+	app := startApplication("testdata/import/", func(ctx gs.Context) {
+		assert.Equal(t, ctx.Prop("a"), "1")
+		assert.Equal(t, ctx.Prop("b"), "2")
+		assert.Equal(t, ctx.Prop("c"), "3")
+		assert.False(t, ctx.Has(gs.SpringConfigImport))
+	})
+	defer app.ShutDown("run test end")
+}
+
+func TestConfigSchema(t *testing.T) {
+	os.Clearenv()
+	gs.Setenv("GS_SPRING_CONFIG_LOCATIONS", "testdata/import/")
+	gs.Setenv("GS_SPRING_PROFILES_ACTIVE", "dev")
+	app := gs.NewApp()
+	app.Schema(conf.NewSchema("test").
+		Required("x").
+		Enum("a", "2", "3").
+		Type("c", true).
+		Enum("spring.profiles.active", "prod").
+		Deprecated("b", "d").
+		Renamed("a", "e").
+		Type("e", time.Second))
+	err := app.Run()
+	assert.NotNil(t, err)
+	assert.Equal(t, err.Error(), `config schema violations:
+	[test] property "x" is required
+	[test] property "a" value "1" is not one of [2,3] (testdata/import/application.properties)
+	[test] property "c" is not a valid bool (testdata/import/override-dev.properties)
+	[test] property "spring.profiles.active" value "dev" is not one of [prod] (system environment)
+	[test] property "e" is not a valid time.Duration (testdata/import/application.properties)`)
+}
+
+func TestAppDryRun(t *testing.T) {
+	os.Clearenv()
+
+	type Conn struct{}
+	app := gs.NewApp()
+	app.Provide(func() *Conn {
+		panic("constructor should not be called in dry run")
+	})
+	app.Provide(func() *Conn { return nil }).Name("mock").On(cond.OnProperty("mock"))
+
+	stdout := os.Stdout
+	f, err := ioutil.TempFile(t.TempDir(), "stdout")
+	assert.Nil(t, err)
+	os.Stdout = f
+	err = app.DryRun()
+	os.Stdout = stdout
+	assert.Nil(t, err)
+
+	b, err := ioutil.ReadFile(f.Name())
+	assert.Nil(t, err)
+	out := string(b)
+	assert.Matches(t, out, `\+ +github.com/go-spring/spring-core/gs_test/gs_test.Conn:Conn +\*gs_test.Conn +.*app_test.go:\d+`)
+	assert.Matches(t, out, `- +github.com/go-spring/spring-core/gs_test/gs_test.Conn:mock .* \(condition not matched\)`)
+	assert.Matches(t, out, `dry run: \d+ beans would be created, 1 excluded\n$`)
+}
+
+type panicEvent struct{}
+
+func (e *panicEvent) OnAppStart(ctx gs.Context) {
+	panic("event should not be notified in command mode")
+}
+
+func (e *panicEvent) OnAppStop(ctx context.Context) {}
+
+func TestAppExecute(t *testing.T) {
+	os.Clearenv()
+
+	type Server struct{}
+	type Repo struct{ closed bool }
+
+	repo := new(Repo)
+	app := gs.NewApp()
+	app.Object(new(panicEvent)).Export((*gs.AppEvent)(nil))
+	app.Object(new(Server)).On(cond.OnMissingProperty(gs.SpringCommand))
+	app.Object(repo).Destroy(func(r *Repo) { r.closed = true })
+
+	err := app.Execute("migrate", func(ctx gs.Context) error {
+		assert.Equal(t, ctx.Prop(gs.SpringCommand), "migrate")
+		var s *Server
+		assert.Error(t, ctx.Get(&s), "can't find bean")
+		var r *Repo
+		assert.Nil(t, ctx.Get(&r))
+		assert.False(t, r.closed)
+		return errors.New("migrate failed")
+	})
+	assert.Error(t, err, "migrate failed")
+	assert.True(t, repo.closed)
+}
+
+func TestSnapshotOnPanic(t *testing.T) {
+	os.Clearenv()
+	dir := t.TempDir()
+	gs.Setenv("GS_DB_PASSWORD", "123456")
+	gs.Setenv("GS_SPRING_SNAPSHOT_DIR", dir)
+	app := startApplication("testdata/config/", func(ctx gs.Context) {})
+	defer app.ShutDown("run test end")
+
+	app.Go(func(ctx context.Context) {
+		panic("boom")
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, len(files), 1)
+	b, err := ioutil.ReadFile(filepath.Join(dir, files[0].Name()))
+	assert.Nil(t, err)
+	var s gs.ContextSnapshot
+	err = json.Unmarshal(b, &s)
+	assert.Nil(t, err)
+	assert.Equal(t, s.Properties["db.password"], conf.MaskedValue)
+	assert.Equal(t, s.Properties["spring.application.name"], "test")
+	assert.Equal(t, s.Health, []gs.HealthReport{
+		{Bean: "github.com/go-spring/spring-core/gs/gs.App:App", Status: "UP"},
+	})
+}
+
+type lifecycleRecorder struct {
+	name   string
+	mutex  *sync.Mutex
+	events *[]string
+}
+
+func (r *lifecycleRecorder) record(event string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	*r.events = append(*r.events, r.name+" "+event)
+}
+
+func (r *lifecycleRecorder) OnAppStart(ctx gs.Context)     { r.record("start") }
+func (r *lifecycleRecorder) OnAppStop(ctx context.Context) { r.record("stop") }
+
+// readySignal 应用就绪之后关闭通道，测试以此代替等待固定的时间。
+type readySignal struct {
+	ready chan struct{}
+}
+
+func (s *readySignal) OnReady(ctx context.Context) { close(s.ready) }
+
+func TestSupervisor(t *testing.T) {
+
+	type AppName struct {
+		Name string `value:"${spring.application.name:=}"`
+	}
+
+	var (
+		mutex  sync.Mutex
+		events []string
+	)
+
+	newApp := func(name string) (*gs.App, *AppName, chan struct{}) {
+		app := gs.NewApp()
+		r := &lifecycleRecorder{name: name, mutex: &mutex, events: &events}
+		app.Object(r).Export((*gs.AppEvent)(nil))
+		ready := &readySignal{ready: make(chan struct{})}
+		app.Object(ready).Export((*gs.ReadyHook)(nil))
+		appName := new(AppName)
+		app.Object(appName)
+		return app, appName, ready.ready
+	}
+
+	t.Run("run", func(t *testing.T) {
+		os.Clearenv()
+		events = nil
+		gs.Setenv("GS_SPRING_CONFIG_LOCATIONS", "testdata/config/")
+		gs.Setenv("GS_ADMIN_SPRING_CONFIG_LOCATIONS", "testdata/import/")
+		gs.Setenv("GS_ADMIN_SPRING_APPLICATION_NAME", "admin")
+
+		main, mainName, mainReady := newApp("main")
+		admin, adminName, adminReady := newApp("admin")
+		s := gs.NewSupervisor().Add("main", main).Add("admin", admin)
+
+		errChan := make(chan error)
+		go func() { errChan <- s.Run() }()
+		<-mainReady
+		<-adminReady
+
+		assert.Equal(t, mainName.Name, "test")
+		assert.Equal(t, adminName.Name, "admin")
+		assert.True(t, main.Ready())
+		assert.True(t, admin.Ready())
+
+		// 任意一个应用退出时所有应用按照相反的顺序退出
+		main.ShutDown("run test end")
+		assert.Nil(t, <-errChan)
+		assert.Equal(t, events, []string{"main start", "admin start", "admin stop", "main stop"})
+	})
+
+	t.Run("start error", func(t *testing.T) {
+		os.Clearenv()
+		events = nil
+		main, _, _ := newApp("main")
+		admin, _, _ := newApp("admin")
+		admin.Provide(func(addr string) *AppName { return nil }, "${redis.addr}").Name("broken")
+		s := gs.NewSupervisor().Add("main", main).Add("admin", admin)
+		err := s.Run()
+		assert.Error(t, err, "(?s)app \"admin\" start error: .*property \"redis.addr\" not exist")
+		assert.Equal(t, events, []string{"main start", "main stop"})
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		assert.Panic(t, func() {
+			gs.NewSupervisor().Add("main", gs.NewApp()).Add("main", gs.NewApp())
+		}, "duplicate app \"main\"")
+	})
+}
+
+func TestReportFile(t *testing.T) {
+	os.Clearenv()
+	file := filepath.Join(t.TempDir(), "report.json")
+	gs.Setenv("GS_SPRING_REPORT_FILE", file)
+	app := gs.NewApp()
+	err := app.Run()
+	assert.Nil(t, err)
+	b, err := ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(b), `"status": "Wired"`))
+}
+
+func TestRuntimeProperties(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+
+	t.Run("cpu limit", func(t *testing.T) {
+		os.Clearenv()
+		gs.Setenv("GS_RUNTIME_CPU_LIMIT", "2.5")
+		app := startApplication("testdata/config/", func(ctx gs.Context) {
+			assert.Equal(t, ctx.Prop(gs.RuntimeCPULimit), "2.5")
+			assert.Equal(t, ctx.Prop(gs.RuntimeCPUCount), "2")
+			assert.Equal(t, ctx.Prop(gs.RuntimePoolSize), "5")
+			assert.True(t, ctx.Has(gs.RuntimeMemoryLimit))
+		})
+		defer app.ShutDown("run test end")
+		assert.Equal(t, runtime.GOMAXPROCS(0), 2)
+	})
+
+	t.Run("cpu count", func(t *testing.T) {
+		os.Clearenv()
+		gs.Setenv("GS_RUNTIME_CPU_COUNT", "3")
+		gs.Setenv("GS_RUNTIME_POOL_SIZE", "20")
+		app := startApplication("testdata/config/", func(ctx gs.Context) {
+			assert.Equal(t, ctx.Prop(gs.RuntimeCPUCount), "3")
+			assert.Equal(t, ctx.Prop(gs.RuntimePoolSize), "20")
+		})
+		defer app.ShutDown("run test end")
+		assert.Equal(t, runtime.GOMAXPROCS(0), 3)
+	})
+}
+
+type warmUpHook struct {
+	delay   time.Duration
+	timeout time.Duration
+	running *int32
+	maxRun  *int32
+	done    int32
+}
+
+func (h *warmUpHook) WarmUp(ctx context.Context) error {
+	n := atomic.AddInt32(h.running, 1)
+	defer atomic.AddInt32(h.running, -1)
+	for {
+		m := atomic.LoadInt32(h.maxRun)
+		if n <= m || atomic.CompareAndSwapInt32(h.maxRun, m, n) {
+			break
+		}
+	}
+	select {
+	case <-time.After(h.delay):
+		atomic.StoreInt32(&h.done, 1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (h *warmUpHook) WarmUpTimeout() time.Duration {
+	return h.timeout
+}
+
+type readyHook struct {
+	calls int32
+}
+
+func (h *readyHook) OnReady(ctx context.Context) {
+	atomic.AddInt32(&h.calls, 1)
+}
+
+func TestWarmUp(t *testing.T) {
+	os.Clearenv()
+
+	var running, maxRun int32
+	hooks := []*warmUpHook{
+		{delay: 200 * time.Millisecond, running: &running, maxRun: &maxRun},
+		{delay: 200 * time.Millisecond, running: &running, maxRun: &maxRun},
+		{delay: 200 * time.Millisecond, running: &running, maxRun: &maxRun},
+		{delay: time.Hour, timeout: 50 * time.Millisecond, running: &running, maxRun: &maxRun},
+	}
+
+	app := gs.NewApp()
+	app.Property("spring.warm-up.parallelism", 2)
+	for i, h := range hooks {
+		app.Object(h).Name(fmt.Sprintf("warm-up-%d", i)).Export((*gs.WarmUp)(nil))
+	}
+	ready := new(readyHook)
+	app.Object(ready).Export((*gs.ReadyHook)(nil))
+	go func() {
+		if err := app.Run(); err != nil {
+			panic(err)
+		}
+	}()
+	defer app.ShutDown("run test end")
+
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, app.Ready())
+	assert.Equal(t, atomic.LoadInt32(&ready.calls), int32(0))
+
+	time.Sleep(500 * time.Millisecond)
+	assert.True(t, app.Ready())
+	assert.Equal(t, atomic.LoadInt32(&ready.calls), int32(1))
+	assert.Equal(t, atomic.LoadInt32(&maxRun), int32(2))
+	for _, h := range hooks[:3] {
+		assert.Equal(t, atomic.LoadInt32(&h.done), int32(1))
+	}
+	assert.Equal(t, atomic.LoadInt32(&hooks[3].done), int32(0))
+}
+
+type shutdownHook struct {
+	name  string
+	block bool
+	calls chan string
+}
+
+func (h *shutdownHook) OnShutdown(ctx context.Context) {
+	if h.block {
+		<-time.After(time.Hour)
+	}
+	h.calls <- h.name
+}
+
+func TestShutdownHooks(t *testing.T) {
+	os.Clearenv()
+
+	calls := make(chan string, 3)
+	app := gs.NewApp()
+	app.Property("spring.shutdown.drain-period", "200ms")
+	app.Property("spring.shutdown.hook-timeout", "50ms")
+	app.Object(&shutdownHook{name: "b", calls: calls}).Name("b").Order(2).Export((*gs.ShutdownHook)(nil))
+	app.Object(&shutdownHook{name: "c", calls: calls, block: true}).Name("c").Order(1).Export((*gs.ShutdownHook)(nil))
+	app.Object(&shutdownHook{name: "a", calls: calls}).Name("a").Order(0).Export((*gs.ShutdownHook)(nil))
+
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		if err := app.Run(); err != nil {
+			panic(err)
+		}
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, app.Ready())
+
+	start := time.Now()
+	go app.Drain()
+	time.Sleep(20 * time.Millisecond)
+	assert.False(t, app.Ready())
+
+	app.ShutDown("run test end")
+	<-exited
+	assert.True(t, time.Since(start) >= 250*time.Millisecond)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, <-calls, "a")
+	assert.Equal(t, <-calls, "b")
+	assert.Equal(t, len(calls), 0)
+}
+
+func TestConfigSources(t *testing.T) {
+	os.Clearenv()
+	gs.Setenv("GS_SPRING_CONFIG_DOTENV", "testdata/source/.env,optional:testdata/source/none.env")
+	gs.Setenv("GS_SPRING_CONFIG_DIRS", "testdata/source/configtree")
+	gs.Setenv("GS_DB_USER", "admin")
+	app := startApplication("testdata/source/", func(ctx gs.Context) {
+		assert.Equal(t, ctx.Prop("a"), "1")
+		assert.Equal(t, ctx.Prop("b"), "4")
+		assert.Equal(t, ctx.Prop("B"), "3")
+		assert.Equal(t, ctx.Prop("TOKEN"), "abc")
+		assert.Equal(t, ctx.Prop("db.host"), "localhost")
+		assert.Equal(t, ctx.Prop("db.user"), "admin")
+		assert.Equal(t, ctx.Prop("db.password"), "s3cret")
+	})
+	defer app.ShutDown("run test end")
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+)
+
+const (
+	appStarting = int32(iota) // 正在启动或者预热
+	appReady                  // 已经就绪
+	appDraining               // 正在排空流量或者已经退出
+)
+
+// WarmUp 预热钩子，在所有 AppEvent 启动完成 (web 服务器已经开始监听端口) 之后、
+// 应用进入就绪状态之前执行，适合预先加载缓存、调用关键路径以完成懒加载的初始化等。
+type WarmUp interface {
+	WarmUp(ctx context.Context) error
+}
+
+// ReadyHook 就绪钩子，所有预热钩子执行结束、应用进入就绪状态之后执行，适合通知
+// systemd 等服务管理器应用已经启动完成。
+type ReadyHook interface {
+	OnReady(ctx context.Context)
+}
+
+// WarmUpTimeout 预热钩子可以实现该接口指定自己的超时时间，返回值不大于 0 时
+// 使用 spring.warm-up.timeout 属性的值。
+type WarmUpTimeout interface {
+	WarmUpTimeout() time.Duration
+}
+
+// warmUpConfig 预热钩子的执行配置。
+type warmUpConfig struct {
+	Timeout     time.Duration `value:"${spring.warm-up.timeout:=30s}"`         // 每个钩子的超时时间
+	Parallelism int           `value:"${spring.warm-up.parallelism:=4}"`       // 同时执行的钩子数量
+	FailOnError bool          `value:"${spring.warm-up.fail-on-error:=false}"` // 钩子失败时是否退出程序
+}
+
+// Ready 应用是否已经就绪，即启动完成并且所有预热钩子都已执行结束。
+func (app *App) Ready() bool {
+	return atomic.LoadInt32(&app.ready) == appReady
+}
+
+// Health 实现 HealthIndicator 接口，应用没有就绪时返回其所处的阶段。
+func (app *App) Health(ctx context.Context) error {
+	switch atomic.LoadInt32(&app.ready) {
+	case appStarting:
+		return errors.New("application is starting")
+	case appDraining:
+		return errors.New("application is draining")
+	}
+	return nil
+}
+
+// runWarmUps 按照配置的并发数执行所有预热钩子，钩子超时之后不再等待它返回，
+// 返回所有失败的钩子的错误。
+func runWarmUps(ctx context.Context, hooks []WarmUp, config warmUpConfig) []error {
+
+	parallelism := config.Parallelism
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+
+	var (
+		mutex  sync.Mutex
+		errs   []error
+		wg     sync.WaitGroup
+		tokens = make(chan struct{}, parallelism)
+	)
+
+	for _, hook := range hooks {
+		tokens <- struct{}{}
+		wg.Add(1)
+		go func(hook WarmUp) {
+			defer func() { <-tokens; wg.Done() }()
+			start := time.Now()
+			err := runWarmUp(ctx, hook, config.Timeout)
+			if err != nil {
+				err = fmt.Errorf("warm up %T: %w", hook, err)
+				mutex.Lock()
+				errs = append(errs, err)
+				mutex.Unlock()
+				return
+			}
+			log.Infof("warm up %T finished in %v", hook, time.Since(start))
+		}(hook)
+	}
+
+	wg.Wait()
+	return errs
+}
+
+func runWarmUp(ctx context.Context, hook WarmUp, timeout time.Duration) error {
+
+	if t, ok := hook.(WarmUpTimeout); ok && t.WarmUpTimeout() > 0 {
+		timeout = t.WarmUpTimeout()
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- hook.WarmUp(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("timeout after %v", timeout)
+		}
+		return ctx.Err()
+	}
+}
+
+// warmUp 执行所有预热钩子，结束之后应用进入就绪状态。
+func (app *App) warmUp(ctx context.Context, config warmUpConfig) {
+	if len(app.WarmUps) > 0 {
+		start := time.Now()
+		errs := runWarmUps(ctx, app.WarmUps, config)
+		for _, err := range errs {
+			log.Error(err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if len(errs) > 0 && config.FailOnError {
+			app.ShutDown("warm up failed")
+			return
+		}
+		log.Infof("%d warm up hooks finished in %v", len(app.WarmUps), time.Since(start))
+	}
+	if atomic.CompareAndSwapInt32(&app.ready, appStarting, appReady) {
+		log.Info("application is ready")
+		for _, hook := range app.ReadyHooks {
+			hook.OnReady(ctx)
+		}
+	}
+}
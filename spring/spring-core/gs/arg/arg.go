@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"reflect"
 	"runtime"
+	"strings"
 
 	"github.com/go-spring/spring-base/log"
 	"github.com/go-spring/spring-base/util"
@@ -207,9 +208,17 @@ func (r *argList) get(ctx Context, fileLine string) ([]reflect.Value, error) {
 		if err != nil {
 			return nil, err
 		}
-		if v.IsValid() {
-			result = append(result, v)
+		if !v.IsValid() {
+			continue
 		}
+		// Options 参数生成一组 Option ，展开之后作为可变参数
+		if o, ok := arg.(*optionArg); ok && o.multi {
+			for i := 0; i < v.Len(); i++ {
+				result = append(result, v.Index(i))
+			}
+			continue
+		}
+		result = append(result, v)
 	}
 
 	return result, nil
@@ -276,14 +285,58 @@ func (r *argList) getArg(ctx Context, arg Arg, t reflect.Type, fileLine string)
 	return v, nil
 }
 
+// dryRun 校验所有参数的属性绑定和依赖注入，但不执行 Callable 和 Option 函数。
+func (r *argList) dryRun(ctx Context, fileLine string) error {
+
+	fnType := r.fnType
+	numIn := fnType.NumIn()
+	variadic := fnType.IsVariadic()
+
+	for idx, arg := range r.args {
+		switch g := arg.(type) {
+		case *Callable:
+			if err := g.DryRun(ctx); err != nil {
+				return err
+			}
+		case ValueArg:
+		case *optionArg:
+			if g.c != nil {
+				if ok, err := ctx.Matches(g.c); err != nil {
+					return err
+				} else if !ok {
+					continue
+				}
+			}
+			if err := g.r.DryRun(ctx); err != nil {
+				return err
+			}
+		default:
+			var t reflect.Type
+			if variadic && idx >= numIn-1 {
+				t = fnType.In(numIn - 1).Elem()
+			} else {
+				t = fnType.In(idx)
+			}
+			if _, err := r.getArg(ctx, arg, t, fileLine); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (r *argList) Len() int {
 	return len(r.args)
 }
 
 // optionArg Option 函数的参数绑定。
 type optionArg struct {
-	r *Callable
-	c cond.Condition
+	r     *Callable
+	c     cond.Condition
+	multi bool // 是否返回一组 Option
+
+	properties []string            // 依赖的属性
+	beans      []cond.BeanSelector // 依赖的 bean
 }
 
 // Provide 为 Option 方法绑定运行时参数。
@@ -306,12 +359,82 @@ func Option(fn interface{}, args ...Arg) *optionArg {
 	return &optionArg{r: r}
 }
 
+// Options 返回生成一组 Option 的函数的参数绑定，fn 通常接收一个绑定了属性的
+// 配置结构体，然后返回所有的 Option ，这些 Option 会被展开作为构造函数的可变参数，
+// 从而不必为每个 Option 都单独声明一次绑定。
+func Options(fn interface{}, args ...Arg) *optionArg {
+
+	t := reflect.TypeOf(fn)
+	if t.Kind() != reflect.Func || t.NumOut() != 1 || t.Out(0).Kind() != reflect.Slice {
+		panic(errors.New("invalid options func"))
+	}
+
+	r, err := Bind(fn, args, 1)
+	util.Panic(err).When(err != nil)
+	return &optionArg{r: r, multi: true}
+}
+
 // On 设置一个 cond.Condition 对象。
 func (arg *optionArg) On(c cond.Condition) *optionArg {
 	arg.c = c
 	return arg
 }
 
+// Require 声明 Option 函数依赖的属性，IoC 容器在刷新时而不是调用时校验这些属性
+// 是否存在，条件不成立的 Option 不做校验。
+func (arg *optionArg) Require(keys ...string) *optionArg {
+	arg.properties = append(arg.properties, keys...)
+	return arg
+}
+
+// RequireBean 声明 Option 函数依赖的 bean ，校验时机同 Require 。
+func (arg *optionArg) RequireBean(selectors ...cond.BeanSelector) *optionArg {
+	arg.beans = append(arg.beans, selectors...)
+	return arg
+}
+
+// verify 校验 Option 函数声明的依赖，返回缺失的依赖。
+func (arg *optionArg) verify(ctx cond.Context) ([]string, error) {
+
+	if arg.c != nil {
+		if ok, err := arg.c.Matches(ctx); err != nil || !ok {
+			return nil, err
+		}
+	}
+
+	var missing []string
+	for _, key := range arg.properties {
+		if !ctx.Has(key) {
+			missing = append(missing, fmt.Sprintf("property %q", key))
+		}
+	}
+	for _, selector := range arg.beans {
+		beans, err := ctx.Find(selector)
+		if err != nil {
+			return nil, err
+		}
+		if len(beans) == 0 {
+			name, ok := selector.(string)
+			if !ok {
+				name = internal.TypeName(selector)
+			}
+			missing = append(missing, fmt.Sprintf("bean %q", name))
+		}
+	}
+
+	// Option 函数的参数也可能是 Option 。
+	for _, a := range arg.r.argList.args {
+		if o, ok := a.(*optionArg); ok {
+			m, err := o.verify(ctx)
+			if err != nil {
+				return nil, err
+			}
+			missing = append(missing, m...)
+		}
+	}
+	return missing, nil
+}
+
 func (arg *optionArg) call(ctx Context) (reflect.Value, error) {
 
 	var (
@@ -393,6 +516,34 @@ func (r *Callable) Call(ctx Context) ([]reflect.Value, error) {
 	return out, nil
 }
 
+// DryRun 校验函数参数的属性绑定和依赖注入但不执行函数，由 IoC 容器在试运行时调用。
+func (r *Callable) DryRun(ctx Context) error {
+	return r.argList.dryRun(ctx, r.fileLine)
+}
+
+// Verify 校验所有 Option 参数声明的依赖，由 IoC 容器在刷新时调用，这样缺失的
+// 属性和 bean 可以在创建任何 bean 之前一次性报告出来。
+func (r *Callable) Verify(ctx cond.Context) error {
+	var missing []string
+	for _, a := range r.argList.args {
+		o, ok := a.(*optionArg)
+		if !ok {
+			continue
+		}
+		m, err := o.verify(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range m {
+			missing = append(missing, fmt.Sprintf("%s (option %s)", s, o.r.fileLine))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
 func (r *Callable) Arg(i int) (Arg, bool) {
 	if i >= r.argList.Len() {
 		return nil, false
@@ -25,6 +25,7 @@ import (
 
 	"github.com/go-spring/spring-base/log"
 	"github.com/go-spring/spring-base/util"
+	"github.com/go-spring/spring-core/conf"
 	"github.com/go-spring/spring-core/grpc"
 	"github.com/go-spring/spring-core/gs/arg"
 	"github.com/go-spring/spring-core/web"
@@ -78,16 +79,50 @@ func (s *startup) Run() error {
 	return gApp.Run()
 }
 
+func (s *startup) DryRun() error {
+	for _, f := range gInits {
+		f(s)
+	}
+	return gApp.DryRun()
+}
+
+func (s *startup) Execute(command string, fn func(ctx Context) error) error {
+	for _, f := range gInits {
+		f(s)
+	}
+	return gApp.Execute(command, fn)
+}
+
 // Run 启动程序。
 func Run() error {
 	return Web(true).Run()
 }
 
+// DryRun 参考 App.DryRun 的解释。
+func DryRun() error {
+	return Web(true).DryRun()
+}
+
+// Execute 参考 App.Execute 的解释，以命令方式执行时默认不启动 web 服务器。
+func Execute(command string, fn func(ctx Context) error) error {
+	return Web(false).Execute(command, fn)
+}
+
 // ShutDown 停止程序。
 func ShutDown(msg ...string) {
 	gApp.ShutDown(msg...)
 }
 
+// Ready 参考 App.Ready 的解释。
+func Ready() bool {
+	return gApp != nil && gApp.Ready()
+}
+
+// Drain 参考 App.Drain 的解释。
+func Drain() {
+	gApp.Drain()
+}
+
 // Banner 参考 App.Banner 的解释。
 func Banner(banner string) {
 	gApp.Banner(banner)
@@ -108,6 +143,21 @@ func Property(key string, value interface{}) {
 	app().Property(key, value)
 }
 
+// Schema 参考 App.Schema 的解释。
+func Schema(s *conf.Schema) {
+	app().Schema(s)
+}
+
+// Defaults 参考 App.Defaults 的解释，通常在模块的 init 函数中调用。
+func Defaults(module string, props map[string]interface{}, profiles ...string) {
+	app().Defaults(module, props, profiles...)
+}
+
+// Documentation 参考 App.Documentation 的解释，需要在 Run 之前调用。
+func Documentation() (*BeanDocumentation, error) {
+	return app().Documentation()
+}
+
 // Object 参考 Container.Object 的解释。
 func Object(i interface{}) *BeanDefinition {
 	return app().c.register(NewBean(reflect.ValueOf(i)))
@@ -208,6 +258,11 @@ func StaticFS(prefix string, fs http.FileSystem) *web.Mapper {
 	return app().StaticFS(prefix, fs)
 }
 
+// Versioning 参考 App.Versioning 的解释。
+func Versioning(config web.VersionConfig) *web.VersionRouter {
+	return app().Versioning(config)
+}
+
 // Consume 参考 App.Consume 的解释。
 func Consume(fn interface{}, topics ...string) {
 	app().Consume(fn, topics...)
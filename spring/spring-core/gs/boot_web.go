@@ -21,56 +21,100 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/go-spring/spring-core/gs/cond"
+	"github.com/go-spring/spring-core/tlsconfig"
 	"github.com/go-spring/spring-core/web"
+	"github.com/go-spring/spring-core/web/nethttp"
 )
 
+// WebContainerType 选择使用的 web 容器，如 gin、echo、nethttp ，没有设置时注册所有
+// 引入的 web 容器，内置的 nethttp 容器只在显式选择时注册。
+const WebContainerType = "web.container.type"
+
 func init() {
 	gInits = append(gInits, func(s *startup) {
 		if s.web {
 			Object(new(WebStarter)).Export((*AppEvent)(nil))
+			Provide(nethttp.New, "${web.server}").
+				On(cond.OnProperty(WebContainerType, cond.HavingValue("nethttp"))).
+				Describe("web container based on net/http, configured by web.server.*")
 		}
 	})
 }
 
 // WebStarter Web 服务器启动器
 type WebStarter struct {
-	Containers []web.Server `autowire:""`
-	Filters    []web.Filter `autowire:"${web.server.filters:=*?}"`
-	Router     web.Router   `autowire:""`
+	Containers []web.Server        `autowire:""`
+	Filters    []web.Filter        `autowire:"${web.server.filters:=*?}"`
+	Prefilters []*web.Prefilter    `autowire:"${web.server.prefilters:=*?}"`
+	Router     web.Router          `autowire:""`
+	App        *App                `autowire:"?"` // 多个应用运行在同一个进程时只关闭所属的应用
+	TLS        *tlsconfig.Provider `autowire:"?"` // 开启了 HTTPS 但是没有配置证书文件的服务器使用
 }
 
 // OnAppStart 应用程序启动事件。
 func (starter *WebStarter) OnAppStart(ctx Context) {
 	for _, c := range starter.Containers {
 		c.AddFilter(starter.Filters...)
+		c.AddPrefilter(starter.Prefilters...)
+		if config := c.Config(); starter.TLS != nil && config.EnableSSL && config.CertFile == "" {
+			c.SetTLSConfig(starter.TLS.ServerConfig())
+		}
 	}
 	for _, m := range starter.Router.Mappers() {
-		for _, c := range starter.getContainers(m) {
-			c.AddMapper(web.NewMapper(m.Method(), m.Path(), m.Handler()))
+		// 路由地址可以包含属性引用，如 ${api.base-path}/users 。
+		path, err := ctx.Resolve(m.Path())
+		if err != nil {
+			starter.shutDown(err.Error())
+			return
+		}
+		for _, c := range starter.getContainers(path) {
+			c.AddMapper(web.NewMapper(m.Method(), path, m.Handler()).WithPolicy(m.Policy()))
 		}
 	}
 	starter.startContainers(ctx)
 }
 
-func (starter *WebStarter) getContainers(mapper *web.Mapper) []web.Server {
+func (starter *WebStarter) getContainers(path string) []web.Server {
 	var ret []web.Server
 	for _, c := range starter.Containers {
-		if strings.HasPrefix(mapper.Path(), c.Config().BasePath) {
+		if strings.HasPrefix(path, c.Config().BasePath) {
 			ret = append(ret, c)
 		}
 	}
 	return ret
 }
 
+// startContainers 启动所有 web 服务器，并等待它们开始监听端口，这样后续的
+// 预热钩子执行时服务器已经可以接收请求。
 func (starter *WebStarter) startContainers(ctx Context) {
+	exited := make([]chan struct{}, len(starter.Containers))
 	for i := range starter.Containers {
 		c := starter.Containers[i]
+		exited[i] = make(chan struct{})
+		done := exited[i]
 		ctx.Go(func(_ context.Context) {
+			defer close(done)
 			if err := c.Start(); err != nil && err != http.ErrServerClosed {
-				ShutDown(err.Error())
+				starter.shutDown(err.Error())
 			}
 		})
 	}
+	for i, c := range starter.Containers {
+		select {
+		case <-c.Started():
+		case <-exited[i]:
+			return
+		}
+	}
+}
+
+func (starter *WebStarter) shutDown(msg string) {
+	if starter.App != nil {
+		starter.App.ShutDown(msg)
+		return
+	}
+	ShutDown(msg)
 }
 
 // OnAppStop 应用程序结束事件。
@@ -0,0 +1,114 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs_test
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/web"
+)
+
+// fakeServer 只记录注册的路由，不监听端口。
+type fakeServer struct {
+	web.Server
+	mutex   sync.Mutex
+	paths   []string
+	started chan struct{}
+	stopped chan struct{}
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{started: make(chan struct{}), stopped: make(chan struct{})}
+}
+
+func (s *fakeServer) Config() web.ServerConfig              { return web.ServerConfig{} }
+func (s *fakeServer) AddFilter(filter ...web.Filter)        {}
+func (s *fakeServer) AddPrefilter(filter ...*web.Prefilter) {}
+func (s *fakeServer) Started() <-chan struct{}              { return s.started }
+
+func (s *fakeServer) AddMapper(m *web.Mapper) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.paths = append(s.paths, m.Path())
+}
+
+func (s *fakeServer) Paths() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.paths...)
+}
+
+func (s *fakeServer) Start() error {
+	close(s.started)
+	<-s.stopped
+	return nil
+}
+
+func (s *fakeServer) Stop(ctx context.Context) error {
+	close(s.stopped)
+	return nil
+}
+
+func runWebApp(props map[string]string) (*gs.App, *fakeServer, chan error) {
+	os.Clearenv()
+	app := gs.NewApp()
+	for k, v := range props {
+		app.Property(k, v)
+	}
+	server := newFakeServer()
+	app.Object(server).Export((*web.Server)(nil))
+	app.Object(new(gs.WebStarter)).Export((*gs.AppEvent)(nil))
+	app.GetMapping("${api.base-path}/users", func(ctx web.Context) {})
+	done := make(chan error, 1)
+	go func() { done <- app.Run() }()
+	return app, server, done
+}
+
+func TestWebStarter_ResolvePath(t *testing.T) {
+
+	t.Run("resolved", func(t *testing.T) {
+		app, server, done := runWebApp(map[string]string{"api.base-path": "/v1"})
+		select {
+		case <-server.Started():
+		case err := <-done:
+			t.Fatalf("app exited: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("server not started")
+		}
+		assert.Equal(t, server.Paths(), []string{"/v1/users"})
+		app.ShutDown("run test end")
+		assert.Nil(t, <-done)
+	})
+
+	// 无法解析的路由地址会关闭应用，而不是以原样注册到服务器。
+	t.Run("unresolvable", func(t *testing.T) {
+		_, server, done := runWebApp(nil)
+		select {
+		case err := <-done:
+			assert.Nil(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("app should be shut down")
+		}
+		assert.Equal(t, len(server.Paths()), 0)
+	})
+}
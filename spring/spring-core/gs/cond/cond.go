@@ -21,13 +21,11 @@ package cond
 
 import (
 	"errors"
-	"go/token"
-	"go/types"
-	"strconv"
+	"fmt"
 	"strings"
 
-	"github.com/go-spring/spring-base/util"
 	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/expr"
 	"github.com/go-spring/spring-core/gs/internal"
 )
 
@@ -81,7 +79,6 @@ type onProperty struct {
 }
 
 func (c *onProperty) Matches(ctx Context) (bool, error) {
-	// 参考 /usr/local/go/src/go/types/eval_test.go 示例
 
 	if !ctx.Has(c.name) {
 		return c.matchIfMissing, nil
@@ -96,20 +93,9 @@ func (c *onProperty) Matches(ctx Context) (bool, error) {
 		return val == c.havingValue, nil
 	}
 
-	var expr string
-	if _, err := strconv.ParseBool(val); err == nil {
-		expr = strings.ReplaceAll(c.havingValue[3:], "$", val)
-	} else if _, err = strconv.ParseFloat(val, 64); err == nil {
-		expr = strings.ReplaceAll(c.havingValue[3:], "$", val)
-	} else {
-		expr = strings.ReplaceAll(c.havingValue[3:], "$", strconv.Quote(val))
-	}
-
-	ret, err := types.Eval(token.NewFileSet(), nil, token.NoPos, expr)
-	if err != nil {
-		return false, err
-	}
-	return strconv.ParseBool(ret.Value.String())
+	env := newEnv(ctx)
+	env.Value = expr.Literal(val)
+	return expr.EvalBool(c.havingValue[3:], env)
 }
 
 // onMissingProperty 基于属性值不存在的 Condition 实现。
@@ -157,7 +143,36 @@ type onExpression struct {
 }
 
 func (c *onExpression) Matches(ctx Context) (bool, error) {
-	return false, util.UnimplementedMethod
+	return expr.EvalBool(c.expression, newEnv(ctx))
+}
+
+// newEnv 返回条件表达式的求值环境，支持 ${key:=def} 属性引用以及 bean(selector)
+// 函数，后者返回是否存在符合条件的 bean 。
+func newEnv(ctx Context) expr.Env {
+	return expr.Env{
+		Resolve: func(s string) (string, error) {
+			tag, err := conf.ParseTag(s)
+			if err != nil {
+				return "", err
+			}
+			if ctx.Has(tag.Key) {
+				return ctx.Prop(tag.Key), nil
+			}
+			if tag.HasDef {
+				return tag.Def, nil
+			}
+			return "", fmt.Errorf("property %q not exist", tag.Key)
+		},
+		Funcs: map[string]expr.Func{
+			"bean": func(args ...interface{}) (interface{}, error) {
+				if len(args) != 1 {
+					return nil, errors.New("bean requires 1 argument")
+				}
+				beans, err := ctx.Find(args[0])
+				return len(beans) > 0, err
+			},
+		},
+	}
 }
 
 // Operator 条件操作符，包含 Or、And、None 三种。
@@ -379,7 +394,8 @@ func (c *conditional) OnSingleBean(selector BeanSelector) *conditional {
 	return c.On(&onSingleBean{selector: selector})
 }
 
-// OnExpression 返回一个以 onExpression 为开始条件的计算式。
+// OnExpression 返回一个以 onExpression 为开始条件的计算式，表达式语法参见 expr 包，
+// 例如 ${server.port:=8080} > 1024 && bean("redis") 。
 func OnExpression(expression string) *conditional {
 	return New().OnExpression(expression)
 }
@@ -408,3 +424,107 @@ func OnProfile(profile string) *conditional {
 func (c *conditional) OnProfile(profile string) *conditional {
 	return c.OnProperty("spring.profiles.active", HavingValue(profile))
 }
+
+// OnWebContainer 返回一个以 web.container.type 属性值是否匹配为开始条件的计算式，
+// 没有设置该属性时条件成立，这样只引入一个 web 容器的应用不需要额外的配置。
+func OnWebContainer(name string) *conditional {
+	return New().OnWebContainer(name)
+}
+
+// OnWebContainer 添加一个 web.container.type 属性值是否匹配的条件。
+func (c *conditional) OnWebContainer(name string) *conditional {
+	return c.OnProperty("web.container.type", HavingValue(name), MatchIfMissing())
+}
+
+// Describe 返回条件的文字描述，供生成文档等场景使用，自定义的 Condition 实现可以
+// 通过 fmt.Stringer 接口提供自己的描述。
+func Describe(c Condition) string {
+	switch v := c.(type) {
+	case nil:
+		return ""
+	case *not:
+		return "!(" + Describe(v.c) + ")"
+	case *onProperty:
+		s := "property(" + v.name
+		if v.havingValue != "" {
+			s += "=" + v.havingValue
+		}
+		if v.matchIfMissing {
+			s += ",matchIfMissing"
+		}
+		return s + ")"
+	case *onMissingProperty:
+		return "missingProperty(" + v.name + ")"
+	case *onBean:
+		return "bean(" + describeSelector(v.selector) + ")"
+	case *onMissingBean:
+		return "missingBean(" + describeSelector(v.selector) + ")"
+	case *onSingleBean:
+		return "singleBean(" + describeSelector(v.selector) + ")"
+	case *onExpression:
+		return "expression(" + v.expression + ")"
+	case *group:
+		var arr []string
+		for _, c := range v.cond {
+			arr = append(arr, Describe(c))
+		}
+		switch v.op {
+		case Or:
+			return "(" + strings.Join(arr, " || ") + ")"
+		case None:
+			return "!(" + strings.Join(arr, " || ") + ")"
+		}
+		return "(" + strings.Join(arr, " && ") + ")"
+	case *conditional:
+		var s string
+		for n := v.head; n != nil && n.cond != nil; n = n.next {
+			s += Describe(n.cond)
+			if n.next != nil && n.next.cond != nil {
+				if n.op == Or {
+					s += " || "
+				} else {
+					s += " && "
+				}
+			}
+		}
+		return s
+	case fmt.Stringer:
+		return v.String()
+	}
+	return "func"
+}
+
+func describeSelector(selector BeanSelector) string {
+	if s, ok := selector.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%T", selector)
+}
+
+// Properties 返回条件中引用的属性名称，不包括表达式中引用的属性。
+func Properties(c Condition) []string {
+	var keys []string
+	var walk func(c Condition)
+	walk = func(c Condition) {
+		switch v := c.(type) {
+		case *not:
+			walk(v.c)
+		case *onProperty:
+			keys = append(keys, v.name)
+		case *onMissingProperty:
+			keys = append(keys, v.name)
+		case *group:
+			for _, c := range v.cond {
+				walk(c)
+			}
+		case *conditional:
+			for n := v.head; n != nil; n = n.next {
+				if n.cond != nil {
+					walk(n.cond)
+				}
+			}
+		}
+	}
+	walk(c)
+	return keys
+}
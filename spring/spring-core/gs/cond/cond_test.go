@@ -351,8 +351,17 @@ func TestOnExpression(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	ctx := cond.NewMockContext(ctrl)
-	ok, err := cond.OnExpression("").Matches(ctx)
-	assert.Error(t, err, "unimplemented method")
+	ctx.EXPECT().Has("a").Return(true)
+	ctx.EXPECT().Prop("a").Return("3")
+	ctx.EXPECT().Has("b").Return(false)
+	ctx.EXPECT().Find("redis").Return([]cond.BeanDefinition{
+		internal.NewMockBeanDefinition(nil),
+	}, nil)
+	ok, err := cond.OnExpression(`${a} > 1 && ${b:=x} == "x" && bean("redis")`).Matches(ctx)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = cond.OnExpression("").Matches(ctx)
+	assert.Error(t, err, "parse expression")
 	assert.False(t, ok)
 }
 
@@ -399,6 +408,38 @@ func TestOnProfile(t *testing.T) {
 	})
 }
 
+func TestOnWebContainer(t *testing.T) {
+	t.Run("no property", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := cond.NewMockContext(ctrl)
+		ctx.EXPECT().Has("web.container.type").Return(false)
+		ok, err := cond.OnWebContainer("gin").Matches(ctx)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+	})
+	t.Run("diff property", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := cond.NewMockContext(ctrl)
+		ctx.EXPECT().Has("web.container.type").Return(true)
+		ctx.EXPECT().Prop("web.container.type").Return("echo")
+		ok, err := cond.OnWebContainer("gin").Matches(ctx)
+		assert.Nil(t, err)
+		assert.False(t, ok)
+	})
+	t.Run("same property", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := cond.NewMockContext(ctrl)
+		ctx.EXPECT().Has("web.container.type").Return(true)
+		ctx.EXPECT().Prop("web.container.type").Return("gin")
+		ok, err := cond.OnWebContainer("gin").Matches(ctx)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+	})
+}
+
 func TestConditional(t *testing.T) {
 	t.Run("ok && ", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
@@ -468,3 +509,14 @@ func TestGroup(t *testing.T) {
 		assert.True(t, ok)
 	})
 }
+
+func TestDescribe(t *testing.T) {
+	c := cond.OnProperty("a", cond.HavingValue("1")).
+		Or().OnMissingBean("redis").
+		And().On(cond.Not(cond.OnMissingProperty("b")))
+	assert.Equal(t, cond.Describe(c), "property(a=1) || missingBean(redis) && !(missingProperty(b))")
+	assert.Equal(t, cond.Properties(c), []string{"a", "b"})
+	g := cond.Group(cond.Or, cond.OnProfile("dev"), cond.OnExpression("${x} > 1"))
+	assert.Equal(t, cond.Describe(g), "(property(spring.profiles.active=dev) || expression(${x} > 1))")
+	assert.Equal(t, cond.Describe(cond.OK()), "func")
+}
@@ -24,20 +24,34 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/url"
 	"reflect"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/go-spring/spring-base/cast"
 	"github.com/go-spring/spring-base/log"
 	"github.com/go-spring/spring-base/util"
 	"github.com/go-spring/spring-core/conf"
 	"github.com/go-spring/spring-core/gs/arg"
 	"github.com/go-spring/spring-core/gs/cond"
 	"github.com/go-spring/spring-core/gs/internal"
+	"github.com/go-spring/spring-core/report"
 )
 
+// SpringInitTimeout 整个容器刷新过程的超时时间，默认不限制。超时后正在执行的初始化
+// 函数被放弃，容器刷新失败。
+const SpringInitTimeout = "spring.init.timeout"
+
+// errInitAbandoned 初始化函数超时被放弃之后仍然尝试访问容器。
+var errInitAbandoned = errors.New("bean init is abandoned after timeout")
+
+// ErrRegisterAfterRefresh 容器开始刷新之后不允许再注册 bean ，否则会引发此错误。
+var ErrRegisterAfterRefresh = errors.New("should call before Refresh")
+
 type refreshState int
 
 const (
@@ -49,6 +63,8 @@ const (
 type Container interface {
 	Context() context.Context
 	Property(key string, value interface{})
+	Schema(s *conf.Schema)
+	Defaults(module string, props map[string]interface{}, profiles ...string)
 	Object(i interface{}) *BeanDefinition
 	Provide(ctor interface{}, args ...arg.Arg) *BeanDefinition
 	Refresh(opts ...internal.RefreshOption) error
@@ -69,11 +85,20 @@ type Context interface {
 	Keys() []string
 	Has(key string) bool
 	Prop(key string, opts ...conf.GetOption) string
+	PropertySource(key string) string
+	Resolve(s string) (string, error)
 	Bind(i interface{}, opts ...conf.BindOption) error
 	Get(i interface{}, selectors ...BeanSelector) error
 	Wire(objOrCtor interface{}, ctorArgs ...arg.Arg) (interface{}, error)
 	Invoke(fn interface{}, args ...arg.Arg) ([]interface{}, error)
 	Go(fn func(ctx context.Context))
+	Frozen() *Snapshot
+	Dynamic() *DynamicRegistry
+	Report() (*Report, error)
+	Snapshot() (*ContextSnapshot, error)
+	Documentation() (*BeanDocumentation, error)
+	Footprint() (*FootprintReport, error)
+	Goroutines() []GoroutineInfo
 }
 
 type tempContainer struct {
@@ -82,6 +107,12 @@ type tempContainer struct {
 	beansByName     map[string][]*BeanDefinition
 	beansByType     map[reflect.Type][]*BeanDefinition
 	mapOfOnProperty map[string]interface{}
+	depends         map[*BeanDefinition][]*BeanDefinition // 注入过程中记录的依赖关系
+	deleted         map[*BeanDefinition]string            // bean 被删除的原因
+	wiringEvents    []WiringEvent                         // 刷新过程中的装配决策
+	schemas         []*conf.Schema                        // 各模块声明的属性约束
+	sources         map[string]string                     // 属性的来源，如属性文件的路径
+	defaults        []*propertyDefaults                   // 各模块声明的默认属性
 }
 
 // container 是 go-spring 框架的基石，实现了 Martin Fowler 在 << Inversion
@@ -97,24 +128,52 @@ type container struct {
 	cancel     context.CancelFunc
 	destroyers []func()
 	state      refreshState
+	frozen     *Snapshot
+	dynamic    *DynamicRegistry
 	wg         sync.WaitGroup
+
+	dynamicMutex sync.Mutex // 保护刷新之后动态注册对容器状态的修改
+
+	initDeadline time.Time       // 容器刷新的截止时间，为零值时不限制
+	initPanic    InitPanicPolicy // 全局的 bean 初始化 panic 处理策略
+	dryRun       bool            // 是否以试运行的方式刷新
+
+	initFailures map[string]error // 初始化 panic 之后被隔离的 bean
+	audit        auditor          // 线程安全审计
+
+	goroutines goroutineTracker // 通过 Go 方法创建的 goroutine
+	goTimeout  time.Duration    // 关闭时等待 goroutine 退出的最长时间
+
+	props       map[string]string // 隐藏了敏感信息的属性，供上下文快照使用
+	propSources map[string]string // 属性的来源，供上下文快照使用
 }
 
 // New 创建 IoC 容器。
 func New() Container {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &container{
-		ctx:    ctx,
-		cancel: cancel,
+		ctx:       ctx,
+		cancel:    cancel,
+		goTimeout: defaultGoroutineTimeout,
 		tempContainer: &tempContainer{
 			p:               conf.New(),
 			beansByName:     make(map[string][]*BeanDefinition),
 			beansByType:     make(map[reflect.Type][]*BeanDefinition),
 			mapOfOnProperty: make(map[string]interface{}),
+			depends:         make(map[*BeanDefinition][]*BeanDefinition),
+			deleted:         make(map[*BeanDefinition]string),
+			sources:         make(map[string]string),
 		},
 	}
 }
 
+// DryRunMode 设置容器是否以试运行的方式刷新。试运行时执行条件判断、属性绑定和依赖
+// 查找，但不执行构造函数和初始化函数，字段在临时创建的对象上绑定和注入，因此不会创
+// 建任何 bean ，也不会打开任何连接。
+func DryRunMode(enable bool) internal.RefreshOption {
+	return internal.DryRun(enable)
+}
+
 // Context 返回 IoC 容器的 ctx 对象。
 func (c *container) Context() context.Context {
 	return c.ctx
@@ -149,9 +208,13 @@ func (c *container) Property(key string, value interface{}) {
 
 func (c *container) register(b *BeanDefinition) *BeanDefinition {
 	if c.state != Unrefreshed {
-		panic(errors.New("should call before Refresh"))
+		if c.audit.isEnabled() {
+			c.audit.violate(c.ctx, AuditRegisterAfterRefresh)
+		}
+		panic(ErrRegisterAfterRefresh)
 	}
 	c.beans = append(c.beans, b)
+	c.beans = append(c.beans, b.results...)
 	return b
 }
 
@@ -259,8 +322,15 @@ func (s *wiringStack) sortDestroyers() []func() {
 
 	destroy := func(v reflect.Value, f interface{}) func() {
 		return func() {
+			if d, ok := v.Interface().(DisposableBean); ok {
+				if err := d.Destroy(); err != nil {
+					log.Error(err)
+				}
+			}
 			if f == nil {
-				v.Interface().(BeanDestroy).OnDestroy()
+				if d, ok := v.Interface().(BeanDestroy); ok {
+					d.OnDestroy()
+				}
 			} else {
 				fnValue := reflect.ValueOf(f)
 				out := fnValue.Call([]reflect.Value{v})
@@ -292,6 +362,19 @@ func (c *container) clear() {
 // Refresh 刷新容器的内容，对 bean 进行有效性判断以及完成属性绑定和依赖注入。
 func (c *container) Refresh(opts ...internal.RefreshOption) (err error) {
 
+	if c.state != Unrefreshed {
+		return errors.New("container already refreshed")
+	}
+
+	if err = c.applyDefaults(); err != nil {
+		return err
+	}
+
+	// 在使用任何属性之前迁移已改名的属性并校验属性约束。
+	if err = c.validateSchemas(); err != nil {
+		return err
+	}
+
 	for key, f := range c.mapOfOnProperty {
 		t := reflect.TypeOf(f)
 		in := reflect.New(t.In(0)).Elem()
@@ -301,8 +384,34 @@ func (c *container) Refresh(opts ...internal.RefreshOption) (err error) {
 		reflect.ValueOf(f).Call([]reflect.Value{in})
 	}
 
-	if c.state != Unrefreshed {
-		return errors.New("container already refreshed")
+	if s := c.p.Get(SpringInitTimeout); s != "" {
+		var d time.Duration
+		if d, err = cast.ToDurationE(s); err != nil {
+			return fmt.Errorf("property %q error: %w", SpringInitTimeout, err)
+		}
+		if d > 0 {
+			c.initDeadline = time.Now().Add(d)
+			defer func() { c.initDeadline = time.Time{} }()
+		}
+	}
+
+	if c.initPanic, err = parseInitPanicPolicy(c.p.Get(SpringInitPanic)); err != nil {
+		return fmt.Errorf("property %q error: %w", SpringInitPanic, err)
+	}
+
+	if s := c.p.Get(SpringShutdownGoroutineTimeout); s != "" {
+		if c.goTimeout, err = cast.ToDurationE(s); err != nil {
+			return fmt.Errorf("property %q error: %w", SpringShutdownGoroutineTimeout, err)
+		}
+	}
+
+	if s := c.p.Get(SpringAuditEnabled); s != "" {
+		var enabled bool
+		if enabled, err = cast.ToBoolE(s); err != nil {
+			return fmt.Errorf("property %q error: %w", SpringAuditEnabled, err)
+		}
+		c.audit.startRefresh(enabled)
+		defer c.audit.endRefresh()
 	}
 
 	start := time.Now()
@@ -311,6 +420,7 @@ func (c *container) Refresh(opts ...internal.RefreshOption) (err error) {
 	for _, opt := range opts {
 		opt(optArg)
 	}
+	c.dryRun = optArg.DryRun
 
 	c.Object(c).Export((*Context)(nil))
 	c.state = Refreshing
@@ -325,6 +435,9 @@ func (c *container) Refresh(opts ...internal.RefreshOption) (err error) {
 		}
 	}
 
+	c.resolveFallbacks()
+	c.resolvePrimaries()
+
 	beansById := make(map[string]*BeanDefinition)
 	{
 		for _, b := range c.beans {
@@ -342,6 +455,16 @@ func (c *container) Refresh(opts ...internal.RefreshOption) (err error) {
 		}
 	}
 
+	// 在创建任何 bean 之前校验 Option 函数声明的依赖。
+	for _, b := range c.beans {
+		if b.status == Deleted || b.f == nil {
+			continue
+		}
+		if err = b.f.Verify(c); err != nil {
+			return fmt.Errorf("%s: %w", b, err)
+		}
+	}
+
 	stack := newWiringStack()
 
 	defer func() {
@@ -374,7 +497,13 @@ func (c *container) Refresh(opts ...internal.RefreshOption) (err error) {
 		}
 	}
 
-	c.destroyers = stack.sortDestroyers()
+	// 试运行时没有创建任何 bean ，也就无需销毁。
+	if !c.dryRun {
+		c.destroyers = stack.sortDestroyers()
+		c.sanitizeProperties()
+	}
+	c.frozen = newSnapshot(c.beans)
+	c.dynamic = newDynamicRegistry(c)
 	c.state = Refreshed
 
 	cost := time.Now().Sub(start)
@@ -398,6 +527,57 @@ func (c *container) registerBean(b *BeanDefinition) {
 	}
 }
 
+// resolveFallbacks 在所有 bean 的有效性确定之后处理默认实现，如果接口存在其他
+// 实现，则默认实现不再作为该接口的候选，所有接口都存在其他实现时默认实现被删除。
+func (c *container) resolveFallbacks() {
+	for _, b := range c.beans {
+		if b.status == Deleted || len(b.fallbacks) == 0 {
+			continue
+		}
+		var replaced []string
+		for _, t := range b.fallbacks {
+			var others []*BeanDefinition
+			for _, d := range c.beansByType[t] {
+				if d != b && d.status != Deleted && !d.isFallbackFor(t) {
+					others = append(others, d)
+				}
+			}
+			if len(others) == 0 {
+				c.addWiringEvent(WiringEvent{
+					Kind:   WiringFallback,
+					Bean:   b.ID(),
+					Type:   t.String(),
+					Reason: "no other candidate",
+				})
+				if b.HasTag(StubTag) {
+					log.Warnf("%s has no implementation, use stub %s", t, b)
+				}
+				continue
+			}
+			for _, d := range others {
+				c.addWiringEvent(WiringEvent{
+					Kind:   WiringOverridden,
+					Bean:   d.ID(),
+					Other:  b.ID(),
+					Type:   t.String(),
+					Reason: "fallback replaced",
+				})
+			}
+			candidates := c.beansByType[t][:0]
+			for _, d := range c.beansByType[t] {
+				if d != b {
+					candidates = append(candidates, d)
+				}
+			}
+			c.beansByType[t] = candidates
+			replaced = append(replaced, fmt.Sprintf("%s by %s", t, others[0]))
+		}
+		if len(replaced) == len(b.fallbacks) {
+			c.exclude(b, "fallback replaced "+strings.Join(replaced, ", "))
+		}
+	}
+}
+
 // resolveBean 判断 bean 的有效性，如果 bean 是无效的则被标记为已删除。
 func (c *container) resolveBean(b *BeanDefinition) error {
 
@@ -407,6 +587,17 @@ func (c *container) resolveBean(b *BeanDefinition) error {
 
 	b.status = Resolving
 
+	// 构造函数的其他结果和第一个结果同时生效或者失效
+	if b.leader != nil {
+		if err := c.resolveBean(b.leader); err != nil {
+			return err
+		}
+		if b.leader.status == Deleted {
+			c.exclude(b, fmt.Sprintf("constructor bean %q deleted", b.leader.ID()))
+			return nil
+		}
+	}
+
 	// method bean 先确定 parent bean 是否存在
 	if b.method {
 		selector, ok := b.f.Arg(0)
@@ -426,7 +617,7 @@ func (c *container) resolveBean(b *BeanDefinition) error {
 			msg = msg[:len(msg)-2] + "]"
 			return errors.New(msg)
 		} else if n == 0 {
-			b.status = Deleted
+			c.exclude(b, fmt.Sprintf("parent bean %q not found", selector))
 			return nil
 		}
 	}
@@ -435,7 +626,7 @@ func (c *container) resolveBean(b *BeanDefinition) error {
 		if ok, err := b.cond.Matches(c); err != nil {
 			return err
 		} else if !ok {
-			b.status = Deleted
+			c.exclude(b, "condition not matched")
 			return nil
 		}
 	}
@@ -533,12 +724,18 @@ func (c *container) findBean(selector BeanSelector) ([]*BeanDefinition, error) {
 		return result, nil
 	}
 
+	return finder(selectorMatcher(selector))
+}
+
+// selectorMatcher 返回判断 bean 是否符合选择器的函数。
+func selectorMatcher(selector BeanSelector) func(*BeanDefinition) bool {
+
 	switch selector.(type) {
 	case string, BeanDefinition, *BeanDefinition:
 		tag := toWireTag(selector)
-		return finder(func(b *BeanDefinition) bool {
+		return func(b *BeanDefinition) bool {
 			return b.Match(tag.typeName, tag.beanName)
-		})
+		}
 	}
 
 	t := reflect.TypeOf(selector)
@@ -548,7 +745,7 @@ func (c *container) findBean(selector BeanSelector) ([]*BeanDefinition, error) {
 		}
 	}
 
-	return finder(func(b *BeanDefinition) bool {
+	return func(b *BeanDefinition) bool {
 		if b.Type() == t {
 			return true
 		}
@@ -558,7 +755,7 @@ func (c *container) findBean(selector BeanSelector) ([]*BeanDefinition, error) {
 			}
 		}
 		return false
-	})
+	}
 }
 
 // wireBean 对 bean 进行属性绑定和依赖注入，同时追踪其注入路径。如果 bean 有初始
@@ -584,7 +781,7 @@ func (c *container) wireBean(b *BeanDefinition, stack *wiringStack) error {
 	}()
 
 	// 记录注入路径上的销毁函数及其执行的先后顺序。
-	if _, ok := b.Interface().(BeanDestroy); ok || b.destroy != nil {
+	if hasDestroy(b) {
 		haveDestroy = true
 		d := stack.saveDestroyer(b)
 		if i := stack.destroyers.Back(); i != nil {
@@ -595,6 +792,10 @@ func (c *container) wireBean(b *BeanDefinition, stack *wiringStack) error {
 
 	stack.pushBack(b)
 
+	if n := len(stack.beans); c.state == Refreshing && n > 1 {
+		c.addDepend(stack.beans[n-2], b)
+	}
+
 	if b.status == Creating && b.f != nil {
 		prev := stack.beans[len(stack.beans)-2]
 		if prev.status == Creating {
@@ -607,6 +808,10 @@ func (c *container) wireBean(b *BeanDefinition, stack *wiringStack) error {
 		return nil
 	}
 
+	if err := c.checkDeadline(); err != nil {
+		return err
+	}
+
 	b.status = Creating
 
 	// 对当前 bean 的间接依赖项进行注入。
@@ -623,6 +828,15 @@ func (c *container) wireBean(b *BeanDefinition, stack *wiringStack) error {
 		}
 	}
 
+	if c.dryRun {
+		if err := c.dryRunBean(b, stack); err != nil {
+			return err
+		}
+		b.status = Wired
+		stack.popBack()
+		return nil
+	}
+
 	v, err := c.getBeanValue(b, stack)
 	if err != nil {
 		return err
@@ -642,6 +856,132 @@ func (c *container) wireBean(b *BeanDefinition, stack *wiringStack) error {
 		return err
 	}
 
+	if err = c.initBean(b); err != nil {
+		return err
+	}
+
+	b.status = Wired
+	stack.popBack()
+	return nil
+}
+
+// checkDeadline 检查容器刷新是否超过了 spring.init.timeout 设置的截止时间。构造
+// 函数无法被中断，因此只在创建每个 bean 之前检查。
+func (c *container) checkDeadline() error {
+	if c.state != Refreshing || c.initDeadline.IsZero() || time.Now().Before(c.initDeadline) {
+		return nil
+	}
+	return fmt.Errorf("refresh timeout, %s exceeded", SpringInitTimeout)
+}
+
+// initBean 执行 bean 的初始化函数，设置了超时时间或者刷新截止时间时在新的 goroutine
+// 中执行并等待其结束，超时后返回错误。超时后初始化函数仍会继续执行直到其自行退出，但是
+// 它通过 OnInit 得到的容器不能再被使用，避免在刷新失败之后修改容器的状态。
+func (c *container) initBean(b *BeanDefinition) error {
+
+	timeout := b.timeout
+	if !c.initDeadline.IsZero() && c.state == Refreshing {
+		remain := time.Until(c.initDeadline)
+		if remain <= 0 {
+			return fmt.Errorf("%s init timeout, %s exceeded", b, SpringInitTimeout)
+		}
+		if timeout <= 0 || remain < timeout {
+			timeout = remain
+		}
+	}
+
+	if timeout <= 0 {
+		return c.isolateInit(b, c.safeInit(b, c))
+	}
+
+	ctx := &initContext{container: c}
+	ch := make(chan error, 1)
+	go func() {
+		c.audit.delegateInit()
+		ch <- c.safeInit(b, ctx)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-ch:
+		return c.isolateInit(b, err)
+	case <-timer.C:
+		atomic.StoreInt32(&ctx.abandoned, 1)
+		c.audit.endDelegate()
+		if b.timeout > 0 && timeout == b.timeout {
+			return fmt.Errorf("%s init timeout after %v", b, timeout)
+		}
+		return fmt.Errorf("%s init timeout, %s exceeded", b, SpringInitTimeout)
+	}
+}
+
+// initContext 传给超时执行的初始化函数的容器，初始化函数超时被放弃之后所有访问容器
+// 的方法都返回错误或者 panic ，panic 会被执行初始化函数的 goroutine 恢复。
+type initContext struct {
+	*container
+	abandoned int32
+}
+
+func (c *initContext) check() error {
+	if atomic.LoadInt32(&c.abandoned) == 1 {
+		return errInitAbandoned
+	}
+	return nil
+}
+
+func (c *initContext) Bind(i interface{}, opts ...conf.BindOption) error {
+	if err := c.check(); err != nil {
+		return err
+	}
+	return c.container.Bind(i, opts...)
+}
+
+func (c *initContext) Get(i interface{}, selectors ...BeanSelector) error {
+	if err := c.check(); err != nil {
+		return err
+	}
+	return c.container.Get(i, selectors...)
+}
+
+func (c *initContext) Wire(objOrCtor interface{}, ctorArgs ...arg.Arg) (interface{}, error) {
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	return c.container.Wire(objOrCtor, ctorArgs...)
+}
+
+func (c *initContext) Invoke(fn interface{}, args ...arg.Arg) ([]interface{}, error) {
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	return c.container.Invoke(fn, args...)
+}
+
+func (c *initContext) Go(fn func(ctx context.Context)) {
+	if err := c.check(); err != nil {
+		panic(err)
+	}
+	c.container.Go(fn)
+}
+
+func (c *initContext) Dynamic() *DynamicRegistry {
+	if err := c.check(); err != nil {
+		panic(err)
+	}
+	return c.container.Dynamic()
+}
+
+// callInit 依次执行 bean 实现的初始化接口以及设置的初始化函数。
+func (c *container) callInit(b *BeanDefinition, ctx Context) error {
+
+	if f, ok := b.Interface().(InitializingBean); ok {
+		if err := f.AfterPropertiesSet(); err != nil {
+			return err
+		}
+	}
+
 	if b.init != nil {
 		fnValue := reflect.ValueOf(b.init)
 		out := fnValue.Call([]reflect.Value{b.Value()})
@@ -651,16 +991,25 @@ func (c *container) wireBean(b *BeanDefinition, stack *wiringStack) error {
 	}
 
 	if f, ok := b.Interface().(BeanInit); ok {
-		if err = f.OnInit(c); err != nil {
+		if err := f.OnInit(ctx); err != nil {
 			return err
 		}
 	}
-
-	b.status = Wired
-	stack.popBack()
 	return nil
 }
 
+// hasDestroy 返回 bean 是否具有销毁函数或者实现了销毁接口。
+func hasDestroy(b *BeanDefinition) bool {
+	if b.destroy != nil {
+		return true
+	}
+	switch b.Interface().(type) {
+	case BeanDestroy, DisposableBean:
+		return true
+	}
+	return false
+}
+
 type argContext struct {
 	c     *container
 	stack *wiringStack
@@ -681,6 +1030,14 @@ func (a *argContext) Wire(v reflect.Value, tag string) error {
 // getBeanValue 获取 bean 的值，如果是构造函数 bean 则执行其构造函数然后返回执行结果。
 func (c *container) getBeanValue(b *BeanDefinition, stack *wiringStack) (reflect.Value, error) {
 
+	// 构造函数的其他结果从第一个结果所在的 bean 获取。
+	if b.leader != nil {
+		if err := c.wireBean(b.leader, stack); err != nil {
+			return reflect.Value{}, err
+		}
+		return c.setBeanValue(b, b.leader.outs[b.index])
+	}
+
 	if b.f == nil {
 		return b.Value(), nil
 	}
@@ -690,8 +1047,17 @@ func (c *container) getBeanValue(b *BeanDefinition, stack *wiringStack) (reflect
 		return reflect.Value{}, err /* fmt.Errorf("%s:%s return error: %v", b.getClass(), b.ID(), err) */
 	}
 
+	if len(b.results) > 0 {
+		b.outs = out
+	}
+	return c.setBeanValue(b, out[0])
+}
+
+// setBeanValue 将构造函数的结果保存到 bean 中，然后返回用于注入的值。
+func (c *container) setBeanValue(b *BeanDefinition, val reflect.Value) (reflect.Value, error) {
+
 	// 构造函数的返回值为值类型时 b.Type() 返回其指针类型。
-	if val := out[0]; internal.IsBeanType(val.Type()) {
+	if internal.IsBeanType(val.Type()) {
 		// 如果实现接口的是值类型，那么需要转换成指针类型然后再赋值给接口。
 		if !val.IsNil() && val.Kind() == reflect.Interface && conf.IsValueType(val.Elem().Type()) {
 			v := reflect.New(val.Elem().Type())
@@ -716,6 +1082,47 @@ func (c *container) getBeanValue(b *BeanDefinition, stack *wiringStack) (reflect
 	return v, nil
 }
 
+// dryRunBean 试运行时校验 bean 的构造函数参数以及字段的属性绑定和依赖注入，字段在
+// 临时创建的对象上绑定和注入，构造函数以接口类型返回时无法校验其字段。
+func (c *container) dryRunBean(b *BeanDefinition, stack *wiringStack) error {
+
+	if b.leader != nil {
+		if err := c.wireBean(b.leader, stack); err != nil {
+			return err
+		}
+	}
+
+	if b.f != nil {
+		if err := b.f.DryRun(&argContext{c: c, stack: stack}); err != nil {
+			return err
+		}
+	}
+
+	t := b.Type()
+	if t.Kind() == reflect.Interface {
+		return nil
+	}
+
+	for _, typ := range b.exports {
+		if !t.Implements(typ) {
+			return fmt.Errorf("%s doesn't implement interface %s", b, typ)
+		}
+	}
+
+	if t.Kind() != reflect.Ptr {
+		return nil
+	}
+	return c.wireBeanValue(reflect.New(t.Elem()), t, stack)
+}
+
+// beanValue 返回用于赋值给类型 t 的 bean 值，试运行时 bean 没有被创建，返回零值。
+func (c *container) beanValue(b *BeanDefinition, t reflect.Type) reflect.Value {
+	if c.dryRun {
+		return reflect.Zero(t)
+	}
+	return b.valueFor(t)
+}
+
 // wireBeanValue 对 v 进行属性绑定和依赖注入，v 在传入时应该是一个已经初始化的值。
 func (c *container) wireBeanValue(v reflect.Value, t reflect.Type, stack *wiringStack) error {
 
@@ -754,6 +1161,14 @@ func (c *container) wireStruct(v reflect.Value, opt conf.BindParam, stack *wirin
 
 		fieldPath := opt.Path + "." + ft.Name
 
+		// embed 标签显式开启对结构体字段的递归注入，通常用于嵌入的公共基础结构体。
+		if tag, ok := ft.Tag.Lookup("embed"); ok {
+			if err := c.wireEmbedded(fv, tag, opt, fieldPath, stack); err != nil {
+				return fmt.Errorf("%q wired error: %w", fieldPath, err)
+			}
+			continue
+		}
+
 		// 支持 autowire 和 inject 两个标签。
 		tag, ok := ft.Tag.Lookup("autowire")
 		if !ok {
@@ -802,10 +1217,37 @@ func (c *container) wireStruct(v reflect.Value, opt conf.BindParam, stack *wirin
 	return nil
 }
 
+// wireEmbedded 对 embed 标签修饰的结构体或者结构体指针字段进行属性绑定和依赖注入，
+// 指针为 nil 时先创建新的对象，这样通过嵌入的指针提升的字段也能完成注入。tag 不为
+// 空时作为属性前缀，格式同 value 标签。
+func (c *container) wireEmbedded(v reflect.Value, tag string, opt conf.BindParam, fieldPath string, stack *wiringStack) error {
+
+	t := v.Type()
+	if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		v = v.Elem()
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("embed should be struct or struct pointer, but %s", t)
+	}
+
+	subParam := conf.BindParam{Type: t, Key: opt.Key, Path: fieldPath}
+	if tag != "" {
+		if err := subParam.BindTag(tag); err != nil {
+			return err
+		}
+	}
+	return c.wireStruct(v, subParam, stack)
+}
+
 func (c *container) wireByTag(v reflect.Value, tag string, stack *wiringStack) error {
 
-	// tag 预处理，可能通过属性值进行指定。
-	if strings.HasPrefix(tag, "${") {
+	// tag 预处理，可能全部或者部分通过属性值进行指定，如 ${cache.impl}-cache 。
+	if strings.Contains(tag, "${") {
 		s, err := c.p.Resolve(tag)
 		if err != nil {
 			return err
@@ -817,6 +1259,10 @@ func (c *container) wireByTag(v reflect.Value, tag string, stack *wiringStack) e
 		return c.autowire(v, nil, stack)
 	}
 
+	if strings.HasPrefix(tag, collectionQueryPrefix) {
+		return c.collectBeansByQuery(v, tag, stack)
+	}
+
 	var tags []wireTag
 	for _, s := range strings.Split(tag, ",") {
 		tags = append(tags, toWireTag(s))
@@ -849,9 +1295,27 @@ func (c *container) getBean(v reflect.Value, tag wireTag, stack *wiringStack) er
 		return fmt.Errorf("%s is not valid receiver type", t.String())
 	}
 
+	result, err := lookupBean(c.beansByType, c.beansByName, t, tag)
+	if result == nil || err != nil {
+		return err
+	}
+
+	// 确保找到的 bean 已经完成依赖注入。
+	err = c.wireBean(result, stack)
+	if err != nil {
+		return err
+	}
+
+	v.Set(c.beanValue(result, t))
+	return nil
+}
+
+// lookupBean 从 bean 注册表中查找 tag 对应的唯一 bean，优先使用设置成主版本的
+// bean，当 tag 允许为空且没有找到时返回 nil 。
+func lookupBean(beansByType map[reflect.Type][]*BeanDefinition, beansByName map[string][]*BeanDefinition, t reflect.Type, tag wireTag) (*BeanDefinition, error) {
 	var foundBeans []*BeanDefinition
 
-	for _, b := range c.beansByType[t] {
+	for _, b := range beansByType[t] {
 		if b.status == Deleted {
 			continue
 		}
@@ -863,7 +1327,7 @@ func (c *container) getBean(v reflect.Value, tag wireTag, stack *wiringStack) er
 
 	// 指定 bean 名称时通过名称获取，防止未通过 Export 方法导出接口。
 	if t.Kind() == reflect.Interface && tag.beanName != "" {
-		for _, b := range c.beansByName[tag.beanName] {
+		for _, b := range beansByName[tag.beanName] {
 			if b.status == Deleted {
 				continue
 			}
@@ -883,16 +1347,19 @@ func (c *container) getBean(v reflect.Value, tag wireTag, stack *wiringStack) er
 			}
 			if !found {
 				foundBeans = append(foundBeans, b)
-				log.Warnf("you should call Export() on %s", b)
+				// 空接口可以接收任意 bean ，不需要导出。
+				if t.NumMethod() > 0 {
+					log.Warnf("you should call Export() on %s", b)
+				}
 			}
 		}
 	}
 
 	if len(foundBeans) == 0 {
 		if tag.nullable {
-			return nil
+			return nil, nil
 		}
-		return fmt.Errorf("can't find bean, bean:%q type:%q", tag, t)
+		return nil, fmt.Errorf("can't find bean, bean:%q type:%q", tag, t)
 	}
 
 	// 优先使用设置成主版本的 bean
@@ -910,7 +1377,7 @@ func (c *container) getBean(v reflect.Value, tag wireTag, stack *wiringStack) er
 			msg += "( " + b.String() + " ), "
 		}
 		msg = msg[:len(msg)-2] + "]"
-		return errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
 	if len(primaryBeans) == 0 && len(foundBeans) > 1 {
@@ -919,24 +1386,13 @@ func (c *container) getBean(v reflect.Value, tag wireTag, stack *wiringStack) er
 			msg += "( " + b.String() + " ), "
 		}
 		msg = msg[:len(msg)-2] + "]"
-		return errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
-	var result *BeanDefinition
 	if len(primaryBeans) == 1 {
-		result = primaryBeans[0]
-	} else {
-		result = foundBeans[0]
-	}
-
-	// 确保找到的 bean 已经完成依赖注入。
-	err := c.wireBean(result, stack)
-	if err != nil {
-		return err
+		return primaryBeans[0], nil
 	}
-
-	v.Set(result.Value())
-	return nil
+	return foundBeans[0], nil
 }
 
 // filterBean 返回 tag 对应的 bean 在数组中的索引，找不到返回 -1。
@@ -992,7 +1448,14 @@ func (c *container) collectBeans(v reflect.Value, tags []wireTag, stack *wiringS
 		return fmt.Errorf("%s is not valid receiver type", t.String())
 	}
 
-	beans := c.beansByType[et]
+	// 条件不满足而被删除的 bean 不参与收集。
+	var beans []*BeanDefinition
+	for _, b := range c.beansByType[et] {
+		if b.status != Deleted {
+			beans = append(beans, b)
+		}
+	}
+
 	if len(tags) > 0 {
 
 		var (
@@ -1055,6 +1518,84 @@ func (c *container) collectBeans(v reflect.Value, tags []wireTag, stack *wiringS
 		return nil
 	}
 
+	return c.setCollection(v, beans, stack)
+}
+
+// collectionQueryPrefix 按条件收集 bean 的 tag 前缀，完整格式为 []?tag=xxx&name=xxx? ，
+// 其中 tag 可以出现多次，表示 bean 必须同时携带这些标签；name 是限定符，可以出现多次，
+// 表示 bean 的名称必须是其中之一；末尾的 ? 表示结果允许为空。
+const collectionQueryPrefix = "[]?"
+
+// collectBeansByQuery 收集满足查询条件的 bean ，slice 按照 order 排序，map 的
+// key 为 bean 的名称。
+func (c *container) collectBeansByQuery(v reflect.Value, tag string, stack *wiringStack) error {
+
+	t := v.Type()
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Map {
+		return fmt.Errorf("should be slice or map in collection mode")
+	}
+
+	et := t.Elem()
+	if !internal.IsBeanReceiver(et) {
+		return fmt.Errorf("%s is not valid receiver type", t.String())
+	}
+
+	query := strings.TrimPrefix(tag, collectionQueryPrefix)
+	nullable := strings.HasSuffix(query, "?")
+	query = strings.TrimSuffix(query, "?")
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return fmt.Errorf("parse collection query %q error: %w", tag, err)
+	}
+
+	for key := range values {
+		if key != "tag" && key != "name" {
+			return fmt.Errorf("unsupported collection query %q in %q", key, tag)
+		}
+	}
+
+	var beans []*BeanDefinition
+	for _, b := range c.beansByType[et] {
+		if b.status == Deleted {
+			continue
+		}
+		matched := true
+		for _, s := range values["tag"] {
+			if !b.HasTag(s) {
+				matched = false
+				break
+			}
+		}
+		if names, ok := values["name"]; ok && matched {
+			matched = false
+			for _, s := range names {
+				if s == b.name {
+					matched = true
+					break
+				}
+			}
+		}
+		if matched {
+			beans = append(beans, b)
+		}
+	}
+
+	if len(beans) == 0 {
+		if nullable {
+			return nil
+		}
+		return fmt.Errorf("no beans collected for %q", tag)
+	}
+
+	return c.setCollection(v, beans, stack)
+}
+
+// setCollection 对收集到的 bean 进行注入，然后按照接收者的类型组装成 slice 或 map ，
+// map 的 key 为 bean 的名称，可以是任意以 string 为底层类型的类型，名称不能重复。
+func (c *container) setCollection(v reflect.Value, beans []*BeanDefinition, stack *wiringStack) error {
+
+	t := v.Type()
 	for _, b := range beans {
 		if err := c.wireBean(b, stack); err != nil {
 			return err
@@ -1067,12 +1608,20 @@ func (c *container) collectBeans(v reflect.Value, tags []wireTag, stack *wiringS
 		sort.Sort(byOrder(beans))
 		ret = reflect.MakeSlice(t, 0, 0)
 		for _, b := range beans {
-			ret = reflect.Append(ret, b.Value())
+			ret = reflect.Append(ret, c.beanValue(b, t.Elem()))
 		}
 	case reflect.Map:
-		ret = reflect.MakeMap(t)
+		kt := t.Key()
+		if kt.Kind() != reflect.String {
+			return fmt.Errorf("map key of %s should be string", t.String())
+		}
+		ret = reflect.MakeMapWithSize(t, len(beans))
 		for _, b := range beans {
-			ret.SetMapIndex(reflect.ValueOf(b.name), b.Value())
+			k := reflect.ValueOf(b.name).Convert(kt)
+			if d := ret.MapIndex(k); d.IsValid() {
+				return fmt.Errorf("found duplicate bean name %q for %s", b.name, t.String())
+			}
+			ret.SetMapIndex(k, c.beanValue(b, t.Elem()))
 		}
 	}
 	v.Set(ret)
@@ -1083,11 +1632,16 @@ func (c *container) collectBeans(v reflect.Value, tags []wireTag, stack *wiringS
 // 号，然后等待所有 goroutine 结束，最后按照被依赖先销毁的原则执行所有的销毁函数。
 func (c *container) Close() {
 
+	c.audit.checkClose(c.ctx)
 	c.cancel()
-	c.wg.Wait()
+	c.waitGoroutines()
 
 	log.Info("goroutines exited")
 
+	if c.dynamic != nil {
+		c.dynamic.close()
+	}
+
 	for _, f := range c.destroyers {
 		f()
 	}
@@ -1098,11 +1652,14 @@ func (c *container) Close() {
 // Go 创建安全可等待的 goroutine，fn 要求的 ctx 对象由 IoC 容器提供，当 IoC 容
 // 器关闭时 ctx会 发出 Done 信号， fn 在接收到此信号后应当立即退出。
 func (c *container) Go(fn func(ctx context.Context)) {
+	id := c.goroutines.add(fn)
 	c.wg.Add(1)
 	go func() {
 		defer c.wg.Done()
+		defer c.goroutines.remove(id)
 		defer func() {
 			if r := recover(); r != nil {
+				report.Panic(c.ctx, report.SourceGo, r)
 				log.Panic(r)
 			}
 		}()
@@ -0,0 +1,126 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/report"
+)
+
+// SpringAuditEnabled 设置为 true 时开启线程安全审计模式，容器在运行时检测不安全的
+// 用法并连同调用栈一起记录日志和上报，帮助迁移到容器的大型代码库发现问题。由于需要
+// 获取 goroutine 的调用栈，只建议在调试时开启。
+const SpringAuditEnabled = "spring.audit.enabled"
+
+// 审计模式检测的不安全用法。
+const (
+	AuditRegisterAfterRefresh = "register after refresh"                // 刷新开始之后注册 bean
+	AuditGetDuringRefresh     = "get during refresh"                    // 其他 goroutine 在刷新过程中获取 bean
+	AuditCloseWhileInFlight   = "close while invocations are in flight" // Get 、Wire 或 Invoke 执行期间关闭容器
+)
+
+// auditor 线程安全审计，所有字段都通过原子操作访问。
+type auditor struct {
+	enabled    int32
+	refreshing int32
+	refresher  int64 // 正在刷新容器的 goroutine
+	delegate   int64 // 代替刷新 goroutine 执行初始化函数的 goroutine
+	inflight   int64 // 正在执行的 Get 、Wire 和 Invoke 调用数
+}
+
+func (a *auditor) isEnabled() bool {
+	return atomic.LoadInt32(&a.enabled) == 1
+}
+
+// startRefresh 记录开始刷新的 goroutine 。
+func (a *auditor) startRefresh(enabled bool) {
+	if !enabled {
+		return
+	}
+	atomic.StoreInt64(&a.refresher, goroutineID())
+	atomic.StoreInt32(&a.refreshing, 1)
+	atomic.StoreInt32(&a.enabled, 1)
+}
+
+// endRefresh 刷新结束，无论成功与否。
+func (a *auditor) endRefresh() {
+	atomic.StoreInt32(&a.refreshing, 0)
+}
+
+// delegateInit 标记当前 goroutine 代替刷新 goroutine 执行初始化函数。
+func (a *auditor) delegateInit() {
+	if a.isEnabled() {
+		atomic.StoreInt64(&a.delegate, goroutineID())
+	}
+}
+
+// endDelegate 清除代替刷新 goroutine 执行初始化函数的 goroutine 。
+func (a *auditor) endDelegate() {
+	atomic.StoreInt64(&a.delegate, 0)
+}
+
+// enter 记录一次 Get 、Wire 或 Invoke 调用，返回的函数在调用结束时执行。
+func (a *auditor) enter(ctx context.Context) func() {
+	if !a.isEnabled() {
+		return func() {}
+	}
+	if atomic.LoadInt32(&a.refreshing) == 1 {
+		id := goroutineID()
+		if id != atomic.LoadInt64(&a.refresher) && id != atomic.LoadInt64(&a.delegate) {
+			a.violate(ctx, AuditGetDuringRefresh)
+		}
+	}
+	atomic.AddInt64(&a.inflight, 1)
+	return func() { atomic.AddInt64(&a.inflight, -1) }
+}
+
+// checkClose 检查关闭容器时是否还有正在执行的调用。
+func (a *auditor) checkClose(ctx context.Context) {
+	if !a.isEnabled() {
+		return
+	}
+	if n := atomic.LoadInt64(&a.inflight); n > 0 {
+		a.violate(ctx, fmt.Sprintf("%s (%d)", AuditCloseWhileInFlight, n))
+	}
+}
+
+// violate 记录并上报不安全的用法。
+func (a *auditor) violate(ctx context.Context, kind string) {
+	err := fmt.Errorf("audit: %s", kind)
+	log.Warnf("%s\n%s", err, debug.Stack())
+	report.Error(ctx, report.SourceAudit, err)
+}
+
+// goroutineID 从调用栈的第一行 "goroutine 18 [running]:" 中解析当前 goroutine 的 ID 。
+func goroutineID() int64 {
+	var buf [64]byte
+	s := string(buf[:runtime.Stack(buf[:], false)])
+	s = strings.TrimPrefix(s, "goroutine ")
+	if i := strings.IndexByte(s, ' '); i > 0 {
+		s = s[:i]
+	}
+	id, _ := strconv.ParseInt(s, 10, 64)
+	return id
+}
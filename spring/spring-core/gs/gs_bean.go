@@ -22,6 +22,7 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/go-spring/spring-base/util"
 	"github.com/go-spring/spring-core/conf"
@@ -77,6 +78,18 @@ type BeanDestroy interface {
 	OnDestroy()
 }
 
+// InitializingBean 属性绑定和依赖注入完成后自动执行 AfterPropertiesSet 方法，
+// 执行时机早于通过 Init 方法设置的初始化函数。
+type InitializingBean interface {
+	AfterPropertiesSet() error
+}
+
+// DisposableBean 容器关闭时自动执行 Destroy 方法，执行时机早于通过 Destroy
+// 方法设置的销毁函数。
+type DisposableBean interface {
+	Destroy() error
+}
+
 // BeanDefinition bean 元数据。
 type BeanDefinition struct {
 
@@ -90,16 +103,27 @@ type BeanDefinition struct {
 	file string // 注册点所在文件
 	line int    // 注册点所在行数
 
-	name    string         // 名称
-	status  beanStatus     // 状态
-	primary bool           // 是否为主版本
-	method  bool           // 是否为成员方法
-	cond    cond.Condition // 判断条件
-	order   float32        // 收集时的顺序
-	init    interface{}    // 初始化函数
-	destroy interface{}    // 销毁函数
-	depends []BeanSelector // 间接依赖项
-	exports []reflect.Type // 导出的接口
+	name    string          // 名称
+	status  beanStatus      // 状态
+	primary bool            // 是否为主版本
+	method  bool            // 是否为成员方法
+	cond    cond.Condition  // 判断条件
+	order   float32         // 收集时的顺序
+	init    interface{}     // 初始化函数
+	timeout time.Duration   // 初始化超时时间
+	policy  InitPanicPolicy // 初始化 panic 处理策略
+	destroy interface{}     // 销毁函数
+	depends []BeanSelector  // 间接依赖项
+	exports []reflect.Type  // 导出的接口
+	tags    []string        // 标签列表
+	desc    string          // 描述
+
+	fallbacks []reflect.Type // 作为默认实现的接口
+
+	leader  *BeanDefinition   // 返回多个结果的构造函数所在的 bean
+	index   int               // 在构造函数结果中的序号
+	results []*BeanDefinition // 构造函数其他结果对应的 bean
+	outs    []reflect.Value   // 构造函数的执行结果
 }
 
 // Type 返回 bean 的类型。
@@ -112,6 +136,16 @@ func (d *BeanDefinition) Value() reflect.Value {
 	return d.v
 }
 
+// valueFor 返回用于赋值给类型 t 的 bean 值。构造函数以接口类型返回时，bean 可能
+// 通过导出的其他接口注入，这时需要使用接口中保存的原始值进行赋值。
+func (d *BeanDefinition) valueFor(t reflect.Type) reflect.Value {
+	v := d.v
+	if v.Kind() == reflect.Interface && !v.Type().AssignableTo(t) {
+		v = v.Elem()
+	}
+	return v
+}
+
 // Interface 返回 bean 的真实值。
 func (d *BeanDefinition) Interface() interface{} {
 	return d.v.Interface()
@@ -149,7 +183,7 @@ func (d *BeanDefinition) FileLine() string {
 
 // getClass 返回 bean 的类型描述。
 func (d *BeanDefinition) getClass() string {
-	if d.f == nil {
+	if d.f == nil && d.leader == nil {
 		return "object bean"
 	}
 	return "constructor bean"
@@ -205,6 +239,69 @@ func (d *BeanDefinition) Primary() *BeanDefinition {
 	return d
 }
 
+// FallbackFor 导出接口并将 bean 设置为这些接口的默认实现，只有当接口没有其他实现
+// 时才会被注入。判断在所有 bean 的条件计算完成之后进行，当所有接口都有其他实现时
+// bean 会被删除，因此不需要为默认实现再配置 OnMissingBean 条件。
+func (d *BeanDefinition) FallbackFor(exports ...interface{}) *BeanDefinition {
+	d.Export(exports...)
+	for _, o := range exports {
+		typ, ok := o.(reflect.Type)
+		if !ok {
+			typ = util.Indirect(reflect.TypeOf(o))
+		}
+		if !d.isFallbackFor(typ) {
+			d.fallbacks = append(d.fallbacks, typ)
+		}
+	}
+	return d
+}
+
+// isFallbackFor 返回 bean 是否为接口 t 的默认实现。
+func (d *BeanDefinition) isFallbackFor(t reflect.Type) bool {
+	for _, typ := range d.fallbacks {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Tag 为 bean 添加标签，收集 bean 时可以通过 []?tag=xxx 的形式按标签进行筛选。
+func (d *BeanDefinition) Tag(tags ...string) *BeanDefinition {
+	for _, tag := range tags {
+		if !d.HasTag(tag) {
+			d.tags = append(d.tags, tag)
+		}
+	}
+	return d
+}
+
+// Tags 返回 bean 的标签列表。
+func (d *BeanDefinition) Tags() []string {
+	return d.tags
+}
+
+// HasTag 返回 bean 是否携带了指定的标签。
+func (d *BeanDefinition) HasTag(tag string) bool {
+	for _, s := range d.tags {
+		if s == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Describe 设置 bean 的描述，会出现在 Documentation 返回的文档中。
+func (d *BeanDefinition) Describe(desc string) *BeanDefinition {
+	d.desc = desc
+	return d
+}
+
+// Description 返回 bean 的描述。
+func (d *BeanDefinition) Description() string {
+	return d.desc
+}
+
 // validLifeCycleFunc 判断是否是合法的用于 bean 生命周期控制的函数，生命周期函数
 // 的要求：只能有一个入参并且必须是 bean 的类型，没有返回值或者只返回 error 类型值。
 func validLifeCycleFunc(fnType reflect.Type, beanType reflect.Type) bool {
@@ -226,6 +323,22 @@ func (d *BeanDefinition) Init(fn interface{}) *BeanDefinition {
 	panic(errors.New("init should be func(bean) or func(bean)error"))
 }
 
+// InitTimeout 设置 bean 初始化的超时时间，包括 Init 方法设置的初始化函数以及
+// 实现的初始化接口，超时后容器刷新失败。同时还受 spring.init.timeout 设置的整个
+// 容器刷新的截止时间限制。
+func (d *BeanDefinition) InitTimeout(timeout time.Duration) *BeanDefinition {
+	d.timeout = timeout
+	return d
+}
+
+// InitPanic 设置 bean 初始化 panic 时的处理策略，未设置时使用 spring.init.panic
+// 属性值。非关键的 bean 可以设置为 InitPanicIsolate ，这样初始化 panic 时应用仍然
+// 可以降级启动。
+func (d *BeanDefinition) InitPanic(policy InitPanicPolicy) *BeanDefinition {
+	d.policy = policy
+	return d
+}
+
 // Destroy 设置 bean 的销毁函数。
 func (d *BeanDefinition) Destroy(fn interface{}) *BeanDefinition {
 	if validLifeCycleFunc(reflect.TypeOf(fn), d.Type()) {
@@ -294,7 +407,7 @@ func NewBean(objOrCtor interface{}, ctorArgs ...arg.Arg) *BeanDefinition {
 	// 以 reflect.ValueOf(fn) 形式注册的函数被视为函数对象 bean 。
 	if t := v.Type(); !fromValue && t.Kind() == reflect.Func {
 
-		if !util.IsConstructor(t) {
+		if !isConstructor(t) {
 			t1 := "func(...)bean"
 			t2 := "func(...)(bean, error)"
 			panic(fmt.Errorf("constructor should be %s or %s", t1, t2))
@@ -304,13 +417,7 @@ func NewBean(objOrCtor interface{}, ctorArgs ...arg.Arg) *BeanDefinition {
 		f, err = arg.Bind(objOrCtor, ctorArgs, skip)
 		util.Panic(err).When(err != nil)
 
-		out0 := t.Out(0)
-		v = reflect.New(out0)
-
-		// 引用类型去掉指针，值类型则刚刚好。
-		if internal.IsBeanType(out0) {
-			v = v.Elem()
-		}
+		v = newResultValue(t.Out(0))
 
 		// 成员方法一般是 xxx/gs_test.(*Server).Consumer 形式命名
 		fnPtr := reflect.ValueOf(objOrCtor).Pointer()
@@ -332,7 +439,7 @@ func NewBean(objOrCtor interface{}, ctorArgs ...arg.Arg) *BeanDefinition {
 	s := strings.Split(t.String(), ".")
 	name := strings.TrimPrefix(s[len(s)-1], "*")
 
-	return &BeanDefinition{
+	d := &BeanDefinition{
 		t:        t,
 		v:        v,
 		f:        f,
@@ -343,4 +450,83 @@ func NewBean(objOrCtor interface{}, ctorArgs ...arg.Arg) *BeanDefinition {
 		file:     file,
 		line:     line,
 	}
+
+	// 构造函数返回多个结果时，其他结果分别注册为 bean 。
+	if f != nil {
+		ft := reflect.TypeOf(objOrCtor)
+		for i := 1; i < ft.NumOut(); i++ {
+			if util.IsErrorType(ft.Out(i)) {
+				break
+			}
+			r := newResultBean(ft.Out(i), file, line)
+			r.leader = d
+			r.index = i
+			d.results = append(d.results, r)
+		}
+	}
+	return d
+}
+
+// isConstructor 返回 t 是否为构造函数，构造函数可以返回一个或者多个 bean ，最后
+// 一个返回值可以是 error 。
+func isConstructor(t reflect.Type) bool {
+	if t.Kind() != reflect.Func || t.NumOut() == 0 {
+		return false
+	}
+	n := t.NumOut()
+	if util.IsErrorType(t.Out(n - 1)) {
+		n--
+	}
+	if n == 0 {
+		return false
+	}
+	for i := 1; i < n; i++ {
+		if !internal.IsBeanType(t.Out(i)) && !conf.IsValueType(t.Out(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// newResultValue 返回保存构造函数结果的值，引用类型去掉指针，值类型则刚刚好。
+func newResultValue(t reflect.Type) reflect.Value {
+	v := reflect.New(t)
+	if internal.IsBeanType(t) {
+		v = v.Elem()
+	}
+	return v
+}
+
+// newResultBean 创建构造函数其他结果对应的 bean 。
+func newResultBean(out reflect.Type, file string, line int) *BeanDefinition {
+
+	v := newResultValue(out)
+	t := v.Type()
+	if t.Kind() == reflect.Ptr && !conf.IsValueType(t.Elem()) {
+		panic(errors.New("bean should be *val but not *ref"))
+	}
+
+	s := strings.Split(t.String(), ".")
+	return &BeanDefinition{
+		t:        t,
+		v:        v,
+		name:     strings.TrimPrefix(s[len(s)-1], "*"),
+		typeName: internal.TypeName(t),
+		status:   Default,
+		file:     file,
+		line:     line,
+	}
+}
+
+// Result 返回构造函数第 i 个结果对应的 bean ，i 从 0 开始，0 即 bean 本身。返回
+// 多个结果的构造函数只会执行一次，其他结果对应的 bean 与第一个 bean 同时生效或者失效，
+// 也可以分别设置名称、导出接口等。
+func (d *BeanDefinition) Result(i int) *BeanDefinition {
+	if i == 0 {
+		return d
+	}
+	if i < 0 || i > len(d.results) {
+		panic(fmt.Errorf("result index %d out of range", i))
+	}
+	return d.results[i-1]
 }
@@ -468,9 +468,13 @@ func TestConstructorBean(t *testing.T) {
 	assert.Equal(t, bd.Type().String(), "gs_test.Teacher")
 
 	assert.Panic(t, func() {
-		_ = newBean(func() (*int, *int) { return nil, nil })
+		_ = newBean(func() error { return nil })
 	}, "constructor should be func\\(...\\)bean or func\\(...\\)\\(bean, error\\)")
 
+	bd = newBean(func() (*int, *string, error) { return nil, nil, nil })
+	assert.Equal(t, bd.Type().String(), "*int")
+	assert.Equal(t, bd.Result(1).Type().String(), "*string")
+
 	bd = newBean(func() (*int, error) { return nil, nil })
 	assert.Equal(t, bd.Type().String(), "*int")
 }
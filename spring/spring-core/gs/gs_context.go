@@ -19,6 +19,7 @@ package gs
 import (
 	"errors"
 	"reflect"
+	"strings"
 
 	"github.com/go-spring/spring-base/log"
 	"github.com/go-spring/spring-base/util"
@@ -39,6 +40,11 @@ func (c *container) Prop(key string, opts ...conf.GetOption) string {
 	return c.p.Get(key, opts...)
 }
 
+// Resolve 解析字符串中包含的所有属性引用即 ${key:=def} 的内容。
+func (c *container) Resolve(s string) (string, error) {
+	return c.p.Resolve(s)
+}
+
 func (c *container) Bind(i interface{}, opts ...conf.BindOption) error {
 	return c.p.Bind(i, opts...)
 }
@@ -66,8 +72,11 @@ func (c *container) Find(selector BeanSelector) ([]cond.BeanDefinition, error) {
 // 工作模式称为自动模式，否则根据传入的选择器列表进行排序，这种工作模式成为指派模式。
 // 该方法和 Find 方法的区别是该方法保证返回的所有 bean 对象都已经完成属性绑定和依
 // 赖注入，而 Find 方法只能保证返回的 bean 对象是有效的，即未被标记为删除的。
+// 另外，集合类型的接收者还可以使用 []?tag=xxx&name=xxx 形式的选择器按照标签和名称收集 bean 。
 func (c *container) Get(i interface{}, selectors ...BeanSelector) error {
 
+	defer c.audit.enter(c.ctx)()
+
 	if i == nil {
 		return errors.New("i can't be nil")
 	}
@@ -85,6 +94,12 @@ func (c *container) Get(i interface{}, selectors ...BeanSelector) error {
 		}
 	}()
 
+	if len(selectors) == 1 {
+		if s, ok := selectors[0].(string); ok && strings.HasPrefix(s, collectionQueryPrefix) {
+			return c.collectBeansByQuery(v.Elem(), s, stack)
+		}
+	}
+
 	var tags []wireTag
 	for _, s := range selectors {
 		tags = append(tags, toWireTag(s))
@@ -97,6 +112,8 @@ func (c *container) Get(i interface{}, selectors ...BeanSelector) error {
 // 种方式，该函数执行完后都会返回 bean 对象的真实值。
 func (c *container) Wire(objOrCtor interface{}, ctorArgs ...arg.Arg) (interface{}, error) {
 
+	defer c.audit.enter(c.ctx)()
+
 	stack := newWiringStack()
 
 	defer func() {
@@ -115,6 +132,8 @@ func (c *container) Wire(objOrCtor interface{}, ctorArgs ...arg.Arg) (interface{
 
 func (c *container) Invoke(fn interface{}, args ...arg.Arg) ([]interface{}, error) {
 
+	defer c.audit.enter(c.ctx)()
+
 	if !util.IsFuncType(reflect.TypeOf(fn)) {
 		return nil, errors.New("fn should be func type")
 	}
@@ -0,0 +1,156 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/gs/cond"
+)
+
+// BeanDoc 单个 bean 的注册信息。
+type BeanDoc struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Source      string   `json:"source"`
+	Description string   `json:"description,omitempty"`
+	Condition   string   `json:"condition,omitempty"`  // 条件的文字描述
+	Properties  []string `json:"properties,omitempty"` // bean 使用的属性
+	Exports     []string `json:"exports,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Primary     bool     `json:"primary,omitempty"`
+}
+
+// BeanDocumentation 所有 bean 的注册信息，供工具生成服务目录等文档。
+type BeanDocumentation struct {
+	Beans []BeanDoc `json:"beans"`
+}
+
+// Documentation 返回所有已注册 bean 的注册信息，包括名称、类型、条件、使用的属性
+// 以及通过 Describe 设置的描述。只能在刷新之前或者以 AutoClear(false) 刷新之后调用，
+// 刷新之后不再包含已被删除的 bean 。
+func (c *container) Documentation() (*BeanDocumentation, error) {
+
+	if c.tempContainer == nil {
+		return nil, errors.New("documentation is only available before refresh or after refresh with AutoClear(false)")
+	}
+
+	doc := &BeanDocumentation{Beans: []BeanDoc{}}
+	for _, b := range c.beans {
+		if b.status == Deleted {
+			continue
+		}
+		bd := BeanDoc{
+			ID:          b.ID(),
+			Name:        b.BeanName(),
+			Type:        b.Type().String(),
+			Source:      b.FileLine(),
+			Description: b.desc,
+			Condition:   cond.Describe(b.cond),
+			Properties:  beanProperties(b),
+			Tags:        b.tags,
+			Primary:     b.primary,
+		}
+		for _, t := range b.exports {
+			bd.Exports = append(bd.Exports, t.String())
+		}
+		doc.Beans = append(doc.Beans, bd)
+	}
+
+	sort.Slice(doc.Beans, func(i, j int) bool {
+		return doc.Beans[i].ID < doc.Beans[j].ID
+	})
+	return doc, nil
+}
+
+// beanProperties 返回 bean 的条件、构造函数参数以及 value 标签引用的属性。
+func beanProperties(b *BeanDefinition) []string {
+
+	keys := make(map[string]struct{})
+	for _, key := range cond.Properties(b.cond) {
+		keys[key] = struct{}{}
+	}
+
+	if b.f != nil {
+		for i := 0; ; i++ {
+			a, ok := b.f.Arg(i)
+			if !ok {
+				break
+			}
+			if s, ok := a.(string); ok && strings.HasPrefix(s, "${") {
+				if tag, err := conf.ParseTag(s); err == nil && tag.Key != "" {
+					keys[tag.Key] = struct{}{}
+				}
+			}
+		}
+	}
+
+	t := b.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		structProperties(t, "", keys)
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+	var ret []string
+	for key := range keys {
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// structProperties 收集结构体字段 value 标签引用的属性，匿名的结构体字段以标签
+// 的属性名作为前缀。
+func structProperties(t reflect.Type, prefix string, keys map[string]struct{}) {
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i)
+		tag, ok := ft.Tag.Lookup("value")
+		if !ok {
+			if ft.Anonymous && ft.Type.Kind() == reflect.Struct {
+				structProperties(ft.Type, prefix, keys)
+			}
+			continue
+		}
+		parsed, err := conf.ParseTag(tag)
+		if err != nil {
+			continue
+		}
+		key := parsed.Key
+		if prefix != "" && key != "" {
+			key = prefix + "." + key
+		} else if key == "" {
+			key = prefix
+		}
+		if ft.Anonymous && ft.Type.Kind() == reflect.Struct {
+			structProperties(ft.Type, key, keys)
+			continue
+		}
+		if key != "" {
+			keys[key] = struct{}{}
+		}
+	}
+}
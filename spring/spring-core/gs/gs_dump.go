@@ -0,0 +1,196 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/report"
+)
+
+// SpringSnapshotDir 设置该属性后，应用在发生 panic 等致命错误时将上下文快照写入
+// 该目录，方便附加到故障报告中。
+const SpringSnapshotDir = "spring.snapshot.dir"
+
+// healthTimeout 生成快照时健康检查的超时时间。
+const healthTimeout = 5 * time.Second
+
+// HealthIndicator 报告组件的健康状况，上下文快照包含所有实现了该接口的 bean 的检
+// 查结果，实现时应该响应 ctx 的超时。
+type HealthIndicator interface {
+	Health(ctx context.Context) error
+}
+
+// HealthReport 单个组件的健康状况。
+type HealthReport struct {
+	Bean   string `json:"bean"`
+	Status string `json:"status"` // UP 或者 DOWN
+	Error  string `json:"error,omitempty"`
+}
+
+// ContextSnapshot 应用上下文的诊断快照，序列化成 JSON 之后可以附加到故障报告中，
+// 其中的属性值已经隐藏了敏感信息。
+type ContextSnapshot struct {
+	Time       time.Time         `json:"time"`
+	Versions   map[string]string `json:"versions"`
+	Profiles   []string          `json:"profiles"`
+	Properties map[string]string `json:"properties"`
+	Sources    map[string]string `json:"sources"` // 属性的来源
+	Beans      []BeanReport      `json:"beans"`
+	Health     []HealthReport    `json:"health"`
+}
+
+// sanitizeProperties 保存经过隐藏处理的属性供上下文快照使用，因为容器刷新完成之后
+// 属性列表会被清理。优先使用注册的 conf.Sanitizer bean 。
+func (c *container) sanitizeProperties() {
+	var s conf.Sanitizer
+	t := reflect.TypeOf((*conf.Sanitizer)(nil)).Elem()
+	for _, b := range c.beansByType[t] {
+		if v, ok := b.valueFor(t).Interface().(conf.Sanitizer); ok && b.status == Wired {
+			s = v
+			break
+		}
+	}
+	if s == nil {
+		s = conf.NewSanitizer()
+	}
+	c.props = c.p.Sanitized(s)
+	c.propSources = make(map[string]string, len(c.props))
+	for k := range c.props {
+		if source := c.sourceOf(k); source != "" {
+			c.propSources[k] = source
+		}
+	}
+}
+
+// Snapshot 返回应用上下文的诊断快照，包含 bean 列表、隐藏了敏感信息的属性、激活的
+// profile 、版本信息以及健康检查的结果，需要在容器刷新完成之后调用。
+func (c *container) Snapshot() (*ContextSnapshot, error) {
+
+	if c.state != Refreshed || c.frozen == nil {
+		return nil, errors.New("snapshot is only available after refresh")
+	}
+
+	s := &ContextSnapshot{
+		Time:       time.Now(),
+		Versions:   versions(),
+		Profiles:   []string{},
+		Properties: c.props,
+		Sources:    c.propSources,
+		Beans:      []BeanReport{},
+		Health:     []HealthReport{},
+	}
+
+	for _, profile := range strings.Split(c.props["spring.profiles.active"], ",") {
+		if profile = strings.TrimSpace(profile); profile != "" {
+			s.Profiles = append(s.Profiles, profile)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, healthTimeout)
+	defer cancel()
+
+	for _, b := range c.frozen.beans {
+		s.Beans = append(s.Beans, BeanReport{
+			ID:     b.ID(),
+			Type:   b.Type().String(),
+			Source: b.FileLine(),
+			Status: getStatusString(b.status),
+		})
+		h, ok := b.Interface().(HealthIndicator)
+		if !ok {
+			continue
+		}
+		r := HealthReport{Bean: b.ID(), Status: "UP"}
+		if err := h.Health(ctx); err != nil {
+			r.Status = "DOWN"
+			r.Error = err.Error()
+		}
+		s.Health = append(s.Health, r)
+	}
+
+	// 初始化 panic 之后被隔离的 bean 。
+	s.Health = append(s.Health, c.initFailureReports()...)
+
+	sort.Slice(s.Beans, func(i, j int) bool {
+		return s.Beans[i].ID < s.Beans[j].ID
+	})
+	return s, nil
+}
+
+// versions 返回框架、Go 运行时以及主模块的版本信息。
+func versions() map[string]string {
+	m := map[string]string{
+		"go-spring": Version,
+		"go":        runtime.Version(),
+		"os/arch":   runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Path != "" {
+		m[info.Main.Path] = info.Main.Version
+	}
+	return m
+}
+
+// writeSnapshot 将上下文快照以 JSON 格式写入 dir 目录，返回文件的路径。
+func (c *container) writeSnapshot(dir string, name string) (string, error) {
+	s, err := c.Snapshot()
+	if err != nil {
+		return "", err
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, name)
+	return file, ioutil.WriteFile(file, b, 0644)
+}
+
+// snapshotReporter 在发生致命错误时写入上下文快照。
+type snapshotReporter struct {
+	c   *container
+	dir string
+}
+
+func (r *snapshotReporter) Report(ctx context.Context, e *report.Event) {
+	if e.Level != report.LevelFatal {
+		return
+	}
+	name := fmt.Sprintf("snapshot-%s-%s.json", e.Time.Format("20060102150405"), e.ID)
+	file, err := r.c.writeSnapshot(r.dir, name)
+	if err != nil {
+		log.Errorf("write snapshot error: %s", err.Error())
+		return
+	}
+	log.Infof("snapshot has been written to %s", file)
+}
@@ -0,0 +1,262 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/gs/cond"
+	"github.com/go-spring/spring-core/gs/internal"
+)
+
+// AutoClear 设置容器刷新完成之后是否清理注册阶段的临时数据，默认清理，需要在刷新
+// 之后动态注册 bean 时应该关闭该选项。
+func AutoClear(enable bool) internal.RefreshOption {
+	return internal.AutoClear(enable)
+}
+
+// DynamicRegistry 容器刷新之后动态注册和注销 bean 的注册表，适用于插件、租户相关
+// 组件等运行时才能确定的 bean 。动态 bean 在注册时立即完成属性绑定和依赖注入，可以
+// 依赖容器中的 bean，但是它们与容器的 bean 注册表相互隔离，不会出现在 Frozen 快照
+// 和自动注入的结果中，只能通过 DynamicRegistry 获取。注销或者容器关闭时执行动态
+// bean 的销毁函数。注意，动态注册要求容器刷新时关闭了 AutoClear 选项。
+type DynamicRegistry struct {
+	c           *container
+	parent      *DynamicRegistry
+	name        string
+	children    []*DynamicRegistry
+	mutex       sync.RWMutex
+	beans       []*BeanDefinition
+	beansByName map[string][]*BeanDefinition
+	beansByType map[reflect.Type][]*BeanDefinition
+	destroyers  map[*BeanDefinition][]func()
+}
+
+func newDynamicRegistry(c *container) *DynamicRegistry {
+	return &DynamicRegistry{
+		c:           c,
+		beansByName: make(map[string][]*BeanDefinition),
+		beansByType: make(map[reflect.Type][]*BeanDefinition),
+		destroyers:  make(map[*BeanDefinition][]func()),
+	}
+}
+
+// Dynamic 返回动态 bean 注册表，容器刷新完成之前返回 nil 。
+func (c *container) Dynamic() *DynamicRegistry {
+	if c.state != Refreshed {
+		return nil
+	}
+	return c.dynamic
+}
+
+// Register 注册一个动态 bean 并立即对其进行属性绑定和依赖注入，bean 的条件不成立
+// 或者与已注册的动态 bean 的 ID 重复时返回 error 。
+func (r *DynamicRegistry) Register(b *BeanDefinition) error {
+
+	// 持有容器的锁直到注册完成，这样上下级注册表之间的 ID 检查和注册是原子的。
+	r.c.dynamicMutex.Lock()
+	defer r.c.dynamicMutex.Unlock()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.c.tempContainer == nil {
+		return errors.New("container has been cleared, refresh it with AutoClear(false)")
+	}
+
+	for _, d := range r.beans {
+		if d.ID() == b.ID() {
+			return fmt.Errorf("found duplicate beans [%s] [%s]", b, d)
+		}
+	}
+	if err := r.checkShadow(b); err != nil {
+		return err
+	}
+
+	stack, err := r.wire(b)
+	if err != nil {
+		return err
+	}
+
+	r.beans = append(r.beans, b)
+	r.beansByName[b.name] = append(r.beansByName[b.name], b)
+	r.beansByType[b.Type()] = append(r.beansByType[b.Type()], b)
+	for _, t := range b.exports {
+		r.beansByType[t] = append(r.beansByType[t], b)
+	}
+	r.destroyers[b] = stack.sortDestroyers()
+	log.Infof("dynamic bean %s registered", b)
+	return nil
+}
+
+// wire 对 bean 进行属性绑定和依赖注入。解析和注入会修改容器的状态，例如被排除的
+// bean 和装配事件，因此调用者需要持有所有注册表共用的容器锁。
+func (r *DynamicRegistry) wire(b *BeanDefinition) (*wiringStack, error) {
+	if err := r.c.resolveBean(b); err != nil {
+		return nil, err
+	}
+	if b.status == Deleted {
+		return nil, fmt.Errorf("bean %s doesn't match its condition", b)
+	}
+
+	stack := newWiringStack()
+	if err := r.c.wireBean(b, stack); err != nil {
+		return nil, fmt.Errorf("%s ↩\n%s", err, stack.path())
+	}
+	return stack, nil
+}
+
+// checkShadow 检查子注册表中的 bean 是否和容器或者上级注册表中的 bean 的 ID 相同，
+// 避免插件等子注册表遮盖应用自己的 bean 。
+func (r *DynamicRegistry) checkShadow(b *BeanDefinition) error {
+	if r.parent == nil {
+		return nil
+	}
+	for _, d := range r.c.beansByName[b.name] {
+		if d.status != Deleted && d.ID() == b.ID() {
+			return fmt.Errorf("%s: bean %s shadows application bean %s", r.name, b, d)
+		}
+	}
+	for p := r.parent; p != nil; p = p.parent {
+		p.mutex.RLock()
+		for _, d := range p.beansByName[b.name] {
+			if d.ID() == b.ID() {
+				p.mutex.RUnlock()
+				return fmt.Errorf("%s: bean %s shadows dynamic bean %s", r.name, b, d)
+			}
+		}
+		p.mutex.RUnlock()
+	}
+	return nil
+}
+
+// Child 创建名为 name 的子注册表，例如每个插件使用一个子注册表。子注册表中的 bean
+// 可以依赖容器中的 bean ，但是不能和容器以及上级注册表中的 bean 的 ID 相同；它们只
+// 能通过子注册表获取，因此不同子注册表之间相互隔离。子注册表可以通过 Close 单独卸载，
+// 上级注册表关闭时也会被关闭。
+func (r *DynamicRegistry) Child(name string) *DynamicRegistry {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	child := newDynamicRegistry(r.c)
+	child.parent, child.name = r, name
+	r.children = append(r.children, child)
+	return child
+}
+
+// Close 关闭子注册表，按照注册的逆序注销其中所有的 bean ，然后将其从上级注册表中
+// 移除。
+func (r *DynamicRegistry) Close() {
+	r.close()
+	if p := r.parent; p != nil {
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		for i, c := range p.children {
+			if c == r {
+				p.children = append(p.children[:i:i], p.children[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Unregister 注销符合选择器的所有动态 bean 并执行它们的销毁函数，返回被注销的
+// bean 的数量。
+func (r *DynamicRegistry) Unregister(selector BeanSelector) int {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	fn := selectorMatcher(selector)
+	var removed []*BeanDefinition
+	for _, b := range r.beans {
+		if fn(b) {
+			removed = append(removed, b)
+		}
+	}
+
+	for _, b := range removed {
+		r.remove(b)
+		log.Infof("dynamic bean %s unregistered", b)
+	}
+	return len(removed)
+}
+
+// remove 从注册表中删除 bean 并执行它的销毁函数。
+func (r *DynamicRegistry) remove(b *BeanDefinition) {
+	r.beans = removeBean(r.beans, b)
+	r.beansByName[b.name] = removeBean(r.beansByName[b.name], b)
+	r.beansByType[b.Type()] = removeBean(r.beansByType[b.Type()], b)
+	for _, t := range b.exports {
+		r.beansByType[t] = removeBean(r.beansByType[t], b)
+	}
+	for _, f := range r.destroyers[b] {
+		f()
+	}
+	delete(r.destroyers, b)
+}
+
+func removeBean(beans []*BeanDefinition, b *BeanDefinition) []*BeanDefinition {
+	for i, d := range beans {
+		if d == b {
+			return append(beans[:i:i], beans[i+1:]...)
+		}
+	}
+	return beans
+}
+
+// Find 查找符合条件的动态 bean 。
+func (r *DynamicRegistry) Find(selector BeanSelector) []cond.BeanDefinition {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	fn := selectorMatcher(selector)
+	var ret []cond.BeanDefinition
+	for _, b := range r.beans {
+		if fn(b) {
+			ret = append(ret, b)
+		}
+	}
+	return ret
+}
+
+// Get 根据类型和选择器获取唯一符合条件的动态 bean ，i 必须是一个指向 bean 接收者
+// 的指针。
+func (r *DynamicRegistry) Get(i interface{}, selector ...BeanSelector) error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	s := Snapshot{beansByName: r.beansByName, beansByType: r.beansByType}
+	return s.Get(i, selector...)
+}
+
+// close 先关闭所有的子注册表，然后按照注册的逆序注销所有的动态 bean 。
+func (r *DynamicRegistry) close() {
+	r.mutex.Lock()
+	children := r.children
+	r.children = nil
+	r.mutex.Unlock()
+	for i := len(children) - 1; i >= 0; i-- {
+		children[i].close()
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for i := len(r.beans) - 1; i >= 0; i-- {
+		r.remove(r.beans[i])
+	}
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+)
+
+// SpringShutdownGoroutineTimeout 关闭容器时等待通过 Go 方法创建的 goroutine 退出的
+// 最长时间，默认 30s ，超时之后报告仍未退出的 goroutine 并继续执行销毁函数，不大于 0
+// 时一直等待。
+const SpringShutdownGoroutineTimeout = "spring.shutdown.goroutine-timeout"
+
+const defaultGoroutineTimeout = 30 * time.Second
+
+// GoroutineInfo 通过 Go 方法创建并且仍在运行的 goroutine 。
+type GoroutineInfo struct {
+	Name  string    `json:"name"` // 函数名
+	Site  string    `json:"site"` // 创建 goroutine 的位置
+	Start time.Time `json:"start"`
+}
+
+// goroutineTracker 记录通过 Go 方法创建的 goroutine 。
+type goroutineTracker struct {
+	mutex   sync.Mutex
+	seq     int64
+	running map[int64]*GoroutineInfo
+}
+
+// add 记录即将创建的 goroutine ，返回其编号。
+func (t *goroutineTracker) add(fn interface{}) int64 {
+	info := &GoroutineInfo{
+		Name:  runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name(),
+		Site:  goSite(),
+		Start: time.Now(),
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.running == nil {
+		t.running = make(map[int64]*GoroutineInfo)
+	}
+	t.seq++
+	t.running[t.seq] = info
+	return t.seq
+}
+
+func (t *goroutineTracker) remove(id int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.running, id)
+}
+
+// list 返回仍在运行的 goroutine ，按照创建时间排序。
+func (t *goroutineTracker) list() []GoroutineInfo {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	ret := make([]GoroutineInfo, 0, len(t.running))
+	for _, info := range t.running {
+		ret = append(ret, *info)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Start.Before(ret[j].Start)
+	})
+	return ret
+}
+
+// goSite 返回 gs 包之外第一个调用者的位置，即用户创建 goroutine 的位置。
+func goSite() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, "github.com/go-spring/spring-core/gs.") {
+			return fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		if !more {
+			return fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+	}
+}
+
+// Goroutines 返回通过 Go 方法创建并且仍在运行的 goroutine ，容器关闭之后返回的是
+// 在 spring.shutdown.goroutine-timeout 时间内没有退出的 goroutine ，测试中可以据此
+// 检查 goroutine 泄漏。
+func (c *container) Goroutines() []GoroutineInfo {
+	return c.goroutines.list()
+}
+
+// waitGoroutines 等待通过 Go 方法创建的 goroutine 退出，超时之后报告没有退出的
+// goroutine 。
+func (c *container) waitGoroutines() {
+
+	if c.goTimeout <= 0 {
+		c.wg.Wait()
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(c.goTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return
+	case <-timer.C:
+	}
+
+	for _, g := range c.goroutines.list() {
+		log.Errorf("goroutine %s started at %s didn't exit within %v", g.Name, g.Site, c.goTimeout)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+)
+
+// BeanFootprint 单个 bean 占用的内存估算。
+type BeanFootprint struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Bytes int64  `json:"bytes"`
+}
+
+// FootprintReport 容器中所有 bean 占用的内存估算，按照占用从大到小排序。
+type FootprintReport struct {
+	Total int64           `json:"total"`
+	Beans []BeanFootprint `json:"beans"`
+}
+
+// memKey 已经访问过的内存，相同地址不同类型的值 (如结构体和它的第一个字段) 分别计算。
+type memKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// memWalker 通过反射遍历 bean 引用的对象估算其占用的内存。
+type memWalker struct {
+	beans   map[memKey]bool // 所有 bean 的地址，遍历到其他 bean 时停止
+	visited map[memKey]bool
+}
+
+// Footprint 通过反射遍历每个 bean 引用的对象估算其占用的内存，遇到其他 bean 时
+// 停止遍历，因此注入的依赖不会重复计算到每个使用者上，但是多个 bean 共享的非 bean
+// 对象会分别计算。结果只是估算值，不包括内存对齐、map 的桶等额外开销，遍历时也
+// 没有加锁，主要用于发现缓存配置等内存占用异常的 bean ，需要在容器刷新完成之后调用。
+func (c *container) Footprint() (*FootprintReport, error) {
+
+	if c.state != Refreshed || c.frozen == nil {
+		return nil, errors.New("footprint is only available after refresh")
+	}
+
+	w := &memWalker{beans: make(map[memKey]bool)}
+	for _, b := range c.frozen.beans {
+		if k, ok := memKeyOf(b.Value()); ok {
+			w.beans[k] = true
+		}
+	}
+
+	r := &FootprintReport{Beans: []BeanFootprint{}}
+	for _, b := range c.frozen.beans {
+		if b.Interface() == c {
+			continue
+		}
+		w.visited = make(map[memKey]bool)
+		n := w.root(b.Value())
+		r.Total += n
+		r.Beans = append(r.Beans, BeanFootprint{
+			ID:    b.ID(),
+			Type:  b.Type().String(),
+			Bytes: n,
+		})
+	}
+
+	sort.SliceStable(r.Beans, func(i, j int) bool {
+		return r.Beans[i].Bytes > r.Beans[j].Bytes
+	})
+	return r, nil
+}
+
+// memKeyOf 返回引用类型的值指向的地址。
+func memKeyOf(v reflect.Value) (memKey, bool) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Chan:
+		if v.IsNil() {
+			return memKey{}, false
+		}
+		return memKey{ptr: v.Pointer(), typ: v.Type()}, true
+	case reflect.Interface:
+		if v.IsNil() {
+			return memKey{}, false
+		}
+		return memKeyOf(v.Elem())
+	}
+	return memKey{}, false
+}
+
+// root 返回 bean 自身及其引用的对象占用的内存。
+func (w *memWalker) root(v reflect.Value) int64 {
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return 0
+		}
+		v = v.Elem()
+	}
+	if k, ok := memKeyOf(v); ok {
+		w.visited[k] = true
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		return int64(v.Type().Elem().Size()) + w.walk(v.Elem())
+	case reflect.Map, reflect.Slice, reflect.Chan:
+		return w.referenced(v)
+	}
+	return int64(v.Type().Size()) + w.walk(v)
+}
+
+// walk 返回值 v 引用的对象占用的内存，不包括 v 本身。
+func (w *memWalker) walk(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Chan:
+		k, ok := memKeyOf(v)
+		if !ok || w.beans[k] || w.visited[k] {
+			return 0
+		}
+		w.visited[k] = true
+		if v.Kind() == reflect.Ptr {
+			return int64(v.Type().Elem().Size()) + w.walk(v.Elem())
+		}
+		return w.referenced(v)
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		e := v.Elem()
+		switch e.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Chan:
+			return w.walk(e)
+		}
+		return int64(e.Type().Size()) + w.walk(e) // 装箱的值
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Struct:
+		var n int64
+		for i := 0; i < v.NumField(); i++ {
+			n += w.walk(v.Field(i))
+		}
+		return n
+	case reflect.Array:
+		var n int64
+		for i := 0; i < v.Len(); i++ {
+			n += w.walk(v.Index(i))
+		}
+		return n
+	}
+	return 0
+}
+
+// referenced 返回 map 、slice 和 chan 的底层存储及其元素引用的对象占用的内存。
+func (w *memWalker) referenced(v reflect.Value) int64 {
+	var n int64
+	switch v.Kind() {
+	case reflect.Slice:
+		n = int64(v.Cap()) * int64(v.Type().Elem().Size())
+		for i := 0; i < v.Len(); i++ {
+			n += w.walk(v.Index(i))
+		}
+	case reflect.Map:
+		t := v.Type()
+		n = int64(v.Len()) * int64(t.Key().Size()+t.Elem().Size())
+		iter := v.MapRange()
+		for iter.Next() {
+			n += w.walk(iter.Key()) + w.walk(iter.Value())
+		}
+	case reflect.Chan:
+		n = int64(v.Cap()) * int64(v.Type().Elem().Size())
+	}
+	return n
+}
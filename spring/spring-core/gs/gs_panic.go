@@ -0,0 +1,106 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+
+	"github.com/go-spring/spring-base/log"
+)
+
+// SpringInitPanic 全局的 bean 初始化 panic 处理策略，可选 fail 和 isolate ，默认为 fail 。
+const SpringInitPanic = "spring.init.panic"
+
+// InitPanicPolicy bean 初始化 panic 时的处理策略。
+type InitPanicPolicy string
+
+const (
+	InitPanicFail    = InitPanicPolicy("fail")    // 继续抛出 panic ，容器刷新失败
+	InitPanicIsolate = InitPanicPolicy("isolate") // 标记 bean 不健康并继续刷新
+)
+
+func parseInitPanicPolicy(s string) (InitPanicPolicy, error) {
+	switch p := InitPanicPolicy(s); p {
+	case "":
+		return InitPanicFail, nil
+	case InitPanicFail, InitPanicIsolate:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown init panic policy %q", s)
+}
+
+// initPanicError bean 初始化时发生的 panic 。
+type initPanicError struct {
+	r     interface{}
+	stack []byte
+}
+
+func (e *initPanicError) Error() string {
+	return fmt.Sprintf("init panic: %v", e.r)
+}
+
+// safeInit 执行 bean 的初始化函数，并将初始化过程中的 panic 转换为错误。
+func (c *container) safeInit(b *BeanDefinition, ctx Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &initPanicError{r: r, stack: debug.Stack()}
+		}
+	}()
+	return c.callInit(b, ctx)
+}
+
+// isolateInit 根据 bean 的处理策略决定初始化 panic 时是否继续刷新，被隔离的 bean
+// 仍然可以被注入，但是在健康检查中显示为 DOWN 。
+func (c *container) isolateInit(b *BeanDefinition, err error) error {
+
+	var e *initPanicError
+	if !errors.As(err, &e) {
+		return err
+	}
+
+	policy := b.policy
+	if policy == "" {
+		policy = c.initPanic
+	}
+	if policy != InitPanicIsolate {
+		// 重新抛出的 panic 只有 recover 处的堆栈，先输出原始的堆栈
+		log.Errorf("%s %s\n%s", b, err, e.stack)
+		panic(e.r)
+	}
+
+	log.Errorf("%s %s, isolated\n%s", b, err, e.stack)
+	if c.initFailures == nil {
+		c.initFailures = make(map[string]error)
+	}
+	c.initFailures[b.ID()] = err
+	return nil
+}
+
+// initFailureReports 返回被隔离的 bean 的健康状况。
+func (c *container) initFailureReports() []HealthReport {
+	var ret []HealthReport
+	for id, err := range c.initFailures {
+		ret = append(ret, HealthReport{Bean: id, Status: "DOWN", Error: err.Error()})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Bean < ret[j].Bean
+	})
+	return ret
+}
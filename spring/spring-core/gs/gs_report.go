@@ -0,0 +1,99 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sort"
+)
+
+// SpringReportFile 设置该属性后，应用在刷新完成之后将 bean 报告写入该文件然后退出，
+// 供 gs report 命令使用。
+const SpringReportFile = "spring.report.file"
+
+// BeanReport 单个 bean 的注册和注入情况。
+type BeanReport struct {
+	ID      string   `json:"id"`
+	Type    string   `json:"type"`
+	Source  string   `json:"source"`
+	Status  string   `json:"status"`
+	Reason  string   `json:"reason,omitempty"`  // bean 被删除的原因
+	Depends []string `json:"depends,omitempty"` // bean 注入时依赖的其他 bean
+}
+
+// Report 容器刷新之后的 bean 依赖关系和条件判断报告。
+type Report struct {
+	Beans  []BeanReport  `json:"beans"`
+	Events []WiringEvent `json:"events,omitempty"` // 装配决策
+}
+
+// addDepend 记录 bean 之间的依赖关系。
+func (c *container) addDepend(b *BeanDefinition, d *BeanDefinition) {
+	for _, r := range c.depends[b] {
+		if r == d {
+			return
+		}
+	}
+	c.depends[b] = append(c.depends[b], d)
+}
+
+// Report 返回容器刷新之后的 bean 报告，需要在清理临时数据之前调用。
+func (c *container) Report() (*Report, error) {
+
+	if c.state != Refreshed || c.tempContainer == nil {
+		return nil, errors.New("report is only available after refresh with AutoClear(false)")
+	}
+
+	c.dynamicMutex.Lock()
+	defer c.dynamicMutex.Unlock()
+
+	r := &Report{Beans: []BeanReport{}, Events: c.wiringEvents}
+	for _, b := range c.beans {
+		br := BeanReport{
+			ID:     b.ID(),
+			Type:   b.Type().String(),
+			Source: b.FileLine(),
+			Status: getStatusString(b.status),
+			Reason: c.deleted[b],
+		}
+		for _, d := range c.depends[b] {
+			br.Depends = append(br.Depends, d.ID())
+		}
+		sort.Strings(br.Depends)
+		r.Beans = append(r.Beans, br)
+	}
+
+	sort.Slice(r.Beans, func(i, j int) bool {
+		return r.Beans[i].ID < r.Beans[j].ID
+	})
+	return r, nil
+}
+
+// writeReport 将 bean 报告以 JSON 格式写入文件。
+func (c *container) writeReport(file string) error {
+	r, err := c.Report()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, b, 0644)
+}
@@ -0,0 +1,196 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/conf"
+)
+
+// SpringConfigStrict 开启后使用已废弃或者已改名的属性会导致容器刷新失败，默认只
+// 输出警告。
+const SpringConfigStrict = "spring.config.strict"
+
+// Schema 声明模块的属性约束，容器刷新时使用所有声明的约束校验合并之后的属性。
+func (c *container) Schema(s *conf.Schema) {
+	if c.state != Unrefreshed {
+		panic(ErrRegisterAfterRefresh)
+	}
+	c.schemas = append(c.schemas, s)
+}
+
+// setProperty 设置属性值并记录属性的来源。
+func (c *container) setProperty(key string, value string, source string) {
+	c.p.Set(key, value)
+	c.sources[key] = source
+}
+
+// sourceOf 返回属性的来源，对于 slice 和 map 类型的属性返回其第一个元素的来源。
+func (c *container) sourceOf(key string) string {
+	if s, ok := c.sources[key]; ok {
+		return s
+	}
+	for _, k := range c.p.Keys() {
+		if strings.HasPrefix(k, key+".") || strings.HasPrefix(k, key+"[") {
+			if s, ok := c.sources[k]; ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// validateSchemas 将已改名属性的值迁移到新属性上，然后使用所有声明的约束校验属性，
+// 非严格模式下已废弃的属性只输出警告，其他违反约束的属性汇总之后作为错误返回。
+func (c *container) validateSchemas() error {
+
+	for _, s := range c.schemas {
+		m, err := s.Migrate(c.p)
+		if err != nil {
+			return err
+		}
+		for oldKey, newKey := range m {
+			c.sources[newKey] = c.sources[oldKey]
+		}
+	}
+
+	strict := false
+	if s := c.p.Get(SpringConfigStrict); s != "" {
+		var err error
+		if strict, err = strconv.ParseBool(s); err != nil {
+			return fmt.Errorf("property %q error: %w", SpringConfigStrict, err)
+		}
+	}
+
+	var errs []string
+	for _, s := range c.schemas {
+		for _, v := range s.Validate(c.p) {
+			v.Source = c.sourceOf(v.Key)
+			if v.Warning && !strict {
+				log.Warn(v.String())
+				continue
+			}
+			errs = append(errs, v.String())
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.New("config schema violations:\n\t" + strings.Join(errs, "\n\t"))
+}
+
+// propertyDefaults 模块在代码中声明的默认属性。
+type propertyDefaults struct {
+	module   string
+	profiles []string
+	props    map[string]interface{}
+}
+
+// Defaults 注册模块的默认属性，其优先级低于属性文件、环境变量和命令行等所有来源，
+// 只填充没有被设置的属性，属性的来源记录为 defaults:module 。指定 profiles 时仅在
+// 其中任意一个 profile 激活时生效，并且优先于未指定 profile 的默认属性。多个模块
+// 声明了同一个属性时先注册的生效。
+func (c *container) Defaults(module string, props map[string]interface{}, profiles ...string) {
+	if c.state != Unrefreshed {
+		panic(ErrRegisterAfterRefresh)
+	}
+	c.defaults = append(c.defaults, &propertyDefaults{
+		module:   module,
+		profiles: profiles,
+		props:    props,
+	})
+}
+
+// applyDefaults 使用模块的默认属性填充没有被设置的属性。
+func (c *container) applyDefaults() error {
+
+	var active []string
+	for _, s := range strings.Split(c.p.Get("spring.profiles.active"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			active = append(active, s)
+		}
+	}
+
+	var list []*propertyDefaults
+	for _, d := range c.defaults {
+		if len(d.profiles) > 0 && matchProfiles(d.profiles, active) {
+			list = append(list, d)
+		}
+	}
+	for _, d := range c.defaults {
+		if len(d.profiles) == 0 {
+			list = append(list, d)
+		}
+	}
+
+	for _, d := range list {
+		p := conf.New()
+		for k, v := range d.props {
+			if err := p.Set(k, v); err != nil {
+				return fmt.Errorf("defaults of %s error: %w", d.module, err)
+			}
+		}
+		// 先确定需要填充的属性，避免同一模块的列表元素因为前面的元素已被填充而被跳过。
+		var keys []string
+		for _, key := range p.Keys() {
+			root := key
+			if i := strings.Index(key, "["); i > 0 {
+				root = key[:i]
+			}
+			if c.p.Has(key) || c.p.Has(root) {
+				continue
+			}
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			// 与已有属性的结构冲突时以已有属性为准。
+			if err := c.p.Set(key, p.Get(key)); err != nil {
+				log.Debugf("skip default property %s of %s: %v", key, d.module, err)
+				continue
+			}
+			c.sources[key] = "defaults:" + d.module
+		}
+	}
+	return nil
+}
+
+func matchProfiles(profiles []string, active []string) bool {
+	for _, profile := range profiles {
+		for _, s := range active {
+			if profile == s {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PropertySource 返回属性的来源，如属性文件的路径、command line 、system environment
+// 或者 defaults:module 等，通过 Property 方法直接设置的属性返回空字符串。
+func (c *container) PropertySource(key string) string {
+	if c.tempContainer == nil {
+		return c.propSources[key]
+	}
+	return c.sourceOf(key)
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/go-spring/spring-core/gs/cond"
+	"github.com/go-spring/spring-core/gs/internal"
+)
+
+// Snapshot 容器刷新完成之后 bean 注册表的只读快照。快照在刷新结束时创建，之后不
+// 会再发生任何变化，因此可以在不加锁的情况下被多个 goroutine 并发访问，适合在高
+// QPS 的代码路径上查找 bean 。快照里面只包含已经完成属性绑定和依赖注入的 bean 。
+type Snapshot struct {
+	beans       []*BeanDefinition
+	beansByName map[string][]*BeanDefinition
+	beansByType map[reflect.Type][]*BeanDefinition
+}
+
+func newSnapshot(beans []*BeanDefinition) *Snapshot {
+	s := &Snapshot{
+		beansByName: make(map[string][]*BeanDefinition),
+		beansByType: make(map[reflect.Type][]*BeanDefinition),
+	}
+	for _, b := range beans {
+		if b.status != Wired {
+			continue
+		}
+		s.beans = append(s.beans, b)
+		s.beansByName[b.name] = append(s.beansByName[b.name], b)
+		s.beansByType[b.Type()] = append(s.beansByType[b.Type()], b)
+		for _, t := range b.exports {
+			s.beansByType[t] = append(s.beansByType[t], b)
+		}
+	}
+	return s
+}
+
+// Frozen 返回容器刷新完成之后 bean 注册表的只读快照，刷新完成之前返回 nil 。
+func (c *container) Frozen() *Snapshot {
+	if c.state != Refreshed {
+		return nil
+	}
+	return c.frozen
+}
+
+// Find 查找符合条件的 bean 对象。
+func (s *Snapshot) Find(selector BeanSelector) []cond.BeanDefinition {
+	fn := selectorMatcher(selector)
+	var ret []cond.BeanDefinition
+	for _, b := range s.beans {
+		if fn(b) {
+			ret = append(ret, b)
+		}
+	}
+	return ret
+}
+
+// Get 根据类型和选择器获取唯一符合条件的 bean 对象，i 必须是一个指向 bean 接收
+// 者的指针，集合类型的接收者请使用 Context 的 Get 方法。
+func (s *Snapshot) Get(i interface{}, selector ...BeanSelector) error {
+
+	if i == nil {
+		return errors.New("i can't be nil")
+	}
+
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Ptr {
+		return errors.New("i must be pointer")
+	}
+
+	v = v.Elem()
+	if t := v.Type(); !internal.IsBeanType(t) {
+		return errors.New("i must be pointer to bean type")
+	}
+
+	var tag wireTag
+	switch len(selector) {
+	case 0:
+	case 1:
+		tag = toWireTag(selector[0])
+	default:
+		return errors.New("too many selectors")
+	}
+
+	b, err := lookupBean(s.beansByType, s.beansByName, v.Type(), tag)
+	if b == nil || err != nil {
+		return err
+	}
+	v.Set(b.valueFor(v.Type()))
+	return nil
+}
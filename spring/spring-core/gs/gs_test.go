@@ -17,15 +17,20 @@
 package gs_test
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"image"
+	"io"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/go-spring/spring-base/assert"
 	"github.com/go-spring/spring-base/cast"
@@ -36,8 +41,10 @@ import (
 	"github.com/go-spring/spring-core/gs"
 	"github.com/go-spring/spring-core/gs/arg"
 	"github.com/go-spring/spring-core/gs/cond"
+	"github.com/go-spring/spring-core/gs/internal"
 	pkg1 "github.com/go-spring/spring-core/gs/testdata/pkg/bar"
 	pkg2 "github.com/go-spring/spring-core/gs/testdata/pkg/foo"
+	"github.com/go-spring/spring-core/report"
 )
 
 func init() {
@@ -1201,6 +1208,179 @@ type RecoresCluster struct {
 	Endpoints string `value:"${redis.endpoints}"`
 }
 
+type abandonedInit struct {
+	wait chan struct{}
+	done chan error
+}
+
+// OnInit 超时之后才访问容器。
+func (b *abandonedInit) OnInit(ctx gs.Context) error {
+	<-b.wait
+	var s *string
+	b.done <- ctx.Get(&s)
+	return nil
+}
+
+func TestRegisterBean_InitTimeout(t *testing.T) {
+
+	t.Run("", func(t *testing.T) {
+		c := gs.New()
+		c.Object(new(int)).Init(func(i *int) {
+			time.Sleep(200 * time.Millisecond)
+		}).InitTimeout(10 * time.Millisecond)
+		err := c.Refresh()
+		assert.Error(t, err, "init timeout after 10ms")
+	})
+
+	t.Run("", func(t *testing.T) {
+		c := gs.New()
+		c.Property(gs.SpringInitTimeout, "10ms")
+		c.Object(new(int)).Init(func(i *int) {
+			time.Sleep(200 * time.Millisecond)
+		})
+		err := c.Refresh()
+		assert.Error(t, err, "init timeout, spring.init.timeout exceeded")
+	})
+
+	// spring.init.timeout 限制的是整个刷新过程，每个 bean 都没有超时也会失败。
+	t.Run("", func(t *testing.T) {
+		c := gs.New()
+		c.Property(gs.SpringInitTimeout, "50ms")
+		for i := 0; i < 5; i++ {
+			c.Object(new(int)).Name(fmt.Sprintf("i%d", i)).Init(func(i *int) {
+				time.Sleep(20 * time.Millisecond)
+			})
+		}
+		err := c.Refresh()
+		assert.Error(t, err, "spring.init.timeout exceeded")
+	})
+
+	t.Run("abandoned", func(t *testing.T) {
+		c := gs.New()
+		b := &abandonedInit{wait: make(chan struct{}), done: make(chan error)}
+		c.Object(b).InitTimeout(10 * time.Millisecond)
+		err := c.Refresh()
+		assert.Error(t, err, "init timeout after 10ms")
+		close(b.wait)
+		assert.Equal(t, <-b.done, errors.New("bean init is abandoned after timeout"))
+	})
+
+	t.Run("", func(t *testing.T) {
+		c := gs.New()
+		c.Property(gs.SpringInitTimeout, "10ms")
+		c.Object(new(int)).Init(func(i *int) {
+			*i = 3
+		}).InitTimeout(time.Second)
+		err := c.Refresh()
+		assert.Nil(t, err)
+	})
+}
+
+func TestRegisterBean_InitPanic(t *testing.T) {
+
+	t.Run("fail", func(t *testing.T) {
+		c := gs.New()
+		c.Object(new(int)).Init(func(i *int) {
+			panic("boom")
+		})
+		assert.Panic(t, func() {
+			_ = c.Refresh()
+		}, "boom")
+	})
+
+	t.Run("isolate", func(t *testing.T) {
+		c := gs.New()
+		c.Property(gs.SpringInitPanic, "isolate")
+		c.Object(new(int)).Init(func(i *int) {
+			panic("boom")
+		}).InitTimeout(time.Second)
+		c.Object(new(bytes.Buffer)).Init(func(b *bytes.Buffer) {
+			b.WriteString("ok")
+		})
+		err := c.Refresh()
+		assert.Nil(t, err)
+		s, err := c.(gs.Context).Snapshot()
+		assert.Nil(t, err)
+		assert.Equal(t, s.Health, []gs.HealthReport{
+			{Bean: "int:int", Status: "DOWN", Error: "init panic: boom"},
+		})
+	})
+
+	t.Run("bean policy", func(t *testing.T) {
+		c := gs.New()
+		c.Property(gs.SpringInitPanic, "isolate")
+		c.Object(new(int)).Init(func(i *int) {
+			panic("boom")
+		}).InitPanic(gs.InitPanicFail)
+		assert.Panic(t, func() {
+			_ = c.Refresh()
+		}, "boom")
+	})
+
+	t.Run("unknown policy", func(t *testing.T) {
+		c := gs.New()
+		c.Property(gs.SpringInitPanic, "ignore")
+		err := c.Refresh()
+		assert.Error(t, err, "unknown init panic policy \"ignore\"")
+	})
+}
+
+type auditReporter struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (r *auditReporter) Report(ctx context.Context, e *report.Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if e.Source == report.SourceAudit {
+		r.events = append(r.events, e.Message)
+	}
+}
+
+func TestAudit(t *testing.T) {
+
+	r := &auditReporter{}
+	report.Register(r)
+	defer report.Unregister(r)
+
+	c := gs.New()
+	c.Property(gs.SpringAuditEnabled, true)
+	c.Object(new(bytes.Buffer)).Init(func(b *bytes.Buffer) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			var s *bytes.Buffer
+			_ = c.(gs.Context).Get(&s)
+		}()
+		<-done
+	})
+	err := c.Refresh()
+	assert.Nil(t, err)
+
+	assert.Panic(t, func() {
+		c.Object(new(int))
+	}, "should call before Refresh")
+
+	start := make(chan struct{})
+	stop := make(chan struct{})
+	go func() {
+		_, _ = c.(gs.Context).Invoke(func() {
+			close(start)
+			<-stop
+		})
+	}()
+	<-start
+	c.Close()
+	close(stop)
+
+	assert.Equal(t, r.events, []string{
+		"audit: get during refresh",
+		"audit: register after refresh",
+		"audit: close while invocations are in flight (1)",
+	})
+}
+
 func TestApplicationContext_ValueBincoreng(t *testing.T) {
 	c := gs.New()
 	c.Property("redis.endpoints", "redis://localhost:6379")
@@ -1291,6 +1471,24 @@ func TestApplicationContext_Collect(t *testing.T) {
 		err := c.Refresh()
 		assert.Nil(t, err)
 	})
+
+	t.Run("deleted", func(t *testing.T) {
+		c := gs.New()
+		c.Property("redis.endpoints", "redis://localhost:6379")
+		c.Object(new(RecoresCluster)).Name("a")
+		c.Object(new(RecoresCluster)).Name("b").On(cond.OnProperty("b.enabled"))
+		c.Object(new(RecoresCluster)).Name("c").On(cond.OnProperty("c.enabled"))
+		err := runTest(c, func(p gs.Context) {
+			var rcs []*RecoresCluster
+			err := p.Get(&rcs, "*?")
+			assert.Nil(t, err)
+			assert.Equal(t, len(rcs), 1)
+			err = p.Get(&rcs)
+			assert.Nil(t, err)
+			assert.Equal(t, len(rcs), 1)
+		})
+		assert.Nil(t, err)
+	})
 }
 
 var defaultClassOption = ClassOption{
@@ -1469,6 +1667,68 @@ func TestOptionConstructorArg(t *testing.T) {
 		})
 		assert.Nil(t, err)
 	})
+
+	t.Run("option require", func(t *testing.T) {
+		c := gs.New()
+		c.Property("president", "CaiYuanPei")
+		c.Provide(NewClassRoom,
+			arg.Option(withClassName, "${class_name}", "${class_floor}").Require("class_name", "class_floor"),
+			arg.Option(withStudents).RequireBean((*Student)(nil)),
+		)
+		err := c.Refresh()
+		assert.Error(t, err, `missing property "class_name" \(option .*\), property "class_floor" \(option .*\), bean ".*gs_test.Student" \(option .*\)`)
+	})
+
+	t.Run("option require on condition", func(t *testing.T) {
+		c := gs.New()
+		c.Property("president", "CaiYuanPei")
+		c.Provide(NewClassRoom,
+			arg.Option(withClassName, "${class_name}", "${class_floor}").
+				On(cond.OnProperty("class_name")).
+				Require("class_name", "class_floor"),
+		)
+		err := runTest(c, func(p gs.Context) {
+			var cls *ClassRoom
+			err := p.Get(&cls)
+			assert.Nil(t, err)
+			assert.Equal(t, cls.className, "default")
+		})
+		assert.Nil(t, err)
+	})
+
+	t.Run("options from settings", func(t *testing.T) {
+		type ClassSettings struct {
+			Name     string `value:"${name:=}"`
+			Floor    int    `value:"${floor:=0}"`
+			Students bool   `value:"${students:=false}"`
+		}
+		c := gs.New()
+		c.Property("president", "CaiYuanPei")
+		c.Property("class.name", "二年级06班")
+		c.Property("class.floor", 3)
+		c.Property("class.students", true)
+		c.Provide(NewClassRoom, arg.Options(func(s ClassSettings, students []*Student) []ClassOptionFunc {
+			var opts []ClassOptionFunc
+			if s.Name != "" {
+				opts = append(opts, withClassName(s.Name, s.Floor))
+			}
+			if s.Students {
+				opts = append(opts, withStudents(students))
+			}
+			return opts
+		}, "${class}").Require("class.name"))
+		c.Object(&Student{}).Name("Student1")
+		c.Object(&Student{}).Name("Student2")
+		err := runTest(c, func(p gs.Context) {
+			var cls *ClassRoom
+			err := p.Get(&cls)
+			assert.Nil(t, err)
+			assert.Equal(t, cls.floor, 3)
+			assert.Equal(t, len(cls.students), 2)
+			assert.Equal(t, cls.className, "二年级06班")
+		})
+		assert.Nil(t, err)
+	})
 }
 
 type ServerInterface interface {
@@ -2410,6 +2670,50 @@ func TestApplicationContext_Destroy(t *testing.T) {
 	assert.Equal(t, destroyArray, []int{1, 2, 2, 4})
 }
 
+type lifecycleBean struct {
+	events []string
+}
+
+func (b *lifecycleBean) AfterPropertiesSet() error {
+	b.events = append(b.events, "AfterPropertiesSet")
+	return nil
+}
+
+func (b *lifecycleBean) Destroy() error {
+	b.events = append(b.events, "Destroy")
+	return nil
+}
+
+type failedInitBean struct{}
+
+func (b *failedInitBean) AfterPropertiesSet() error {
+	return errors.New("init failed")
+}
+
+func TestApplicationContext_LifecycleInterface(t *testing.T) {
+
+	t.Run("", func(t *testing.T) {
+		b := new(lifecycleBean)
+		c := gs.New()
+		c.Object(b).Init(func(b *lifecycleBean) {
+			b.events = append(b.events, "Init")
+		}).Destroy(func(b *lifecycleBean) {
+			b.events = append(b.events, "OnDestroy")
+		})
+		err := c.Refresh()
+		assert.Nil(t, err)
+		c.Close()
+		assert.Equal(t, b.events, []string{"AfterPropertiesSet", "Init", "Destroy", "OnDestroy"})
+	})
+
+	t.Run("", func(t *testing.T) {
+		c := gs.New()
+		c.Object(new(failedInitBean))
+		err := c.Refresh()
+		assert.Error(t, err, "init failed")
+	})
+}
+
 type Registry interface {
 	got()
 }
@@ -2896,64 +3200,919 @@ func TestMapCollection(t *testing.T) {
 	})
 }
 
-type circularA struct {
-	b *circularB
+type Strategy interface {
+	Apply(i int) int
 }
 
-func newCircularA(b *circularB) *circularA {
-	return &circularA{b: b}
-}
+type StrategyName string
 
-type circularB struct {
-	A *circularA `autowire:",lazy"`
+type doubleStrategy struct{}
+
+func (*doubleStrategy) Apply(i int) int { return i * 2 }
+
+type squareStrategy struct{}
+
+func (*squareStrategy) Apply(i int) int { return i * i }
+
+type StrategyRegistry struct {
+	strategies map[StrategyName]Strategy
 }
 
-func newCircularB() *circularB {
-	return new(circularB)
+func NewStrategyRegistry(strategies map[StrategyName]Strategy) *StrategyRegistry {
+	return &StrategyRegistry{strategies: strategies}
 }
 
-func TestLazy(t *testing.T) {
-	for i := 0; i < 1; i++ {
+func TestMapConstructorArg(t *testing.T) {
+
+	t.Run("", func(t *testing.T) {
 		c := gs.New()
-		c.Provide(newCircularA)
-		c.Provide(newCircularB)
-		d := struct {
-			b *circularB `autowire:""`
-		}{}
-		c.Object(&d)
-		err := c.Refresh()
+		c.Object(new(doubleStrategy)).Name("double").Export((*Strategy)(nil))
+		c.Object(new(squareStrategy)).Name("square").Export((*Strategy)(nil))
+		c.Provide(NewStrategyRegistry)
+		err := runTest(c, func(p gs.Context) {
+			var r *StrategyRegistry
+			err := p.Get(&r)
+			assert.Nil(t, err)
+			assert.Equal(t, len(r.strategies), 2)
+			assert.Equal(t, r.strategies["double"].Apply(3), 6)
+			assert.Equal(t, r.strategies["square"].Apply(3), 9)
+		})
 		assert.Nil(t, err)
-		assert.NotNil(t, d.b)
-		assert.NotNil(t, d.b.A)
-	}
+	})
+
+	t.Run("nullable", func(t *testing.T) {
+		c := gs.New()
+		c.Provide(NewStrategyRegistry, "*?")
+		err := runTest(c, func(p gs.Context) {
+			var r *StrategyRegistry
+			err := p.Get(&r)
+			assert.Nil(t, err)
+			assert.Equal(t, len(r.strategies), 0)
+		})
+		assert.Nil(t, err)
+	})
+
+	t.Run("duplicate name", func(t *testing.T) {
+		c := gs.New()
+		c.Object(new(doubleStrategy)).Name("a").Export((*Strategy)(nil))
+		c.Object(new(squareStrategy)).Name("a").Export((*Strategy)(nil))
+		c.Provide(NewStrategyRegistry)
+		err := c.Refresh()
+		assert.Error(t, err, "found duplicate bean name \"a\" for map\\[gs_test.StrategyName\\]gs_test.Strategy")
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		c := gs.New()
+		c.Object(new(doubleStrategy)).Export((*Strategy)(nil))
+		c.Provide(func(m map[int]Strategy) *StrategyRegistry { return nil })
+		err := c.Refresh()
+		assert.Error(t, err, "map key of map\\[int\\]gs_test.Strategy should be string")
+	})
 }
 
-type memory struct {
+type cubeStrategy struct{}
+
+func (*cubeStrategy) Apply(i int) int { return i * i * i }
+
+func TestFallbackFor(t *testing.T) {
+
+	t.Run("no other candidate", func(t *testing.T) {
+		c := gs.New()
+		c.Object(new(doubleStrategy)).FallbackFor((*Strategy)(nil))
+		err := runTest(c, func(p gs.Context) {
+			var s Strategy
+			err := p.Get(&s)
+			assert.Nil(t, err)
+			assert.Equal(t, s.Apply(3), 6)
+		})
+		assert.Nil(t, err)
+	})
+
+	t.Run("replaced", func(t *testing.T) {
+		c := gs.New()
+		c.Object(new(doubleStrategy)).FallbackFor((*Strategy)(nil))
+		c.Object(new(squareStrategy)).Export((*Strategy)(nil))
+		err := runTest(c, func(p gs.Context) {
+			var s Strategy
+			err := p.Get(&s)
+			assert.Nil(t, err)
+			assert.Equal(t, s.Apply(3), 9)
+			var d *doubleStrategy
+			err = p.Get(&d)
+			assert.Error(t, err, "can't find bean")
+			var all []Strategy
+			err = p.Get(&all)
+			assert.Nil(t, err)
+			assert.Equal(t, len(all), 1)
+		})
+		assert.Nil(t, err)
+	})
+
+	t.Run("replaced on condition", func(t *testing.T) {
+		c := gs.New()
+		c.Property("strategy", "square")
+		c.Object(new(doubleStrategy)).FallbackFor((*Strategy)(nil))
+		c.Object(new(squareStrategy)).Export((*Strategy)(nil)).On(cond.OnProperty("strategy", cond.HavingValue("square")))
+		c.Object(new(cubeStrategy)).Export((*Strategy)(nil)).On(cond.OnProperty("strategy", cond.HavingValue("cube")))
+		err := runTest(c, func(p gs.Context) {
+			var s Strategy
+			err := p.Get(&s)
+			assert.Nil(t, err)
+			assert.Equal(t, s.Apply(3), 9)
+		})
+		assert.Nil(t, err)
+	})
+
+	t.Run("partially replaced", func(t *testing.T) {
+		type Doubler interface {
+			Apply(i int) int
+		}
+		c := gs.New()
+		c.Object(new(doubleStrategy)).FallbackFor((*Strategy)(nil), (*Doubler)(nil))
+		c.Object(new(squareStrategy)).Export((*Strategy)(nil))
+		err := runTest(c, func(p gs.Context) {
+			var s Strategy
+			err := p.Get(&s)
+			assert.Nil(t, err)
+			assert.Equal(t, s.Apply(3), 9)
+			var d Doubler
+			err = p.Get(&d)
+			assert.Nil(t, err)
+			assert.Equal(t, d.Apply(3), 6)
+		})
+		assert.Nil(t, err)
+	})
 }
 
-func (m *memory) OnInit(ctx gs.Context) error {
-	fmt.Println("memory.OnInit")
-	return nil
+type metricsRecorder struct {
+	prefix string
 }
 
-func (m *memory) OnDestroy() {
-	fmt.Println("memory.OnDestroy")
+// baseDeps 多个 bean 共享的公共依赖。
+type baseDeps struct {
+	Metrics *metricsRecorder `autowire:""`
+	AppName string           `value:"${name}"`
 }
 
-type table struct {
-	_ *memory `autowire:""`
+type auditDeps struct {
+	Enabled bool `value:"${enabled:=false}"`
 }
 
-func (t *table) OnInit(ctx gs.Context) error {
-	fmt.Println("table.OnInit")
-	return nil
+type orderService struct {
+	*baseDeps `embed:"${app}"`
+	audit     auditDeps `embed:"${audit}"`
 }
 
-func (t *table) OnDestroy() {
-	fmt.Println("table.OnDestroy")
+type userService struct {
+	baseDeps `embed:"${app}"`
 }
 
-func TestDestroyDependence(t *testing.T) {
+func TestEmbedded(t *testing.T) {
+
+	t.Run("", func(t *testing.T) {
+		c := gs.New()
+		c.Property("app.name", "shop")
+		c.Property("audit.enabled", true)
+		c.Object(&metricsRecorder{prefix: "shop"})
+		order := new(orderService)
+		c.Object(order)
+		user := new(userService)
+		c.Object(user)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.NotNil(t, order.baseDeps)
+		assert.Equal(t, order.Metrics.prefix, "shop")
+		assert.Equal(t, order.AppName, "shop")
+		assert.True(t, order.audit.Enabled)
+		assert.Equal(t, user.Metrics.prefix, "shop")
+		assert.Equal(t, user.AppName, "shop")
+	})
+
+	t.Run("invalid type", func(t *testing.T) {
+		c := gs.New()
+		c.Object(&struct {
+			Name string `embed:""`
+		}{})
+		err := c.Refresh()
+		assert.Error(t, err, "embed should be struct or struct pointer, but string")
+	})
+}
+
+type pipeReader struct{ p *pipe }
+
+type pipeWriter struct{ p *pipe }
+
+type pipe struct{ buf []string }
+
+func (w *pipeWriter) Write(s string) { w.p.buf = append(w.p.buf, s) }
+
+func (r *pipeReader) Read() []string { return r.p.buf }
+
+func TestMultiResultConstructor(t *testing.T) {
+
+	t.Run("", func(t *testing.T) {
+		calls := 0
+		c := gs.New()
+		b := c.Provide(func(name string) (*pipeReader, *pipeWriter, error) {
+			calls++
+			p := &pipe{buf: []string{name}}
+			return &pipeReader{p}, &pipeWriter{p}, nil
+		}, "${pipe.name:=pipe}")
+		b.Result(1).Name("writer")
+		err := runTest(c, func(p gs.Context) {
+			var w *pipeWriter
+			err := p.Get(&w, "writer")
+			assert.Nil(t, err)
+			var r *pipeReader
+			err = p.Get(&r)
+			assert.Nil(t, err)
+			w.Write("a")
+			assert.Equal(t, r.Read(), []string{"pipe", "a"})
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, calls, 1)
+	})
+
+	t.Run("condition", func(t *testing.T) {
+		c := gs.New()
+		c.Provide(func() (*pipeReader, *pipeWriter) {
+			p := &pipe{}
+			return &pipeReader{p}, &pipeWriter{p}
+		}).On(cond.OnProperty("pipe.enabled"))
+		err := runTest(c, func(p gs.Context) {
+			var w *pipeWriter
+			err := p.Get(&w)
+			assert.Error(t, err, "can't find bean")
+		})
+		assert.Nil(t, err)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		c := gs.New()
+		c.Provide(func() (*pipeReader, *pipeWriter, error) {
+			return nil, nil, errors.New("pipe error")
+		})
+		err := c.Refresh()
+		assert.Error(t, err, "pipe error")
+	})
+}
+
+type describedFilter struct {
+	filterImpl
+}
+
+func (*describedFilter) String() string { return "described filter" }
+
+func TestExportFromInterfaceConstructor(t *testing.T) {
+	c := gs.New()
+	c.Provide(func() filter { return new(describedFilter) }).Export((*fmt.Stringer)(nil))
+	err := runTest(c, func(p gs.Context) {
+		var f filter
+		err := p.Get(&f)
+		assert.Nil(t, err)
+		assert.Equal(t, f.Filter("a"), "a")
+		var s fmt.Stringer
+		err = p.Get(&s)
+		assert.Nil(t, err)
+		assert.Equal(t, s.String(), "described filter")
+		var all []fmt.Stringer
+		err = p.Get(&all)
+		assert.Nil(t, err)
+		assert.Equal(t, len(all), 1)
+		var m map[string]fmt.Stringer
+		err = p.Get(&m)
+		assert.Nil(t, err)
+		assert.Equal(t, m["filter"].String(), "described filter")
+	})
+	assert.Nil(t, err)
+}
+
+func TestCollectByTag(t *testing.T) {
+
+	type handler struct {
+		v string
+	}
+
+	t.Run("", func(t *testing.T) {
+		c := gs.New()
+		c.Object(&handler{"a"}).Name("a").Order(2).Tag("handler")
+		c.Object(&handler{"b"}).Name("b").Order(1).Tag("handler", "admin")
+		c.Object(&handler{"c"}).Name("c")
+		s := &struct {
+			Handlers []*handler          `autowire:"[]?tag=handler"`
+			Admins   map[string]*handler `autowire:"[]?tag=handler&tag=admin"`
+			Others   []*handler          `autowire:"[]?tag=other?"`
+		}{}
+		c.Object(s)
+		err := runTest(c, func(p gs.Context) {
+			var m map[string]*handler
+			err := p.Get(&m, "[]?tag=handler")
+			assert.Nil(t, err)
+			assert.Equal(t, len(m), 2)
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, len(s.Handlers), 2)
+		assert.Equal(t, s.Handlers[0].v, "b")
+		assert.Equal(t, s.Handlers[1].v, "a")
+		assert.Equal(t, len(s.Admins), 1)
+		assert.Equal(t, s.Admins["b"].v, "b")
+		assert.Equal(t, len(s.Others), 0)
+	})
+
+	t.Run("", func(t *testing.T) {
+		c := gs.New()
+		c.Object(&handler{"a"}).Tag("handler")
+		c.Object(&struct {
+			Handlers []*handler `autowire:"[]?tag=other"`
+		}{})
+		err := c.Refresh()
+		assert.Error(t, err, "no beans collected for \"\\[\\]\\?tag=other\"")
+	})
+
+	t.Run("", func(t *testing.T) {
+		c := gs.New()
+		c.Object(&handler{"a"}).Tag("handler")
+		c.Object(&struct {
+			Handlers []*handler `autowire:"[]?type=a"`
+		}{})
+		err := c.Refresh()
+		assert.Error(t, err, "unsupported collection query \"type\"")
+	})
+
+	t.Run("qualifier", func(t *testing.T) {
+		c := gs.New()
+		c.Object(&handler{"a"}).Name("a").Order(2).Tag("handler")
+		c.Object(&handler{"b"}).Name("b").Order(1).Tag("handler")
+		c.Object(&handler{"c"}).Name("c").Tag("handler")
+		c.Object(&handler{"d"}).Name("d")
+		s := &struct {
+			Handlers []*handler          `autowire:"[]?tag=handler&name=a&name=b"`
+			Named    map[string]*handler `autowire:"[]?name=c&name=d"`
+			Missing  []*handler          `autowire:"[]?tag=handler&name=d?"`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Equal(t, len(s.Handlers), 2)
+		assert.Equal(t, s.Handlers[0].v, "b")
+		assert.Equal(t, s.Handlers[1].v, "a")
+		assert.Equal(t, len(s.Named), 2)
+		assert.Equal(t, s.Named["d"].v, "d")
+		assert.Equal(t, len(s.Missing), 0)
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+
+	type BeanZero struct {
+		Int int
+	}
+
+	c := gs.New()
+	assert.Nil(t, c.(gs.Context).Frozen())
+
+	c.Object(&BeanZero{5}).Name("zero")
+	c.Object(&BeanZero{6}).Name("six").On(cond.OnProperty("six"))
+	c.Object(bytes.NewBufferString("buffer")).Export((*io.Reader)(nil))
+	err := c.Refresh()
+	assert.Nil(t, err)
+
+	s := c.(gs.Context).Frozen()
+	assert.NotNil(t, s)
+	assert.Equal(t, len(s.Find((*BeanZero)(nil))), 1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var b *BeanZero
+			assert.Nil(t, s.Get(&b, "zero"))
+			assert.Equal(t, b.Int, 5)
+			var r io.Reader
+			assert.Nil(t, s.Get(&r))
+		}()
+	}
+	wg.Wait()
+
+	var b *BeanZero
+	err = s.Get(&b, "six")
+	assert.Error(t, err, "can't find bean")
+
+	assert.Panic(t, func() {
+		c.Object(new(int))
+	}, "should call before Refresh")
+}
+
+func TestDynamicRegistry(t *testing.T) {
+
+	type Plugin struct {
+		Name   string        `value:"${plugin.name}"`
+		Buffer *bytes.Buffer `autowire:""`
+		closed bool
+	}
+
+	c := gs.New()
+	assert.Nil(t, c.(gs.Context).Dynamic())
+
+	c.Property("plugin.name", "demo")
+	c.Object(bytes.NewBufferString("buffer"))
+	err := c.Refresh(internal.AutoClear(false))
+	assert.Nil(t, err)
+
+	r := c.(gs.Context).Dynamic()
+	p := &Plugin{}
+	b := gs.NewBean(p).Name("demo").Destroy(func(p *Plugin) { p.closed = true })
+	err = r.Register(b)
+	assert.Nil(t, err)
+	assert.Equal(t, p.Name, "demo")
+	assert.Equal(t, p.Buffer.String(), "buffer")
+
+	err = r.Register(gs.NewBean(&Plugin{}).Name("demo"))
+	assert.Error(t, err, "found duplicate beans")
+
+	err = r.Register(gs.NewBean(&Plugin{}).Name("off").On(cond.OnProperty("plugin.off")))
+	assert.Error(t, err, "doesn't match its condition")
+
+	var got *Plugin
+	err = r.Get(&got, "demo")
+	assert.Nil(t, err)
+	assert.Equal(t, got, p)
+	assert.Equal(t, len(c.(gs.Context).Frozen().Find("demo")), 0)
+
+	assert.Equal(t, r.Unregister("demo"), 1)
+	assert.True(t, p.closed)
+	assert.Equal(t, len(r.Find("demo")), 0)
+
+	c.Close()
+}
+
+func TestDynamicRegistry_ConcurrentChildren(t *testing.T) {
+
+	type Plugin struct {
+		Buffer *bytes.Buffer `autowire:""`
+	}
+
+	c := gs.New()
+	c.Object(bytes.NewBufferString("buffer"))
+	err := c.Refresh(internal.AutoClear(false))
+	assert.Nil(t, err)
+
+	const n = 8
+	r := c.(gs.Context).Dynamic()
+	plugins := make([]*Plugin, n)
+	errs := make([][]error, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		i, child := i, r.Child(fmt.Sprintf("plugin-%d", i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			plugins[i] = &Plugin{}
+			errs[i] = append(errs[i], child.Register(gs.NewBean(plugins[i]).Name("plugin")))
+			for j := 0; j < 20; j++ {
+				b := gs.NewBean(&Plugin{}).On(cond.OnProperty("plugin.off"))
+				errs[i] = append(errs[i], child.Register(b))
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		assert.Nil(t, errs[i][0])
+		assert.Equal(t, plugins[i].Buffer.String(), "buffer")
+		for _, err = range errs[i][1:] {
+			assert.Error(t, err, "doesn't match its condition")
+		}
+	}
+	c.Close()
+}
+
+func TestReport(t *testing.T) {
+
+	type Dao struct{}
+	type Service struct {
+		Dao *Dao `autowire:""`
+	}
+
+	c := gs.New()
+	c.Object(&Dao{}).Name("dao")
+	c.Object(&Service{}).Name("service")
+	c.Object(&Dao{}).Name("mock-dao").On(cond.OnProperty("mock"))
+	err := c.Refresh(gs.AutoClear(false))
+	assert.Nil(t, err)
+
+	r, err := c.(gs.Context).Report()
+	assert.Nil(t, err)
+
+	const pkg = "github.com/go-spring/spring-core/gs_test/gs_test."
+	beans := make(map[string]gs.BeanReport)
+	for _, b := range r.Beans {
+		beans[b.ID] = b
+	}
+	assert.Equal(t, beans[pkg+"Service:service"].Depends, []string{pkg + "Dao:dao"})
+	assert.Equal(t, beans[pkg+"Dao:mock-dao"].Status, "Deleted")
+	assert.Equal(t, beans[pkg+"Dao:mock-dao"].Reason, "condition not matched")
+}
+
+func TestWiringEvents(t *testing.T) {
+
+	type Dao struct{}
+
+	c := gs.New()
+	c.Object(&Dao{}).Name("dao")
+	c.Object(&Dao{}).Name("primary-dao").Primary()
+	c.Object(&Dao{}).Name("mock-dao").On(cond.OnProperty("mock"))
+	c.Object(new(doubleStrategy)).FallbackFor((*Strategy)(nil))
+	c.Object(new(squareStrategy)).Export((*Strategy)(nil))
+	c.Object(new(filterImpl)).FallbackFor((*filter)(nil))
+	err := c.Refresh(gs.AutoClear(false))
+	assert.Nil(t, err)
+
+	r, err := c.(gs.Context).Report()
+	assert.Nil(t, err)
+
+	const pkg = "github.com/go-spring/spring-core/gs_test/gs_test."
+	assert.Equal(t, len(r.Events), 5)
+	assert.True(t, strings.HasPrefix(r.Events[2].Reason, `fallback replaced gs_test.Strategy by object bean name:"squareStrategy"`))
+	r.Events[2].Reason = ""
+
+	assert.Equal(t, r.Events, []gs.WiringEvent{
+		{Kind: gs.WiringExcluded, Bean: pkg + "Dao:mock-dao", Reason: "condition not matched"},
+		{Kind: gs.WiringOverridden, Bean: pkg + "squareStrategy:squareStrategy", Other: pkg + "doubleStrategy:doubleStrategy", Type: "gs_test.Strategy", Reason: "fallback replaced"},
+		{Kind: gs.WiringExcluded, Bean: pkg + "doubleStrategy:doubleStrategy"},
+		{Kind: gs.WiringFallback, Bean: pkg + "filterImpl:filterImpl", Type: "gs_test.filter", Reason: "no other candidate"},
+		{Kind: gs.WiringOverridden, Bean: pkg + "Dao:primary-dao", Other: pkg + "Dao:dao", Type: "*gs_test.Dao", Reason: "primary"},
+	})
+}
+
+func TestRenamedProperty(t *testing.T) {
+
+	type Config struct {
+		Host string `value:"${redis.host}"`
+		Port int    `value:"${redis.port:=6379}"`
+	}
+
+	schema := conf.NewSchema("redis").
+		Renamed("redis.addr", "redis.host").
+		Renamed("redis.server.port", "redis.port")
+
+	t.Run("migrate", func(t *testing.T) {
+		c := gs.New()
+		c.Property("redis.addr", "127.0.0.1")
+		c.Property("redis.server.port", 6380)
+		c.Schema(schema)
+		cfg := new(Config)
+		c.Object(cfg)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Equal(t, cfg, &Config{Host: "127.0.0.1", Port: 6380})
+	})
+
+	t.Run("new key wins", func(t *testing.T) {
+		c := gs.New()
+		c.Property("redis.addr", "127.0.0.1")
+		c.Property("redis.host", "localhost")
+		c.Schema(schema)
+		cfg := new(Config)
+		c.Object(cfg)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Equal(t, cfg, &Config{Host: "localhost", Port: 6379})
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		c := gs.New()
+		c.Property("redis.addr", "127.0.0.1")
+		c.Property(gs.SpringConfigStrict, true)
+		c.Schema(schema)
+		err := c.Refresh()
+		assert.Error(t, err, `property "redis.addr" has been renamed to "redis.host"`)
+	})
+}
+
+func TestDefaults(t *testing.T) {
+
+	type Config struct {
+		Host    string     `value:"${redis.host}"`
+		Port    int        `value:"${redis.port}"`
+		Pool    int        `value:"${redis.pool.size}"`
+		Servers []string   `value:"${redis.servers}"`
+		Ctx     gs.Context `autowire:""`
+	}
+
+	c := gs.New()
+	c.Property("spring.profiles.active", "dev")
+	c.Property("redis.port", 6380)
+	c.Property("redis.servers", []string{"a"})
+	c.Defaults("redis", map[string]interface{}{
+		"redis": map[string]interface{}{
+			"host":    "127.0.0.1",
+			"port":    6379,
+			"servers": []string{"b", "c"},
+		},
+		"redis.pool.size": 10,
+	})
+	c.Defaults("redis-dev", map[string]interface{}{
+		"redis.host": "localhost",
+	}, "dev")
+	c.Defaults("redis-prod", map[string]interface{}{
+		"redis.host": "redis.prod",
+	}, "prod")
+	c.Defaults("other", map[string]interface{}{
+		"redis.pool.size": 20,
+	})
+	cfg := new(Config)
+	c.Object(cfg)
+	err := c.Refresh()
+	assert.Nil(t, err)
+	assert.Equal(t, cfg.Host, "localhost")
+	assert.Equal(t, cfg.Port, 6380)
+	assert.Equal(t, cfg.Pool, 10)
+	assert.Equal(t, cfg.Servers, []string{"a"})
+	assert.Equal(t, cfg.Ctx.PropertySource("redis.host"), "defaults:redis-dev")
+	assert.Equal(t, cfg.Ctx.PropertySource("redis.pool.size"), "defaults:redis")
+	assert.Equal(t, cfg.Ctx.PropertySource("redis.port"), "")
+
+	s, err := cfg.Ctx.Snapshot()
+	assert.Nil(t, err)
+	assert.Equal(t, s.Sources["redis.pool.size"], "defaults:redis")
+
+	t.Run("conflict", func(t *testing.T) {
+		c := gs.New()
+		c.Defaults("a", map[string]interface{}{"a": 1})
+		c.Defaults("b", map[string]interface{}{"a.b": 2})
+		err := c.Refresh()
+		assert.Nil(t, err)
+	})
+}
+
+func TestDocumentation(t *testing.T) {
+
+	type Server struct {
+		Addr  string `value:"${server.addr}"`
+		Redis struct {
+			Host string `value:"${host}"`
+		} `value:"${redis}"`
+	}
+
+	c := gs.New()
+	c.Object(&Server{}).
+		Describe("http server").
+		On(cond.OnProperty("server.enabled", cond.HavingValue("true"))).
+		Export((*fmt.Stringer)(nil))
+	c.Provide(func(name string) *bytes.Buffer { return bytes.NewBufferString(name) }, "${app.name:=demo}").Name("buf")
+
+	doc, err := c.(gs.Context).Documentation()
+	assert.Nil(t, err)
+	assert.Equal(t, len(doc.Beans), 2)
+	assert.Equal(t, doc.Beans[0].Name, "buf")
+	assert.Equal(t, doc.Beans[0].Properties, []string{"app.name"})
+	assert.Equal(t, doc.Beans[1].Description, "http server")
+	assert.Equal(t, doc.Beans[1].Condition, "property(server.enabled=true)")
+	assert.Equal(t, doc.Beans[1].Properties, []string{"redis", "server.addr", "server.enabled"})
+	assert.Equal(t, doc.Beans[1].Exports, []string{"fmt.Stringer"})
+
+	err = c.Refresh()
+	assert.Nil(t, err)
+	_, err = c.(gs.Context).Documentation()
+	assert.Error(t, err, "documentation is only available before refresh")
+}
+
+func TestFootprint(t *testing.T) {
+
+	type Cache struct {
+		Name string
+		Data map[string][]byte
+	}
+
+	type Service struct {
+		Cache *Cache   `autowire:""`
+		Names []string `value:"${names}"`
+		Self  *Service
+	}
+
+	c := gs.New()
+	c.Property("names", "a,bc")
+	c.Object(&Cache{
+		Name: "cache",
+		Data: map[string][]byte{"k": make([]byte, 1024)},
+	})
+	c.Provide(func() *Service {
+		s := &Service{}
+		s.Self = s
+		return s
+	})
+
+	_, err := c.(gs.Context).Footprint()
+	assert.Error(t, err, "footprint is only available after refresh")
+
+	err = c.Refresh()
+	assert.Nil(t, err)
+	r, err := c.(gs.Context).Footprint()
+	assert.Nil(t, err)
+	assert.Equal(t, len(r.Beans), 2)
+
+	cacheSize := int64(unsafe.Sizeof(Cache{})) + 5 + int64(unsafe.Sizeof("")+unsafe.Sizeof([]byte{})) + 1 + 1024
+	assert.Equal(t, r.Beans[0].Type, "*gs_test.Cache")
+	assert.Equal(t, r.Beans[0].Bytes, cacheSize)
+
+	serviceSize := int64(unsafe.Sizeof(Service{})) + 2*int64(unsafe.Sizeof("")) + 3
+	assert.Equal(t, r.Beans[1].Type, "*gs_test.Service")
+	assert.Equal(t, r.Beans[1].Bytes, serviceSize)
+	assert.Equal(t, r.Total, cacheSize+serviceSize)
+}
+
+func TestDryRun(t *testing.T) {
+
+	type Conn struct {
+		Addr string `value:"${redis.addr}"`
+	}
+
+	type Service struct {
+		Conn    *Conn    `autowire:""`
+		Timeout int      `value:"${service.timeout:=3}"`
+		Filters []filter `autowire:"*?"`
+	}
+
+	newConn := func(addr string) *Conn {
+		panic("constructor should not be called in dry run")
+	}
+
+	t.Run("success", func(t *testing.T) {
+		c := gs.New()
+		c.Property("redis.addr", "127.0.0.1:6379")
+		c.Provide(newConn, "${redis.addr}").Init(func(*Conn) {
+			panic("init should not be called in dry run")
+		}).Destroy(func(*Conn) {
+			panic("destroy should not be called in dry run")
+		})
+		c.Object(new(Service))
+		c.Object(new(filterImpl)).Export((*filter)(nil)).On(cond.OnProperty("filter.enabled"))
+		err := c.Refresh(gs.AutoClear(false), gs.DryRunMode(true))
+		assert.Nil(t, err)
+
+		r, err := c.(gs.Context).Report()
+		assert.Nil(t, err)
+		status := make(map[string]string)
+		for _, b := range r.Beans {
+			status[b.Type] = b.Status
+		}
+		assert.Equal(t, status["*gs_test.Conn"], "Wired")
+		assert.Equal(t, status["*gs_test.Service"], "Wired")
+		assert.Equal(t, status["*gs_test.filterImpl"], "Deleted")
+		c.Close()
+	})
+
+	t.Run("missing property", func(t *testing.T) {
+		c := gs.New()
+		c.Provide(newConn, "${redis.addr}")
+		err := c.Refresh(gs.DryRunMode(true))
+		assert.Error(t, err, "property \"redis.addr\" not exist")
+	})
+
+	t.Run("missing bean", func(t *testing.T) {
+		c := gs.New()
+		c.Object(new(Service))
+		err := c.Refresh(gs.DryRunMode(true))
+		assert.Error(t, err, "can't find bean, bean:\"\" type:\"\\*gs_test.Conn\"")
+	})
+}
+
+type downIndicator struct{}
+
+func (*downIndicator) Health(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+type upIndicator struct{}
+
+func (*upIndicator) Health(ctx context.Context) error {
+	return nil
+}
+
+func TestContextSnapshot(t *testing.T) {
+
+	c := gs.New()
+	_, err := c.(gs.Context).Snapshot()
+	assert.Error(t, err, "snapshot is only available after refresh")
+
+	c.Property("spring.profiles.active", "dev, test")
+	c.Property("db.url", "mysql://127.0.0.1")
+	c.Property("db.password", "123456")
+	c.Object(new(downIndicator)).Name("db")
+	c.Object(new(upIndicator)).Name("cache")
+	err = c.Refresh()
+	assert.Nil(t, err)
+
+	s, err := c.(gs.Context).Snapshot()
+	assert.Nil(t, err)
+	assert.Equal(t, s.Profiles, []string{"dev", "test"})
+	assert.Equal(t, s.Properties["db.url"], "mysql://127.0.0.1")
+	assert.Equal(t, s.Properties["db.password"], conf.MaskedValue)
+	assert.Equal(t, s.Versions["go-spring"], gs.Version)
+	assert.Equal(t, s.Health, []gs.HealthReport{
+		{Bean: "github.com/go-spring/spring-core/gs_test/gs_test.downIndicator:db", Status: "DOWN", Error: "connection refused"},
+		{Bean: "github.com/go-spring/spring-core/gs_test/gs_test.upIndicator:cache", Status: "UP"},
+	})
+	assert.Equal(t, len(s.Beans), 3)
+	assert.Equal(t, s.Beans[0].ID, "github.com/go-spring/spring-core/gs/gs.container:container")
+
+	// 注册 conf.Sanitizer bean 之后使用自定义的规则
+	c = gs.New()
+	c.Property("db.url", "mysql://127.0.0.1")
+	c.Property("db.password", "123456")
+	c.Object(conf.NewSanitizer("url")).Export((*conf.Sanitizer)(nil))
+	err = c.Refresh()
+	assert.Nil(t, err)
+	s, err = c.(gs.Context).Snapshot()
+	assert.Nil(t, err)
+	assert.Equal(t, s.Properties["db.url"], conf.MaskedValue)
+	assert.Equal(t, s.Properties["db.password"], "123456")
+}
+
+func TestPlaceholderInAutowireTag(t *testing.T) {
+
+	type cache struct {
+		name string
+	}
+
+	c := gs.New()
+	c.Property("cache.impl", "redis")
+	c.Object(&cache{"redis"}).Name("redis-cache")
+	c.Object(&cache{"memory"}).Name("memory-cache")
+	s := &struct {
+		Cache   *cache `autowire:"${cache.impl}-cache"`
+		Default *cache `autowire:"${cache.default:=memory}-cache"`
+	}{}
+	c.Object(s)
+	err := c.Refresh()
+	assert.Nil(t, err)
+	assert.Equal(t, s.Cache.name, "redis")
+	assert.Equal(t, s.Default.name, "memory")
+}
+
+type circularA struct {
+	b *circularB
+}
+
+func newCircularA(b *circularB) *circularA {
+	return &circularA{b: b}
+}
+
+type circularB struct {
+	A *circularA `autowire:",lazy"`
+}
+
+func newCircularB() *circularB {
+	return new(circularB)
+}
+
+func TestLazy(t *testing.T) {
+	for i := 0; i < 1; i++ {
+		c := gs.New()
+		c.Provide(newCircularA)
+		c.Provide(newCircularB)
+		d := struct {
+			b *circularB `autowire:""`
+		}{}
+		c.Object(&d)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.NotNil(t, d.b)
+		assert.NotNil(t, d.b.A)
+	}
+}
+
+type memory struct {
+}
+
+func (m *memory) OnInit(ctx gs.Context) error {
+	fmt.Println("memory.OnInit")
+	return nil
+}
+
+func (m *memory) OnDestroy() {
+	fmt.Println("memory.OnDestroy")
+}
+
+type table struct {
+	_ *memory `autowire:""`
+}
+
+func (t *table) OnInit(ctx gs.Context) error {
+	fmt.Println("table.OnInit")
+	return nil
+}
+
+func (t *table) OnDestroy() {
+	fmt.Println("table.OnDestroy")
+}
+
+func TestDestroyDependence(t *testing.T) {
 	c := gs.New()
 	c.Object(new(memory))
 	c.Object(new(table)).Name("aaa")
@@ -2961,3 +4120,29 @@ func TestDestroyDependence(t *testing.T) {
 	err := c.Refresh()
 	assert.Nil(t, err)
 }
+
+func TestGoroutines(t *testing.T) {
+
+	c := gs.New()
+	c.Property(gs.SpringShutdownGoroutineTimeout, "50ms")
+	err := c.Refresh()
+	assert.Nil(t, err)
+
+	ctx := c.(gs.Context)
+	stop := make(chan struct{})
+	ctx.Go(func(ctx context.Context) { <-ctx.Done() })
+	ctx.Go(func(ctx context.Context) { <-stop })
+
+	g := ctx.Goroutines()
+	assert.Equal(t, len(g), 2)
+	assert.True(t, strings.Contains(g[0].Site, "gs_test.go"))
+
+	start := time.Now()
+	c.Close()
+	assert.True(t, time.Since(start) < time.Second)
+
+	g = ctx.Goroutines()
+	assert.Equal(t, len(g), 1)
+	assert.True(t, strings.HasPrefix(g[0].Name, "github.com/go-spring/spring-core/gs_test.TestGoroutines.func"))
+	close(stop)
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"reflect"
+
+	"github.com/go-spring/spring-base/log"
+)
+
+// 装配决策的类型。
+const (
+	WiringExcluded   = "excluded"   // bean 因为条件不成立等原因被排除
+	WiringOverridden = "overridden" // bean 作为主版本或者非默认实现覆盖了其他 bean
+	WiringFallback   = "fallback"   // 接口没有其他实现，使用了默认实现
+)
+
+// SpringStubMissingBeans 开发环境下设置为 true 时，stubgen 工具生成的桩实现作为接口
+// 的默认实现生效，缺少实现的接口会注入只记录调用并返回零值的桩实现而不是刷新失败。
+const SpringStubMissingBeans = "spring.stub.missing-beans"
+
+// StubTag stubgen 工具生成的桩实现携带的标签。
+const StubTag = "stub"
+
+// WiringEvent 容器刷新过程中的装配决策，包含在 Report 中，也会由事件总线在应用启动时
+// 发布，平台团队可以据此集中检查大型代码库中的装配情况。
+type WiringEvent struct {
+	Kind   string `json:"kind"`
+	Bean   string `json:"bean"`            // 决策涉及的 bean
+	Other  string `json:"other,omitempty"` // 被覆盖的 bean
+	Type   string `json:"type,omitempty"`  // 决策涉及的类型
+	Reason string `json:"reason,omitempty"`
+}
+
+func (c *container) addWiringEvent(e WiringEvent) {
+	log.Debugf("wiring %s bean:%q other:%q type:%q %s", e.Kind, e.Bean, e.Other, e.Type, e.Reason)
+	c.wiringEvents = append(c.wiringEvents, e)
+}
+
+// exclude 将 bean 标记为已删除并记录原因。
+func (c *container) exclude(b *BeanDefinition, reason string) {
+	b.status = Deleted
+	c.deleted[b] = reason
+	c.addWiringEvent(WiringEvent{Kind: WiringExcluded, Bean: b.ID(), Reason: reason})
+}
+
+// resolvePrimaries 记录主版本 bean 覆盖同类型其他 bean 的决策。
+func (c *container) resolvePrimaries() {
+	for _, b := range c.beans {
+		if b.status == Deleted || !b.primary {
+			continue
+		}
+		for _, t := range append([]reflect.Type{b.Type()}, b.exports...) {
+			for _, d := range c.beansByType[t] {
+				if d == b || d.status == Deleted || d.primary {
+					continue
+				}
+				c.addWiringEvent(WiringEvent{
+					Kind:   WiringOverridden,
+					Bean:   b.ID(),
+					Other:  d.ID(),
+					Type:   t.String(),
+					Reason: "primary",
+				})
+			}
+		}
+	}
+}
@@ -43,6 +43,7 @@ type BeanDefinition interface {
 
 type RefreshArg struct {
 	AutoClear bool
+	DryRun    bool
 }
 
 type RefreshOption func(arg *RefreshArg)
@@ -53,6 +54,12 @@ func AutoClear(enable bool) RefreshOption {
 	}
 }
 
+func DryRun(enable bool) RefreshOption {
+	return func(arg *RefreshArg) {
+		arg.DryRun = enable
+	}
+}
+
 // TypeOf 获取任意数据的真实类型。
 func TypeOf(i interface{}) reflect.Type {
 	switch o := i.(type) {
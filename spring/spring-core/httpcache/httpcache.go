@@ -0,0 +1,315 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package httpcache 提供了缓存 HTTP 响应的过滤器。读多写少的路由可以按照路由分组配置
+// 缓存时间和缓存键的计算方式，缓存的响应带有 ETag 和 Last-Modified 响应头，客户端
+// 携带 If-None-Match 或者 If-Modified-Since 请求头并且内容没有变化时返回 304 。另外
+// NewSingleFlightFilter 可以将并发的相同 GET 请求合并为一次处理，例如：
+//
+//	gs.Provide(httpcache.NewSingleFlightFilter, "${web.single-flight}")
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/web"
+)
+
+// HeaderCache 表示响应是否来自缓存，取值为 HIT 或者 MISS 。
+const HeaderCache = "X-Cache"
+
+// 内置的缓存键计算方式。
+const (
+	KeyURL  = "url"  // 请求路径和排序后的查询参数
+	KeyPath = "path" // 只使用请求路径，忽略查询参数
+)
+
+// KeyFunc 计算请求的缓存键。
+type KeyFunc func(r *http.Request) string
+
+// KeyByURL 使用请求路径和排序后的查询参数作为缓存键。
+func KeyByURL(r *http.Request) string {
+	q := r.URL.Query()
+	if len(q) == 0 {
+		return r.URL.Path
+	}
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString(r.URL.Path)
+	for i, k := range keys {
+		if i == 0 {
+			sb.WriteByte('?')
+		} else {
+			sb.WriteByte('&')
+		}
+		vs := q[k]
+		sort.Strings(vs)
+		for j, v := range vs {
+			if j > 0 {
+				sb.WriteByte('&')
+			}
+			sb.WriteString(k + "=" + v)
+		}
+	}
+	return sb.String()
+}
+
+// KeyByPath 只使用请求路径作为缓存键。
+func KeyByPath(r *http.Request) string {
+	return r.URL.Path
+}
+
+// RouteConfig 路由分组的缓存配置。
+type RouteConfig struct {
+	TTL    time.Duration `value:"${ttl:=1m}"`    // 响应在服务端的缓存时间
+	MaxAge time.Duration `value:"${max-age:=0}"` // 大于 0 时设置 Cache-Control: max-age 允许客户端和代理缓存
+	Key    string        `value:"${key:=url}"`   // 缓存键的计算方式，url 、path 或者 KeyFuncs 中注册的名称
+	Vary   []string      `value:"${vary:=}"`     // 参与缓存键计算的请求头，例如 Accept-Language
+}
+
+// Config 响应缓存配置，Routes 的键为请求路径或者注册的路由路径，例如
+// web.cache.routes./api/*.ttl=5m ，键 default 表示其他 GET 请求的缓存配置。
+type Config struct {
+	Prefix   string                 `value:"${web.cache.prefix:=http-cache:}"` // 存储中缓存键的前缀
+	MaxBody  conf.ByteSize          `value:"${web.cache.max-body:=1MB}"`       // 超过该长度的响应不会被缓存
+	Routes   map[string]RouteConfig `value:"${web.cache.routes:=}"`
+	KeyFuncs map[string]KeyFunc     // 自定义的缓存键计算方式
+}
+
+func NewConfig() Config {
+	return Config{Prefix: "http-cache:", MaxBody: conf.MegaByte}
+}
+
+// route 路由分组的缓存配置以及缓存键的计算方式。
+type route struct {
+	RouteConfig
+	key KeyFunc
+}
+
+func (r *route) cacheKey(prefix string, req *http.Request) string {
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	sb.WriteString(r.key(req))
+	for _, h := range r.Vary {
+		sb.WriteString("|" + h + "=" + req.Header.Get(h))
+	}
+	return sb.String()
+}
+
+// NewFilter 创建缓存 GET 请求响应的过滤器，store 为 nil 时使用基于内存的 Store 。
+// 只有状态码为 200 、没有设置 Cookie 并且没有禁止缓存的响应才会被缓存，路由的匹配
+// 规则和 web.NewTimeoutFilter 相同。被缓存的路由的响应在处理完成后才会发送。
+func NewFilter(config Config, store Store) (web.Filter, error) {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	routes := make(map[string]*route)
+	for k, c := range config.Routes {
+		var fn KeyFunc
+		switch c.Key {
+		case KeyURL, "":
+			fn = KeyByURL
+		case KeyPath:
+			fn = KeyByPath
+		default:
+			if fn = config.KeyFuncs[c.Key]; fn == nil {
+				return nil, fmt.Errorf("web.cache.routes.%s: unknown key %q", k, c.Key)
+			}
+		}
+		routes[k] = &route{RouteConfig: c, key: fn}
+	}
+	return web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+
+		r := ctx.Request()
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			chain.Continue(ctx)
+			return
+		}
+
+		rt := matchRoute(ctx, routes)
+		if rt == nil || hasDirective(r.Header.Get(web.HeaderCacheControl), "no-store") {
+			chain.Continue(ctx)
+			return
+		}
+
+		c := r.Context()
+		key := rt.cacheKey(config.Prefix, r)
+
+		// 请求携带 no-cache 时跳过缓存，使用新的响应更新缓存。
+		if !hasDirective(r.Header.Get(web.HeaderCacheControl), "no-cache") {
+			e, err := store.Get(c, key)
+			if err != nil {
+				panic(err)
+			}
+			if e != nil {
+				ctx.SetHeader(HeaderCache, "HIT")
+				ctx.SetHeader(web.HeaderAge, strconv.Itoa(int(time.Since(e.Created)/time.Second)))
+				serve(ctx, e)
+				return
+			}
+		}
+
+		w := ctx.ResponseWriter()
+		cw := &cacheWriter{ResponseWriter: w, maxBody: int(config.MaxBody)}
+		ctx.SetResponseWriter(cw)
+		defer ctx.SetResponseWriter(w)
+
+		w.Header().Set(HeaderCache, "MISS")
+		chain.Next(ctx)
+		ctx.SetResponseWriter(w)
+
+		if cw.bypass {
+			return
+		}
+
+		e := cw.entry()
+		if r.Method != http.MethodGet || !cacheable(e) {
+			if err := cw.flush(); err != nil {
+				panic(err)
+			}
+			return
+		}
+
+		if e.Header.Get(web.HeaderETag) == "" {
+			sum := sha256.Sum256(e.Body)
+			e.Header.Set(web.HeaderETag, `"`+hex.EncodeToString(sum[:16])+`"`)
+		}
+		if e.Header.Get(web.HeaderLastModified) == "" {
+			e.Header.Set(web.HeaderLastModified, e.Created.UTC().Format(http.TimeFormat))
+		}
+		if rt.MaxAge > 0 && e.Header.Get(web.HeaderCacheControl) == "" {
+			e.Header.Set(web.HeaderCacheControl, "max-age="+strconv.Itoa(int(rt.MaxAge/time.Second)))
+		}
+		if err := store.Save(c, key, e, rt.TTL); err != nil {
+			panic(err)
+		}
+		serve(ctx, e)
+	}), nil
+}
+
+// cacheable 只缓存状态码为 200 、没有设置 Cookie 并且没有禁止缓存的响应。
+func cacheable(e *Entry) bool {
+	if e.Status != http.StatusOK || e.Header.Get(web.HeaderSetCookie) != "" {
+		return false
+	}
+	cc := e.Header.Get(web.HeaderCacheControl)
+	return !hasDirective(cc, "no-store") && !hasDirective(cc, "private")
+}
+
+// serve 使用缓存的响应回复请求，内容没有变化时返回 304 。
+func serve(ctx web.Context, e *Entry) {
+	h := ctx.ResponseWriter().Header()
+	for k, v := range e.Header {
+		h[k] = append([]string(nil), v...)
+	}
+	if notModified(ctx.Request(), e) {
+		h.Del(web.HeaderContentType)
+		h.Del(web.HeaderContentLength)
+		ctx.SetStatus(http.StatusNotModified)
+		return
+	}
+	ctx.SetStatus(e.Status)
+	if ctx.Request().Method != http.MethodHead {
+		_, _ = ctx.ResponseWriter().Write(e.Body)
+	}
+}
+
+// notModified 判断条件请求的内容是否没有变化，If-None-Match 优先于 If-Modified-Since 。
+func notModified(r *http.Request, e *Entry) bool {
+	if inm := r.Header.Get(web.HeaderIfNoneMatch); inm != "" {
+		return etagMatch(inm, e.Header.Get(web.HeaderETag))
+	}
+	ims := r.Header.Get(web.HeaderIfModifiedSince)
+	if ims == "" {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	lm, err := http.ParseTime(e.Header.Get(web.HeaderLastModified))
+	if err != nil {
+		return false
+	}
+	return !lm.After(t)
+}
+
+// etagMatch 使用弱比较判断 If-None-Match 中是否包含 etag 。
+func etagMatch(inm string, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, s := range strings.Split(inm, ",") {
+		s = strings.TrimSpace(s)
+		if s == "*" || strings.TrimPrefix(s, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDirective 判断 Cache-Control 中是否包含指定的指令。
+func hasDirective(cacheControl string, directive string) bool {
+	for _, s := range strings.Split(cacheControl, ",") {
+		if i := strings.IndexByte(s, '='); i >= 0 {
+			s = s[:i]
+		}
+		if strings.EqualFold(strings.TrimSpace(s), directive) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRoute 返回请求所属分组的缓存配置，匹配规则和 web.NewTimeoutFilter 相同。
+func matchRoute(ctx web.Context, routes map[string]*route) *route {
+	path := ctx.Request().URL.Path
+	if r, ok := routes[path]; ok {
+		return r
+	}
+	if r, ok := routes[ctx.Path()]; ok {
+		return r
+	}
+	var (
+		prefix string
+		r      *route
+	)
+	for k, v := range routes {
+		if strings.HasSuffix(k, "/*") {
+			p := strings.TrimSuffix(k, "*")
+			if strings.HasPrefix(path, p) && len(p) > len(prefix) {
+				prefix, r = p, v
+			}
+		}
+	}
+	if prefix != "" {
+		return r
+	}
+	return routes["default"]
+}
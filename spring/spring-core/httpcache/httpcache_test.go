@@ -0,0 +1,332 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package httpcache_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/httpcache"
+	"github.com/go-spring/spring-core/redis"
+	"github.com/go-spring/spring-core/web"
+)
+
+func newFilter(t *testing.T, routes map[string]httpcache.RouteConfig) web.Filter {
+	config := httpcache.NewConfig()
+	config.Routes = routes
+	f, err := httpcache.NewFilter(config, nil)
+	assert.Nil(t, err)
+	return f
+}
+
+func serve(f web.Filter, handler web.Filter, method, url string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, url, nil)
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	ctx := web.NewBaseContext("", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+	web.NewFilterChain([]web.Filter{f, handler}).Next(ctx)
+	return w
+}
+
+func TestFilter(t *testing.T) {
+
+	count := 0
+	handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		count++
+		ctx.String("hello %s", ctx.QueryParam("name"))
+	})
+
+	f := newFilter(t, map[string]httpcache.RouteConfig{
+		"/api/*": {TTL: time.Minute, MaxAge: 30 * time.Second, Key: httpcache.KeyURL},
+	})
+
+	w := serve(f, handler, http.MethodGet, "/api/hello?name=a&x=1", nil)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Body.String(), "hello a")
+	assert.Equal(t, w.Header().Get(httpcache.HeaderCache), "MISS")
+	assert.Equal(t, w.Header().Get(web.HeaderCacheControl), "max-age=30")
+	etag := w.Header().Get(web.HeaderETag)
+	assert.NotEqual(t, etag, "")
+	lastModified := w.Header().Get(web.HeaderLastModified)
+
+	// 查询参数的顺序不影响缓存键。
+	w = serve(f, handler, http.MethodGet, "/api/hello?x=1&name=a", nil)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Body.String(), "hello a")
+	assert.Equal(t, w.Header().Get(httpcache.HeaderCache), "HIT")
+	assert.Equal(t, w.Header().Get(web.HeaderETag), etag)
+	assert.Equal(t, count, 1)
+
+	w = serve(f, handler, http.MethodGet, "/api/hello?name=b", nil)
+	assert.Equal(t, w.Body.String(), "hello b")
+	assert.Equal(t, count, 2)
+
+	w = serve(f, handler, http.MethodGet, "/api/hello?name=a&x=1", map[string]string{web.HeaderIfNoneMatch: `"other", ` + etag})
+	assert.Equal(t, w.Code, http.StatusNotModified)
+	assert.Equal(t, w.Body.String(), "")
+
+	w = serve(f, handler, http.MethodGet, "/api/hello?name=a&x=1", map[string]string{web.HeaderIfModifiedSince: lastModified})
+	assert.Equal(t, w.Code, http.StatusNotModified)
+
+	w = serve(f, handler, http.MethodHead, "/api/hello?name=a&x=1", nil)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Body.String(), "")
+	assert.Equal(t, count, 2)
+
+	// no-cache 跳过缓存并更新缓存。
+	w = serve(f, handler, http.MethodGet, "/api/hello?name=a&x=1", map[string]string{web.HeaderCacheControl: "no-cache"})
+	assert.Equal(t, w.Header().Get(httpcache.HeaderCache), "MISS")
+	assert.Equal(t, count, 3)
+
+	w = serve(f, handler, http.MethodGet, "/other", nil)
+	assert.Equal(t, w.Header().Get(httpcache.HeaderCache), "")
+	w = serve(f, handler, http.MethodPost, "/api/hello", nil)
+	assert.Equal(t, w.Header().Get(httpcache.HeaderCache), "")
+	assert.Equal(t, count, 5)
+}
+
+func TestFilterKey(t *testing.T) {
+
+	count := 0
+	handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		count++
+		ctx.String("%s %s", ctx.Header("Accept-Language"), ctx.Header("X-Tenant"))
+	})
+
+	config := httpcache.NewConfig()
+	config.Routes = map[string]httpcache.RouteConfig{
+		"/path":   {TTL: time.Minute, Key: httpcache.KeyPath, Vary: []string{"Accept-Language"}},
+		"/tenant": {TTL: time.Minute, Key: "tenant"},
+	}
+	_, err := httpcache.NewFilter(config, nil)
+	assert.Error(t, err, "web.cache.routes./tenant: unknown key \"tenant\"")
+
+	config.KeyFuncs = map[string]httpcache.KeyFunc{
+		"tenant": func(r *http.Request) string { return r.Header.Get("X-Tenant") },
+	}
+	f, err := httpcache.NewFilter(config, nil)
+	assert.Nil(t, err)
+
+	w := serve(f, handler, http.MethodGet, "/path?a=1", map[string]string{"Accept-Language": "zh"})
+	assert.Equal(t, w.Body.String(), "zh ")
+	w = serve(f, handler, http.MethodGet, "/path?a=2", map[string]string{"Accept-Language": "zh"})
+	assert.Equal(t, w.Header().Get(httpcache.HeaderCache), "HIT")
+	w = serve(f, handler, http.MethodGet, "/path", map[string]string{"Accept-Language": "en"})
+	assert.Equal(t, w.Body.String(), "en ")
+	assert.Equal(t, count, 2)
+
+	serve(f, handler, http.MethodGet, "/tenant", map[string]string{"X-Tenant": "t1"})
+	w = serve(f, handler, http.MethodGet, "/tenant", map[string]string{"X-Tenant": "t1"})
+	assert.Equal(t, w.Header().Get(httpcache.HeaderCache), "HIT")
+	w = serve(f, handler, http.MethodGet, "/tenant", map[string]string{"X-Tenant": "t2"})
+	assert.Equal(t, w.Body.String(), " t2")
+	assert.Equal(t, count, 4)
+}
+
+func TestFilterNotCacheable(t *testing.T) {
+
+	count := 0
+	handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		count++
+		switch ctx.Request().URL.Path {
+		case "/fail":
+			ctx.SetStatus(http.StatusInternalServerError)
+		case "/cookie":
+			ctx.SetCookie(&http.Cookie{Name: "a", Value: "b"})
+		case "/private":
+			ctx.SetHeader(web.HeaderCacheControl, "private")
+		case "/large":
+			ctx.String("0123456789")
+			return
+		}
+		ctx.String("ok")
+	})
+
+	config := httpcache.NewConfig()
+	config.MaxBody = 4
+	config.Routes = map[string]httpcache.RouteConfig{"default": {TTL: time.Minute}}
+	f, err := httpcache.NewFilter(config, nil)
+	assert.Nil(t, err)
+
+	for i, path := range []string{"/fail", "/cookie", "/private", "/large"} {
+		w := serve(f, handler, http.MethodGet, path, nil)
+		expect := "ok"
+		if path == "/large" {
+			expect = "0123456789"
+		}
+		assert.Equal(t, w.Body.String(), expect)
+		serve(f, handler, http.MethodGet, path, nil)
+		assert.Equal(t, count, 2*(i+1), path)
+	}
+
+	w := serve(f, handler, http.MethodGet, "/fail", nil)
+	assert.Equal(t, w.Code, http.StatusInternalServerError)
+
+	serve(f, handler, http.MethodGet, "/ok", map[string]string{web.HeaderCacheControl: "no-store"})
+	serve(f, handler, http.MethodGet, "/ok", nil)
+	w = serve(f, handler, http.MethodGet, "/ok", nil)
+	assert.Equal(t, w.Header().Get(httpcache.HeaderCache), "HIT")
+	assert.Equal(t, count, 11)
+}
+
+// connPool 只支持 SET、GET 和 DEL 命令的 redis.ConnPool 实现。
+type connPool struct {
+	data map[string]string
+}
+
+func (p *connPool) Exec(ctx context.Context, cmd string, args []interface{}) (interface{}, error) {
+	key := args[0].(string)
+	switch cmd {
+	case "SET":
+		p.data[key] = args[1].(string)
+		return "OK", nil
+	case "GET":
+		if v, ok := p.data[key]; ok {
+			return v, nil
+		}
+		return nil, redis.ErrNil()
+	case "DEL":
+		delete(p.data, key)
+		return int64(1), nil
+	}
+	return nil, nil
+}
+
+func TestRedisStore(t *testing.T) {
+	c, err := redis.NewClient(&connPool{data: map[string]string{}})
+	assert.Nil(t, err)
+	s := httpcache.NewRedisStore(c)
+	ctx := context.Background()
+
+	e, err := s.Get(ctx, "k")
+	assert.Nil(t, err)
+	assert.Nil(t, e)
+
+	created := time.Date(2021, 12, 1, 10, 0, 0, 0, time.UTC)
+	expect := &httpcache.Entry{
+		Status:  http.StatusOK,
+		Header:  http.Header{web.HeaderContentType: {web.MIMETextPlain}},
+		Body:    []byte("ok"),
+		Created: created,
+	}
+	assert.Nil(t, s.Save(ctx, "k", expect, time.Minute))
+
+	e, err = s.Get(ctx, "k")
+	assert.Nil(t, err)
+	assert.Equal(t, e, expect)
+
+	assert.Nil(t, s.Delete(ctx, "k"))
+	e, err = s.Get(ctx, "k")
+	assert.Nil(t, err)
+	assert.Nil(t, e)
+}
+
+func TestSingleFlightFilter(t *testing.T) {
+
+	var (
+		count   int32
+		entered = make(chan struct{}, 10)
+		release = make(chan struct{})
+	)
+
+	handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		atomic.AddInt32(&count, 1)
+		entered <- struct{}{}
+		<-release
+		if ctx.QueryParam("cookie") != "" {
+			ctx.SetCookie(&http.Cookie{Name: "session", Value: "1"})
+		}
+		ctx.String("hello %s", ctx.QueryParam("name"))
+	})
+
+	f := httpcache.NewSingleFlightFilter(httpcache.SingleFlightConfig{
+		Paths:   []string{"/api"},
+		Headers: []string{"Authorization"},
+		MaxBody: conf.MegaByte,
+	})
+
+	run := func(n int, url string, header map[string]string) []*httptest.ResponseRecorder {
+		ret := make([]*httptest.ResponseRecorder, n)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ret[0] = serve(f, handler, http.MethodGet, url, header)
+		}()
+		<-entered
+		for i := 1; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				ret[i] = serve(f, handler, http.MethodGet, url, header)
+			}(i)
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		release = make(chan struct{})
+		return ret
+	}
+
+	// 查询参数的顺序不影响合并键。
+	ws := run(5, "/api/hello?name=a&x=1", nil)
+	assert.Equal(t, atomic.LoadInt32(&count), int32(1))
+	assert.Equal(t, ws[0].Header().Get(httpcache.HeaderSingleFlight), "")
+	for _, w := range ws {
+		assert.Equal(t, w.Code, http.StatusOK)
+		assert.Equal(t, w.Body.String(), "hello a")
+	}
+	for _, w := range ws[1:] {
+		assert.Equal(t, w.Header().Get(httpcache.HeaderSingleFlight), "SHARED")
+	}
+
+	// 设置了 Cookie 的响应不会被共享。
+	atomic.StoreInt32(&count, 0)
+	ws = run(3, "/api/hello?name=b&cookie=1", nil)
+	assert.Equal(t, atomic.LoadInt32(&count), int32(3))
+	<-entered
+	<-entered
+	for _, w := range ws {
+		assert.Equal(t, w.Body.String(), "hello b")
+		assert.Equal(t, w.Header().Get(httpcache.HeaderSingleFlight), "")
+	}
+
+	// 不同的 Authorization 请求头不会合并。
+	atomic.StoreInt32(&count, 0)
+	var wg sync.WaitGroup
+	for _, token := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(token string) {
+			defer wg.Done()
+			serve(f, handler, http.MethodGet, "/api/hello?name=c", map[string]string{"Authorization": token})
+		}(token)
+	}
+	<-entered
+	<-entered
+	close(release)
+	wg.Wait()
+	assert.Equal(t, atomic.LoadInt32(&count), int32(2))
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package httpcache
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/web"
+)
+
+// HeaderSingleFlight 等待其他请求完成并共享其响应的请求带有该响应头，取值为 SHARED 。
+const HeaderSingleFlight = "X-Single-Flight"
+
+// SingleFlightConfig 合并并发请求的配置。
+type SingleFlightConfig struct {
+	Paths   []string      `value:"${paths:=}"`                       // 只合并这些前缀的路径，为空时合并所有 GET 请求
+	Headers []string      `value:"${headers:=Authorization,Cookie}"` // 参与合并键计算的请求头，避免不同用户共享响应
+	MaxBody conf.ByteSize `value:"${max-body:=1MB}"`                 // 响应超过该大小时不共享，等待的请求各自处理
+}
+
+// flight 正在处理的请求，entry 为 nil 表示响应无法共享。
+type flight struct {
+	done  chan struct{}
+	entry *Entry
+}
+
+type flightGroup struct {
+	config  SingleFlightConfig
+	mutex   sync.Mutex
+	flights map[string]*flight
+}
+
+func (g *flightGroup) match(path string) bool {
+	if len(g.config.Paths) == 0 {
+		return true
+	}
+	for _, p := range g.config.Paths {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *flightGroup) key(r *http.Request) string {
+	var sb strings.Builder
+	sb.WriteString(KeyByURL(r))
+	for _, h := range g.config.Headers {
+		sb.WriteString("|" + h + "=" + strings.Join(r.Header.Values(h), ","))
+	}
+	return sb.String()
+}
+
+// NewSingleFlightFilter 创建合并并发的相同 GET 请求的过滤器，同一时刻只有一个请求执行
+// 处理器，其他请求等待并共享它的响应，用于避免热点接口在缓存失效等时刻被大量请求击穿。
+// 请求的路径、排序后的查询参数以及配置的请求头相同时视为相同的请求。设置了 Cookie 、
+// 超过最大长度或者调用了 Flush 的响应不会被共享，此时等待的请求各自执行处理器。
+func NewSingleFlightFilter(config SingleFlightConfig) web.Filter {
+	g := &flightGroup{config: config, flights: make(map[string]*flight)}
+	return web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+
+		r := ctx.Request()
+		if r.Method != http.MethodGet || !g.match(r.URL.Path) {
+			chain.Continue(ctx)
+			return
+		}
+
+		key := g.key(r)
+		g.mutex.Lock()
+		if f, ok := g.flights[key]; ok {
+			g.mutex.Unlock()
+			select {
+			case <-f.done:
+			case <-r.Context().Done():
+				return
+			}
+			if f.entry == nil {
+				chain.Continue(ctx)
+				return
+			}
+			ctx.SetHeader(HeaderSingleFlight, "SHARED")
+			serve(ctx, f.entry)
+			return
+		}
+		f := &flight{done: make(chan struct{})}
+		g.flights[key] = f
+		g.mutex.Unlock()
+
+		// 处理器 panic 时等待的请求各自执行处理器。
+		defer func() {
+			g.mutex.Lock()
+			delete(g.flights, key)
+			g.mutex.Unlock()
+			close(f.done)
+		}()
+
+		w := ctx.ResponseWriter()
+		cw := &cacheWriter{ResponseWriter: w, maxBody: int(config.MaxBody)}
+		ctx.SetResponseWriter(cw)
+		defer ctx.SetResponseWriter(w)
+
+		chain.Next(ctx)
+		ctx.SetResponseWriter(w)
+
+		if cw.bypass {
+			return
+		}
+
+		e := cw.entry()
+		e.Body = append([]byte(nil), e.Body...)
+		if err := cw.flush(); err != nil {
+			panic(err)
+		}
+		if e.Header.Get(web.HeaderSetCookie) == "" {
+			f.entry = e
+		}
+	})
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-core/internal/redisutil"
+	"github.com/go-spring/spring-core/redis"
+)
+
+// Entry 缓存的响应。
+type Entry struct {
+	Status  int         `json:"status"`
+	Header  http.Header `json:"header"`
+	Body    []byte      `json:"body"`
+	Created time.Time   `json:"created"`
+}
+
+// Store 保存缓存的响应的存储。
+type Store interface {
+	// Get 返回缓存键对应的响应，没有缓存或者已经过期时返回 nil 。
+	Get(ctx context.Context, key string) (*Entry, error)
+	// Save 保存缓存键对应的响应。
+	Save(ctx context.Context, key string, e *Entry, ttl time.Duration) error
+	// Delete 删除缓存键，用于数据变化时使缓存失效。
+	Delete(ctx context.Context, key string) error
+}
+
+// memoryStore 基于内存的 Store 实现，适用于单机部署和测试。
+type memoryStore struct {
+	mutex sync.Mutex
+	items map[string]memoryItem
+}
+
+type memoryItem struct {
+	entry  *Entry
+	expire time.Time
+}
+
+// NewMemoryStore 创建基于内存的 Store 。
+func NewMemoryStore() Store {
+	return &memoryStore{items: make(map[string]memoryItem)}
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string) (*Entry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	item, ok := s.items[key]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(item.expire) {
+		delete(s.items, key)
+		return nil, nil
+	}
+	return item.entry, nil
+}
+
+func (s *memoryStore) Save(ctx context.Context, key string, e *Entry, ttl time.Duration) error {
+	if e == nil {
+		return errors.New("entry can't be nil")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	for k, item := range s.items {
+		if now.After(item.expire) {
+			delete(s.items, k)
+		}
+	}
+	s.items[key] = memoryItem{entry: e, expire: now.Add(ttl)}
+	return nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.items, key)
+	return nil
+}
+
+// redisStore 基于 redis 的 Store 实现，适用于多实例部署。
+type redisStore struct {
+	store *redisutil.TTLStore
+}
+
+// NewRedisStore 创建基于 redis 的 Store 。
+func NewRedisStore(client *redis.Client) Store {
+	return &redisStore{store: redisutil.NewTTLStore(client)}
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) (*Entry, error) {
+	e := new(Entry)
+	if ok, err := s.store.Get(ctx, key, e); !ok || err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (s *redisStore) Save(ctx context.Context, key string, e *Entry, ttl time.Duration) error {
+	return s.store.Set(ctx, key, e, ttl)
+}
+
+func (s *redisStore) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
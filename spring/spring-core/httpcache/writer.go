@@ -0,0 +1,113 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package httpcache
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/go-spring/spring-core/web"
+)
+
+// cacheWriter 缓存路由的响应，以便在处理完成后决定是否保存响应以及是否返回 304 。
+// 响应超过最大长度或者路由主动刷新数据时不再缓存，直接发送给客户端。
+type cacheWriter struct {
+	web.ResponseWriter
+	maxBody int
+	status  int
+	size    int
+	bypass  bool
+	buf     bytes.Buffer
+}
+
+func (w *cacheWriter) Status() int {
+	if w.status != 0 {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *cacheWriter) Size() int {
+	return w.size
+}
+
+func (w *cacheWriter) WriteHeader(code int) {
+	if w.bypass {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	w.size += len(b)
+	if w.bypass {
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf.Write(b)
+	if w.buf.Len() > w.maxBody {
+		if err := w.flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush 立即发送已经写入的数据，用于服务器推送等场景，此时响应不会被缓存。
+func (w *cacheWriter) Flush() {
+	_ = w.flush()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// flush 放弃缓存，发送状态码和已经写入的数据。
+func (w *cacheWriter) flush() error {
+	if w.bypass {
+		return nil
+	}
+	w.bypass = true
+	w.ResponseWriter.Header().Del(HeaderCache)
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// entry 返回缓存的响应，不包含和本次请求相关的响应头。
+func (w *cacheWriter) entry() *Entry {
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	h := w.Header().Clone()
+	h.Del(HeaderCache)
+	h.Del(web.HeaderAge)
+	h.Del("Date")
+	return &Entry{
+		Status:  status,
+		Header:  h,
+		Body:    w.buf.Bytes(),
+		Created: time.Now(),
+	}
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package httpclient 根据配置创建命名的 http 客户端，每个客户端单独配置连接池、指标
+// 和追踪，例如：
+//
+//	httpclient.clients[0].name=user-service
+//	httpclient.clients[0].timeout=3s
+//	httpclient.clients[0].max-conns-per-host=50
+//
+// 客户端统计连接池的状态（空闲、使用中的连接数量以及等待连接的时间），配置了指标注册
+// 表时输出按照主机统计的请求耗时直方图；注册了 Tracer 时为每个请求创建 span ，并且
+// 将请求 ID 传递给下游服务，例如：
+//
+//	gs.Provide(httpclient.New, "${httpclient}", "?", "?")
+package httpclient
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-spring/spring-core/metrics"
+)
+
+// 客户端输出的指标的名称。
+const (
+	RequestsMetric    = "http_client_requests_seconds"
+	ConnWaitMetric    = "http_client_conn_wait_seconds"
+	ConnectionsMetric = "http_client_connections"
+)
+
+// ClientConfig 命名的客户端的配置。
+type ClientConfig struct {
+	Name                string        `value:"${name}"`
+	Timeout             time.Duration `value:"${timeout:=30s}"` // 整个请求的超时时间，包括读取响应体
+	DialTimeout         time.Duration `value:"${dial-timeout:=5s}"`
+	MaxIdleConns        int           `value:"${max-idle-conns:=100}"`
+	MaxIdleConnsPerHost int           `value:"${max-idle-conns-per-host:=10}"`
+	MaxConnsPerHost     int           `value:"${max-conns-per-host:=0}"` // 每个主机最多的连接数量，0 表示不限制
+	IdleConnTimeout     time.Duration `value:"${idle-conn-timeout:=90s}"`
+	Metrics             bool          `value:"${metrics:=true}"` // 是否输出指标，需要注册指标注册表
+	Tracing             bool          `value:"${tracing:=true}"` // 是否创建 span 并传递请求 ID
+}
+
+// Config 客户端的配置，通常配合 httpclient 前缀一起使用。
+type Config struct {
+	Clients []ClientConfig `value:"${clients:=}"`
+}
+
+// PoolStats 客户端连接池的状态。
+type PoolStats struct {
+	Name         string        `json:"name"`
+	Open         int           `json:"open"`          // 打开的连接数量
+	Idle         int           `json:"idle"`          // 空闲的连接数量
+	InUse        int           `json:"in_use"`        // 正在处理请求的连接数量
+	Waiting      int           `json:"waiting"`       // 正在等待连接的请求数量
+	WaitCount    int64         `json:"wait_count"`    // 获取过连接的请求数量
+	WaitDuration time.Duration `json:"wait_duration"` // 获取连接的总耗时
+}
+
+// Tracer 为客户端请求创建 span ，实现需要把追踪信息写入请求头传递给下游服务。返回的
+// 函数在请求结束时调用，err 为请求的错误。
+type Tracer interface {
+	Start(client string, req *http.Request) (finish func(resp *http.Response, err error))
+}
+
+// Clients 命名的 http 客户端。
+type Clients struct {
+	clients map[string]*http.Client
+	pools   map[string]*pool
+}
+
+// New 创建所有配置的客户端，registry 和 tracer 可以为 nil 。
+func New(config Config, registry *metrics.Registry, tracer Tracer) (*Clients, error) {
+	c := &Clients{
+		clients: make(map[string]*http.Client),
+		pools:   make(map[string]*pool),
+	}
+
+	var m *meters
+	if registry != nil {
+		m = newMeters(registry)
+	}
+
+	for _, cc := range config.Clients {
+		if cc.Name == "" {
+			return nil, fmt.Errorf("httpclient: client name is empty")
+		}
+		if _, ok := c.clients[cc.Name]; ok {
+			return nil, fmt.Errorf("httpclient: duplicate client %q", cc.Name)
+		}
+		p := &pool{name: cc.Name}
+		dialer := &net.Dialer{Timeout: cc.DialTimeout, KeepAlive: 30 * time.Second}
+		base := &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           p.dialContext(dialer.DialContext),
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          cc.MaxIdleConns,
+			MaxIdleConnsPerHost:   cc.MaxIdleConnsPerHost,
+			MaxConnsPerHost:       cc.MaxConnsPerHost,
+			IdleConnTimeout:       cc.IdleConnTimeout,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		}
+		t := &transport{base: base, pool: p}
+		if cc.Metrics && m != nil {
+			t.meters, p.meters = m, m
+		}
+		if cc.Tracing {
+			t.tracer, t.propagate = tracer, true
+		}
+		c.pools[cc.Name] = p
+		c.clients[cc.Name] = &http.Client{Transport: t, Timeout: cc.Timeout}
+	}
+	return c, nil
+}
+
+// Get 返回命名的客户端，没有配置时返回 nil 。
+func (c *Clients) Get(name string) *http.Client {
+	return c.clients[name]
+}
+
+// Stats 返回所有客户端连接池的状态，按照名称排序。
+func (c *Clients) Stats() []PoolStats {
+	ret := make([]PoolStats, 0, len(c.pools))
+	for _, p := range c.pools {
+		ret = append(ret, p.stats())
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+// Close 关闭所有客户端的空闲连接。
+func (c *Clients) Close() {
+	for _, client := range c.clients {
+		client.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package httpclient_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-base/knife"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/httpclient"
+	"github.com/go-spring/spring-core/metrics"
+	"github.com/go-spring/spring-core/web"
+)
+
+type tracer struct {
+	mutex sync.Mutex
+	spans []string
+}
+
+func (t *tracer) Start(client string, req *http.Request) func(*http.Response, error) {
+	req.Header.Set("Traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	return func(resp *http.Response, err error) {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		t.spans = append(t.spans, client+" "+req.Method+" "+resp.Status)
+	}
+}
+
+func TestClients(t *testing.T) {
+
+	release := make(chan struct{})
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+		_, _ = w.Write([]byte(r.Header.Get(web.HeaderXRequestID) + "|" + r.Header.Get("Traceparent")))
+	}))
+	defer svr.Close()
+
+	p, err := conf.Bytes([]byte(`
+		httpclient.clients[0].name=user
+		httpclient.clients[0].max-conns-per-host=1
+		httpclient.clients[1].name=plain
+		httpclient.clients[1].metrics=false
+		httpclient.clients[1].tracing=false
+	`), ".properties")
+	assert.Nil(t, err)
+	var config httpclient.Config
+	err = p.Bind(&config, conf.Key("httpclient"))
+	assert.Nil(t, err)
+
+	registry := metrics.New(metrics.Config{Enabled: true}, nil)
+	tr := &tracer{}
+	clients, err := httpclient.New(config, registry, tr)
+	assert.Nil(t, err)
+	defer clients.Close()
+	assert.Nil(t, clients.Get("none"))
+
+	// 请求 ID 和追踪信息被传递给下游服务。
+	ctx, _ := knife.New(context.Background())
+	r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	r.Header.Set(web.HeaderXRequestID, "req-1")
+	webCtx := web.NewBaseContext("/", nil, r, &web.BufferedResponseWriter{ResponseWriter: httptest.NewRecorder()})
+	get := func(client, path string) string {
+		req, _ := http.NewRequestWithContext(webCtx.Context(), http.MethodGet, svr.URL+path, nil)
+		resp, err := clients.Get(client).Do(req)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		assert.Nil(t, err)
+		return string(b)
+	}
+	handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		assert.Equal(t, get("user", "/"), "req-1|00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+		assert.Equal(t, get("plain", "/"), "|")
+	})
+	web.NewFilterChain([]web.Filter{web.NewRequestIDFilter(web.NewRequestIDConfig()), handler}).Next(webCtx)
+	assert.Equal(t, tr.spans, []string{"user GET 200 OK"})
+
+	// 每个主机只有一个连接，第二个请求需要等待第一个请求归还连接。
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get("user", "/slow")
+		}()
+	}
+	for {
+		s := clients.Stats()[1]
+		if s.InUse == 1 && s.Waiting == 1 {
+			assert.Equal(t, s.Open, 1)
+			assert.Equal(t, s.Idle, 0)
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	stats := clients.Stats()
+	assert.Equal(t, stats[0].Name, "plain")
+	s := stats[1]
+	assert.Equal(t, s.Name, "user")
+	assert.Equal(t, s.Open, 1)
+	assert.Equal(t, s.Idle, 1)
+	assert.Equal(t, s.InUse, 0)
+	assert.Equal(t, s.Waiting, 0)
+	assert.Equal(t, s.WaitCount, int64(3))
+
+	var buf bytes.Buffer
+	err = registry.Write(&buf)
+	assert.Nil(t, err)
+	out := buf.String()
+	host := strings.TrimPrefix(svr.URL, "http://")
+	assert.Matches(t, out, `http_client_requests_seconds_count\{client="user",host="`+host+`",method="GET",status="200"\} 3`)
+	assert.Matches(t, out, `http_client_conn_wait_seconds_count\{client="user"\} 3`)
+	assert.Matches(t, out, `http_client_connections\{client="user",state="idle"\} 1`)
+	assert.False(t, strings.Contains(out, `client="plain"`))
+
+	_, err = httpclient.New(httpclient.Config{Clients: []httpclient.ClientConfig{{Name: "a"}, {Name: "a"}}}, nil, nil)
+	assert.Error(t, err, "duplicate client \"a\"")
+}
@@ -0,0 +1,225 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-core/metrics"
+	"github.com/go-spring/spring-core/web"
+)
+
+type meters struct {
+	requests *metrics.Histogram
+	wait     *metrics.Histogram
+	conns    *metrics.Gauge
+}
+
+func newMeters(r *metrics.Registry) *meters {
+	return &meters{
+		requests: r.Histogram(RequestsMetric, "HTTP client request duration in seconds until response headers.", nil, "client", "host", "method", "status"),
+		wait:     r.Histogram(ConnWaitMetric, "Time spent waiting for a connection in seconds.", nil, "client"),
+		conns:    r.Gauge(ConnectionsMetric, "HTTP client connections by state.", "client", "state"),
+	}
+}
+
+// pool 统计一个客户端的连接池。net/http 没有提供连接池的状态，因此通过拨号函数统计
+// 打开的连接，通过 httptrace 统计等待和使用中的连接，空闲的连接为两者之差。HTTP/2
+// 的多个请求共享一个连接，此时 InUse 是正在使用连接的请求数量。
+type pool struct {
+	name         string
+	meters       *meters
+	mutex        sync.Mutex
+	open         int
+	inUse        int
+	waiting      int
+	waitCount    int64
+	waitDuration time.Duration
+}
+
+func (p *pool) update(fn func()) {
+	p.mutex.Lock()
+	fn()
+	s := p.statsLocked()
+	p.mutex.Unlock()
+	if p.meters != nil {
+		p.meters.conns.Set(float64(s.Idle), p.name, "idle")
+		p.meters.conns.Set(float64(s.InUse), p.name, "in_use")
+		p.meters.conns.Set(float64(s.Waiting), p.name, "waiting")
+	}
+}
+
+func (p *pool) stats() PoolStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.statsLocked()
+}
+
+func (p *pool) statsLocked() PoolStats {
+	idle := p.open - p.inUse
+	if idle < 0 {
+		idle = 0
+	}
+	return PoolStats{
+		Name:         p.name,
+		Open:         p.open,
+		Idle:         idle,
+		InUse:        p.inUse,
+		Waiting:      p.waiting,
+		WaitCount:    p.waitCount,
+		WaitDuration: p.waitDuration,
+	}
+}
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (p *pool) dialContext(dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		p.update(func() { p.open++ })
+		return &trackedConn{Conn: conn, pool: p}, nil
+	}
+}
+
+// trackedConn 关闭时从连接池的统计中移除。
+type trackedConn struct {
+	net.Conn
+	pool *pool
+	once sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() { c.pool.update(func() { c.pool.open-- }) })
+	return c.Conn.Close()
+}
+
+// connState 一次请求获取连接的状态。
+type connState struct {
+	mutex   sync.Mutex
+	start   time.Time
+	waiting bool
+	got     bool
+}
+
+// transport 统计连接池和请求耗时，并且创建 span 。
+type transport struct {
+	base      http.RoundTripper
+	pool      *pool
+	meters    *meters
+	tracer    Tracer
+	propagate bool
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	p := t.pool
+	s := &connState{}
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			if !s.waiting && !s.got {
+				s.start, s.waiting = time.Now(), true
+				p.update(func() { p.waiting++ })
+			}
+		},
+		GotConn: func(httptrace.GotConnInfo) {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			if !s.waiting || s.got {
+				return
+			}
+			wait := time.Since(s.start)
+			s.waiting, s.got = false, true
+			p.update(func() {
+				p.waiting--
+				p.inUse++
+				p.waitCount++
+				p.waitDuration += wait
+			})
+			if t.meters != nil {
+				t.meters.wait.Observe(wait.Seconds(), p.name)
+			}
+		},
+	}
+
+	// RoundTripper 不能修改调用方的请求，需要传递请求头时复制请求。
+	ctx := httptrace.WithClientTrace(req.Context(), trace)
+	req = req.Clone(ctx)
+	if t.propagate {
+		if id := web.RequestID(ctx); id != "" && req.Header.Get(web.HeaderXRequestID) == "" {
+			req.Header.Set(web.HeaderXRequestID, id)
+		}
+	}
+	var finish func(*http.Response, error)
+	if t.tracer != nil {
+		finish = t.tracer.Start(p.name, req)
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+
+	if finish != nil {
+		finish(resp, err)
+	}
+	if t.meters != nil {
+		status := "error"
+		if err == nil {
+			status = strconv.Itoa(resp.StatusCode)
+		}
+		t.meters.requests.Observe(time.Since(start).Seconds(), p.name, req.URL.Host, req.Method, status)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.waiting { // 等待连接时请求被取消
+		s.waiting = false
+		p.update(func() { p.waiting-- })
+	}
+	if !s.got {
+		return resp, err
+	}
+	if err != nil || resp.Body == nil {
+		p.update(func() { p.inUse-- })
+		return resp, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: func() { p.update(func() { p.inUse-- }) }}
+	return resp, nil
+}
+
+// releaseBody 关闭响应体时归还连接。
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
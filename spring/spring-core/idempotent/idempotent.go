@@ -0,0 +1,363 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package idempotent 提供了基于幂等键的请求去重功能。客户端在修改类请求中携带
+// X-Idempotency-Key 请求头，过滤器保存请求指纹和响应，重复的请求直接返回保存的
+// 响应而不会再次执行，相同的幂等键用于不同的请求时拒绝该请求。
+package idempotent
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-core/web"
+)
+
+// HeaderIdempotencyKey 携带幂等键的请求头。
+const HeaderIdempotencyKey = "X-Idempotency-Key"
+
+// HeaderReplayed 响应是否来自于保存的响应。
+const HeaderReplayed = "X-Idempotent-Replayed"
+
+// Response 保存的请求指纹和响应。
+type Response struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType"`
+	Body        string `json:"body"`
+}
+
+// Store 保存幂等键和响应的存储。
+type Store interface {
+
+	// Lock 占用幂等键，幂等键已经被占用时返回 false 。
+	Lock(ctx context.Context, key string, ttl time.Duration) (bool, error)
+
+	// Get 返回幂等键对应的响应，没有保存响应时返回 nil 。
+	Get(ctx context.Context, key string) (*Response, error)
+
+	// Save 保存幂等键对应的响应。
+	Save(ctx context.Context, key string, resp *Response, ttl time.Duration) error
+
+	// Delete 删除幂等键，之后相同幂等键的请求会被再次执行。
+	Delete(ctx context.Context, key string) error
+}
+
+// DefaultMaxBody 计算请求指纹时默认读取的请求体的最大字节数。
+const DefaultMaxBody = 1024 * 1024
+
+// FilterConfig 幂等过滤器的配置。
+type FilterConfig struct {
+	Store    Store
+	Header   string
+	Prefix   string        // 存储中幂等键的前缀
+	Methods  []string      // 需要保证幂等的请求方法
+	Paths    []string      // 只对这些前缀的路径生效，为空时不限制，可以为不同的路由组创建不同的过滤器
+	TTL      time.Duration // 响应的保存时间
+	LockTTL  time.Duration // 请求处理中占用幂等键的时间
+	Required bool          // 修改类请求没有携带幂等键时是否拒绝请求
+	MaxBody  int64         // 请求体的最大字节数，超过时返回 413 ，为 0 时使用 DefaultMaxBody
+
+	// Principal 返回请求的调用方，幂等键只在同一个调用方的范围内有效，避免其他调用方
+	// 使用猜到的幂等键获取别人的响应。为 nil 时使用 Basic Auth 的用户名，没有时使用
+	// Authorization 和 Cookie 请求头的摘要，都没有时所有匿名请求共享幂等键。
+	Principal func(ctx web.Context) string
+}
+
+// NewFilterConfig 返回使用 store 保存响应的默认配置。
+func NewFilterConfig(store Store) FilterConfig {
+	return FilterConfig{
+		Store:   store,
+		Header:  HeaderIdempotencyKey,
+		Prefix:  "idempotent:",
+		Methods: []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		TTL:     24 * time.Hour,
+		LockTTL: time.Minute,
+		MaxBody: DefaultMaxBody,
+	}
+}
+
+// defaultPrincipal 返回 Basic Auth 的用户名，没有时返回 Authorization 和 Cookie
+// 请求头的摘要。
+func defaultPrincipal(ctx web.Context) string {
+	if user, ok := ctx.Get(web.AuthUserKey).(string); ok && user != "" {
+		return user
+	}
+	auth, cookie := ctx.Header(web.HeaderAuthorization), ctx.Header(web.HeaderCookie)
+	if auth == "" && cookie == "" {
+		return ""
+	}
+	h := sha256.Sum256([]byte(auth + "\n" + cookie))
+	return hex.EncodeToString(h[:])
+}
+
+// storeKey 返回存储中的幂等键，非匿名调用方的幂等键带有调用方的摘要。
+func (config *FilterConfig) storeKey(ctx web.Context, idempotencyKey string) string {
+	principal := config.Principal(ctx)
+	if principal == "" {
+		return config.Prefix + idempotencyKey
+	}
+	h := sha256.Sum256([]byte(principal))
+	return config.Prefix + hex.EncodeToString(h[:16]) + ":" + idempotencyKey
+}
+
+func (config *FilterConfig) match(r *http.Request) bool {
+	found := false
+	for _, m := range config.Methods {
+		if m == r.Method {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+	if len(config.Paths) == 0 {
+		return true
+	}
+	for _, p := range config.Paths {
+		if strings.HasPrefix(r.URL.Path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// errBodyTooLarge 请求体超过了最大字节数。
+var errBodyTooLarge = errors.New("request body too large")
+
+// fingerprint 计算请求方法、路径和请求体的摘要，请求体会被重新放回请求中，请求体
+// 超过 max 字节时返回 errBodyTooLarge 。
+func fingerprint(r *http.Request, max int64) (string, error) {
+	var body []byte
+	if r.Body != nil {
+		b, err := ioutil.ReadAll(io.LimitReader(r.Body, max+1))
+		if err != nil {
+			return "", err
+		}
+		if int64(len(b)) > max {
+			return "", errBodyTooLarge
+		}
+		body = b
+		r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// canReplay 只有文本格式的响应才会被缓存，参见 web.BufferedResponseWriter 。
+func canReplay(contentType string) bool {
+	if i := strings.IndexAny(contentType, "; "); i >= 0 {
+		contentType = contentType[:i]
+	}
+	switch strings.ToLower(contentType) {
+	case web.MIMEApplicationJSON, web.MIMEApplicationXML, web.MIMETextPlain, web.MIMETextXML,
+		web.MIMEApplicationJavaScript, web.MIMETextHTML:
+		return true
+	}
+	return false
+}
+
+// NewFilter 创建保证修改类请求幂等的过滤器。处理成功的请求的响应被保存下来，服务器
+// 错误 (5xx) 或者无法缓存的响应不会被保存，客户端可以使用相同的幂等键重试。
+func NewFilter(config FilterConfig) web.Filter {
+	if config.Header == "" {
+		config.Header = HeaderIdempotencyKey
+	}
+	if config.MaxBody <= 0 {
+		config.MaxBody = DefaultMaxBody
+	}
+	if config.Principal == nil {
+		config.Principal = defaultPrincipal
+	}
+	return web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+
+		r := ctx.Request()
+		if !config.match(r) {
+			chain.Continue(ctx)
+			return
+		}
+
+		idempotencyKey := ctx.Header(config.Header)
+		if idempotencyKey == "" {
+			if config.Required {
+				ctx.SetStatus(http.StatusBadRequest)
+				ctx.String("missing header %s", config.Header)
+				return
+			}
+			chain.Continue(ctx)
+			return
+		}
+
+		fp, err := fingerprint(r, config.MaxBody)
+		if err == errBodyTooLarge {
+			ctx.SetStatus(http.StatusRequestEntityTooLarge)
+			ctx.String(err.Error())
+			return
+		}
+		if err != nil {
+			ctx.SetStatus(http.StatusBadRequest)
+			ctx.String(err.Error())
+			return
+		}
+
+		c := r.Context()
+		key := config.storeKey(ctx, idempotencyKey)
+		store := config.Store
+
+		resp, err := store.Get(c, key)
+		if err != nil {
+			panic(err)
+		}
+		if resp != nil {
+			replay(ctx, resp, fp)
+			return
+		}
+
+		ok, err := store.Lock(c, key, config.LockTTL)
+		if err != nil {
+			panic(err)
+		}
+		if !ok {
+			// 加锁失败也可能是因为前一个请求刚刚处理完成。
+			if resp, err = store.Get(c, key); err != nil {
+				panic(err)
+			}
+			if resp != nil {
+				replay(ctx, resp, fp)
+				return
+			}
+			ctx.SetStatus(http.StatusConflict)
+			ctx.String("request with the same idempotency key is in progress")
+			return
+		}
+
+		saved := false
+		defer func() {
+			if !saved {
+				_ = store.Delete(c, key)
+			}
+		}()
+
+		chain.Next(ctx)
+
+		w := ctx.ResponseWriter()
+		status := w.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		contentType := w.Header().Get(web.HeaderContentType)
+		if status >= http.StatusInternalServerError || (w.Size() > 0 && !canReplay(contentType)) {
+			return
+		}
+
+		resp = &Response{
+			Fingerprint: fp,
+			Status:      status,
+			ContentType: contentType,
+			Body:        w.Body(),
+		}
+		if err = store.Save(c, key, resp, config.TTL); err != nil {
+			panic(err)
+		}
+		saved = true
+	})
+}
+
+// replay 返回保存的响应，幂等键用于不同的请求时返回 422 。
+func replay(ctx web.Context, resp *Response, fp string) {
+	if resp.Fingerprint != fp {
+		ctx.SetStatus(http.StatusUnprocessableEntity)
+		ctx.String("idempotency key is reused with a different request")
+		return
+	}
+	ctx.SetHeader(HeaderReplayed, "true")
+	if resp.ContentType != "" {
+		ctx.SetContentType(resp.ContentType)
+	}
+	ctx.SetStatus(resp.Status)
+	_, _ = ctx.ResponseWriter().Write([]byte(resp.Body))
+}
+
+// memoryStore 基于内存的 Store 实现，适用于单机部署和测试。
+type memoryStore struct {
+	mutex sync.Mutex
+	items map[string]memoryItem
+}
+
+type memoryItem struct {
+	resp   *Response
+	expire time.Time
+}
+
+// NewMemoryStore 创建基于内存的 Store 。
+func NewMemoryStore() Store {
+	return &memoryStore{items: make(map[string]memoryItem)}
+}
+
+func (s *memoryStore) get(key string) (memoryItem, bool) {
+	item, ok := s.items[key]
+	if ok && time.Now().After(item.expire) {
+		delete(s.items, key)
+		return memoryItem{}, false
+	}
+	return item, ok
+}
+
+func (s *memoryStore) Lock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.get(key); ok {
+		return false, nil
+	}
+	s.items[key] = memoryItem{expire: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string) (*Response, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	item, _ := s.get(key)
+	return item.resp, nil
+}
+
+func (s *memoryStore) Save(ctx context.Context, key string, resp *Response, ttl time.Duration) error {
+	if resp == nil {
+		return errors.New("response can't be nil")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.items[key] = memoryItem{resp: resp, expire: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.items, key)
+	return nil
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package idempotent_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/idempotent"
+	"github.com/go-spring/spring-core/redis"
+	"github.com/go-spring/spring-core/web"
+)
+
+// responseWriter 模拟服务器使用的缓存响应内容的 web.ResponseWriter 。
+type responseWriter struct {
+	*httptest.ResponseRecorder
+	status int
+	buf    bytes.Buffer
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseRecorder.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.buf.Write(b)
+	return w.ResponseRecorder.Write(b)
+}
+
+func (w *responseWriter) Status() int  { return w.status }
+func (w *responseWriter) Size() int    { return w.buf.Len() }
+func (w *responseWriter) Body() string { return w.buf.String() }
+
+func TestFilter(t *testing.T) {
+
+	count := 0
+	handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		count++
+		if ctx.Request().URL.Path == "/fail" {
+			ctx.SetStatus(http.StatusInternalServerError)
+			ctx.String("error")
+			return
+		}
+		ctx.JSON(map[string]int{"count": count})
+	})
+
+	config := idempotent.NewFilterConfig(idempotent.NewMemoryStore())
+	config.Paths = []string{"/orders", "/fail"}
+	config.Required = true
+	f := idempotent.NewFilter(config)
+
+	serve := func(method, path, key, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://127.0.0.1:8080"+path, strings.NewReader(body))
+		if key != "" {
+			r.Header.Set(idempotent.HeaderIdempotencyKey, key)
+		}
+		w := &responseWriter{ResponseRecorder: httptest.NewRecorder()}
+		ctx := web.NewBaseContext("", nil, r, w)
+		web.NewFilterChain([]web.Filter{f, handler}).Next(ctx)
+		return w.ResponseRecorder
+	}
+
+	w := serve(http.MethodPost, "/orders", "k1", "a")
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, strings.TrimSpace(w.Body.String()), `{"count":1}`)
+
+	w = serve(http.MethodPost, "/orders", "k1", "a")
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, strings.TrimSpace(w.Body.String()), `{"count":1}`)
+	assert.Equal(t, w.Header().Get(idempotent.HeaderReplayed), "true")
+	assert.Equal(t, count, 1)
+
+	w = serve(http.MethodPost, "/orders", "k1", "b")
+	assert.Equal(t, w.Code, http.StatusUnprocessableEntity)
+
+	w = serve(http.MethodPost, "/orders", "", "a")
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+
+	w = serve(http.MethodGet, "/orders", "", "")
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, count, 2)
+
+	w = serve(http.MethodPost, "/users", "", "")
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, count, 3)
+
+	// 服务器错误的响应不会被保存，可以使用相同的幂等键重试。
+	serve(http.MethodPost, "/fail", "k2", "")
+	w = serve(http.MethodPost, "/fail", "k2", "")
+	assert.Equal(t, w.Code, http.StatusInternalServerError)
+	assert.Equal(t, count, 5)
+}
+
+func TestFilterInProgress(t *testing.T) {
+	store := idempotent.NewMemoryStore()
+	ok, err := store.Lock(context.Background(), "idempotent:k1", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	f := idempotent.NewFilter(idempotent.NewFilterConfig(store))
+	r, _ := http.NewRequest(http.MethodPost, "http://127.0.0.1:8080/orders", nil)
+	r.Header.Set(idempotent.HeaderIdempotencyKey, "k1")
+	w := &responseWriter{ResponseRecorder: httptest.NewRecorder()}
+	web.NewFilterChain([]web.Filter{f}).Next(web.NewBaseContext("", nil, r, w))
+	assert.Equal(t, w.Code, http.StatusConflict)
+}
+
+func TestFilterScope(t *testing.T) {
+
+	count := 0
+	handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		count++
+		ctx.String("%s:%d", ctx.Header(web.HeaderAuthorization), count)
+	})
+
+	config := idempotent.NewFilterConfig(idempotent.NewMemoryStore())
+	config.MaxBody = 4
+	f := idempotent.NewFilter(config)
+
+	serve := func(auth, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(http.MethodPost, "http://127.0.0.1:8080/orders", strings.NewReader(body))
+		r.Header.Set(idempotent.HeaderIdempotencyKey, "k1")
+		if auth != "" {
+			r.Header.Set(web.HeaderAuthorization, auth)
+		}
+		w := &responseWriter{ResponseRecorder: httptest.NewRecorder()}
+		web.NewFilterChain([]web.Filter{f, handler}).Next(web.NewBaseContext("", nil, r, w))
+		return w.ResponseRecorder
+	}
+
+	// 不同调用方使用相同的幂等键互不影响。
+	assert.Equal(t, serve("alice", "a").Body.String(), "alice:1")
+	assert.Equal(t, serve("bob", "a").Body.String(), "bob:2")
+	assert.Equal(t, serve("alice", "a").Body.String(), "alice:1")
+	assert.Equal(t, count, 2)
+
+	w := serve("alice", "12345")
+	assert.Equal(t, w.Code, http.StatusRequestEntityTooLarge)
+	assert.Equal(t, count, 2)
+}
+
+// connPool 只支持 SET、GET 和 DEL 命令的 redis.ConnPool 实现。
+type connPool struct {
+	data map[string]string
+}
+
+func (p *connPool) Exec(ctx context.Context, cmd string, args []interface{}) (interface{}, error) {
+	key := args[0].(string)
+	switch cmd {
+	case "SET":
+		if _, ok := p.data[key]; ok && args[len(args)-1] == "NX" {
+			return nil, redis.ErrNil()
+		}
+		p.data[key] = args[1].(string)
+		return "OK", nil
+	case "GET":
+		if v, ok := p.data[key]; ok {
+			return v, nil
+		}
+		return nil, redis.ErrNil()
+	case "DEL":
+		delete(p.data, key)
+		return int64(1), nil
+	}
+	return nil, nil
+}
+
+func TestRedisStore(t *testing.T) {
+	c, err := redis.NewClient(&connPool{data: map[string]string{}})
+	assert.Nil(t, err)
+	s := idempotent.NewRedisStore(c)
+	ctx := context.Background()
+
+	ok, err := s.Lock(ctx, "k", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = s.Lock(ctx, "k", time.Minute)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	resp, err := s.Get(ctx, "k")
+	assert.Nil(t, err)
+	assert.Nil(t, resp)
+
+	err = s.Save(ctx, "k", &idempotent.Response{Fingerprint: "fp", Status: 201, Body: "ok"}, time.Hour)
+	assert.Nil(t, err)
+
+	resp, err = s.Get(ctx, "k")
+	assert.Nil(t, err)
+	assert.Equal(t, resp, &idempotent.Response{Fingerprint: "fp", Status: 201, Body: "ok"})
+
+	assert.Nil(t, s.Delete(ctx, "k"))
+	resp, err = s.Get(ctx, "k")
+	assert.Nil(t, err)
+	assert.Nil(t, resp)
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package idempotent
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-spring/spring-core/internal/redisutil"
+	"github.com/go-spring/spring-core/redis"
+)
+
+// lockValue 请求处理中时幂等键保存的值。
+const lockValue = "::locked::"
+
+// redisStore 基于 redis 的 Store 实现，适用于多实例部署。
+type redisStore struct {
+	store *redisutil.TTLStore
+}
+
+// NewRedisStore 创建基于 redis 的 Store 。
+func NewRedisStore(client *redis.Client) Store {
+	return &redisStore{store: redisutil.NewTTLStore(client)}
+}
+
+func (s *redisStore) Lock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return s.store.SetNX(ctx, key, lockValue, ttl)
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) (*Response, error) {
+	str, ok, err := s.store.GetString(ctx, key)
+	if !ok || err != nil || str == lockValue {
+		return nil, err
+	}
+	resp := new(Response)
+	if err = json.Unmarshal([]byte(str), resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s *redisStore) Save(ctx context.Context, key string, resp *Response, ttl time.Duration) error {
+	return s.store.Set(ctx, key, resp, ttl)
+}
+
+func (s *redisStore) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
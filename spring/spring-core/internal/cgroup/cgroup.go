@@ -0,0 +1,136 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package cgroup 读取 cgroup 中配置的 CPU 和内存限制，支持 cgroup v1 和 v2 。
+package cgroup
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// unlimitedMemory cgroup v1 没有内存限制时 memory.limit_in_bytes 是一个接近
+// math.MaxInt64 的值，超过这个值的限制被认为是没有限制。
+const unlimitedMemory = int64(1) << 62
+
+// Limits 容器的资源限制。
+type Limits struct {
+	CPU    float64 // 可以使用的 CPU 核数，为 0 时没有限制
+	Memory int64   // 可以使用的内存字节数，为 0 时没有限制
+}
+
+// Detect 返回当前进程的资源限制，不在容器中运行或者读取失败时返回零值。
+func Detect() Limits {
+	return DetectFrom("/proc/self/cgroup", "/sys/fs/cgroup")
+}
+
+// DetectFrom 根据 procFile (即 /proc/self/cgroup) 和 cgroup 挂载目录 root 返回资源限制。
+func DetectFrom(procFile, root string) Limits {
+	paths, err := readProcCgroup(procFile)
+	if err != nil {
+		return Limits{}
+	}
+	if _, err = os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		return detectV2(root, paths[""])
+	}
+	return detectV1(root, paths)
+}
+
+// readProcCgroup 返回控制器和 cgroup 路径的映射，cgroup v2 的控制器为空字符串。
+func readProcCgroup(procFile string) (map[string]string, error) {
+	f, err := os.Open(procFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	paths := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		ss := strings.SplitN(scanner.Text(), ":", 3)
+		if len(ss) != 3 {
+			continue
+		}
+		if ss[1] == "" {
+			paths[""] = ss[2]
+			continue
+		}
+		for _, c := range strings.Split(ss[1], ",") {
+			paths[c] = ss[2]
+		}
+	}
+	return paths, scanner.Err()
+}
+
+// readFile 依次在 cgroup 路径和挂载根目录下读取文件，容器中的 cgroup 通常被挂载
+// 为根目录，此时 /proc/self/cgroup 中的路径在容器中并不存在。
+func readFile(dir, path, name string) (string, bool) {
+	for _, file := range []string{filepath.Join(dir, path, name), filepath.Join(dir, name)} {
+		if b, err := ioutil.ReadFile(file); err == nil {
+			return strings.TrimSpace(string(b)), true
+		}
+	}
+	return "", false
+}
+
+func detectV2(root, path string) Limits {
+	var limits Limits
+	if s, ok := readFile(root, path, "cpu.max"); ok {
+		if ss := strings.Fields(s); len(ss) == 2 && ss[0] != "max" {
+			quota, err1 := strconv.ParseFloat(ss[0], 64)
+			period, err2 := strconv.ParseFloat(ss[1], 64)
+			if err1 == nil && err2 == nil && quota > 0 && period > 0 {
+				limits.CPU = quota / period
+			}
+		}
+	}
+	if s, ok := readFile(root, path, "memory.max"); ok && s != "max" {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil && n > 0 {
+			limits.Memory = n
+		}
+	}
+	return limits
+}
+
+func detectV1(root string, paths map[string]string) Limits {
+	var limits Limits
+	if path, ok := paths["cpu"]; ok {
+		for _, dir := range []string{"cpu", "cpu,cpuacct", "cpuacct,cpu"} {
+			s1, ok1 := readFile(filepath.Join(root, dir), path, "cpu.cfs_quota_us")
+			s2, ok2 := readFile(filepath.Join(root, dir), path, "cpu.cfs_period_us")
+			if !ok1 || !ok2 {
+				continue
+			}
+			quota, err1 := strconv.ParseFloat(s1, 64)
+			period, err2 := strconv.ParseFloat(s2, 64)
+			if err1 == nil && err2 == nil && quota > 0 && period > 0 {
+				limits.CPU = quota / period
+			}
+			break
+		}
+	}
+	if path, ok := paths["memory"]; ok {
+		if s, ok := readFile(filepath.Join(root, "memory"), path, "memory.limit_in_bytes"); ok {
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil && n > 0 && n < unlimitedMemory {
+				limits.Memory = n
+			}
+		}
+	}
+	return limits
+}
@@ -0,0 +1,39 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cgroup_test
+
+import (
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/internal/cgroup"
+)
+
+func TestDetectFrom(t *testing.T) {
+	tests := map[string]cgroup.Limits{
+		"v1":           {CPU: 2, Memory: 1 << 30},
+		"v1-unlimited": {},
+		"v2":           {CPU: 1.5, Memory: 512 << 20},
+		"v2-unlimited": {},
+		"not-exist":    {},
+	}
+	for name, expect := range tests {
+		dir := "testdata/" + name
+		limits := cgroup.DetectFrom(dir+"/cgroup", dir+"/root")
+		assert.Equal(t, limits, expect)
+	}
+}
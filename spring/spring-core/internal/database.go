@@ -18,5 +18,8 @@ package internal
 
 // DatabaseClientConfig 关系型数据库客户端配置，通常配合数据库名称前缀一起使用。
 type DatabaseClientConfig struct {
-	Url string `value:"${url}"`
+	Url          string        `value:"${url}"`
+	MaxOpenConns int           `value:"${max-open-conns:=${runtime.pool.size:=0}}"` // 最大连接数，为 0 时不限制
+	MaxIdleConns int           `value:"${max-idle-conns:=2}"`                       // 最大空闲连接数
+	WaitFor      WaitForConfig `value:"${wait-for}"`                                // 启动时等待数据库就绪
 }
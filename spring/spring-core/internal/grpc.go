@@ -18,10 +18,27 @@ package internal
 
 // GrpcServerConfig gRPC 服务器配置，通常配合服务器名称前缀一起使用。
 type GrpcServerConfig struct {
-	Port int `value:"${port:=9090}"`
+	Port       int            `value:"${port:=9090}"`
+	Reflection bool           `value:"${reflection.enabled:=false}"` // 是否注册反射服务，grpcurl 等工具需要
+	Bulkhead   BulkheadConfig `value:"${bulkhead}"`                  // 限制同时处理的请求数量
+	TLS        bool           `value:"${tls.enabled:=false}"`        // 是否使用 tls.* 配置开启 TLS
 }
 
 // GrpcEndpointConfig gRPC 服务端点配置，通常配合端点名称前缀一起使用。
 type GrpcEndpointConfig struct {
-	Address string `value:"${address:=127.0.0.1:9090}"`
+	Address       string              `value:"${address:=127.0.0.1:9090}"`     // 支持 dns:///host:port 形式的地址
+	Endpoints     []GrpcAddressConfig `value:"${endpoints:=}"`                 // 静态端点列表，不为空时忽略 Address
+	Balancer      string              `value:"${balancer:=pick_first}"`        // 负载均衡策略
+	HashHeader    string              `value:"${hash-header:=}"`               // 一致性哈希使用的请求头
+	HealthCheck   bool                `value:"${health-check.enabled:=false}"` // 是否剔除健康检查失败的端点
+	HealthService string              `value:"${health-check.service:=}"`      // 健康检查的服务名称
+	Bulkhead      BulkheadConfig      `value:"${bulkhead}"`                    // 限制同时进行的调用数量
+	TLS           bool                `value:"${tls.enabled:=false}"`          // 是否使用 tls.* 配置开启 TLS
+	Policy        string              `value:"${policy:=}"`                    // resilience.policies 中定义的调用策略
+}
+
+// GrpcAddressConfig gRPC 静态端点配置。
+type GrpcAddressConfig struct {
+	Address string `value:"${address}"`
+	Weight  int    `value:"${weight:=1}"`
 }
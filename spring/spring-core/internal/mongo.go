@@ -18,6 +18,7 @@ package internal
 
 // MongoClientConfig MongoDB 客户端配置，通常配合客户端名称前缀一起使用。
 type MongoClientConfig struct {
-	Url  string `value:"${url:=mongodb://localhost}"`
-	Ping bool   `value:"${ping:=true}"` // 是否 PING 探测
+	Url     string        `value:"${url:=mongodb://localhost}"`
+	Ping    bool          `value:"${ping:=true}"` // 是否 PING 探测
+	WaitFor WaitForConfig `value:"${wait-for}"`   // 启动时等待服务器就绪
 }
@@ -18,14 +18,16 @@ package internal
 
 // RedisClientConfig Redis 客户端配置，通常配合 redis 服务器名称前缀一起使用。
 type RedisClientConfig struct {
-	Host           string `value:"${host:=127.0.0.1}"`    // IP
-	Port           int    `value:"${port:=6379}"`         // 端口号
-	Username       string `value:"${username:=}"`         // 用户名
-	Password       string `value:"${password:=}"`         // 密码
-	Database       int    `value:"${database:=0}"`        // DB 序号
-	Ping           bool   `value:"${ping:=true}"`         // 是否 PING 探测
-	ConnectTimeout int    `value:"${connect-timeout:=0}"` // 连接超时，毫秒
-	ReadTimeout    int    `value:"${read-timeout:=0}"`    // 读取超时，毫秒
-	WriteTimeout   int    `value:"${write-timeout:=0}"`   // 写入超时，毫秒
-	IdleTimeout    int    `value:"${idle-timeout:=0}"`    // 空闲连接超时，毫秒
+	Host           string        `value:"${host:=127.0.0.1}"`                    // IP
+	Port           int           `value:"${port:=6379}"`                         // 端口号
+	Username       string        `value:"${username:=}"`                         // 用户名
+	Password       string        `value:"${password:=}"`                         // 密码
+	Database       int           `value:"${database:=0}"`                        // DB 序号
+	Ping           bool          `value:"${ping:=true}"`                         // 是否 PING 探测
+	ConnectTimeout int           `value:"${connect-timeout:=0}"`                 // 连接超时，毫秒
+	ReadTimeout    int           `value:"${read-timeout:=0}"`                    // 读取超时，毫秒
+	WriteTimeout   int           `value:"${write-timeout:=0}"`                   // 写入超时，毫秒
+	IdleTimeout    int           `value:"${idle-timeout:=0}"`                    // 空闲连接超时，毫秒
+	PoolSize       int           `value:"${pool-size:=${runtime.pool.size:=0}}"` // 连接池大小，为 0 时使用客户端的默认值
+	WaitFor        WaitForConfig `value:"${wait-for}"`                           // 启动时等待服务器就绪
 }
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package redisutil 提供了使用 redis 保存数据的模块共用的工具。
+package redisutil
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-spring/spring-core/redis"
+)
+
+// TTLStore 基于 redis 字符串的带有过期时间的存储，键由调用者加上各自模块的前缀，
+// 值为字符串或者 JSON 编码的对象。
+type TTLStore struct {
+	client *redis.Client
+}
+
+// NewTTLStore 创建基于 redis 的 TTLStore 。
+func NewTTLStore(client *redis.Client) *TTLStore {
+	return &TTLStore{client: client}
+}
+
+// GetString 返回键的值，键不存在时 ok 为 false 。
+func (s *TTLStore) GetString(ctx context.Context, key string) (value string, ok bool, err error) {
+	value, err = s.client.OpsForString().Get(ctx, key)
+	if redis.IsErrNil(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// Get 将键的值按照 JSON 解码到 v ，键不存在时 ok 为 false 。
+func (s *TTLStore) Get(ctx context.Context, key string, v interface{}) (ok bool, err error) {
+	str, ok, err := s.GetString(ctx, key)
+	if !ok || err != nil {
+		return false, err
+	}
+	if err = json.Unmarshal([]byte(str), v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Set 将 v 按照 JSON 编码之后保存 ttl 时长。
+func (s *TTLStore) Set(ctx context.Context, key string, v interface{}, ttl time.Duration) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.OpsForString().Set(ctx, key, string(b), "PX", ttl.Milliseconds())
+	return err
+}
+
+// SetNX 键不存在时保存 value ttl 时长，键已经存在时返回 false 。
+func (s *TTLStore) SetNX(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	_, err := s.client.OpsForString().Set(ctx, key, value, "PX", ttl.Milliseconds(), "NX")
+	if redis.IsErrNil(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete 删除键。
+func (s *TTLStore) Delete(ctx context.Context, key string) error {
+	_, err := s.client.OpsForKey().Del(ctx, key)
+	return err
+}
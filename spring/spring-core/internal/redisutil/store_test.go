@@ -0,0 +1,58 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redisutil_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/internal/redisutil"
+	"github.com/go-spring/spring-core/redis"
+)
+
+func TestTTLStore(t *testing.T) {
+	c, err := redis.NewMemoryClient()
+	assert.Nil(t, err)
+	s := redisutil.NewTTLStore(c)
+	ctx := context.Background()
+
+	var v map[string]int
+	ok, err := s.Get(ctx, "k", &v)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, s.Set(ctx, "k", map[string]int{"a": 1}, time.Minute))
+	ok, err = s.Get(ctx, "k", &v)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, v, map[string]int{"a": 1})
+
+	ok, err = s.SetNX(ctx, "k", "1", time.Minute)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, s.Delete(ctx, "k"))
+	ok, err = s.SetNX(ctx, "k", "1", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	str, ok, err := s.GetString(ctx, "k")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, str, "1")
+}
@@ -0,0 +1,26 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import "time"
+
+// BulkheadConfig 舱壁配置，限制同时执行的调用数量。
+type BulkheadConfig struct {
+	MaxConcurrent int           `value:"${max-concurrent:=0}"` // 最大并发数，0 表示不限制
+	MaxWait       time.Duration `value:"${max-wait:=0}"`       // 排队等待的最长时间，0 表示不等待
+	MaxWaiting    int           `value:"${max-waiting:=0}"`    // 最多排队的调用数量，0 表示不限制
+}
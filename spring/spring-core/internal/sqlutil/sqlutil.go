@@ -0,0 +1,43 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sqlutil 提供了使用 database/sql 的模块共用的 SQL 工具。
+package sqlutil
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Bind 将 SQL 语句中的 ? 依次替换为 placeholder 加序号，例如 PostgreSQL 的 $1 、
+// $2 ，placeholder 为 ? 时原样返回。
+func Bind(query string, placeholder string) string {
+	if placeholder == "?" {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			sb.WriteString(placeholder)
+			sb.WriteString(strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,30 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqlutil_test
+
+import (
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/internal/sqlutil"
+)
+
+func TestBind(t *testing.T) {
+	query := "UPDATE jobs SET status = ? WHERE id = ?"
+	assert.Equal(t, sqlutil.Bind(query, "?"), query)
+	assert.Equal(t, sqlutil.Bind(query, "$"), "UPDATE jobs SET status = $1 WHERE id = $2")
+}
@@ -0,0 +1,28 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import "time"
+
+// WaitForConfig 启动时等待依赖的服务就绪的配置，通常配合客户端配置的 wait-for 前缀
+// 一起使用，例如 redis.wait-for.max-wait=30s 。
+type WaitForConfig struct {
+	MaxWait    time.Duration `value:"${max-wait:=0}"`     // 最长等待时间，0 表示不等待，只尝试一次
+	Backoff    time.Duration `value:"${backoff:=500ms}"`  // 第一次重试之前等待的时间
+	MaxBackoff time.Duration `value:"${max-backoff:=5s}"` // 等待时间的上限
+	Multiplier float64       `value:"${multiplier:=2}"`   // 每次重试之后等待时间增长的倍数
+}
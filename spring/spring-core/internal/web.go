@@ -16,6 +16,8 @@
 
 package internal
 
+import "github.com/go-spring/spring-core/conf"
+
 // WebServerConfig Web 服务器配置，通常配合 web 服务器名称前缀一起使用。
 type WebServerConfig struct {
 	Host         string `value:"${host:=}"`            // 监听 IP
@@ -26,4 +28,9 @@ type WebServerConfig struct {
 	BasePath     string `value:"${base-path:=/}"`      // 根路径
 	ReadTimeout  int    `value:"${read-timeout:=0}"`   // 读取超时，毫秒
 	WriteTimeout int    `value:"${write-timeout:=0}"`  // 写入超时，毫秒
+
+	ReadHeaderTimeout int           `value:"${read-header-timeout:=0}"` // 读取请求头超时，毫秒，为 0 时使用读取超时
+	IdleTimeout       int           `value:"${idle-timeout:=0}"`        // 长连接空闲超时，毫秒，为 0 时使用读取超时
+	MaxHeaderBytes    conf.ByteSize `value:"${max-header-bytes:=0}"`    // 请求头最大字节数，如 64KB ，为 0 时使用 1MB
+	MaxBodySize       conf.ByteSize `value:"${max-body-size:=0}"`       // 请求体最大字节数，如 8MB ，为 0 时不限制
 }
@@ -0,0 +1,254 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ipfilter 根据客户端的 IP 地址控制访问，支持 CIDR 形式的黑白名单以及基于
+// GeoIP 的国家或者地区限制。规则按照路径分组配置，可以在运行时重新加载，用于快速处
+// 置恶意访问，例如：
+//
+//	gs.Provide(ipfilter.New, "${web.ip-filter}", "?")
+package ipfilter
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/web"
+)
+
+var logger = log.GetLogger("GS_IPFILTER")
+
+// GeoIP 查询 IP 地址所属的国家或者地区，返回 ISO 3166-1 的两位字母代码，如 CN 、US ，
+// 无法确定时返回空字符串。
+type GeoIP interface {
+	Country(ip net.IP) (string, error)
+}
+
+// GroupConfig 一组路径的访问规则。拒绝规则优先，允许规则非空时只有命中的请求可以访问。
+type GroupConfig struct {
+	Name           string   `value:"${name}"`              // 分组的名称，必须配置
+	Paths          []string `value:"${paths:=}"`           // 规则作用的路径前缀，为空时作用于所有路径
+	Allow          []string `value:"${allow:=}"`           // 允许访问的 IP 或者 CIDR ，如 10.0.0.0/8
+	Deny           []string `value:"${deny:=}"`            // 禁止访问的 IP 或者 CIDR
+	AllowCountries []string `value:"${allow-countries:=}"` // 允许访问的国家或者地区，需要 GeoIP
+	DenyCountries  []string `value:"${deny-countries:=}"`  // 禁止访问的国家或者地区，需要 GeoIP
+	Status         int      `value:"${status:=403}"`       // 拒绝访问时返回的状态码
+}
+
+// Config 访问控制配置，请求需要通过所有匹配的分组的检查。
+type Config struct {
+	TrustProxy bool          `value:"${trust-proxy:=false}"` // 为 true 时从 X-Forwarded-For 等请求头获取客户端地址
+	Groups     []GroupConfig `value:"${groups:=}"`
+}
+
+type group struct {
+	config         GroupConfig
+	allow          []*net.IPNet
+	deny           []*net.IPNet
+	allowCountries map[string]bool
+	denyCountries  map[string]bool
+}
+
+func newGroup(config GroupConfig, geo GeoIP) (*group, error) {
+	var err error
+	g := &group{config: config}
+	if g.allow, err = parseNets(config.Allow); err != nil {
+		return nil, err
+	}
+	if g.deny, err = parseNets(config.Deny); err != nil {
+		return nil, err
+	}
+	g.allowCountries = countrySet(config.AllowCountries)
+	g.denyCountries = countrySet(config.DenyCountries)
+	if geo == nil && (len(g.allowCountries) > 0 || len(g.denyCountries) > 0) {
+		return nil, fmt.Errorf("ip-filter group %q: countries need a GeoIP provider", config.Name)
+	}
+	if config.Status == 0 {
+		g.config.Status = http.StatusForbidden
+	}
+	return g, nil
+}
+
+// parseNets 解析 IP 或者 CIDR 列表，单个 IP 地址视为只包含自身的网段。
+func parseNets(a []string) ([]*net.IPNet, error) {
+	var ret []*net.IPNet
+	for _, s := range a {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip %q", s)
+			}
+			bits := 8 * net.IPv6len
+			if v4 := ip.To4(); v4 != nil {
+				ip, bits = v4, 8*net.IPv4len
+			}
+			ret = append(ret, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, n)
+	}
+	return ret, nil
+}
+
+func countrySet(a []string) map[string]bool {
+	m := make(map[string]bool)
+	for _, s := range a {
+		if s = strings.TrimSpace(s); s != "" {
+			m[strings.ToUpper(s)] = true
+		}
+	}
+	return m
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *group) match(path string) bool {
+	if len(g.config.Paths) == 0 {
+		return true
+	}
+	for _, p := range g.config.Paths {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *group) useGeo() bool {
+	return len(g.allowCountries) > 0 || len(g.denyCountries) > 0
+}
+
+// allowed 返回是否允许 ip 访问，country 为空表示未知。
+func (g *group) allowed(ip net.IP, country string) bool {
+	if containsIP(g.deny, ip) || g.denyCountries[country] {
+		return false
+	}
+	if len(g.allow) == 0 && len(g.allowCountries) == 0 {
+		return true
+	}
+	return containsIP(g.allow, ip) || g.allowCountries[country]
+}
+
+type rules struct {
+	config Config
+	groups []*group
+}
+
+// Filter 根据客户端 IP 地址控制访问的过滤器。
+type Filter struct {
+	rules atomic.Value
+	geo   GeoIP
+}
+
+// New 创建访问控制过滤器，geo 可以为空，此时不支持按照国家或者地区限制访问。
+func New(config Config, geo GeoIP) (*Filter, error) {
+	f := &Filter{geo: geo}
+	if err := f.Reload(config); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload 在运行时替换访问规则，例如临时封禁发起攻击的网段，配置有误时保留原来的规则。
+func (f *Filter) Reload(config Config) error {
+	r := &rules{config: config}
+	for _, c := range config.Groups {
+		g, err := newGroup(c, f.geo)
+		if err != nil {
+			return err
+		}
+		r.groups = append(r.groups, g)
+	}
+	f.rules.Store(r)
+	return nil
+}
+
+// Config 返回当前生效的访问控制配置。
+func (f *Filter) Config() Config {
+	return f.rules.Load().(*rules).config
+}
+
+func (f *Filter) Invoke(ctx web.Context, chain web.FilterChain) {
+	r := f.rules.Load().(*rules)
+	ip := clientIP(ctx, r.config.TrustProxy)
+
+	var (
+		country string
+		located bool
+	)
+
+	path := ctx.Request().URL.Path
+	for _, g := range r.groups {
+		if !g.match(path) {
+			continue
+		}
+		if g.useGeo() && !located {
+			country, located = f.country(ctx, ip), true
+		}
+		if !g.allowed(ip, country) {
+			ctx.SetStatus(g.config.Status)
+			ctx.String(http.StatusText(g.config.Status))
+			return
+		}
+	}
+	chain.Next(ctx)
+}
+
+// country 查询 ip 所属的国家或者地区，查询失败时视为未知。
+func (f *Filter) country(ctx web.Context, ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	country, err := f.geo.Country(ip)
+	if err != nil {
+		logger.WithContext(ctx.Context()).Errorf(log.ERROR, "geoip lookup %s error: %v", ip, err)
+		return ""
+	}
+	return strings.ToUpper(country)
+}
+
+// clientIP 返回客户端的 IP 地址，只有信任代理时才使用请求头中的地址，否则攻击者可以
+// 通过伪造请求头绕过限制。
+func clientIP(ctx web.Context, trustProxy bool) net.IP {
+	if trustProxy {
+		return net.ParseIP(ctx.ClientIP())
+	}
+	addr := ctx.Request().RemoteAddr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(addr)
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ipfilter_test
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/ipfilter"
+	"github.com/go-spring/spring-core/web"
+)
+
+type geoIP map[string]string
+
+func (g geoIP) Country(ip net.IP) (string, error) {
+	if ip.String() == "9.9.9.9" {
+		return "", errors.New("lookup failed")
+	}
+	return g[ip.String()], nil
+}
+
+func invoke(f web.Filter, path string, remoteAddr string, header http.Header) int {
+	handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		ctx.String("ok")
+	})
+	r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:8080"+path, nil)
+	r.RemoteAddr = remoteAddr
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	ctx := web.NewBaseContext("", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+	web.NewFilterChain([]web.Filter{f, handler}).Next(ctx)
+	return w.Code
+}
+
+func TestFilter(t *testing.T) {
+
+	p, err := conf.Bytes([]byte(`
+		ip-filter.groups[0].name=global
+		ip-filter.groups[0].deny=1.2.3.4,5.6.0.0/16
+		ip-filter.groups[0].deny-countries=kp
+		ip-filter.groups[1].name=admin
+		ip-filter.groups[1].paths=/admin
+		ip-filter.groups[1].allow=10.0.0.0/8,::1
+		ip-filter.groups[1].status=404
+		ip-filter.groups[2].name=shop
+		ip-filter.groups[2].paths=/shop
+		ip-filter.groups[2].allow-countries=CN
+	`), ".properties")
+	assert.Nil(t, err)
+
+	var config ipfilter.Config
+	err = p.Bind(&config, conf.Key("ip-filter"))
+	assert.Nil(t, err)
+	assert.False(t, config.TrustProxy)
+	assert.Equal(t, config.Groups[0].Status, 403)
+
+	_, err = ipfilter.New(config, nil)
+	assert.Error(t, err, "ip-filter group \"global\": countries need a GeoIP provider")
+
+	geo := geoIP{"8.8.8.8": "US", "7.7.7.7": "KP", "6.6.6.6": "cn"}
+	f, err := ipfilter.New(config, geo)
+	assert.Nil(t, err)
+
+	forwarded := http.Header{"X-Forwarded-For": {"10.1.1.1"}}
+	for _, c := range []struct {
+		path   string
+		addr   string
+		header http.Header
+		status int
+	}{
+		{"/api", "8.8.8.8:1234", nil, 200},
+		{"/api", "1.2.3.4:1234", nil, 403},
+		{"/api", "5.6.7.8:1234", nil, 403},
+		{"/api", "7.7.7.7:1234", nil, 403},
+		{"/api", "9.9.9.9:1234", nil, 200},
+		{"/admin/users", "10.2.3.4:1234", nil, 200},
+		{"/admin/users", "[::1]:1234", nil, 200},
+		{"/admin/users", "8.8.8.8:1234", nil, 404},
+		{"/admin/users", "8.8.8.8:1234", forwarded, 404},
+		{"/shop", "6.6.6.6:1234", nil, 200},
+		{"/shop", "8.8.8.8:1234", nil, 403},
+		{"/shop", "9.9.9.9:1234", nil, 403},
+	} {
+		status := invoke(f, c.path, c.addr, c.header)
+		assert.Equal(t, status, c.status)
+	}
+
+	// 信任代理时使用请求头中的客户端地址。
+	config.TrustProxy = true
+	err = f.Reload(config)
+	assert.Nil(t, err)
+	assert.Equal(t, invoke(f, "/admin/users", "8.8.8.8:1234", forwarded), 200)
+
+	// 配置有误时保留原来的规则。
+	config.Groups[0].Deny = []string{"1.2.3"}
+	err = f.Reload(config)
+	assert.Error(t, err, "invalid ip \"1.2.3\"")
+	assert.Equal(t, invoke(f, "/api", "1.2.3.4:1234", nil), 403)
+
+	config.Groups[0].Deny = nil
+	err = f.Reload(config)
+	assert.Nil(t, err)
+	assert.Equal(t, invoke(f, "/api", "1.2.3.4:1234", nil), 200)
+	assert.Equal(t, len(f.Config().Groups[0].Deny), 0)
+}
@@ -0,0 +1,416 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package jobqueue 实现了持久化的后台任务队列。处理请求时将任务放入队列，注册为 bean
+// 的 Worker 在后台按照配置的并发数执行任务，例如：
+//
+//	gs.Object(jobqueue.NewWorker("email", func(ctx context.Context, m *Mail) error {
+//		return mailer.Send(ctx, m)
+//	}).Concurrency(4))
+//
+//	id, err := queue.Enqueue(ctx, "email", &Mail{To: "jim@example.com"}, jobqueue.Delay(time.Minute))
+//
+// 任务被取出之后在 Lease 时间内被当前实例占用，执行成功之后才从队列中删除，实例崩溃
+// 时超过占用时间的任务会被再次取出，因此任务至少执行一次，处理函数需要保证幂等。执行
+// 失败的任务按照指数退避重试，超过最多执行次数之后被移入失败列表。
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-base/util"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/report"
+	"github.com/google/uuid"
+)
+
+var logger = log.GetLogger("GS_JOBQUEUE")
+
+// ErrQueueClosed 任务队列已经关闭。
+var ErrQueueClosed = errors.New("jobqueue: queue is closed")
+
+// Job 队列中的任务。
+type Job struct {
+	ID          string          `json:"id"`
+	Queue       string          `json:"queue"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"` // 已经取出执行的次数
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"` // 任务可以被执行的时间
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// Backend 保存任务的存储。
+type Backend interface {
+
+	// Enqueue 保存任务，任务在 RunAt 之后可以被取出。
+	Enqueue(ctx context.Context, job *Job) error
+
+	// Dequeue 取出一个可以执行的任务并且在 lease 时间内占用，同时将任务的执行次数加一，
+	// 没有可以执行的任务时返回 nil 。
+	Dequeue(ctx context.Context, queue string, lease time.Duration) (*Job, error)
+
+	// Ack 任务执行成功，删除任务。
+	Ack(ctx context.Context, job *Job) error
+
+	// Retry 任务执行失败，保存 LastError 并且在 runAt 之后再次执行。
+	Retry(ctx context.Context, job *Job, runAt time.Time) error
+
+	// Fail 任务最终失败，将任务移入失败列表。
+	Fail(ctx context.Context, job *Job) error
+}
+
+// Option 放入队列时的任务选项。
+type Option func(job *Job)
+
+// Delay 任务在 d 之后执行。
+func Delay(d time.Duration) Option {
+	return func(job *Job) {
+		job.RunAt = time.Now().Add(d)
+	}
+}
+
+// At 任务在 t 之后执行。
+func At(t time.Time) Option {
+	return func(job *Job) {
+		job.RunAt = t
+	}
+}
+
+// MaxAttempts 任务最多执行的次数，包括第一次执行。
+func MaxAttempts(n int) Option {
+	return func(job *Job) {
+		job.MaxAttempts = n
+	}
+}
+
+// WithID 使用指定的任务 ID ，默认为随机的 UUID 。
+func WithID(id string) Option {
+	return func(job *Job) {
+		job.ID = id
+	}
+}
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 标记不应该重试的错误，任务直接被移入失败列表。
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+var jobType = reflect.TypeOf((*Job)(nil))
+
+// Worker 执行某个队列的任务。
+type Worker struct {
+	queue       string
+	t           reflect.Type
+	fn          reflect.Value
+	concurrency int
+	maxAttempts int
+}
+
+// NewWorker 创建 Worker ，fn 的形式为 func(ctx context.Context, payload T) error ，
+// T 为 *Job 时传入任务本身，其他类型通过 json 解码任务的 Payload 。
+func NewWorker(queue string, fn interface{}) *Worker {
+	t := reflect.TypeOf(fn)
+	if !util.IsFuncType(t) || !util.ReturnOnlyError(t) || t.NumIn() != 2 || !util.IsContextType(t.In(0)) {
+		panic(errors.New("fn should be func(context.Context, T) error"))
+	}
+	return &Worker{queue: queue, t: t.In(1), fn: reflect.ValueOf(fn), concurrency: 1}
+}
+
+// Concurrency 同时执行的任务数量，默认为 1 。
+func (w *Worker) Concurrency(n int) *Worker {
+	if n < 1 {
+		n = 1
+	}
+	w.concurrency = n
+	return w
+}
+
+// MaxAttempts 该队列的任务默认最多执行的次数，为 0 时使用 Config.MaxAttempts 。
+func (w *Worker) MaxAttempts(n int) *Worker {
+	w.maxAttempts = n
+	return w
+}
+
+func (w *Worker) call(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			report.Panic(report.WithTags(ctx, "jobqueue.queue", w.queue), report.SourceTask, r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	var arg reflect.Value
+	if w.t == jobType {
+		arg = reflect.ValueOf(job)
+	} else {
+		ptr := reflect.New(w.t)
+		if err = json.Unmarshal(job.Payload, ptr.Interface()); err != nil {
+			return Permanent(err)
+		}
+		arg = ptr.Elem()
+	}
+	out := w.fn.Call([]reflect.Value{reflect.ValueOf(ctx), arg})
+	err, _ = out[0].Interface().(error)
+	return err
+}
+
+// Config 任务队列的配置，通常配合 jobqueue 前缀一起使用。
+type Config struct {
+	PollInterval time.Duration `value:"${poll-interval:=1s}"` // 队列为空时轮询的间隔
+	Lease        time.Duration `value:"${lease:=5m}"`         // 任务的占用时间，也是任务执行的超时时间
+	MaxAttempts  int           `value:"${max-attempts:=5}"`   // 任务默认最多执行的次数
+	Backoff      time.Duration `value:"${backoff:=1s}"`       // 第一次重试之前等待的时间
+	MaxBackoff   time.Duration `value:"${max-backoff:=1h}"`
+	Multiplier   float64       `value:"${multiplier:=2}"`
+}
+
+// NewConfig 返回默认的配置。
+func NewConfig() Config {
+	return Config{
+		PollInterval: time.Second,
+		Lease:        5 * time.Minute,
+		MaxAttempts:  5,
+		Backoff:      time.Second,
+		MaxBackoff:   time.Hour,
+		Multiplier:   2,
+	}
+}
+
+// Queue 任务队列，负责放入任务以及在后台运行 Worker 。
+type Queue struct {
+	config  Config
+	backend Backend
+	workers map[string]*Worker
+	notify  map[string]chan struct{}
+
+	mutex  sync.RWMutex
+	closed bool
+	stop   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewQueue 创建任务队列，backend 为空时使用 MemoryBackend ，同一个队列只能有一个 Worker 。
+func NewQueue(config Config, backend Backend, workers []*Worker) (*Queue, error) {
+	if backend == nil {
+		backend = NewMemoryBackend()
+	}
+	q := &Queue{
+		config:  config,
+		backend: backend,
+		workers: make(map[string]*Worker),
+		notify:  make(map[string]chan struct{}),
+		stop:    make(chan struct{}),
+	}
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+	for _, w := range workers {
+		if _, ok := q.workers[w.queue]; ok {
+			return nil, fmt.Errorf("jobqueue: duplicate worker for queue %s", w.queue)
+		}
+		q.workers[w.queue] = w
+		q.notify[w.queue] = make(chan struct{}, 1)
+	}
+	return q, nil
+}
+
+// Enqueue 将任务放入队列，payload 通过 json 序列化，返回任务 ID 。
+func (q *Queue) Enqueue(ctx context.Context, queue string, payload interface{}, opts ...Option) (string, error) {
+	q.mutex.RLock()
+	closed := q.closed
+	q.mutex.RUnlock()
+	if closed {
+		return "", ErrQueueClosed
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	job := &Job{
+		ID:          uuid.New().String(),
+		Queue:       queue,
+		Payload:     b,
+		MaxAttempts: q.config.MaxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+	}
+	if w, ok := q.workers[queue]; ok && w.maxAttempts > 0 {
+		job.MaxAttempts = w.maxAttempts
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	if err = q.backend.Enqueue(ctx, job); err != nil {
+		return "", err
+	}
+	if c, ok := q.notify[queue]; ok && !job.RunAt.After(now) {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+	return job.ID, nil
+}
+
+// Start 在后台运行所有的 Worker 。
+func (q *Queue) Start() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.closed {
+		return
+	}
+	for _, w := range q.workers {
+		q.wg.Add(1)
+		go q.poll(w)
+	}
+}
+
+// poll 不断地从队列中取出任务并执行，同时执行的任务数量不超过 Worker 的并发数。
+func (q *Queue) poll(w *Worker) {
+	defer q.wg.Done()
+	sem := make(chan struct{}, w.concurrency)
+	for {
+		select {
+		case sem <- struct{}{}:
+		case <-q.stop:
+			return
+		}
+		job, err := q.backend.Dequeue(q.ctx, w.queue, q.config.Lease)
+		if err != nil {
+			logger.WithContext(q.ctx).Errorf(log.ERROR, "jobqueue %s: dequeue error: %v", w.queue, err)
+		}
+		if job == nil {
+			<-sem
+			select {
+			case <-q.stop:
+				return
+			case <-q.notify[w.queue]:
+			case <-time.After(q.config.PollInterval):
+			}
+			continue
+		}
+		q.wg.Add(1)
+		go func() {
+			defer func() { <-sem }()
+			defer q.wg.Done()
+			q.run(w, job)
+		}()
+	}
+}
+
+// run 执行任务，根据结果确认、重试或者移入失败列表。
+func (q *Queue) run(w *Worker, job *Job) {
+	ctx := q.ctx
+	if q.config.Lease > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.config.Lease)
+		defer cancel()
+	}
+
+	var err error
+	if job.MaxAttempts > 0 && job.Attempts > job.MaxAttempts {
+		err = Permanent(fmt.Errorf("exceeded %d attempts: %s", job.MaxAttempts, job.LastError))
+	} else {
+		err = w.call(ctx, job)
+	}
+
+	// 队列关闭时取消的任务不修改状态，占用时间结束之后会被再次执行。
+	if err != nil && q.ctx.Err() != nil {
+		return
+	}
+
+	bg := context.Background()
+	if err == nil {
+		if err = q.backend.Ack(bg, job); err != nil {
+			logger.WithContext(ctx).Errorf(log.ERROR, "jobqueue %s: ack job %s error: %v", job.Queue, job.ID, err)
+		}
+		return
+	}
+
+	job.LastError = err.Error()
+	var perm *permanentError
+	if errors.As(err, &perm) || (job.MaxAttempts > 0 && job.Attempts >= job.MaxAttempts) {
+		logger.WithContext(ctx).Warnf("jobqueue %s: job %s failed after %d attempts: %v", job.Queue, job.ID, job.Attempts, err)
+		err = q.backend.Fail(bg, job)
+	} else {
+		err = q.backend.Retry(bg, job, time.Now().Add(q.backoff(job.Attempts)))
+	}
+	if err != nil {
+		logger.WithContext(ctx).Errorf(log.ERROR, "jobqueue %s: update job %s error: %v", job.Queue, job.ID, err)
+	}
+}
+
+// backoff 返回第 attempts 次执行失败之后等待的时间。
+func (q *Queue) backoff(attempts int) time.Duration {
+	d := float64(q.config.Backoff) * math.Pow(q.config.Multiplier, float64(attempts-1))
+	if q.config.MaxBackoff > 0 && d > float64(q.config.MaxBackoff) {
+		return q.config.MaxBackoff
+	}
+	return time.Duration(d)
+}
+
+// Close 停止取出新的任务并等待正在执行的任务完成，ctx 结束时取消正在执行的任务，
+// 这些任务在占用时间结束之后会被再次执行。
+func (q *Queue) Close(ctx context.Context) error {
+	q.mutex.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.stop)
+	}
+	q.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// OnAppStart 应用启动后运行所有的 Worker 。
+func (q *Queue) OnAppStart(ctx gs.Context) {
+	q.Start()
+}
+
+// OnAppStop 应用退出时关闭任务队列。
+func (q *Queue) OnAppStop(ctx context.Context) {
+	if err := q.Close(ctx); err != nil {
+		logger.WithContext(ctx).Errorf(log.ERROR, "jobqueue close error: %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jobqueue_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/jobqueue"
+)
+
+type Mail struct {
+	To string `json:"to"`
+}
+
+func newConfig() jobqueue.Config {
+	config := jobqueue.NewConfig()
+	config.PollInterval = 10 * time.Millisecond
+	config.Backoff = 10 * time.Millisecond
+	return config
+}
+
+func waitFor(t *testing.T, fn func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestQueue(t *testing.T) {
+
+	var (
+		mutex sync.Mutex
+		sent  []string
+	)
+	w := jobqueue.NewWorker("email", func(ctx context.Context, m *Mail) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		sent = append(sent, m.To)
+		return nil
+	})
+
+	backend := jobqueue.NewMemoryBackend()
+	q, err := jobqueue.NewQueue(newConfig(), backend, []*jobqueue.Worker{w})
+	assert.Nil(t, err)
+	q.Start()
+
+	ctx := context.Background()
+	id, err := q.Enqueue(ctx, "email", &Mail{To: "jim@example.com"})
+	assert.Nil(t, err)
+	assert.NotEqual(t, id, "")
+
+	id, err = q.Enqueue(ctx, "email", &Mail{To: "tom@example.com"}, jobqueue.WithID("tom"))
+	assert.Nil(t, err)
+	assert.Equal(t, id, "tom")
+
+	waitFor(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(sent) == 2
+	})
+	assert.Nil(t, q.Close(ctx))
+	assert.Equal(t, len(backend.Failed()), 0)
+
+	_, err = q.Enqueue(ctx, "email", &Mail{})
+	assert.Equal(t, err, jobqueue.ErrQueueClosed)
+}
+
+func TestQueue_Retry(t *testing.T) {
+
+	var count int32
+	w := jobqueue.NewWorker("retry", func(ctx context.Context, job *jobqueue.Job) error {
+		if atomic.AddInt32(&count, 1) < 3 {
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+
+	backend := jobqueue.NewMemoryBackend()
+	q, err := jobqueue.NewQueue(newConfig(), backend, []*jobqueue.Worker{w})
+	assert.Nil(t, err)
+	q.Start()
+	defer q.Close(context.Background())
+
+	_, err = q.Enqueue(context.Background(), "retry", nil)
+	assert.Nil(t, err)
+	waitFor(t, func() bool { return atomic.LoadInt32(&count) == 3 })
+	assert.Equal(t, len(backend.Failed()), 0)
+}
+
+func TestQueue_Fail(t *testing.T) {
+
+	var panics, attempts int32
+	workers := []*jobqueue.Worker{
+		jobqueue.NewWorker("panic", func(ctx context.Context, job *jobqueue.Job) error {
+			atomic.AddInt32(&panics, 1)
+			panic("boom")
+		}),
+		jobqueue.NewWorker("permanent", func(ctx context.Context, job *jobqueue.Job) error {
+			atomic.AddInt32(&attempts, 1)
+			return jobqueue.Permanent(errors.New("bad request"))
+		}).MaxAttempts(10),
+		jobqueue.NewWorker("decode", func(ctx context.Context, m Mail) error {
+			return nil
+		}),
+	}
+
+	backend := jobqueue.NewMemoryBackend()
+	q, err := jobqueue.NewQueue(newConfig(), backend, workers)
+	assert.Nil(t, err)
+	q.Start()
+	defer q.Close(context.Background())
+
+	ctx := context.Background()
+	_, err = q.Enqueue(ctx, "panic", nil, jobqueue.MaxAttempts(2))
+	assert.Nil(t, err)
+	_, err = q.Enqueue(ctx, "permanent", nil)
+	assert.Nil(t, err)
+	_, err = q.Enqueue(ctx, "decode", "not a mail")
+	assert.Nil(t, err)
+
+	waitFor(t, func() bool { return len(backend.Failed()) == 3 })
+	assert.Equal(t, atomic.LoadInt32(&panics), int32(2))
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(1))
+
+	errs := make(map[string]string)
+	for _, job := range backend.Failed() {
+		errs[job.Queue] = job.LastError
+	}
+	assert.Equal(t, errs["panic"], "panic: boom")
+	assert.Equal(t, errs["permanent"], "bad request")
+	assert.Equal(t, errs["decode"], "json: cannot unmarshal string into Go value of type jobqueue_test.Mail")
+}
+
+func TestQueue_Delay(t *testing.T) {
+
+	done := make(chan time.Time, 1)
+	w := jobqueue.NewWorker("delay", func(ctx context.Context, job *jobqueue.Job) error {
+		done <- time.Now()
+		return nil
+	})
+
+	q, err := jobqueue.NewQueue(newConfig(), nil, []*jobqueue.Worker{w})
+	assert.Nil(t, err)
+	q.Start()
+	defer q.Close(context.Background())
+
+	start := time.Now()
+	_, err = q.Enqueue(context.Background(), "delay", nil, jobqueue.Delay(100*time.Millisecond))
+	assert.Nil(t, err)
+	select {
+	case at := <-done:
+		assert.True(t, at.Sub(start) >= 100*time.Millisecond)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+func TestQueue_Concurrency(t *testing.T) {
+
+	var running, max int32
+	release := make(chan struct{})
+	w := jobqueue.NewWorker("sync", func(ctx context.Context, job *jobqueue.Job) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt32(&running, -1)
+		return nil
+	}).Concurrency(2)
+
+	backend := jobqueue.NewMemoryBackend()
+	q, err := jobqueue.NewQueue(newConfig(), backend, []*jobqueue.Worker{w})
+	assert.Nil(t, err)
+	q.Start()
+
+	for i := 0; i < 5; i++ {
+		_, err = q.Enqueue(context.Background(), "sync", i)
+		assert.Nil(t, err)
+	}
+	waitFor(t, func() bool { return atomic.LoadInt32(&running) == 2 })
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, atomic.LoadInt32(&max), int32(2))
+	close(release)
+	assert.Nil(t, q.Close(context.Background()))
+}
+
+func TestQueue_Close(t *testing.T) {
+
+	started := make(chan struct{})
+	w := jobqueue.NewWorker("slow", func(ctx context.Context, job *jobqueue.Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	backend := jobqueue.NewMemoryBackend()
+	q, err := jobqueue.NewQueue(newConfig(), backend, []*jobqueue.Worker{w})
+	assert.Nil(t, err)
+	q.Start()
+
+	_, err = q.Enqueue(context.Background(), "slow", nil)
+	assert.Nil(t, err)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, q.Close(ctx), context.DeadlineExceeded)
+
+	// 被取消的任务保留在队列中，占用时间结束之后会被再次执行。
+	assert.Equal(t, len(backend.Failed()), 0)
+	job, err := backend.Dequeue(context.Background(), "slow", time.Minute)
+	assert.Nil(t, err)
+	assert.Nil(t, job)
+}
+
+func TestNewQueue(t *testing.T) {
+	fn := func(ctx context.Context, job *jobqueue.Job) error { return nil }
+	workers := []*jobqueue.Worker{jobqueue.NewWorker("a", fn), jobqueue.NewWorker("a", fn)}
+	_, err := jobqueue.NewQueue(newConfig(), nil, workers)
+	assert.Error(t, err, "duplicate worker for queue a")
+
+	assert.Panic(t, func() {
+		jobqueue.NewWorker("a", func(job *jobqueue.Job) {})
+	}, "fn should be func\\(context.Context, T\\) error")
+}
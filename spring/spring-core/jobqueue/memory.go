@@ -0,0 +1,112 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jobqueue
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryBackend 基于内存的 Backend ，任务不会被持久化，适用于开发和测试。
+type MemoryBackend struct {
+	mutex  sync.Mutex
+	jobs   map[string]map[string]*Job // 队列 -> 任务 ID -> 任务
+	failed map[string]*Job
+}
+
+// NewMemoryBackend 创建基于内存的 Backend 。
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		jobs:   make(map[string]map[string]*Job),
+		failed: make(map[string]*Job),
+	}
+}
+
+func (b *MemoryBackend) Enqueue(ctx context.Context, job *Job) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	m := b.jobs[job.Queue]
+	if m == nil {
+		m = make(map[string]*Job)
+		b.jobs[job.Queue] = m
+	}
+	j := *job
+	m[job.ID] = &j
+	return nil
+}
+
+func (b *MemoryBackend) Dequeue(ctx context.Context, queue string, lease time.Duration) (*Job, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+	var found *Job
+	for _, j := range b.jobs[queue] {
+		if j.RunAt.After(now) {
+			continue
+		}
+		if found == nil || j.RunAt.Before(found.RunAt) {
+			found = j
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+	found.Attempts++
+	found.RunAt = now.Add(lease)
+	j := *found
+	return &j, nil
+}
+
+func (b *MemoryBackend) Ack(ctx context.Context, job *Job) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.jobs[job.Queue], job.ID)
+	return nil
+}
+
+func (b *MemoryBackend) Retry(ctx context.Context, job *Job, runAt time.Time) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if j, ok := b.jobs[job.Queue][job.ID]; ok {
+		j.LastError = job.LastError
+		j.RunAt = runAt
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Fail(ctx context.Context, job *Job) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.jobs[job.Queue], job.ID)
+	j := *job
+	b.failed[job.ID] = &j
+	return nil
+}
+
+// Failed 返回失败列表中的任务，按照创建时间排序。
+func (b *MemoryBackend) Failed() []*Job {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	r := make([]*Job, 0, len(b.failed))
+	for _, j := range b.failed {
+		r = append(r, j)
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].CreatedAt.Before(r[j].CreatedAt) })
+	return r
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-spring/spring-core/redis"
+)
+
+// dequeueScript 原子地取出分数不大于当前时间的第一个任务，将分数设置为占用结束的
+// 时间并增加执行次数。KEYS: 队列、任务、执行次数，ARGV: 当前时间、占用结束的时间。
+const dequeueScript = `-- jobqueue:dequeue
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 1)
+if #ids == 0 then
+	return false
+end
+redis.call('ZADD', KEYS[1], ARGV[2], ids[1])
+local attempts = redis.call('HINCRBY', KEYS[3], ids[1], 1)
+return {ids[1], redis.call('HGET', KEYS[2], ids[1]), attempts}
+`
+
+// RedisConfig 基于 redis 的 Backend 的配置。
+type RedisConfig struct {
+	Prefix string `value:"${prefix:=jobqueue:}"`
+}
+
+// redisBackend 基于 redis 的 Backend ，每个队列使用一个有序集合保存任务 ID ，分数
+// 为任务可以被取出的时间，任务和执行次数分别保存在两个哈希表中。
+type redisBackend struct {
+	prefix string
+	client *redis.Client
+}
+
+// NewRedisBackend 创建基于 redis 的 Backend ，适用于多实例部署。
+func NewRedisBackend(config RedisConfig, client *redis.Client) Backend {
+	return &redisBackend{prefix: config.Prefix, client: client}
+}
+
+func (b *redisBackend) keys(queue string) (zset, jobs, attempts, failed string) {
+	k := b.prefix + queue
+	return k, k + ":jobs", k + ":attempts", k + ":failed"
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func (b *redisBackend) Enqueue(ctx context.Context, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	zset, jobs, _, _ := b.keys(job.Queue)
+	if _, err = b.client.OpsForHash().HSet(ctx, jobs, job.ID, string(data)); err != nil {
+		return err
+	}
+	_, err = b.client.OpsForZSet().ZAdd(ctx, zset, millis(job.RunAt), job.ID)
+	return err
+}
+
+func (b *redisBackend) Dequeue(ctx context.Context, queue string, lease time.Duration) (*Job, error) {
+	zset, jobs, attempts, _ := b.keys(queue)
+	now := time.Now()
+	r, err := b.client.Slice(ctx, "EVAL", dequeueScript, 3, zset, jobs, attempts, millis(now), millis(now.Add(lease)))
+	if redis.IsErrNil(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(r) == 0 {
+		return nil, nil
+	}
+	if len(r) != 3 {
+		return nil, fmt.Errorf("jobqueue: unexpected dequeue result %v", r)
+	}
+	id := toString(r[0])
+	data := toString(r[1])
+	if data == "" {
+		// 任务的数据已经不存在，删除残留的任务 ID 。
+		_, err = b.client.OpsForZSet().ZRem(ctx, zset, id)
+		return nil, err
+	}
+	job := new(Job)
+	if err = json.Unmarshal([]byte(data), job); err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(toString(r[2]))
+	if err != nil {
+		return nil, err
+	}
+	job.Attempts = n
+	job.RunAt = now.Add(lease)
+	return job, nil
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func (b *redisBackend) Ack(ctx context.Context, job *Job) error {
+	zset, jobs, attempts, _ := b.keys(job.Queue)
+	if _, err := b.client.OpsForZSet().ZRem(ctx, zset, job.ID); err != nil {
+		return err
+	}
+	if _, err := b.client.OpsForHash().HDel(ctx, jobs, job.ID); err != nil {
+		return err
+	}
+	_, err := b.client.OpsForHash().HDel(ctx, attempts, job.ID)
+	return err
+}
+
+func (b *redisBackend) Retry(ctx context.Context, job *Job, runAt time.Time) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	zset, jobs, _, _ := b.keys(job.Queue)
+	if _, err = b.client.OpsForHash().HSet(ctx, jobs, job.ID, string(data)); err != nil {
+		return err
+	}
+	_, err = b.client.OpsForZSet().ZAdd(ctx, zset, millis(runAt), job.ID)
+	return err
+}
+
+func (b *redisBackend) Fail(ctx context.Context, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, _, _, failed := b.keys(job.Queue)
+	if _, err = b.client.OpsForHash().HSet(ctx, failed, job.ID, string(data)); err != nil {
+		return err
+	}
+	return b.Ack(ctx, job)
+}
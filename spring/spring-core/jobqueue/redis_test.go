@@ -0,0 +1,143 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jobqueue_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/jobqueue"
+	"github.com/go-spring/spring-core/redis"
+)
+
+// connPool 只支持 HSET、HDEL、ZADD、ZREM 以及出队脚本的 redis.ConnPool 实现。
+type connPool struct {
+	hashes map[string]map[string]string
+	zsets  map[string]map[string]int64
+}
+
+func newConnPool() *connPool {
+	return &connPool{
+		hashes: make(map[string]map[string]string),
+		zsets:  make(map[string]map[string]int64),
+	}
+}
+
+func (p *connPool) hash(key string) map[string]string {
+	m, ok := p.hashes[key]
+	if !ok {
+		m = make(map[string]string)
+		p.hashes[key] = m
+	}
+	return m
+}
+
+func (p *connPool) zset(key string) map[string]int64 {
+	m, ok := p.zsets[key]
+	if !ok {
+		m = make(map[string]int64)
+		p.zsets[key] = m
+	}
+	return m
+}
+
+func (p *connPool) Exec(ctx context.Context, cmd string, args []interface{}) (interface{}, error) {
+	switch cmd {
+	case "HSET":
+		p.hash(args[0].(string))[args[1].(string)] = args[2].(string)
+		return int64(1), nil
+	case "HDEL":
+		delete(p.hash(args[0].(string)), args[1].(string))
+		return int64(1), nil
+	case "ZADD":
+		p.zset(args[0].(string))[args[2].(string)] = args[1].(int64)
+		return int64(1), nil
+	case "ZREM":
+		delete(p.zset(args[0].(string)), args[1].(string))
+		return int64(1), nil
+	case "EVAL":
+		if !strings.HasPrefix(args[0].(string), "-- jobqueue:dequeue") {
+			return nil, fmt.Errorf("unknown script %v", args[0])
+		}
+		zset, jobs, attempts := p.zset(args[2].(string)), p.hash(args[3].(string)), p.hash(args[4].(string))
+		var ids []string
+		for id, score := range zset {
+			if score <= args[5].(int64) {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			return nil, redis.ErrNil()
+		}
+		sort.Slice(ids, func(i, j int) bool { return zset[ids[i]] < zset[ids[j]] })
+		id := ids[0]
+		zset[id] = args[6].(int64)
+		n, _ := strconv.Atoi(attempts[id])
+		attempts[id] = strconv.Itoa(n + 1)
+		return []interface{}{id, jobs[id], int64(n + 1)}, nil
+	}
+	return nil, fmt.Errorf("unsupported command %s", cmd)
+}
+
+func TestRedisBackend(t *testing.T) {
+	pool := newConnPool()
+	c, err := redis.NewClient(pool)
+	assert.Nil(t, err)
+	b := jobqueue.NewRedisBackend(jobqueue.RedisConfig{Prefix: "jobqueue:"}, c)
+	ctx := context.Background()
+
+	now := time.Now()
+	err = b.Enqueue(ctx, &jobqueue.Job{ID: "1", Queue: "email", Payload: []byte(`{"to":"jim"}`), RunAt: now, CreatedAt: now})
+	assert.Nil(t, err)
+	err = b.Enqueue(ctx, &jobqueue.Job{ID: "2", Queue: "email", RunAt: now.Add(time.Hour), CreatedAt: now})
+	assert.Nil(t, err)
+
+	job, err := b.Dequeue(ctx, "email", time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, job.ID, "1")
+	assert.Equal(t, job.Attempts, 1)
+	assert.Equal(t, string(job.Payload), `{"to":"jim"}`)
+
+	// 被占用的任务和未到执行时间的任务都不能被取出。
+	job2, err := b.Dequeue(ctx, "email", time.Minute)
+	assert.Nil(t, err)
+	assert.Nil(t, job2)
+
+	job.LastError = "unavailable"
+	assert.Nil(t, b.Retry(ctx, job, now))
+	job, err = b.Dequeue(ctx, "email", time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, job.Attempts, 2)
+	assert.Equal(t, job.LastError, "unavailable")
+
+	assert.Nil(t, b.Fail(ctx, job))
+	assert.Equal(t, len(pool.hashes["jobqueue:email:failed"]), 1)
+	assert.Equal(t, len(pool.hashes["jobqueue:email:jobs"]), 1)
+	assert.Equal(t, len(pool.hashes["jobqueue:email:attempts"]), 0)
+	assert.Equal(t, len(pool.zsets["jobqueue:email"]), 1)
+
+	job = &jobqueue.Job{ID: "2", Queue: "email"}
+	assert.Nil(t, b.Ack(ctx, job))
+	assert.Equal(t, len(pool.hashes["jobqueue:email:jobs"]), 0)
+	assert.Equal(t, len(pool.zsets["jobqueue:email"]), 0)
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jobqueue
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/go-spring/spring-core/internal/sqlutil"
+)
+
+// SQLConfig 基于数据库的 Backend 的配置。
+type SQLConfig struct {
+	Table       string `value:"${table:=jobs}"`
+	Placeholder string `value:"${placeholder:=?}"` // 参数占位符，PostgreSQL 使用 $
+}
+
+// sqlBackend 基于数据库的 Backend ，使用 run_at 做乐观锁保证同一个任务只能被一个实例占用。
+type sqlBackend struct {
+	db     *sql.DB
+	config SQLConfig
+}
+
+// dequeueBatch 每次取出的候选任务的数量，减少多个实例之间的冲突。
+const dequeueBatch = 10
+
+// NewSQLBackend 创建基于数据库的 Backend ，适用于多实例部署，任务表的结构如下(MySQL):
+//
+//	CREATE TABLE jobs (
+//	    id           VARCHAR(64) PRIMARY KEY,
+//	    queue        VARCHAR(255) NOT NULL,
+//	    payload      BLOB,
+//	    attempts     INT NOT NULL DEFAULT 0,
+//	    max_attempts INT NOT NULL DEFAULT 0,
+//	    run_at       BIGINT NOT NULL,
+//	    last_error   TEXT,
+//	    created_at   BIGINT NOT NULL,
+//	    failed_at    BIGINT NOT NULL DEFAULT 0,
+//	    INDEX idx_queue_run_at (queue, failed_at, run_at)
+//	);
+func NewSQLBackend(config SQLConfig, db *sql.DB) Backend {
+	return &sqlBackend{db: db, config: config}
+}
+
+// bind 将 SQL 语句中的 ? 替换为配置的占位符。
+func (b *sqlBackend) bind(query string) string {
+	return sqlutil.Bind(query, b.config.Placeholder)
+}
+
+func (b *sqlBackend) exec(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	result, err := b.db.ExecContext(ctx, b.bind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (b *sqlBackend) Enqueue(ctx context.Context, job *Job) error {
+	query := fmt.Sprintf("INSERT INTO %s (id, queue, payload, attempts, max_attempts, run_at, last_error, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", b.config.Table)
+	_, err := b.exec(ctx, query, job.ID, job.Queue, []byte(job.Payload), job.Attempts, job.MaxAttempts, millis(job.RunAt), job.LastError, millis(job.CreatedAt))
+	return err
+}
+
+func (b *sqlBackend) Dequeue(ctx context.Context, queue string, lease time.Duration) (*Job, error) {
+	now := time.Now()
+	query := fmt.Sprintf("SELECT id, payload, attempts, max_attempts, run_at, last_error, created_at FROM %s WHERE queue = ? AND failed_at = 0 AND run_at <= ? ORDER BY run_at LIMIT %d", b.config.Table, dequeueBatch)
+	rows, err := b.db.QueryContext(ctx, b.bind(query), queue, millis(now))
+	if err != nil {
+		return nil, err
+	}
+	var (
+		jobs  []*Job
+		runAt []int64
+	)
+	for rows.Next() {
+		var (
+			job       = &Job{Queue: queue}
+			payload   []byte
+			lastError sql.NullString
+			at        int64
+			createdAt int64
+		)
+		if err = rows.Scan(&job.ID, &payload, &job.Attempts, &job.MaxAttempts, &at, &lastError, &createdAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		job.Payload = payload
+		job.LastError = lastError.String
+		job.CreatedAt = fromMillis(createdAt)
+		jobs = append(jobs, job)
+		runAt = append(runAt, at)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	until := now.Add(lease)
+	update := fmt.Sprintf("UPDATE %s SET run_at = ?, attempts = attempts + 1 WHERE id = ? AND run_at = ? AND failed_at = 0", b.config.Table)
+	for i, job := range jobs {
+		n, err := b.exec(ctx, update, millis(until), job.ID, runAt[i])
+		if err != nil {
+			return nil, err
+		}
+		if n == 1 {
+			job.Attempts++
+			job.RunAt = until
+			return job, nil
+		}
+	}
+	return nil, nil
+}
+
+func fromMillis(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+func (b *sqlBackend) Ack(ctx context.Context, job *Job) error {
+	_, err := b.exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = ?", b.config.Table), job.ID)
+	return err
+}
+
+func (b *sqlBackend) Retry(ctx context.Context, job *Job, runAt time.Time) error {
+	query := fmt.Sprintf("UPDATE %s SET run_at = ?, last_error = ? WHERE id = ?", b.config.Table)
+	_, err := b.exec(ctx, query, millis(runAt), job.LastError, job.ID)
+	return err
+}
+
+func (b *sqlBackend) Fail(ctx context.Context, job *Job) error {
+	query := fmt.Sprintf("UPDATE %s SET failed_at = ?, last_error = ? WHERE id = ?", b.config.Table)
+	_, err := b.exec(ctx, query, millis(time.Now()), job.LastError, job.ID)
+	return err
+}
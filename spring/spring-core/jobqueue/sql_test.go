@@ -0,0 +1,75 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jobqueue_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/jobqueue"
+)
+
+func TestSQLBackend(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	b := jobqueue.NewSQLBackend(jobqueue.SQLConfig{Table: "jobs", Placeholder: "$"}, db)
+	ctx := context.Background()
+
+	now := time.Now()
+	mock.ExpectExec(`INSERT INTO jobs \(id, queue, payload, attempts, max_attempts, run_at, last_error, created_at\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8\)`).
+		WithArgs("1", "email", []byte(`{}`), 0, 5, sqlmock.AnyArg(), "", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	err = b.Enqueue(ctx, &jobqueue.Job{ID: "1", Queue: "email", Payload: []byte(`{}`), MaxAttempts: 5, RunAt: now, CreatedAt: now})
+	assert.Nil(t, err)
+
+	// 第一个候选任务已经被其他实例占用，取出第二个。
+	columns := []string{"id", "payload", "attempts", "max_attempts", "run_at", "last_error", "created_at"}
+	mock.ExpectQuery(`SELECT id, payload, attempts, max_attempts, run_at, last_error, created_at FROM jobs WHERE queue = \$1 AND failed_at = 0 AND run_at <= \$2 ORDER BY run_at LIMIT 10`).
+		WithArgs("email", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("1", []byte(`{}`), 0, 5, 100, nil, 100).
+			AddRow("2", []byte(`[]`), 1, 5, 200, "unavailable", 100))
+	update := `UPDATE jobs SET run_at = \$1, attempts = attempts \+ 1 WHERE id = \$2 AND run_at = \$3 AND failed_at = 0`
+	mock.ExpectExec(update).WithArgs(sqlmock.AnyArg(), "1", 100).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(update).WithArgs(sqlmock.AnyArg(), "2", 200).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	job, err := b.Dequeue(ctx, "email", time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, job.ID, "2")
+	assert.Equal(t, job.Attempts, 2)
+	assert.Equal(t, job.LastError, "unavailable")
+	assert.Equal(t, string(job.Payload), `[]`)
+
+	mock.ExpectExec(`UPDATE jobs SET run_at = \$1, last_error = \$2 WHERE id = \$3`).
+		WithArgs(sqlmock.AnyArg(), "timeout", "2").WillReturnResult(sqlmock.NewResult(0, 1))
+	job.LastError = "timeout"
+	assert.Nil(t, b.Retry(ctx, job, now))
+
+	mock.ExpectExec(`UPDATE jobs SET failed_at = \$1, last_error = \$2 WHERE id = \$3`).
+		WithArgs(sqlmock.AnyArg(), "timeout", "2").WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.Nil(t, b.Fail(ctx, job))
+
+	mock.ExpectExec(`DELETE FROM jobs WHERE id = \$1`).WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.Nil(t, b.Ack(ctx, &jobqueue.Job{ID: "1"}))
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
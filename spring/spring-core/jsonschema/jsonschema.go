@@ -0,0 +1,334 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package jsonschema 提供了 JSON Schema 校验，支持 draft-07 中常用的关键字，用于
+// 结构体标签无法表达的请求体校验。
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Schema 编译后的 JSON Schema 。
+type Schema struct {
+	always *bool // 布尔形式的 schema
+
+	types    []string
+	enum     []interface{}
+	constVal interface{}
+	hasConst bool
+	format   string
+
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	minProperties        *int
+	maxProperties        *int
+
+	items       *Schema
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       *float64
+
+	allOf []*Schema
+	anyOf []*Schema
+	oneOf []*Schema
+	not   *Schema
+	ref   *Schema
+}
+
+// Compile 编译 JSON 格式的 schema ，$ref 只支持以 # 开头的本地引用。
+func Compile(data []byte) (*Schema, error) {
+	var doc interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		return nil, err
+	}
+	c := &compiler{root: doc, cache: make(map[string]*Schema)}
+	return c.compileRef("#")
+}
+
+// CompileFile 编译文件中的 schema 。
+func CompileFile(file string) (*Schema, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Compile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return s, nil
+}
+
+// MustCompile 编译字符串形式的 schema ，出错时 panic ，通常用于包初始化。
+func MustCompile(schema string) *Schema {
+	s, err := Compile([]byte(schema))
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// MustCompileFile 编译文件中的 schema ，出错时 panic ，通常用于包初始化。
+func MustCompileFile(file string) *Schema {
+	s, err := CompileFile(file)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// compiler 编译 schema 文档，已经编译的本地引用被缓存下来，以便支持递归的 schema 。
+type compiler struct {
+	root  interface{}
+	cache map[string]*Schema
+}
+
+// compileRef 编译本地引用指向的 schema ，例如 #/definitions/address 。
+func (c *compiler) compileRef(ref string) (*Schema, error) {
+	if s, ok := c.cache[ref]; ok {
+		return s, nil
+	}
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	v := c.root
+	if p := strings.TrimPrefix(ref, "#"); p != "" {
+		for _, token := range strings.Split(strings.TrimPrefix(p, "/"), "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			switch x := v.(type) {
+			case map[string]interface{}:
+				v = x[token]
+			case []interface{}:
+				i, err := strconv.Atoi(token)
+				if err != nil || i < 0 || i >= len(x) {
+					return nil, fmt.Errorf("invalid $ref %q", ref)
+				}
+				v = x[i]
+			default:
+				v = nil
+			}
+			if v == nil {
+				return nil, fmt.Errorf("invalid $ref %q", ref)
+			}
+		}
+	}
+	s := new(Schema)
+	c.cache[ref] = s
+	if err := c.compile(s, v); err != nil {
+		delete(c.cache, ref)
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *compiler) compileValue(v interface{}) (*Schema, error) {
+	s := new(Schema)
+	if err := c.compile(s, v); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *compiler) compileArray(key string, v interface{}) ([]*Schema, error) {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array", key)
+	}
+	var r []*Schema
+	for _, e := range arr {
+		s, err := c.compileValue(e)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, s)
+	}
+	return r, nil
+}
+
+func (c *compiler) compile(s *Schema, v interface{}) error {
+
+	if b, ok := v.(bool); ok {
+		s.always = &b
+		return nil
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("schema must be an object or a boolean")
+	}
+
+	var err error
+	for key, val := range m {
+		switch key {
+		case "$ref":
+			ref, _ := val.(string)
+			if s.ref, err = c.compileRef(ref); err != nil {
+				return err
+			}
+		case "type":
+			switch t := val.(type) {
+			case string:
+				s.types = []string{t}
+			case []interface{}:
+				for _, e := range t {
+					str, _ := e.(string)
+					s.types = append(s.types, str)
+				}
+			default:
+				return fmt.Errorf("type must be a string or an array")
+			}
+		case "enum":
+			arr, ok := val.([]interface{})
+			if !ok {
+				return fmt.Errorf("enum must be an array")
+			}
+			s.enum = arr
+		case "const":
+			s.constVal, s.hasConst = val, true
+		case "format":
+			s.format, _ = val.(string)
+		case "properties":
+			props, ok := val.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("properties must be an object")
+			}
+			s.properties = make(map[string]*Schema)
+			for name, p := range props {
+				if s.properties[name], err = c.compileValue(p); err != nil {
+					return fmt.Errorf("properties.%s: %w", name, err)
+				}
+			}
+		case "required":
+			arr, ok := val.([]interface{})
+			if !ok {
+				return fmt.Errorf("required must be an array")
+			}
+			for _, e := range arr {
+				str, _ := e.(string)
+				s.required = append(s.required, str)
+			}
+		case "additionalProperties":
+			if s.additionalProperties, err = c.compileValue(val); err != nil {
+				return fmt.Errorf("additionalProperties: %w", err)
+			}
+		case "items":
+			if s.items, err = c.compileValue(val); err != nil {
+				return fmt.Errorf("items: %w", err)
+			}
+		case "uniqueItems":
+			s.uniqueItems, _ = val.(bool)
+		case "pattern":
+			str, _ := val.(string)
+			if s.pattern, err = regexp.Compile(str); err != nil {
+				return fmt.Errorf("pattern: %w", err)
+			}
+		case "minProperties", "maxProperties", "minItems", "maxItems", "minLength", "maxLength":
+			n, err := toInt(val)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			switch key {
+			case "minProperties":
+				s.minProperties = &n
+			case "maxProperties":
+				s.maxProperties = &n
+			case "minItems":
+				s.minItems = &n
+			case "maxItems":
+				s.maxItems = &n
+			case "minLength":
+				s.minLength = &n
+			case "maxLength":
+				s.maxLength = &n
+			}
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf":
+			f, ok := toFloat(val)
+			if !ok {
+				return fmt.Errorf("%s must be a number", key)
+			}
+			switch key {
+			case "minimum":
+				s.minimum = &f
+			case "maximum":
+				s.maximum = &f
+			case "exclusiveMinimum":
+				s.exclusiveMinimum = &f
+			case "exclusiveMaximum":
+				s.exclusiveMaximum = &f
+			case "multipleOf":
+				if f <= 0 {
+					return fmt.Errorf("multipleOf must be greater than 0")
+				}
+				s.multipleOf = &f
+			}
+		case "allOf":
+			if s.allOf, err = c.compileArray(key, val); err != nil {
+				return err
+			}
+		case "anyOf":
+			if s.anyOf, err = c.compileArray(key, val); err != nil {
+				return err
+			}
+		case "oneOf":
+			if s.oneOf, err = c.compileArray(key, val); err != nil {
+				return err
+			}
+		case "not":
+			if s.not, err = c.compileValue(val); err != nil {
+				return fmt.Errorf("not: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func toInt(v interface{}) (int, error) {
+	f, ok := toFloat(v)
+	if !ok || f < 0 || f != float64(int(f)) {
+		return 0, fmt.Errorf("must be a non-negative integer")
+	}
+	return int(f), nil
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsonschema_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/jsonschema"
+)
+
+const orderSchema = `{
+	"type": "object",
+	"required": ["id", "items"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "string", "format": "uuid"},
+		"email": {"type": "string", "format": "email"},
+		"status": {"enum": ["new", "paid"]},
+		"coupon": {"type": ["string", "null"], "pattern": "^[A-Z]{4}$"},
+		"items": {
+			"type": "array",
+			"minItems": 1,
+			"uniqueItems": true,
+			"items": {"$ref": "#/definitions/item"}
+		},
+		"payment": {
+			"oneOf": [
+				{"type": "object", "required": ["card"]},
+				{"type": "object", "required": ["wallet"]}
+			]
+		}
+	},
+	"definitions": {
+		"item": {
+			"type": "object",
+			"required": ["sku", "quantity"],
+			"properties": {
+				"sku": {"type": "string", "minLength": 3, "maxLength": 8},
+				"quantity": {"type": "integer", "minimum": 1, "exclusiveMaximum": 100},
+				"price": {"type": "number", "multipleOf": 0.01, "not": {"const": 0}}
+			}
+		}
+	}
+}`
+
+func TestSchema(t *testing.T) {
+
+	s := jsonschema.MustCompile(orderSchema)
+
+	err := s.ValidateJSON([]byte(`{
+		"id": "0d9ad123-327f-bde5-14b4-8f93c36c3546",
+		"email": "jim@example.com",
+		"status": "paid",
+		"coupon": null,
+		"items": [{"sku": "abc", "quantity": 2, "price": 9.99}],
+		"payment": {"card": "4111"}
+	}`))
+	assert.Nil(t, err)
+
+	err = s.ValidateJSON([]byte(`{
+		"id": "1",
+		"email": "jim",
+		"status": "closed",
+		"coupon": "abc",
+		"items": [{"sku": "ab", "quantity": 1.5, "price": 0}, {"sku": "abcdefghi", "quantity": 100}],
+		"payment": {"card": "4111", "wallet": "w"},
+		"extra/key": true
+	}`))
+	e, ok := err.(*jsonschema.ValidationError)
+	assert.True(t, ok)
+	assert.Equal(t, e.Violations, []jsonschema.Violation{
+		{Path: "/coupon", Keyword: "pattern", Message: `value must match pattern "^[A-Z]{4}$"`},
+		{Path: "/email", Keyword: "format", Message: "value is not a valid email"},
+		{Path: "/extra~1key", Keyword: "additionalProperties", Message: "property is not allowed"},
+		{Path: "/id", Keyword: "format", Message: "value is not a valid uuid"},
+		{Path: "/items/0/price", Keyword: "not", Message: "value must not match the schema"},
+		{Path: "/items/0/quantity", Keyword: "type", Message: "expected integer but got number"},
+		{Path: "/items/0/sku", Keyword: "minLength", Message: "length must be >= 3 but got 2"},
+		{Path: "/items/1/quantity", Keyword: "exclusiveMaximum", Message: "value must be < 100"},
+		{Path: "/items/1/sku", Keyword: "maxLength", Message: "length must be <= 8 but got 9"},
+		{Path: "/payment", Keyword: "oneOf", Message: "value must match exactly one schema but matches 2"},
+		{Path: "/status", Keyword: "enum", Message: `value must be one of ["new","paid"]`},
+	})
+
+	err = s.ValidateJSON([]byte(`{"items": [{"sku": "abc", "quantity": 1}, {"sku": "abc", "quantity": 1}]}`))
+	assert.Error(t, err, `/: missing property "id"; /items: items at 0 and 1 are equal`)
+
+	err = s.Validate(nil)
+	assert.Error(t, err, "/: expected object but got null")
+
+	err = s.ValidateJSON([]byte(`{`))
+	assert.Error(t, err, "unexpected EOF")
+}
+
+func TestRecursiveSchema(t *testing.T) {
+	s := jsonschema.MustCompile(`{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string"},
+			"children": {"type": "array", "items": {"$ref": "#"}}
+		}
+	}`)
+	assert.Nil(t, s.ValidateJSON([]byte(`{"name": "a", "children": [{"name": "b", "children": []}]}`)))
+	err := s.ValidateJSON([]byte(`{"children": [{"children": [{"name": 1}]}]}`))
+	assert.Error(t, err, "/children/0/children/0/name: expected string but got integer")
+}
+
+func TestCompile(t *testing.T) {
+
+	_, err := jsonschema.Compile([]byte(`{"$ref": "#/definitions/missing"}`))
+	assert.Error(t, err, `invalid \$ref "#/definitions/missing"`)
+
+	_, err = jsonschema.Compile([]byte(`{"$ref": "http://example.com/schema.json"}`))
+	assert.Error(t, err, `unsupported \$ref`)
+
+	_, err = jsonschema.Compile([]byte(`{"properties": {"a": {"minLength": -1}}}`))
+	assert.Error(t, err, "properties.a: minLength: must be a non-negative integer")
+
+	_, err = jsonschema.Compile([]byte(`1`))
+	assert.Error(t, err, "schema must be an object or a boolean")
+
+	dir, err := ioutil.TempDir("", "jsonschema")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "schema.json")
+	err = ioutil.WriteFile(file, []byte(`{"type": "string"}`), 0644)
+	assert.Nil(t, err)
+	s, err := jsonschema.CompileFile(file)
+	assert.Nil(t, err)
+	assert.Nil(t, s.ValidateJSON([]byte(`"a"`)))
+
+	s = jsonschema.MustCompile(`false`)
+	assert.Error(t, s.Validate(1), "value is not allowed")
+}
@@ -0,0 +1,373 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Violation 不满足 schema 的一处数据。
+type Violation struct {
+	Path    string `json:"path"`    // 数据的 JSON Pointer ，根节点为空字符串
+	Keyword string `json:"keyword"` // 不满足的关键字
+	Message string `json:"message"`
+}
+
+// ValidationError 数据不满足 schema 时返回的错误，包含所有的 Violation 。
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	var sb strings.Builder
+	for i, v := range e.Violations {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+		path := v.Path
+		if path == "" {
+			path = "/"
+		}
+		sb.WriteString(path + ": " + v.Message)
+	}
+	return sb.String()
+}
+
+// ValidateJSON 校验 JSON 格式的数据，数据不满足 schema 时返回 *ValidationError 。
+func (s *Schema) ValidateJSON(data []byte) error {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return err
+	}
+	return s.Validate(v)
+}
+
+// Validate 校验 json.Unmarshal 得到的数据，数据不满足 schema 时返回 *ValidationError 。
+func (s *Schema) Validate(v interface{}) error {
+	var r []Violation
+	s.validate("", v, &r)
+	if len(r) > 0 {
+		return &ValidationError{Violations: r}
+	}
+	return nil
+}
+
+// valid 判断数据是否满足 schema ，用于 anyOf 、oneOf 和 not 关键字。
+func (s *Schema) valid(v interface{}) bool {
+	var r []Violation
+	s.validate("", v, &r)
+	return len(r) == 0
+}
+
+func (s *Schema) validate(path string, v interface{}, r *[]Violation) {
+
+	add := func(keyword string, format string, args ...interface{}) {
+		*r = append(*r, Violation{Path: path, Keyword: keyword, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.always != nil {
+		if !*s.always {
+			add("false", "value is not allowed")
+		}
+		return
+	}
+
+	if s.ref != nil {
+		s.ref.validate(path, v, r)
+	}
+
+	if len(s.types) > 0 {
+		t := typeOf(v)
+		found := false
+		for _, e := range s.types {
+			if e == t || (e == "number" && t == "integer") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			add("type", "expected %s but got %s", strings.Join(s.types, " or "), t)
+			return
+		}
+	}
+
+	if len(s.enum) > 0 {
+		found := false
+		for _, e := range s.enum {
+			if equal(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			add("enum", "value must be one of %s", toJSON(s.enum))
+		}
+	}
+
+	if s.hasConst && !equal(s.constVal, v) {
+		add("const", "value must be %s", toJSON(s.constVal))
+	}
+
+	for _, e := range s.allOf {
+		e.validate(path, v, r)
+	}
+
+	if len(s.anyOf) > 0 {
+		found := false
+		for _, e := range s.anyOf {
+			if e.valid(v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			add("anyOf", "value must match at least one schema")
+		}
+	}
+
+	if len(s.oneOf) > 0 {
+		n := 0
+		for _, e := range s.oneOf {
+			if e.valid(v) {
+				n++
+			}
+		}
+		if n != 1 {
+			add("oneOf", "value must match exactly one schema but matches %d", n)
+		}
+	}
+
+	if s.not != nil && s.not.valid(v) {
+		add("not", "value must not match the schema")
+	}
+
+	switch x := v.(type) {
+	case string:
+		s.validateString(x, add)
+	case map[string]interface{}:
+		s.validateObject(path, x, r, add)
+	case []interface{}:
+		s.validateArray(path, x, r, add)
+	default:
+		if f, ok := toFloat(v); ok {
+			s.validateNumber(f, add)
+		}
+	}
+}
+
+type addFunc func(keyword string, format string, args ...interface{})
+
+func (s *Schema) validateString(str string, add addFunc) {
+	n := utf8.RuneCountInString(str)
+	if s.minLength != nil && n < *s.minLength {
+		add("minLength", "length must be >= %d but got %d", *s.minLength, n)
+	}
+	if s.maxLength != nil && n > *s.maxLength {
+		add("maxLength", "length must be <= %d but got %d", *s.maxLength, n)
+	}
+	if s.pattern != nil && !s.pattern.MatchString(str) {
+		add("pattern", "value must match pattern %q", s.pattern.String())
+	}
+	if s.format != "" && !checkFormat(s.format, str) {
+		add("format", "value is not a valid %s", s.format)
+	}
+}
+
+func (s *Schema) validateNumber(f float64, add addFunc) {
+	format := func(f float64) string { return strconv.FormatFloat(f, 'g', -1, 64) }
+	if s.minimum != nil && f < *s.minimum {
+		add("minimum", "value must be >= %s", format(*s.minimum))
+	}
+	if s.maximum != nil && f > *s.maximum {
+		add("maximum", "value must be <= %s", format(*s.maximum))
+	}
+	if s.exclusiveMinimum != nil && f <= *s.exclusiveMinimum {
+		add("exclusiveMinimum", "value must be > %s", format(*s.exclusiveMinimum))
+	}
+	if s.exclusiveMaximum != nil && f >= *s.exclusiveMaximum {
+		add("exclusiveMaximum", "value must be < %s", format(*s.exclusiveMaximum))
+	}
+	if s.multipleOf != nil {
+		if q := f / *s.multipleOf; math.Abs(q-math.Round(q)) > 1e-9 {
+			add("multipleOf", "value must be a multiple of %s", format(*s.multipleOf))
+		}
+	}
+}
+
+func (s *Schema) validateObject(path string, m map[string]interface{}, r *[]Violation, add addFunc) {
+	if s.minProperties != nil && len(m) < *s.minProperties {
+		add("minProperties", "object must have at least %d properties", *s.minProperties)
+	}
+	if s.maxProperties != nil && len(m) > *s.maxProperties {
+		add("maxProperties", "object must have at most %d properties", *s.maxProperties)
+	}
+	for _, name := range s.required {
+		if _, ok := m[name]; !ok {
+			add("required", "missing property %q", name)
+		}
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := path + "/" + strings.ReplaceAll(strings.ReplaceAll(k, "~", "~0"), "/", "~1")
+		if e, ok := s.properties[k]; ok {
+			e.validate(p, m[k], r)
+		} else if s.additionalProperties != nil {
+			if a := s.additionalProperties.always; a != nil && !*a {
+				*r = append(*r, Violation{Path: p, Keyword: "additionalProperties", Message: "property is not allowed"})
+				continue
+			}
+			s.additionalProperties.validate(p, m[k], r)
+		}
+	}
+}
+
+func (s *Schema) validateArray(path string, arr []interface{}, r *[]Violation, add addFunc) {
+	if s.minItems != nil && len(arr) < *s.minItems {
+		add("minItems", "array must have at least %d items", *s.minItems)
+	}
+	if s.maxItems != nil && len(arr) > *s.maxItems {
+		add("maxItems", "array must have at most %d items", *s.maxItems)
+	}
+	if s.uniqueItems {
+	loop:
+		for i := 1; i < len(arr); i++ {
+			for j := 0; j < i; j++ {
+				if equal(arr[i], arr[j]) {
+					add("uniqueItems", "items at %d and %d are equal", j, i)
+					break loop
+				}
+			}
+		}
+	}
+	if s.items != nil {
+		for i, e := range arr {
+			s.items.validate(path+"/"+strconv.Itoa(i), e, r)
+		}
+	}
+}
+
+// typeOf 返回数据的 JSON 类型，没有小数部分的数字为 integer 。
+func typeOf(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		if f, ok := toFloat(x); ok {
+			if f == math.Trunc(f) && !math.IsInf(f, 0) {
+				return "integer"
+			}
+			return "number"
+		}
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// equal 判断两个 JSON 数据是否相等，数字按照数值比较。
+func equal(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			if w, ok := y[k]; !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func toJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// checkFormat 校验字符串的格式，不支持的格式总是返回 true 。
+func checkFormat(format string, s string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", s)
+		return err == nil
+	case "time":
+		_, err := time.Parse("15:04:05Z07:00", s)
+		return err == nil
+	case "email":
+		a, err := mail.ParseAddress(s)
+		return err == nil && a.Address == s
+	case "uri":
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != ""
+	case "uuid":
+		return uuidRegexp.MatchString(s)
+	case "ipv4":
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && strings.Contains(s, ".")
+	case "ipv6":
+		ip := net.ParseIP(s)
+		return ip != nil && strings.Contains(s, ":")
+	}
+	return true
+}
@@ -0,0 +1,274 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package metrics 是不依赖第三方库的指标注册表，支持计数器、仪表盘和直方图，并且以
+// Prometheus 的文本格式输出。每个指标都可以通过 metrics.meters.{name}.* 属性单独
+// 关闭、调整直方图的桶或者限制保留的标签，不需要修改代码就可以控制指标的基数，例如：
+//
+//	metrics.meters.http_server_requests_seconds.buckets=0.05,0.1,0.5,1
+//	metrics.meters.http_server_requests_seconds.labels=method,status
+//
+// 注册为 bean 的 MeterCustomizer 在属性之后调整指标的选项，例如：
+//
+//	gs.Provide(metrics.New, "${metrics}", "*?")
+package metrics
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets 直方图默认的桶，单位为秒，适合统计请求耗时。
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var validName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// MeterConfig 单个指标的配置。
+type MeterConfig struct {
+	Enabled bool      `value:"${enabled:=true}"`
+	Buckets []float64 `value:"${buckets:=}"` // 直方图的桶，为空时使用创建指标时指定的桶
+	Labels  []string  `value:"${labels:=}"`  // 保留的标签，为空时保留所有标签，其他标签的取值被合并
+}
+
+// Config 指标配置，通常配合 metrics 前缀一起使用。
+type Config struct {
+	Enabled bool                   `value:"${enabled:=true}"` // 为 false 时关闭所有指标
+	Path    string                 `value:"${path:=/metrics}"`
+	Meters  map[string]MeterConfig `value:"${meters:=}"`
+}
+
+// MeterOptions 创建指标时使用的选项。
+type MeterOptions struct {
+	Name     string
+	Help     string
+	Labels   []string  // 保留的标签
+	Buckets  []float64 // 直方图的桶，其他指标忽略
+	Disabled bool
+}
+
+// MeterCustomizer 在属性配置之后调整指标的选项，例如为某些指标关闭标签或者设置桶。
+type MeterCustomizer interface {
+	Customize(opts *MeterOptions)
+}
+
+// MeterCustomizerFunc 函数形式的 MeterCustomizer 。
+type MeterCustomizerFunc func(opts *MeterOptions)
+
+func (f MeterCustomizerFunc) Customize(opts *MeterOptions) { f(opts) }
+
+type kind string
+
+const (
+	counterKind   = kind("counter")
+	gaugeKind     = kind("gauge")
+	histogramKind = kind("histogram")
+)
+
+// series 指标的一组标签取值对应的数据。
+type series struct {
+	values []string
+	value  float64  // 计数器和仪表盘的值
+	counts []uint64 // 直方图每个桶的计数，不累加
+	sum    float64
+	count  uint64
+}
+
+// meter 指标的公共实现，labels 是创建时声明的标签，keep 是保留的标签的下标。
+type meter struct {
+	kind   kind
+	opts   MeterOptions
+	labels []string
+	keep   []int
+	mutex  sync.Mutex
+	series map[string]*series
+}
+
+func (m *meter) get(values []string) *series {
+	if len(values) != len(m.labels) {
+		panic(fmt.Errorf("metrics: %s expects %d label values but got %d", m.opts.Name, len(m.labels), len(values)))
+	}
+	kept := make([]string, len(m.keep))
+	for i, j := range m.keep {
+		kept[i] = values[j]
+	}
+	key := strings.Join(kept, "\xff")
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{values: kept}
+		if m.kind == histogramKind {
+			s.counts = make([]uint64, len(m.opts.Buckets))
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+// Counter 只增不减的计数器。
+type Counter struct{ m *meter }
+
+// Inc 将标签取值对应的计数加 1 ，标签取值的数量必须和创建时声明的标签一致。
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add 将标签取值对应的计数增加 v ，v 不能为负数。
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic(fmt.Errorf("metrics: counter %s can't decrease", c.m.opts.Name))
+	}
+	if c.m.opts.Disabled {
+		return
+	}
+	c.m.mutex.Lock()
+	defer c.m.mutex.Unlock()
+	c.m.get(values).value += v
+}
+
+// Gauge 可增可减的仪表盘。
+type Gauge struct{ m *meter }
+
+// Set 设置标签取值对应的值。合并了标签取值的仪表盘以最后一次设置的值为准。
+func (g *Gauge) Set(v float64, values ...string) {
+	if g.m.opts.Disabled {
+		return
+	}
+	g.m.mutex.Lock()
+	defer g.m.mutex.Unlock()
+	g.m.get(values).value = v
+}
+
+// Add 将标签取值对应的值增加 v ，v 可以为负数。
+func (g *Gauge) Add(v float64, values ...string) {
+	if g.m.opts.Disabled {
+		return
+	}
+	g.m.mutex.Lock()
+	defer g.m.mutex.Unlock()
+	g.m.get(values).value += v
+}
+
+// Histogram 按照桶统计观测值的分布。
+type Histogram struct{ m *meter }
+
+// Observe 记录标签取值对应的一个观测值。
+func (h *Histogram) Observe(v float64, values ...string) {
+	if h.m.opts.Disabled {
+		return
+	}
+	h.m.mutex.Lock()
+	defer h.m.mutex.Unlock()
+	s := h.m.get(values)
+	if i := sort.SearchFloat64s(h.m.opts.Buckets, v); i < len(s.counts) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+// Registry 指标注册表。
+type Registry struct {
+	config      Config
+	customizers []MeterCustomizer
+	mutex       sync.Mutex
+	meters      map[string]*meter
+}
+
+// New 创建指标注册表，customizers 按照顺序调整每个指标的选项。
+func New(config Config, customizers []MeterCustomizer) *Registry {
+	return &Registry{
+		config:      config,
+		customizers: customizers,
+		meters:      make(map[string]*meter),
+	}
+}
+
+// Config 返回指标配置。
+func (r *Registry) Config() Config {
+	return r.config
+}
+
+// Counter 创建或者返回已经创建的计数器。
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r.meter(counterKind, name, help, nil, labels)}
+}
+
+// Gauge 创建或者返回已经创建的仪表盘。
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.meter(gaugeKind, name, help, nil, labels)}
+}
+
+// Histogram 创建或者返回已经创建的直方图，buckets 为空时使用 DefaultBuckets 。
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	return &Histogram{r.meter(histogramKind, name, help, buckets, labels)}
+}
+
+// meter 创建指标，依次应用属性配置和 MeterCustomizer 。同名的指标只创建一次，类型
+// 或者标签不同时 panic 。
+func (r *Registry) meter(k kind, name, help string, buckets []float64, labels []string) *meter {
+	if !validName.MatchString(name) {
+		panic(fmt.Errorf("metrics: invalid name %q", name))
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if m, ok := r.meters[name]; ok {
+		if m.kind != k || strings.Join(m.labels, ",") != strings.Join(labels, ",") {
+			panic(fmt.Errorf("metrics: %s already registered as %s%v", name, m.kind, m.labels))
+		}
+		return m
+	}
+
+	opts := MeterOptions{
+		Name:     name,
+		Help:     help,
+		Labels:   labels,
+		Buckets:  buckets,
+		Disabled: !r.config.Enabled,
+	}
+	if c, ok := r.config.Meters[name]; ok {
+		opts.Disabled = opts.Disabled || !c.Enabled
+		if len(c.Buckets) > 0 {
+			opts.Buckets = c.Buckets
+		}
+		if len(c.Labels) > 0 {
+			opts.Labels = c.Labels
+		}
+	}
+	for _, c := range r.customizers {
+		c.Customize(&opts)
+	}
+	opts.Buckets = append([]float64(nil), opts.Buckets...)
+	sort.Float64s(opts.Buckets)
+
+	m := &meter{kind: k, opts: opts, labels: labels, series: make(map[string]*series)}
+	for i, l := range labels {
+		for _, s := range opts.Labels {
+			if s == l {
+				m.keep = append(m.keep, i)
+				break
+			}
+		}
+	}
+	r.meters[name] = m
+	return m
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/metrics"
+	"github.com/go-spring/spring-core/web"
+)
+
+func output(t *testing.T, r *metrics.Registry) string {
+	var buf bytes.Buffer
+	err := r.Write(&buf)
+	assert.Nil(t, err)
+	return buf.String()
+}
+
+func TestRegistry(t *testing.T) {
+
+	p, err := conf.Bytes([]byte(`
+		metrics.meters.requests_total.labels=method
+		metrics.meters.latency_seconds.buckets=1,0.1
+		metrics.meters.debug_total.enabled=false
+	`), ".properties")
+	assert.Nil(t, err)
+
+	var config metrics.Config
+	err = p.Bind(&config, conf.Key("metrics"))
+	assert.Nil(t, err)
+	assert.True(t, config.Enabled)
+	assert.Equal(t, config.Path, "/metrics")
+
+	customizer := metrics.MeterCustomizerFunc(func(opts *metrics.MeterOptions) {
+		if opts.Name == "queue_size" {
+			opts.Help = "Queue size."
+		}
+	})
+	r := metrics.New(config, []metrics.MeterCustomizer{customizer})
+
+	// path 标签没有在 labels 中，不同路径的计数被合并。
+	c := r.Counter("requests_total", "Total requests.", "method", "path")
+	c.Inc("GET", "/a")
+	c.Inc("GET", "/b")
+	c.Add(2, "POST", "/a")
+	assert.Equal(t, r.Counter("requests_total", "", "method", "path"), c)
+
+	h := r.Histogram("latency_seconds", "", nil)
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(3)
+
+	g := r.Gauge("queue_size", "", "queue")
+	g.Set(3, "a\"b")
+	g.Add(-1, "a\"b")
+
+	d := r.Counter("debug_total", "")
+	d.Inc()
+
+	assert.Equal(t, output(t, r), `# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 3.55
+latency_seconds_count 3
+# HELP queue_size Queue size.
+# TYPE queue_size gauge
+queue_size{queue="a\"b"} 2
+# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{method="GET"} 2
+requests_total{method="POST"} 2
+`)
+
+	assert.Panic(t, func() { r.Gauge("requests_total", "") }, "requests_total already registered as counter")
+	assert.Panic(t, func() { c.Inc("GET") }, "expects 2 label values but got 1")
+	assert.Panic(t, func() { r.Counter("bad-name", "") }, "invalid name")
+
+	r = metrics.New(metrics.Config{Enabled: false}, nil)
+	r.Counter("requests_total", "").Inc()
+	assert.Equal(t, output(t, r), "")
+}
+
+func TestFilter(t *testing.T) {
+
+	r := metrics.New(metrics.Config{Enabled: true, Meters: map[string]metrics.MeterConfig{
+		metrics.HTTPServerRequests: {Enabled: true, Buckets: []float64{10}},
+	}}, nil)
+
+	f := metrics.NewFilter(r)
+	serve := func(fn web.HandlerFunc) {
+		req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+		w := httptest.NewRecorder()
+		ctx := web.NewBaseContext("/users/{id}", nil, req, &web.BufferedResponseWriter{ResponseWriter: w})
+		handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) { fn(ctx) })
+		web.NewFilterChain([]web.Filter{f, handler}).Next(ctx)
+	}
+
+	serve(func(ctx web.Context) { ctx.String("ok") })
+	serve(func(ctx web.Context) { ctx.SetStatus(http.StatusNotFound) })
+	assert.Panic(t, func() { serve(func(ctx web.Context) { panic("boom") }) }, "boom")
+
+	w := httptest.NewRecorder()
+	ctx := web.NewBaseContext("/metrics", nil, httptest.NewRequest(http.MethodGet, "/metrics", nil), &web.BufferedResponseWriter{ResponseWriter: w})
+	r.Handler().Invoke(ctx)
+	assert.Equal(t, w.Header().Get(web.HeaderContentType), metrics.ContentType)
+	out := w.Body.String()
+	assert.Matches(t, out, `http_server_requests_seconds_count\{method="GET",route="/users/\{id\}",status="200"\} 1`)
+	assert.Matches(t, out, `http_server_requests_seconds_count\{method="GET",route="/users/\{id\}",status="404"\} 1`)
+	assert.Matches(t, out, `http_server_requests_seconds_count\{method="GET",route="/users/\{id\}",status="500"\} 1`)
+}
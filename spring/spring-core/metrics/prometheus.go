@@ -0,0 +1,141 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-spring/spring-core/web"
+)
+
+// ContentType Prometheus 文本格式的 Content-Type 。
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Write 以 Prometheus 的文本格式输出所有开启的指标，指标按照名称排序。
+func (r *Registry) Write(w io.Writer) error {
+
+	r.mutex.Lock()
+	meters := make([]*meter, 0, len(r.meters))
+	for _, m := range r.meters {
+		if !m.opts.Disabled {
+			meters = append(meters, m)
+		}
+	}
+	r.mutex.Unlock()
+
+	sort.Slice(meters, func(i, j int) bool {
+		return meters[i].opts.Name < meters[j].opts.Name
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, m := range meters {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+func (m *meter) write(w *bufio.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := m.opts.Name
+	if m.opts.Help != "" {
+		w.WriteString("# HELP " + name + " " + escapeHelp(m.opts.Help) + "\n")
+	}
+	w.WriteString("# TYPE " + name + " " + string(m.kind) + "\n")
+
+	keys := make([]string, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var names []string
+	for _, i := range m.keep {
+		names = append(names, m.labels[i])
+	}
+
+	for _, k := range keys {
+		s := m.series[k]
+		if m.kind != histogramKind {
+			writeSample(w, name, names, s.values, "", "", s.value)
+			continue
+		}
+		var cumulative uint64
+		for i, b := range m.opts.Buckets {
+			cumulative += s.counts[i]
+			writeSample(w, name+"_bucket", names, s.values, "le", formatFloat(b), float64(cumulative))
+		}
+		writeSample(w, name+"_bucket", names, s.values, "le", "+Inf", float64(s.count))
+		writeSample(w, name+"_sum", names, s.values, "", "", s.sum)
+		writeSample(w, name+"_count", names, s.values, "", "", float64(s.count))
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, names, values []string, extraName, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(names) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, n := range names {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(n + `="` + escapeLabel(values[i]) + `"`)
+		}
+		if extraName != "" {
+			if len(names) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(extraName + `="` + extraValue + `"`)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteString(" " + formatFloat(v) + "\n")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpReplacer.Replace(s) }
+func escapeLabel(s string) string { return labelReplacer.Replace(s) }
+
+// Handler 返回以 Prometheus 文本格式输出指标的处理函数。
+func (r *Registry) Handler() web.Handler {
+	return web.FUNC(func(ctx web.Context) {
+		ctx.SetContentType(ContentType)
+		if err := r.Write(ctx.ResponseWriter()); err != nil {
+			panic(err)
+		}
+	})
+}
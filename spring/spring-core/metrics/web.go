@@ -0,0 +1,49 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-spring/spring-core/web"
+)
+
+// HTTPServerRequests 统计服务端请求耗时的直方图的名称。
+const HTTPServerRequests = "http_server_requests_seconds"
+
+// NewFilter 创建统计请求耗时的过滤器，标签为请求方法、路由和响应状态码。路由使用
+// 注册时的路径，例如 /users/{id} ，避免路径参数导致指标的基数过大。处理器 panic 的
+// 请求计为 500 。
+func NewFilter(r *Registry) web.Filter {
+	h := r.Histogram(HTTPServerRequests, "HTTP server request duration in seconds.", nil, "method", "route", "status")
+	return web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		start := time.Now()
+		panicked := true
+		defer func() {
+			status := ctx.ResponseWriter().Status()
+			if panicked {
+				status = 500
+			} else if status == 0 {
+				status = 200
+			}
+			h.Observe(time.Since(start).Seconds(), ctx.Request().Method, ctx.Path(), strconv.Itoa(status))
+		}()
+		chain.Next(ctx)
+		panicked = false
+	})
+}
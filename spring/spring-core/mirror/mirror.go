@@ -0,0 +1,258 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package mirror 将生产流量按照比例异步复制到影子服务，影子服务的响应被忽略，不影
+// 响原始请求的处理，用于使用真实流量验证新版本的服务，例如：
+//
+//	gs.Provide(mirror.New, "${web.mirror}").On(cond.OnProperty("web.mirror.target"))
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/web"
+)
+
+var logger = log.GetLogger("GS_MIRROR")
+
+// HeaderXMirror 复制的请求携带该请求头，影子服务可以据此避免产生副作用。
+const HeaderXMirror = "X-Mirror"
+
+// Config 流量复制配置。
+type Config struct {
+	Target      string        `value:"${target}"`          // 影子服务的地址，如 http://127.0.0.1:9090
+	Rate        float64       `value:"${rate:=1}"`         // 复制的比例，取值范围是 0~1
+	Paths       []string      `value:"${paths:=}"`         // 只复制这些前缀的路径，为空时不限制
+	Methods     []string      `value:"${methods:=}"`       // 只复制这些方法的请求，为空时不限制
+	Timeout     time.Duration `value:"${timeout:=5s}"`     // 复制请求的超时时间
+	MaxBody     conf.ByteSize `value:"${max-body:=1MB}"`   // 请求体超过该大小时不复制
+	Concurrency int           `value:"${concurrency:=64}"` // 同时进行的复制请求的上限，超过时丢弃
+}
+
+// Stats 流量复制的统计数据。
+type Stats struct {
+	Mirrored int64 `json:"mirrored"` // 已经发送的复制请求
+	Failed   int64 `json:"failed"`   // 发送失败的复制请求
+	Dropped  int64 `json:"dropped"`  // 因为并发超限或者请求体过大而放弃的请求
+}
+
+// Mirror 复制流量的过滤器。
+type Mirror struct {
+	config Config
+	target *url.URL
+	client *http.Client
+	tokens chan struct{}
+	wg     sync.WaitGroup
+
+	mutex sync.Mutex
+	rand  *rand.Rand
+
+	mirrored int64
+	failed   int64
+	dropped  int64
+}
+
+// New 创建复制流量的过滤器。
+func New(config Config) (*Mirror, error) {
+	target, err := url.Parse(config.Target)
+	if err != nil {
+		return nil, err
+	}
+	if target.Scheme == "" || target.Host == "" {
+		return nil, fmt.Errorf("invalid mirror target %q", config.Target)
+	}
+	if config.Concurrency <= 0 {
+		return nil, fmt.Errorf("invalid mirror concurrency %d", config.Concurrency)
+	}
+	return &Mirror{
+		config: config,
+		target: target,
+		client: &http.Client{Timeout: config.Timeout},
+		tokens: make(chan struct{}, config.Concurrency),
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// Stats 返回流量复制的统计数据。
+func (m *Mirror) Stats() Stats {
+	return Stats{
+		Mirrored: atomic.LoadInt64(&m.mirrored),
+		Failed:   atomic.LoadInt64(&m.failed),
+		Dropped:  atomic.LoadInt64(&m.dropped),
+	}
+}
+
+// Wait 等待已经发出的复制请求结束，通常在关闭应用时调用。
+func (m *Mirror) Wait() {
+	m.wg.Wait()
+}
+
+func (m *Mirror) sampled() bool {
+	if m.config.Rate >= 1 {
+		return true
+	}
+	if m.config.Rate <= 0 {
+		return false
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.rand.Float64() < m.config.Rate
+}
+
+func (m *Mirror) match(r *http.Request) bool {
+	if len(m.config.Methods) > 0 && !contains(m.config.Methods, r.Method) {
+		return false
+	}
+	if len(m.config.Paths) == 0 {
+		return true
+	}
+	for _, p := range m.config.Paths {
+		if strings.HasPrefix(r.URL.Path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Mirror) Invoke(ctx web.Context, chain web.FilterChain) {
+	m.mirror(ctx)
+	chain.Next(ctx)
+}
+
+// mirror 按照配置复制请求，复制的请求异步发送。
+func (m *Mirror) mirror(ctx web.Context) {
+
+	req := ctx.Request()
+	if req.Header.Get(HeaderXMirror) != "" || !m.match(req) || !m.sampled() {
+		return
+	}
+
+	// 读取请求体之后恢复，不影响后续的处理。
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.ContentLength > int64(m.config.MaxBody) {
+			atomic.AddInt64(&m.dropped, 1)
+			return
+		}
+		b, err := ioutil.ReadAll(io.LimitReader(req.Body, int64(m.config.MaxBody)+1))
+		req.Body = readCloser{io.MultiReader(bytes.NewReader(b), req.Body), req.Body}
+		if err != nil || int64(len(b)) > int64(m.config.MaxBody) {
+			atomic.AddInt64(&m.dropped, 1)
+			return
+		}
+		body = b
+	}
+
+	select {
+	case m.tokens <- struct{}{}:
+	default:
+		atomic.AddInt64(&m.dropped, 1)
+		return
+	}
+
+	r, err := m.newRequest(req, body)
+	if err != nil {
+		<-m.tokens
+		atomic.AddInt64(&m.failed, 1)
+		logger.WithContext(ctx.Context()).Errorf(log.ERROR, "mirror request error: %v", err)
+		return
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer func() { <-m.tokens }()
+		m.send(r)
+	}()
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// hopHeaders 逐跳首部，不能转发给影子服务。
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// newRequest 创建复制的请求，复制的请求不受原始请求上下文的影响。
+func (m *Mirror) newRequest(req *http.Request, body []byte) (*http.Request, error) {
+	u := *m.target
+	u.Path = strings.TrimSuffix(m.target.Path, "/") + req.URL.Path
+	u.RawPath = ""
+	u.RawQuery = req.URL.RawQuery
+	r, err := http.NewRequestWithContext(context.Background(), req.Method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, vv := range req.Header {
+		for _, v := range vv {
+			r.Header.Add(k, v)
+		}
+	}
+	for _, h := range hopHeaders {
+		r.Header.Del(h)
+	}
+	r.Header.Set(HeaderXMirror, "1")
+	if body == nil {
+		r.Body = http.NoBody
+	}
+	return r, nil
+}
+
+// send 发送复制的请求并丢弃响应。
+func (m *Mirror) send(r *http.Request) {
+	resp, err := m.client.Do(r)
+	if err != nil {
+		atomic.AddInt64(&m.failed, 1)
+		logger.Warnf("mirror %s %s error: %v", r.Method, r.URL.Path, err)
+		return
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+	atomic.AddInt64(&m.mirrored, 1)
+}
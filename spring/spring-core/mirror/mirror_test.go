@@ -0,0 +1,100 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mirror_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/mirror"
+	"github.com/go-spring/spring-core/web"
+)
+
+func TestMirror(t *testing.T) {
+
+	var (
+		mutex    sync.Mutex
+		requests []string
+	)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		mutex.Lock()
+		defer mutex.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.RequestURI()+" "+string(b)+" "+r.Header.Get(mirror.HeaderXMirror))
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer shadow.Close()
+
+	_, err := mirror.New(mirror.Config{Target: "127.0.0.1:9090", Concurrency: 1})
+	assert.Error(t, err, "")
+
+	m, err := mirror.New(mirror.Config{
+		Target:      shadow.URL + "/v2",
+		Rate:        1,
+		Paths:       []string{"/api"},
+		MaxBody:     8,
+		Concurrency: 4,
+	})
+	assert.Nil(t, err)
+
+	handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		b, err := ctx.RequestBody()
+		assert.Nil(t, err)
+		ctx.String("echo " + string(b))
+	})
+
+	for _, c := range []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodPost, "/api/users?a=1", "hello"},
+		{http.MethodGet, "/api/users/1", ""},
+		{http.MethodGet, "/health", ""},
+		{http.MethodPost, "/api/upload", "too large body"},
+	} {
+		r := httptest.NewRequest(c.method, "http://127.0.0.1:8080"+c.path, strings.NewReader(c.body))
+		w := httptest.NewRecorder()
+		ctx := web.NewBaseContext("", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+		web.NewFilterChain([]web.Filter{m, handler}).Next(ctx)
+		assert.Equal(t, w.Code, http.StatusOK)
+		assert.Equal(t, w.Body.String(), "echo "+c.body)
+	}
+
+	m.Wait()
+	assert.Equal(t, m.Stats(), mirror.Stats{Mirrored: 2, Dropped: 1})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, len(requests), 2)
+	assert.True(t, contains(requests, "POST /v2/api/users?a=1 hello 1"))
+	assert.True(t, contains(requests, "GET /v2/api/users/1  1"))
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
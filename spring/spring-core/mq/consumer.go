@@ -54,7 +54,7 @@ func (c *consumer) Consume(ctx context.Context, msg Message) error {
 		return err
 	}
 	out := c.v.Call([]reflect.Value{reflect.ValueOf(ctx), e})
-	if err = out[0].Interface().(error); err != nil {
+	if err, _ = out[0].Interface().(error); err != nil {
 		return err
 	}
 	return nil
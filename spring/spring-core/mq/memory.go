@@ -0,0 +1,80 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mq
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/go-spring/spring-base/log"
+)
+
+var logger = log.GetLogger("GS_MQ")
+
+// ErrBrokerClosed 向已关闭的 MemoryBroker 发送消息时返回该错误。
+var ErrBrokerClosed = errors.New("mq: broker closed")
+
+// MemoryBroker 基于内存的消息代理，同时实现了 Producer 接口，发送的消息会异步地
+// 投递给订阅了该主题的全部消费者，适合在开发和测试环境中替代真实的 MQ 服务。
+type MemoryBroker struct {
+	mutex     sync.RWMutex
+	wg        sync.WaitGroup
+	closed    bool
+	consumers map[string][]Consumer
+}
+
+// NewMemoryBroker 创建基于内存的消息代理。
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{consumers: make(map[string][]Consumer)}
+}
+
+// Subscribe 为消费者订阅其关注的全部主题。
+func (b *MemoryBroker) Subscribe(consumer Consumer) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, topic := range consumer.Topics() {
+		b.consumers[topic] = append(b.consumers[topic], consumer)
+	}
+}
+
+// SendMessage 将消息投递给订阅了该主题的消费者，没有消费者时消息被丢弃。
+func (b *MemoryBroker) SendMessage(ctx context.Context, msg Message) error {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	if b.closed {
+		return ErrBrokerClosed
+	}
+	for _, c := range b.consumers[msg.Topic()] {
+		b.wg.Add(1)
+		go func(c Consumer) {
+			defer b.wg.Done()
+			if err := c.Consume(context.Background(), msg); err != nil {
+				logger.WithContext(ctx).Errorf(log.ERROR, "consume message %s of topic %s error: %v", msg.ID(), msg.Topic(), err)
+			}
+		}(c)
+	}
+	return nil
+}
+
+// Close 停止接收新的消息，并等待已经投递的消息消费完成。
+func (b *MemoryBroker) Close() {
+	b.mutex.Lock()
+	b.closed = true
+	b.mutex.Unlock()
+	b.wg.Wait()
+}
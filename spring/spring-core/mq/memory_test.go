@@ -0,0 +1,79 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mq_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/mq"
+)
+
+type event struct {
+	Name string `json:"name"`
+}
+
+func TestMemoryBroker(t *testing.T) {
+
+	var (
+		mutex    sync.Mutex
+		received []string
+	)
+
+	b := mq.NewMemoryBroker()
+	b.Subscribe(mq.Bind(func(ctx context.Context, e *event) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		received = append(received, "a:"+e.Name)
+		return nil
+	}, "order", "user"))
+	b.Subscribe(mq.Bind(func(ctx context.Context, e *event) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		received = append(received, "b:"+e.Name)
+		return errors.New("ignored")
+	}, "order"))
+
+	ctx := context.Background()
+	err := b.SendMessage(ctx, mq.NewMessage().WithTopic("order").WithBody([]byte(`{"name":"o1"}`)))
+	assert.Nil(t, err)
+	err = b.SendMessage(ctx, mq.NewMessage().WithTopic("user").WithBody([]byte(`{"name":"u1"}`)))
+	assert.Nil(t, err)
+	err = b.SendMessage(ctx, mq.NewMessage().WithTopic("none").WithBody([]byte(`{}`)))
+	assert.Nil(t, err)
+
+	b.Close()
+	assert.Equal(t, len(received), 3)
+	assert.True(t, contains(received, "a:o1"))
+	assert.True(t, contains(received, "b:o1"))
+	assert.True(t, contains(received, "a:u1"))
+
+	err = b.SendMessage(ctx, mq.NewMessage().WithTopic("order"))
+	assert.True(t, errors.Is(err, mq.ErrBrokerClosed))
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
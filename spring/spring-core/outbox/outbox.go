@@ -0,0 +1,276 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package outbox 实现事务发件箱模式，消息和业务数据在同一个数据库事务中写入发件箱表，
+// 然后由后台的 Relay 发布到消息队列，保证消息至少投递一次。发件箱表的结构如下(MySQL):
+//
+//	CREATE TABLE outbox (
+//	    id           BIGINT AUTO_INCREMENT PRIMARY KEY,
+//	    topic        VARCHAR(255) NOT NULL,
+//	    msg_id       VARCHAR(255) NOT NULL,
+//	    body         BLOB,
+//	    extra        TEXT,
+//	    created_at   BIGINT NOT NULL,
+//	    published_at BIGINT NOT NULL DEFAULT 0,
+//	    attempts     INT NOT NULL DEFAULT 0,
+//	    INDEX idx_published_at (published_at)
+//	);
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/internal/sqlutil"
+	"github.com/go-spring/spring-core/mq"
+	"github.com/go-spring/spring-core/report"
+)
+
+var logger = log.GetLogger("GS_OUTBOX")
+
+// Execer 可以执行 SQL 语句的对象，通常是业务代码正在使用的 *sql.Tx 。
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+type Config struct {
+	Table       string        `value:"${table:=outbox}"`
+	Placeholder string        `value:"${placeholder:=?}"` // 参数占位符，PostgreSQL 使用 $
+	BatchSize   int           `value:"${batch-size:=100}"`
+	Interval    time.Duration `value:"${interval:=1s}"`    // 轮询发件箱的间隔
+	MaxAttempts int           `value:"${max-attempts:=0}"` // 超过发送次数的消息不再重试，为 0 时不限制
+	Retention   time.Duration `value:"${retention:=24h}"`  // 已发布消息的保留时间，为 0 时发布后立即删除
+}
+
+func NewConfig() Config {
+	return Config{
+		Table:       "outbox",
+		Placeholder: "?",
+		BatchSize:   100,
+		Interval:    time.Second,
+		Retention:   24 * time.Hour,
+	}
+}
+
+// Outbox 发件箱
+type Outbox struct {
+	db     *sql.DB
+	config Config
+}
+
+func New(config Config, db *sql.DB) *Outbox {
+	return &Outbox{db: db, config: config}
+}
+
+// bind 将 SQL 语句中的 ? 替换为配置的占位符。
+func (o *Outbox) bind(query string) string {
+	return sqlutil.Bind(query, o.config.Placeholder)
+}
+
+// Save 使用 tx 将消息写入发件箱，tx 提交之后消息才会被 Relay 发布，回滚则消息被丢弃。
+func (o *Outbox) Save(ctx context.Context, tx Execer, msg mq.Message) error {
+	var extra []byte
+	if len(msg.Extra()) > 0 {
+		var err error
+		if extra, err = json.Marshal(msg.Extra()); err != nil {
+			return err
+		}
+	}
+	query := fmt.Sprintf("INSERT INTO %s (topic, msg_id, body, extra, created_at) VALUES (?, ?, ?, ?, ?)", o.config.Table)
+	_, err := tx.ExecContext(ctx, o.bind(query), msg.Topic(), msg.ID(), msg.Body(), string(extra), time.Now().UnixNano()/int64(time.Millisecond))
+	return err
+}
+
+type record struct {
+	id       int64
+	attempts int
+	msg      mq.Message
+}
+
+// fetch 按照写入顺序返回一批待发布的消息。
+func (o *Outbox) fetch(ctx context.Context) ([]record, error) {
+	query := fmt.Sprintf("SELECT id, topic, msg_id, body, extra, attempts FROM %s WHERE published_at = 0", o.config.Table)
+	var args []interface{}
+	if o.config.MaxAttempts > 0 {
+		query += " AND attempts < ?"
+		args = append(args, o.config.MaxAttempts)
+	}
+	query += fmt.Sprintf(" ORDER BY id LIMIT %d", o.config.BatchSize)
+
+	rows, err := o.db.QueryContext(ctx, o.bind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []record
+	for rows.Next() {
+		var (
+			r          record
+			topic, id  string
+			body       []byte
+			extraBytes sql.NullString
+		)
+		if err = rows.Scan(&r.id, &topic, &id, &body, &extraBytes, &r.attempts); err != nil {
+			return nil, err
+		}
+		msg := mq.NewMessage().WithTopic(topic).WithID(id).WithBody(body)
+		if extraBytes.String != "" {
+			var extra map[string]string
+			if err = json.Unmarshal([]byte(extraBytes.String), &extra); err != nil {
+				return nil, err
+			}
+			for k, v := range extra {
+				msg.WithExtra(k, v)
+			}
+		}
+		r.msg = msg
+		ret = append(ret, r)
+	}
+	return ret, rows.Err()
+}
+
+func (o *Outbox) exec(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	result, err := o.db.ExecContext(ctx, o.bind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (o *Outbox) markPublished(ctx context.Context, id int64) error {
+	var err error
+	if o.config.Retention == 0 {
+		_, err = o.exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = ?", o.config.Table), id)
+	} else {
+		now := time.Now().UnixNano() / int64(time.Millisecond)
+		_, err = o.exec(ctx, fmt.Sprintf("UPDATE %s SET published_at = ? WHERE id = ?", o.config.Table), now, id)
+	}
+	return err
+}
+
+func (o *Outbox) markFailed(ctx context.Context, id int64) error {
+	_, err := o.exec(ctx, fmt.Sprintf("UPDATE %s SET attempts = attempts + 1 WHERE id = ?", o.config.Table), id)
+	return err
+}
+
+// Cleanup 删除超过保留时间的已发布消息，返回删除的数量。
+func (o *Outbox) Cleanup(ctx context.Context) (int64, error) {
+	if o.config.Retention == 0 {
+		return 0, nil
+	}
+	before := time.Now().Add(-o.config.Retention).UnixNano() / int64(time.Millisecond)
+	return o.exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE published_at > 0 AND published_at < ?", o.config.Table), before)
+}
+
+// Relay 将发件箱中的消息发布到消息队列。消息在发送成功之后才会被标记为已发布，
+// 进程崩溃或者部署了多个 Relay 时可能会重复发送，消费者需要根据消息 ID 去重。
+type Relay struct {
+	outbox   *Outbox
+	producer mq.Producer
+	stop     context.CancelFunc
+	done     chan struct{}
+}
+
+func NewRelay(outbox *Outbox, producer mq.Producer) *Relay {
+	return &Relay{outbox: outbox, producer: producer}
+}
+
+// RelayOnce 发布一批消息，返回发布成功的数量。为了保持消息的顺序，遇到发送失败
+// 的消息时结束本批次的发布。
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	records, err := r.outbox.fetch(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i, rec := range records {
+		if err = r.producer.SendMessage(ctx, rec.msg); err != nil {
+			if e := r.outbox.markFailed(ctx, rec.id); e != nil {
+				logger.WithContext(ctx).Error(log.ERROR, e)
+			}
+			return i, fmt.Errorf("publish outbox message %d error: %w", rec.id, err)
+		}
+		if err = r.outbox.markPublished(ctx, rec.id); err != nil {
+			return i, err
+		}
+	}
+	return len(records), nil
+}
+
+// relayOnce 调用 RelayOnce ，发布过程中的 panic 会被上报并且作为错误返回。
+func (r *Relay) relayOnce(ctx context.Context) (n int, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			ctx = report.WithTags(ctx, "outbox.table", r.outbox.config.Table)
+			report.Panic(ctx, report.SourceTask, v)
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return r.RelayOnce(ctx)
+}
+
+// Run 周期性地发布消息并清理过期的消息，直到 ctx 被取消。
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.outbox.config.Interval)
+	defer ticker.Stop()
+	for {
+		for {
+			n, err := r.relayOnce(ctx)
+			if err != nil {
+				logger.WithContext(ctx).Error(log.ERROR, err)
+			}
+			if err != nil || n < r.outbox.config.BatchSize {
+				break
+			}
+		}
+		if _, err := r.outbox.Cleanup(ctx); err != nil {
+			logger.WithContext(ctx).Error(log.ERROR, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// OnAppStart 应用启动后在后台运行 Relay 。
+func (r *Relay) OnAppStart(ctx gs.Context) {
+	var c context.Context
+	c, r.stop = context.WithCancel(ctx.Context())
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		r.Run(c)
+	}()
+}
+
+// OnAppStop 停止 Relay 并等待正在发布的消息完成。
+func (r *Relay) OnAppStop(ctx context.Context) {
+	if r.stop == nil {
+		return
+	}
+	r.stop()
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/mq"
+	"github.com/go-spring/spring-core/outbox"
+)
+
+type producer struct {
+	fail error
+	sent []mq.Message
+}
+
+func (p *producer) SendMessage(ctx context.Context, msg mq.Message) error {
+	if p.fail != nil {
+		return p.fail
+	}
+	p.sent = append(p.sent, msg)
+	return nil
+}
+
+func TestOutbox_Save(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	config := outbox.NewConfig()
+	config.Placeholder = "$"
+	o := outbox.New(config, db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO outbox \(topic, msg_id, body, extra, created_at\) VALUES \(\$1, \$2, \$3, \$4, \$5\)`).
+		WithArgs("order", "1", []byte("created"), `{"k":"v"}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	ctx := context.Background()
+	tx, err := db.Begin()
+	assert.Nil(t, err)
+	msg := mq.NewMessage().WithTopic("order").WithID("1").WithBody([]byte("created")).WithExtra("k", "v")
+	err = o.Save(ctx, tx, msg)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Commit())
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestRelay(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	config := outbox.NewConfig()
+	config.MaxAttempts = 3
+	o := outbox.New(config, db)
+	p := &producer{}
+	r := outbox.NewRelay(o, p)
+	ctx := context.Background()
+
+	columns := []string{"id", "topic", "msg_id", "body", "extra", "attempts"}
+	query := `SELECT id, topic, msg_id, body, extra, attempts FROM outbox WHERE published_at = 0 AND attempts < \? ORDER BY id LIMIT 100`
+
+	t.Run("published", func(t *testing.T) {
+		mock.ExpectQuery(query).WithArgs(3).WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "order", "1", []byte("a"), `{"k":"v"}`, 0).
+			AddRow(2, "order", "2", []byte("b"), "", 1))
+		mock.ExpectExec(`UPDATE outbox SET published_at = \? WHERE id = \?`).WithArgs(sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE outbox SET published_at = \? WHERE id = \?`).WithArgs(sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(0, 1))
+		n, err := r.RelayOnce(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, n, 2)
+		assert.Equal(t, len(p.sent), 2)
+		assert.Equal(t, p.sent[0].Extra(), map[string]string{"k": "v"})
+		assert.Equal(t, string(p.sent[1].Body()), "b")
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		p.fail = errors.New("broker unavailable")
+		mock.ExpectQuery(query).WithArgs(3).WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(3, "order", "3", []byte("c"), "", 0).
+			AddRow(4, "order", "4", []byte("d"), "", 0))
+		mock.ExpectExec(`UPDATE outbox SET attempts = attempts \+ 1 WHERE id = \?`).WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+		n, err := r.RelayOnce(ctx)
+		assert.Error(t, err, "broker unavailable")
+		assert.Equal(t, n, 0)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM outbox WHERE published_at > 0 AND published_at < \?`).
+			WithArgs(sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 5))
+		n, err := o.Cleanup(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, n, int64(5))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRelay_DeleteOnPublish(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	config := outbox.NewConfig()
+	config.Retention = 0
+	r := outbox.NewRelay(outbox.New(config, db), &producer{})
+
+	mock.ExpectQuery(`SELECT .* FROM outbox WHERE published_at = 0 ORDER BY id LIMIT 100`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "topic", "msg_id", "body", "extra", "attempts"}).
+			AddRow(1, "order", "1", []byte("a"), nil, 0))
+	mock.ExpectExec(`DELETE FROM outbox WHERE id = \?`).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := r.RelayOnce(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, n, 1)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
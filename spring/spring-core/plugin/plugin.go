@@ -0,0 +1,198 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package plugin 提供了插件子系统。插件通过清单 (Manifest) 描述自己提供的 bean，
+// 清单可以在程序内部通过 Register 函数注册，也可以通过 Open 函数从编译好的 go 插件
+// 中加载。插件在运行时被加载到容器动态注册表的一个子注册表中，宿主程序通过扩展点接口
+// 获取插件提供的功能。
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	goplugin "plugin"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-spring/spring-core/gs"
+)
+
+// APIVersion 插件接口的版本，插件要求的主版本号必须与之相同，次版本号不能高于它。
+const APIVersion = "1.0"
+
+// ManifestSymbol go 插件中导出的插件清单的变量名，类型必须是 *Manifest 。
+const ManifestSymbol = "Manifest"
+
+// Manifest 插件清单。
+type Manifest struct {
+	Name       string                      // 插件名称
+	Version    string                      // 插件自身的版本
+	APIVersion string                      // 插件要求的插件接口版本
+	Beans      func() []*gs.BeanDefinition // 插件提供的 bean
+}
+
+var (
+	mutex     sync.Mutex
+	manifests = map[string]*Manifest{}
+)
+
+// Register 注册程序内部的插件清单，通常在 init 函数中调用。
+func Register(m *Manifest) {
+	if err := register(m); err != nil {
+		panic(err)
+	}
+}
+
+func register(m *Manifest) error {
+	if m.Name == "" {
+		return errors.New("plugin name can't be empty")
+	}
+	if m.Beans == nil {
+		return fmt.Errorf("plugin %q provides no beans", m.Name)
+	}
+	if err := checkVersion(m.APIVersion); err != nil {
+		return fmt.Errorf("plugin %q: %w", m.Name, err)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if _, ok := manifests[m.Name]; ok {
+		return fmt.Errorf("duplicate plugin %q", m.Name)
+	}
+	manifests[m.Name] = m
+	return nil
+}
+
+// Open 打开编译好的 go 插件并注册其中导出的插件清单。
+func Open(path string) (*Manifest, error) {
+	p, err := goplugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup(ManifestSymbol)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := sym.(*Manifest)
+	if !ok {
+		return nil, fmt.Errorf("symbol %q in %s should be *plugin.Manifest", ManifestSymbol, path)
+	}
+	if err = register(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// checkVersion 检查插件要求的接口版本是否与 APIVersion 兼容。
+func checkVersion(v string) error {
+	major, minor, err := parseVersion(v)
+	if err != nil {
+		return err
+	}
+	hostMajor, hostMinor, _ := parseVersion(APIVersion)
+	if major != hostMajor || minor > hostMinor {
+		return fmt.Errorf("api version %s is incompatible with %s", v, APIVersion)
+	}
+	return nil
+}
+
+func parseVersion(v string) (major, minor int, err error) {
+	ss := strings.SplitN(v, ".", 3)
+	if len(ss) < 2 {
+		return 0, 0, fmt.Errorf("invalid api version %q", v)
+	}
+	if major, err = strconv.Atoi(ss[0]); err != nil {
+		return 0, 0, fmt.Errorf("invalid api version %q", v)
+	}
+	if minor, err = strconv.Atoi(ss[1]); err != nil {
+		return 0, 0, fmt.Errorf("invalid api version %q", v)
+	}
+	return major, minor, nil
+}
+
+// Plugin 已经加载到容器中的插件。
+type Plugin struct {
+	manifest *Manifest
+	registry *gs.DynamicRegistry // 插件独占的子注册表
+	beans    []*gs.BeanDefinition
+}
+
+// Load 将已注册的插件加载到容器动态注册表的子注册表中，插件的 bean 可以依赖应用的
+// bean ，但是不能与应用的 bean 重名，也不会影响应用和其他插件的 bean 。任何一个 bean
+// 注册失败都会导致已注册的 bean 被注销。
+func Load(ctx gs.Context, name string) (*Plugin, error) {
+
+	mutex.Lock()
+	m, ok := manifests[name]
+	mutex.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("plugin %q not found", name)
+	}
+
+	d := ctx.Dynamic()
+	if d == nil {
+		return nil, errors.New("context should be refreshed before loading plugins")
+	}
+
+	r := d.Child("plugin " + name)
+	p := &Plugin{manifest: m, registry: r}
+	for _, b := range m.Beans() {
+		if err := r.Register(b); err != nil {
+			p.Unload()
+			return nil, fmt.Errorf("load plugin %q error: %w", name, err)
+		}
+		p.beans = append(p.beans, b)
+	}
+	return p, nil
+}
+
+// Manifest 返回插件清单。
+func (p *Plugin) Manifest() *Manifest {
+	return p.manifest
+}
+
+// Extensions 收集插件中实现了扩展点接口的 bean，i 必须是指向接口切片的指针，
+// 例如 *[]Handler 。
+func (p *Plugin) Extensions(i interface{}) error {
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return errors.New("i must be pointer to slice")
+	}
+	v = v.Elem()
+	et := v.Type().Elem()
+	if et.Kind() != reflect.Interface {
+		return errors.New("extension point must be interface type")
+	}
+	for _, b := range p.beans {
+		if b.Type().Implements(et) {
+			v.Set(reflect.Append(v, b.Value()))
+		}
+	}
+	return nil
+}
+
+// Registry 返回插件独占的子注册表，可以用来按照类型或者名称获取插件的 bean 。
+func (p *Plugin) Registry() *gs.DynamicRegistry {
+	return p.registry
+}
+
+// Unload 从容器中卸载插件，插件 bean 的销毁函数会被执行。
+func (p *Plugin) Unload() {
+	p.registry.Close()
+	p.beans = nil
+}
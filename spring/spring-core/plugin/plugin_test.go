@@ -0,0 +1,152 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plugin_test
+
+import (
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/plugin"
+)
+
+type Greeter interface {
+	Greet() string
+}
+
+type hello struct {
+	Prefix  string `value:"${greet.prefix:=hello}"`
+	stopped bool
+}
+
+func (h *hello) Greet() string { return h.Prefix + " plugin" }
+
+func TestPlugin(t *testing.T) {
+
+	h := &hello{}
+	plugin.Register(&plugin.Manifest{
+		Name:       "hello",
+		Version:    "0.1.0",
+		APIVersion: "1.0",
+		Beans: func() []*gs.BeanDefinition {
+			return []*gs.BeanDefinition{
+				gs.NewBean(h).Destroy(func(h *hello) { h.stopped = true }),
+			}
+		},
+	})
+
+	assert.Panic(t, func() {
+		plugin.Register(&plugin.Manifest{
+			Name:       "future",
+			APIVersion: "1.1",
+			Beans:      func() []*gs.BeanDefinition { return nil },
+		})
+	}, "api version 1.1 is incompatible with 1.0")
+
+	c := gs.New()
+	err := c.Refresh(gs.AutoClear(false))
+	assert.Nil(t, err)
+	ctx := c.(gs.Context)
+
+	_, err = plugin.Load(ctx, "none")
+	assert.Error(t, err, "plugin \"none\" not found")
+
+	p, err := plugin.Load(ctx, "hello")
+	assert.Nil(t, err)
+	assert.Equal(t, p.Manifest().Version, "0.1.0")
+
+	var greeters []Greeter
+	err = p.Extensions(&greeters)
+	assert.Nil(t, err)
+	assert.Equal(t, len(greeters), 1)
+	assert.Equal(t, greeters[0].Greet(), "hello plugin")
+
+	// 插件的 bean 只能通过插件的子注册表获取。
+	assert.Equal(t, len(p.Registry().Find("hello")), 1)
+	assert.Equal(t, len(ctx.Dynamic().Find("hello")), 0)
+
+	p.Unload()
+	assert.True(t, h.stopped)
+	assert.Equal(t, len(p.Registry().Find("hello")), 0)
+}
+
+type counter struct {
+	Name    string
+	stopped bool
+}
+
+func TestPlugin_Isolation(t *testing.T) {
+
+	a, b := &counter{Name: "a"}, &counter{Name: "b"}
+	plugin.Register(&plugin.Manifest{
+		Name:       "counter-a",
+		APIVersion: "1.0",
+		Beans: func() []*gs.BeanDefinition {
+			return []*gs.BeanDefinition{
+				gs.NewBean(a).Name("counter").Destroy(func(c *counter) { c.stopped = true }),
+			}
+		},
+	})
+	plugin.Register(&plugin.Manifest{
+		Name:       "counter-b",
+		APIVersion: "1.0",
+		Beans: func() []*gs.BeanDefinition {
+			return []*gs.BeanDefinition{
+				gs.NewBean(b).Name("counter").Destroy(func(c *counter) { c.stopped = true }),
+			}
+		},
+	})
+	plugin.Register(&plugin.Manifest{
+		Name:       "shadow",
+		APIVersion: "1.0",
+		Beans: func() []*gs.BeanDefinition {
+			return []*gs.BeanDefinition{
+				gs.NewBean(new(counter)).Name("app"),
+			}
+		},
+	})
+
+	c := gs.New()
+	c.Object(&counter{Name: "app"}).Name("app")
+	err := c.Refresh(gs.AutoClear(false))
+	assert.Nil(t, err)
+	ctx := c.(gs.Context)
+
+	_, err = plugin.Load(ctx, "shadow")
+	assert.Error(t, err, "load plugin \"shadow\" error: plugin shadow: bean .* shadows application bean .*")
+
+	pa, err := plugin.Load(ctx, "counter-a")
+	assert.Nil(t, err)
+	pb, err := plugin.Load(ctx, "counter-b")
+	assert.Nil(t, err)
+
+	// 不同插件的同名 bean 互不影响。
+	var ca, cb *counter
+	assert.Nil(t, pa.Registry().Get(&ca, "counter"))
+	assert.Nil(t, pb.Registry().Get(&cb, "counter"))
+	assert.Equal(t, ca.Name, "a")
+	assert.Equal(t, cb.Name, "b")
+
+	// 卸载一个插件不影响另一个插件。
+	pa.Unload()
+	assert.True(t, a.stopped)
+	assert.False(t, b.stopped)
+	assert.Nil(t, pb.Registry().Get(&cb, "counter"))
+
+	c.Close()
+	assert.True(t, b.stopped)
+}
@@ -0,0 +1,347 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package pool 提供了由容器管理生命周期的对象池，用于复用解析器、大块缓冲区、
+// 模型推理会话等创建代价较高的对象。对象池作为 bean 注册时，容器关闭时会等待
+// 借出的对象归还之后再销毁所有对象。
+//
+//	gs.Provide(pool.New, arg.Value("parser"), "${pool.parser}", arg.Value(pool.Factory{
+//		New: func(ctx context.Context) (interface{}, error) { return NewParser() },
+//	}))
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+)
+
+// ErrPoolClosed 对象池已经关闭。
+var ErrPoolClosed = errors.New("pool: closed")
+
+// Config 对象池配置。
+type Config struct {
+	MaxSize      int           `value:"${max-size:=8}"`        // 最多同时借出的对象数量，不大于 0 时不限制
+	MaxIdle      int           `value:"${max-idle:=8}"`        // 最多保留的空闲对象数量
+	IdleTimeout  time.Duration `value:"${idle-timeout:=10m}"`  // 空闲超过该时间的对象被回收，不大于 0 时不回收
+	DrainTimeout time.Duration `value:"${drain-timeout:=30s}"` // 关闭时等待借出的对象归还的最长时间
+}
+
+// Factory 对象的创建、健康检查和销毁函数。
+type Factory struct {
+	New      func(ctx context.Context) (interface{}, error) // 创建对象
+	Validate func(obj interface{}) error                    // 借出之前检查空闲对象，返回错误时销毁该对象，可选
+	Close    func(obj interface{})                          // 销毁对象，可选
+}
+
+// Stats 对象池的运行状态。
+type Stats struct {
+	Name      string `json:"name"`
+	Active    int    `json:"active"`    // 已借出的对象数量
+	Idle      int    `json:"idle"`      // 空闲的对象数量
+	Created   int64  `json:"created"`   // 创建的对象数量
+	Destroyed int64  `json:"destroyed"` // 销毁的对象数量
+	Borrowed  int64  `json:"borrowed"`  // 借出的次数
+	Invalid   int64  `json:"invalid"`   // 健康检查失败的次数
+	Timeout   int64  `json:"timeout"`   // 等待借出超时的次数
+}
+
+type idleObject struct {
+	obj   interface{}
+	since time.Time
+}
+
+// Pool 对象池，空闲对象按照后进先出的顺序借出，这样长时间不用的对象可以被回收。
+type Pool struct {
+	name    string
+	config  Config
+	factory Factory
+	sem     chan struct{} // 借出的许可，MaxSize 不大于 0 时为 nil
+	stop    chan struct{}
+
+	mutex  sync.Mutex
+	idle   []idleObject
+	active int
+	closed bool
+
+	created   int64
+	destroyed int64
+	borrowed  int64
+	invalid   int64
+	timeout   int64
+}
+
+var (
+	mutex sync.RWMutex
+	pools []*Pool
+)
+
+// New 创建并注册对象池，IdleTimeout 大于 0 时启动回收空闲对象的 goroutine 。
+func New(name string, config Config, factory Factory) (*Pool, error) {
+	if factory.New == nil {
+		return nil, errors.New("pool: factory.New can't be nil")
+	}
+	p := &Pool{
+		name:    name,
+		config:  config,
+		factory: factory,
+		stop:    make(chan struct{}),
+	}
+	if config.MaxSize > 0 {
+		p.sem = make(chan struct{}, config.MaxSize)
+	}
+	if config.IdleTimeout > 0 {
+		go p.evictLoop()
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	pools = append(pools, p)
+	return p, nil
+}
+
+// Name 返回对象池的名称。
+func (p *Pool) Name() string {
+	return p.name
+}
+
+// Get 借出一个对象，优先使用空闲对象，没有空闲对象时创建新的对象。借出的对象必须
+// 通过 Put 归还或者通过 Discard 丢弃。借出的对象达到上限时等待其他对象归还，ctx
+// 结束时返回 ctx.Err() 。
+func (p *Pool) Get(ctx context.Context) (interface{}, error) {
+
+	p.mutex.Lock()
+	closed := p.closed
+	p.mutex.Unlock()
+	if closed {
+		return nil, ErrPoolClosed
+	}
+
+	if p.sem != nil {
+		select {
+		case p.sem <- struct{}{}:
+		case <-ctx.Done():
+			atomic.AddInt64(&p.timeout, 1)
+			return nil, ctx.Err()
+		}
+	}
+
+	obj, err := p.get(ctx)
+	if err != nil {
+		p.release()
+		return nil, err
+	}
+	atomic.AddInt64(&p.borrowed, 1)
+	return obj, nil
+}
+
+func (p *Pool) get(ctx context.Context) (interface{}, error) {
+	for {
+		p.mutex.Lock()
+		if p.closed {
+			p.mutex.Unlock()
+			return nil, ErrPoolClosed
+		}
+		n := len(p.idle)
+		if n == 0 {
+			p.active++
+			p.mutex.Unlock()
+			break
+		}
+		o := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.active++
+		p.mutex.Unlock()
+
+		if p.factory.Validate == nil {
+			return o.obj, nil
+		}
+		err := p.factory.Validate(o.obj)
+		if err == nil {
+			return o.obj, nil
+		}
+		atomic.AddInt64(&p.invalid, 1)
+		log.Warnf("pool %s: invalid object discarded: %v", p.name, err)
+		p.mutex.Lock()
+		p.active--
+		p.mutex.Unlock()
+		p.destroy(o.obj)
+	}
+
+	obj, err := p.factory.New(ctx)
+	if err != nil {
+		p.mutex.Lock()
+		p.active--
+		p.mutex.Unlock()
+		return nil, err
+	}
+	atomic.AddInt64(&p.created, 1)
+	return obj, nil
+}
+
+// Put 归还借出的对象，空闲对象达到上限或者对象池已经关闭时直接销毁。
+func (p *Pool) Put(obj interface{}) {
+	p.mutex.Lock()
+	if !p.closed && len(p.idle) < p.config.MaxIdle {
+		p.idle = append(p.idle, idleObject{obj: obj, since: time.Now()})
+		p.active--
+		p.mutex.Unlock()
+		p.release()
+		return
+	}
+	p.mutex.Unlock()
+	p.Discard(obj)
+}
+
+// Discard 丢弃借出的对象，用于对象在使用过程中损坏的情况。对象销毁之后才减少借出
+// 的数量，这样 Close 返回时所有归还的对象都已经被销毁。
+func (p *Pool) Discard(obj interface{}) {
+	p.destroy(obj)
+	p.mutex.Lock()
+	p.active--
+	p.mutex.Unlock()
+	p.release()
+}
+
+func (p *Pool) release() {
+	if p.sem != nil {
+		<-p.sem
+	}
+}
+
+func (p *Pool) destroy(obj interface{}) {
+	atomic.AddInt64(&p.destroyed, 1)
+	if p.factory.Close != nil {
+		p.factory.Close(obj)
+	}
+}
+
+// evictLoop 定期回收空闲超时的对象。
+func (p *Pool) evictLoop() {
+	ticker := time.NewTicker(p.config.IdleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.evict(time.Now().Add(-p.config.IdleTimeout))
+		}
+	}
+}
+
+// evict 回收 deadline 之前就已经空闲的对象。
+func (p *Pool) evict(deadline time.Time) {
+	p.mutex.Lock()
+	var expired []interface{}
+	i := 0 // 空闲对象按照归还时间排序，最早归还的在前面
+	for ; i < len(p.idle) && p.idle[i].since.Before(deadline); i++ {
+		expired = append(expired, p.idle[i].obj)
+	}
+	p.idle = append(p.idle[:0], p.idle[i:]...)
+	p.mutex.Unlock()
+	for _, obj := range expired {
+		p.destroy(obj)
+	}
+}
+
+// Close 关闭对象池，不再借出对象，等待借出的对象归还之后销毁所有对象，ctx 结束时
+// 不再等待并返回 ctx.Err() ，之后归还的对象会被直接销毁。
+func (p *Pool) Close(ctx context.Context) error {
+
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return nil
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.mutex.Unlock()
+
+	close(p.stop)
+	for _, o := range idle {
+		p.destroy(o.obj)
+	}
+
+	mutex.Lock()
+	for i, v := range pools {
+		if v == p {
+			pools = append(pools[:i:i], pools[i+1:]...)
+			break
+		}
+	}
+	mutex.Unlock()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		p.mutex.Lock()
+		active := p.active
+		p.mutex.Unlock()
+		if active == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			log.Warnf("pool %s: %d objects are not returned", p.name, active)
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Destroy 实现 gs.DisposableBean 接口，容器关闭时在 DrainTimeout 内排空对象池。
+func (p *Pool) Destroy() error {
+	ctx := context.Background()
+	if p.config.DrainTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.config.DrainTimeout)
+		defer cancel()
+	}
+	return p.Close(ctx)
+}
+
+// Stats 返回对象池的运行状态。
+func (p *Pool) Stats() Stats {
+	p.mutex.Lock()
+	active, idle := p.active, len(p.idle)
+	p.mutex.Unlock()
+	return Stats{
+		Name:      p.name,
+		Active:    active,
+		Idle:      idle,
+		Created:   atomic.LoadInt64(&p.created),
+		Destroyed: atomic.LoadInt64(&p.destroyed),
+		Borrowed:  atomic.LoadInt64(&p.borrowed),
+		Invalid:   atomic.LoadInt64(&p.invalid),
+		Timeout:   atomic.LoadInt64(&p.timeout),
+	}
+}
+
+// AllStats 返回所有对象池的运行状态。
+func AllStats() []Stats {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	var ret []Stats
+	for _, p := range pools {
+		ret = append(ret, p.Stats())
+	}
+	return ret
+}
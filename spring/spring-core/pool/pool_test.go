@@ -0,0 +1,146 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pool_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/arg"
+	"github.com/go-spring/spring-core/pool"
+)
+
+type session struct {
+	id     int
+	broken bool
+	closed bool
+}
+
+func newFactory(sessions *[]*session) pool.Factory {
+	return pool.Factory{
+		New: func(ctx context.Context) (interface{}, error) {
+			s := &session{id: len(*sessions) + 1}
+			*sessions = append(*sessions, s)
+			return s, nil
+		},
+		Validate: func(obj interface{}) error {
+			if obj.(*session).broken {
+				return errors.New("broken")
+			}
+			return nil
+		},
+		Close: func(obj interface{}) {
+			obj.(*session).closed = true
+		},
+	}
+}
+
+func TestPool(t *testing.T) {
+
+	var sessions []*session
+	p, err := pool.New("session", pool.Config{MaxSize: 2, MaxIdle: 1}, newFactory(&sessions))
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+	s1, err := p.Get(ctx)
+	assert.Nil(t, err)
+	s2, err := p.Get(ctx)
+	assert.Nil(t, err)
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = p.Get(timeout)
+	assert.Equal(t, err, context.DeadlineExceeded)
+
+	p.Put(s1)
+	p.Put(s2) // 超过 MaxIdle 被销毁
+	assert.True(t, sessions[1].closed)
+
+	s, err := p.Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, s.(*session).id, 1)
+
+	s.(*session).broken = true
+	p.Put(s)
+	s, err = p.Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, s.(*session).id, 3)
+	assert.True(t, sessions[0].closed)
+
+	assert.Equal(t, p.Stats(), pool.Stats{
+		Name:      "session",
+		Active:    1,
+		Created:   3,
+		Destroyed: 2,
+		Borrowed:  4,
+		Invalid:   1,
+		Timeout:   1,
+	})
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		p.Put(s)
+	}()
+	err = p.Close(ctx)
+	assert.Nil(t, err)
+	assert.True(t, sessions[2].closed)
+
+	_, err = p.Get(ctx)
+	assert.Equal(t, err, pool.ErrPoolClosed)
+}
+
+func TestPool_Evict(t *testing.T) {
+
+	var sessions []*session
+	config := pool.Config{MaxIdle: 2, IdleTimeout: 20 * time.Millisecond}
+	p, err := pool.New("evict", config, newFactory(&sessions))
+	assert.Nil(t, err)
+	defer p.Close(context.Background())
+
+	s, err := p.Get(context.Background())
+	assert.Nil(t, err)
+	p.Put(s)
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, p.Stats().Idle, 0)
+	assert.True(t, sessions[0].closed)
+}
+
+func TestPool_Container(t *testing.T) {
+
+	var sessions []*session
+	c := gs.New()
+	c.Property("pool.session.max-size", 1)
+	c.Provide(pool.New, arg.Value("session"), "${pool.session}", arg.Value(newFactory(&sessions)))
+
+	err := c.Refresh(gs.AutoClear(false))
+	assert.Nil(t, err)
+
+	var p *pool.Pool
+	err = c.(gs.Context).Get(&p)
+	assert.Nil(t, err)
+	s, err := p.Get(context.Background())
+	assert.Nil(t, err)
+	p.Put(s)
+
+	c.Close()
+	assert.True(t, sessions[0].closed)
+}
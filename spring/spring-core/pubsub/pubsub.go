@@ -0,0 +1,395 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package pubsub 在 WebSocket 之上实现基于主题的发布订阅，浏览器不需要自定义协议就
+// 可以接收服务端的推送。客户端和服务端之间传递 JSON 格式的帧：
+//
+//	{"type":"subscribe","topic":"orders"}             // 客户端订阅主题
+//	{"type":"unsubscribe","topic":"orders"}           // 客户端取消订阅
+//	{"type":"publish","topic":"chat","data":{...}}    // 客户端发布消息，需要开启 client-publish
+//	{"type":"subscribed","topic":"orders"}            // 服务端确认订阅
+//	{"type":"message","topic":"orders","data":{...}}  // 服务端推送消息
+//	{"type":"error","topic":"orders","error":"..."}   // 服务端拒绝请求
+//
+// 注册为 bean 的 Authorizer 检查客户端是否可以订阅或者发布某个主题。多个实例部署时
+// 注册 Relay ，Publish 发布的消息通过 Relay 转发给所有实例的订阅者，例如：
+//
+//	gs.Provide(pubsub.NewBroker, "${pubsub}", "*?", "?")
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/web"
+)
+
+var logger = log.GetLogger("GS_PUBSUB")
+
+// closeSlowConsumer 发送缓冲已满的连接使用该状态码关闭。
+const closeSlowConsumer = 1008
+
+// Action 客户端请求的操作。
+type Action string
+
+const (
+	Subscribe = Action("subscribe")
+	Publish   = Action("publish")
+)
+
+// 帧的类型。
+const (
+	FrameSubscribe   = "subscribe"
+	FrameUnsubscribe = "unsubscribe"
+	FramePublish     = "publish"
+	FrameSubscribed  = "subscribed"
+	FrameMessage     = "message"
+	FrameError       = "error"
+)
+
+var (
+	ErrForbidden     = errors.New("pubsub: forbidden")
+	ErrTooManyTopics = errors.New("pubsub: too many topics")
+	ErrInvalidFrame  = errors.New("pubsub: invalid frame")
+)
+
+// Frame 客户端和服务端之间传递的帧。
+type Frame struct {
+	Type  string          `json:"type"`
+	Topic string          `json:"topic,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// Authorizer 检查客户端是否可以订阅或者发布主题，返回错误时拒绝，错误信息会发送给
+// 客户端。ctx 是 WebSocket 握手的请求，可以获取认证信息。
+type Authorizer interface {
+	Authorize(ctx web.Context, action Action, topic string) error
+}
+
+// AuthorizerFunc 函数形式的 Authorizer 。
+type AuthorizerFunc func(ctx web.Context, action Action, topic string) error
+
+func (f AuthorizerFunc) Authorize(ctx web.Context, action Action, topic string) error {
+	return f(ctx, action, topic)
+}
+
+// Relay 在多个实例之间转发消息，例如 spring-redigo 基于 redis 的 PUBLISH 和
+// PSUBSCRIBE 的实现。发布的消息需要转发给包括自己在内的所有实例。
+type Relay interface {
+	Publish(ctx context.Context, topic string, data []byte) error
+	Subscribe(fn func(topic string, data []byte)) (cancel func(), err error)
+}
+
+// Config 发布订阅的配置，通常配合 pubsub 前缀一起使用。
+type Config struct {
+	Path          string              `value:"${path:=/ws}"`
+	ClientPublish bool                `value:"${client-publish:=false}"` // 是否允许客户端发布消息
+	MaxTopics     int                 `value:"${max-topics:=100}"`       // 每个连接最多订阅的主题数量
+	SendBuffer    int                 `value:"${send-buffer:=64}"`       // 每个连接等待发送的消息数量，超过时断开连接
+	PingInterval  time.Duration       `value:"${ping-interval:=30s}"`    // 发送 ping 的间隔，两个间隔内没有收到数据时断开连接
+	WriteTimeout  time.Duration       `value:"${write-timeout:=10s}"`
+	WebSocket     web.WebSocketConfig `value:"${websocket}"`
+}
+
+// Broker 管理 WebSocket 连接和主题的订阅关系。
+type Broker struct {
+	config      Config
+	authorizers []Authorizer
+	relay       Relay
+	cancel      func()
+	mutex       sync.RWMutex
+	topics      map[string]map[*client]struct{}
+	clients     map[*client]struct{}
+}
+
+// NewBroker 创建 Broker ，relay 为 nil 时只在本实例内投递消息。
+func NewBroker(config Config, authorizers []Authorizer, relay Relay) (*Broker, error) {
+	if config.SendBuffer <= 0 {
+		return nil, fmt.Errorf("pubsub: invalid send-buffer %d", config.SendBuffer)
+	}
+	b := &Broker{
+		config:      config,
+		authorizers: authorizers,
+		relay:       relay,
+		topics:      make(map[string]map[*client]struct{}),
+		clients:     make(map[*client]struct{}),
+	}
+	if relay != nil {
+		cancel, err := relay.Subscribe(b.deliver)
+		if err != nil {
+			return nil, err
+		}
+		b.cancel = cancel
+	}
+	return b, nil
+}
+
+// Path 返回 WebSocket 的地址。
+func (b *Broker) Path() string {
+	return b.config.Path
+}
+
+// Publish 向主题发布消息，data 被编码为 JSON 。
+func (b *Broker) Publish(ctx context.Context, topic string, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return b.publish(ctx, topic, raw)
+}
+
+func (b *Broker) publish(ctx context.Context, topic string, raw []byte) error {
+	if b.relay != nil {
+		return b.relay.Publish(ctx, topic, raw)
+	}
+	b.deliver(topic, raw)
+	return nil
+}
+
+// deliver 将消息投递给本实例中订阅了主题的连接。
+func (b *Broker) deliver(topic string, data []byte) {
+	msg, err := json.Marshal(&Frame{Type: FrameMessage, Topic: topic, Data: data})
+	if err != nil {
+		logger.Errorf("pubsub: topic %q invalid message: %v", topic, err)
+		return
+	}
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	for c := range b.topics[topic] {
+		c.enqueue(msg)
+	}
+}
+
+// Subscribers 返回订阅了主题的连接数量。
+func (b *Broker) Subscribers(topic string) int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return len(b.topics[topic])
+}
+
+func (b *Broker) authorize(ctx web.Context, action Action, topic string) error {
+	for _, a := range b.authorizers {
+		if err := a.Authorize(ctx, action, topic); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Broker) subscribe(c *client, topic string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	m, ok := b.topics[topic]
+	if !ok {
+		m = make(map[*client]struct{})
+		b.topics[topic] = m
+	}
+	m[c] = struct{}{}
+}
+
+func (b *Broker) unsubscribe(c *client, topic string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if m, ok := b.topics[topic]; ok {
+		delete(m, c)
+		if len(m) == 0 {
+			delete(b.topics, topic)
+		}
+	}
+}
+
+// Handler 返回将请求升级为 WebSocket 并处理订阅的处理函数。
+func (b *Broker) Handler() web.Handler {
+	return web.FUNC(b.serve)
+}
+
+func (b *Broker) serve(ctx web.Context) {
+
+	ws, err := web.UpgradeWebSocket(ctx, b.config.WebSocket)
+	if err != nil {
+		logger.WithContext(ctx.Context()).Infof("pubsub: %v", err)
+		return
+	}
+
+	c := &client{
+		ws:     ws,
+		send:   make(chan []byte, b.config.SendBuffer),
+		done:   make(chan struct{}),
+		topics: make(map[string]struct{}),
+	}
+
+	b.mutex.Lock()
+	b.clients[c] = struct{}{}
+	b.mutex.Unlock()
+
+	defer func() {
+		b.mutex.Lock()
+		delete(b.clients, c)
+		b.mutex.Unlock()
+		for topic := range c.topics {
+			b.unsubscribe(c, topic)
+		}
+		c.close(web.CloseNormal, "")
+	}()
+
+	go c.writeLoop(b.config.PingInterval, b.config.WriteTimeout)
+	b.readLoop(ctx, c)
+}
+
+// readLoop 处理客户端发送的帧，直到连接断开。
+func (b *Broker) readLoop(ctx web.Context, c *client) {
+
+	extend := func() {
+		if d := b.config.PingInterval; d > 0 {
+			_ = c.ws.SetReadDeadline(time.Now().Add(2 * d))
+		}
+	}
+	c.ws.SetPongHandler(func([]byte) { extend() })
+
+	for {
+		extend()
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		var f Frame
+		if err = json.Unmarshal(data, &f); err != nil || f.Topic == "" {
+			c.reply(&Frame{Type: FrameError, Error: ErrInvalidFrame.Error()})
+			continue
+		}
+		switch f.Type {
+		case FrameSubscribe:
+			if _, ok := c.topics[f.Topic]; ok {
+				c.reply(&Frame{Type: FrameSubscribed, Topic: f.Topic})
+				continue
+			}
+			if b.config.MaxTopics > 0 && len(c.topics) >= b.config.MaxTopics {
+				c.reply(&Frame{Type: FrameError, Topic: f.Topic, Error: ErrTooManyTopics.Error()})
+				continue
+			}
+			if err = b.authorize(ctx, Subscribe, f.Topic); err != nil {
+				c.reply(&Frame{Type: FrameError, Topic: f.Topic, Error: err.Error()})
+				continue
+			}
+			c.topics[f.Topic] = struct{}{}
+			b.subscribe(c, f.Topic)
+			c.reply(&Frame{Type: FrameSubscribed, Topic: f.Topic})
+		case FrameUnsubscribe:
+			delete(c.topics, f.Topic)
+			b.unsubscribe(c, f.Topic)
+		case FramePublish:
+			if !b.config.ClientPublish {
+				err = ErrForbidden
+			} else if err = b.authorize(ctx, Publish, f.Topic); err == nil {
+				if len(f.Data) == 0 {
+					f.Data = json.RawMessage("null")
+				}
+				err = b.publish(ctx.Context(), f.Topic, f.Data)
+			}
+			if err != nil {
+				c.reply(&Frame{Type: FrameError, Topic: f.Topic, Error: err.Error()})
+			}
+		default:
+			c.reply(&Frame{Type: FrameError, Topic: f.Topic, Error: ErrInvalidFrame.Error()})
+		}
+	}
+}
+
+// Close 停止转发消息并关闭所有的连接。
+func (b *Broker) Close() {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	for c := range b.clients {
+		c.close(web.CloseGoingAway, "server shutdown")
+	}
+}
+
+// client 一个 WebSocket 连接，topics 只在读取的 goroutine 中访问。
+type client struct {
+	ws     *web.WebSocket
+	send   chan []byte
+	done   chan struct{}
+	once   sync.Once
+	topics map[string]struct{}
+}
+
+// enqueue 将消息放入发送缓冲，缓冲已满说明客户端消费太慢，断开连接。
+func (c *client) enqueue(msg []byte) {
+	select {
+	case c.send <- msg:
+	case <-c.done:
+	default:
+		// 投递时持有 Broker 的锁，在后台关闭连接避免阻塞其他连接。
+		go c.close(closeSlowConsumer, "slow consumer")
+	}
+}
+
+func (c *client) reply(f *Frame) {
+	if msg, err := json.Marshal(f); err == nil {
+		c.enqueue(msg)
+	}
+}
+
+func (c *client) close(code int, text string) {
+	c.once.Do(func() {
+		close(c.done)
+		_ = c.ws.WriteClose(code, text)
+		_ = c.ws.Close()
+	})
+}
+
+// writeLoop 发送缓冲中的消息，并且定期发送 ping 。
+func (c *client) writeLoop(pingInterval, writeTimeout time.Duration) {
+	var tick <-chan time.Time
+	if pingInterval > 0 {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	write := func(typ int, msg []byte) bool {
+		if writeTimeout > 0 {
+			_ = c.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+		}
+		if err := c.ws.WriteMessage(typ, msg); err != nil {
+			c.close(web.CloseGoingAway, "")
+			return false
+		}
+		return true
+	}
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.send:
+			if !write(web.TextMessage, msg) {
+				return
+			}
+		case <-tick:
+			if !write(web.PingMessage, nil) {
+				return
+			}
+		}
+	}
+}
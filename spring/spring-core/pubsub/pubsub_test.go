@@ -0,0 +1,192 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pubsub_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/pubsub"
+	"github.com/go-spring/spring-core/web"
+)
+
+func newConfig(t *testing.T, props string) pubsub.Config {
+	p, err := conf.Bytes([]byte(props), ".properties")
+	assert.Nil(t, err)
+	var config pubsub.Config
+	err = p.Bind(&config, conf.Key("pubsub"))
+	assert.Nil(t, err)
+	return config
+}
+
+func newServer(b *pubsub.Broker) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := web.NewBaseContext(b.Path(), nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+		b.Handler().Invoke(ctx)
+	}))
+}
+
+type conn struct {
+	t  *testing.T
+	ws *web.WebSocket
+}
+
+func dial(t *testing.T, s *httptest.Server, header http.Header) *conn {
+	ws, err := web.DialWebSocket("ws"+strings.TrimPrefix(s.URL, "http")+"/ws", header)
+	assert.Nil(t, err)
+	return &conn{t: t, ws: ws}
+}
+
+func (c *conn) send(typ, topic, data string) {
+	f := pubsub.Frame{Type: typ, Topic: topic}
+	if data != "" {
+		f.Data = json.RawMessage(data)
+	}
+	b, _ := json.Marshal(f)
+	err := c.ws.WriteMessage(web.TextMessage, b)
+	assert.Nil(c.t, err)
+}
+
+func (c *conn) recv() pubsub.Frame {
+	_ = c.ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := c.ws.ReadMessage()
+	assert.Nil(c.t, err)
+	var f pubsub.Frame
+	err = json.Unmarshal(data, &f)
+	assert.Nil(c.t, err)
+	return f
+}
+
+func TestBroker(t *testing.T) {
+
+	config := newConfig(t, `
+		pubsub.max-topics=2
+		pubsub.websocket.max-message-size=1024
+	`)
+	assert.Equal(t, config.Path, "/ws")
+	assert.False(t, config.ClientPublish)
+
+	// 只有管理员可以订阅 admin. 开头的主题。
+	authorizer := pubsub.AuthorizerFunc(func(ctx web.Context, action pubsub.Action, topic string) error {
+		if strings.HasPrefix(topic, "admin.") && ctx.Header("X-Role") != "admin" {
+			return pubsub.ErrForbidden
+		}
+		return nil
+	})
+	b, err := pubsub.NewBroker(config, []pubsub.Authorizer{authorizer}, nil)
+	assert.Nil(t, err)
+	s := newServer(b)
+	defer s.Close()
+	defer b.Close()
+
+	c := dial(t, s, nil)
+	c.send(pubsub.FrameSubscribe, "orders", "")
+	assert.Equal(t, c.recv(), pubsub.Frame{Type: pubsub.FrameSubscribed, Topic: "orders"})
+
+	c.send(pubsub.FrameSubscribe, "admin.audit", "")
+	assert.Equal(t, c.recv(), pubsub.Frame{Type: pubsub.FrameError, Topic: "admin.audit", Error: "pubsub: forbidden"})
+
+	c.send(pubsub.FramePublish, "orders", `{"id":1}`)
+	assert.Equal(t, c.recv(), pubsub.Frame{Type: pubsub.FrameError, Topic: "orders", Error: "pubsub: forbidden"})
+
+	c.send(pubsub.FrameSubscribe, "users", "")
+	assert.Equal(t, c.recv().Type, pubsub.FrameSubscribed)
+	c.send(pubsub.FrameSubscribe, "items", "")
+	assert.Equal(t, c.recv(), pubsub.Frame{Type: pubsub.FrameError, Topic: "items", Error: "pubsub: too many topics"})
+
+	admin := dial(t, s, http.Header{"X-Role": {"admin"}})
+	admin.send(pubsub.FrameSubscribe, "admin.audit", "")
+	assert.Equal(t, admin.recv().Type, pubsub.FrameSubscribed)
+
+	err = b.Publish(context.Background(), "orders", map[string]int{"id": 7})
+	assert.Nil(t, err)
+	assert.Equal(t, c.recv(), pubsub.Frame{Type: pubsub.FrameMessage, Topic: "orders", Data: json.RawMessage(`{"id":7}`)})
+
+	c.send(pubsub.FrameUnsubscribe, "orders", "")
+	c.send(pubsub.FrameSubscribe, "users", "")
+	assert.Equal(t, c.recv().Type, pubsub.FrameSubscribed)
+	assert.Equal(t, b.Subscribers("orders"), 0)
+
+	// 连接断开后取消订阅。
+	_ = admin.ws.Close()
+	for b.Subscribers("admin.audit") != 0 {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// memoryRelay 模拟多个实例共享的消息通道。
+type memoryRelay struct {
+	mutex sync.Mutex
+	subs  []func(topic string, data []byte)
+}
+
+func (r *memoryRelay) Publish(ctx context.Context, topic string, data []byte) error {
+	r.mutex.Lock()
+	subs := append([]func(string, []byte){}, r.subs...)
+	r.mutex.Unlock()
+	for _, fn := range subs {
+		fn(topic, data)
+	}
+	return nil
+}
+
+func (r *memoryRelay) Subscribe(fn func(topic string, data []byte)) (func(), error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.subs = append(r.subs, fn)
+	return func() {}, nil
+}
+
+func TestBroker_Relay(t *testing.T) {
+
+	config := newConfig(t, "pubsub.client-publish=true")
+	relay := &memoryRelay{}
+
+	b1, err := pubsub.NewBroker(config, nil, relay)
+	assert.Nil(t, err)
+	s1 := newServer(b1)
+	defer s1.Close()
+	defer b1.Close()
+
+	b2, err := pubsub.NewBroker(config, nil, relay)
+	assert.Nil(t, err)
+	s2 := newServer(b2)
+	defer s2.Close()
+	defer b2.Close()
+
+	c1 := dial(t, s1, nil)
+	c1.send(pubsub.FrameSubscribe, "chat", "")
+	assert.Equal(t, c1.recv().Type, pubsub.FrameSubscribed)
+
+	c2 := dial(t, s2, nil)
+	c2.send(pubsub.FrameSubscribe, "chat", "")
+	assert.Equal(t, c2.recv().Type, pubsub.FrameSubscribed)
+
+	// 客户端发布到一个实例的消息被推送给所有实例的订阅者。
+	c2.send(pubsub.FramePublish, "chat", `"hi"`)
+	want := pubsub.Frame{Type: pubsub.FrameMessage, Topic: "chat", Data: json.RawMessage(`"hi"`)}
+	assert.Equal(t, c1.recv(), want)
+	assert.Equal(t, c2.recv(), want)
+}
@@ -0,0 +1,1609 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errWrongType   = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errNotInteger  = errors.New("ERR value is not an integer or out of range")
+	errNotFloat    = errors.New("ERR value is not a valid float")
+	errSyntax      = errors.New("ERR syntax error")
+	errOutOfRange  = errors.New("ERR index out of range")
+	errNoSuchKey   = errors.New("ERR no such key")
+	errNumberOfArg = errors.New("ERR wrong number of arguments")
+)
+
+type memoryEntry struct {
+	value    interface{} // string、[]string、map[string]string、map[string]struct{}、map[string]float64
+	expireAt time.Time
+}
+
+type memoryCommand struct {
+	minArgs int
+	fn      func(m *MemoryConnPool, args []string) (interface{}, error)
+}
+
+// MemoryConnPool 基于内存的 ConnPool 实现，支持常用的字符串、哈希、列表、集合、
+// 有序集合以及键过期命令，适合在开发和测试环境中替代真实的 redis 服务。
+type MemoryConnPool struct {
+	mutex sync.Mutex
+	data  map[string]*memoryEntry
+	now   func() time.Time
+}
+
+// NewMemoryConnPool 创建基于内存的 ConnPool 对象。
+func NewMemoryConnPool() *MemoryConnPool {
+	return &MemoryConnPool{
+		data: make(map[string]*memoryEntry),
+		now:  time.Now,
+	}
+}
+
+// NewMemoryClient 创建使用内存存储的 redis 客户端。
+func NewMemoryClient() (*Client, error) {
+	return NewClient(NewMemoryConnPool())
+}
+
+func (m *MemoryConnPool) Exec(ctx context.Context, cmd string, args []interface{}) (interface{}, error) {
+	c, ok := memoryCommands[strings.ToUpper(cmd)]
+	if !ok {
+		return nil, fmt.Errorf("ERR unknown command '%s'", cmd)
+	}
+	if len(args) < c.minArgs {
+		return nil, fmt.Errorf("%w for '%s' command", errNumberOfArg, strings.ToLower(cmd))
+	}
+	strArgs := make([]string, len(args))
+	for i, arg := range args {
+		strArgs[i] = memoryString(arg)
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return c.fn(m, strArgs)
+}
+
+func memoryString(v interface{}) string {
+	switch r := v.(type) {
+	case string:
+		return r
+	case []byte:
+		return string(r)
+	case float32:
+		return strconv.FormatFloat(float64(r), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(r, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(r)
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// get 返回未过期的键值，过期的键会被删除。
+func (m *MemoryConnPool) get(key string) *memoryEntry {
+	e, ok := m.data[key]
+	if !ok {
+		return nil
+	}
+	if !e.expireAt.IsZero() && !m.now().Before(e.expireAt) {
+		delete(m.data, key)
+		return nil
+	}
+	return e
+}
+
+func (m *MemoryConnPool) getString(key string) (string, bool, error) {
+	e := m.get(key)
+	if e == nil {
+		return "", false, nil
+	}
+	s, ok := e.value.(string)
+	if !ok {
+		return "", false, errWrongType
+	}
+	return s, true, nil
+}
+
+func (m *MemoryConnPool) getHash(key string, create bool) (map[string]string, error) {
+	e := m.get(key)
+	if e == nil {
+		if !create {
+			return nil, nil
+		}
+		h := make(map[string]string)
+		m.data[key] = &memoryEntry{value: h}
+		return h, nil
+	}
+	h, ok := e.value.(map[string]string)
+	if !ok {
+		return nil, errWrongType
+	}
+	return h, nil
+}
+
+func (m *MemoryConnPool) getList(key string) (*memoryEntry, []string, error) {
+	e := m.get(key)
+	if e == nil {
+		return nil, nil, nil
+	}
+	l, ok := e.value.([]string)
+	if !ok {
+		return nil, nil, errWrongType
+	}
+	return e, l, nil
+}
+
+func (m *MemoryConnPool) getSet(key string, create bool) (map[string]struct{}, error) {
+	e := m.get(key)
+	if e == nil {
+		if !create {
+			return nil, nil
+		}
+		s := make(map[string]struct{})
+		m.data[key] = &memoryEntry{value: s}
+		return s, nil
+	}
+	s, ok := e.value.(map[string]struct{})
+	if !ok {
+		return nil, errWrongType
+	}
+	return s, nil
+}
+
+func (m *MemoryConnPool) getZSet(key string, create bool) (map[string]float64, error) {
+	e := m.get(key)
+	if e == nil {
+		if !create {
+			return nil, nil
+		}
+		z := make(map[string]float64)
+		m.data[key] = &memoryEntry{value: z}
+		return z, nil
+	}
+	z, ok := e.value.(map[string]float64)
+	if !ok {
+		return nil, errWrongType
+	}
+	return z, nil
+}
+
+// removeIfEmpty 容器类型的值为空时删除对应的键，与 redis 的行为保持一致。
+func (m *MemoryConnPool) removeIfEmpty(key string, n int) {
+	if n == 0 {
+		delete(m.data, key)
+	}
+}
+
+func parseInt(s string) (int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, errNotInteger
+	}
+	return n, nil
+}
+
+func parseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errNotFloat
+	}
+	return f, nil
+}
+
+// normalizeRange 将 redis 风格的 [start,stop] 区间转换成切片下标，ok 为 false 表示区间为空。
+func normalizeRange(start, stop int64, n int) (int, int, bool) {
+	size := int64(n)
+	if start < 0 {
+		start += size
+	}
+	if stop < 0 {
+		stop += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+	if start > stop || start >= size {
+		return 0, 0, false
+	}
+	return int(start), int(stop) + 1, true
+}
+
+func stringsReply(s []string) []interface{} {
+	r := make([]interface{}, len(s))
+	for i, v := range s {
+		r[i] = v
+	}
+	return r
+}
+
+var memoryCommands = map[string]memoryCommand{}
+
+func init() {
+
+	memoryCommands["PING"] = memoryCommand{0, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		if len(args) > 0 {
+			return args[0], nil
+		}
+		return "PONG", nil
+	}}
+
+	memoryCommands["FLUSHALL"] = memoryCommand{0, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		m.data = make(map[string]*memoryEntry)
+		return "OK", nil
+	}}
+	memoryCommands["FLUSHDB"] = memoryCommands["FLUSHALL"]
+
+	memoryCommands["DBSIZE"] = memoryCommand{0, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		var n int64
+		for key := range m.data {
+			if m.get(key) != nil {
+				n++
+			}
+		}
+		return n, nil
+	}}
+
+	// Keys
+
+	memoryCommands["DEL"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		var n int64
+		for _, key := range args {
+			if m.get(key) != nil {
+				delete(m.data, key)
+				n++
+			}
+		}
+		return n, nil
+	}}
+	memoryCommands["UNLINK"] = memoryCommands["DEL"]
+
+	memoryCommands["EXISTS"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		var n int64
+		for _, key := range args {
+			if m.get(key) != nil {
+				n++
+			}
+		}
+		return n, nil
+	}}
+
+	expire := func(unit time.Duration) func(m *MemoryConnPool, args []string) (interface{}, error) {
+		return func(m *MemoryConnPool, args []string) (interface{}, error) {
+			n, err := parseInt(args[1])
+			if err != nil {
+				return nil, err
+			}
+			e := m.get(args[0])
+			if e == nil {
+				return int64(0), nil
+			}
+			if n <= 0 {
+				delete(m.data, args[0])
+				return int64(1), nil
+			}
+			e.expireAt = m.now().Add(time.Duration(n) * unit)
+			return int64(1), nil
+		}
+	}
+	memoryCommands["EXPIRE"] = memoryCommand{2, expire(time.Second)}
+	memoryCommands["PEXPIRE"] = memoryCommand{2, expire(time.Millisecond)}
+
+	ttl := func(unit time.Duration) func(m *MemoryConnPool, args []string) (interface{}, error) {
+		return func(m *MemoryConnPool, args []string) (interface{}, error) {
+			e := m.get(args[0])
+			if e == nil {
+				return int64(-2), nil
+			}
+			if e.expireAt.IsZero() {
+				return int64(-1), nil
+			}
+			d := e.expireAt.Sub(m.now())
+			return int64((d + unit - 1) / unit), nil
+		}
+	}
+	memoryCommands["TTL"] = memoryCommand{1, ttl(time.Second)}
+	memoryCommands["PTTL"] = memoryCommand{1, ttl(time.Millisecond)}
+
+	memoryCommands["PERSIST"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		e := m.get(args[0])
+		if e == nil || e.expireAt.IsZero() {
+			return int64(0), nil
+		}
+		e.expireAt = time.Time{}
+		return int64(1), nil
+	}}
+
+	memoryCommands["KEYS"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		var keys []string
+		for key := range m.data {
+			if m.get(key) == nil {
+				continue
+			}
+			if ok, _ := path.Match(args[0], key); ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		return stringsReply(keys), nil
+	}}
+
+	memoryCommands["TYPE"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		e := m.get(args[0])
+		if e == nil {
+			return "none", nil
+		}
+		switch e.value.(type) {
+		case string:
+			return "string", nil
+		case []string:
+			return "list", nil
+		case map[string]string:
+			return "hash", nil
+		case map[string]struct{}:
+			return "set", nil
+		default:
+			return "zset", nil
+		}
+	}}
+
+	// Strings
+
+	memoryCommands["GET"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		s, ok, err := m.getString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrNil()
+		}
+		return s, nil
+	}}
+
+	memoryCommands["SET"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		var (
+			nx, xx, keepTTL bool
+			expireAt        time.Time
+		)
+		for i := 2; i < len(args); i++ {
+			switch opt := strings.ToUpper(args[i]); opt {
+			case "NX":
+				nx = true
+			case "XX":
+				xx = true
+			case "KEEPTTL":
+				keepTTL = true
+			case "EX", "PX":
+				if i+1 >= len(args) {
+					return nil, errSyntax
+				}
+				n, err := parseInt(args[i+1])
+				if err != nil {
+					return nil, err
+				}
+				unit := time.Second
+				if opt == "PX" {
+					unit = time.Millisecond
+				}
+				expireAt = m.now().Add(time.Duration(n) * unit)
+				i++
+			default:
+				return nil, errSyntax
+			}
+		}
+		e := m.get(args[0])
+		if (nx && e != nil) || (xx && e == nil) {
+			return nil, ErrNil()
+		}
+		if keepTTL && e != nil {
+			expireAt = e.expireAt
+		}
+		m.data[args[0]] = &memoryEntry{value: args[1], expireAt: expireAt}
+		return "OK", nil
+	}}
+
+	memoryCommands["SETNX"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		if m.get(args[0]) != nil {
+			return int64(0), nil
+		}
+		m.data[args[0]] = &memoryEntry{value: args[1]}
+		return int64(1), nil
+	}}
+
+	memoryCommands["SETEX"] = memoryCommand{3, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		n, err := parseInt(args[1])
+		if err != nil {
+			return nil, err
+		}
+		expireAt := m.now().Add(time.Duration(n) * time.Second)
+		m.data[args[0]] = &memoryEntry{value: args[2], expireAt: expireAt}
+		return "OK", nil
+	}}
+
+	memoryCommands["GETSET"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		s, ok, err := m.getString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		m.data[args[0]] = &memoryEntry{value: args[1]}
+		if !ok {
+			return nil, ErrNil()
+		}
+		return s, nil
+	}}
+
+	memoryCommands["GETDEL"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		s, ok, err := m.getString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrNil()
+		}
+		delete(m.data, args[0])
+		return s, nil
+	}}
+
+	memoryCommands["APPEND"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		s, ok, err := m.getString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		s += args[1]
+		if ok {
+			m.data[args[0]].value = s
+		} else {
+			m.data[args[0]] = &memoryEntry{value: s}
+		}
+		return int64(len(s)), nil
+	}}
+
+	memoryCommands["STRLEN"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		s, _, err := m.getString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return int64(len(s)), nil
+	}}
+
+	incrBy := func(m *MemoryConnPool, key string, delta int64) (interface{}, error) {
+		s, ok, err := m.getString(key)
+		if err != nil {
+			return nil, err
+		}
+		var n int64
+		if ok {
+			if n, err = parseInt(s); err != nil {
+				return nil, err
+			}
+		}
+		n += delta
+		if ok {
+			m.data[key].value = strconv.FormatInt(n, 10)
+		} else {
+			m.data[key] = &memoryEntry{value: strconv.FormatInt(n, 10)}
+		}
+		return n, nil
+	}
+
+	memoryCommands["INCR"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		return incrBy(m, args[0], 1)
+	}}
+
+	memoryCommands["DECR"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		return incrBy(m, args[0], -1)
+	}}
+
+	memoryCommands["INCRBY"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		n, err := parseInt(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return incrBy(m, args[0], n)
+	}}
+
+	memoryCommands["DECRBY"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		n, err := parseInt(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return incrBy(m, args[0], -n)
+	}}
+
+	memoryCommands["INCRBYFLOAT"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		delta, err := parseFloat(args[1])
+		if err != nil {
+			return nil, err
+		}
+		s, ok, err := m.getString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		var f float64
+		if ok {
+			if f, err = parseFloat(s); err != nil {
+				return nil, err
+			}
+		}
+		s = formatFloat(f + delta)
+		if ok {
+			m.data[args[0]].value = s
+		} else {
+			m.data[args[0]] = &memoryEntry{value: s}
+		}
+		return s, nil
+	}}
+
+	memoryCommands["MGET"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		r := make([]interface{}, len(args))
+		for i, key := range args {
+			if s, ok, _ := m.getString(key); ok {
+				r[i] = s
+			}
+		}
+		return r, nil
+	}}
+
+	memoryCommands["MSET"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		if len(args)%2 != 0 {
+			return nil, errNumberOfArg
+		}
+		for i := 0; i < len(args); i += 2 {
+			m.data[args[i]] = &memoryEntry{value: args[i+1]}
+		}
+		return "OK", nil
+	}}
+
+	// Hashes
+
+	memoryCommands["HSET"] = memoryCommand{3, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		if len(args)%2 != 1 {
+			return nil, errNumberOfArg
+		}
+		h, err := m.getHash(args[0], true)
+		if err != nil {
+			return nil, err
+		}
+		var n int64
+		for i := 1; i < len(args); i += 2 {
+			if _, ok := h[args[i]]; !ok {
+				n++
+			}
+			h[args[i]] = args[i+1]
+		}
+		return n, nil
+	}}
+	memoryCommands["HMSET"] = memoryCommand{3, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		if _, err := memoryCommands["HSET"].fn(m, args); err != nil {
+			return nil, err
+		}
+		return "OK", nil
+	}}
+
+	memoryCommands["HSETNX"] = memoryCommand{3, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		h, err := m.getHash(args[0], true)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := h[args[1]]; ok {
+			return int64(0), nil
+		}
+		h[args[1]] = args[2]
+		return int64(1), nil
+	}}
+
+	memoryCommands["HGET"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		h, err := m.getHash(args[0], false)
+		if err != nil {
+			return nil, err
+		}
+		v, ok := h[args[1]]
+		if !ok {
+			return nil, ErrNil()
+		}
+		return v, nil
+	}}
+
+	memoryCommands["HMGET"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		h, err := m.getHash(args[0], false)
+		if err != nil {
+			return nil, err
+		}
+		r := make([]interface{}, len(args)-1)
+		for i, field := range args[1:] {
+			if v, ok := h[field]; ok {
+				r[i] = v
+			}
+		}
+		return r, nil
+	}}
+
+	memoryCommands["HDEL"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		h, err := m.getHash(args[0], false)
+		if err != nil || h == nil {
+			return int64(0), err
+		}
+		var n int64
+		for _, field := range args[1:] {
+			if _, ok := h[field]; ok {
+				delete(h, field)
+				n++
+			}
+		}
+		m.removeIfEmpty(args[0], len(h))
+		return n, nil
+	}}
+
+	memoryCommands["HEXISTS"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		h, err := m.getHash(args[0], false)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := h[args[1]]; ok {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	}}
+
+	memoryCommands["HLEN"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		h, err := m.getHash(args[0], false)
+		if err != nil {
+			return nil, err
+		}
+		return int64(len(h)), nil
+	}}
+
+	hashFields := func(h map[string]string) []string {
+		fields := make([]string, 0, len(h))
+		for field := range h {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		return fields
+	}
+
+	memoryCommands["HKEYS"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		h, err := m.getHash(args[0], false)
+		if err != nil {
+			return nil, err
+		}
+		return stringsReply(hashFields(h)), nil
+	}}
+
+	memoryCommands["HVALS"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		h, err := m.getHash(args[0], false)
+		if err != nil {
+			return nil, err
+		}
+		var r []interface{}
+		for _, field := range hashFields(h) {
+			r = append(r, h[field])
+		}
+		return r, nil
+	}}
+
+	memoryCommands["HGETALL"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		h, err := m.getHash(args[0], false)
+		if err != nil {
+			return nil, err
+		}
+		var r []interface{}
+		for _, field := range hashFields(h) {
+			r = append(r, field, h[field])
+		}
+		return r, nil
+	}}
+
+	memoryCommands["HINCRBY"] = memoryCommand{3, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		delta, err := parseInt(args[2])
+		if err != nil {
+			return nil, err
+		}
+		h, err := m.getHash(args[0], true)
+		if err != nil {
+			return nil, err
+		}
+		var n int64
+		if v, ok := h[args[1]]; ok {
+			if n, err = parseInt(v); err != nil {
+				return nil, err
+			}
+		}
+		n += delta
+		h[args[1]] = strconv.FormatInt(n, 10)
+		return n, nil
+	}}
+
+	memoryCommands["HINCRBYFLOAT"] = memoryCommand{3, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		delta, err := parseFloat(args[2])
+		if err != nil {
+			return nil, err
+		}
+		h, err := m.getHash(args[0], true)
+		if err != nil {
+			return nil, err
+		}
+		var f float64
+		if v, ok := h[args[1]]; ok {
+			if f, err = parseFloat(v); err != nil {
+				return nil, err
+			}
+		}
+		s := formatFloat(f + delta)
+		h[args[1]] = s
+		return s, nil
+	}}
+
+	// Lists
+
+	push := func(left, exist bool) func(m *MemoryConnPool, args []string) (interface{}, error) {
+		return func(m *MemoryConnPool, args []string) (interface{}, error) {
+			e, l, err := m.getList(args[0])
+			if err != nil {
+				return nil, err
+			}
+			if e == nil {
+				if exist {
+					return int64(0), nil
+				}
+				e = &memoryEntry{}
+				m.data[args[0]] = e
+			}
+			for _, v := range args[1:] {
+				if left {
+					l = append([]string{v}, l...)
+				} else {
+					l = append(l, v)
+				}
+			}
+			e.value = l
+			return int64(len(l)), nil
+		}
+	}
+	memoryCommands["LPUSH"] = memoryCommand{2, push(true, false)}
+	memoryCommands["RPUSH"] = memoryCommand{2, push(false, false)}
+	memoryCommands["LPUSHX"] = memoryCommand{2, push(true, true)}
+	memoryCommands["RPUSHX"] = memoryCommand{2, push(false, true)}
+
+	pop := func(left bool) func(m *MemoryConnPool, args []string) (interface{}, error) {
+		return func(m *MemoryConnPool, args []string) (interface{}, error) {
+			count := int64(1)
+			if len(args) > 1 {
+				n, err := parseInt(args[1])
+				if err != nil {
+					return nil, err
+				}
+				count = n
+			}
+			e, l, err := m.getList(args[0])
+			if err != nil {
+				return nil, err
+			}
+			if e == nil {
+				return nil, ErrNil()
+			}
+			if count > int64(len(l)) {
+				count = int64(len(l))
+			}
+			var popped []string
+			if left {
+				popped, l = l[:count], l[count:]
+			} else {
+				popped = make([]string, 0, count)
+				for i := len(l) - 1; i >= len(l)-int(count); i-- {
+					popped = append(popped, l[i])
+				}
+				l = l[:len(l)-int(count)]
+			}
+			e.value = l
+			m.removeIfEmpty(args[0], len(l))
+			if len(args) > 1 {
+				return stringsReply(popped), nil
+			}
+			return popped[0], nil
+		}
+	}
+	memoryCommands["LPOP"] = memoryCommand{1, pop(true)}
+	memoryCommands["RPOP"] = memoryCommand{1, pop(false)}
+
+	memoryCommands["LLEN"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		_, l, err := m.getList(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return int64(len(l)), nil
+	}}
+
+	memoryCommands["LINDEX"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		index, err := parseInt(args[1])
+		if err != nil {
+			return nil, err
+		}
+		_, l, err := m.getList(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if index < 0 {
+			index += int64(len(l))
+		}
+		if index < 0 || index >= int64(len(l)) {
+			return nil, ErrNil()
+		}
+		return l[index], nil
+	}}
+
+	memoryCommands["LSET"] = memoryCommand{3, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		index, err := parseInt(args[1])
+		if err != nil {
+			return nil, err
+		}
+		e, l, err := m.getList(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			return nil, errNoSuchKey
+		}
+		if index < 0 {
+			index += int64(len(l))
+		}
+		if index < 0 || index >= int64(len(l)) {
+			return nil, errOutOfRange
+		}
+		l[index] = args[2]
+		return "OK", nil
+	}}
+
+	memoryCommands["LRANGE"] = memoryCommand{3, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		start, err := parseInt(args[1])
+		if err != nil {
+			return nil, err
+		}
+		stop, err := parseInt(args[2])
+		if err != nil {
+			return nil, err
+		}
+		_, l, err := m.getList(args[0])
+		if err != nil {
+			return nil, err
+		}
+		i, j, ok := normalizeRange(start, stop, len(l))
+		if !ok {
+			return []interface{}{}, nil
+		}
+		return stringsReply(l[i:j]), nil
+	}}
+
+	memoryCommands["LTRIM"] = memoryCommand{3, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		start, err := parseInt(args[1])
+		if err != nil {
+			return nil, err
+		}
+		stop, err := parseInt(args[2])
+		if err != nil {
+			return nil, err
+		}
+		e, l, err := m.getList(args[0])
+		if err != nil || e == nil {
+			return "OK", err
+		}
+		i, j, ok := normalizeRange(start, stop, len(l))
+		if !ok {
+			delete(m.data, args[0])
+			return "OK", nil
+		}
+		e.value = append([]string(nil), l[i:j]...)
+		return "OK", nil
+	}}
+
+	memoryCommands["LREM"] = memoryCommand{3, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		count, err := parseInt(args[1])
+		if err != nil {
+			return nil, err
+		}
+		e, l, err := m.getList(args[0])
+		if err != nil || e == nil {
+			return int64(0), err
+		}
+		var removed int64
+		limit := count
+		if limit < 0 {
+			limit = -limit
+		}
+		keep := make([]string, 0, len(l))
+		if count >= 0 {
+			for _, v := range l {
+				if v == args[2] && (limit == 0 || removed < limit) {
+					removed++
+					continue
+				}
+				keep = append(keep, v)
+			}
+		} else {
+			for i := len(l) - 1; i >= 0; i-- {
+				if l[i] == args[2] && removed < limit {
+					removed++
+					continue
+				}
+				keep = append([]string{l[i]}, keep...)
+			}
+		}
+		e.value = keep
+		m.removeIfEmpty(args[0], len(keep))
+		return removed, nil
+	}}
+
+	// Sets
+
+	sortedMembers := func(s map[string]struct{}) []string {
+		members := make([]string, 0, len(s))
+		for member := range s {
+			members = append(members, member)
+		}
+		sort.Strings(members)
+		return members
+	}
+
+	memoryCommands["SADD"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		s, err := m.getSet(args[0], true)
+		if err != nil {
+			return nil, err
+		}
+		var n int64
+		for _, member := range args[1:] {
+			if _, ok := s[member]; !ok {
+				s[member] = struct{}{}
+				n++
+			}
+		}
+		return n, nil
+	}}
+
+	memoryCommands["SREM"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		s, err := m.getSet(args[0], false)
+		if err != nil || s == nil {
+			return int64(0), err
+		}
+		var n int64
+		for _, member := range args[1:] {
+			if _, ok := s[member]; ok {
+				delete(s, member)
+				n++
+			}
+		}
+		m.removeIfEmpty(args[0], len(s))
+		return n, nil
+	}}
+
+	memoryCommands["SISMEMBER"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		s, err := m.getSet(args[0], false)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := s[args[1]]; ok {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	}}
+
+	memoryCommands["SCARD"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		s, err := m.getSet(args[0], false)
+		if err != nil {
+			return nil, err
+		}
+		return int64(len(s)), nil
+	}}
+
+	memoryCommands["SMEMBERS"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		s, err := m.getSet(args[0], false)
+		if err != nil {
+			return nil, err
+		}
+		return stringsReply(sortedMembers(s)), nil
+	}}
+
+	// Sorted Sets
+
+	rangeByRank := func(z map[string]float64, reverse bool) []string {
+		members := make([]string, 0, len(z))
+		for member := range z {
+			members = append(members, member)
+		}
+		sort.Slice(members, func(i, j int) bool {
+			a, b := members[i], members[j]
+			if reverse {
+				a, b = b, a
+			}
+			if z[a] != z[b] {
+				return z[a] < z[b]
+			}
+			return a < b
+		})
+		return members
+	}
+
+	memoryCommands["ZADD"] = memoryCommand{3, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		var nx, xx bool
+		i := 1
+		for ; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+				continue
+			case "XX":
+				xx = true
+				continue
+			}
+			break
+		}
+		if (len(args)-i)%2 != 0 || i == len(args) {
+			return nil, errSyntax
+		}
+		z, err := m.getZSet(args[0], true)
+		if err != nil {
+			return nil, err
+		}
+		var n int64
+		for ; i < len(args); i += 2 {
+			score, err := parseFloat(args[i])
+			if err != nil {
+				return nil, err
+			}
+			_, ok := z[args[i+1]]
+			if (nx && ok) || (xx && !ok) {
+				continue
+			}
+			if !ok {
+				n++
+			}
+			z[args[i+1]] = score
+		}
+		m.removeIfEmpty(args[0], len(z))
+		return n, nil
+	}}
+
+	memoryCommands["ZINCRBY"] = memoryCommand{3, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		delta, err := parseFloat(args[1])
+		if err != nil {
+			return nil, err
+		}
+		z, err := m.getZSet(args[0], true)
+		if err != nil {
+			return nil, err
+		}
+		z[args[2]] += delta
+		return formatFloat(z[args[2]]), nil
+	}}
+
+	memoryCommands["ZSCORE"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		z, err := m.getZSet(args[0], false)
+		if err != nil {
+			return nil, err
+		}
+		score, ok := z[args[1]]
+		if !ok {
+			return nil, ErrNil()
+		}
+		return formatFloat(score), nil
+	}}
+
+	memoryCommands["ZREM"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		z, err := m.getZSet(args[0], false)
+		if err != nil || z == nil {
+			return int64(0), err
+		}
+		var n int64
+		for _, member := range args[1:] {
+			if _, ok := z[member]; ok {
+				delete(z, member)
+				n++
+			}
+		}
+		m.removeIfEmpty(args[0], len(z))
+		return n, nil
+	}}
+
+	memoryCommands["ZCARD"] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		z, err := m.getZSet(args[0], false)
+		if err != nil {
+			return nil, err
+		}
+		return int64(len(z)), nil
+	}}
+
+	zRange := func(reverse bool) func(m *MemoryConnPool, args []string) (interface{}, error) {
+		return func(m *MemoryConnPool, args []string) (interface{}, error) {
+			start, err := parseInt(args[1])
+			if err != nil {
+				return nil, err
+			}
+			stop, err := parseInt(args[2])
+			if err != nil {
+				return nil, err
+			}
+			withScores := len(args) > 3 && strings.ToUpper(args[3]) == "WITHSCORES"
+			z, err := m.getZSet(args[0], false)
+			if err != nil {
+				return nil, err
+			}
+			members := rangeByRank(z, reverse)
+			i, j, ok := normalizeRange(start, stop, len(members))
+			if !ok {
+				return []interface{}{}, nil
+			}
+			var r []interface{}
+			for _, member := range members[i:j] {
+				r = append(r, member)
+				if withScores {
+					r = append(r, formatFloat(z[member]))
+				}
+			}
+			return r, nil
+		}
+	}
+	memoryCommands["ZRANGE"] = memoryCommand{3, zRange(false)}
+	memoryCommands["ZREVRANGE"] = memoryCommand{3, zRange(true)}
+
+	zRank := func(reverse bool) func(m *MemoryConnPool, args []string) (interface{}, error) {
+		return func(m *MemoryConnPool, args []string) (interface{}, error) {
+			z, err := m.getZSet(args[0], false)
+			if err != nil {
+				return nil, err
+			}
+			for i, member := range rangeByRank(z, reverse) {
+				if member == args[1] {
+					return int64(i), nil
+				}
+			}
+			return nil, ErrNil()
+		}
+	}
+	memoryCommands["ZRANK"] = memoryCommand{2, zRank(false)}
+	memoryCommands["ZREVRANK"] = memoryCommand{2, zRank(true)}
+
+	// 补充命令
+
+	expireAt := func(unit time.Duration) func(m *MemoryConnPool, args []string) (interface{}, error) {
+		return func(m *MemoryConnPool, args []string) (interface{}, error) {
+			n, err := parseInt(args[1])
+			if err != nil {
+				return nil, err
+			}
+			e := m.get(args[0])
+			if e == nil {
+				return int64(0), nil
+			}
+			e.expireAt = time.Unix(0, n*int64(unit))
+			m.get(args[0])
+			return int64(1), nil
+		}
+	}
+	memoryCommands["EXPIREAT"] = memoryCommand{2, expireAt(time.Second)}
+	memoryCommands["PEXPIREAT"] = memoryCommand{2, expireAt(time.Millisecond)}
+
+	memoryCommands["TOUCH"] = memoryCommands["EXISTS"]
+
+	rename := func(nx bool) func(m *MemoryConnPool, args []string) (interface{}, error) {
+		return func(m *MemoryConnPool, args []string) (interface{}, error) {
+			e := m.get(args[0])
+			if e == nil {
+				return nil, errNoSuchKey
+			}
+			if nx {
+				if m.get(args[1]) != nil {
+					return int64(0), nil
+				}
+				delete(m.data, args[0])
+				m.data[args[1]] = e
+				return int64(1), nil
+			}
+			delete(m.data, args[0])
+			m.data[args[1]] = e
+			return "OK", nil
+		}
+	}
+	memoryCommands["RENAME"] = memoryCommand{2, rename(false)}
+	memoryCommands["RENAMENX"] = memoryCommand{2, rename(true)}
+
+	memoryCommands["GETRANGE"] = memoryCommand{3, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		start, err := parseInt(args[1])
+		if err != nil {
+			return nil, err
+		}
+		stop, err := parseInt(args[2])
+		if err != nil {
+			return nil, err
+		}
+		s, _, err := m.getString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		i, j, ok := normalizeRange(start, stop, len(s))
+		if !ok {
+			return "", nil
+		}
+		return s[i:j], nil
+	}}
+
+	memoryCommands["PSETEX"] = memoryCommand{3, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		n, err := parseInt(args[1])
+		if err != nil {
+			return nil, err
+		}
+		expireAt := m.now().Add(time.Duration(n) * time.Millisecond)
+		m.data[args[0]] = &memoryEntry{value: args[2], expireAt: expireAt}
+		return "OK", nil
+	}}
+
+	memoryCommands["MSETNX"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		if len(args)%2 != 0 {
+			return nil, errNumberOfArg
+		}
+		for i := 0; i < len(args); i += 2 {
+			if m.get(args[i]) != nil {
+				return int64(0), nil
+			}
+		}
+		for i := 0; i < len(args); i += 2 {
+			m.data[args[i]] = &memoryEntry{value: args[i+1]}
+		}
+		return int64(1), nil
+	}}
+
+	memoryCommands["HSTRLEN"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		h, err := m.getHash(args[0], false)
+		if err != nil {
+			return nil, err
+		}
+		return int64(len(h[args[1]])), nil
+	}}
+
+	memoryCommands["LINSERT"] = memoryCommand{4, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		where := strings.ToUpper(args[1])
+		if where != "BEFORE" && where != "AFTER" {
+			return nil, errSyntax
+		}
+		e, l, err := m.getList(args[0])
+		if err != nil || e == nil {
+			return int64(0), err
+		}
+		for i, v := range l {
+			if v != args[2] {
+				continue
+			}
+			if where == "AFTER" {
+				i++
+			}
+			l = append(l[:i], append([]string{args[3]}, l[i:]...)...)
+			e.value = l
+			return int64(len(l)), nil
+		}
+		return int64(-1), nil
+	}}
+
+	memoryCommands["LMOVE"] = memoryCommand{4, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		e, l, err := m.getList(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			return nil, ErrNil()
+		}
+		if _, _, err = m.getList(args[1]); err != nil {
+			return nil, err
+		}
+		var v string
+		if strings.ToUpper(args[2]) == "LEFT" {
+			v, l = l[0], l[1:]
+		} else {
+			v, l = l[len(l)-1], l[:len(l)-1]
+		}
+		e.value = l
+		m.removeIfEmpty(args[0], len(l))
+		left := strings.ToUpper(args[3]) == "LEFT"
+		if _, err = push(left, false)(m, []string{args[1], v}); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}}
+
+	memoryCommands["RPOPLPUSH"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		return memoryCommands["LMOVE"].fn(m, []string{args[0], args[1], "RIGHT", "LEFT"})
+	}}
+
+	memoryCommands["SMISMEMBER"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		s, err := m.getSet(args[0], false)
+		if err != nil {
+			return nil, err
+		}
+		r := make([]interface{}, len(args)-1)
+		for i, member := range args[1:] {
+			if _, ok := s[member]; ok {
+				r[i] = int64(1)
+			} else {
+				r[i] = int64(0)
+			}
+		}
+		return r, nil
+	}}
+
+	memoryCommands["SMOVE"] = memoryCommand{3, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		src, err := m.getSet(args[0], false)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = m.getSet(args[1], false); err != nil {
+			return nil, err
+		}
+		if _, ok := src[args[2]]; !ok {
+			return int64(0), nil
+		}
+		delete(src, args[2])
+		m.removeIfEmpty(args[0], len(src))
+		dst, _ := m.getSet(args[1], true)
+		dst[args[2]] = struct{}{}
+		return int64(1), nil
+	}}
+
+	// combine 按照 op 计算多个集合的差集、交集或者并集。
+	combine := func(m *MemoryConnPool, op string, keys []string) (map[string]struct{}, error) {
+		var r map[string]struct{}
+		for i, key := range keys {
+			s, err := m.getSet(key, false)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 {
+				r = make(map[string]struct{}, len(s))
+				for member := range s {
+					r[member] = struct{}{}
+				}
+				continue
+			}
+			switch op {
+			case "DIFF":
+				for member := range s {
+					delete(r, member)
+				}
+			case "INTER":
+				for member := range r {
+					if _, ok := s[member]; !ok {
+						delete(r, member)
+					}
+				}
+			case "UNION":
+				for member := range s {
+					r[member] = struct{}{}
+				}
+			}
+		}
+		return r, nil
+	}
+
+	for _, op := range []string{"DIFF", "INTER", "UNION"} {
+		op := op
+		memoryCommands["S"+op] = memoryCommand{1, func(m *MemoryConnPool, args []string) (interface{}, error) {
+			r, err := combine(m, op, args)
+			if err != nil {
+				return nil, err
+			}
+			return stringsReply(sortedMembers(r)), nil
+		}}
+		memoryCommands["S"+op+"STORE"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+			r, err := combine(m, op, args[1:])
+			if err != nil {
+				return nil, err
+			}
+			delete(m.data, args[0])
+			if len(r) > 0 {
+				m.data[args[0]] = &memoryEntry{value: r}
+			}
+			return int64(len(r)), nil
+		}}
+	}
+
+	memoryCommands["ZMSCORE"] = memoryCommand{2, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		z, err := m.getZSet(args[0], false)
+		if err != nil {
+			return nil, err
+		}
+		r := make([]interface{}, len(args)-1)
+		for i, member := range args[1:] {
+			if score, ok := z[member]; ok {
+				r[i] = formatFloat(score)
+			}
+		}
+		return r, nil
+	}}
+
+	// parseScore 解析 ZRANGEBYSCORE 等命令的分数区间边界，支持 (、-inf 和 +inf 语法。
+	parseScore := func(s string) (float64, bool, error) {
+		exclusive := strings.HasPrefix(s, "(")
+		if exclusive {
+			s = s[1:]
+		}
+		f, err := parseFloat(s)
+		if err != nil {
+			return 0, false, errors.New("ERR min or max is not a float")
+		}
+		return f, exclusive, nil
+	}
+
+	byScore := func(m *MemoryConnPool, key, min, max string, reverse bool) (map[string]float64, []string, error) {
+		lo, loEx, err := parseScore(min)
+		if err != nil {
+			return nil, nil, err
+		}
+		hi, hiEx, err := parseScore(max)
+		if err != nil {
+			return nil, nil, err
+		}
+		z, err := m.getZSet(key, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		var members []string
+		for _, member := range rangeByRank(z, reverse) {
+			score := z[member]
+			if score < lo || (loEx && score == lo) || score > hi || (hiEx && score == hi) {
+				continue
+			}
+			members = append(members, member)
+		}
+		return z, members, nil
+	}
+
+	memoryCommands["ZCOUNT"] = memoryCommand{3, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		_, members, err := byScore(m, args[0], args[1], args[2], false)
+		if err != nil {
+			return nil, err
+		}
+		return int64(len(members)), nil
+	}}
+
+	rangeByScore := func(reverse bool) func(m *MemoryConnPool, args []string) (interface{}, error) {
+		return func(m *MemoryConnPool, args []string) (interface{}, error) {
+			min, max := args[1], args[2]
+			if reverse {
+				min, max = max, min
+			}
+			var (
+				withScores    bool
+				offset, count int64 = 0, -1
+			)
+			for i := 3; i < len(args); i++ {
+				switch strings.ToUpper(args[i]) {
+				case "WITHSCORES":
+					withScores = true
+				case "LIMIT":
+					if i+2 >= len(args) {
+						return nil, errSyntax
+					}
+					var err error
+					if offset, err = parseInt(args[i+1]); err != nil {
+						return nil, err
+					}
+					if count, err = parseInt(args[i+2]); err != nil {
+						return nil, err
+					}
+					i += 2
+				default:
+					return nil, errSyntax
+				}
+			}
+			z, members, err := byScore(m, args[0], min, max, reverse)
+			if err != nil {
+				return nil, err
+			}
+			r := []interface{}{}
+			for i, member := range members {
+				if int64(i) < offset || (count >= 0 && int64(i) >= offset+count) {
+					continue
+				}
+				r = append(r, member)
+				if withScores {
+					r = append(r, formatFloat(z[member]))
+				}
+			}
+			return r, nil
+		}
+	}
+	memoryCommands["ZRANGEBYSCORE"] = memoryCommand{3, rangeByScore(false)}
+	memoryCommands["ZREVRANGEBYSCORE"] = memoryCommand{3, rangeByScore(true)}
+
+	memoryCommands["ZREMRANGEBYSCORE"] = memoryCommand{3, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		z, members, err := byScore(m, args[0], args[1], args[2], false)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			delete(z, member)
+		}
+		m.removeIfEmpty(args[0], len(z))
+		return int64(len(members)), nil
+	}}
+
+	memoryCommands["ZREMRANGEBYRANK"] = memoryCommand{3, func(m *MemoryConnPool, args []string) (interface{}, error) {
+		start, err := parseInt(args[1])
+		if err != nil {
+			return nil, err
+		}
+		stop, err := parseInt(args[2])
+		if err != nil {
+			return nil, err
+		}
+		z, err := m.getZSet(args[0], false)
+		if err != nil {
+			return nil, err
+		}
+		members := rangeByRank(z, false)
+		i, j, ok := normalizeRange(start, stop, len(members))
+		if !ok {
+			return int64(0), nil
+		}
+		for _, member := range members[i:j] {
+			delete(z, member)
+		}
+		m.removeIfEmpty(args[0], len(z))
+		return int64(j - i), nil
+	}}
+
+	zPop := func(reverse bool) func(m *MemoryConnPool, args []string) (interface{}, error) {
+		return func(m *MemoryConnPool, args []string) (interface{}, error) {
+			count := int64(1)
+			if len(args) > 1 {
+				n, err := parseInt(args[1])
+				if err != nil {
+					return nil, err
+				}
+				count = n
+			}
+			z, err := m.getZSet(args[0], false)
+			if err != nil {
+				return nil, err
+			}
+			r := []interface{}{}
+			for _, member := range rangeByRank(z, reverse) {
+				if int64(len(r)/2) >= count {
+					break
+				}
+				r = append(r, member, formatFloat(z[member]))
+				delete(z, member)
+			}
+			m.removeIfEmpty(args[0], len(z))
+			return r, nil
+		}
+	}
+	memoryCommands["ZPOPMIN"] = memoryCommand{1, zPop(false)}
+	memoryCommands["ZPOPMAX"] = memoryCommand{1, zPop(true)}
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package report 收集 web 过滤器、定时任务和异步 goroutine 中发生的 panic 以及
+// 应用主动上报的错误，附加上下文中的标签之后交给注册的 Reporter 上报，例如发送到
+// Sentry 等错误跟踪服务。
+package report
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 错误的来源。
+const (
+	SourceWeb   = "web"       // web 请求的处理过程
+	SourceTask  = "task"      // 定时任务和批处理作业
+	SourceGo    = "goroutine" // 通过 gs.Go 创建的 goroutine
+	SourceEvent = "event"     // 事件监听器
+	SourceAudit = "audit"     // 容器的线程安全审计
+)
+
+// 错误的级别。
+const (
+	LevelError = "error"
+	LevelFatal = "fatal" // panic 的级别
+)
+
+// Frame 调用栈中的一帧。
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// Event 一次需要上报的错误。
+type Event struct {
+	ID      string
+	Time    time.Time
+	Level   string
+	Source  string
+	Type    string // 错误的类型，例如 *errors.errorString
+	Message string
+	Stack   []Frame // 最内层的调用在前
+	Tags    map[string]string
+}
+
+// Reporter 上报错误，Report 在发生错误的 goroutine 中被调用，耗时的操作应当异步
+// 执行。
+type Reporter interface {
+	Report(ctx context.Context, e *Event)
+}
+
+var (
+	mutex     sync.RWMutex
+	reporters []Reporter
+)
+
+// Register 注册 Reporter 。
+func Register(r Reporter) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	reporters = append(reporters, r)
+}
+
+// Unregister 注销 Reporter 。
+func Unregister(r Reporter) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for i, v := range reporters {
+		if v == r {
+			reporters = append(reporters[:i:i], reporters[i+1:]...)
+			return
+		}
+	}
+}
+
+type tagsKey struct{}
+
+// WithTags 返回附加了标签的 ctx ，kv 是交替出现的键和值，上报的错误会携带 ctx
+// 中的所有标签，例如请求 ID 、作业名称等。
+func WithTags(ctx context.Context, kv ...string) context.Context {
+	tags := make(map[string]string)
+	for k, v := range Tags(ctx) {
+		tags[k] = v
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		tags[kv[i]] = kv[i+1]
+	}
+	return context.WithValue(ctx, tagsKey{}, tags)
+}
+
+// Tags 返回 ctx 中的标签。
+func Tags(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(tagsKey{}).(map[string]string)
+	return tags
+}
+
+// Panic 上报 recover 得到的 panic ，需要在 defer 的函数中调用，调用栈从发生 panic
+// 的位置开始。
+func Panic(ctx context.Context, source string, r interface{}) {
+	e := newEvent(ctx, LevelFatal, source, r)
+	e.Stack = panicStack(callers())
+	dispatch(ctx, e)
+}
+
+// Error 上报错误，调用栈从调用者开始。
+func Error(ctx context.Context, source string, err error) {
+	if err == nil {
+		return
+	}
+	e := newEvent(ctx, LevelError, source, err)
+	e.Stack = callers()
+	dispatch(ctx, e)
+}
+
+func newEvent(ctx context.Context, level, source string, v interface{}) *Event {
+	e := &Event{
+		ID:     strings.Replace(uuid.New().String(), "-", "", -1),
+		Time:   time.Now(),
+		Level:  level,
+		Source: source,
+		Type:   fmt.Sprintf("%T", v),
+		Tags:   map[string]string{"source": source},
+	}
+	if err, ok := v.(error); ok {
+		e.Message = err.Error()
+	} else {
+		e.Message = fmt.Sprint(v)
+	}
+	for k, v := range Tags(ctx) {
+		e.Tags[k] = v
+	}
+	return e
+}
+
+func dispatch(ctx context.Context, e *Event) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	for _, r := range reporters {
+		r.Report(ctx, e)
+	}
+}
+
+// callers 返回 Panic 或者 Error 的调用者开始的调用栈。
+func callers() []Frame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(3, pcs)
+	var stack []Frame
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		stack = append(stack, Frame{Function: f.Function, File: f.File, Line: f.Line})
+		if !more {
+			return stack
+		}
+	}
+}
+
+// panicStack 去掉 defer 函数和 runtime 的帧，返回发生 panic 的位置开始的调用栈。
+func panicStack(stack []Frame) []Frame {
+	for i, f := range stack {
+		if f.Function == "runtime.gopanic" {
+			stack = stack[i+1:]
+			break
+		}
+	}
+	for len(stack) > 0 && strings.HasPrefix(stack[0].Function, "runtime.") {
+		stack = stack[1:]
+	}
+	return stack
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package report_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/report"
+)
+
+type reporter struct {
+	events []*report.Event
+}
+
+func (r *reporter) Report(ctx context.Context, e *report.Event) {
+	r.events = append(r.events, e)
+}
+
+func panicking() {
+	panic(errors.New("boom"))
+}
+
+func TestPanic(t *testing.T) {
+
+	r := &reporter{}
+	report.Register(r)
+	defer report.Unregister(r)
+
+	ctx := report.WithTags(context.Background(), "job", "sync")
+	ctx = report.WithTags(ctx, "step", "load")
+
+	func() {
+		defer func() {
+			report.Panic(ctx, report.SourceTask, recover())
+		}()
+		panicking()
+	}()
+
+	assert.Equal(t, len(r.events), 1)
+	e := r.events[0]
+	assert.Equal(t, len(e.ID), 32)
+	assert.Equal(t, e.Level, report.LevelFatal)
+	assert.Equal(t, e.Source, report.SourceTask)
+	assert.Equal(t, e.Type, "*errors.errorString")
+	assert.Equal(t, e.Message, "boom")
+	assert.Equal(t, e.Tags, map[string]string{"source": "task", "job": "sync", "step": "load"})
+	assert.True(t, strings.HasSuffix(e.Stack[0].Function, "report_test.panicking"))
+	assert.True(t, strings.HasSuffix(e.Stack[0].File, "report_test.go"))
+}
+
+func TestError(t *testing.T) {
+
+	r := &reporter{}
+	report.Register(r)
+
+	report.Error(context.Background(), report.SourceGo, nil)
+	report.Error(context.Background(), report.SourceGo, errors.New("timeout"))
+	assert.Equal(t, len(r.events), 1)
+	e := r.events[0]
+	assert.Equal(t, e.Level, report.LevelError)
+	assert.Equal(t, e.Type, "*errors.errorString")
+	assert.Equal(t, e.Message, "timeout")
+	assert.True(t, strings.HasSuffix(e.Stack[0].Function, "report_test.TestError"))
+
+	report.Unregister(r)
+	report.Error(context.Background(), report.SourceGo, errors.New("timeout"))
+	assert.Equal(t, len(r.events), 1)
+}
@@ -0,0 +1,254 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resilience
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrLimitExceeded 正在处理的请求数量达到了自适应并发限制。
+var ErrLimitExceeded = errors.New("resilience: concurrency limit exceeded")
+
+// 自适应并发限制的算法。
+const (
+	Gradient = "gradient"
+	Vegas    = "vegas"
+)
+
+// AdaptiveConfig 自适应并发限制配置。
+type AdaptiveConfig struct {
+	Algorithm    string  `value:"${algorithm:=gradient}"` // gradient 或者 vegas
+	InitialLimit int     `value:"${initial-limit:=20}"`
+	MinLimit     int     `value:"${min-limit:=1}"`
+	MaxLimit     int     `value:"${max-limit:=1000}"`
+	Smoothing    float64 `value:"${smoothing:=0.2}"` // 新的限制值所占的比重，取值范围 (0,1]
+}
+
+// Limit 根据请求的耗时计算并发限制的算法，Update 在每个请求结束时被调用，调用者
+// 保证不会并发调用。
+type Limit interface {
+	Limit() int
+
+	// Update 根据请求的耗时 rtt 、请求开始时正在处理的请求数量 inflight 以及请求是
+	// 否因为过载而失败计算新的限制值。
+	Update(rtt time.Duration, inflight int, dropped bool) int
+}
+
+// NewLimit 根据配置创建限制算法。
+func NewLimit(config AdaptiveConfig) (Limit, error) {
+	if config.MinLimit < 1 {
+		config.MinLimit = 1
+	}
+	if config.MaxLimit < config.MinLimit {
+		config.MaxLimit = config.MinLimit
+	}
+	if config.Smoothing <= 0 || config.Smoothing > 1 {
+		config.Smoothing = 1
+	}
+	l := limitBase{config: config, limit: float64(config.InitialLimit)}
+	l.limit = l.clamp(l.limit)
+	switch config.Algorithm {
+	case Gradient:
+		return &GradientLimit{limitBase: l}, nil
+	case Vegas:
+		return &VegasLimit{limitBase: l}, nil
+	}
+	return nil, fmt.Errorf("unknown algorithm %q", config.Algorithm)
+}
+
+type limitBase struct {
+	config AdaptiveConfig
+	limit  float64
+}
+
+func (l *limitBase) Limit() int {
+	return int(l.limit)
+}
+
+func (l *limitBase) clamp(v float64) float64 {
+	return math.Max(float64(l.config.MinLimit), math.Min(float64(l.config.MaxLimit), v))
+}
+
+// smooth 按照平滑系数更新限制值。
+func (l *limitBase) smooth(newLimit float64) int {
+	s := l.config.Smoothing
+	l.limit = l.clamp(l.limit*(1-s) + l.clamp(newLimit)*s)
+	return int(l.limit)
+}
+
+// log10 返回 limit 的常用对数，最小为 1 。
+func log10(limit float64) float64 {
+	return math.Max(1, math.Log10(limit))
+}
+
+// GradientLimit 比较请求耗时的长期均值和当前值，耗时变长说明出现了排队，按比例
+// 减小限制值，否则以 sqrt(limit) 的速度增大限制值。
+type GradientLimit struct {
+	limitBase
+	longRtt float64 // 请求耗时的指数移动平均值，单位为纳秒
+}
+
+// gradientWindow 计算长期均值的样本窗口大小。
+const gradientWindow = 600
+
+func (l *GradientLimit) Update(rtt time.Duration, inflight int, dropped bool) int {
+	shortRtt := float64(rtt)
+	if shortRtt <= 0 {
+		return l.Limit()
+	}
+	if l.longRtt == 0 {
+		l.longRtt = shortRtt
+	} else {
+		l.longRtt += (shortRtt - l.longRtt) / gradientWindow
+	}
+	// 负载下降之后让长期均值更快地回落，避免限制值过高。
+	if l.longRtt/shortRtt > 2 {
+		l.longRtt *= 0.95
+	}
+	// 请求数量远小于限制值时无法判断限制值是否合适。
+	if float64(inflight) < l.limit/2 && !dropped {
+		return l.Limit()
+	}
+	gradient := math.Max(0.5, math.Min(1, 1.5*l.longRtt/shortRtt))
+	if dropped {
+		gradient = 0.5
+	}
+	return l.smooth(l.limit*gradient + math.Sqrt(l.limit))
+}
+
+// VegasLimit 以观测到的最小耗时作为无负载耗时，据此估算排队的请求数量，排队少时
+// 增大限制值，排队多或者请求因为过载而失败时减小限制值。
+type VegasLimit struct {
+	limitBase
+	noLoadRtt time.Duration
+}
+
+func (l *VegasLimit) Update(rtt time.Duration, inflight int, dropped bool) int {
+	if rtt <= 0 {
+		return l.Limit()
+	}
+	if l.noLoadRtt == 0 || rtt < l.noLoadRtt {
+		l.noLoadRtt = rtt
+	}
+	d := log10(l.limit)
+	if dropped {
+		return l.smooth(l.limit - d)
+	}
+	if float64(inflight)*2 < l.limit {
+		return l.Limit()
+	}
+	queue := math.Ceil(l.limit * (1 - float64(l.noLoadRtt)/float64(rtt)))
+	switch {
+	case queue <= d:
+		return l.smooth(l.limit + 6*d)
+	case queue < 3*d:
+		return l.smooth(l.limit + d)
+	case queue > 6*d:
+		return l.smooth(l.limit - d)
+	}
+	return l.Limit()
+}
+
+// LimiterStats 自适应并发限制的运行状态。
+type LimiterStats struct {
+	Name     string `json:"name"`
+	Limit    int    `json:"limit"`
+	Inflight int    `json:"inflight"`
+	Accepted int64  `json:"accepted"`
+	Rejected int64  `json:"rejected"`
+}
+
+// AdaptiveLimiter 自适应并发限制器，根据请求的耗时动态调整允许同时处理的请求数量，
+// 在服务过载时尽早拒绝请求，避免所有请求都因为排队而超时。
+type AdaptiveLimiter struct {
+	name     string
+	mutex    sync.Mutex
+	limit    Limit
+	inflight int
+	accepted int64
+	rejected int64
+}
+
+var (
+	limitersMu sync.RWMutex
+	limiters   []*AdaptiveLimiter
+)
+
+// NewAdaptiveLimiter 创建并注册自适应并发限制器。
+func NewAdaptiveLimiter(name string, config AdaptiveConfig) (*AdaptiveLimiter, error) {
+	limit, err := NewLimit(config)
+	if err != nil {
+		return nil, err
+	}
+	l := &AdaptiveLimiter{name: name, limit: limit}
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	limiters = append(limiters, l)
+	return l, nil
+}
+
+// Acquire 获取处理请求的许可，请求数量达到限制时返回 ErrLimitExceeded 。成功时
+// 返回的函数必须在请求结束时调用且只能调用一次，dropped 表示请求是否因为过载而
+// 失败，例如超时。
+func (l *AdaptiveLimiter) Acquire() (release func(dropped bool), err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.inflight >= l.limit.Limit() {
+		atomic.AddInt64(&l.rejected, 1)
+		return nil, ErrLimitExceeded
+	}
+	l.inflight++
+	inflight := l.inflight
+	atomic.AddInt64(&l.accepted, 1)
+	start := time.Now()
+	return func(dropped bool) {
+		rtt := time.Since(start)
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		l.inflight--
+		l.limit.Update(rtt, inflight, dropped)
+	}, nil
+}
+
+// Stats 返回限制器的运行状态。
+func (l *AdaptiveLimiter) Stats() LimiterStats {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return LimiterStats{
+		Name:     l.name,
+		Limit:    l.limit.Limit(),
+		Inflight: l.inflight,
+		Accepted: atomic.LoadInt64(&l.accepted),
+		Rejected: atomic.LoadInt64(&l.rejected),
+	}
+}
+
+// AdaptiveStats 返回所有自适应并发限制器的运行状态。
+func AdaptiveStats() []LimiterStats {
+	limitersMu.RLock()
+	defer limitersMu.RUnlock()
+	var ret []LimiterStats
+	for _, l := range limiters {
+		ret = append(ret, l.Stats())
+	}
+	return ret
+}
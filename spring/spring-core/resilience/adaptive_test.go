@@ -0,0 +1,168 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resilience_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-base/knife"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/resilience"
+	"github.com/go-spring/spring-core/web"
+)
+
+func newLimit(t *testing.T, algorithm string) resilience.Limit {
+	l, err := resilience.NewLimit(resilience.AdaptiveConfig{
+		Algorithm:    algorithm,
+		InitialLimit: 20,
+		MinLimit:     1,
+		MaxLimit:     1000,
+		Smoothing:    0.2,
+	})
+	assert.Nil(t, err)
+	return l
+}
+
+// update 以满负载的并发数量提交 n 个耗时为 rtt 的样本。
+func update(l resilience.Limit, n int, rtt time.Duration, dropped bool) int {
+	for i := 0; i < n; i++ {
+		l.Update(rtt, l.Limit(), dropped)
+	}
+	return l.Limit()
+}
+
+func TestGradientLimit(t *testing.T) {
+
+	l := newLimit(t, resilience.Gradient)
+
+	// 负载很低时不调整
+	l.Update(10*time.Millisecond, 1, false)
+	assert.Equal(t, l.Limit(), 20)
+
+	// 耗时稳定时增大
+	high := update(l, 50, 10*time.Millisecond, false)
+	assert.True(t, high > 20)
+
+	// 耗时变长时减小
+	low := update(l, 50, 40*time.Millisecond, false)
+	assert.True(t, low < high)
+
+	// 过载时快速减小
+	assert.True(t, update(l, 10, 40*time.Millisecond, true) < low)
+}
+
+func TestVegasLimit(t *testing.T) {
+
+	l := newLimit(t, resilience.Vegas)
+
+	l.Update(10*time.Millisecond, 1, false)
+	assert.Equal(t, l.Limit(), 20)
+
+	high := update(l, 20, 10*time.Millisecond, false)
+	assert.True(t, high > 20)
+
+	low := update(l, 20, 100*time.Millisecond, false)
+	assert.True(t, low < high)
+
+	assert.True(t, update(l, 10, 10*time.Millisecond, true) < low)
+	assert.Equal(t, update(l, 1000, 100*time.Millisecond, true), 1)
+}
+
+func TestNewLimit(t *testing.T) {
+	_, err := resilience.NewLimit(resilience.AdaptiveConfig{Algorithm: "aimd"})
+	assert.Error(t, err, `unknown algorithm "aimd"`)
+	l, err := resilience.NewLimit(resilience.AdaptiveConfig{Algorithm: resilience.Vegas, InitialLimit: 50, MaxLimit: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, l.Limit(), 10)
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+
+	l, err := resilience.NewAdaptiveLimiter("adaptive", resilience.AdaptiveConfig{
+		Algorithm:    resilience.Vegas,
+		InitialLimit: 1,
+		MaxLimit:     1,
+	})
+	assert.Nil(t, err)
+
+	release, err := l.Acquire()
+	assert.Nil(t, err)
+	_, err = l.Acquire()
+	assert.Equal(t, err, resilience.ErrLimitExceeded)
+	assert.Equal(t, l.Stats(), resilience.LimiterStats{Name: "adaptive", Limit: 1, Inflight: 1, Accepted: 1, Rejected: 1})
+	release(false)
+
+	var found bool
+	for _, s := range resilience.AdaptiveStats() {
+		if s.Name == "adaptive" {
+			found = true
+			assert.Equal(t, s.Inflight, 0)
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestAdaptiveLimitFilter(t *testing.T) {
+
+	p, err := conf.Bytes([]byte(`
+web.adaptive-limit./api/*.initial-limit=1
+web.adaptive-limit./api/*.max-limit=1
+`), ".properties")
+	assert.Nil(t, err)
+	var config resilience.AdaptiveLimitConfig
+	err = p.Bind(&config)
+	assert.Nil(t, err)
+	assert.Equal(t, config.Routes["/api/*"].Algorithm, resilience.Gradient)
+
+	f, err := resilience.NewAdaptiveLimitFilter(config)
+	assert.Nil(t, err)
+
+	serve := func(path string, handler web.Filter) *httptest.ResponseRecorder {
+		ctx, _ := knife.New(context.Background())
+		r := httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		webCtx := web.NewBaseContext(path, nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+		web.NewFilterChain([]web.Filter{f, handler}).Next(webCtx)
+		return w
+	}
+
+	ok := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		ctx.String("ok")
+	})
+
+	var api, health *httptest.ResponseRecorder
+	handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		api = serve("/api/orders", ok)
+		health = serve("/health", ok)
+		ctx.String("ok")
+	})
+	assert.Equal(t, serve("/api/users", handler).Body.String(), "ok")
+	assert.Equal(t, api.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, api.Body.String(), resilience.ErrLimitExceeded.Error())
+	assert.Equal(t, health.Body.String(), "ok")
+	assert.Equal(t, serve("/api/orders", ok).Body.String(), "ok")
+
+	_, err = resilience.NewAdaptiveLimitFilter(resilience.AdaptiveLimitConfig{
+		Routes: map[string]resilience.AdaptiveConfig{"/api/*": {Algorithm: "aimd"}},
+	})
+	assert.Error(t, err, `web.adaptive-limit./api/\*: unknown algorithm "aimd"`)
+}
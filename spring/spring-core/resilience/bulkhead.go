@@ -0,0 +1,170 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package resilience 提供了防止一个缓慢的依赖拖垮整个应用的工具。
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-spring/spring-core/internal"
+)
+
+// ErrBulkheadFull 舱壁已满，调用被拒绝。
+var ErrBulkheadFull = errors.New("resilience: bulkhead is full")
+
+// BulkheadConfig 舱壁配置。
+type BulkheadConfig = internal.BulkheadConfig
+
+// BulkheadStats 舱壁的运行状态。
+type BulkheadStats struct {
+	Name     string `json:"name"`
+	Active   int    `json:"active"`   // 正在执行的调用数量
+	Waiting  int    `json:"waiting"`  // 正在排队的调用数量
+	Accepted int64  `json:"accepted"` // 获得许可的调用数量
+	Rejected int64  `json:"rejected"` // 被拒绝的调用数量，包括排队超时
+}
+
+// Bulkhead 舱壁，通过信号量限制同时执行的调用数量，超出限制的调用排队等待一段
+// 时间，仍然没有获得许可时被拒绝，避免一个缓慢的依赖耗尽所有的 goroutine 。
+type Bulkhead struct {
+	name     string
+	config   BulkheadConfig
+	sem      chan struct{}
+	waiting  int64
+	accepted int64
+	rejected int64
+}
+
+var (
+	bulkheadsMu sync.RWMutex
+	bulkheads   = map[string]*Bulkhead{}
+)
+
+// NewBulkhead 创建舱壁并按照名称注册，MaxConcurrent 不大于 0 时不限制并发数。
+// 同名的舱壁会替换之前注册的舱壁，因此重复创建同一个客户端不会使注册表无限增长。
+func NewBulkhead(name string, config BulkheadConfig) *Bulkhead {
+	b := &Bulkhead{name: name, config: config}
+	if config.MaxConcurrent > 0 {
+		b.sem = make(chan struct{}, config.MaxConcurrent)
+	}
+	bulkheadsMu.Lock()
+	defer bulkheadsMu.Unlock()
+	bulkheads[name] = b
+	return b
+}
+
+// Unregister 注销舱壁，舱壁的使用者关闭时调用，已经被同名舱壁替换时不做任何事。
+func Unregister(b *Bulkhead) {
+	bulkheadsMu.Lock()
+	defer bulkheadsMu.Unlock()
+	if bulkheads[b.name] == b {
+		delete(bulkheads, b.name)
+	}
+}
+
+// Name 返回舱壁的名称。
+func (b *Bulkhead) Name() string {
+	return b.name
+}
+
+// Acquire 获取一个执行许可，成功时返回释放许可的函数，调用结束后必须执行且只能执行
+// 一次。舱壁已满时返回 ErrBulkheadFull ，排队期间 ctx 结束时返回 ctx.Err() 。
+func (b *Bulkhead) Acquire(ctx context.Context) (release func(), err error) {
+
+	if b.sem == nil {
+		atomic.AddInt64(&b.accepted, 1)
+		return func() {}, nil
+	}
+
+	defer func() {
+		if err != nil {
+			atomic.AddInt64(&b.rejected, 1)
+		} else {
+			atomic.AddInt64(&b.accepted, 1)
+		}
+	}()
+
+	release = func() { <-b.sem }
+
+	select {
+	case b.sem <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if b.config.MaxWait <= 0 {
+		return nil, ErrBulkheadFull
+	}
+
+	n := atomic.AddInt64(&b.waiting, 1)
+	defer atomic.AddInt64(&b.waiting, -1)
+	if b.config.MaxWaiting > 0 && n > int64(b.config.MaxWaiting) {
+		return nil, ErrBulkheadFull
+	}
+
+	t := time.NewTimer(b.config.MaxWait)
+	defer t.Stop()
+
+	select {
+	case b.sem <- struct{}{}:
+		return release, nil
+	case <-t.C:
+		return nil, ErrBulkheadFull
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Execute 获得许可之后执行 fn ，fn 返回或者 panic 时释放许可。
+func (b *Bulkhead) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	release, err := b.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn(ctx)
+}
+
+// Stats 返回舱壁的运行状态。
+func (b *Bulkhead) Stats() BulkheadStats {
+	return BulkheadStats{
+		Name:     b.name,
+		Active:   len(b.sem),
+		Waiting:  int(atomic.LoadInt64(&b.waiting)),
+		Accepted: atomic.LoadInt64(&b.accepted),
+		Rejected: atomic.LoadInt64(&b.rejected),
+	}
+}
+
+// Stats 返回所有舱壁的运行状态，按照名称排序。
+func Stats() []BulkheadStats {
+	bulkheadsMu.RLock()
+	defer bulkheadsMu.RUnlock()
+	var ret []BulkheadStats
+	for _, b := range bulkheads {
+		ret = append(ret, b.Stats())
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
@@ -0,0 +1,172 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resilience_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-base/knife"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/grpc"
+	"github.com/go-spring/spring-core/resilience"
+	"github.com/go-spring/spring-core/web"
+)
+
+func TestBulkhead(t *testing.T) {
+
+	b := resilience.NewBulkhead("test", resilience.BulkheadConfig{MaxConcurrent: 1})
+	release, err := b.Acquire(context.Background())
+	assert.Nil(t, err)
+	_, err = b.Acquire(context.Background())
+	assert.Equal(t, err, resilience.ErrBulkheadFull)
+	assert.Equal(t, b.Stats(), resilience.BulkheadStats{Name: "test", Active: 1, Accepted: 1, Rejected: 1})
+	release()
+
+	err = b.Execute(context.Background(), func(ctx context.Context) error { return nil })
+	assert.Nil(t, err)
+	assert.Equal(t, b.Stats(), resilience.BulkheadStats{Name: "test", Accepted: 2, Rejected: 1})
+
+	b = resilience.NewBulkhead("unlimited", resilience.BulkheadConfig{})
+	for i := 0; i < 3; i++ {
+		_, err = b.Acquire(context.Background())
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, b.Stats().Accepted, int64(3))
+}
+
+func TestBulkhead_Wait(t *testing.T) {
+
+	b := resilience.NewBulkhead("wait", resilience.BulkheadConfig{
+		MaxConcurrent: 1,
+		MaxWait:       time.Second,
+		MaxWaiting:    1,
+	})
+	release, err := b.Acquire(context.Background())
+	assert.Nil(t, err)
+
+	// 排队的调用在许可释放之后获得许可
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r, err := b.Acquire(context.Background())
+		assert.Nil(t, err)
+		r()
+	}()
+	for b.Stats().Waiting == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 超出排队数量的调用直接被拒绝
+	_, err = b.Acquire(context.Background())
+	assert.Equal(t, err, resilience.ErrBulkheadFull)
+
+	release()
+	wg.Wait()
+
+	// 排队期间 ctx 结束
+	release, err = b.Acquire(context.Background())
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = b.Acquire(ctx)
+	assert.Equal(t, err, context.DeadlineExceeded)
+	release()
+
+	assert.Equal(t, b.Stats(), resilience.BulkheadStats{Name: "wait", Accepted: 3, Rejected: 2})
+}
+
+func TestBulkheadFilter(t *testing.T) {
+
+	b := resilience.NewBulkhead("web", resilience.BulkheadConfig{MaxConcurrent: 1})
+	f := resilience.NewBulkheadFilter(b)
+
+	serve := func(handler web.Filter) *httptest.ResponseRecorder {
+		ctx, _ := knife.New(context.Background())
+		r := httptest.NewRequest(http.MethodGet, "/users", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		webCtx := web.NewBaseContext("/users", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+		web.NewFilterChain([]web.Filter{f, handler}).Next(webCtx)
+		return w
+	}
+
+	var w *httptest.ResponseRecorder
+	handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		assert.Equal(t, b.Stats().Active, 1)
+		w = serve(web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+			t.Fatal("should not be called")
+		}))
+		ctx.String("ok")
+	})
+	assert.Equal(t, serve(handler).Body.String(), "ok")
+	assert.Equal(t, w.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, w.Body.String(), resilience.ErrBulkheadFull.Error())
+	assert.Equal(t, b.Stats().Active, 0)
+}
+
+func TestBulkheadConfig(t *testing.T) {
+
+	p, err := conf.Bytes([]byte("bulkhead.max-concurrent=8\nbulkhead.max-wait=50ms"), ".properties")
+	assert.Nil(t, err)
+	var config grpc.ServerConfig
+	err = p.Bind(&config)
+	assert.Nil(t, err)
+	assert.Equal(t, config.Bulkhead, resilience.BulkheadConfig{MaxConcurrent: 8, MaxWait: 50 * time.Millisecond})
+
+	var endpoint grpc.EndpointConfig
+	err = conf.New().Bind(&endpoint)
+	assert.Nil(t, err)
+	assert.Equal(t, endpoint.Bulkhead, resilience.BulkheadConfig{})
+}
+
+func TestStats(t *testing.T) {
+
+	find := func(name string) (resilience.BulkheadStats, int) {
+		var (
+			ret resilience.BulkheadStats
+			n   int
+		)
+		for _, s := range resilience.Stats() {
+			if s.Name == name {
+				ret, n = s, n+1
+			}
+		}
+		return ret, n
+	}
+
+	old := resilience.NewBulkhead("stats", resilience.BulkheadConfig{})
+	b := resilience.NewBulkhead("stats", resilience.BulkheadConfig{})
+	_ = b.Execute(context.Background(), func(ctx context.Context) error { return nil })
+	s, n := find("stats")
+	assert.Equal(t, n, 1)
+	assert.Equal(t, s.Accepted, int64(1))
+
+	// 被替换的舱壁注销时不影响新的舱壁
+	resilience.Unregister(old)
+	_, n = find("stats")
+	assert.Equal(t, n, 1)
+
+	resilience.Unregister(b)
+	_, n = find("stats")
+	assert.Equal(t, n, 0)
+}
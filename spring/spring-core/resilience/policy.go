@@ -0,0 +1,294 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 熔断器已经打开，调用被拒绝。
+var ErrCircuitOpen = errors.New("resilience: circuit breaker is open")
+
+// Policy 调用策略，包括超时、重试和熔断设置。
+type Policy struct {
+	Timeout time.Duration `value:"${timeout:=0}"` // 每次调用的超时时间，0 表示不限制
+	Retry   RetryPolicy   `value:"${retry}"`
+	Circuit CircuitPolicy `value:"${circuit}"`
+}
+
+// RetryPolicy 重试设置，两次调用之间的等待时间按照倍数增长。
+type RetryPolicy struct {
+	MaxAttempts int           `value:"${max-attempts:=1}"` // 最多调用的次数，包括第一次调用
+	Backoff     time.Duration `value:"${backoff:=100ms}"`  // 第一次重试之前等待的时间
+	MaxBackoff  time.Duration `value:"${max-backoff:=2s}"` // 等待时间的上限
+	Multiplier  float64       `value:"${multiplier:=2}"`   // 每次重试之后等待时间增长的倍数
+}
+
+// CircuitPolicy 熔断设置，统计窗口内的失败率达到阈值时打开熔断器，经过一段时间之后
+// 放行一个试探调用，成功则关闭熔断器，失败则继续保持打开。
+type CircuitPolicy struct {
+	FailureRate  float64       `value:"${failure-rate:=0}"`    // 打开熔断器的失败率，0 表示不使用熔断器
+	MinRequests  int           `value:"${min-requests:=20}"`   // 统计窗口内至少有这么多调用时才计算失败率
+	Window       time.Duration `value:"${window:=10s}"`        // 统计窗口
+	OpenDuration time.Duration `value:"${open-duration:=30s}"` // 熔断器打开的时间
+}
+
+// RegistryConfig 调用策略配置，通常配合 resilience 前缀一起使用，例如
+// resilience.policies.payments.timeout=2s 。
+type RegistryConfig struct {
+	Policies map[string]Policy `value:"${policies:=}"`
+}
+
+// Registry 按照名称管理调用策略，路由和客户端通过名称引用策略，从而集中调整超时、
+// 重试和熔断设置。
+type Registry struct {
+	executors map[string]*Executor
+}
+
+// NewRegistry Registry 的构造函数。
+func NewRegistry(config RegistryConfig) *Registry {
+	r := &Registry{executors: make(map[string]*Executor)}
+	for name, p := range config.Policies {
+		r.executors[name] = NewExecutor(name, p)
+	}
+	return r
+}
+
+// Get 返回名称对应的 Executor ，同名的策略共用同一个熔断器。
+func (r *Registry) Get(name string) (*Executor, error) {
+	if e, ok := r.executors[name]; ok {
+		return e, nil
+	}
+	return nil, fmt.Errorf("resilience: policy %q not found", name)
+}
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+type ignoredError struct{ err error }
+
+func (e *ignoredError) Error() string { return e.err.Error() }
+func (e *ignoredError) Unwrap() error { return e.err }
+
+// Permanent 标记不应该重试的错误，例如非幂等的调用在服务端出错，仍然计入熔断器的
+// 失败次数。
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+// Ignore 标记调用方自身导致的错误，例如参数错误，既不重试也不计入熔断器的失败次数。
+func Ignore(err error) error {
+	return &ignoredError{err}
+}
+
+// unwrap 去掉 Permanent 和 Ignore 的标记，返回原始的错误以及是否可以重试、是否计入
+// 失败次数。
+func unwrap(err error) (_ error, retryable bool, failure bool) {
+	switch e := err.(type) {
+	case nil:
+		return nil, false, false
+	case *permanentError:
+		return e.err, false, true
+	case *ignoredError:
+		return e.err, false, false
+	}
+	return err, err != ErrCircuitOpen, err != ErrCircuitOpen
+}
+
+// Executor 按照策略执行调用。
+type Executor struct {
+	name    string
+	policy  Policy
+	breaker *breaker
+}
+
+// NewExecutor Executor 的构造函数。
+func NewExecutor(name string, policy Policy) *Executor {
+	e := &Executor{name: name, policy: policy}
+	if policy.Circuit.FailureRate > 0 {
+		e.breaker = &breaker{policy: policy.Circuit}
+	}
+	return e
+}
+
+// Name 返回策略的名称。
+func (e *Executor) Name() string {
+	return e.name
+}
+
+// Policy 返回调用策略。
+func (e *Executor) Policy() Policy {
+	return e.policy
+}
+
+// State 返回熔断器的状态，closed 、open 或者 half-open ，没有使用熔断器时总是 closed 。
+func (e *Executor) State() string {
+	if e.breaker == nil {
+		return stateClosed
+	}
+	return e.breaker.currentState()
+}
+
+// Execute 按照策略执行 fn ，每次调用使用设置了超时时间的 ctx ，失败时按照重试设置
+// 再次调用，熔断器打开时返回 ErrCircuitOpen 。fn 可以通过 Permanent 和 Ignore 标记
+// 不应该重试的错误。
+func (e *Executor) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	return e.execute(ctx, e.policy.Retry.MaxAttempts, true, fn)
+}
+
+// ExecuteOnce 和 Execute 相同，但是不重试。
+func (e *Executor) ExecuteOnce(ctx context.Context, fn func(ctx context.Context) error) error {
+	return e.execute(ctx, 1, true, fn)
+}
+
+func (e *Executor) execute(ctx context.Context, attempts int, timeout bool, fn func(ctx context.Context) error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := e.policy.Retry.Backoff
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			if !sleep(ctx, backoff) {
+				return err
+			}
+			backoff = time.Duration(float64(backoff) * e.policy.Retry.Multiplier)
+			if max := e.policy.Retry.MaxBackoff; max > 0 && backoff > max {
+				backoff = max
+			}
+		}
+		var retryable bool
+		err, retryable, _ = unwrap(e.attempt(ctx, timeout, fn))
+		if err == nil || !retryable || ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func (e *Executor) attempt(ctx context.Context, timeout bool, fn func(ctx context.Context) error) error {
+	if e.breaker != nil && !e.breaker.allow() {
+		return ErrCircuitOpen
+	}
+	if timeout && e.policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.policy.Timeout)
+		defer cancel()
+	}
+	err := fn(ctx)
+	if e.breaker != nil {
+		_, _, failure := unwrap(err)
+		e.breaker.record(!failure)
+	}
+	return err
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+const (
+	stateClosed   = "closed"
+	stateOpen     = "open"
+	stateHalfOpen = "half-open"
+)
+
+// breaker 熔断器，半开状态下同一时间只放行一个试探调用。
+type breaker struct {
+	policy   CircuitPolicy
+	mutex    sync.Mutex
+	state    string
+	start    time.Time // 统计窗口的开始时间
+	requests int
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func (b *breaker) currentState() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.state == "" {
+		return stateClosed
+	}
+	return b.state
+}
+
+func (b *breaker) allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+	switch b.state {
+	case stateOpen:
+		if now.Sub(b.openedAt) < b.policy.OpenDuration {
+			return false
+		}
+		b.state, b.probing = stateHalfOpen, true
+		return true
+	case stateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	if now.Sub(b.start) > b.policy.Window {
+		b.start, b.requests, b.failures = now, 0, 0
+	}
+	return true
+}
+
+func (b *breaker) record(success bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+	switch b.state {
+	case stateOpen:
+		return
+	case stateHalfOpen:
+		b.probing = false
+		if success {
+			b.state, b.start, b.requests, b.failures = stateClosed, now, 0, 0
+		} else {
+			b.state, b.openedAt = stateOpen, now
+		}
+		return
+	}
+	b.requests++
+	if !success {
+		b.failures++
+	}
+	if b.requests >= b.policy.MinRequests && float64(b.failures) >= b.policy.FailureRate*float64(b.requests) {
+		b.state, b.openedAt = stateOpen, now
+	}
+}
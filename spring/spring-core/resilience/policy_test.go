@@ -0,0 +1,261 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resilience_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-base/knife"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/resilience"
+	"github.com/go-spring/spring-core/web"
+)
+
+func newRegistry(t *testing.T, s string) *resilience.Registry {
+	p, err := conf.Bytes([]byte(s), ".properties")
+	assert.Nil(t, err)
+	var config resilience.RegistryConfig
+	err = p.Bind(&config, conf.Key("resilience"))
+	assert.Nil(t, err)
+	return resilience.NewRegistry(config)
+}
+
+func TestRegistry(t *testing.T) {
+
+	r := newRegistry(t, `
+resilience.policies.payments.timeout=2s
+resilience.policies.payments.retry.max-attempts=3
+resilience.policies.payments.circuit.failure-rate=0.5
+resilience.policies.default.timeout=1s
+`)
+	e, err := r.Get("payments")
+	assert.Nil(t, err)
+	assert.Equal(t, e.Policy(), resilience.Policy{
+		Timeout: 2 * time.Second,
+		Retry: resilience.RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     100 * time.Millisecond,
+			MaxBackoff:  2 * time.Second,
+			Multiplier:  2,
+		},
+		Circuit: resilience.CircuitPolicy{
+			FailureRate:  0.5,
+			MinRequests:  20,
+			Window:       10 * time.Second,
+			OpenDuration: 30 * time.Second,
+		},
+	})
+
+	e, err = r.Get("default")
+	assert.Nil(t, err)
+	assert.Equal(t, e.Policy().Retry.MaxAttempts, 1)
+	assert.Equal(t, e.State(), "closed")
+
+	_, err = r.Get("orders")
+	assert.Error(t, err, `resilience: policy "orders" not found`)
+}
+
+func TestExecutor_Retry(t *testing.T) {
+
+	e := resilience.NewExecutor("retry", resilience.Policy{
+		Retry: resilience.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, Multiplier: 2},
+	})
+
+	errUnavailable := errors.New("unavailable")
+	calls := 0
+	err := e.Execute(context.Background(), func(ctx context.Context) error {
+		if calls++; calls < 3 {
+			return errUnavailable
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, calls, 3)
+
+	calls = 0
+	err = e.Execute(context.Background(), func(ctx context.Context) error {
+		calls++
+		return errUnavailable
+	})
+	assert.Equal(t, err, errUnavailable)
+	assert.Equal(t, calls, 3)
+
+	for _, wrap := range []func(error) error{resilience.Permanent, resilience.Ignore} {
+		calls = 0
+		err = e.Execute(context.Background(), func(ctx context.Context) error {
+			calls++
+			return wrap(errUnavailable)
+		})
+		assert.Equal(t, err, errUnavailable)
+		assert.Equal(t, calls, 1)
+	}
+
+	calls = 0
+	err = e.ExecuteOnce(context.Background(), func(ctx context.Context) error {
+		calls++
+		return errUnavailable
+	})
+	assert.Equal(t, err, errUnavailable)
+	assert.Equal(t, calls, 1)
+
+	// 每次调用单独计算超时时间
+	e = resilience.NewExecutor("timeout", resilience.Policy{
+		Timeout: 10 * time.Millisecond,
+		Retry:   resilience.RetryPolicy{MaxAttempts: 2},
+	})
+	calls = 0
+	err = e.Execute(context.Background(), func(ctx context.Context) error {
+		calls++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.Equal(t, err, context.DeadlineExceeded)
+	assert.Equal(t, calls, 2)
+}
+
+func TestExecutor_Circuit(t *testing.T) {
+
+	e := resilience.NewExecutor("circuit", resilience.Policy{
+		Circuit: resilience.CircuitPolicy{
+			FailureRate:  0.5,
+			MinRequests:  4,
+			Window:       time.Minute,
+			OpenDuration: 20 * time.Millisecond,
+		},
+	})
+	fail := func(ctx context.Context) error { return errors.New("error") }
+	succeed := func(ctx context.Context) error { return nil }
+	ignore := func(ctx context.Context) error { return resilience.Ignore(errors.New("bad request")) }
+
+	// 调用方自身的错误不计入失败次数
+	for i := 0; i < 4; i++ {
+		_ = e.Execute(context.Background(), ignore)
+	}
+	assert.Equal(t, e.State(), "closed")
+
+	for i := 0; i < 4; i++ {
+		_ = e.Execute(context.Background(), fail)
+	}
+	assert.Equal(t, e.State(), "open")
+	assert.Equal(t, e.Execute(context.Background(), succeed), resilience.ErrCircuitOpen)
+
+	// 试探调用失败时继续保持打开
+	time.Sleep(25 * time.Millisecond)
+	_ = e.Execute(context.Background(), fail)
+	assert.Equal(t, e.State(), "open")
+
+	time.Sleep(25 * time.Millisecond)
+	assert.Nil(t, e.Execute(context.Background(), succeed))
+	assert.Equal(t, e.State(), "closed")
+}
+
+func TestPolicyFilter(t *testing.T) {
+
+	r := newRegistry(t, `
+resilience.policies.payments.timeout=20ms
+resilience.policies.payments.circuit.failure-rate=1
+resilience.policies.payments.circuit.min-requests=2
+`)
+	router := web.NewRouter()
+	router.GetMapping("/payments/{id}", nil).WithPolicy("payments")
+	router.GetMapping("/users", nil)
+	f, err := resilience.NewPolicyFilter(r, router)
+	assert.Nil(t, err)
+
+	serve := func(path string, handler web.Filter) *httptest.ResponseRecorder {
+		ctx, _ := knife.New(context.Background())
+		req := httptest.NewRequest(http.MethodGet, strings.Replace(path, "{id}", "1", 1), nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		webCtx := web.NewBaseContext(path, nil, req, &web.BufferedResponseWriter{ResponseWriter: w})
+		web.NewFilterChain([]web.Filter{f, handler}).Next(webCtx)
+		return w
+	}
+
+	slow := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		<-ctx.Context().Done()
+	})
+	w := serve("/payments/{id}", slow)
+	assert.Equal(t, w.Code, http.StatusGatewayTimeout)
+
+	// 没有设置策略的路由
+	w = serve("/users", web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		_, ok := ctx.Context().Deadline()
+		assert.False(t, ok)
+		ctx.String("ok")
+	}))
+	assert.Equal(t, w.Body.String(), "ok")
+
+	serve("/payments/{id}", slow)
+	w = serve("/payments/{id}", web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		t.Fatal("should not be called")
+	}))
+	assert.Equal(t, w.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, w.Body.String(), resilience.ErrCircuitOpen.Error())
+
+	router.GetMapping("/orders", nil).WithPolicy("orders")
+	_, err = resilience.NewPolicyFilter(r, router)
+	assert.Error(t, err, `resilience: policy "orders" not found`)
+}
+
+func TestPolicyTransport(t *testing.T) {
+
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if len(bodies) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	e := resilience.NewExecutor("payments", resilience.Policy{
+		Timeout: time.Second,
+		Retry:   resilience.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+	})
+	client := &http.Client{Transport: resilience.NewPolicyTransport(e, nil)}
+
+	resp, err := client.Post(server.URL, "text/plain", strings.NewReader("pay"))
+	assert.Nil(t, err)
+	b, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, string(b), "ok")
+	assert.Equal(t, bodies, []string{"pay", "pay", "pay"})
+
+	// 最后一次调用的响应原样返回
+	bodies = nil
+	e = resilience.NewExecutor("payments", resilience.Policy{
+		Retry: resilience.RetryPolicy{MaxAttempts: 2},
+	})
+	client = &http.Client{Transport: resilience.NewPolicyTransport(e, nil)}
+	resp, err = client.Get(server.URL)
+	assert.Nil(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusServiceUnavailable)
+	assert.Equal(t, len(bodies), 2)
+}
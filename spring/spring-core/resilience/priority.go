@@ -0,0 +1,238 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resilience
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-core/web"
+)
+
+// ErrPriorityQueueFull 优先级队列已满或者排队超时，请求被拒绝。
+var ErrPriorityQueueFull = errors.New("resilience: priority queue is full")
+
+// PriorityClass 请求的优先级，繁忙时各个级别的排队请求按照权重比例获得执行许可，
+// 权重低的请求也会得到执行，但是不会挤占权重高的请求。
+type PriorityClass struct {
+	Name       string   `value:"${name}"`
+	Weight     int      `value:"${weight:=1}"`      // 繁忙时获得执行许可的权重
+	MaxQueue   int      `value:"${max-queue:=100}"` // 最多排队的请求数量，超过时拒绝
+	Routes     []string `value:"${routes:=}"`       // 属于该级别的路由，精确匹配或者以 /* 结尾按照前缀匹配
+	Principals []string `value:"${principals:=}"`   // 属于该级别的调用方，例如批处理任务使用的账号
+}
+
+// PriorityConfig 优先级调度配置，例如 web.priority.classes[0].name=interactive 。
+type PriorityConfig struct {
+	MaxConcurrent int             `value:"${web.priority.max-concurrent:=100}"` // 同时处理的请求数量
+	MaxWait       time.Duration   `value:"${web.priority.max-wait:=1s}"`        // 排队等待的最长时间
+	Header        string          `value:"${web.priority.header:=}"`            // 指定级别名称的请求头，为空时不使用
+	Default       string          `value:"${web.priority.default:=}"`           // 没有匹配的级别时使用的级别，为空时使用最后一个级别
+	Classes       []PriorityClass `value:"${web.priority.classes:=}"`
+
+	// Principal 返回请求的调用方，为 nil 时依次使用 Basic Auth 的用户名和
+	// serviceauth 认证的服务名称。
+	Principal func(ctx web.Context) string
+}
+
+// PriorityStats 优先级队列的运行状态。
+type PriorityStats struct {
+	Name     string `json:"name"`
+	Waiting  int    `json:"waiting"`  // 正在排队的请求数量
+	Accepted int64  `json:"accepted"` // 获得许可的请求数量
+	Rejected int64  `json:"rejected"` // 被拒绝的请求数量，包括排队超时
+}
+
+type priorityQueue struct {
+	class    PriorityClass
+	waiters  list.List // *priorityWaiter
+	current  int       // 平滑加权轮询的当前权重
+	accepted int64
+	rejected int64
+}
+
+type priorityWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// PriorityScheduler 按照优先级调度请求，同时处理的请求数量达到上限时请求按照级别
+// 排队，有请求结束时使用平滑加权轮询从各个级别的队列中选择下一个请求。
+type PriorityScheduler struct {
+	config PriorityConfig
+	mutex  sync.Mutex
+	active int
+	queues []*priorityQueue
+	byName map[string]*priorityQueue
+	def    *priorityQueue
+}
+
+var (
+	schedulersMu sync.RWMutex
+	schedulers   []*PriorityScheduler
+)
+
+// NewPriorityScheduler 创建并注册优先级调度器。
+func NewPriorityScheduler(config PriorityConfig) (*PriorityScheduler, error) {
+	if config.MaxConcurrent <= 0 {
+		return nil, fmt.Errorf("web.priority.max-concurrent: invalid value %d", config.MaxConcurrent)
+	}
+	if len(config.Classes) == 0 {
+		return nil, errors.New("web.priority.classes: no priority class")
+	}
+	s := &PriorityScheduler{config: config, byName: make(map[string]*priorityQueue)}
+	for _, c := range config.Classes {
+		if c.Weight <= 0 {
+			return nil, fmt.Errorf("web.priority.classes: class %q has invalid weight %d", c.Name, c.Weight)
+		}
+		if _, ok := s.byName[c.Name]; ok {
+			return nil, fmt.Errorf("web.priority.classes: duplicate class %q", c.Name)
+		}
+		q := &priorityQueue{class: c}
+		s.queues = append(s.queues, q)
+		s.byName[c.Name] = q
+	}
+	s.def = s.queues[len(s.queues)-1]
+	if config.Default != "" {
+		if s.def = s.byName[config.Default]; s.def == nil {
+			return nil, fmt.Errorf("web.priority.default: class %q not found", config.Default)
+		}
+	}
+	schedulersMu.Lock()
+	defer schedulersMu.Unlock()
+	schedulers = append(schedulers, s)
+	return s, nil
+}
+
+// Acquire 以 class 级别获取处理请求的许可，未知的级别使用默认级别。成功时返回的函数
+// 必须在请求结束时调用且只能调用一次。队列已满或者排队超时时返回 ErrPriorityQueueFull ，
+// 排队期间 ctx 结束时返回 ctx.Err() 。
+func (s *PriorityScheduler) Acquire(ctx context.Context, class string) (release func(), err error) {
+
+	s.mutex.Lock()
+	q := s.byName[class]
+	if q == nil {
+		q = s.def
+	}
+
+	if s.active < s.config.MaxConcurrent {
+		s.active++
+		q.accepted++
+		s.mutex.Unlock()
+		return s.release, nil
+	}
+
+	if q.waiters.Len() >= q.class.MaxQueue || s.config.MaxWait <= 0 {
+		q.rejected++
+		s.mutex.Unlock()
+		return nil, ErrPriorityQueueFull
+	}
+
+	w := &priorityWaiter{ready: make(chan struct{})}
+	e := q.waiters.PushBack(w)
+	s.mutex.Unlock()
+
+	t := time.NewTimer(s.config.MaxWait)
+	defer t.Stop()
+
+	select {
+	case <-w.ready:
+		return s.release, nil
+	case <-t.C:
+		err = ErrPriorityQueueFull
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// 放弃等待的同时获得了许可。
+	if w.granted {
+		return s.release, nil
+	}
+	q.waiters.Remove(e)
+	q.rejected++
+	return nil, err
+}
+
+// release 将许可交给下一个排队的请求，没有排队的请求时归还许可。
+func (s *PriorityScheduler) release() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	q := s.next()
+	if q == nil {
+		s.active--
+		return
+	}
+	w := q.waiters.Remove(q.waiters.Front()).(*priorityWaiter)
+	w.granted = true
+	close(w.ready)
+}
+
+// next 使用平滑加权轮询选择下一个有排队请求的队列，并且预先计入获得许可的数量。
+func (s *PriorityScheduler) next() *priorityQueue {
+	var (
+		total  int
+		chosen *priorityQueue
+	)
+	for _, q := range s.queues {
+		if q.waiters.Len() == 0 {
+			continue
+		}
+		q.current += q.class.Weight
+		total += q.class.Weight
+		if chosen == nil || q.current > chosen.current {
+			chosen = q
+		}
+	}
+	if chosen != nil {
+		chosen.current -= total
+		chosen.accepted++
+	}
+	return chosen
+}
+
+// Stats 返回各个级别的运行状态。
+func (s *PriorityScheduler) Stats() []PriorityStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var ret []PriorityStats
+	for _, q := range s.queues {
+		ret = append(ret, PriorityStats{
+			Name:     q.class.Name,
+			Waiting:  q.waiters.Len(),
+			Accepted: q.accepted,
+			Rejected: q.rejected,
+		})
+	}
+	return ret
+}
+
+// SchedulerStats 返回所有优先级调度器各个级别的运行状态。
+func SchedulerStats() []PriorityStats {
+	schedulersMu.RLock()
+	defer schedulersMu.RUnlock()
+	var ret []PriorityStats
+	for _, s := range schedulers {
+		ret = append(ret, s.Stats()...)
+	}
+	return ret
+}
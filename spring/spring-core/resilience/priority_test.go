@@ -0,0 +1,216 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resilience_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-base/knife"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/resilience"
+	"github.com/go-spring/spring-core/serviceauth"
+	"github.com/go-spring/spring-core/web"
+)
+
+func waiting(s *resilience.PriorityScheduler) map[string]int {
+	m := make(map[string]int)
+	for _, stats := range s.Stats() {
+		m[stats.Name] = stats.Waiting
+	}
+	return m
+}
+
+func TestPriorityScheduler(t *testing.T) {
+
+	s, err := resilience.NewPriorityScheduler(resilience.PriorityConfig{
+		MaxConcurrent: 1,
+		MaxWait:       5 * time.Second,
+		Classes: []resilience.PriorityClass{
+			{Name: "interactive", Weight: 3, MaxQueue: 10},
+			{Name: "batch", Weight: 1, MaxQueue: 10},
+		},
+	})
+	assert.Nil(t, err)
+
+	release, err := s.Acquire(context.Background(), "interactive")
+	assert.Nil(t, err)
+
+	var (
+		mutex sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+	acquire := func(class string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := s.Acquire(context.Background(), class)
+			assert.Nil(t, err)
+			mutex.Lock()
+			order = append(order, class)
+			mutex.Unlock()
+			r()
+		}()
+	}
+
+	// 低优先级的请求先到达也不会挤占高优先级的请求。
+	for i := 0; i < 4; i++ {
+		acquire("batch")
+	}
+	for i := 0; i < 4; i++ {
+		acquire("interactive")
+	}
+	for waiting(s)["batch"] != 4 || waiting(s)["interactive"] != 4 {
+		time.Sleep(time.Millisecond)
+	}
+
+	release()
+	wg.Wait()
+	assert.Equal(t, order, []string{
+		"interactive", "interactive", "batch", "interactive",
+		"interactive", "batch", "batch", "batch",
+	})
+
+	for _, stats := range s.Stats() {
+		switch stats.Name {
+		case "interactive":
+			assert.Equal(t, stats.Accepted, int64(5))
+		case "batch":
+			assert.Equal(t, stats.Accepted, int64(4))
+		}
+	}
+}
+
+func TestPriorityScheduler_Reject(t *testing.T) {
+
+	s, err := resilience.NewPriorityScheduler(resilience.PriorityConfig{
+		MaxConcurrent: 1,
+		MaxWait:       50 * time.Millisecond,
+		Default:       "batch",
+		Classes: []resilience.PriorityClass{
+			{Name: "interactive", Weight: 3, MaxQueue: 10},
+			{Name: "batch", Weight: 1, MaxQueue: 1},
+		},
+	})
+	assert.Nil(t, err)
+
+	release, err := s.Acquire(context.Background(), "interactive")
+	assert.Nil(t, err)
+	defer release()
+
+	// 未知的级别使用默认级别，队列已满时立即拒绝。
+	done := make(chan error)
+	go func() {
+		_, err := s.Acquire(context.Background(), "unknown")
+		done <- err
+	}()
+	for waiting(s)["batch"] != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	_, err = s.Acquire(context.Background(), "batch")
+	assert.Equal(t, err, resilience.ErrPriorityQueueFull)
+	assert.Equal(t, <-done, resilience.ErrPriorityQueueFull)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.Acquire(ctx, "interactive")
+	assert.Equal(t, err, context.Canceled)
+	assert.Equal(t, waiting(s)["interactive"], 0)
+
+	_, err = resilience.NewPriorityScheduler(resilience.PriorityConfig{
+		MaxConcurrent: 1,
+		Default:       "none",
+		Classes:       []resilience.PriorityClass{{Name: "batch", Weight: 1}},
+	})
+	assert.Error(t, err, "web.priority.default: class \"none\" not found")
+}
+
+func TestPriorityFilter(t *testing.T) {
+
+	p, err := conf.Bytes([]byte(`
+		web.priority.header=X-Priority
+		web.priority.default=interactive
+		web.priority.classes[0].name=batch
+		web.priority.classes[0].routes=/api/export/*
+		web.priority.classes[0].principals=etl
+		web.priority.classes[1].name=interactive
+		web.priority.classes[1].weight=4
+	`), ".properties")
+	assert.Nil(t, err)
+
+	var config resilience.PriorityConfig
+	err = p.Bind(&config)
+	assert.Nil(t, err)
+	assert.Equal(t, config.MaxConcurrent, 100)
+	assert.Equal(t, config.Classes[0].Weight, 1)
+
+	f, err := resilience.NewPriorityFilter(config)
+	assert.Nil(t, err)
+	handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		ctx.String("ok")
+	})
+
+	accepted := func() map[string]int64 {
+		m := make(map[string]int64)
+		for _, stats := range resilience.SchedulerStats() {
+			m[stats.Name] += stats.Accepted
+		}
+		return m
+	}
+
+	for _, c := range []struct {
+		path    string
+		header  string
+		user    string
+		service string
+		class   string
+	}{
+		{"/api/export/orders", "", "", "", "batch"},
+		{"/api/orders", "", "", "", "interactive"},
+		{"/api/orders", "batch", "", "", "batch"},
+		{"/api/orders", "unknown", "", "", "interactive"},
+		{"/api/orders", "", "etl", "", "batch"},
+		{"/api/orders", "", "", "etl", "batch"},
+		{"/api/orders", "", "alice", "", "interactive"},
+	} {
+		before := accepted()
+		ctx, _ := knife.New(context.Background())
+		if c.service != "" {
+			err = serviceauth.Set(ctx, c.service)
+			assert.Nil(t, err)
+		}
+		r := httptest.NewRequest(http.MethodGet, c.path, nil).WithContext(ctx)
+		if c.header != "" {
+			r.Header.Set("X-Priority", c.header)
+		}
+		w := httptest.NewRecorder()
+		webCtx := web.NewBaseContext("", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+		if c.user != "" {
+			webCtx.Set(web.AuthUserKey, c.user)
+		}
+		web.NewFilterChain([]web.Filter{f, handler}).Next(webCtx)
+		assert.Equal(t, w.Body.String(), "ok")
+		after := accepted()
+		assert.Equal(t, after[c.class]-before[c.class], int64(1))
+	}
+}
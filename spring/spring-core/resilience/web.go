@@ -0,0 +1,373 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-spring/spring-core/serviceauth"
+	"github.com/go-spring/spring-core/web"
+)
+
+// NewBulkheadFilter 创建限制同时处理的请求数量的过滤器，可以通过 URLPatterns 只保护
+// 部分路由。被拒绝的请求返回 503 状态码。
+func NewBulkheadFilter(b *Bulkhead) web.Filter {
+	return web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		release, err := b.Acquire(ctx.Context())
+		if err != nil {
+			ctx.SetStatus(http.StatusServiceUnavailable)
+			ctx.String(err.Error())
+			return
+		}
+		defer release()
+		chain.Next(ctx)
+	})
+}
+
+// Transport 限制同时进行的 http 客户端请求数量的 http.RoundTripper ，请求的响应头
+// 返回之后即释放许可。
+type Transport struct {
+	Base     http.RoundTripper // 为 nil 时使用 http.DefaultTransport
+	bulkhead *Bulkhead
+}
+
+// NewTransport 创建限制并发请求数量的 Transport 。
+func NewTransport(b *Bulkhead, base http.RoundTripper) *Transport {
+	return &Transport{Base: base, bulkhead: b}
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	release, err := t.bulkhead.Acquire(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return base.RoundTrip(r)
+}
+
+// AdaptiveLimitConfig 自适应并发限制配置，Routes 的键为请求路径或者注册的路由路径，
+// 以 /* 结尾时按照前缀匹配，键 default 表示其他请求，例如
+// web.adaptive-limit./api/*.max-limit=200 。每个键对应一个独立的限制器。
+type AdaptiveLimitConfig struct {
+	Routes map[string]AdaptiveConfig `value:"${web.adaptive-limit:=}"`
+}
+
+// NewAdaptiveLimitFilter 创建按照路由分组进行自适应并发限制的过滤器，没有匹配的分组
+// 时不做限制。被拒绝的请求返回 503 状态码，超时以及返回 503 、504 状态码的请求被
+// 视为过载。
+func NewAdaptiveLimitFilter(config AdaptiveLimitConfig) (web.Filter, error) {
+	groups := make(map[string]*AdaptiveLimiter)
+	for route, c := range config.Routes {
+		l, err := NewAdaptiveLimiter("web:"+route, c)
+		if err != nil {
+			return nil, fmt.Errorf("web.adaptive-limit.%s: %w", route, err)
+		}
+		groups[route] = l
+	}
+	return web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+
+		l := matchRoute(ctx, groups)
+		if l == nil {
+			chain.Continue(ctx)
+			return
+		}
+
+		release, err := l.Acquire()
+		if err != nil {
+			ctx.SetStatus(http.StatusServiceUnavailable)
+			ctx.String(err.Error())
+			return
+		}
+
+		dropped := true
+		defer func() { release(dropped) }()
+		chain.Next(ctx)
+
+		switch ctx.ResponseWriter().Status() {
+		case http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		default:
+			dropped = ctx.Context().Err() == context.DeadlineExceeded
+		}
+	}), nil
+}
+
+// matchRoute 返回请求所属分组的限制器，匹配规则和 web.NewTimeoutFilter 相同。
+func matchRoute(ctx web.Context, groups map[string]*AdaptiveLimiter) *AdaptiveLimiter {
+	path := ctx.Request().URL.Path
+	if l, ok := groups[path]; ok {
+		return l
+	}
+	if l, ok := groups[ctx.Path()]; ok {
+		return l
+	}
+	var (
+		prefix string
+		l      *AdaptiveLimiter
+	)
+	for k, v := range groups {
+		if strings.HasSuffix(k, "/*") {
+			p := strings.TrimSuffix(k, "*")
+			if strings.HasPrefix(path, p) && len(p) > len(prefix) {
+				prefix, l = p, v
+			}
+		}
+	}
+	if prefix != "" {
+		return l
+	}
+	return groups["default"]
+}
+
+// errServerError 路由返回了 5xx 状态码。
+var errServerError = errors.New("server error")
+
+// NewPolicyFilter 创建为通过 Mapper.WithPolicy 设置了调用策略的路由执行超时和熔断
+// 设置的过滤器，路由不会重试。熔断器打开时返回 503 ，超时并且还没有写入响应时返回
+// 504 ，返回 5xx 状态码的请求计入失败次数。路由地址按照注册时的原样匹配。
+func NewPolicyFilter(registry *Registry, router web.Router) (web.Filter, error) {
+	routes := make(map[string]*Executor)
+	for _, m := range router.Mappers() {
+		if m.Policy() == "" {
+			continue
+		}
+		e, err := registry.Get(m.Policy())
+		if err != nil {
+			return nil, err
+		}
+		for _, method := range web.GetMethod(m.Method()) {
+			routes[method+" "+m.Path()] = e
+		}
+	}
+	return web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+
+		e, ok := routes[ctx.Request().Method+" "+ctx.Path()]
+		if !ok {
+			chain.Continue(ctx)
+			return
+		}
+
+		err := e.ExecuteOnce(ctx.Context(), func(c context.Context) error {
+			ctx.SetContext(c)
+			chain.Next(ctx)
+			if c.Err() == context.DeadlineExceeded {
+				return c.Err()
+			}
+			if ctx.ResponseWriter().Status() >= http.StatusInternalServerError {
+				return errServerError
+			}
+			return nil
+		})
+
+		w := ctx.ResponseWriter()
+		switch {
+		case err == ErrCircuitOpen:
+			ctx.SetStatus(http.StatusServiceUnavailable)
+			ctx.String(err.Error())
+		case err == context.DeadlineExceeded && w.Status() == 0 && w.Size() == 0:
+			ctx.SetStatus(http.StatusGatewayTimeout)
+			ctx.String(http.StatusText(http.StatusGatewayTimeout))
+		}
+	}), nil
+}
+
+// PolicyTransport 按照调用策略执行 http 客户端请求的 http.RoundTripper 。网络错误
+// 以及 502 、503 、504 状态码会被重试，最后一次调用返回这些状态码时原样返回响应。
+// 请求体不能重新读取(即 GetBody 为 nil)时不重试。
+type PolicyTransport struct {
+	Base     http.RoundTripper // 为 nil 时使用 http.DefaultTransport
+	executor *Executor
+}
+
+// NewPolicyTransport 创建按照调用策略执行请求的 PolicyTransport 。
+func NewPolicyTransport(e *Executor, base http.RoundTripper) *PolicyTransport {
+	return &PolicyTransport{Base: base, executor: e}
+}
+
+// errRetryableStatus 响应的状态码可以重试。
+var errRetryableStatus = errors.New("retryable status")
+
+func (t *PolicyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	attempts := t.executor.policy.Retry.MaxAttempts
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		attempts = 1
+	}
+
+	var (
+		resp  *http.Response
+		first = true
+	)
+	err := t.executor.execute(r.Context(), attempts, false, func(ctx context.Context) error {
+		if resp != nil {
+			_ = resp.Body.Close()
+			resp = nil
+		}
+
+		// 超时需要覆盖读取响应体的过程，因此在关闭响应体时才取消 ctx 。
+		cancel := func() {}
+		if d := t.executor.policy.Timeout; d > 0 {
+			ctx, cancel = context.WithTimeout(ctx, d)
+		}
+		req := r.Clone(ctx)
+		if !first && r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				cancel()
+				return Permanent(err)
+			}
+			req.Body = body
+		}
+		first = false
+
+		res, err := base.RoundTrip(req)
+		if err != nil {
+			cancel()
+			return err
+		}
+		res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+		resp = res
+		switch res.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return errRetryableStatus
+		}
+		return nil
+	})
+	if err == errRetryableStatus {
+		return resp, nil
+	}
+	if err != nil {
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// cancelBody 关闭时取消请求 ctx 的响应体。
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// NewPriorityFilter 创建按照优先级调度请求的过滤器，避免繁忙时低优先级的批量请求挤占
+// 交互请求的处理能力。请求的级别依次由请求头、路由和调用方决定：请求头指定了已配置的
+// 级别时使用该级别，否则按照配置的顺序检查每个级别，路由或者调用方匹配时使用该级别，
+// 都不匹配时使用默认级别。被拒绝的请求返回 503 状态码。请求头可以被客户端伪造，只应
+// 该在由网关设置该请求头时使用。
+func NewPriorityFilter(config PriorityConfig) (web.Filter, error) {
+	s, err := NewPriorityScheduler(config)
+	if err != nil {
+		return nil, err
+	}
+	principal := config.Principal
+	if principal == nil {
+		principal = defaultPrincipal
+	}
+	return web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		release, err := s.Acquire(ctx.Context(), classify(ctx, config, principal))
+		if err != nil {
+			ctx.SetStatus(http.StatusServiceUnavailable)
+			ctx.String(err.Error())
+			return
+		}
+		defer release()
+		chain.Next(ctx)
+	}), nil
+}
+
+// classify 返回请求的优先级名称，没有匹配的级别时返回空字符串。
+func classify(ctx web.Context, config PriorityConfig, principal func(web.Context) string) string {
+	if config.Header != "" {
+		if name := ctx.Header(config.Header); name != "" {
+			for _, c := range config.Classes {
+				if c.Name == name {
+					return name
+				}
+			}
+		}
+	}
+	var (
+		who     string
+		checked bool
+	)
+	for _, c := range config.Classes {
+		for _, route := range c.Routes {
+			if matchPriorityRoute(ctx, route) {
+				return c.Name
+			}
+		}
+		if len(c.Principals) == 0 {
+			continue
+		}
+		if !checked {
+			who, checked = principal(ctx), true
+		}
+		if who == "" {
+			continue
+		}
+		for _, p := range c.Principals {
+			if p == who {
+				return c.Name
+			}
+		}
+	}
+	return ""
+}
+
+// defaultPrincipal 依次使用 Basic Auth 的用户名和 serviceauth 认证的服务名称。
+func defaultPrincipal(ctx web.Context) string {
+	if user, ok := ctx.Get(web.AuthUserKey).(string); ok && user != "" {
+		return user
+	}
+	if service, ok := serviceauth.Get(ctx.Context()); ok {
+		return service
+	}
+	return ""
+}
+
+// matchPriorityRoute 判断请求是否属于路由，匹配规则和 web.NewTimeoutFilter 相同。
+func matchPriorityRoute(ctx web.Context, route string) bool {
+	path := ctx.Request().URL.Path
+	if route == path || route == ctx.Path() {
+		return true
+	}
+	if strings.HasSuffix(route, "/*") {
+		return strings.HasPrefix(path, strings.TrimSuffix(route, "*"))
+	}
+	return false
+}
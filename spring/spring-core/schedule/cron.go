@@ -0,0 +1,195 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bounds cron 表达式中一个字段的取值范围以及可以使用的名称。
+type bounds struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	seconds = bounds{0, 59, nil}
+	minutes = bounds{0, 59, nil}
+	hours   = bounds{0, 23, nil}
+	days    = bounds{1, 31, nil}
+	months  = bounds{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	weekdays = bounds{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var macros = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// Cron 解析后的 cron 表达式，每个字段使用一个位图表示可以匹配的值。
+type Cron struct {
+	second, minute, hour, day, month, weekday uint64
+
+	anyDay     bool // 日字段是 * 或者 ?
+	anyWeekday bool // 周字段是 * 或者 ?
+}
+
+// ParseCron 解析 cron 表达式，支持 "分 时 日 月 周" 形式的 5 段表达式和在前面增加
+// 秒字段的 6 段表达式，以及 @daily 、@hourly 等预定义的表达式。字段支持 * 、? 、
+// 列表 1,3 、范围 1-5 、步长 */10 以及月份和星期的英文缩写，星期的 0 和 7 都表示
+// 星期日。日和周字段都不是 * 时，满足其中一个即可。
+func ParseCron(spec string) (*Cron, error) {
+
+	s := strings.TrimSpace(spec)
+	if strings.HasPrefix(s, "@") {
+		m, ok := macros[strings.ToLower(s)]
+		if !ok {
+			return nil, fmt.Errorf("cron %q: unknown macro", spec)
+		}
+		s = m
+	}
+
+	fields := strings.Fields(s)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron %q: expected 5 or 6 fields, found %d", spec, len(fields))
+	}
+
+	c := &Cron{
+		anyDay:     fields[3] == "*" || fields[3] == "?",
+		anyWeekday: fields[5] == "*" || fields[5] == "?",
+	}
+
+	targets := []*uint64{&c.second, &c.minute, &c.hour, &c.day, &c.month, &c.weekday}
+	for i, b := range []bounds{seconds, minutes, hours, days, months, weekdays} {
+		bits, err := parseField(fields[i], b)
+		if err != nil {
+			return nil, fmt.Errorf("cron %q: %w", spec, err)
+		}
+		*targets[i] = bits
+	}
+
+	// 星期日既可以用 0 也可以用 7 表示。
+	if c.weekday&(1<<7) != 0 {
+		c.weekday |= 1
+	}
+	return c, nil
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, expr := range strings.Split(field, ",") {
+		start, end, step := b.min, b.max, 1
+		rangeAndStep := strings.SplitN(expr, "/", 2)
+		if len(rangeAndStep) == 2 {
+			n, err := strconv.Atoi(rangeAndStep[1])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", expr)
+			}
+			step = n
+		}
+		switch r := rangeAndStep[0]; r {
+		case "*", "?":
+		default:
+			lowAndHigh := strings.SplitN(r, "-", 2)
+			n, err := parseValue(lowAndHigh[0], b)
+			if err != nil {
+				return 0, err
+			}
+			start = n
+			if len(lowAndHigh) == 2 {
+				if end, err = parseValue(lowAndHigh[1], b); err != nil {
+					return 0, err
+				}
+			} else if len(rangeAndStep) == 1 {
+				end = start
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid range %q", expr)
+			}
+		}
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, b bounds) (int, error) {
+	if n, ok := b.names[strings.ToLower(s)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if n < b.min || n > b.max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", n, b.min, b.max)
+	}
+	return n, nil
+}
+
+// Next 返回 t 之后第一个满足表达式的时间，精确到秒，五年之内没有满足的时间时
+// 返回零值。
+func (c *Cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Second).Add(time.Second)
+	limit := t.Year() + 5
+	for t.Year() <= limit {
+		y, m, d := t.Date()
+		switch {
+		case c.month&(1<<uint(m)) == 0:
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = time.Date(y, m, d, t.Hour(), t.Minute()+1, 0, 0, loc)
+		case c.second&(1<<uint(t.Second())) == 0:
+			t = t.Add(time.Second)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *Cron) dayMatches(t time.Time) bool {
+	day := c.day&(1<<uint(t.Day())) != 0
+	weekday := c.weekday&(1<<uint(t.Weekday())) != 0
+	if c.anyDay || c.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
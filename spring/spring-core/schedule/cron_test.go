@@ -0,0 +1,75 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schedule_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/schedule"
+)
+
+func TestCron(t *testing.T) {
+
+	now := time.Date(2021, 12, 1, 10, 30, 15, 0, time.UTC) // 星期三
+
+	testcases := []struct {
+		spec string
+		next string
+	}{
+		{"* * * * * *", "2021-12-01 10:30:16"},
+		{"0 3 * * *", "2021-12-02 03:00:00"},
+		{"*/20 * * * *", "2021-12-01 10:40:00"},
+		{"30 10-12/2 * * *", "2021-12-01 12:30:00"},
+		{"0 0 1,15 * *", "2021-12-15 00:00:00"},
+		{"0 0 * * SUN", "2021-12-05 00:00:00"},
+		{"0 0 * * 7", "2021-12-05 00:00:00"},
+		{"0 0 13 * 5", "2021-12-03 00:00:00"},
+		{"0 0 1 feb ?", "2022-02-01 00:00:00"},
+		{"0 0 29 2 *", "2024-02-29 00:00:00"},
+		{"@hourly", "2021-12-01 11:00:00"},
+		{"@monthly", "2022-01-01 00:00:00"},
+	}
+
+	for _, c := range testcases {
+		cron, err := schedule.ParseCron(c.spec)
+		assert.Nil(t, err)
+		assert.Equal(t, cron.Next(now).Format("2006-01-02 15:04:05"), c.next)
+	}
+
+	cron, err := schedule.ParseCron("0 0 30 2 *")
+	assert.Nil(t, err)
+	assert.True(t, cron.Next(now).IsZero())
+
+	errors := []struct {
+		spec string
+		err  string
+	}{
+		{"* * *", `cron "\* \* \*": expected 5 or 6 fields, found 3`},
+		{"@often", `cron "@often": unknown macro`},
+		{"60 * * * *", `cron "60 \* \* \* \*": value 60 out of range \[0, 59\]`},
+		{"* * * * mon-x", `invalid value "x"`},
+		{"5-1 * * * *", `invalid range "5-1"`},
+		{"*/0 * * * *", `invalid step "\*/0"`},
+	}
+
+	for _, c := range errors {
+		_, err = schedule.ParseCron(c.spec)
+		assert.Error(t, err, c.err)
+	}
+}
@@ -0,0 +1,197 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package schedule 通过属性定义定时任务，例如：
+//
+//	schedule.jobs.cleanup.cron=0 3 * * *
+//	schedule.jobs.cleanup.bean=cleaner.Run
+//
+// 表示每天 3 点执行名为 cleaner 的 bean 的 Run 方法，因此不修改代码就可以添加、
+// 调整或者通过 schedule.jobs.cleanup.enabled=false 禁用定时任务。
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/report"
+)
+
+var logger = log.GetLogger("GS_SCHEDULE")
+
+type Config struct {
+	Jobs map[string]JobConfig `value:"${jobs:=}"`
+}
+
+// JobConfig 定时任务的配置。
+type JobConfig struct {
+	Cron    string `value:"${cron}"`          // cron 表达式，参见 ParseCron
+	Bean    string `value:"${bean}"`          // bean 名称和方法名称，如 cleaner.Run
+	Enabled bool   `value:"${enabled:=true}"` // 是否启用
+}
+
+type job struct {
+	name string
+	cron *Cron
+	fn   func(ctx context.Context) error
+}
+
+// Scheduler 按照 cron 表达式周期性地执行 bean 的方法，上一次执行还未结束时跳过
+// 本次执行。方法可以接收一个 context.Context 参数，可以返回一个 error 。
+type Scheduler struct {
+	jobs   []*job
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewScheduler 创建 Scheduler ，cron 表达式不合法或者找不到 bean 的方法时返回错误。
+func NewScheduler(config Config, ctx gs.Context) (*Scheduler, error) {
+
+	var names []string
+	for name := range config.Jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s := new(Scheduler)
+	for _, name := range names {
+		c := config.Jobs[name]
+		if !c.Enabled {
+			logger.Infof("job %s is disabled", name)
+			continue
+		}
+		cron, err := ParseCron(c.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("job %q: %w", name, err)
+		}
+		fn, err := resolveMethod(ctx, c.Bean)
+		if err != nil {
+			return nil, fmt.Errorf("job %q: %w", name, err)
+		}
+		s.jobs = append(s.jobs, &job{name: name, cron: cron, fn: fn})
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s, nil
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// resolveMethod 查找 bean.Method 形式的方法，并包装成统一的函数形式。
+func resolveMethod(ctx gs.Context, target string) (func(ctx context.Context) error, error) {
+
+	i := strings.LastIndex(target, ".")
+	if i <= 0 || i == len(target)-1 {
+		return nil, fmt.Errorf("bean %q should be in the form of bean.Method", target)
+	}
+	beanName, methodName := target[:i], target[i+1:]
+
+	var b interface{}
+	if err := ctx.Get(&b, beanName); err != nil {
+		return nil, err
+	}
+
+	m := reflect.ValueOf(b).MethodByName(methodName)
+	if !m.IsValid() {
+		return nil, fmt.Errorf("bean %q has no method %q", beanName, methodName)
+	}
+
+	t := m.Type()
+	withContext := t.NumIn() == 1 && t.In(0) == contextType
+	returnError := t.NumOut() == 1 && t.Out(0) == errorType
+	if (t.NumIn() > 0 && !withContext) || (t.NumOut() > 0 && !returnError) {
+		return nil, fmt.Errorf("method %s should be func([context.Context]) [error], but %s", target, t)
+	}
+
+	return func(ctx context.Context) error {
+		var in []reflect.Value
+		if withContext {
+			in = append(in, reflect.ValueOf(ctx))
+		}
+		out := m.Call(in)
+		if returnError && !out[0].IsNil() {
+			return out[0].Interface().(error)
+		}
+		return nil
+	}, nil
+}
+
+// OnAppStart 开始执行定时任务。
+func (s *Scheduler) OnAppStart(ctx gs.Context) {
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go func(j *job) {
+			defer s.wg.Done()
+			s.loop(j)
+		}(j)
+	}
+}
+
+func (s *Scheduler) loop(j *job) {
+	for {
+		next := j.cron.Next(time.Now())
+		if next.IsZero() {
+			logger.WithContext(s.ctx).Warnf("job %s will never run again", j.name)
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := s.run(j); err != nil {
+			logger.WithContext(s.ctx).Errorf(log.ERROR, "job %s failed: %v", j.name, err)
+		}
+	}
+}
+
+// run 执行定时任务，任务中的 panic 会被上报并且作为任务的错误返回。
+func (s *Scheduler) run(j *job) (err error) {
+	ctx := report.WithTags(s.ctx, "schedule.job", j.name)
+	defer func() {
+		if v := recover(); v != nil {
+			report.Panic(ctx, report.SourceTask, v)
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return j.fn(ctx)
+}
+
+// OnAppStop 停止执行定时任务，并等待正在执行的任务结束。
+func (s *Scheduler) OnAppStop(ctx context.Context) {
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schedule_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/schedule"
+)
+
+type Cleaner struct {
+	count int32
+}
+
+func (c *Cleaner) Run(ctx context.Context) error {
+	if atomic.AddInt32(&c.count, 1) == 1 {
+		return errors.New("first run failed")
+	}
+	return nil
+}
+
+func (c *Cleaner) Stats() int {
+	return int(atomic.LoadInt32(&c.count))
+}
+
+func newScheduler(cleaner *Cleaner, props map[string]string) (*schedule.Scheduler, error) {
+	c := gs.New()
+	for k, v := range props {
+		c.Property(k, v)
+	}
+	c.Object(cleaner).Name("cleaner")
+	var s *schedule.Scheduler
+	c.Provide(schedule.NewScheduler, "${schedule}").Init(func(b *schedule.Scheduler) { s = b })
+	err := c.Refresh()
+	return s, err
+}
+
+func TestScheduler(t *testing.T) {
+
+	t.Run("run", func(t *testing.T) {
+		cleaner := new(Cleaner)
+		s, err := newScheduler(cleaner, map[string]string{
+			"schedule.jobs.cleanup.cron":     "* * * * * *",
+			"schedule.jobs.cleanup.bean":     "cleaner.Run",
+			"schedule.jobs.disabled.cron":    "* * * * * *",
+			"schedule.jobs.disabled.bean":    "nothing.Run",
+			"schedule.jobs.disabled.enabled": "false",
+		})
+		assert.Nil(t, err)
+		s.OnAppStart(nil)
+		time.Sleep(2500 * time.Millisecond)
+		s.OnAppStop(context.Background())
+		assert.True(t, atomic.LoadInt32(&cleaner.count) >= 2)
+	})
+
+	testcases := []struct {
+		cron string
+		bean string
+		err  string
+	}{
+		{"0 3 * * *", "cleaner", `job "cleanup": bean "cleaner" should be in the form of bean.Method`},
+		{"0 3 * * *", "nothing.Run", `job "cleanup": can't find bean, bean:"nothing"`},
+		{"0 3 * * *", "cleaner.Stop", `job "cleanup": bean "cleaner" has no method "Stop"`},
+		{"0 3 * * *", "cleaner.Stats", `job "cleanup": method cleaner.Stats should be func\(\[context.Context\]\) \[error\], but func\(\) int`},
+		{"0 3 * *", "cleaner.Run", `job "cleanup": cron "0 3 \* \*": expected 5 or 6 fields, found 4`},
+	}
+
+	for _, c := range testcases {
+		_, err := newScheduler(new(Cleaner), map[string]string{
+			"schedule.jobs.cleanup.cron": c.cron,
+			"schedule.jobs.cleanup.bean": c.bean,
+		})
+		assert.Error(t, err, c.err)
+	}
+}
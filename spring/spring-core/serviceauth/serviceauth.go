@@ -0,0 +1,230 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package serviceauth 提供了服务之间的认证，调用方使用共享密钥为请求签发 HS256
+// 算法的 JWT ，被调用方校验令牌并将调用方的服务名称保存到请求上下文中，同时支持
+// 按照服务名称设置白名单和黑名单。密钥通过 kid 区分，轮换密钥时先在所有服务上添加
+// 新密钥，再切换签名使用的密钥，最后删除旧密钥。
+package serviceauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-spring/spring-base/knife"
+)
+
+// HeaderServiceToken 携带服务令牌的请求头。
+const HeaderServiceToken = "X-Service-Token"
+
+// ctxKey 调用方服务名称在请求上下文中的 key 。
+const ctxKey = "::service::"
+
+var (
+	ErrNoToken      = errors.New("serviceauth: no service token")
+	ErrInvalidToken = errors.New("serviceauth: invalid service token")
+	ErrExpiredToken = errors.New("serviceauth: service token is expired")
+	ErrDenied       = errors.New("serviceauth: service is not allowed")
+)
+
+// Config 服务认证配置，通常配合 service-auth 前缀一起使用。
+type Config struct {
+	Service string            `value:"${service:=}"`   // 当前服务的名称，签发的令牌以此作为 iss 并校验令牌的 aud
+	KeyID   string            `value:"${key-id:=}"`    // 签名使用的密钥
+	Keys    map[string]string `value:"${keys:=}"`      // 所有有效的密钥，键为 kid
+	TTL     time.Duration     `value:"${ttl:=5m}"`     // 令牌的有效期
+	Leeway  time.Duration     `value:"${leeway:=30s}"` // 校验有效期时允许的时钟偏差
+	Allow   []string          `value:"${allow:=}"`     // 允许调用的服务，为空时不限制
+	Deny    []string          `value:"${deny:=}"`      // 禁止调用的服务，优先于 Allow
+}
+
+// Claims 服务令牌携带的信息。
+type Claims struct {
+	Issuer    string `json:"iss"`           // 调用方的服务名称
+	Audience  string `json:"aud,omitempty"` // 被调用方的服务名称，为空时不限制
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// Signer 为发出的请求签发服务令牌。
+type Signer struct {
+	service string
+	kid     string
+	key     []byte
+	ttl     time.Duration
+}
+
+// NewSigner 创建 Signer ，Service 和 KeyID 不能为空，并且 KeyID 必须在 Keys 中。
+func NewSigner(config Config) (*Signer, error) {
+	if config.Service == "" {
+		return nil, errors.New("serviceauth: service is required")
+	}
+	key, ok := config.Keys[config.KeyID]
+	if !ok || key == "" {
+		return nil, fmt.Errorf("serviceauth: key %q not found", config.KeyID)
+	}
+	if config.TTL <= 0 {
+		return nil, errors.New("serviceauth: ttl must be positive")
+	}
+	return &Signer{
+		service: config.Service,
+		kid:     config.KeyID,
+		key:     []byte(key),
+		ttl:     config.TTL,
+	}, nil
+}
+
+// Sign 签发调用 audience 服务的令牌，audience 为空时令牌可以用于调用任何服务。
+func (s *Signer) Sign(audience string) (string, error) {
+	now := time.Now()
+	return sign(header{Alg: "HS256", Kid: s.kid, Typ: "JWT"}, Claims{
+		Issuer:    s.service,
+		Audience:  audience,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.ttl).Unix(),
+	}, s.key)
+}
+
+func sign(h header, c Claims, key []byte) (string, error) {
+	hb, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+	cb, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	s := base64.RawURLEncoding.EncodeToString(hb) + "." + base64.RawURLEncoding.EncodeToString(cb)
+	return s + "." + base64.RawURLEncoding.EncodeToString(signature(s, key)), nil
+}
+
+func signature(s string, key []byte) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(s))
+	return m.Sum(nil)
+}
+
+// Verifier 校验收到的服务令牌以及调用方是否被允许。
+type Verifier struct {
+	service string
+	keys    map[string][]byte
+	leeway  time.Duration
+	allow   map[string]bool
+	deny    map[string]bool
+}
+
+// NewVerifier 创建 Verifier ，Keys 不能为空。
+func NewVerifier(config Config) (*Verifier, error) {
+	if len(config.Keys) == 0 {
+		return nil, errors.New("serviceauth: keys are required")
+	}
+	v := &Verifier{
+		service: config.Service,
+		keys:    make(map[string][]byte),
+		leeway:  config.Leeway,
+		allow:   make(map[string]bool),
+		deny:    make(map[string]bool),
+	}
+	for kid, key := range config.Keys {
+		v.keys[kid] = []byte(key)
+	}
+	for _, s := range config.Allow {
+		v.allow[s] = true
+	}
+	for _, s := range config.Deny {
+		v.deny[s] = true
+	}
+	return v, nil
+}
+
+// Verify 校验令牌的签名、有效期和接收方，然后检查调用方是否被允许，令牌无效时返回
+// ErrInvalidToken 或者 ErrExpiredToken ，调用方不被允许时返回 ErrDenied 。
+func (v *Verifier) Verify(token string) (*Claims, error) {
+
+	if token == "" {
+		return nil, ErrNoToken
+	}
+
+	ss := strings.Split(token, ".")
+	if len(ss) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var h header
+	if err := decode(ss[0], &h); err != nil || h.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+	key, ok := v.keys[h.Kid]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(ss[2])
+	if err != nil || !hmac.Equal(sig, signature(ss[0]+"."+ss[1], key)) {
+		return nil, ErrInvalidToken
+	}
+
+	var c Claims
+	if err = decode(ss[1], &c); err != nil || c.Issuer == "" {
+		return nil, ErrInvalidToken
+	}
+	now := time.Now()
+	if now.Add(-v.leeway).Unix() >= c.ExpiresAt || now.Add(v.leeway).Unix() < c.IssuedAt {
+		return nil, ErrExpiredToken
+	}
+	if c.Audience != "" && v.service != "" && c.Audience != v.service {
+		return nil, ErrInvalidToken
+	}
+
+	if v.deny[c.Issuer] || (len(v.allow) > 0 && !v.allow[c.Issuer]) {
+		return &c, ErrDenied
+	}
+	return &c, nil
+}
+
+func decode(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// Set 将调用方的服务名称保存到上下文中，ctx 必须是 knife 上下文。
+func Set(ctx context.Context, service string) error {
+	return knife.Store(ctx, ctxKey, service)
+}
+
+// Get 返回上下文中保存的调用方服务名称。
+func Get(ctx context.Context) (string, bool) {
+	v, err := knife.Load(ctx, ctxKey)
+	if err != nil || v == nil {
+		return "", false
+	}
+	return v.(string), true
+}
@@ -0,0 +1,239 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package serviceauth_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-base/knife"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/serviceauth"
+	"github.com/go-spring/spring-core/web"
+)
+
+func newConfig(t *testing.T, s string) serviceauth.Config {
+	p, err := conf.Bytes([]byte(s), ".properties")
+	assert.Nil(t, err)
+	var config serviceauth.Config
+	err = p.Bind(&config, conf.Key("service-auth"))
+	assert.Nil(t, err)
+	return config
+}
+
+// token 使用 key 签发任意内容的令牌。
+func token(header, claims, key string) string {
+	s := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(claims))
+	m := hmac.New(sha256.New, []byte(key))
+	m.Write([]byte(s))
+	return s + "." + base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+func TestSignVerify(t *testing.T) {
+
+	order := newConfig(t, `
+service-auth.service=order
+service-auth.key-id=k1
+service-auth.keys.k1=secret1
+`)
+	assert.Equal(t, order.TTL, 5*time.Minute)
+	signer, err := serviceauth.NewSigner(order)
+	assert.Nil(t, err)
+
+	// 轮换密钥期间新旧密钥同时有效
+	user := newConfig(t, `
+service-auth.service=user
+service-auth.keys.k1=secret1
+service-auth.keys.k2=secret2
+`)
+	verifier, err := serviceauth.NewVerifier(user)
+	assert.Nil(t, err)
+
+	s, err := signer.Sign("user")
+	assert.Nil(t, err)
+	c, err := verifier.Verify(s)
+	assert.Nil(t, err)
+	assert.Equal(t, c.Issuer, "order")
+	assert.Equal(t, c.Audience, "user")
+
+	s, err = signer.Sign("")
+	assert.Nil(t, err)
+	_, err = verifier.Verify(s)
+	assert.Nil(t, err)
+
+	// 令牌发给了其他服务
+	s, err = signer.Sign("payment")
+	assert.Nil(t, err)
+	_, err = verifier.Verify(s)
+	assert.Equal(t, err, serviceauth.ErrInvalidToken)
+
+	// 旧密钥已经删除
+	delete(user.Keys, "k1")
+	verifier, err = serviceauth.NewVerifier(user)
+	assert.Nil(t, err)
+	s, err = signer.Sign("user")
+	assert.Nil(t, err)
+	_, err = verifier.Verify(s)
+	assert.Equal(t, err, serviceauth.ErrInvalidToken)
+
+	_, err = serviceauth.NewSigner(user)
+	assert.Error(t, err, `serviceauth: key "" not found`)
+	_, err = serviceauth.NewVerifier(serviceauth.Config{})
+	assert.Error(t, err, "serviceauth: keys are required")
+}
+
+func TestVerify_Invalid(t *testing.T) {
+
+	verifier, err := serviceauth.NewVerifier(serviceauth.Config{
+		Service: "user",
+		Keys:    map[string]string{"k1": "secret1"},
+	})
+	assert.Nil(t, err)
+
+	now := time.Now().Unix()
+	header := `{"alg":"HS256","kid":"k1","typ":"JWT"}`
+	valid := `{"iss":"order","iat":` + itoa(now) + `,"exp":` + itoa(now+60) + `}`
+
+	_, err = verifier.Verify(token(header, valid, "secret1"))
+	assert.Nil(t, err)
+
+	testcases := []struct {
+		token string
+		err   error
+	}{
+		{"", serviceauth.ErrNoToken},
+		{"a.b", serviceauth.ErrInvalidToken},
+		{token(header, valid, "secret2"), serviceauth.ErrInvalidToken},
+		{token(`{"alg":"none","kid":"k1"}`, valid, "secret1"), serviceauth.ErrInvalidToken},
+		{token(`{"alg":"HS256","kid":"k9"}`, valid, "secret1"), serviceauth.ErrInvalidToken},
+		{token(header, `{"iat":`+itoa(now)+`,"exp":`+itoa(now+60)+`}`, "secret1"), serviceauth.ErrInvalidToken},
+		{token(header, `{"iss":"order","iat":`+itoa(now-120)+`,"exp":`+itoa(now-60)+`}`, "secret1"), serviceauth.ErrExpiredToken},
+		{token(header, `{"iss":"order","iat":`+itoa(now+60)+`,"exp":`+itoa(now+120)+`}`, "secret1"), serviceauth.ErrExpiredToken},
+	}
+	for i, c := range testcases {
+		_, err = verifier.Verify(c.token)
+		assert.Equal(t, err, c.err, strconv.Itoa(i))
+	}
+
+	// 篡改内容之后签名失效
+	ss := strings.Split(token(header, valid, "secret1"), ".")
+	ss[1] = base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(valid, "order", "admin", 1)))
+	_, err = verifier.Verify(strings.Join(ss, "."))
+	assert.Equal(t, err, serviceauth.ErrInvalidToken)
+}
+
+func itoa(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
+
+func TestFilter(t *testing.T) {
+
+	config := newConfig(t, `
+service-auth.service=user
+service-auth.keys.k1=secret1
+service-auth.deny=payment
+`)
+	f, err := serviceauth.NewFilter(config)
+	assert.Nil(t, err)
+
+	var caller string
+	handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		caller, _ = serviceauth.Get(ctx.Context())
+		ctx.String("ok")
+	})
+
+	serve := func(service string) *httptest.ResponseRecorder {
+		ctx, _ := knife.New(context.Background())
+		r := httptest.NewRequest(http.MethodGet, "/users", nil).WithContext(ctx)
+		if service != "" {
+			signer, err := serviceauth.NewSigner(serviceauth.Config{
+				Service: service,
+				KeyID:   "k1",
+				Keys:    map[string]string{"k1": "secret1"},
+				TTL:     time.Minute,
+			})
+			assert.Nil(t, err)
+			s, err := signer.Sign("user")
+			assert.Nil(t, err)
+			r.Header.Set(serviceauth.HeaderServiceToken, s)
+		}
+		w := httptest.NewRecorder()
+		webCtx := web.NewBaseContext("/users", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+		web.NewFilterChain([]web.Filter{f, handler}).Next(webCtx)
+		return w
+	}
+
+	w := serve("order")
+	assert.Equal(t, w.Body.String(), "ok")
+	assert.Equal(t, caller, "order")
+
+	w = serve("payment")
+	assert.Equal(t, w.Code, http.StatusForbidden)
+	assert.Equal(t, w.Body.String(), serviceauth.ErrDenied.Error())
+
+	w = serve("")
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+	assert.Equal(t, w.Body.String(), serviceauth.ErrNoToken.Error())
+
+	// 白名单
+	config.Allow = []string{"gateway"}
+	f, err = serviceauth.NewFilter(config)
+	assert.Nil(t, err)
+	assert.Equal(t, serve("order").Code, http.StatusForbidden)
+	assert.Equal(t, serve("gateway").Body.String(), "ok")
+}
+
+func TestTransport(t *testing.T) {
+
+	config := serviceauth.Config{
+		Service: "order",
+		KeyID:   "k1",
+		Keys:    map[string]string{"k1": "secret1"},
+		TTL:     time.Minute,
+	}
+	verifier, err := serviceauth.NewVerifier(config)
+	assert.Nil(t, err)
+
+	var caller string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := verifier.Verify(r.Header.Get(serviceauth.HeaderServiceToken))
+		assert.Nil(t, err)
+		caller = c.Issuer
+	}))
+	defer server.Close()
+
+	signer, err := serviceauth.NewSigner(config)
+	assert.Nil(t, err)
+	client := &http.Client{Transport: serviceauth.NewTransport(signer, "order", nil)}
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	assert.Nil(t, err)
+	resp, err := client.Do(req)
+	assert.Nil(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, caller, "order")
+	assert.Equal(t, req.Header.Get(serviceauth.HeaderServiceToken), "")
+}
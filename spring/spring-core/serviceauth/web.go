@@ -0,0 +1,75 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package serviceauth
+
+import (
+	"net/http"
+
+	"github.com/go-spring/spring-core/web"
+)
+
+// NewFilter 创建校验服务令牌的过滤器，校验通过后将调用方的服务名称保存到请求上下文
+// 中。令牌缺失或者无效时返回 401 ，调用方不被允许时返回 403 。
+func NewFilter(config Config) (web.Filter, error) {
+	v, err := NewVerifier(config)
+	if err != nil {
+		return nil, err
+	}
+	return web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		c, err := v.Verify(ctx.Header(HeaderServiceToken))
+		if err != nil {
+			if err == ErrDenied {
+				ctx.SetStatus(http.StatusForbidden)
+			} else {
+				ctx.SetStatus(http.StatusUnauthorized)
+			}
+			ctx.String(err.Error())
+			return
+		}
+		if err = ctx.Set(ctxKey, c.Issuer); err != nil {
+			panic(err)
+		}
+		chain.Continue(ctx)
+	}), nil
+}
+
+// Transport 为 http 客户端请求添加服务令牌的 http.RoundTripper 。
+type Transport struct {
+	Base     http.RoundTripper // 为 nil 时使用 http.DefaultTransport
+	signer   *Signer
+	audience string
+}
+
+// NewTransport 创建为调用 audience 服务的请求添加令牌的 Transport 。
+func NewTransport(signer *Signer, audience string, base http.RoundTripper) *Transport {
+	return &Transport{Base: base, signer: signer, audience: audience}
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	token, err := t.signer.Sign(t.audience)
+	if err != nil {
+		return nil, err
+	}
+	// RoundTripper 不应该修改原始请求。
+	r = r.Clone(r.Context())
+	r.Header.Set(HeaderServiceToken, token)
+	return base.RoundTrip(r)
+}
@@ -0,0 +1,259 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sharding 根据分片键将数据路由到不同的数据源，分片使用数据源的名称表示，
+// 和具体的数据库客户端无关。配置示例:
+//
+//	sharding.tables[0].name=orders
+//	sharding.tables[0].shards=order-db-0,order-db-1
+//	sharding.tables[0].strategy=hash
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+var (
+	ErrUnknownTable = errors.New("sharding: unknown table")
+	ErrNoShardKey   = errors.New("sharding: no shard key")
+)
+
+// Keyer 自定义分片键的实体。
+type Keyer interface {
+	ShardKey() interface{}
+}
+
+// Key 返回 v 的分片键，Keyer 返回 ShardKey() 的值，结构体返回带有 shard:"key"
+// 标签的字段的值，其他类型返回 v 本身。
+func Key(v interface{}) (interface{}, error) {
+	if k, ok := v.(Keyer); ok {
+		return k.ShardKey(), nil
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, ErrNoShardKey
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		if !rv.IsValid() {
+			return nil, ErrNoShardKey
+		}
+		return rv.Interface(), nil
+	}
+	if f, ok := keyField(rv); ok {
+		return f.Interface(), nil
+	}
+	return nil, fmt.Errorf("%w in %s", ErrNoShardKey, rv.Type())
+}
+
+// keyField 查找带有 shard:"key" 标签的字段，支持嵌入的结构体。
+func keyField(rv reflect.Value) (reflect.Value, bool) {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Tag.Get("shard") == "key" {
+			return rv.Field(i), true
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if v, ok := keyField(rv.Field(i)); ok {
+				return v, true
+			}
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// Strategy 根据分片键计算分片的序号，n 为分片的数量。注册为 bean 之后可以在配置中
+// 通过名称使用。
+type Strategy interface {
+	Name() string
+	Shard(key interface{}, n int) (int, error)
+}
+
+type strategy struct {
+	name string
+	fn   func(key interface{}, n int) (int, error)
+}
+
+func (s *strategy) Name() string {
+	return s.name
+}
+
+func (s *strategy) Shard(key interface{}, n int) (int, error) {
+	return s.fn(key, n)
+}
+
+// NewStrategy 使用函数创建 Strategy 。
+func NewStrategy(name string, fn func(key interface{}, n int) (int, error)) Strategy {
+	return &strategy{name: name, fn: fn}
+}
+
+// Hash 返回使用分片键的 FNV-1a 哈希值取模的 Strategy ，名称为 hash 。
+func Hash() Strategy {
+	return NewStrategy("hash", func(key interface{}, n int) (int, error) {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(fmt.Sprint(key)))
+		return int(h.Sum32() % uint32(n)), nil
+	})
+}
+
+// Mod 返回使用整数分片键取模的 Strategy ，名称为 mod ，适用于自增 ID 等分布均匀的键。
+func Mod() Strategy {
+	return NewStrategy("mod", func(key interface{}, n int) (int, error) {
+		var i int64
+		rv := reflect.ValueOf(key)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i = rv.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return int(rv.Uint() % uint64(n)), nil
+		case reflect.String:
+			var err error
+			if i, err = strconv.ParseInt(rv.String(), 10, 64); err != nil {
+				return 0, fmt.Errorf("sharding: key %q is not an integer", rv.String())
+			}
+		default:
+			return 0, fmt.Errorf("sharding: key %v is not an integer", key)
+		}
+		if i < 0 {
+			return 0, fmt.Errorf("sharding: key %d is negative", i)
+		}
+		return int(i % int64(n)), nil
+	})
+}
+
+// TableConfig 分片表的配置。
+type TableConfig struct {
+	Name     string   `value:"${name}"`
+	Shards   []string `value:"${shards}"`         // 数据源的名称，顺序决定分片的序号
+	Strategy string   `value:"${strategy:=hash}"` // 分片策略的名称
+}
+
+// Config 分片的配置，通常配合 sharding 前缀一起使用。
+type Config struct {
+	Tables []TableConfig `value:"${tables:=}"`
+}
+
+type table struct {
+	shards   []string
+	strategy Strategy
+}
+
+// Router 根据分片键选择数据源。
+type Router struct {
+	names  []string
+	tables map[string]*table
+}
+
+// NewRouter 创建 Router ，strategies 中的策略覆盖同名的内置策略 hash 和 mod 。
+func NewRouter(config Config, strategies []Strategy) (*Router, error) {
+	m := make(map[string]Strategy)
+	for _, s := range append([]Strategy{Hash(), Mod()}, strategies...) {
+		m[s.Name()] = s
+	}
+	r := &Router{tables: make(map[string]*table)}
+	for _, c := range config.Tables {
+		if _, ok := r.tables[c.Name]; ok {
+			return nil, fmt.Errorf("sharding: duplicate table %s", c.Name)
+		}
+		if len(c.Shards) == 0 {
+			return nil, fmt.Errorf("sharding: table %s has no shards", c.Name)
+		}
+		s, ok := m[c.Strategy]
+		if !ok {
+			return nil, fmt.Errorf("sharding: unknown strategy %s for table %s", c.Strategy, c.Name)
+		}
+		r.names = append(r.names, c.Name)
+		r.tables[c.Name] = &table{shards: c.Shards, strategy: s}
+	}
+	return r, nil
+}
+
+// Tables 返回所有分片表的名称。
+func (r *Router) Tables() []string {
+	return r.names
+}
+
+// Shards 返回分片表的所有数据源。
+func (r *Router) Shards(name string) ([]string, error) {
+	t, ok := r.tables[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownTable, name)
+	}
+	return t.shards, nil
+}
+
+// Route 返回 v 所在的数据源，v 可以是实体也可以是分片键，参见 Key 。
+func (r *Router) Route(name string, v interface{}) (string, error) {
+	t, ok := r.tables[name]
+	if !ok {
+		return "", fmt.Errorf("%w %s", ErrUnknownTable, name)
+	}
+	key, err := Key(v)
+	if err != nil {
+		return "", err
+	}
+	i, err := t.strategy.Shard(key, len(t.shards))
+	if err != nil {
+		return "", err
+	}
+	if i < 0 || i >= len(t.shards) {
+		return "", fmt.Errorf("sharding: strategy %s returns invalid shard %d", t.strategy.Name(), i)
+	}
+	return t.shards[i], nil
+}
+
+// Gather 在所有的分片上并发执行 fn ，返回按照分片顺序排列的结果。任何一个分片返回
+// 错误时取消其他分片的执行并返回第一个错误。
+func Gather(ctx context.Context, shards []string, fn func(ctx context.Context, shard string) (interface{}, error)) ([]interface{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg     sync.WaitGroup
+		once   sync.Once
+		result = make([]interface{}, len(shards))
+		err    error
+	)
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(i int, shard string) {
+			defer wg.Done()
+			v, e := fn(ctx, shard)
+			if e != nil {
+				once.Do(func() {
+					err = fmt.Errorf("sharding: shard %s: %w", shard, e)
+					cancel()
+				})
+				return
+			}
+			result[i] = v
+		}(i, shard)
+	}
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sharding_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/sharding"
+)
+
+type Base struct {
+	UserID int64 `shard:"key"`
+}
+
+type Order struct {
+	Base
+	ID int64
+}
+
+type Account struct {
+	Region string
+}
+
+func (a *Account) ShardKey() interface{} {
+	return a.Region
+}
+
+func TestKey(t *testing.T) {
+
+	key, err := sharding.Key(&Order{Base: Base{UserID: 7}})
+	assert.Nil(t, err)
+	assert.Equal(t, key, int64(7))
+
+	key, err = sharding.Key(&Account{Region: "eu"})
+	assert.Nil(t, err)
+	assert.Equal(t, key, "eu")
+
+	key, err = sharding.Key("abc")
+	assert.Nil(t, err)
+	assert.Equal(t, key, "abc")
+
+	_, err = sharding.Key(struct{ ID int }{})
+	assert.True(t, errors.Is(err, sharding.ErrNoShardKey))
+
+	_, err = sharding.Key((*Order)(nil))
+	assert.True(t, errors.Is(err, sharding.ErrNoShardKey))
+}
+
+func TestStrategy(t *testing.T) {
+
+	i, err := sharding.Mod().Shard(int64(7), 4)
+	assert.Nil(t, err)
+	assert.Equal(t, i, 3)
+
+	i, err = sharding.Mod().Shard("10", 4)
+	assert.Nil(t, err)
+	assert.Equal(t, i, 2)
+
+	_, err = sharding.Mod().Shard(-1, 4)
+	assert.Error(t, err, "key -1 is negative")
+
+	_, err = sharding.Mod().Shard("a", 4)
+	assert.Error(t, err, "is not an integer")
+
+	a, err := sharding.Hash().Shard("user-1", 8)
+	assert.Nil(t, err)
+	b, err := sharding.Hash().Shard("user-1", 8)
+	assert.Nil(t, err)
+	assert.Equal(t, a, b)
+	assert.True(t, a >= 0 && a < 8)
+}
+
+func TestRouter(t *testing.T) {
+
+	region := sharding.NewStrategy("region", func(key interface{}, n int) (int, error) {
+		if key == "eu" {
+			return 1, nil
+		}
+		return 0, nil
+	})
+	config := sharding.Config{Tables: []sharding.TableConfig{
+		{Name: "orders", Shards: []string{"db0", "db1", "db2"}, Strategy: "mod"},
+		{Name: "accounts", Shards: []string{"us", "eu"}, Strategy: "region"},
+	}}
+	r, err := sharding.NewRouter(config, []sharding.Strategy{region})
+	assert.Nil(t, err)
+	assert.Equal(t, r.Tables(), []string{"orders", "accounts"})
+
+	shard, err := r.Route("orders", &Order{Base: Base{UserID: 4}})
+	assert.Nil(t, err)
+	assert.Equal(t, shard, "db1")
+
+	shard, err = r.Route("orders", 5)
+	assert.Nil(t, err)
+	assert.Equal(t, shard, "db2")
+
+	shard, err = r.Route("accounts", &Account{Region: "eu"})
+	assert.Nil(t, err)
+	assert.Equal(t, shard, "eu")
+
+	_, err = r.Route("users", 1)
+	assert.True(t, errors.Is(err, sharding.ErrUnknownTable))
+
+	shards, err := r.Shards("accounts")
+	assert.Nil(t, err)
+	assert.Equal(t, shards, []string{"us", "eu"})
+
+	config.Tables[1].Strategy = "range"
+	_, err = sharding.NewRouter(config, nil)
+	assert.Error(t, err, "unknown strategy range for table accounts")
+
+	config.Tables[1] = sharding.TableConfig{Name: "orders", Shards: []string{"db0"}, Strategy: "hash"}
+	_, err = sharding.NewRouter(config, nil)
+	assert.Error(t, err, "duplicate table orders")
+}
+
+func TestGather(t *testing.T) {
+
+	result, err := sharding.Gather(context.Background(), []string{"a", "b", "c"}, func(ctx context.Context, shard string) (interface{}, error) {
+		return shard + shard, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, result, []interface{}{"aa", "bb", "cc"})
+
+	_, err = sharding.Gather(context.Background(), []string{"a", "b"}, func(ctx context.Context, shard string) (interface{}, error) {
+		if shard == "b" {
+			return nil, errors.New("timeout")
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	assert.Error(t, err, "shard b: timeout")
+}
@@ -0,0 +1,238 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tenant
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-spring/spring-core/conf"
+)
+
+// Source 租户配置源，返回租户需要覆盖的属性，租户没有配置时返回 nil 。
+type Source interface {
+	Load(tenant string) (*conf.Properties, error)
+}
+
+// dirSource 从目录中加载租户配置文件的 Source 实现。
+type dirSource struct {
+	dir string
+}
+
+// DirSource 返回从 dir 目录加载 {tenant}.properties、{tenant}.yaml 等租户配置
+// 文件的 Source，存在多个文件时按照 properties、yaml、yml、toml 的顺序依次覆盖。
+func DirSource(dir string) Source {
+	return &dirSource{dir: dir}
+}
+
+func (s *dirSource) Load(tenant string) (*conf.Properties, error) {
+	if !Valid(tenant) {
+		return nil, ErrInvalidTenant
+	}
+	var p *conf.Properties
+	for _, ext := range []string{".properties", ".yaml", ".yml", ".toml"} {
+		file := filepath.Join(s.dir, tenant+ext)
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			continue
+		}
+		if p == nil {
+			p = conf.New()
+		}
+		if err := p.Load(file); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Base 租户属性的基础属性，通常是 gs.Context 对象。
+type Base interface {
+	Keys() []string
+	Prop(key string, opts ...conf.GetOption) string
+}
+
+// Manager 管理每个租户的属性，租户的属性由基础属性和租户配置源的属性叠加而成，
+// 租户配置源的属性优先。只有配置源中存在的租户才会单独缓存，其他租户共享基础属性，
+// 因此伪造的租户标识不会让缓存无限增长。
+type Manager struct {
+	base     Base
+	source   Source
+	mutex    sync.Mutex
+	props    map[string]*conf.Properties
+	defaults *conf.Properties // 没有租户配置的租户共享的属性
+}
+
+// NewManager 创建租户管理器。
+func NewManager(base Base, source Source) *Manager {
+	return &Manager{
+		base:   base,
+		source: source,
+		props:  make(map[string]*conf.Properties),
+	}
+}
+
+// Properties 返回租户的属性，结果会被缓存直到调用 Evict 方法。租户标识不合法时
+// 返回 ErrInvalidTenant 。
+func (m *Manager) Properties(tenant string) (*conf.Properties, error) {
+
+	if !Valid(tenant) {
+		return nil, ErrInvalidTenant
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if p, ok := m.props[tenant]; ok {
+		return p, nil
+	}
+
+	overlay, err := m.source.Load(tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	if overlay == nil && m.defaults != nil {
+		return m.defaults, nil
+	}
+
+	p := conf.New()
+	for _, k := range m.base.Keys() {
+		if err = p.Set(k, m.base.Prop(k)); err != nil {
+			return nil, err
+		}
+	}
+
+	if overlay == nil {
+		m.defaults = p
+		return p, nil
+	}
+
+	for _, k := range overlay.Keys() {
+		if err = p.Set(k, overlay.Get(k)); err != nil {
+			return nil, err
+		}
+	}
+	m.props[tenant] = p
+	return p, nil
+}
+
+// Evict 清除租户的属性缓存，下次访问时重新加载。共享的基础属性也会被清除，以便
+// 重新加载新增的租户配置。
+func (m *Manager) Evict(tenant string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.props, tenant)
+	m.defaults = nil
+}
+
+// Factory 使用租户的属性创建租户范围的 bean 。
+type Factory func(tenant string, p *conf.Properties) (interface{}, error)
+
+// DefaultCapacity 租户范围的 bean 默认最多缓存的实例数量。
+const DefaultCapacity = 1024
+
+// Scoped 租户范围的 bean，例如每个租户独立的数据源、带租户前缀的缓存等，每个租户
+// 在第一次使用时创建自己的实例。缓存的实例数量超过容量时淘汰最久未使用的实例，被淘汰
+// 的实例实现了 io.Closer 时会被关闭。
+type Scoped struct {
+	manager  *Manager
+	factory  Factory
+	capacity int
+	mutex    sync.Mutex
+	lru      list.List // *scopedBean ，最近使用的在前面
+	beans    map[string]*list.Element
+}
+
+type scopedBean struct {
+	tenant string
+	bean   interface{}
+}
+
+// NewScoped 创建租户范围的 bean ，最多缓存 DefaultCapacity 个租户的实例。
+func NewScoped(m *Manager, factory Factory) *Scoped {
+	return NewScopedWithCapacity(m, factory, DefaultCapacity)
+}
+
+// NewScopedWithCapacity 创建最多缓存 capacity 个租户的实例的租户范围的 bean 。
+func NewScopedWithCapacity(m *Manager, factory Factory, capacity int) *Scoped {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &Scoped{
+		manager:  m,
+		factory:  factory,
+		capacity: capacity,
+		beans:    make(map[string]*list.Element),
+	}
+}
+
+// Get 返回上下文中的租户对应的实例。
+func (s *Scoped) Get(ctx context.Context) (interface{}, error) {
+	t, ok := Get(ctx)
+	if !ok {
+		return nil, ErrNoTenant
+	}
+	return s.For(t)
+}
+
+// For 返回租户对应的实例，不存在时使用租户的属性创建。
+func (s *Scoped) For(tenant string) (interface{}, error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if e, ok := s.beans[tenant]; ok {
+		s.lru.MoveToFront(e)
+		return e.Value.(*scopedBean).bean, nil
+	}
+
+	p, err := s.manager.Properties(tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := s.factory(tenant, p)
+	if err != nil {
+		return nil, err
+	}
+	s.beans[tenant] = s.lru.PushFront(&scopedBean{tenant: tenant, bean: b})
+	for s.lru.Len() > s.capacity {
+		s.remove(s.lru.Back())
+	}
+	return b, nil
+}
+
+// Evict 删除租户对应的实例，下次访问时重新创建。
+func (s *Scoped) Evict(tenant string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if e, ok := s.beans[tenant]; ok {
+		s.remove(e)
+	}
+}
+
+func (s *Scoped) remove(e *list.Element) {
+	b := s.lru.Remove(e).(*scopedBean)
+	delete(s.beans, b.tenant)
+	if c, ok := b.bean.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package tenant 提供了多租户支持，包括从请求中解析租户的 Resolver、将租户保存到
+// 请求上下文的过滤器、按租户覆盖的属性以及租户范围的 bean 。
+package tenant
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-spring/spring-base/knife"
+	"github.com/go-spring/spring-core/web"
+)
+
+// ctxKey 租户在请求上下文中的 key 。
+const ctxKey = "::tenant::"
+
+// ErrNoTenant 上下文中没有租户信息。
+var ErrNoTenant = errors.New("no tenant in context")
+
+// ErrInvalidTenant 租户标识不合法。
+var ErrInvalidTenant = errors.New("invalid tenant")
+
+// validTenant 合法的租户标识，租户标识来自请求头或者 JWT ，会被用于拼接配置文件
+// 路径等场景，因此只允许字母、数字、下划线和中划线。
+var validTenant = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Valid 返回租户标识是否合法。
+func Valid(tenant string) bool {
+	return validTenant.MatchString(tenant)
+}
+
+// Resolver 从请求中解析租户，没有租户信息时返回空字符串。
+type Resolver interface {
+	Resolve(ctx web.Context) (string, error)
+}
+
+// ResolverFunc 函数形式的 Resolver 实现。
+type ResolverFunc func(ctx web.Context) (string, error)
+
+func (f ResolverFunc) Resolve(ctx web.Context) (string, error) {
+	return f(ctx)
+}
+
+// HeaderResolver 返回从请求头中解析租户的 Resolver 。
+func HeaderResolver(header string) Resolver {
+	return ResolverFunc(func(ctx web.Context) (string, error) {
+		return ctx.Header(header), nil
+	})
+}
+
+// JWTResolver 返回从 Authorization 请求头携带的 JWT 中解析租户的 Resolver，
+// claim 是保存租户的字段名。注意，该 Resolver 不校验 JWT 的签名，必须放在完成
+// 认证的过滤器之后使用。
+func JWTResolver(claim string) Resolver {
+	return ResolverFunc(func(ctx web.Context) (string, error) {
+		auth := ctx.Header(web.HeaderAuthorization)
+		if !strings.HasPrefix(auth, "Bearer ") {
+			return "", nil
+		}
+		ss := strings.Split(auth[len("Bearer "):], ".")
+		if len(ss) != 3 {
+			return "", errors.New("invalid jwt token")
+		}
+		b, err := base64.RawURLEncoding.DecodeString(ss[1])
+		if err != nil {
+			return "", err
+		}
+		var claims map[string]interface{}
+		if err = json.Unmarshal(b, &claims); err != nil {
+			return "", err
+		}
+		if v, ok := claims[claim]; ok {
+			return fmt.Sprint(v), nil
+		}
+		return "", nil
+	})
+}
+
+// FilterConfig 租户过滤器的配置。
+type FilterConfig struct {
+	Resolver Resolver // 从请求中解析租户
+	Required bool     // 没有租户信息时是否拒绝请求
+}
+
+// NewFilterConfig 返回从 X-Tenant-ID 请求头解析租户的默认配置。
+func NewFilterConfig() FilterConfig {
+	return FilterConfig{Resolver: HeaderResolver("X-Tenant-ID")}
+}
+
+// NewFilter 创建解析租户并将其保存到请求上下文的过滤器，租户标识不合法时返回 400 。
+func NewFilter(config FilterConfig) web.Filter {
+	return web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		t, err := config.Resolver.Resolve(ctx)
+		if err != nil {
+			ctx.SetStatus(http.StatusBadRequest)
+			ctx.String(err.Error())
+			return
+		}
+		if t == "" {
+			if config.Required {
+				ctx.SetStatus(http.StatusBadRequest)
+				ctx.String(ErrNoTenant.Error())
+				return
+			}
+			chain.Continue(ctx)
+			return
+		}
+		if !Valid(t) {
+			ctx.SetStatus(http.StatusBadRequest)
+			ctx.String(ErrInvalidTenant.Error())
+			return
+		}
+		if err = ctx.Set(ctxKey, t); err != nil {
+			panic(err)
+		}
+		chain.Continue(ctx)
+	})
+}
+
+// Set 将租户保存到上下文中，ctx 必须是 knife 上下文。
+func Set(ctx context.Context, tenant string) error {
+	return knife.Store(ctx, ctxKey, tenant)
+}
+
+// Get 返回上下文中保存的租户。
+func Get(ctx context.Context) (string, bool) {
+	v, err := knife.Load(ctx, ctxKey)
+	if err != nil || v == nil {
+		return "", false
+	}
+	return v.(string), true
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tenant_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/tenant"
+	"github.com/go-spring/spring-core/web"
+)
+
+func newContext(header, value string) (web.Context, *httptest.ResponseRecorder) {
+	r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:8080/", nil)
+	if header != "" {
+		r.Header.Set(header, value)
+	}
+	w := httptest.NewRecorder()
+	return web.NewBaseContext("", nil, r, &web.BufferedResponseWriter{ResponseWriter: w}), w
+}
+
+func TestFilter(t *testing.T) {
+
+	t.Run("header", func(t *testing.T) {
+		var got string
+		ctx, _ := newContext("X-Tenant-ID", "acme")
+		f := tenant.NewFilter(tenant.NewFilterConfig())
+		web.NewFilterChain([]web.Filter{f, web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+			got, _ = tenant.Get(ctx.Context())
+		})}).Next(ctx)
+		assert.Equal(t, got, "acme")
+	})
+
+	t.Run("jwt", func(t *testing.T) {
+		payload := base64.RawURLEncoding.EncodeToString([]byte(`{"tid":"acme"}`))
+		ctx, _ := newContext(web.HeaderAuthorization, "Bearer e30."+payload+".sig")
+		t2, err := tenant.JWTResolver("tid").Resolve(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, t2, "acme")
+	})
+
+	t.Run("required", func(t *testing.T) {
+		ctx, w := newContext("", "")
+		config := tenant.NewFilterConfig()
+		config.Required = true
+		f := tenant.NewFilter(config)
+		web.NewFilterChain([]web.Filter{f}).Next(ctx)
+		assert.Equal(t, w.Code, http.StatusBadRequest)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		ctx, w := newContext("X-Tenant-ID", "../testdata/acme")
+		f := tenant.NewFilter(tenant.NewFilterConfig())
+		web.NewFilterChain([]web.Filter{f}).Next(ctx)
+		assert.Equal(t, w.Code, http.StatusBadRequest)
+		assert.Equal(t, w.Body.String(), "invalid tenant")
+	})
+}
+
+type base map[string]string
+
+func (b base) Keys() []string {
+	var keys []string
+	for k := range b {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (b base) Prop(key string, opts ...conf.GetOption) string {
+	return b[key]
+}
+
+func TestScoped(t *testing.T) {
+
+	m := tenant.NewManager(base{"db.url": "mysql://default"}, tenant.DirSource("testdata"))
+
+	count := 0
+	s := tenant.NewScoped(m, func(tenant string, p *conf.Properties) (interface{}, error) {
+		count++
+		return p.Get("db.url"), nil
+	})
+
+	b, err := s.For("acme")
+	assert.Nil(t, err)
+	assert.Equal(t, b, "mysql://acme")
+
+	b, err = s.For("other")
+	assert.Nil(t, err)
+	assert.Equal(t, b, "mysql://default")
+
+	_, _ = s.For("acme")
+	assert.Equal(t, count, 2)
+
+	ctx, _ := newContext("", "")
+	_, err = s.Get(ctx.Context())
+	assert.Error(t, err, "no tenant in context")
+
+	err = tenant.Set(ctx.Context(), "acme")
+	assert.Nil(t, err)
+	b, err = s.Get(ctx.Context())
+	assert.Nil(t, err)
+	assert.Equal(t, b, "mysql://acme")
+
+	_, err = s.For("../tenant/testdata/acme")
+	assert.Equal(t, err, tenant.ErrInvalidTenant)
+	_, err = tenant.DirSource("testdata").Load("../testdata/acme")
+	assert.Equal(t, err, tenant.ErrInvalidTenant)
+}
+
+type closer struct {
+	closed bool
+}
+
+func (c *closer) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestScoped_Capacity(t *testing.T) {
+
+	m := tenant.NewManager(base{"db.url": "mysql://default"}, tenant.DirSource("testdata"))
+
+	// 没有配置的租户共享基础属性。
+	p1, err := m.Properties("t1")
+	assert.Nil(t, err)
+	p2, err := m.Properties("t2")
+	assert.Nil(t, err)
+	assert.True(t, p1 == p2)
+
+	s := tenant.NewScopedWithCapacity(m, func(tenant string, p *conf.Properties) (interface{}, error) {
+		return &closer{}, nil
+	}, 2)
+
+	b1, _ := s.For("t1")
+	b2, _ := s.For("t2")
+	_, _ = s.For("t1")
+	_, _ = s.For("t3")
+	assert.False(t, b1.(*closer).closed)
+	assert.True(t, b2.(*closer).closed)
+
+	b, _ := s.For("t2")
+	assert.True(t, b != b2)
+}
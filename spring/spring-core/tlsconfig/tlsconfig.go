@@ -0,0 +1,334 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package tlsconfig 提供了 web 服务器、gRPC 以及 http 客户端共用的 TLS 配置，证书
+// 可以来自文件、SPIFFE 或者 Vault 等 Source ，轮换之后自动重新加载，只需要配置一次
+// 就可以在所有组件上开启 mTLS 。
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+)
+
+var logger = log.GetLogger("GS_TLS")
+
+// 内置的证书来源。
+const (
+	SourceFile   = "file"
+	SourceSpiffe = "spiffe"
+)
+
+// Config TLS 配置，通常配合 tls 前缀一起使用。
+type Config struct {
+	Source         string        `value:"${source:=file}"`         // file 、spiffe 或者其他 Source 的名称
+	CertFile       string        `value:"${cert-file:=}"`          // 证书文件，可以包含中间证书
+	KeyFile        string        `value:"${key-file:=}"`           // 私钥文件
+	CAFile         string        `value:"${ca-file:=}"`            // 校验对端证书的 CA ，为空时使用系统 CA
+	SpiffeDir      string        `value:"${spiffe.dir:=}"`         // spiffe-helper 写入 SVID 的目录
+	SpiffeIDs      []string      `value:"${spiffe.allowed-ids:=}"` // 允许的对端 SPIFFE ID ，为空时不限制
+	ClientAuth     string        `value:"${client-auth:=none}"`    // none 、request 或者 require ，require 即 mTLS
+	MinVersion     string        `value:"${min-version:=1.2}"`     // 1.0 、1.1 、1.2 或者 1.3
+	CipherSuites   []string      `value:"${cipher-suites:=}"`      // 为空时使用 Go 的默认值，对 TLS 1.3 无效
+	ReloadInterval time.Duration `value:"${reload-interval:=1m}"`  // 重新加载证书的间隔，0 表示不重新加载
+}
+
+// Bundle 证书以及校验对端证书的 CA 。
+type Bundle struct {
+	Certificate *tls.Certificate
+	Roots       *x509.CertPool // 为 nil 时使用系统 CA
+}
+
+// Source 证书来源，Load 返回当前有效的证书，证书轮换之后应当返回新的证书。
+type Source interface {
+	Name() string
+	Load(ctx context.Context) (*Bundle, error)
+}
+
+// fileSource 从 PEM 文件加载证书。
+type fileSource struct {
+	name     string
+	certFile string
+	keyFile  string
+	caFile   string
+}
+
+// FileSource 返回从 PEM 文件加载证书的 Source ，caFile 为空时使用系统 CA 。
+func FileSource(certFile, keyFile, caFile string) Source {
+	return &fileSource{name: SourceFile, certFile: certFile, keyFile: keyFile, caFile: caFile}
+}
+
+// SpiffeSource 返回从 spiffe-helper 写入的 svid.pem 、svid_key.pem 和
+// svid_bundle.pem 文件加载 X.509 SVID 的 Source 。
+func SpiffeSource(dir string) Source {
+	return &fileSource{
+		name:     SourceSpiffe,
+		certFile: filepath.Join(dir, "svid.pem"),
+		keyFile:  filepath.Join(dir, "svid_key.pem"),
+		caFile:   filepath.Join(dir, "svid_bundle.pem"),
+	}
+}
+
+func (s *fileSource) Name() string {
+	return s.name
+}
+
+func (s *fileSource) Load(ctx context.Context) (*Bundle, error) {
+	cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{Certificate: &cert}
+	if s.caFile != "" {
+		pem, err := ioutil.ReadFile(s.caFile)
+		if err != nil {
+			return nil, err
+		}
+		if b.Roots, err = NewCertPool(pem); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.caFile, err)
+		}
+	}
+	return b, nil
+}
+
+// NewCertPool 使用 PEM 格式的证书创建 CA 证书池。
+func NewCertPool(pem []byte) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no certificate found")
+	}
+	return pool, nil
+}
+
+var versions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":    tls.NoClientCert,
+	"request": tls.VerifyClientCertIfGiven,
+	"require": tls.RequireAndVerifyClientCert,
+}
+
+// Provider 根据配置创建 tls.Config 对象，握手时使用最新加载的证书，因此证书轮换
+// 之后不需要重启服务器，也不需要重新创建客户端。
+type Provider struct {
+	config     Config
+	source     Source
+	minVersion uint16
+	ciphers    []uint16
+	clientAuth tls.ClientAuthType
+	spiffeIDs  map[string]bool
+
+	mutex     sync.RWMutex
+	bundle    *Bundle
+	loadedAt  time.Time
+	reloading int32
+}
+
+// NewProvider 创建 Provider 并加载证书，sources 是 file 和 spiffe 之外的其他证书来源，
+// 通过 Config.Source 按照名称选择。
+func NewProvider(config Config, sources []Source) (*Provider, error) {
+
+	p := &Provider{config: config, spiffeIDs: make(map[string]bool)}
+
+	var ok bool
+	if p.minVersion, ok = versions[config.MinVersion]; !ok {
+		return nil, fmt.Errorf("tls: unknown min-version %q", config.MinVersion)
+	}
+	if p.clientAuth, ok = clientAuthTypes[config.ClientAuth]; !ok {
+		return nil, fmt.Errorf("tls: unknown client-auth %q", config.ClientAuth)
+	}
+	if len(config.CipherSuites) > 0 {
+		suites := make(map[string]uint16)
+		for _, s := range tls.CipherSuites() {
+			suites[s.Name] = s.ID
+		}
+		for _, name := range config.CipherSuites {
+			id, ok := suites[name]
+			if !ok {
+				return nil, fmt.Errorf("tls: unknown or insecure cipher suite %q", name)
+			}
+			p.ciphers = append(p.ciphers, id)
+		}
+	}
+	for _, id := range config.SpiffeIDs {
+		p.spiffeIDs[id] = true
+	}
+
+	switch config.Source {
+	case SourceFile:
+		p.source = FileSource(config.CertFile, config.KeyFile, config.CAFile)
+	case SourceSpiffe:
+		p.source = SpiffeSource(config.SpiffeDir)
+	default:
+		for _, s := range sources {
+			if s.Name() == config.Source {
+				p.source = s
+				break
+			}
+		}
+		if p.source == nil {
+			return nil, fmt.Errorf("tls: source %q not found", config.Source)
+		}
+	}
+
+	if err := p.Reload(context.Background()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload 立即重新加载证书。
+func (p *Provider) Reload(ctx context.Context) error {
+	b, err := p.source.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("tls: load certificate from %s source: %w", p.source.Name(), err)
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.bundle = b
+	p.loadedAt = time.Now()
+	return nil
+}
+
+// current 返回当前的证书，超过重新加载的间隔时在后台重新加载，加载失败时继续
+// 使用原来的证书。
+func (p *Provider) current() *Bundle {
+	p.mutex.RLock()
+	b, loadedAt := p.bundle, p.loadedAt
+	p.mutex.RUnlock()
+	d := p.config.ReloadInterval
+	if d > 0 && time.Since(loadedAt) > d && atomic.CompareAndSwapInt32(&p.reloading, 0, 1) {
+		go func() {
+			defer atomic.StoreInt32(&p.reloading, 0)
+			if err := p.Reload(context.Background()); err != nil {
+				logger.Warn(err)
+			}
+		}()
+	}
+	return b
+}
+
+func (p *Provider) baseConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:   p.minVersion,
+		CipherSuites: p.ciphers,
+	}
+}
+
+// ServerConfig 返回服务器使用的 tls.Config 对象。
+func (p *Provider) ServerConfig() *tls.Config {
+	c := p.baseConfig()
+	c.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return p.current().Certificate, nil
+	}
+	c.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		b := p.current()
+		cc := p.baseConfig()
+		cc.Certificates = []tls.Certificate{*b.Certificate}
+		cc.ClientAuth = p.clientAuth
+		cc.ClientCAs = b.Roots
+		cc.VerifyPeerCertificate = p.verifySpiffeID
+		return cc, nil
+	}
+	return c
+}
+
+// ClientConfig 返回客户端使用的 tls.Config 对象，客户端证书在握手时获取，CA 在调用
+// 时获取。配置了 SPIFFE ID 时按照 SPIFFE 的规则校验服务端证书，即不校验主机名而是
+// 校验 SPIFFE ID ，并且在握手时获取 CA 。
+func (p *Provider) ClientConfig() *tls.Config {
+	c := p.baseConfig()
+	c.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return p.current().Certificate, nil
+	}
+	if len(p.spiffeIDs) == 0 {
+		c.RootCAs = p.current().Roots
+		return c
+	}
+	// 由 VerifyPeerCertificate 使用最新的 CA 校验证书链。
+	c.InsecureSkipVerify = true
+	c.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if err := p.verifyChain(rawCerts); err != nil {
+			return err
+		}
+		return p.verifySpiffeID(rawCerts, nil)
+	}
+	return c
+}
+
+// Transport 返回使用客户端 TLS 配置的 http.Transport 对象。
+func (p *Provider) Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = p.ClientConfig()
+	return t
+}
+
+func (p *Provider) verifyChain(rawCerts [][]byte) error {
+	var certs []*x509.Certificate
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return errors.New("tls: no peer certificate")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         p.current().Roots,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
+
+// verifySpiffeID 校验对端证书的 SPIFFE ID 是否被允许，没有配置时不校验。
+func (p *Provider) verifySpiffeID(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(p.spiffeIDs) == 0 || len(rawCerts) == 0 {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return err
+	}
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" && p.spiffeIDs[uri.String()] {
+			return nil
+		}
+	}
+	return errors.New("tls: spiffe id of peer is not allowed")
+}
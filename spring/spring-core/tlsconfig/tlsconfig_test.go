@@ -0,0 +1,282 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tlsconfig_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/tlsconfig"
+)
+
+type issuer struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+var serial int64
+
+func newIssuer(t *testing.T) *issuer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	serial++
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	return &issuer{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue 签发证书，并将证书、私钥和 CA 写入 dir 目录下的 svid.pem 、svid_key.pem
+// 和 svid_bundle.pem 文件。
+func (ca *issuer) issue(t *testing.T, dir string, spiffeID string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	serial++
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if spiffeID != "" {
+		u, err := url.Parse(spiffeID)
+		assert.Nil(t, err)
+		tmpl.URIs = []*url.URL{u}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	assert.Nil(t, err)
+	b, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	write(t, filepath.Join(dir, "svid.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	write(t, filepath.Join(dir, "svid_key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b}))
+	write(t, filepath.Join(dir, "svid_bundle.pem"), ca.pem)
+}
+
+func write(t *testing.T, file string, b []byte) {
+	assert.Nil(t, ioutil.WriteFile(file, b, 0600))
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "tls")
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}
+
+func fileConfig(dir string) tlsconfig.Config {
+	return tlsconfig.Config{
+		Source:     tlsconfig.SourceFile,
+		CertFile:   filepath.Join(dir, "svid.pem"),
+		KeyFile:    filepath.Join(dir, "svid_key.pem"),
+		CAFile:     filepath.Join(dir, "svid_bundle.pem"),
+		ClientAuth: "require",
+		MinVersion: "1.2",
+	}
+}
+
+func newProvider(t *testing.T, config tlsconfig.Config) *tlsconfig.Provider {
+	p, err := tlsconfig.NewProvider(config, nil)
+	assert.Nil(t, err)
+	return p
+}
+
+// serve 启动使用 p 的 https 服务器。
+func serve(t *testing.T, p *tlsconfig.Provider) *httptest.Server {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	s.TLS = p.ServerConfig()
+	s.StartTLS()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// get 使用 p 访问服务器，返回服务器证书的序列号。
+func get(p *tlsconfig.Provider, url string) (int64, error) {
+	t := p.Transport()
+	defer t.CloseIdleConnections()
+	resp, err := (&http.Client{Transport: t}).Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return resp.TLS.PeerCertificates[0].SerialNumber.Int64(), nil
+}
+
+func TestProvider(t *testing.T) {
+
+	ca := newIssuer(t)
+	serverDir, clientDir := tempDir(t), tempDir(t)
+	ca.issue(t, serverDir, "")
+	ca.issue(t, clientDir, "")
+
+	server := newProvider(t, fileConfig(serverDir))
+	s := serve(t, server)
+
+	// mTLS
+	first, err := get(newProvider(t, fileConfig(clientDir)), s.URL)
+	assert.Nil(t, err)
+
+	// 没有客户端证书
+	config := fileConfig(clientDir)
+	config.CertFile = ""
+	_, err = tlsconfig.NewProvider(config, nil)
+	assert.Error(t, err, "tls: load certificate from file source: open : no such file or directory")
+
+	// 其他 CA 签发的客户端证书
+	otherDir := tempDir(t)
+	newIssuer(t).issue(t, otherDir, "")
+	other := fileConfig(otherDir)
+	other.CAFile = filepath.Join(serverDir, "svid_bundle.pem")
+	_, err = get(newProvider(t, other), s.URL)
+	assert.NotNil(t, err)
+
+	// 证书轮换之后不需要重启服务器
+	ca.issue(t, serverDir, "")
+	assert.Nil(t, server.Reload(context.Background()))
+	second, err := get(newProvider(t, fileConfig(clientDir)), s.URL)
+	assert.Nil(t, err)
+	assert.True(t, second != first)
+}
+
+func TestProvider_Reload(t *testing.T) {
+
+	ca := newIssuer(t)
+	serverDir, clientDir := tempDir(t), tempDir(t)
+	ca.issue(t, serverDir, "")
+	ca.issue(t, clientDir, "")
+
+	config := fileConfig(serverDir)
+	config.ReloadInterval = time.Millisecond
+	s := serve(t, newProvider(t, config))
+	client := newProvider(t, fileConfig(clientDir))
+	first, err := get(client, s.URL)
+	assert.Nil(t, err)
+
+	ca.issue(t, serverDir, "")
+	time.Sleep(5 * time.Millisecond)
+	for i := 0; i < 100; i++ {
+		n, err := get(client, s.URL)
+		assert.Nil(t, err)
+		if n != first {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("certificate is not reloaded")
+}
+
+func TestProvider_Spiffe(t *testing.T) {
+
+	ca := newIssuer(t)
+	serverDir, clientDir := tempDir(t), tempDir(t)
+	ca.issue(t, serverDir, "spiffe://example.org/user")
+	ca.issue(t, clientDir, "spiffe://example.org/order")
+
+	s := serve(t, newProvider(t, tlsconfig.Config{
+		Source:     tlsconfig.SourceSpiffe,
+		SpiffeDir:  serverDir,
+		SpiffeIDs:  []string{"spiffe://example.org/order"},
+		ClientAuth: "require",
+		MinVersion: "1.3",
+	}))
+
+	client := func(allowed string) *tlsconfig.Provider {
+		return newProvider(t, tlsconfig.Config{
+			Source:     tlsconfig.SourceSpiffe,
+			SpiffeDir:  clientDir,
+			SpiffeIDs:  []string{allowed},
+			ClientAuth: "none",
+			MinVersion: "1.2",
+		})
+	}
+	_, err := get(client("spiffe://example.org/user"), s.URL)
+	assert.Nil(t, err)
+	_, err = get(client("spiffe://example.org/payment"), s.URL)
+	assert.Error(t, err, "tls: spiffe id of peer is not allowed")
+
+	// 服务器不允许的调用方
+	ca.issue(t, clientDir, "spiffe://example.org/payment")
+	_, err = get(client("spiffe://example.org/user"), s.URL)
+	assert.NotNil(t, err)
+}
+
+type source struct{ bundle *tlsconfig.Bundle }
+
+func (s *source) Name() string { return "memory" }
+
+func (s *source) Load(ctx context.Context) (*tlsconfig.Bundle, error) { return s.bundle, nil }
+
+func TestNewProvider(t *testing.T) {
+
+	dir := tempDir(t)
+	newIssuer(t).issue(t, dir, "")
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, "svid.pem"), filepath.Join(dir, "svid_key.pem"))
+	assert.Nil(t, err)
+
+	config := fileConfig(dir)
+	config.Source = "memory"
+	_, err = tlsconfig.NewProvider(config, nil)
+	assert.Error(t, err, `tls: source "memory" not found`)
+	p, err := tlsconfig.NewProvider(config, []tlsconfig.Source{&source{&tlsconfig.Bundle{Certificate: &cert}}})
+	assert.Nil(t, err)
+	assert.Equal(t, p.ServerConfig().MinVersion, uint16(tls.VersionTLS12))
+
+	config = fileConfig(dir)
+	config.MinVersion = "1.4"
+	_, err = tlsconfig.NewProvider(config, nil)
+	assert.Error(t, err, `tls: unknown min-version "1.4"`)
+
+	config = fileConfig(dir)
+	config.ClientAuth = "optional"
+	_, err = tlsconfig.NewProvider(config, nil)
+	assert.Error(t, err, `tls: unknown client-auth "optional"`)
+
+	config = fileConfig(dir)
+	config.CipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_RSA_WITH_RC4_128_SHA"}
+	_, err = tlsconfig.NewProvider(config, nil)
+	assert.Error(t, err, `tls: unknown or insecure cipher suite "TLS_RSA_WITH_RC4_128_SHA"`)
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package waitfor 在启动时等待依赖的服务就绪，避免容器先于数据库等服务启动时应用
+// 启动失败并且反复重启。
+package waitfor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/internal"
+)
+
+var logger = log.GetLogger("GS_WAITFOR")
+
+// Config 等待的配置，各个客户端的配置中都包含该配置，例如 redis.wait-for.max-wait=30s 。
+type Config = internal.WaitForConfig
+
+// Wait 反复执行 fn 直到成功，两次执行之间的等待时间按照倍数增长。超过 MaxWait 之后
+// 返回最后一次的错误，MaxWait 为 0 时只执行一次。name 是服务的名称，用于打印日志。
+func Wait(name string, config Config, fn func(ctx context.Context) error) error {
+	return WaitContext(context.Background(), name, config, fn)
+}
+
+// WaitContext 和 Wait 相同，ctx 结束时停止等待。
+func WaitContext(ctx context.Context, name string, config Config, fn func(ctx context.Context) error) error {
+	deadline := time.Now().Add(config.MaxWait)
+	backoff := config.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			if attempt > 1 {
+				logger.Infof("waitfor: %s is ready after %d attempts", name, attempt)
+			}
+			return nil
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			if attempt > 1 {
+				return fmt.Errorf("waitfor: %s is not ready after %s: %w", name, config.MaxWait, err)
+			}
+			return err
+		}
+		if backoff <= 0 {
+			backoff = 500 * time.Millisecond
+		}
+		if backoff > remaining {
+			backoff = remaining
+		}
+		logger.Warnf("waitfor: %s is not ready (attempt %d), retry in %s: %v", name, attempt, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = time.Duration(float64(backoff) * config.Multiplier)
+		if config.MaxBackoff > 0 && backoff > config.MaxBackoff {
+			backoff = config.MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package waitfor_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/waitfor"
+)
+
+func TestWait(t *testing.T) {
+	refused := errors.New("connection refused")
+
+	t.Run("once", func(t *testing.T) {
+		n := 0
+		err := waitfor.Wait("db", waitfor.Config{}, func(ctx context.Context) error {
+			n++
+			return refused
+		})
+		assert.Equal(t, err, refused)
+		assert.Equal(t, n, 1)
+	})
+
+	t.Run("ready", func(t *testing.T) {
+		n := 0
+		config := waitfor.Config{MaxWait: time.Second, Backoff: time.Millisecond, Multiplier: 2}
+		err := waitfor.Wait("db", config, func(ctx context.Context) error {
+			if n++; n < 3 {
+				return refused
+			}
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, n, 3)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		n := 0
+		config := waitfor.Config{MaxWait: 50 * time.Millisecond, Backoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond, Multiplier: 2}
+		start := time.Now()
+		err := waitfor.Wait("db", config, func(ctx context.Context) error {
+			n++
+			return refused
+		})
+		assert.True(t, errors.Is(err, refused))
+		assert.Error(t, err, "db is not ready after 50ms")
+		assert.True(t, n > 2)
+		assert.True(t, time.Since(start) < time.Second)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		config := waitfor.Config{MaxWait: time.Minute, Backoff: time.Minute}
+		err := waitfor.WaitContext(ctx, "db", config, func(ctx context.Context) error {
+			cancel()
+			return refused
+		})
+		assert.Equal(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"io/ioutil"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec BIND 形式的处理函数使用的请求体和响应体编解码器，例如 protobuf 。请求的
+// Content-Type 有支持绑定类型的编解码器时使用编解码器解析请求体，否则使用 Context.Bind ；
+// 响应按照 Accept 请求头选择编解码器，没有合适的编解码器时使用 RpcInvoke 。
+type Codec interface {
+
+	// MediaTypes 返回支持的媒体类型。
+	MediaTypes() []string
+
+	// Supports 返回是否支持 t 类型的数据，t 为处理函数的参数或者返回值类型。
+	Supports(t reflect.Type) bool
+
+	// Marshal 编码响应数据。
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal 解码请求体。
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var codecs struct {
+	sync.RWMutex
+	list []Codec
+}
+
+// RegisterCodec 注册编解码器，先注册的编解码器优先使用，通常在包初始化时调用。
+func RegisterCodec(c Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+	codecs.list = append(codecs.list, c)
+}
+
+// findCodec 返回支持 mediaType 和 t 类型的编解码器。
+func findCodec(mediaType string, t reflect.Type) Codec {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	for _, c := range codecs.list {
+		for _, m := range c.MediaTypes() {
+			if strings.EqualFold(m, mediaType) && c.Supports(t) {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// requestCodec 返回解析请求体使用的编解码器。
+func requestCodec(ctx Context, t reflect.Type) Codec {
+	mediaType, _, err := mime.ParseMediaType(ctx.Header(HeaderContentType))
+	if err != nil {
+		return nil
+	}
+	return findCodec(mediaType, t)
+}
+
+// responseCodec 按照 Accept 请求头的优先级返回编码响应使用的编解码器和媒体类型，
+// 没有 Accept 请求头时按照 application/json 查找。
+func responseCodec(ctx Context, t reflect.Type) (Codec, string) {
+	for _, mediaType := range acceptedMediaTypes(ctx.Header(HeaderAccept)) {
+		if mediaType == "*/*" {
+			mediaType = MIMEApplicationJSON
+		}
+		if c := findCodec(mediaType, t); c != nil {
+			return c, mediaType
+		}
+	}
+	return nil, ""
+}
+
+// acceptedMediaTypes 返回按照 q 值从高到低排序的媒体类型。
+func acceptedMediaTypes(accept string) []string {
+	if accept == "" {
+		return []string{MIMEApplicationJSON}
+	}
+	type item struct {
+		mediaType string
+		q         float64
+	}
+	var items []item
+	for _, s := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(s))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, _ = strconv.ParseFloat(v, 64)
+		}
+		if q > 0 {
+			items = append(items, item{mediaType, q})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].q > items[j].q })
+	r := make([]string, len(items))
+	for i, e := range items {
+		r[i] = e.mediaType
+	}
+	return r
+}
+
+// bindCodec 使用编解码器解析请求体。
+func bindCodec(ctx Context, c Codec, i interface{}) error {
+	b, err := ioutil.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return err
+	}
+	return c.Unmarshal(b, i)
+}
+
+// writeCodec 使用编解码器编码响应数据。
+func writeCodec(ctx Context, c Codec, mediaType string, data interface{}) {
+	b, err := c.Marshal(data)
+	if err != nil {
+		panic(err)
+	}
+	ctx.Blob(mediaType, b)
+}
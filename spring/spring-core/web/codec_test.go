@@ -0,0 +1,123 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/web"
+)
+
+type Point struct {
+	X, Y int
+}
+
+// pointCodec 使用 "x,y" 格式编解码 Point 的编解码器。
+type pointCodec struct{}
+
+func (pointCodec) MediaTypes() []string {
+	return []string{"text/x-point", "application/x-point"}
+}
+
+func (pointCodec) Supports(t reflect.Type) bool {
+	return t == reflect.TypeOf((*Point)(nil))
+}
+
+func (pointCodec) Marshal(v interface{}) ([]byte, error) {
+	p := v.(*Point)
+	return []byte(strconv.Itoa(p.X) + "," + strconv.Itoa(p.Y)), nil
+}
+
+func (pointCodec) Unmarshal(data []byte, v interface{}) error {
+	ss := strings.Split(string(data), ",")
+	if len(ss) != 2 {
+		return errors.New("invalid point")
+	}
+	p := v.(*Point)
+	p.X, _ = strconv.Atoi(ss[0])
+	p.Y, _ = strconv.Atoi(ss[1])
+	return nil
+}
+
+func TestRegisterCodec(t *testing.T) {
+
+	web.RegisterCodec(pointCodec{})
+
+	h := web.BIND(func(ctx context.Context, p *Point) *Point {
+		return &Point{X: p.Y, Y: p.X}
+	})
+
+	testcases := []struct {
+		contentType string
+		accept      string
+		body        string
+		status      int
+		respType    string
+		respBody    string
+	}{
+		{
+			contentType: "text/x-point; charset=utf-8",
+			accept:      "application/json;q=0.5, application/x-point",
+			body:        "1,2",
+			status:      http.StatusOK,
+			respType:    "application/x-point",
+			respBody:    "2,1",
+		},
+		{
+			contentType: "text/x-point",
+			body:        "3,4",
+			status:      http.StatusOK,
+			respType:    web.MIMEApplicationJSONCharsetUTF8,
+			respBody:    `{"X":4,"Y":3}`,
+		},
+		{
+			contentType: "application/json",
+			accept:      "text/x-point",
+			body:        `{"X":5,"Y":6}`,
+			status:      http.StatusOK,
+			respType:    "text/x-point",
+			respBody:    "6,5",
+		},
+	}
+
+	for i, c := range testcases {
+		r := httptest.NewRequest(http.MethodPost, "/point", strings.NewReader(c.body))
+		r.Header.Set(web.HeaderContentType, c.contentType)
+		if c.accept != "" {
+			r.Header.Set(web.HeaderAccept, c.accept)
+		}
+		w := httptest.NewRecorder()
+		ctx := &jsonBindContext{web.NewBaseContext("/point", h, r, &web.BufferedResponseWriter{ResponseWriter: w})}
+		h.Invoke(ctx)
+		assert.Equal(t, w.Code, c.status, strconv.Itoa(i))
+		assert.Equal(t, w.Header().Get(web.HeaderContentType), c.respType, strconv.Itoa(i))
+		assert.Equal(t, strings.TrimSpace(w.Body.String()), c.respBody, strconv.Itoa(i))
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/point", strings.NewReader("1"))
+	r.Header.Set(web.HeaderContentType, "text/x-point")
+	ctx := web.NewBaseContext("/point", h, r, &web.BufferedResponseWriter{ResponseWriter: httptest.NewRecorder()})
+	assert.Panic(t, func() { h.Invoke(ctx) }, "invalid point")
+}
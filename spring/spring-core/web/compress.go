@@ -0,0 +1,331 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-spring/spring-core/conf"
+)
+
+// 支持的压缩格式。
+const (
+	EncodingBrotli  = "br"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+type CompressConfig struct {
+	Level     int      `value:"${web.compress.level:=-1}"`        // gzip 和 deflate 的压缩级别，brotli 使用默认级别
+	MinLength int      `value:"${web.compress.min-length:=1024}"` // 响应达到该长度时才进行压缩
+	Encodings []string `value:"${web.compress.encodings:=br,gzip,deflate}"`
+	Types     []string `value:"${web.compress.types:=text/html,text/plain,text/xml,text/css,application/json,application/xml,application/javascript}"`
+}
+
+func NewCompressConfig() CompressConfig {
+	return CompressConfig{
+		Level:     gzip.DefaultCompression,
+		MinLength: 1024,
+		Encodings: []string{EncodingBrotli, EncodingGzip, EncodingDeflate},
+		Types: []string{
+			MIMETextHTML, MIMETextPlain, MIMETextXML, "text/css",
+			MIMEApplicationJSON, MIMEApplicationXML, MIMEApplicationJavaScript,
+		},
+	}
+}
+
+// negotiate 根据 Accept-Encoding 请求头选择压缩格式，不需要压缩时返回空字符串。
+func (config *CompressConfig) negotiate(acceptEncoding string) string {
+	accepted := make(map[string]bool)
+	for _, s := range strings.Split(acceptEncoding, ",") {
+		ss := strings.Split(s, ";")
+		enc := strings.ToLower(strings.TrimSpace(ss[0]))
+		q := 1.0
+		for _, param := range ss[1:] {
+			if param = strings.TrimSpace(param); strings.HasPrefix(param, "q=") {
+				q, _ = strconv.ParseFloat(param[2:], 64)
+			}
+		}
+		accepted[enc] = q > 0
+	}
+	for _, enc := range config.Encodings {
+		if ok, found := accepted[enc]; found {
+			if ok {
+				return enc
+			}
+			continue
+		}
+		if accepted["*"] {
+			return enc
+		}
+	}
+	return ""
+}
+
+func (config *CompressConfig) allow(contentType string) bool {
+	contentType = strings.ToLower(filterFlags(contentType))
+	for _, t := range config.Types {
+		if t == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+func newEncoder(encoding string, w io.Writer, level int) (io.WriteCloser, error) {
+	switch encoding {
+	case EncodingBrotli:
+		return brotli.NewWriter(w), nil
+	case EncodingGzip:
+		return gzip.NewWriterLevel(w, level)
+	default:
+		return zlib.NewWriterLevel(w, level)
+	}
+}
+
+// compressWriter 在响应达到指定长度之后对响应进行压缩，在此之前缓存响应的数据。
+type compressWriter struct {
+	ResponseWriter
+	config   *CompressConfig
+	encoding string
+	status   int
+	size     int
+	decided  bool
+	buf      bytes.Buffer // 决定是否压缩之前缓存的数据
+	body     bytes.Buffer // 压缩之前的响应数据，参见 BufferedResponseWriter.Body
+	encoder  io.WriteCloser
+}
+
+func (w *compressWriter) Status() int {
+	if w.status != 0 {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *compressWriter) Size() int {
+	return w.size
+}
+
+func (w *compressWriter) Body() string {
+	return w.body.String()
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	w.size += len(b)
+	if canPrintResponse(w) {
+		w.body.Write(b)
+	}
+	if !w.decided {
+		w.buf.Write(b)
+		if w.buf.Len() < w.config.MinLength {
+			return len(b), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide 决定是否压缩响应，然后写入响应头和缓存的数据。
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+
+	h := w.Header()
+	if h.Get(HeaderContentType) == "" && w.buf.Len() > 0 {
+		h.Set(HeaderContentType, http.DetectContentType(w.buf.Bytes()))
+	}
+
+	switch w.status {
+	case http.StatusNoContent, http.StatusNotModified:
+		compress = false
+	}
+
+	if compress && h.Get(HeaderContentEncoding) == "" && w.config.allow(h.Get(HeaderContentType)) {
+		encoder, err := newEncoder(w.encoding, w.ResponseWriter, w.config.Level)
+		if err != nil {
+			return err
+		}
+		w.encoder = encoder
+		h.Set(HeaderContentEncoding, w.encoding)
+		h.Add(HeaderVary, HeaderAcceptEncoding)
+		h.Del(HeaderContentLength)
+	}
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+	return err
+}
+
+// Flush 立即发送已经写入的数据，用于服务器推送等场景。
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide(true)
+	}
+	if f, ok := w.encoder.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) close() error {
+	if !w.decided {
+		if err := w.decide(false); err != nil {
+			return err
+		}
+	}
+	if w.encoder != nil {
+		return w.encoder.Close()
+	}
+	return nil
+}
+
+// NewCompressFilter 创建根据 Accept-Encoding 请求头对响应进行压缩的过滤器，
+// 只有类型在允许列表中并且达到指定长度的响应才会被压缩。
+func NewCompressFilter(config CompressConfig) Filter {
+	return FuncFilter(func(ctx Context, chain FilterChain) {
+
+		encoding := config.negotiate(ctx.Header(HeaderAcceptEncoding))
+		if encoding == "" || ctx.Request().Method == http.MethodHead {
+			chain.Continue(ctx)
+			return
+		}
+
+		w := ctx.ResponseWriter()
+		cw := &compressWriter{ResponseWriter: w, config: &config, encoding: encoding}
+		ctx.SetResponseWriter(cw)
+		defer ctx.SetResponseWriter(w)
+
+		chain.Next(ctx)
+
+		if err := cw.close(); err != nil {
+			panic(err)
+		}
+	})
+}
+
+// DefaultMaxDecompressSize 服务器没有限制请求体大小时解压后的请求体的默认上限。
+const DefaultMaxDecompressSize = 32 * 1024 * 1024
+
+// DecompressConfig 请求体解压的配置。
+type DecompressConfig struct {
+	MaxSize conf.ByteSize `value:"${web.decompress.max-size:=0}"` // 解压后的最大字节数，为 0 时使用服务器的 max-body-size
+}
+
+func NewDecompressConfig() DecompressConfig {
+	return DecompressConfig{}
+}
+
+// NewDecompressFilter 创建根据 Content-Encoding 请求头对请求体进行透明解压的过滤器，
+// 不支持的压缩格式返回 415 错误。为了防止压缩炸弹，解压后的请求体超过 MaxSize 时读取
+// 返回 413 错误，MaxSize 为 0 时使用服务器的请求体大小限制，服务器也没有限制时使用
+// DefaultMaxDecompressSize 。
+func NewDecompressFilter(config DecompressConfig) Filter {
+	return FuncFilter(func(ctx Context, chain FilterChain) {
+
+		r := ctx.Request()
+		encoding := strings.ToLower(strings.TrimSpace(r.Header.Get(HeaderContentEncoding)))
+		if encoding == "" || encoding == "identity" || r.Body == nil || r.Body == http.NoBody {
+			chain.Continue(ctx)
+			return
+		}
+
+		var (
+			reader io.ReadCloser
+			err    error
+		)
+		switch encoding {
+		case EncodingGzip:
+			reader, err = gzip.NewReader(r.Body)
+		case EncodingDeflate:
+			reader, err = zlib.NewReader(r.Body)
+		case EncodingBrotli:
+			reader = ioutil.NopCloser(brotli.NewReader(r.Body))
+		default:
+			ctx.SetStatus(http.StatusUnsupportedMediaType)
+			ctx.String("unsupported content encoding %q", encoding)
+			return
+		}
+		if err != nil {
+			ctx.SetStatus(http.StatusBadRequest)
+			ctx.String(err.Error())
+			return
+		}
+
+		max := int64(config.MaxSize)
+		if max <= 0 {
+			if b, ok := r.Body.(*requestBody); ok && b.max > 0 {
+				max = b.max
+			} else {
+				max = DefaultMaxDecompressSize
+			}
+		}
+
+		body := r.Body
+		limited := &requestBody{ReadCloser: reader, max: max}
+		r.Body = limited
+		r.Header.Del(HeaderContentEncoding)
+		r.Header.Del(HeaderContentLength)
+		r.ContentLength = -1
+		defer func() {
+			_ = reader.Close()
+			_ = body.Close()
+		}()
+		chain.Next(ctx)
+
+		// 处理器没有响应读取请求体的错误时返回 413 错误。
+		if w := ctx.ResponseWriter(); limited.read > max && w.Status() == 0 && w.Size() == 0 {
+			ctx.SetStatus(http.StatusRequestEntityTooLarge)
+			ctx.String(http.StatusText(http.StatusRequestEntityTooLarge))
+		}
+	})
+}
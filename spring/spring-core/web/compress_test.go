@@ -0,0 +1,157 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+
+package web_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/web"
+)
+
+func TestCompressFilter(t *testing.T) {
+
+	p, err := conf.Bytes([]byte("web.compress.min-length=16"), ".properties")
+	assert.Nil(t, err)
+	config := web.NewCompressConfig()
+	err = p.Bind(&config)
+	assert.Nil(t, err)
+	assert.Equal(t, config.MinLength, 16)
+	assert.Equal(t, config.Encodings, []string{"br", "gzip", "deflate"})
+
+	long := strings.Repeat("hello world ", 10)
+	f := web.NewCompressFilter(config)
+
+	for _, c := range []struct {
+		acceptEncoding  string
+		contentType     string
+		body            string
+		contentEncoding string
+	}{
+		{"gzip, deflate", web.MIMETextPlain, long, "gzip"},
+		{"gzip;q=0, br", web.MIMEApplicationJSON, long, "br"},
+		{"*", web.MIMETextPlain, long, "br"},
+		{"gzip", web.MIMETextPlain, "short", ""},
+		{"gzip", web.MIMEOctetStream, long, ""},
+		{"", web.MIMETextPlain, long, ""},
+	} {
+		r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:8080/", nil)
+		r.Header.Set(web.HeaderAcceptEncoding, c.acceptEncoding)
+		w := httptest.NewRecorder()
+		ctx := web.NewBaseContext("", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+		handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+			ctx.Blob(c.contentType, []byte(c.body))
+		})
+		web.NewFilterChain([]web.Filter{f, handler}).Next(ctx)
+
+		assert.Equal(t, w.Code, http.StatusOK)
+		assert.Equal(t, w.Header().Get(web.HeaderContentEncoding), c.contentEncoding)
+
+		var b []byte
+		switch c.contentEncoding {
+		case "gzip":
+			reader, err := gzip.NewReader(w.Body)
+			assert.Nil(t, err)
+			b, err = ioutil.ReadAll(reader)
+			assert.Nil(t, err)
+		case "br":
+			b, err = ioutil.ReadAll(brotli.NewReader(w.Body))
+			assert.Nil(t, err)
+		default:
+			b = w.Body.Bytes()
+		}
+		assert.Equal(t, string(b), c.body)
+	}
+}
+
+func TestDecompressFilter(t *testing.T) {
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, _ = gw.Write([]byte(`{"name":"go-spring"}`))
+	_ = gw.Close()
+
+	f := web.NewDecompressFilter(web.NewDecompressConfig())
+	handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		b, err := ctx.RequestBody()
+		assert.Nil(t, err)
+		ctx.String(string(b))
+	})
+
+	r, _ := http.NewRequest(http.MethodPost, "http://127.0.0.1:8080/", &buf)
+	r.Header.Set(web.HeaderContentEncoding, "gzip")
+	w := httptest.NewRecorder()
+	ctx := web.NewBaseContext("", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+	web.NewFilterChain([]web.Filter{f, handler}).Next(ctx)
+	assert.Equal(t, w.Body.String(), `{"name":"go-spring"}`)
+	assert.Equal(t, r.Header.Get(web.HeaderContentEncoding), "")
+
+	r, _ = http.NewRequest(http.MethodPost, "http://127.0.0.1:8080/", strings.NewReader("x"))
+	r.Header.Set(web.HeaderContentEncoding, "zstd")
+	w = httptest.NewRecorder()
+	ctx = web.NewBaseContext("", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+	web.NewFilterChain([]web.Filter{f, handler}).Next(ctx)
+	assert.Equal(t, w.Code, http.StatusUnsupportedMediaType)
+}
+
+func TestDecompressFilter_MaxSize(t *testing.T) {
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, _ = gw.Write(bytes.Repeat([]byte("0"), 1024*1024))
+	_ = gw.Close()
+
+	f := web.NewDecompressFilter(web.DecompressConfig{MaxSize: 1024})
+
+	t.Run("unhandled", func(t *testing.T) {
+		handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+			_, err := ctx.RequestBody()
+			assert.Error(t, err, "code=413")
+		})
+		r, _ := http.NewRequest(http.MethodPost, "http://127.0.0.1:8080/", bytes.NewReader(buf.Bytes()))
+		r.Header.Set(web.HeaderContentEncoding, "gzip")
+		w := httptest.NewRecorder()
+		ctx := web.NewBaseContext("", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+		web.NewFilterChain([]web.Filter{f, handler}).Next(ctx)
+		assert.Equal(t, w.Code, http.StatusRequestEntityTooLarge)
+	})
+
+	t.Run("handled", func(t *testing.T) {
+		handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+			if _, err := ctx.RequestBody(); err != nil {
+				ctx.SetStatus(http.StatusBadRequest)
+				ctx.String(err.Error())
+			}
+		})
+		r, _ := http.NewRequest(http.MethodPost, "http://127.0.0.1:8080/", bytes.NewReader(buf.Bytes()))
+		r.Header.Set(web.HeaderContentEncoding, "gzip")
+		w := httptest.NewRecorder()
+		ctx := web.NewBaseContext("", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+		web.NewFilterChain([]web.Filter{f, handler}).Next(ctx)
+		assert.Equal(t, w.Code, http.StatusBadRequest)
+		assert.Equal(t, w.Body.String(), "code=413, message=Request Entity Too Large")
+	})
+}
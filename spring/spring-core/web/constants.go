@@ -20,13 +20,17 @@ const (
 	HeaderAccept              = "Accept"
 	HeaderAcceptEncoding      = "Accept-Encoding"
 	HeaderAllow               = "Allow"
+	HeaderAge                 = "Age"
 	HeaderAuthorization       = "Authorization"
+	HeaderCacheControl        = "Cache-Control"
 	HeaderContentDisposition  = "Content-Disposition"
 	HeaderContentEncoding     = "Content-Encoding"
 	HeaderContentLength       = "Content-Length"
 	HeaderContentType         = "Content-Type"
 	HeaderCookie              = "Cookie"
 	HeaderSetCookie           = "Set-Cookie"
+	HeaderETag                = "ETag"
+	HeaderIfNoneMatch         = "If-None-Match"
 	HeaderIfModifiedSince     = "If-Modified-Since"
 	HeaderLastModified        = "Last-Modified"
 	HeaderLocation            = "Location"
@@ -29,6 +29,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/go-spring/spring-base/knife"
 	"github.com/go-spring/spring-base/log"
@@ -82,6 +83,11 @@ func (c *BaseContext) Context() context.Context {
 	return c.r.Context()
 }
 
+// SetContext 替换 Request 绑定的 context.Context 对象
+func (c *BaseContext) SetContext(ctx context.Context) {
+	c.r = c.r.WithContext(ctx)
+}
+
 // IsTLS returns true if HTTP connection is TLS otherwise false.
 func (c *BaseContext) IsTLS() bool {
 	return c.r.TLS != nil
@@ -264,6 +270,11 @@ func (c *BaseContext) ResponseWriter() ResponseWriter {
 	return c.w
 }
 
+// SetResponseWriter 替换 ResponseWriter 。
+func (c *BaseContext) SetResponseWriter(w ResponseWriter) {
+	c.w = w
+}
+
 // SetStatus sets the HTTP response code.
 func (c *BaseContext) SetStatus(code int) {
 	c.w.WriteHeader(code)
@@ -426,8 +437,7 @@ func (c *BaseContext) File(file string) {
 }
 
 func (c *BaseContext) contentDisposition(file, name, dispositionType string) {
-	s := fmt.Sprintf("%s; filename=%q", dispositionType, name)
-	c.SetHeader(HeaderContentDisposition, s)
+	c.SetHeader(HeaderContentDisposition, ContentDisposition(dispositionType, name))
 	c.File(file)
 }
 
@@ -441,6 +451,69 @@ func (c *BaseContext) Inline(file string, name string) {
 	c.contentDisposition(file, name, "inline")
 }
 
+// ServeContent 使用 content 的内容响应请求，支持 Range 请求和条件请求。
+func (c *BaseContext) ServeContent(name string, modTime time.Time, content io.ReadSeeker) {
+	http.ServeContent(c.w, c.r, name, modTime, content)
+}
+
+// Stream 将 r 的内容分块发送给客户端，每次写入后立即刷新，客户端断开时停止。
+func (c *BaseContext) Stream(contentType string, r io.Reader) {
+	c.SetContentType(contentType)
+	flusher, _ := c.w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		if c.r.Context().Err() != nil {
+			return
+		}
+		n, err := r.Read(buf)
+		if n > 0 {
+			_, werr := c.w.Write(buf[:n])
+			util.Panic(werr).When(werr != nil)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err == io.EOF {
+			return
+		}
+		util.Panic(err).When(err != nil)
+	}
+}
+
+// ContentDisposition 返回 Content-Disposition 响应头的值，文件名包含非 ASCII 字符
+// 时按照 RFC 6266 增加 filename* 参数，filename 参数中的非 ASCII 字符被替换为 _ 。
+func ContentDisposition(dispositionType, name string) string {
+	ascii := true
+	fallback := []rune(name)
+	for i, r := range fallback {
+		if r >= 0x80 || r < 0x20 || r == 0x7f {
+			fallback[i] = '_'
+			ascii = false
+		}
+	}
+	quoted := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(string(fallback))
+	s := fmt.Sprintf(`%s; filename="%s"`, dispositionType, quoted)
+	if !ascii {
+		s += "; filename*=UTF-8''" + encodeRFC5987(name)
+	}
+	return s
+}
+
+// encodeRFC5987 按照 RFC 5987 对参数值进行百分号编码。
+func encodeRFC5987(s string) string {
+	const attrChars = "!#$&+-.^_`|~"
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || strings.IndexByte(attrChars, b) >= 0 {
+			sb.WriteByte(b)
+			continue
+		}
+		fmt.Fprintf(&sb, "%%%02X", b)
+	}
+	return sb.String()
+}
+
 // Redirect redirects the request to a provided URL with status code.
 func (c *BaseContext) Redirect(code int, url string) {
 	if (code < http.StatusMultipleChoices || code > http.StatusPermanentRedirect) && code != http.StatusCreated {
@@ -15,3 +15,82 @@
  */
 
 package web_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/web"
+)
+
+func TestContentDisposition(t *testing.T) {
+	assert.Equal(t, web.ContentDisposition("attachment", "report.csv"), `attachment; filename="report.csv"`)
+	assert.Equal(t, web.ContentDisposition("inline", `a"b\c.txt`), `inline; filename="a\"b\\c.txt"`)
+	assert.Equal(t, web.ContentDisposition("attachment", "报表 2021.csv"),
+		`attachment; filename="__ 2021.csv"; filename*=UTF-8''%E6%8A%A5%E8%A1%A8%202021.csv`)
+}
+
+func TestServeContent(t *testing.T) {
+	modTime := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	r := httptest.NewRequest(http.MethodGet, "/report.csv", nil)
+	r.Header.Set("Range", "bytes=2-5")
+	w := httptest.NewRecorder()
+	ctx := web.NewBaseContext("/report.csv", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+	ctx.ServeContent("report.csv", modTime, strings.NewReader("0123456789"))
+	assert.Equal(t, w.Code, http.StatusPartialContent)
+	assert.Equal(t, w.Header().Get("Content-Range"), "bytes 2-5/10")
+	assert.Equal(t, w.Body.String(), "2345")
+
+	r = httptest.NewRequest(http.MethodGet, "/report.csv", nil)
+	r.Header.Set(web.HeaderIfModifiedSince, modTime.Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+	ctx = web.NewBaseContext("/report.csv", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+	ctx.ServeContent("report.csv", modTime, strings.NewReader("0123456789"))
+	assert.Equal(t, w.Code, http.StatusNotModified)
+}
+
+type chunkReader struct {
+	chunks []string
+	cancel context.CancelFunc
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		if r.cancel != nil {
+			return 0, errors.New("should stop after client disconnected")
+		}
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	if len(r.chunks) == 1 && r.cancel != nil {
+		r.cancel()
+	}
+	return n, nil
+}
+
+func TestStream(t *testing.T) {
+
+	r := httptest.NewRequest(http.MethodGet, "/events", nil)
+	w := httptest.NewRecorder()
+	ctx := web.NewBaseContext("/events", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+	ctx.Stream(web.MIMETextPlain, &chunkReader{chunks: []string{"a", "b", "c"}})
+	assert.Equal(t, w.Header().Get(web.HeaderContentType), web.MIMETextPlain)
+	assert.Equal(t, w.Body.String(), "abc")
+	assert.True(t, w.Flushed)
+
+	c, cancel := context.WithCancel(context.Background())
+	r = httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(c)
+	w = httptest.NewRecorder()
+	ctx = web.NewBaseContext("/events", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+	ctx.Stream(web.MIMETextPlain, &chunkReader{chunks: []string{"a", "b", "c"}, cancel: cancel})
+	assert.Equal(t, w.Body.String(), "ab")
+}
@@ -17,13 +17,18 @@
 package web
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/go-spring/spring-base/log"
 )
@@ -132,6 +137,9 @@ type Context interface {
 	// Context 返回 Request 绑定的 context.Context 对象
 	Context() context.Context
 
+	// SetContext 替换 Request 绑定的 context.Context 对象，ctx 应该派生自 Context() 。
+	SetContext(ctx context.Context)
+
 	// IsTLS returns true if HTTP connection is TLS otherwise false.
 	IsTLS() bool
 
@@ -211,6 +219,9 @@ type Context interface {
 	// ResponseWriter returns ResponseWriter.
 	ResponseWriter() ResponseWriter
 
+	// SetResponseWriter 替换 ResponseWriter，通常用于过滤器对响应进行包装。
+	SetResponseWriter(w ResponseWriter)
+
 	// SetStatus sets the HTTP response code.
 	SetStatus(code int)
 
@@ -276,6 +287,13 @@ type Context interface {
 	// Inline sends a response as inline, opening the file in the browser. Maybe panic.
 	Inline(file string, name string)
 
+	// ServeContent 使用 content 的内容响应请求，支持 Range 请求和 If-Modified-Since
+	// 等条件请求，Content-Type 根据 name 的扩展名或者内容推断。
+	ServeContent(name string, modTime time.Time, content io.ReadSeeker)
+
+	// Stream 将 r 的内容分块发送给客户端，每次写入后立即刷新，客户端断开时停止。Maybe panic.
+	Stream(contentType string, r io.Reader)
+
 	// Redirect redirects the request to a provided URL with status code. Maybe panic.
 	Redirect(code int, url string)
 
@@ -331,6 +349,26 @@ func (w *BufferedResponseWriter) WriteHeader(code int) {
 	w.status = code
 }
 
+// Flush 立即发送已经写入的数据。
+func (w *BufferedResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 接管底层的连接，用于 WebSocket 等协议升级的场景，接管成功后状态码记为 101 。
+func (w *BufferedResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("web: response writer doesn't support hijacking")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
 func (w *BufferedResponseWriter) Write(data []byte) (n int, err error) {
 	if n, err = w.ResponseWriter.Write(data); err == nil && n > 0 {
 		if w.cache && canPrintResponse(w.ResponseWriter) {
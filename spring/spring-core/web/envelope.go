@@ -0,0 +1,164 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/web/i18n"
+)
+
+const envelopeKey = "::envelope::"
+
+// CodeError 注册到错误码表中的业务错误，处理函数返回或者抛出该错误时响应体的 code
+// 和 message 为错误码和错误信息，HTTP 状态码为 Status 。
+type CodeError struct {
+	Code    int32  // 错误码
+	Status  int    // HTTP 状态码
+	Message string // 错误信息，可以使用 {{key}} 引用国际化文本
+	cause   error
+}
+
+var codeErrors = make(map[int32]*CodeError)
+
+// RegisterError 注册错误码，错误码重复时 panic ，通常在包初始化时调用，例如
+// var ErrUserNotFound = web.RegisterError(10001, http.StatusNotFound, "{{user.not-found}}") 。
+func RegisterError(code int32, status int, message string) *CodeError {
+	if e, ok := codeErrors[code]; ok {
+		panic(fmt.Errorf("duplicate error code %d (%s)", code, e.Message))
+	}
+	e := &CodeError{Code: code, Status: status, Message: message}
+	codeErrors[code] = e
+	return e
+}
+
+// LookupError 返回注册的错误码，没有注册时返回 nil 。
+func LookupError(code int32) *CodeError {
+	return codeErrors[code]
+}
+
+func (e *CodeError) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("code=%d, message=%s, error=%v", e.Code, e.Message, e.cause)
+	}
+	return fmt.Sprintf("code=%d, message=%s", e.Code, e.Message)
+}
+
+// Wrap 返回携带原始错误的副本，原始错误只用于日志，不会返回给客户端。
+func (e *CodeError) Wrap(err error) *CodeError {
+	c := *e
+	c.cause = err
+	return &c
+}
+
+func (e *CodeError) Unwrap() error {
+	return e.cause
+}
+
+// Is 错误码相同即认为是同一个错误。
+func (e *CodeError) Is(target error) bool {
+	t, ok := target.(*CodeError)
+	return ok && t.Code == e.Code
+}
+
+// Envelope 标准的响应体。
+type Envelope struct {
+	Code    int32       `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type EnvelopeConfig struct {
+	Success RpcSuccess // 成功时的错误码和信息，默认为 SUCCESS
+	Error   RpcError   // 未注册错误的错误码和信息，默认为 ERROR
+}
+
+func NewEnvelopeConfig() EnvelopeConfig {
+	return EnvelopeConfig{Success: SUCCESS, Error: ERROR}
+}
+
+// NewEnvelopeFilter 创建响应体过滤器，经过该过滤器的 BIND 形式的处理函数只需要返
+// 回数据和错误，由框架包装成 Envelope 。CodeError 按照注册的 HTTP 状态码返回，
+// HttpError 按照其状态码返回，其他错误返回 500 并且不会暴露错误信息。处理函数中
+// 抛出的 CodeError 也会被包装成 Envelope 。
+func NewEnvelopeFilter(config EnvelopeConfig) Filter {
+	return FuncFilter(func(ctx Context, chain FilterChain) {
+		if err := ctx.Set(envelopeKey, &config); err != nil {
+			logger.WithContext(ctx.Context()).Error(log.ERROR, err)
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				var e *CodeError
+				if err, ok := r.(error); ok && errors.As(err, &e) {
+					writeEnvelope(ctx, &config, nil, err)
+					return
+				}
+				panic(r)
+			}
+		}()
+		// 需要捕获后续节点中的 panic ，因此不能使用 Continue 。
+		chain.Next(ctx)
+	})
+}
+
+// envelopeConfig 返回 NewEnvelopeFilter 保存在上下文中的配置，没有经过该过滤器时
+// 返回 nil 。
+func envelopeConfig(ctx Context) *EnvelopeConfig {
+	config, _ := ctx.Get(envelopeKey).(*EnvelopeConfig)
+	return config
+}
+
+func writeEnvelope(ctx Context, config *EnvelopeConfig, data interface{}, err error) {
+
+	status := http.StatusOK
+	env := &Envelope{Code: config.Success.Code, Message: config.Success.Msg, Data: data}
+
+	if err != nil {
+		var (
+			codeErr *CodeError
+			httpErr *HttpError
+		)
+		env.Data = nil
+		switch {
+		case errors.As(err, &codeErr):
+			status, env.Code = codeErr.Status, codeErr.Code
+			env.Message = codeErr.Message
+			if s, e := i18n.Resolve(ctx.Context(), codeErr.Message); e == nil {
+				env.Message = s
+			}
+			if codeErr.cause != nil {
+				logger.WithContext(ctx.Context()).Warnf("%s %v", ctx.Path(), err)
+			}
+		case errors.As(err, &httpErr):
+			status, env.Code, env.Message = httpErr.Code, int32(httpErr.Code), httpErr.Message
+		default:
+			logger.WithContext(ctx.Context()).Errorf(log.ERROR, "%s %v", ctx.Path(), err)
+			status, env.Code, env.Message = http.StatusInternalServerError, config.Error.Code, config.Error.Msg
+		}
+		if status == 0 {
+			status = http.StatusOK
+		}
+	}
+
+	// 必须在写入状态码之前设置 Content-Type 。
+	ctx.SetContentType(MIMEApplicationJSONCharsetUTF8)
+	ctx.SetStatus(status)
+	ctx.JSON(env)
+}
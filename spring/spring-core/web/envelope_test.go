@@ -0,0 +1,132 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/web"
+	"github.com/go-spring/spring-core/web/i18n"
+)
+
+var errUserNotFound = web.RegisterError(10001, http.StatusNotFound, "{{user.not-found}}")
+
+type failedBindContext struct {
+	*web.BaseContext
+}
+
+func (ctx *failedBindContext) Bind(i interface{}) error {
+	return errors.New("invalid json")
+}
+
+func TestEnvelopeFilter(t *testing.T) {
+
+	p := conf.New()
+	err := p.Set("user.not-found", "user not found")
+	assert.Nil(t, err)
+	err = i18n.Register("zh-CN", p)
+	assert.Nil(t, err)
+
+	assert.Equal(t, web.LookupError(10001), errUserNotFound)
+	assert.Panic(t, func() {
+		web.RegisterError(10001, http.StatusBadRequest, "duplicate")
+	}, "duplicate error code 10001")
+
+	type User struct {
+		Name string `json:"name"`
+	}
+
+	type GetUserReq struct {
+		Name string
+	}
+
+	filter := web.NewEnvelopeFilter(web.NewEnvelopeConfig())
+	invoke := func(ctx web.Context, fn interface{}) {
+		h := web.HandlerFilter(web.BIND(fn))
+		web.NewFilterChain([]web.Filter{filter, h}).Next(ctx)
+	}
+
+	testcases := []struct {
+		fn     interface{}
+		status int
+		body   string
+	}{
+		{
+			fn: func(ctx context.Context, req *GetUserReq) (*User, error) {
+				return &User{Name: "jim"}, nil
+			},
+			status: http.StatusOK,
+			body:   `{"code":200,"message":"SUCCESS","data":{"name":"jim"}}`,
+		},
+		{
+			fn: func(ctx context.Context, req *GetUserReq) (*User, error) {
+				return nil, errUserNotFound.Wrap(errors.New("no rows"))
+			},
+			status: http.StatusNotFound,
+			body:   `{"code":10001,"message":"user not found"}`,
+		},
+		{
+			fn: func(ctx context.Context, req *GetUserReq) (*User, error) {
+				return nil, fmt.Errorf("query user: %w", errUserNotFound)
+			},
+			status: http.StatusNotFound,
+			body:   `{"code":10001,"message":"user not found"}`,
+		},
+		{
+			fn: func(ctx context.Context, req *GetUserReq) (*User, error) {
+				return nil, errors.New("db password is 123456")
+			},
+			status: http.StatusInternalServerError,
+			body:   `{"code":-1,"message":"ERROR"}`,
+		},
+		{
+			fn: func(ctx context.Context, req *GetUserReq) *User {
+				panic(errUserNotFound)
+			},
+			status: http.StatusNotFound,
+			body:   `{"code":10001,"message":"user not found"}`,
+		},
+	}
+
+	for _, c := range testcases {
+		ctx, w := newRecordedContext("/users")
+		invoke(&bindContext{ctx}, c.fn)
+		assert.Equal(t, w.Code, c.status)
+		assert.Equal(t, w.Header().Get(web.HeaderContentType), web.MIMEApplicationJSONCharsetUTF8)
+		assert.Equal(t, w.Body.String(), c.body)
+	}
+
+	ctx, w := newRecordedContext("/users")
+	invoke(&failedBindContext{ctx}, func(ctx context.Context, req *GetUserReq) *User {
+		return nil
+	})
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+	assert.Equal(t, w.Body.String(), `{"code":400,"message":"invalid json"}`)
+
+	assert.Panic(t, func() {
+		ctx, _ = newRecordedContext("/users")
+		invoke(&bindContext{ctx}, func(ctx context.Context, req *GetUserReq) *User {
+			panic("boom")
+		})
+	}, "boom")
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nethttp
+
+import (
+	"net/http"
+
+	"github.com/go-spring/spring-core/web"
+)
+
+// Context 适配 net/http 的 Web 上下文
+type Context struct {
+	*web.BaseContext
+
+	pathNames  []string
+	pathValues []string
+
+	// wildcard 通配符的名称
+	wildcard string
+}
+
+// newContext Context 的构造函数
+func newContext(handler web.Handler, path, wildcard string, names, values []string, r *http.Request, w web.ResponseWriter) *Context {
+	if names == nil {
+		names, values = []string{}, []string{}
+	}
+	return &Context{
+		BaseContext: web.NewBaseContext(path, handler, r, w),
+		pathNames:   names,
+		pathValues:  values,
+		wildcard:    wildcard,
+	}
+}
+
+// PathParam returns path parameter by name.
+func (ctx *Context) PathParam(name string) string {
+	if name == ctx.wildcard {
+		name = "*"
+	}
+	for i, n := range ctx.pathNames {
+		if n == name {
+			return ctx.pathValues[i]
+		}
+	}
+	return ""
+}
+
+// PathParamNames returns path parameter names.
+func (ctx *Context) PathParamNames() []string {
+	return ctx.pathNames
+}
+
+// PathParamValues returns path parameter values.
+func (ctx *Context) PathParamValues() []string {
+	return ctx.pathValues
+}
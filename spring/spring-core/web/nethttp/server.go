@@ -0,0 +1,231 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package nethttp 使用标准库 net/http 实现的 Web 容器，不依赖第三方框架，通过
+// web.container.type=nethttp 启用。
+package nethttp
+
+import (
+	"errors"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/web"
+)
+
+// route 注册的路由，segments 是 echo 风格的路径分段。
+type route struct {
+	method   string
+	path     string // 注册时候的路径
+	wildcard string // 通配符的名称
+	segments []string
+	handler  web.Handler
+	filters  []web.Filter
+}
+
+// static 返回路由中确定的路径分段的数量，用于决定路由匹配的优先级。
+func (r *route) static() int {
+	n := 0
+	for _, s := range r.segments {
+		if s != "*" && !strings.HasPrefix(s, ":") {
+			n++
+		}
+	}
+	return n
+}
+
+// match 返回请求路径是否匹配路由，匹配时返回路径参数的名称和值。
+func (r *route) match(parts []string) (names []string, values []string, ok bool) {
+	for i, s := range r.segments {
+		if s == "*" {
+			names = append(names, "*")
+			values = append(values, strings.Join(parts[i:], "/"))
+			return names, values, true
+		}
+		if i >= len(parts) {
+			return nil, nil, false
+		}
+		if strings.HasPrefix(s, ":") {
+			names = append(names, s[1:])
+			values = append(values, parts[i])
+			continue
+		}
+		if s != parts[i] {
+			return nil, nil, false
+		}
+	}
+	return names, values, len(parts) == len(r.segments)
+}
+
+// serverHandler net/http 实现的 web 服务器
+type serverHandler struct {
+	routes []*route
+}
+
+// New 创建 net/http 实现的 web 服务器
+func New(config web.ServerConfig) web.Server {
+	return web.NewServer(config, new(serverHandler))
+}
+
+func (h *serverHandler) RecoveryFilter(errHandler web.ErrorHandler) web.Filter {
+	return &recoveryFilter{errHandler: errHandler}
+}
+
+func (h *serverHandler) Start(s web.Server) error {
+
+	urlPatterns, err := web.URLPatterns(s.Filters())
+	if err != nil {
+		return err
+	}
+
+	for _, mapper := range s.Mappers() {
+		path, wildcard := web.ToPathStyle(mapper.Path(), web.EchoPathStyle)
+		segments := split(path)
+		filters := urlPatterns.Get(mapper.Path())
+		for _, method := range web.GetMethod(mapper.Method()) {
+			h.routes = append(h.routes, &route{
+				method:   method,
+				path:     mapper.Path(),
+				wildcard: wildcard,
+				segments: segments,
+				handler:  mapper.Handler(),
+				filters:  filters,
+			})
+		}
+	}
+
+	// 确定的路径分段越多优先级越高，通配符的优先级最低。
+	sort.SliceStable(h.routes, func(i, j int) bool {
+		ri, rj := h.routes[i], h.routes[j]
+		if si, sj := ri.static(), rj.static(); si != sj {
+			return si > sj
+		}
+		return len(ri.segments) > len(rj.segments)
+	})
+	return nil
+}
+
+// split 将路径分割成路径分段，根路径没有分段。
+func split(path string) []string {
+	path = strings.TrimPrefix(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+func (h *serverHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	rw, ok := w.(web.ResponseWriter)
+	if !ok {
+		rw = &web.BufferedResponseWriter{ResponseWriter: w}
+	}
+
+	parts := split(r.URL.Path)
+	var (
+		matched *route
+		names   []string
+		values  []string
+		allowed bool // 存在路径匹配但是方法不匹配的路由
+	)
+	for _, rt := range h.routes {
+		n, v, ok := rt.match(parts)
+		if !ok {
+			continue
+		}
+		if rt.method != r.Method {
+			allowed = true
+			continue
+		}
+		matched, names, values = rt, n, v
+		break
+	}
+
+	var webCtx *Context
+	if matched != nil {
+		webCtx = newContext(matched.handler, matched.path, matched.wildcard, names, values, r, rw)
+	} else {
+		webCtx = newContext(nil, "", "", nil, nil, r, rw)
+	}
+
+	// 流量录制
+	web.StartRecord(webCtx)
+	defer func() { web.StopRecord(webCtx) }()
+
+	// 流量回放
+	web.StartReplay(webCtx)
+	defer func() { web.StopReplay(webCtx) }()
+
+	if matched == nil {
+		if allowed {
+			panic(&web.HttpError{Code: http.StatusMethodNotAllowed, Message: "405 method not allowed"})
+		}
+		panic(&web.HttpError{Code: http.StatusNotFound, Message: "404 page not found"})
+	}
+
+	filters := append(append([]web.Filter{}, matched.filters...), web.HandlerFilter(matched.handler))
+	web.NewFilterChain(filters).Next(webCtx)
+}
+
+// recoveryFilter net/http 容器的恢复过滤器
+type recoveryFilter struct {
+	errHandler web.ErrorHandler
+}
+
+func (f *recoveryFilter) Invoke(ctx web.Context, chain web.FilterChain) {
+
+	defer func() {
+		if err := recover(); err != nil {
+
+			ctxLogger := log.WithContext(ctx.Context())
+			ctxLogger.Error(nil, err, "\n", string(debug.Stack()))
+
+			httpE := web.HttpError{Code: http.StatusInternalServerError}
+			switch e := err.(type) {
+			case *web.HttpError:
+				httpE = *e
+			case web.HttpError:
+				httpE = e
+			case error:
+				var he *web.HttpError
+				if errors.As(e, &he) { // 如读取请求体时返回的 413 和 408 错误
+					httpE = *he
+				} else {
+					httpE.Message = e.Error()
+				}
+			default:
+				httpE.Message = http.StatusText(httpE.Code)
+				httpE.Internal = err
+			}
+
+			// 只上报服务端的错误，主动返回的 4xx 错误不需要上报。
+			if httpE.Code >= http.StatusInternalServerError {
+				web.ReportPanic(ctx, err)
+			}
+
+			if ctx.Request().Method == http.MethodHead {
+				ctx.NoContent(httpE.Code)
+				return
+			}
+			f.errHandler.Invoke(ctx, &httpE)
+		}
+	}()
+
+	chain.Next(ctx)
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nethttp_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-base/util"
+	"github.com/go-spring/spring-core/web"
+	"github.com/go-spring/spring-core/web/nethttp"
+)
+
+func get(t *testing.T, method, url string) (int, string) {
+	req, err := http.NewRequest(method, url, nil)
+	assert.Nil(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	return resp.StatusCode, string(b)
+}
+
+func TestServer(t *testing.T) {
+
+	c := nethttp.New(web.ServerConfig{Port: 8089})
+	c.AddFilter(web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		ctx.SetHeader("X-Filter", "1")
+		chain.Next(ctx)
+	}).URLPatterns("/users/*"))
+
+	c.GetMapping("/", func(ctx web.Context) {
+		ctx.String("root")
+	})
+	c.GetMapping("/users/me", func(ctx web.Context) {
+		ctx.String("me")
+	})
+	c.GetMapping("/users/:id", func(ctx web.Context) {
+		ctx.String("user %s %s", ctx.PathParam("id"), strings.Join(ctx.PathParamNames(), ","))
+	})
+	c.GetMapping("/files/*", func(ctx web.Context) {
+		ctx.String("file %s", ctx.PathParam("*"))
+	})
+	c.GetMapping("/panic", func(ctx web.Context) {
+		panic(web.NewHttpError(http.StatusTooManyRequests))
+	})
+
+	go c.Start()
+	defer c.Stop(context.Background())
+	<-c.Started()
+	time.Sleep(10 * time.Millisecond)
+
+	code, body := get(t, http.MethodGet, "http://127.0.0.1:8089/")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "root")
+
+	code, body = get(t, http.MethodGet, "http://127.0.0.1:8089/users/me")
+	assert.Equal(t, body, "me")
+
+	code, body = get(t, http.MethodGet, "http://127.0.0.1:8089/users/42")
+	assert.Equal(t, body, "user 42 id")
+
+	code, body = get(t, http.MethodGet, "http://127.0.0.1:8089/files/a/b.txt")
+	assert.Equal(t, body, "file a/b.txt")
+
+	code, body = get(t, http.MethodGet, "http://127.0.0.1:8089/panic")
+	assert.Equal(t, code, http.StatusTooManyRequests)
+
+	code, body = get(t, http.MethodPost, "http://127.0.0.1:8089/users/42")
+	assert.Equal(t, code, http.StatusMethodNotAllowed)
+	assert.Equal(t, body, "405 method not allowed")
+
+	code, body = get(t, http.MethodGet, "http://127.0.0.1:8089/none")
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.Equal(t, body, "404 page not found")
+
+	resp, err := http.Get("http://127.0.0.1:8089/users/42")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.Header.Get("X-Filter"), "1")
+}
+
+func do(t *testing.T, req *http.Request) (int, string) {
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	return resp.StatusCode, string(b)
+}
+
+// TestParams 和 spring-gin 、spring-echo 中的同名测试保持一致。
+func TestParams(t *testing.T) {
+
+	c := nethttp.New(web.ServerConfig{Port: 8089})
+	c.GetMapping("/users/:id", func(ctx web.Context) {
+		id, err := web.ParamInt(ctx, "id")
+		util.Panic(err).When(err != nil)
+		page, err := web.ParamInt(ctx, "page", 1)
+		util.Panic(err).When(err != nil)
+		verbose, err := web.ParamBool(ctx, "verbose", false)
+		util.Panic(err).When(err != nil)
+		ctx.String("id=%d page=%d verbose=%v", id, page, verbose)
+	})
+	c.GetMapping("/files/{*:path}", func(ctx web.Context) {
+		path, err := web.ParamString(ctx, "path")
+		util.Panic(err).When(err != nil)
+		ctx.String("path=%s", path)
+	})
+	c.PostMapping("/form", func(ctx web.Context) {
+		name, err := web.ParamString(ctx, "name")
+		util.Panic(err).When(err != nil)
+		size, err := web.ParamInt64(ctx, "size", 0)
+		util.Panic(err).When(err != nil)
+		ctx.String("name=%s size=%d", name, size)
+	})
+
+	go c.Start()
+	defer c.Stop(context.Background())
+	<-c.Started()
+	time.Sleep(10 * time.Millisecond)
+
+	host := "http://127.0.0.1:8089"
+	for _, d := range []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/users/42?page=2", http.StatusOK, "id=42 page=2 verbose=false"},
+		{"/users/42?verbose=true", http.StatusOK, "id=42 page=1 verbose=true"},
+		{"/users/abc", http.StatusBadRequest, `invalid parameter "id" value "abc"`},
+		{"/users/42?page=x", http.StatusBadRequest, `invalid parameter "page" value "x"`},
+		{"/files/a/b.txt", http.StatusOK, "path=a/b.txt"},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, host+d.path, nil)
+		code, body := do(t, req)
+		assert.Equal(t, code, d.code)
+		assert.Equal(t, body, d.body)
+	}
+
+	form := url.Values{"name": {"jim"}, "size": {"3"}}
+	req, _ := http.NewRequest(http.MethodPost, host+"/form", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	code, body := do(t, req)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "name=jim size=3")
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	_ = w.WriteField("name", "tom")
+	_ = w.Close()
+	req, _ = http.NewRequest(http.MethodPost, host+"/form", &buf)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	code, body = do(t, req)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "name=tom size=0")
+
+	req, _ = http.NewRequest(http.MethodPost, host+"/form", strings.NewReader("size=1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	code, body = do(t, req)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.Equal(t, body, `missing required parameter "name"`)
+
+	// 没有 boundary 的 multipart 请求体无法解析。
+	req, _ = http.NewRequest(http.MethodPost, host+"/form", strings.NewReader("name=tom"))
+	req.Header.Set("Content-Type", "multipart/form-data")
+	code, body = do(t, req)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.True(t, strings.HasPrefix(body, "invalid form: "), fmt.Sprintf("unexpected body %q", body))
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	DefaultPageSize = 20  // 没有指定 size 参数时的分页大小
+	MaxPageSize     = 100 // 分页大小的上限，超过时使用上限
+)
+
+// sortFieldRegex 排序字段只能由字母、数字、下划线和点组成，防止 SQL 注入。
+var sortFieldRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// Order 一个排序字段。
+type Order struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
+}
+
+// Sort 排序规则，按照顺序依次比较各个字段。
+type Sort []Order
+
+// String 返回 name desc, id 形式的字符串。
+func (s Sort) String() string {
+	var ss []string
+	for _, o := range s {
+		if o.Desc {
+			ss = append(ss, o.Field+" desc")
+		} else {
+			ss = append(ss, o.Field)
+		}
+	}
+	return strings.Join(ss, ", ")
+}
+
+// Pageable 分页参数，从 page、size、sort 查询参数解析，page 从 0 开始，例如
+// ?page=1&size=10&sort=name,desc&sort=id 。BIND 形式的处理函数的请求参数中包含
+// Pageable 类型的字段时自动填充该字段。
+type Pageable struct {
+	Page int  `json:"page" form:"-" query:"-"`
+	Size int  `json:"size" form:"-" query:"-"`
+	Sort Sort `json:"sort" form:"-" query:"-"`
+}
+
+// Offset 返回当前页第一条数据的偏移量。
+func (p Pageable) Offset() int {
+	return p.Page * p.Size
+}
+
+// GetPageable 从查询参数解析分页参数，size 超过 MaxPageSize 时使用 MaxPageSize 。
+func GetPageable(ctx Context) (Pageable, error) {
+
+	p := Pageable{Size: DefaultPageSize}
+
+	if s := ctx.QueryParam("page"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return Pageable{}, fmt.Errorf("invalid page %q", s)
+		}
+		p.Page = n
+	}
+
+	if s := ctx.QueryParam("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return Pageable{}, fmt.Errorf("invalid size %q", s)
+		}
+		p.Size = n
+	}
+	if p.Size > MaxPageSize {
+		p.Size = MaxPageSize
+	}
+
+	sort, err := ParseSort(ctx.QueryParams()["sort"]...)
+	if err != nil {
+		return Pageable{}, err
+	}
+	p.Sort = sort
+	return p, nil
+}
+
+// ParseSort 解析 field[,field...][,asc|desc] 形式的排序参数。
+func ParseSort(params ...string) (Sort, error) {
+	var sort Sort
+	for _, param := range params {
+		fields := strings.Split(param, ",")
+		desc := false
+		switch strings.ToLower(fields[len(fields)-1]) {
+		case "desc":
+			desc = true
+			fields = fields[:len(fields)-1]
+		case "asc":
+			fields = fields[:len(fields)-1]
+		}
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("invalid sort %q", param)
+		}
+		for _, f := range fields {
+			if !sortFieldRegex.MatchString(f) {
+				return nil, fmt.Errorf("invalid sort field %q", f)
+			}
+			sort = append(sort, Order{Field: f, Desc: desc})
+		}
+	}
+	return sort, nil
+}
+
+var pageableType = reflect.TypeOf(Pageable{})
+
+// bindPageable 填充 v 中 Pageable 类型的字段，包括嵌入的字段。
+func bindPageable(ctx Context, v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if f.Type() != pageableType || !f.CanSet() {
+			continue
+		}
+		p, err := GetPageable(ctx)
+		if err != nil {
+			return NewHttpError(http.StatusBadRequest, err.Error())
+		}
+		f.Set(reflect.ValueOf(p))
+	}
+	return nil
+}
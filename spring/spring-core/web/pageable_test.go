@@ -0,0 +1,102 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-base/knife"
+	"github.com/go-spring/spring-core/web"
+)
+
+func newContext(url string) *web.BaseContext {
+	ctx, _ := newRecordedContext(url)
+	return ctx
+}
+
+func newRecordedContext(url string) (*web.BaseContext, *httptest.ResponseRecorder) {
+	ctx, _ := knife.New(context.Background())
+	r := httptest.NewRequest(http.MethodGet, url, nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	return web.NewBaseContext("/users", nil, r, &web.BufferedResponseWriter{ResponseWriter: w}), w
+}
+
+func TestGetPageable(t *testing.T) {
+
+	p, err := web.GetPageable(newContext("/users"))
+	assert.Nil(t, err)
+	assert.Equal(t, p, web.Pageable{Size: web.DefaultPageSize})
+
+	p, err = web.GetPageable(newContext("/users?page=2&size=500&sort=name,desc&sort=age,id&sort=created_at,ASC"))
+	assert.Nil(t, err)
+	assert.Equal(t, p.Page, 2)
+	assert.Equal(t, p.Size, web.MaxPageSize)
+	assert.Equal(t, p.Offset(), 200)
+	assert.Equal(t, p.Sort.String(), "name desc, age, id, created_at")
+
+	testcases := []struct {
+		url string
+		err string
+	}{
+		{"/users?page=-1", `invalid page "-1"`},
+		{"/users?size=x", `invalid size "x"`},
+		{"/users?size=0", `invalid size "0"`},
+		{"/users?sort=desc", `invalid sort "desc"`},
+		{"/users?sort=name%20desc", `invalid sort field "name desc"`},
+	}
+	for _, c := range testcases {
+		_, err = web.GetPageable(newContext(c.url))
+		assert.Error(t, err, c.err)
+	}
+}
+
+type bindContext struct {
+	*web.BaseContext
+}
+
+func (ctx *bindContext) Bind(i interface{}) error {
+	return nil
+}
+
+func TestBindPageable(t *testing.T) {
+
+	type ListRequest struct {
+		web.Pageable
+		Name string
+	}
+
+	var req *ListRequest
+	h := web.BIND(func(ctx context.Context, r *ListRequest) interface{} {
+		req = r
+		return nil
+	})
+
+	h.Invoke(&bindContext{newContext("/users?page=1&size=10&sort=name,desc")})
+	assert.Equal(t, req.Pageable, web.Pageable{
+		Page: 1,
+		Size: 10,
+		Sort: web.Sort{{Field: "name", Desc: true}},
+	})
+
+	assert.Panic(t, func() {
+		h.Invoke(&bindContext{newContext("/users?page=x")})
+	}, `invalid page "x"`)
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Param 依次从路径参数、查询参数和表单参数中查找名为 name 的参数，第二个返回值表
+// 示参数是否存在。不同的 web 容器对路径参数和表单的处理方式不同，例如 gin 的通配符
+// 参数多一个 / ，而 echo 需要通过不同的方法读取，使用 Param 系列函数编写的处理函数
+// 在各个 web 容器上的行为一致。表单无法解析时返回错误，例如请求体不是合法的 multipart
+// 格式。
+func Param(ctx Context, name string) (string, bool, error) {
+
+	for i, n := range ctx.PathParamNames() {
+		if n == name {
+			return ctx.PathParamValues()[i], true, nil
+		}
+		if n == "*" { // 具名的通配符，例如 /files/{*:path}
+			if v := ctx.PathParam(name); v != "" {
+				return v, true, nil
+			}
+		}
+	}
+
+	if v, ok := ctx.QueryParams()[name]; ok && len(v) > 0 {
+		return v[0], true, nil
+	}
+
+	r := ctx.Request()
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || ctx.ContentType() == "" {
+		return "", false, nil
+	}
+
+	form, err := ctx.FormParams()
+	if err != nil {
+		var he *HttpError
+		if errors.As(err, &he) { // 如读取请求体时返回的 413 和 408 错误
+			return "", false, he
+		}
+		return "", false, NewHttpError(http.StatusBadRequest, fmt.Sprintf("invalid form: %s", err))
+	}
+	if v, ok := form[name]; ok && len(v) > 0 {
+		return v[0], true, nil
+	}
+	return "", false, nil
+}
+
+// param 返回参数的值，参数不存在并且没有默认值时返回 400 错误。
+func param(ctx Context, name string, hasDef bool) (string, bool, error) {
+	s, ok, err := Param(ctx, name)
+	if err != nil {
+		return "", false, err
+	}
+	if !ok && !hasDef {
+		return "", false, NewHttpError(http.StatusBadRequest, fmt.Sprintf("missing required parameter %q", name))
+	}
+	return s, ok, nil
+}
+
+func invalidParam(name string, s string) error {
+	return NewHttpError(http.StatusBadRequest, fmt.Sprintf("invalid parameter %q value %q", name, s))
+}
+
+// ParamString 返回字符串类型的参数，没有指定默认值时参数是必需的，参数不存在时返回
+// 400 错误，该错误可以直接 panic 交给错误处理接口。
+func ParamString(ctx Context, name string, def ...string) (string, error) {
+	s, ok, err := param(ctx, name, len(def) > 0)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return def[0], nil
+	}
+	return s, nil
+}
+
+// ParamInt 返回 int 类型的参数，没有指定默认值时参数是必需的，参数不存在或者格式
+// 错误时返回 400 错误。
+func ParamInt(ctx Context, name string, def ...int) (int, error) {
+	s, ok, err := param(ctx, name, len(def) > 0)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return def[0], nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, invalidParam(name, s)
+	}
+	return n, nil
+}
+
+// ParamInt64 返回 int64 类型的参数，规则同 ParamInt 。
+func ParamInt64(ctx Context, name string, def ...int64) (int64, error) {
+	s, ok, err := param(ctx, name, len(def) > 0)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return def[0], nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, invalidParam(name, s)
+	}
+	return n, nil
+}
+
+// ParamFloat64 返回 float64 类型的参数，规则同 ParamInt 。
+func ParamFloat64(ctx Context, name string, def ...float64) (float64, error) {
+	s, ok, err := param(ctx, name, len(def) > 0)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return def[0], nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, invalidParam(name, s)
+	}
+	return f, nil
+}
+
+// ParamBool 返回 bool 类型的参数，规则同 ParamInt 。
+func ParamBool(ctx Context, name string, def ...bool) (bool, error) {
+	s, ok, err := param(ctx, name, len(def) > 0)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return def[0], nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, invalidParam(name, s)
+	}
+	return b, nil
+}
+
+// ParamDuration 返回 time.Duration 类型的参数，如 10s ，规则同 ParamInt 。
+func ParamDuration(ctx Context, name string, def ...time.Duration) (time.Duration, error) {
+	s, ok, err := param(ctx, name, len(def) > 0)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return def[0], nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, invalidParam(name, s)
+	}
+	return d, nil
+}
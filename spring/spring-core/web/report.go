@@ -0,0 +1,37 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"github.com/go-spring/spring-core/report"
+)
+
+// ReportPanic 上报请求处理过程中的 panic ，附加请求的方法、路由、URL 和请求 ID
+// 等标签，需要在恢复过滤器 defer 的函数中调用。
+func ReportPanic(ctx Context, r interface{}) {
+	req := ctx.Request()
+	reqID := RequestID(ctx.Context())
+	if reqID == "" {
+		reqID = ctx.Header(HeaderXRequestID)
+	}
+	c := report.WithTags(ctx.Context(),
+		"http.method", req.Method,
+		"http.route", ctx.Path(),
+		"http.url", req.URL.String(),
+		"request_id", reqID)
+	report.Panic(c, report.SourceWeb, r)
+}
@@ -17,9 +17,23 @@
 package web
 
 import (
+	"context"
+
+	"github.com/go-spring/spring-base/knife"
+	"github.com/go-spring/spring-base/log"
 	"github.com/google/uuid"
 )
 
+const requestIDKey = "::requestID::"
+
+// maxRequestIDLength 请求 ID 的最大长度，超过该长度或者包含控制字符的请求 ID 会被
+// 重新生成，避免污染日志。
+const maxRequestIDLength = 128
+
+func init() {
+	log.RegisterContextField("request_id", RequestID)
+}
+
 type RequestIDConfig struct {
 	Header    string
 	Generator func() string
@@ -29,6 +43,9 @@ func NewRequestIDConfig() RequestIDConfig {
 	return RequestIDConfig{}
 }
 
+// NewRequestIDFilter 创建请求 ID 过滤器，请求中没有请求 ID 时生成新的请求 ID 并写回
+// 请求头，这样转发给下游服务的请求会携带相同的请求 ID 。请求 ID 被保存到上下文中并且
+// 通过响应头返回，使用 logger.WithContext 输出的日志会携带 request_id 字段。
 func NewRequestIDFilter(config RequestIDConfig) Filter {
 	if config.Header == "" {
 		config.Header = HeaderXRequestID
@@ -40,10 +57,35 @@ func NewRequestIDFilter(config RequestIDConfig) Filter {
 	}
 	return FuncFilter(func(ctx Context, chain FilterChain) {
 		reqID := ctx.Header(config.Header)
-		if reqID == "" {
+		if !validRequestID(reqID) {
 			reqID = config.Generator()
+			ctx.Request().Header.Set(config.Header, reqID)
+		}
+		if err := ctx.Set(requestIDKey, reqID); err != nil {
+			logger.WithContext(ctx.Context()).Error(log.ERROR, err)
 		}
-		ctx.SetHeader(HeaderXRequestID, reqID)
+		ctx.SetHeader(config.Header, reqID)
 		chain.Continue(ctx)
 	})
 }
+
+func validRequestID(s string) bool {
+	if s == "" || len(s) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID 返回 NewRequestIDFilter 保存在上下文中的请求 ID ，没有时返回空字符串。
+func RequestID(ctx context.Context) string {
+	v, err := knife.Load(ctx, requestIDKey)
+	if err != nil || v == nil {
+		return ""
+	}
+	return v.(string)
+}
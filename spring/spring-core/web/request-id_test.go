@@ -17,21 +17,56 @@
 package web_test
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-base/log"
 	"github.com/go-spring/spring-core/web"
 )
 
 func TestRequestIDFilter(t *testing.T) {
-	r, _ := http.NewRequest(http.MethodPost, "http://127.0.0.1:8080/", nil)
-	w := httptest.NewRecorder()
-	ctx := web.NewBaseContext("", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
-	f := web.NewRequestIDFilter(web.RequestIDConfig{
-		Generator: func() string { return "0d9ad123-327f-bde5-14b4-8f93c36c3546" },
+
+	run := func(reqID string) (*httptest.ResponseRecorder, web.Context, string) {
+		r, _ := http.NewRequest(http.MethodPost, "http://127.0.0.1:8080/", nil)
+		if reqID != "" {
+			r.Header.Set(web.HeaderXRequestID, reqID)
+		}
+		w := httptest.NewRecorder()
+		ctx := web.NewBaseContext("", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+		f := web.NewRequestIDFilter(web.RequestIDConfig{
+			Generator: func() string { return "0d9ad123-327f-bde5-14b4-8f93c36c3546" },
+		})
+		var got string
+		h := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+			got = web.RequestID(ctx.Context())
+		})
+		web.NewFilterChain([]web.Filter{f, h}).Next(ctx)
+		return w, ctx, got
+	}
+
+	t.Run("generate", func(t *testing.T) {
+		w, ctx, got := run("")
+		assert.Equal(t, got, "0d9ad123-327f-bde5-14b4-8f93c36c3546")
+		assert.Equal(t, w.Result().Header.Get(web.HeaderXRequestID), "0d9ad123-327f-bde5-14b4-8f93c36c3546")
+		assert.Equal(t, ctx.Header(web.HeaderXRequestID), "0d9ad123-327f-bde5-14b4-8f93c36c3546")
+		msg := log.NewMessageBuilder().WithContext(ctx.Context()).Build()
+		assert.Equal(t, msg.Fields(), []string{"request_id=0d9ad123-327f-bde5-14b4-8f93c36c3546"})
+	})
+
+	t.Run("propagate", func(t *testing.T) {
+		w, _, got := run("abc")
+		assert.Equal(t, got, "abc")
+		assert.Equal(t, w.Result().Header.Get(web.HeaderXRequestID), "abc")
 	})
-	web.NewFilterChain([]web.Filter{f}).Next(ctx)
-	assert.Equal(t, w.Result().Header.Get(web.HeaderXRequestID), "0d9ad123-327f-bde5-14b4-8f93c36c3546")
+
+	t.Run("invalid", func(t *testing.T) {
+		_, _, got := run(strings.Repeat("a", 129))
+		assert.Equal(t, got, "0d9ad123-327f-bde5-14b4-8f93c36c3546")
+	})
+
+	assert.Equal(t, web.RequestID(context.Background()), "")
 }
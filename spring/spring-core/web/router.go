@@ -18,6 +18,8 @@ package web
 
 import (
 	"net/http"
+
+	"github.com/go-spring/spring-core/jsonschema"
 )
 
 const (
@@ -62,6 +64,7 @@ type Mapper struct {
 	path    string    // 路由地址
 	handler Handler   // 处理函数
 	swagger Operation // 描述文档
+	policy  string    // 韧性策略
 }
 
 // NewMapper Mapper 的构造函数
@@ -89,6 +92,23 @@ func (m *Mapper) Operation(op Operation) {
 	m.swagger = op
 }
 
+// WithPolicy 设置路由使用的韧性策略，策略在 resilience.policies 属性中定义
+func (m *Mapper) WithPolicy(name string) *Mapper {
+	m.policy = name
+	return m
+}
+
+// Policy 返回路由使用的韧性策略
+func (m *Mapper) Policy() string {
+	return m.policy
+}
+
+// WithSchema 在调用处理函数之前使用 JSON Schema 校验请求体，不满足时返回 400 错误
+func (m *Mapper) WithSchema(schema *jsonschema.Schema) *Mapper {
+	m.handler = &schemaHandler{Handler: m.handler, schema: schema}
+	return m
+}
+
 // Router 路由注册接口
 type Router interface {
 
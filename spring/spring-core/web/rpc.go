@@ -19,17 +19,21 @@ package web
 import (
 	"context"
 	"errors"
+	"net/http"
 	"reflect"
 
 	"github.com/go-spring/spring-base/knife"
 	"github.com/go-spring/spring-base/log"
 	"github.com/go-spring/spring-base/util"
+	"github.com/go-spring/spring-core/validator"
 )
 
 const (
 	ctxKey = "::request::"
 )
 
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
 // bindHandler BIND 形式的 Web 处理接口
 type bindHandler struct {
 	fn       interface{}
@@ -41,21 +45,69 @@ type bindHandler struct {
 func (b *bindHandler) Invoke(ctx Context) {
 	err := knife.Store(ctx.Context(), ctxKey, ctx)
 	util.Panic(err).When(err != nil)
+
+	// 经过响应体过滤器时由框架包装返回值和错误
+	if config := envelopeConfig(ctx); config != nil {
+		bindVal, err := b.bind(ctx)
+		if err != nil {
+			var httpErr *HttpError
+			if !errors.As(err, &httpErr) {
+				err = NewHttpError(http.StatusBadRequest, err.Error()).SetInternal(err)
+			}
+			writeEnvelope(ctx, config, nil, err)
+			return
+		}
+		data, err := b.invoke(ctx, bindVal)
+		writeEnvelope(ctx, config, data, err)
+		return
+	}
+
+	if c, mediaType := responseCodec(ctx, b.fnType.Out(0)); c != nil {
+		writeCodec(ctx, c, mediaType, b.call(ctx))
+		return
+	}
 	RpcInvoke(ctx, b.call)
 }
 
 func (b *bindHandler) call(ctx Context) interface{} {
+	bindVal, err := b.bind(ctx)
+	if err != nil {
+		panic(err)
+	}
+	data, err := b.invoke(ctx, bindVal)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
 
-	// 反射创建需要绑定请求参数
+// bind 反射创建并绑定请求参数
+func (b *bindHandler) bind(ctx Context) (reflect.Value, error) {
 	bindVal := reflect.New(b.bindType.Elem())
-	if err := ctx.Bind(bindVal.Interface()); err != nil {
-		panic(err)
+	if c := requestCodec(ctx, b.bindType); c != nil {
+		if err := bindCodec(ctx, c, bindVal.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+		if err := validator.Validate(bindVal.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+	} else if err := ctx.Bind(bindVal.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+	if err := bindPageable(ctx, bindVal.Elem()); err != nil {
+		return reflect.Value{}, err
 	}
+	return bindVal, nil
+}
 
-	// 执行处理函数，并返回结果
+// invoke 执行处理函数，并返回结果
+func (b *bindHandler) invoke(ctx Context, bindVal reflect.Value) (interface{}, error) {
 	ctxVal := reflect.ValueOf(ctx.Request().Context())
-	in := []reflect.Value{ctxVal, bindVal}
-	return b.fnValue.Call(in)[0].Interface()
+	out := b.fnValue.Call([]reflect.Value{ctxVal, bindVal})
+	if len(out) == 2 && !out[1].IsNil() {
+		return nil, out[1].Interface().(error)
+	}
+	return out[0].Interface(), nil
 }
 
 func (b *bindHandler) FileLine() (file string, line int, fnName string) {
@@ -64,8 +116,17 @@ func (b *bindHandler) FileLine() (file string, line int, fnName string) {
 
 func validBindFn(fnType reflect.Type) bool {
 
-	// 必须是函数，必须有两个入参，必须有一个返回值
-	if fnType.Kind() != reflect.Func || fnType.NumIn() != 2 || fnType.NumOut() != 1 {
+	// 必须是函数，必须有两个入参，必须有一个返回值或者返回值和 error
+	if fnType.Kind() != reflect.Func || fnType.NumIn() != 2 {
+		return false
+	}
+	switch fnType.NumOut() {
+	case 1:
+	case 2:
+		if fnType.Out(1) != errorType {
+			return false
+		}
+	default:
 		return false
 	}
 
@@ -88,7 +149,7 @@ func BIND(fn interface{}) Handler {
 			bindType: fnType.In(1),
 		}
 	}
-	panic(errors.New("fn should be func(context.Context, *struct})anything"))
+	panic(errors.New("fn should be func(context.Context, *struct})(anything[, error])"))
 }
 
 // GetRequest 获取 ctx 对象上绑定的 web.Context 对象。
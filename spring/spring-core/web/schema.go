@@ -0,0 +1,82 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/go-spring/spring-core/jsonschema"
+)
+
+// SchemaError 请求体不满足 JSON Schema 时的响应体。
+type SchemaError struct {
+	Message    string                 `json:"message"`
+	Violations []jsonschema.Violation `json:"violations,omitempty"`
+}
+
+// schemaHandler 在调用处理函数之前使用 JSON Schema 校验请求体。
+type schemaHandler struct {
+	Handler
+	schema *jsonschema.Schema
+}
+
+func (h *schemaHandler) Invoke(ctx Context) {
+	if err := validateBody(ctx.Request(), h.schema); err != nil {
+		writeSchemaError(ctx, err)
+		return
+	}
+	h.Handler.Invoke(ctx)
+}
+
+// validateBody 校验请求体，请求体会被重新放回请求中，空的请求体按照 null 校验。
+func validateBody(r *http.Request, schema *jsonschema.Schema) error {
+	var body []byte
+	if r.Body != nil {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		body = b
+		r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return schema.Validate(nil)
+	}
+	return schema.ValidateJSON(body)
+}
+
+// writeSchemaError 返回 400 错误，经过响应体过滤器时 Violation 列表放在 Envelope 的
+// data 字段中。
+func writeSchemaError(ctx Context, err error) {
+	resp := &SchemaError{Message: "request body does not match the schema"}
+	var e *jsonschema.ValidationError
+	if errors.As(err, &e) {
+		resp.Violations = e.Violations
+	} else {
+		resp.Message = "invalid request body: " + err.Error()
+	}
+	ctx.SetContentType(MIMEApplicationJSONCharsetUTF8)
+	ctx.SetStatus(http.StatusBadRequest)
+	if envelopeConfig(ctx) != nil {
+		ctx.JSON(&Envelope{Code: http.StatusBadRequest, Message: resp.Message, Data: resp.Violations})
+		return
+	}
+	ctx.JSON(resp)
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/jsonschema"
+	"github.com/go-spring/spring-core/web"
+)
+
+type jsonBindContext struct {
+	*web.BaseContext
+}
+
+func (ctx *jsonBindContext) Bind(i interface{}) error {
+	b, err := ctx.RequestBody()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, i)
+}
+
+func TestMapper_WithSchema(t *testing.T) {
+
+	type CreateUserReq struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	schema := jsonschema.MustCompile(`{
+		"type": "object",
+		"required": ["name"],
+		"properties": {
+			"name": {"type": "string", "minLength": 1},
+			"age": {"type": "integer", "minimum": 0}
+		}
+	}`)
+
+	fn := func(ctx context.Context, req *CreateUserReq) *CreateUserReq {
+		return req
+	}
+	m := web.NewMapper(web.MethodPost, "/users", web.BIND(fn)).WithSchema(schema)
+	filter := web.NewEnvelopeFilter(web.NewEnvelopeConfig())
+
+	testcases := []struct {
+		envelope bool
+		body     string
+		status   int
+		expect   string
+	}{
+		{
+			body:   `{"name":"jim","age":3}`,
+			status: http.StatusOK,
+			expect: `{"name":"jim","age":3}`,
+		},
+		{
+			body:   `{"age":-1}`,
+			status: http.StatusBadRequest,
+			expect: `{"message":"request body does not match the schema","violations":[{"path":"","keyword":"required","message":"missing property \"name\""},{"path":"/age","keyword":"minimum","message":"value must be \u003e= 0"}]}`,
+		},
+		{
+			body:   `{"name":`,
+			status: http.StatusBadRequest,
+			expect: `{"message":"invalid request body: unexpected EOF"}`,
+		},
+		{
+			envelope: true,
+			body:     `{"name":""}`,
+			status:   http.StatusBadRequest,
+			expect:   `{"code":400,"message":"request body does not match the schema","data":[{"path":"/name","keyword":"minLength","message":"length must be \u003e= 1 but got 0"}]}`,
+		},
+	}
+
+	for i, c := range testcases {
+		r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(c.body))
+		w := httptest.NewRecorder()
+		ctx := &jsonBindContext{web.NewBaseContext("/users", m.Handler(), r, &web.BufferedResponseWriter{ResponseWriter: w})}
+		filters := []web.Filter{web.HandlerFilter(m.Handler())}
+		if c.envelope {
+			filters = append([]web.Filter{filter}, filters...)
+		}
+		web.NewFilterChain(filters).Next(ctx)
+		assert.Equal(t, w.Code, c.status, strconv.Itoa(i))
+		assert.Equal(t, strings.TrimSpace(w.Body.String()), c.expect, strconv.Itoa(i))
+	}
+}
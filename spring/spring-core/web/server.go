@@ -19,7 +19,10 @@ package web
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"reflect"
 	"time"
@@ -85,9 +88,15 @@ type Server interface {
 	// Swagger 设置与服务器绑定的 Swagger 对象
 	Swagger(swagger Swagger)
 
+	// SetTLSConfig 设置 HTTPS 使用的 TLS 配置，优先于配置的证书文件
+	SetTLSConfig(config *tls.Config)
+
 	// Start 启动 web 服务器
 	Start() error
 
+	// Started 返回的 channel 在服务器开始监听端口之后关闭
+	Started() <-chan struct{}
+
 	// Stop 停止 web 服务器
 	Stop(ctx context.Context) error
 }
@@ -110,12 +119,15 @@ type server struct {
 	prefilters []*Prefilter // 前置过滤器
 	errHandler ErrorHandler // 错误处理接口
 
-	swagger Swagger // Swagger根
+	swagger Swagger     // Swagger根
+	tls     *tls.Config // HTTPS 配置
+
+	started chan struct{} // 开始监听端口之后关闭
 }
 
 // NewServer server 的构造函数
 func NewServer(config ServerConfig, handler ServerHandler) *server {
-	return &server{config: config, handler: handler}
+	return &server{config: config, handler: handler, started: make(chan struct{})}
 }
 
 // Address 返回监听地址
@@ -227,21 +239,42 @@ func (s *server) Start() (err error) {
 		return err
 	}
 	s.server = &http.Server{
-		Handler:      s,
-		Addr:         s.Address(),
-		ReadTimeout:  time.Duration(s.config.ReadTimeout) * time.Millisecond,
-		WriteTimeout: time.Duration(s.config.WriteTimeout) * time.Millisecond,
+		Handler:           s,
+		Addr:              s.Address(),
+		ReadTimeout:       time.Duration(s.config.ReadTimeout) * time.Millisecond,
+		WriteTimeout:      time.Duration(s.config.WriteTimeout) * time.Millisecond,
+		ReadHeaderTimeout: time.Duration(s.config.ReadHeaderTimeout) * time.Millisecond,
+		IdleTimeout:       time.Duration(s.config.IdleTimeout) * time.Millisecond,
+		MaxHeaderBytes:    int(s.config.MaxHeaderBytes),
+		TLSConfig:         s.tls,
+	}
+	listener, err := net.Listen("tcp", s.Address())
+	if err != nil {
+		return err
 	}
+	close(s.started)
 	logger.Info("⇨ http server started on ", s.Address())
 	if !s.config.EnableSSL {
-		err = s.server.ListenAndServe()
+		err = s.server.Serve(listener)
+	} else if s.tls != nil {
+		err = s.server.ServeTLS(listener, "", "")
 	} else {
-		err = s.server.ListenAndServeTLS(s.config.CertFile, s.config.KeyFile)
+		err = s.server.ServeTLS(listener, s.config.CertFile, s.config.KeyFile)
 	}
 	logger.Infof("http server stopped on %s return %s", s.Address(), cast.ToString(err))
 	return err
 }
 
+// SetTLSConfig 设置 HTTPS 使用的 TLS 配置，优先于配置的证书文件
+func (s *server) SetTLSConfig(config *tls.Config) {
+	s.tls = config
+}
+
+// Started 返回的 channel 在服务器开始监听端口之后关闭
+func (s *server) Started() <-chan struct{} {
+	return s.started
+}
+
 // Stop 停止 web 服务器
 func (s *server) Stop(ctx context.Context) error {
 	return s.server.Shutdown(ctx)
@@ -258,6 +291,14 @@ func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		errHandler = defaultErrorHandler
 	}
 	prefilters = append(prefilters, s.handler.RecoveryFilter(errHandler))
+	if r.Body != nil && r.Body != http.NoBody {
+		if max := int64(s.config.MaxBodySize); max > 0 && r.ContentLength > max {
+			prefilters = append(prefilters, FuncFilter(func(ctx Context, chain FilterChain) {
+				panic(NewHttpError(http.StatusRequestEntityTooLarge))
+			}))
+		}
+		r.Body = &requestBody{ReadCloser: r.Body, max: int64(s.config.MaxBodySize)}
+	}
 	for _, f := range s.Prefilters() {
 		prefilters = append(prefilters, f)
 	}
@@ -265,6 +306,34 @@ func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	NewFilterChain(prefilters).Next(NewBaseContext("", nil, r, writer))
 }
 
+// requestBody 限制请求体的大小，超出限制时返回 413 错误，读取超时时返回 408 错误，
+// 这些错误经由恢复过滤器交给统一的错误处理接口。
+type requestBody struct {
+	io.ReadCloser
+	max  int64 // 为 0 时不限制
+	read int64
+}
+
+func (b *requestBody) Read(p []byte) (n int, err error) {
+	if b.max > 0 {
+		if b.read > b.max {
+			return 0, NewHttpError(http.StatusRequestEntityTooLarge)
+		}
+		if remain := b.max - b.read + 1; int64(len(p)) > remain {
+			p = p[:remain]
+		}
+	}
+	n, err = b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.max > 0 && b.read > b.max {
+		return n - int(b.read-b.max), NewHttpError(http.StatusRequestEntityTooLarge)
+	}
+	if e, ok := err.(net.Error); ok && e.Timeout() {
+		err = NewHttpError(http.StatusRequestTimeout)
+	}
+	return n, err
+}
+
 /////////////////// Web Handlers //////////////////////
 
 // fnHandler 封装 Web 处理函数
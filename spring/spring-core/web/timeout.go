@@ -0,0 +1,93 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TimeoutConfig 请求超时配置，Routes 的键为请求路径或者注册的路由路径，例如
+// web.timeout./slow-endpoint=5s ，键 default 表示其他请求的超时时间。
+type TimeoutConfig struct {
+	Routes       map[string]time.Duration `value:"${web.timeout:=}"`
+	ErrorHandler ErrorHandler             // 为 nil 时使用默认的错误处理接口
+}
+
+func NewTimeoutConfig() TimeoutConfig {
+	return TimeoutConfig{}
+}
+
+func (config *TimeoutConfig) timeout(ctx Context) time.Duration {
+	if d, ok := config.Routes[ctx.Request().URL.Path]; ok {
+		return d
+	}
+	if d, ok := config.Routes[ctx.Path()]; ok {
+		return d
+	}
+	var (
+		prefix string
+		d      time.Duration
+	)
+	for k, v := range config.Routes {
+		if strings.HasSuffix(k, "/*") {
+			p := strings.TrimSuffix(k, "*")
+			if strings.HasPrefix(ctx.Request().URL.Path, p) && len(p) > len(prefix) {
+				prefix, d = p, v
+			}
+		}
+	}
+	if prefix != "" {
+		return d
+	}
+	return config.Routes["default"]
+}
+
+// NewTimeoutFilter 创建为请求设置超时时间的过滤器。处理函数和下游调用应该使用
+// ctx.Context() 以便在超时的时候被取消，超时并且还没有写入响应时返回 504 错误。
+// 路径以 /* 结尾时按照前缀匹配，匹配多个前缀时使用最长的那个。注意，过滤器不会在
+// 超时的时候中断处理函数，504 错误在处理函数返回之后才会写入，因此不检查 ctx 是否
+// 取消的处理函数仍然会让客户端等待到它执行结束。
+func NewTimeoutFilter(config TimeoutConfig) Filter {
+	errHandler := config.ErrorHandler
+	if errHandler == nil {
+		errHandler = defaultErrorHandler
+	}
+	return FuncFilter(func(ctx Context, chain FilterChain) {
+
+		d := config.timeout(ctx)
+		if d <= 0 {
+			chain.Continue(ctx)
+			return
+		}
+
+		c, cancel := context.WithTimeout(ctx.Context(), d)
+		defer cancel()
+		ctx.SetContext(c)
+
+		chain.Next(ctx)
+
+		if c.Err() != context.DeadlineExceeded {
+			return
+		}
+		if w := ctx.ResponseWriter(); w.Status() == 0 && w.Size() == 0 {
+			errHandler.Invoke(ctx, NewHttpError(http.StatusGatewayTimeout))
+		}
+	})
+}
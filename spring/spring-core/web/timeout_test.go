@@ -0,0 +1,64 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/web"
+)
+
+func TestTimeoutFilter(t *testing.T) {
+
+	p, err := conf.Bytes([]byte("web.timeout./slow=20ms\nweb.timeout./api/*=1h\nweb.timeout.default=1h"), ".properties")
+	assert.Nil(t, err)
+	config := web.NewTimeoutConfig()
+	err = p.Bind(&config)
+	assert.Nil(t, err)
+	assert.Equal(t, config.Routes["/slow"], 20*time.Millisecond)
+
+	f := web.NewTimeoutFilter(config)
+	handler := web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		deadline, ok := ctx.Context().Deadline()
+		assert.True(t, ok)
+		if time.Until(deadline) < time.Second {
+			<-ctx.Context().Done()
+			return
+		}
+		ctx.String("ok")
+	})
+
+	for _, c := range []struct {
+		path   string
+		status int
+	}{
+		{"/slow", http.StatusGatewayTimeout},
+		{"/api/users", http.StatusOK},
+		{"/fast", http.StatusOK},
+	} {
+		r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:8080"+c.path, nil)
+		w := httptest.NewRecorder()
+		ctx := web.NewBaseContext("", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+		web.NewFilterChain([]web.Filter{f, handler}).Next(ctx)
+		assert.Equal(t, w.Code, c.status)
+	}
+}
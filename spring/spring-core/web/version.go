@@ -0,0 +1,203 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// API 版本的解析方式。
+const (
+	PathVersioning   = "path"   // 路径前缀，如 /v1/users
+	HeaderVersioning = "header" // 请求头，如 X-API-Version: v1
+	QueryVersioning  = "query"  // 查询参数，如 /users?version=v1
+)
+
+// 弃用相关的响应头，参见 RFC 8594 。
+const (
+	HeaderDeprecation = "Deprecation"
+	HeaderSunset      = "Sunset"
+	HeaderLink        = "Link"
+)
+
+type VersionConfig struct {
+	Strategy string // 版本的解析方式，默认为 PathVersioning
+	Name     string // 请求头或者查询参数的名称
+	Default  string // 请求中没有携带版本时使用的版本，为空时拒绝该请求
+}
+
+func NewVersionConfig() VersionConfig {
+	return VersionConfig{Strategy: PathVersioning}
+}
+
+// VersionRouter 按照 API 版本注册处理函数，使得多个版本的接口可以同时存在。
+type VersionRouter struct {
+	router     Router
+	config     VersionConfig
+	dispatcher map[string]*versionDispatcher
+}
+
+// NewVersionRouter VersionRouter 的构造函数
+func NewVersionRouter(router Router, config VersionConfig) *VersionRouter {
+	if config.Strategy == "" {
+		config.Strategy = PathVersioning
+	}
+	if config.Name == "" {
+		switch config.Strategy {
+		case HeaderVersioning:
+			config.Name = "X-API-Version"
+		case QueryVersioning:
+			config.Name = "version"
+		}
+	}
+	return &VersionRouter{
+		router:     router,
+		config:     config,
+		dispatcher: make(map[string]*versionDispatcher),
+	}
+}
+
+// Version 返回指定版本的路由注册器。
+func (r *VersionRouter) Version(version string) *VersionRoutes {
+	return &VersionRoutes{router: r, version: version}
+}
+
+// VersionRoutes 某个 API 版本的路由注册器。
+type VersionRoutes struct {
+	router  *VersionRouter
+	version string
+	sunset  time.Time
+	link    string
+	deprec  bool
+}
+
+// Deprecated 将该版本标记为已弃用，响应中会自动添加 Deprecation 响应头，sunset
+// 不为零值时添加 Sunset 响应头，link 不为空时添加指向迁移文档的 Link 响应头。
+// 注意，需要在注册处理函数之前调用。
+func (v *VersionRoutes) Deprecated(sunset time.Time, link string) *VersionRoutes {
+	v.deprec = true
+	v.sunset = sunset
+	v.link = link
+	return v
+}
+
+// HandleRequest 注册任意 HTTP 方法处理函数
+func (v *VersionRoutes) HandleRequest(method uint32, path string, h Handler) *Mapper {
+	h = &versionHandler{Handler: h, routes: v}
+	r := v.router
+	if r.config.Strategy == PathVersioning {
+		return r.router.HandleRequest(method, "/"+v.version+path, h)
+	}
+	key := fmt.Sprintf("%d:%s", method, path)
+	d, ok := r.dispatcher[key]
+	if !ok {
+		d = &versionDispatcher{config: r.config, handlers: make(map[string]Handler)}
+		d.mapper = r.router.HandleRequest(method, path, d)
+		r.dispatcher[key] = d
+	}
+	d.handlers[v.version] = h
+	return d.mapper
+}
+
+// RequestMapping 注册任意 HTTP 方法处理函数
+func (v *VersionRoutes) RequestMapping(method uint32, path string, fn HandlerFunc) *Mapper {
+	return v.HandleRequest(method, path, FUNC(fn))
+}
+
+// RequestBinding 注册任意 HTTP 方法处理函数
+func (v *VersionRoutes) RequestBinding(method uint32, path string, fn interface{}) *Mapper {
+	return v.HandleRequest(method, path, BIND(fn))
+}
+
+// GetMapping 注册 GET 方法处理函数
+func (v *VersionRoutes) GetMapping(path string, fn HandlerFunc) *Mapper {
+	return v.HandleRequest(MethodGet, path, FUNC(fn))
+}
+
+// PostMapping 注册 POST 方法处理函数
+func (v *VersionRoutes) PostMapping(path string, fn HandlerFunc) *Mapper {
+	return v.HandleRequest(MethodPost, path, FUNC(fn))
+}
+
+// PutMapping 注册 PUT 方法处理函数
+func (v *VersionRoutes) PutMapping(path string, fn HandlerFunc) *Mapper {
+	return v.HandleRequest(MethodPut, path, FUNC(fn))
+}
+
+// DeleteMapping 注册 DELETE 方法处理函数
+func (v *VersionRoutes) DeleteMapping(path string, fn HandlerFunc) *Mapper {
+	return v.HandleRequest(MethodDelete, path, FUNC(fn))
+}
+
+// versionHandler 为已弃用版本的响应添加弃用相关的响应头。
+type versionHandler struct {
+	Handler
+	routes *VersionRoutes
+}
+
+func (h *versionHandler) Invoke(ctx Context) {
+	if v := h.routes; v.deprec {
+		ctx.SetHeader(HeaderDeprecation, "true")
+		if !v.sunset.IsZero() {
+			ctx.SetHeader(HeaderSunset, v.sunset.UTC().Format(http.TimeFormat))
+		}
+		if v.link != "" {
+			ctx.SetHeader(HeaderLink, fmt.Sprintf("<%s>; rel=\"deprecation\"", v.link))
+		}
+	}
+	h.Handler.Invoke(ctx)
+}
+
+// versionDispatcher 根据请求头或者查询参数中的版本选择处理函数。
+type versionDispatcher struct {
+	config   VersionConfig
+	mapper   *Mapper
+	handlers map[string]Handler
+}
+
+func (d *versionDispatcher) Invoke(ctx Context) {
+	var version string
+	if d.config.Strategy == HeaderVersioning {
+		version = ctx.Header(d.config.Name)
+	} else {
+		version = ctx.QueryParam(d.config.Name)
+	}
+	version = strings.TrimSpace(version)
+	if version == "" {
+		version = d.config.Default
+	}
+	h, ok := d.handlers[version]
+	if !ok {
+		ctx.SetStatus(http.StatusBadRequest)
+		ctx.String("unsupported api version %q", version)
+		return
+	}
+	h.Invoke(ctx)
+}
+
+func (d *versionDispatcher) FileLine() (file string, line int, fnName string) {
+	if h, ok := d.handlers[d.config.Default]; ok {
+		return h.FileLine()
+	}
+	for _, h := range d.handlers {
+		return h.FileLine()
+	}
+	return "", 0, ""
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/web"
+)
+
+func invoke(m *web.Mapper, url string, header http.Header) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest(http.MethodGet, url, nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	ctx := web.NewBaseContext(m.Path(), m.Handler(), r, &web.BufferedResponseWriter{ResponseWriter: w})
+	m.Handler().Invoke(ctx)
+	return w
+}
+
+func TestVersionRouter(t *testing.T) {
+
+	sunset := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("path", func(t *testing.T) {
+		router := web.NewRouter()
+		v := web.NewVersionRouter(router, web.NewVersionConfig())
+		v.Version("v1").Deprecated(sunset, "https://example.com/migrate").GetMapping("/users", func(ctx web.Context) { ctx.String("v1") })
+		v.Version("v2").GetMapping("/users", func(ctx web.Context) { ctx.String("v2") })
+
+		mappers := router.Mappers()
+		assert.Equal(t, len(mappers), 2)
+		assert.Equal(t, mappers[0].Path(), "/v1/users")
+		assert.Equal(t, mappers[1].Path(), "/v2/users")
+
+		w := invoke(mappers[0], "http://127.0.0.1/v1/users", nil)
+		assert.Equal(t, w.Body.String(), "v1")
+		assert.Equal(t, w.Header().Get(web.HeaderDeprecation), "true")
+		assert.Equal(t, w.Header().Get(web.HeaderSunset), "Tue, 01 Jan 2030 00:00:00 GMT")
+		assert.Equal(t, w.Header().Get(web.HeaderLink), `<https://example.com/migrate>; rel="deprecation"`)
+
+		w = invoke(mappers[1], "http://127.0.0.1/v2/users", nil)
+		assert.Equal(t, w.Body.String(), "v2")
+		assert.Equal(t, w.Header().Get(web.HeaderDeprecation), "")
+	})
+
+	t.Run("header", func(t *testing.T) {
+		router := web.NewRouter()
+		v := web.NewVersionRouter(router, web.VersionConfig{Strategy: web.HeaderVersioning, Default: "v2"})
+		v.Version("v1").Deprecated(time.Time{}, "").GetMapping("/users", func(ctx web.Context) { ctx.String("v1") })
+		v.Version("v2").GetMapping("/users", func(ctx web.Context) { ctx.String("v2") })
+
+		mappers := router.Mappers()
+		assert.Equal(t, len(mappers), 1)
+		assert.Equal(t, mappers[0].Path(), "/users")
+
+		w := invoke(mappers[0], "http://127.0.0.1/users", http.Header{"X-Api-Version": {"v1"}})
+		assert.Equal(t, w.Body.String(), "v1")
+		assert.Equal(t, w.Header().Get(web.HeaderDeprecation), "true")
+		assert.Equal(t, w.Header().Get(web.HeaderSunset), "")
+
+		w = invoke(mappers[0], "http://127.0.0.1/users", nil)
+		assert.Equal(t, w.Body.String(), "v2")
+
+		w = invoke(mappers[0], "http://127.0.0.1/users", http.Header{"X-Api-Version": {"v3"}})
+		assert.Equal(t, w.Code, http.StatusBadRequest)
+	})
+
+	t.Run("query", func(t *testing.T) {
+		router := web.NewRouter()
+		v := web.NewVersionRouter(router, web.VersionConfig{Strategy: web.QueryVersioning})
+		v.Version("1").GetMapping("/users", func(ctx web.Context) { ctx.String("v1") })
+		v.Version("2").GetMapping("/users", func(ctx web.Context) { ctx.String("v2") })
+
+		m := router.Mappers()[0]
+		w := invoke(m, "http://127.0.0.1/users?version=2", nil)
+		assert.Equal(t, w.Body.String(), "v2")
+
+		w = invoke(m, "http://127.0.0.1/users", nil)
+		assert.Equal(t, w.Code, http.StatusBadRequest)
+	})
+}
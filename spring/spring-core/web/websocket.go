@@ -0,0 +1,504 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket 消息的类型，取值和 RFC 6455 的操作码相同。
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// 关闭 WebSocket 连接的状态码。
+const (
+	CloseNormal        = 1000
+	CloseGoingAway     = 1001
+	CloseProtocolError = 1002
+	CloseInvalidData   = 1007
+	CloseMessageTooBig = 1009
+
+	// CloseNoStatusReceived 对端的关闭帧没有携带状态码，只用于 CloseError ，
+	// 不能出现在发送的关闭帧中。
+	CloseNoStatusReceived = 1005
+)
+
+// DefaultMaxMessageSize 读取的 WebSocket 消息默认的最大字节数。
+const DefaultMaxMessageSize = 1 << 20
+
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var (
+	ErrBadHandshake    = errors.New("web: websocket bad handshake")
+	ErrMessageTooLarge = errors.New("web: websocket message too large")
+	ErrProtocol        = errors.New("web: websocket protocol error")
+	errCloseSent       = errors.New("web: websocket close frame already sent")
+)
+
+// CloseError 对端发送了关闭帧。
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("web: websocket closed with code %d %s", e.Code, e.Text)
+}
+
+// WebSocketConfig WebSocket 升级的配置。
+type WebSocketConfig struct {
+	MaxMessageSize int64    `value:"${max-message-size:=1048576}"` // 读取的消息的最大字节数，超过时以 1009 关闭连接
+	Subprotocols   []string `value:"${subprotocols:=}"`            // 支持的子协议，选择客户端请求的第一个支持的子协议
+
+	// CheckOrigin 检查请求的来源，为 nil 时只允许没有 Origin 或者 Origin 的主机
+	// 和请求的主机相同的请求，避免跨站的 WebSocket 劫持。
+	CheckOrigin func(r *http.Request) bool
+}
+
+// WebSocket 实现了 RFC 6455 的基本功能，包括文本和二进制消息、分片消息、ping/pong
+// 以及关闭握手，不支持压缩等扩展。ReadMessage 只能在一个 goroutine 中调用，其他方法
+// 可以并发调用。
+type WebSocket struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	client      bool // 客户端发送的帧需要加掩码
+	maxSize     int64
+	subprotocol string
+	mutex       sync.Mutex
+	closeSent   bool
+	onPong      func(data []byte)
+}
+
+// UpgradeWebSocket 将请求升级为 WebSocket 连接，握手失败时向客户端返回错误的响应，
+// 并且返回包装了 ErrBadHandshake 的错误。升级之后不能再使用 ctx 写响应。
+func UpgradeWebSocket(ctx Context, config WebSocketConfig) (*WebSocket, error) {
+
+	r := ctx.Request()
+	fail := func(status int, reason string) (*WebSocket, error) {
+		ctx.SetStatus(status)
+		ctx.String(reason)
+		return nil, fmt.Errorf("%w: %s", ErrBadHandshake, reason)
+	}
+
+	if r.Method != http.MethodGet {
+		return fail(http.StatusMethodNotAllowed, "websocket handshake requires GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, HeaderUpgrade, "websocket") {
+		return fail(http.StatusBadRequest, "not a websocket upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		ctx.SetHeader("Sec-WebSocket-Version", "13")
+		return fail(http.StatusUpgradeRequired, "unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+		return fail(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+	checkOrigin := config.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return fail(http.StatusForbidden, "origin not allowed")
+	}
+
+	var subprotocol string
+	for _, p := range headerTokens(r.Header, "Sec-WebSocket-Protocol") {
+		for _, s := range config.Subprotocols {
+			if p == s && subprotocol == "" {
+				subprotocol = s
+			}
+		}
+	}
+
+	h, ok := ctx.ResponseWriter().(http.Hijacker)
+	if !ok {
+		return fail(http.StatusInternalServerError, "websocket is not supported")
+	}
+	conn, rw, err := h.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	if rw.Reader.Buffered() > 0 {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%w: client sent data before handshake", ErrBadHandshake)
+	}
+
+	resp := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n"
+	if subprotocol != "" {
+		resp += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+	}
+	if _, err = conn.Write([]byte(resp + "\r\n")); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return newWebSocket(conn, rw.Reader, false, int64(config.MaxMessageSize), subprotocol), nil
+}
+
+// DialWebSocket 作为客户端连接 ws:// 或者 wss:// 地址，用于服务之间的推送以及测试。
+func DialWebSocket(rawURL string, header http.Header) (*WebSocket, error) {
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	host := u.Host
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		if u.Port() == "" {
+			host += ":80"
+		}
+		conn, err = net.Dial("tcp", host)
+	case "wss":
+		if u.Port() == "" {
+			host += ":443"
+		}
+		conn, err = tls.Dial("tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, fmt.Errorf("web: unsupported websocket scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 16)
+	if _, err = rand.Read(b); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(b)
+
+	req := &http.Request{Method: http.MethodGet, URL: u, Host: u.Host, Header: make(http.Header)}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set(HeaderUpgrade, "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+
+	var sb strings.Builder
+	sb.WriteString("GET " + u.RequestURI() + " HTTP/1.1\r\nHost: " + u.Host + "\r\n")
+	_ = req.Header.Write(&sb)
+	sb.WriteString("\r\n")
+	if _, err = conn.Write([]byte(sb.String())); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%w: status %d", ErrBadHandshake, resp.StatusCode)
+	}
+	subprotocol := resp.Header.Get("Sec-WebSocket-Protocol")
+	return newWebSocket(conn, br, true, DefaultMaxMessageSize, subprotocol), nil
+}
+
+func newWebSocket(conn net.Conn, br *bufio.Reader, client bool, maxSize int64, subprotocol string) *WebSocket {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMessageSize
+	}
+	return &WebSocket{conn: conn, br: br, client: client, maxSize: maxSize, subprotocol: subprotocol}
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get(HeaderOrigin)
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func headerTokens(h http.Header, name string) []string {
+	var ret []string
+	for _, v := range h.Values(name) {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				ret = append(ret, s)
+			}
+		}
+	}
+	return ret
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, s := range headerTokens(h, name) {
+		if strings.EqualFold(s, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// Subprotocol 返回握手时协商的子协议。
+func (ws *WebSocket) Subprotocol() string {
+	return ws.subprotocol
+}
+
+// RemoteAddr 返回对端的地址。
+func (ws *WebSocket) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+// SetReadDeadline 设置读取的截止时间，可以配合 ping 检测失效的连接。
+func (ws *WebSocket) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline 设置写入的截止时间。
+func (ws *WebSocket) SetWriteDeadline(t time.Time) error {
+	return ws.conn.SetWriteDeadline(t)
+}
+
+// SetPongHandler 设置收到 pong 时的回调，在 ReadMessage 中调用，例如用于延长读取的
+// 截止时间。
+func (ws *WebSocket) SetPongHandler(fn func(data []byte)) {
+	ws.onPong = fn
+}
+
+// ReadMessage 读取一条完整的文本或者二进制消息，自动回复 ping 并且忽略 pong 。对端
+// 发送关闭帧时回复关闭帧并返回 *CloseError ，违反协议或者消息过大时发送关闭帧并返回
+// 错误，此后应当调用 Close 关闭连接。
+func (ws *WebSocket) ReadMessage() (messageType int, data []byte, err error) {
+	for {
+		fin, op, payload, err := ws.readFrame(ws.maxSize - int64(len(data)))
+		if err != nil {
+			return 0, nil, err
+		}
+		switch op {
+		case PingMessage:
+			if err = ws.writeFrame(PongMessage, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if ws.onPong != nil {
+				ws.onPong(payload)
+			}
+			continue
+		case CloseMessage:
+			e := &CloseError{Code: CloseNoStatusReceived}
+			if len(payload) >= 2 {
+				e.Code = int(binary.BigEndian.Uint16(payload))
+				e.Text = string(payload[2:])
+			}
+			_ = ws.WriteClose(e.Code, "")
+			return 0, nil, e
+		case 0:
+			if messageType == 0 {
+				return 0, nil, ws.fail(CloseProtocolError, ErrProtocol, "unexpected continuation frame")
+			}
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, ws.fail(CloseProtocolError, ErrProtocol, "expected continuation frame")
+			}
+			messageType = op
+		default:
+			return 0, nil, ws.fail(CloseProtocolError, ErrProtocol, fmt.Sprintf("unknown opcode %d", op))
+		}
+		data = append(data, payload...)
+		if fin {
+			if messageType == TextMessage && !utf8.Valid(data) {
+				return 0, nil, ws.fail(CloseInvalidData, ErrProtocol, "invalid utf-8 text")
+			}
+			return messageType, data, nil
+		}
+	}
+}
+
+// readFrame 读取一个帧，数据帧的长度超过 limit 时返回 ErrMessageTooLarge 。
+func (ws *WebSocket) readFrame(limit int64) (fin bool, op int, payload []byte, err error) {
+
+	var h [8]byte
+	if _, err = io.ReadFull(ws.br, h[:2]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, op = h[0]&0x80 != 0, int(h[0]&0x0f)
+	if h[0]&0x70 != 0 {
+		return false, 0, nil, ws.fail(CloseProtocolError, ErrProtocol, "reserved bits are set")
+	}
+	masked := h[1]&0x80 != 0
+	if masked == ws.client {
+		return false, 0, nil, ws.fail(CloseProtocolError, ErrProtocol, "invalid frame mask")
+	}
+
+	n := int64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		if _, err = io.ReadFull(ws.br, h[:2]); err != nil {
+			return false, 0, nil, err
+		}
+		n = int64(binary.BigEndian.Uint16(h[:2]))
+	case 127:
+		if _, err = io.ReadFull(ws.br, h[:8]); err != nil {
+			return false, 0, nil, err
+		}
+		n = int64(binary.BigEndian.Uint64(h[:8]))
+	}
+
+	if op >= CloseMessage {
+		if !fin || n > 125 {
+			return false, 0, nil, ws.fail(CloseProtocolError, ErrProtocol, "invalid control frame")
+		}
+	} else if n < 0 || n > limit {
+		return false, 0, nil, ws.fail(CloseMessageTooBig, ErrMessageTooLarge, "")
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(ws.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(ws.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, op, payload, nil
+}
+
+// fail 发送关闭帧并返回错误。
+func (ws *WebSocket) fail(code int, err error, msg string) error {
+	_ = ws.WriteClose(code, msg)
+	if msg == "" {
+		return err
+	}
+	return fmt.Errorf("%w: %s", err, msg)
+}
+
+// WriteMessage 发送一条消息，messageType 可以是文本、二进制、ping 或者 pong 。
+func (ws *WebSocket) WriteMessage(messageType int, data []byte) error {
+	switch messageType {
+	case TextMessage, BinaryMessage:
+	case PingMessage, PongMessage:
+		if len(data) > 125 {
+			return errors.New("web: websocket control frame too large")
+		}
+	default:
+		return fmt.Errorf("web: invalid websocket message type %d", messageType)
+	}
+	return ws.writeFrame(messageType, data)
+}
+
+// WriteClose 发送关闭帧，只会发送一次。code 为 CloseNoStatusReceived 时发送不带
+// 状态码的关闭帧。
+func (ws *WebSocket) WriteClose(code int, text string) error {
+	var payload []byte
+	if code != CloseNoStatusReceived {
+		if len(text) > 123 {
+			text = text[:123]
+		}
+		payload = make([]byte, 2, 2+len(text))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		payload = append(payload, text...)
+	}
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+	if ws.closeSent {
+		return nil
+	}
+	ws.closeSent = true
+	return ws.writeFrameLocked(CloseMessage, payload)
+}
+
+func (ws *WebSocket) writeFrame(op int, payload []byte) error {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+	if ws.closeSent {
+		return errCloseSent
+	}
+	return ws.writeFrameLocked(op, payload)
+}
+
+func (ws *WebSocket) writeFrameLocked(op int, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|byte(op))
+	var maskBit byte
+	if ws.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = append(frame, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(frame[len(frame)-8:], uint64(n))
+	}
+	if !ws.client {
+		frame = append(frame, payload...)
+	} else {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	}
+	_, err := ws.conn.Write(frame)
+	return err
+}
+
+// Close 关闭底层的连接，不发送关闭帧。
+func (ws *WebSocket) Close() error {
+	return ws.conn.Close()
+}
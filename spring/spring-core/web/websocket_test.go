@@ -0,0 +1,174 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web_test
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/web"
+)
+
+func newWebSocketServer(t *testing.T, config web.WebSocketConfig, fn func(ws *web.WebSocket)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := web.NewBaseContext("/ws", nil, r, &web.BufferedResponseWriter{ResponseWriter: w})
+		ws, err := web.UpgradeWebSocket(ctx, config)
+		if err != nil {
+			return
+		}
+		assert.Equal(t, ctx.ResponseWriter().Status(), http.StatusSwitchingProtocols)
+		defer ws.Close()
+		fn(ws)
+	}))
+}
+
+func wsURL(s *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + "/ws"
+}
+
+func TestWebSocket(t *testing.T) {
+
+	closed := make(chan error, 1)
+	s := newWebSocketServer(t, web.WebSocketConfig{MaxMessageSize: 16, Subprotocols: []string{"chat"}}, func(ws *web.WebSocket) {
+		for {
+			typ, data, err := ws.ReadMessage()
+			if err != nil {
+				closed <- err
+				return
+			}
+			_ = ws.WriteMessage(typ, append([]byte("echo "), data...))
+		}
+	})
+	defer s.Close()
+
+	header := http.Header{"Sec-WebSocket-Protocol": {"json, chat"}}
+	ws, err := web.DialWebSocket(wsURL(s), header)
+	assert.Nil(t, err)
+	defer ws.Close()
+	assert.Equal(t, ws.Subprotocol(), "chat")
+
+	err = ws.WriteMessage(web.PingMessage, []byte("p"))
+	assert.Nil(t, err)
+	err = ws.WriteMessage(web.TextMessage, []byte("hello"))
+	assert.Nil(t, err)
+	typ, data, err := ws.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, typ, web.TextMessage)
+	assert.Equal(t, string(data), "echo hello")
+
+	err = ws.WriteMessage(web.BinaryMessage, []byte{1, 2})
+	assert.Nil(t, err)
+	typ, data, err = ws.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, typ, web.BinaryMessage)
+	assert.Equal(t, data, []byte("echo \x01\x02"))
+
+	// 超过最大长度的消息使用 1009 关闭连接。
+	err = ws.WriteMessage(web.TextMessage, []byte(strings.Repeat("x", 17)))
+	assert.Nil(t, err)
+	_, _, err = ws.ReadMessage()
+	var e *web.CloseError
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, e.Code, web.CloseMessageTooBig)
+	assert.True(t, errors.Is(<-closed, web.ErrMessageTooLarge))
+}
+
+func TestWebSocket_Close(t *testing.T) {
+
+	closed := make(chan error, 1)
+	s := newWebSocketServer(t, web.WebSocketConfig{}, func(ws *web.WebSocket) {
+		_, _, err := ws.ReadMessage()
+		closed <- err
+	})
+	defer s.Close()
+
+	ws, err := web.DialWebSocket(wsURL(s), nil)
+	assert.Nil(t, err)
+	defer ws.Close()
+
+	err = ws.WriteClose(web.CloseGoingAway, "bye")
+	assert.Nil(t, err)
+	err = <-closed
+	var e *web.CloseError
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, *e, web.CloseError{Code: web.CloseGoingAway, Text: "bye"})
+
+	// 服务端回复关闭帧。
+	_, _, err = ws.ReadMessage()
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, e.Code, web.CloseGoingAway)
+}
+
+func TestWebSocket_CloseWithoutStatus(t *testing.T) {
+
+	closed := make(chan error, 1)
+	s := newWebSocketServer(t, web.WebSocketConfig{}, func(ws *web.WebSocket) {
+		_, _, err := ws.ReadMessage()
+		closed <- err
+	})
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	_, err = io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: "+s.Listener.Addr().String()+
+		"\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13"+
+		"\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	assert.Nil(t, err)
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, resp.StatusCode, http.StatusSwitchingProtocols)
+
+	// 发送不带状态码的关闭帧，客户端的帧必须设置掩码。
+	_, err = conn.Write([]byte{0x88, 0x80, 1, 2, 3, 4})
+	assert.Nil(t, err)
+	var e *web.CloseError
+	assert.True(t, errors.As(<-closed, &e))
+	assert.Equal(t, e.Code, web.CloseNoStatusReceived)
+
+	// 回复的关闭帧同样不带状态码，1005 不能出现在关闭帧中。
+	frame := make([]byte, 2)
+	_, err = io.ReadFull(r, frame)
+	assert.Nil(t, err)
+	assert.Equal(t, frame, []byte{0x88, 0x00})
+}
+
+func TestWebSocket_Handshake(t *testing.T) {
+
+	s := newWebSocketServer(t, web.WebSocketConfig{}, func(ws *web.WebSocket) {})
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/ws")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusBadRequest)
+
+	_, err = web.DialWebSocket(wsURL(s), http.Header{"Origin": {"http://evil.example.com"}})
+	assert.Error(t, err, "websocket bad handshake: status 403")
+
+	_, err = web.DialWebSocket(wsURL(s), http.Header{"Origin": {s.URL}})
+	assert.Nil(t, err)
+}
@@ -0,0 +1,282 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Provider 校验 webhook 请求的签名并解析出投递信息，自定义的签名方式可以实现该接口
+// 并注册为 bean 。
+type Provider interface {
+
+	// Name 返回提供方的名称，即接收地址中的 {provider} 。
+	Name() string
+
+	// Verify 校验请求的签名，返回的 Delivery 不需要设置 Provider 和 Body 字段。
+	Verify(r *http.Request, body []byte) (*Delivery, error)
+}
+
+// ProviderConfig 通过配置创建的提供方。
+type ProviderConfig struct {
+	Name      string        `value:"${name}"`
+	Type      string        `value:"${type:=hmac}"`    // github、stripe 或者 hmac
+	Secrets   []string      `value:"${secrets:=}"`     // 所有有效的密钥，轮换密钥时可以同时配置新旧密钥
+	Tolerance time.Duration `value:"${tolerance:=5m}"` // 签名时间戳允许的偏差，为 0 时不校验
+
+	// 以下配置仅用于 hmac 类型。
+	Algorithm       string `value:"${algorithm:=sha256}"` // sha1、sha256 或者 sha512
+	Encoding        string `value:"${encoding:=hex}"`     // hex 或者 base64
+	SignatureHeader string `value:"${signature-header:=X-Signature}"`
+	SignaturePrefix string `value:"${signature-prefix:=}"` // 签名的前缀，如 sha256=
+	TimestampHeader string `value:"${timestamp-header:=}"` // 为空时不签名时间戳，否则签名内容为 {timestamp}.{body}
+	EventHeader     string `value:"${event-header:=X-Event}"`
+	IDHeader        string `value:"${id-header:=X-Delivery-ID}"`
+}
+
+// NewProvider 根据配置创建提供方。
+func NewProvider(config ProviderConfig) (Provider, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("webhook: provider name is required")
+	}
+	if len(config.Secrets) == 0 {
+		return nil, fmt.Errorf("webhook: provider %s has no secrets", config.Name)
+	}
+	switch config.Type {
+	case "github":
+		return &githubProvider{name: config.Name, secrets: config.Secrets}, nil
+	case "stripe":
+		return &stripeProvider{name: config.Name, secrets: config.Secrets, tolerance: config.Tolerance}, nil
+	case "hmac":
+		return newHMACProvider(config)
+	}
+	return nil, fmt.Errorf("webhook: unknown provider type %q", config.Type)
+}
+
+// sign 计算 HMAC 签名。
+func sign(h func() hash.Hash, secret string, content ...[]byte) []byte {
+	mac := hmac.New(h, []byte(secret))
+	for _, b := range content {
+		mac.Write(b)
+	}
+	return mac.Sum(nil)
+}
+
+// verifyHex 使用任意一个密钥校验十六进制编码的签名。
+func verifyHex(h func() hash.Hash, secrets []string, signature string, content ...[]byte) bool {
+	b, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	for _, secret := range secrets {
+		if hmac.Equal(b, sign(h, secret, content...)) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTimestamp 校验时间戳是否在允许的偏差以内。
+func checkTimestamp(s string, tolerance time.Duration) (time.Time, error) {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidSignature
+	}
+	t := time.Unix(sec, 0)
+	if d := time.Since(t); tolerance > 0 && (d > tolerance || d < -tolerance) {
+		return time.Time{}, ErrExpired
+	}
+	return t, nil
+}
+
+// githubProvider 校验 GitHub 的 X-Hub-Signature-256 签名，没有该请求头时使用
+// X-Hub-Signature 的 sha1 签名。
+type githubProvider struct {
+	name    string
+	secrets []string
+}
+
+func (p *githubProvider) Name() string {
+	return p.name
+}
+
+func (p *githubProvider) Verify(r *http.Request, body []byte) (*Delivery, error) {
+	h, prefix := sha256.New, "sha256="
+	signature := r.Header.Get("X-Hub-Signature-256")
+	if signature == "" {
+		h, prefix = sha1.New, "sha1="
+		signature = r.Header.Get("X-Hub-Signature")
+	}
+	if signature == "" {
+		return nil, ErrNoSignature
+	}
+	if !strings.HasPrefix(signature, prefix) || !verifyHex(h, p.secrets, signature[len(prefix):], body) {
+		return nil, ErrInvalidSignature
+	}
+	return &Delivery{
+		ID:    r.Header.Get("X-GitHub-Delivery"),
+		Event: r.Header.Get("X-GitHub-Event"),
+	}, nil
+}
+
+// stripeProvider 校验 Stripe 的 Stripe-Signature 签名，签名内容为 {t}.{body} ，
+// 事件类型和 ID 从请求体中读取。
+type stripeProvider struct {
+	name      string
+	secrets   []string
+	tolerance time.Duration
+}
+
+func (p *stripeProvider) Name() string {
+	return p.name
+}
+
+func (p *stripeProvider) Verify(r *http.Request, body []byte) (*Delivery, error) {
+	header := r.Header.Get("Stripe-Signature")
+	if header == "" {
+		return nil, ErrNoSignature
+	}
+	var (
+		timestamp  string
+		signatures []string
+	)
+	for _, item := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			signatures = append(signatures, kv[1])
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return nil, ErrInvalidSignature
+	}
+	ok := false
+	for _, s := range signatures {
+		if verifyHex(sha256.New, p.secrets, s, []byte(timestamp), []byte{'.'}, body) {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return nil, ErrInvalidSignature
+	}
+	t, err := checkTimestamp(timestamp, p.tolerance)
+	if err != nil {
+		return nil, err
+	}
+	var event struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	}
+	if err = json.Unmarshal(body, &event); err != nil {
+		return nil, ErrInvalidPayload
+	}
+	return &Delivery{ID: event.ID, Event: event.Type, Timestamp: t}, nil
+}
+
+// hmacProvider 使用可配置的请求头和算法校验 HMAC 签名。
+type hmacProvider struct {
+	config ProviderConfig
+	hash   func() hash.Hash
+}
+
+func newHMACProvider(config ProviderConfig) (*hmacProvider, error) {
+	p := &hmacProvider{config: config}
+	switch config.Algorithm {
+	case "sha1":
+		p.hash = sha1.New
+	case "sha256":
+		p.hash = sha256.New
+	case "sha512":
+		p.hash = sha512.New
+	default:
+		return nil, fmt.Errorf("webhook: unknown algorithm %q", config.Algorithm)
+	}
+	if config.Encoding != "hex" && config.Encoding != "base64" {
+		return nil, fmt.Errorf("webhook: unknown encoding %q", config.Encoding)
+	}
+	return p, nil
+}
+
+func (p *hmacProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *hmacProvider) Verify(r *http.Request, body []byte) (*Delivery, error) {
+	signature := r.Header.Get(p.config.SignatureHeader)
+	if signature == "" {
+		return nil, ErrNoSignature
+	}
+	if !strings.HasPrefix(signature, p.config.SignaturePrefix) {
+		return nil, ErrInvalidSignature
+	}
+	signature = signature[len(p.config.SignaturePrefix):]
+
+	d := &Delivery{
+		ID:    r.Header.Get(p.config.IDHeader),
+		Event: r.Header.Get(p.config.EventHeader),
+	}
+	content := [][]byte{body}
+	var timestamp string
+	if p.config.TimestampHeader != "" {
+		if timestamp = r.Header.Get(p.config.TimestampHeader); timestamp == "" {
+			return nil, ErrInvalidSignature
+		}
+		content = [][]byte{[]byte(timestamp), {'.'}, body}
+	}
+
+	ok := false
+	if p.config.Encoding == "hex" {
+		ok = verifyHex(p.hash, p.config.Secrets, signature, content...)
+	} else if b, err := base64.StdEncoding.DecodeString(signature); err == nil {
+		for _, secret := range p.config.Secrets {
+			if hmac.Equal(b, sign(p.hash, secret, content...)) {
+				ok = true
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, ErrInvalidSignature
+	}
+
+	if timestamp != "" {
+		t, err := checkTimestamp(timestamp, p.config.Tolerance)
+		if err != nil {
+			return nil, err
+		}
+		d.Timestamp = t
+	}
+	return d, nil
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/webhook"
+)
+
+func hmacSum(h func() hash.Hash, secret, content string) []byte {
+	mac := hmac.New(h, []byte(secret))
+	mac.Write([]byte(content))
+	return mac.Sum(nil)
+}
+
+func hexSign(h func() hash.Hash, secret, content string) string {
+	return hex.EncodeToString(hmacSum(h, secret, content))
+}
+
+func newRequest(body string, header map[string]string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/webhooks/test", strings.NewReader(body))
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	return r
+}
+
+func TestGitHubProvider(t *testing.T) {
+
+	p, err := webhook.NewProvider(webhook.ProviderConfig{Name: "github", Type: "github", Secrets: []string{"old", "new"}})
+	assert.Nil(t, err)
+
+	body := `{"ref":"refs/heads/main"}`
+	d, err := p.Verify(newRequest(body, map[string]string{
+		"X-Hub-Signature-256": "sha256=" + hexSign(sha256.New, "new", body),
+		"X-GitHub-Event":      "push",
+		"X-GitHub-Delivery":   "72d3162e",
+	}), []byte(body))
+	assert.Nil(t, err)
+	assert.Equal(t, d.Event, "push")
+	assert.Equal(t, d.ID, "72d3162e")
+
+	_, err = p.Verify(newRequest(body, map[string]string{
+		"X-Hub-Signature": "sha1=" + hexSign(sha1.New, "old", body),
+	}), []byte(body))
+	assert.Nil(t, err)
+
+	_, err = p.Verify(newRequest(body, map[string]string{
+		"X-Hub-Signature-256": "sha256=" + hexSign(sha256.New, "other", body),
+	}), []byte(body))
+	assert.Equal(t, err, webhook.ErrInvalidSignature)
+
+	_, err = p.Verify(newRequest(body, nil), []byte(body))
+	assert.Equal(t, err, webhook.ErrNoSignature)
+}
+
+func TestStripeProvider(t *testing.T) {
+
+	p, err := webhook.NewProvider(webhook.ProviderConfig{Name: "stripe", Type: "stripe", Secrets: []string{"whsec"}, Tolerance: 5 * time.Minute})
+	assert.Nil(t, err)
+
+	body := `{"id":"evt_1","type":"invoice.paid"}`
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	header := fmt.Sprintf("t=%s,v1=%s,v1=%s", ts, hexSign(sha256.New, "other", ts+"."+body), hexSign(sha256.New, "whsec", ts+"."+body))
+	d, err := p.Verify(newRequest(body, map[string]string{"Stripe-Signature": header}), []byte(body))
+	assert.Nil(t, err)
+	assert.Equal(t, d.ID, "evt_1")
+	assert.Equal(t, d.Event, "invoice.paid")
+	assert.Equal(t, d.Timestamp.Unix(), time.Now().Unix())
+
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	header = fmt.Sprintf("t=%s,v1=%s", old, hexSign(sha256.New, "whsec", old+"."+body))
+	_, err = p.Verify(newRequest(body, map[string]string{"Stripe-Signature": header}), []byte(body))
+	assert.Equal(t, err, webhook.ErrExpired)
+
+	header = fmt.Sprintf("t=%s,v1=%s", ts, hexSign(sha256.New, "whsec", body))
+	_, err = p.Verify(newRequest(body, map[string]string{"Stripe-Signature": header}), []byte(body))
+	assert.Equal(t, err, webhook.ErrInvalidSignature)
+}
+
+func TestHMACProvider(t *testing.T) {
+
+	_, err := webhook.NewProvider(webhook.ProviderConfig{Name: "x", Type: "hmac", Secrets: []string{"s"}, Algorithm: "md5", Encoding: "hex"})
+	assert.Error(t, err, "webhook: unknown algorithm \"md5\"")
+	_, err = webhook.NewProvider(webhook.ProviderConfig{Name: "x", Type: "hmac"})
+	assert.Error(t, err, "webhook: provider x has no secrets")
+
+	p, err := webhook.NewProvider(webhook.ProviderConfig{
+		Name:            "shop",
+		Type:            "hmac",
+		Secrets:         []string{"s3cr3t"},
+		Tolerance:       time.Minute,
+		Algorithm:       "sha256",
+		Encoding:        "base64",
+		SignatureHeader: "X-Shop-Signature",
+		SignaturePrefix: "v1=",
+		TimestampHeader: "X-Shop-Timestamp",
+		EventHeader:     "X-Shop-Event",
+		IDHeader:        "X-Shop-ID",
+	})
+	assert.Nil(t, err)
+
+	body := `{"order":1}`
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	d, err := p.Verify(newRequest(body, map[string]string{
+		"X-Shop-Signature": "v1=" + base64.StdEncoding.EncodeToString(hmacSum(sha256.New, "s3cr3t", ts+"."+body)),
+		"X-Shop-Timestamp": ts,
+		"X-Shop-Event":     "order.created",
+		"X-Shop-ID":        "d-1",
+	}), []byte(body))
+	assert.Nil(t, err)
+	assert.Equal(t, d.Event, "order.created")
+	assert.Equal(t, d.ID, "d-1")
+
+	_, err = p.Verify(newRequest(body, map[string]string{
+		"X-Shop-Signature": "v1=" + base64.StdEncoding.EncodeToString(hmacSum(sha256.New, "s3cr3t", body)),
+	}), []byte(body))
+	assert.Equal(t, err, webhook.ErrInvalidSignature)
+}
@@ -0,0 +1,501 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/event"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/web"
+	"github.com/google/uuid"
+)
+
+// 发出的 webhook 请求携带的请求头，签名为 v1={hex(HMAC-SHA256(secret, timestamp.body))} ，
+// 接收方可以使用 hmac 类型的提供方校验。
+const (
+	HeaderWebhookID        = "X-Webhook-ID"
+	HeaderWebhookEvent     = "X-Webhook-Event"
+	HeaderWebhookTimestamp = "X-Webhook-Timestamp"
+	HeaderWebhookSignature = "X-Webhook-Signature"
+)
+
+var (
+	ErrSenderClosed       = errors.New("webhook: sender is closed")
+	ErrDeadLetterNotFound = errors.New("webhook: dead letter not found")
+)
+
+// Event 可以通过 webhook 发送给订阅者的事件，事件本身通过 json 序列化。
+type Event interface {
+	EventType() string
+}
+
+// Subscription webhook 订阅。
+type Subscription struct {
+	ID     string   `value:"${id}" json:"id"`
+	URL    string   `value:"${url}" json:"url"`
+	Secret string   `value:"${secret:=}" json:"-"`
+	Events []string `value:"${events:=*}" json:"events"` // 订阅的事件类型，支持 * 和 order.* 形式的前缀
+}
+
+// Match 是否订阅了该类型的事件。
+func (s *Subscription) Match(eventType string) bool {
+	for _, e := range s.Events {
+		if e == "*" || e == eventType {
+			return true
+		}
+		if strings.HasSuffix(e, ".*") && strings.HasPrefix(eventType, e[:len(e)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// SenderConfig 发送 webhook 的配置，通常配合 webhook.delivery 前缀一起使用。两次
+// 发送之间的等待时间按照倍数增长，超过最多发送次数的消息被保存到死信存储。
+type SenderConfig struct {
+	Subscriptions []Subscription `value:"${subscriptions:=}"`
+	Timeout       time.Duration  `value:"${timeout:=10s}"`    // 每次发送的超时时间
+	Concurrency   int            `value:"${concurrency:=8}"`  // 同时发送的请求数量
+	MaxAttempts   int            `value:"${max-attempts:=8}"` // 最多发送的次数，包括第一次发送
+	Backoff       time.Duration  `value:"${backoff:=1s}"`     // 第一次重试之前等待的时间
+	MaxBackoff    time.Duration  `value:"${max-backoff:=10m}"`
+	Multiplier    float64        `value:"${multiplier:=2}"`
+}
+
+// Message 发送给一个订阅者的 webhook 消息。
+type Message struct {
+	ID           string          `json:"id"` // 消息 ID ，即 {事件 ID}.{订阅 ID}
+	EventID      string          `json:"event_id"`
+	Event        string          `json:"event"`
+	Subscription string          `json:"subscription"`
+	URL          string          `json:"url"`
+	Body         json.RawMessage `json:"body"`
+	Attempts     int             `json:"attempts"`
+	LastError    string          `json:"last_error,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	FailedAt     time.Time       `json:"failed_at,omitempty"`
+}
+
+// DeadLetterStore 保存发送失败的消息。
+type DeadLetterStore interface {
+
+	// Save 保存发送失败的消息，相同 ID 的消息被覆盖。
+	Save(ctx context.Context, m *Message) error
+
+	// Get 返回 ID 对应的消息，不存在时返回 nil 。
+	Get(ctx context.Context, id string) (*Message, error)
+
+	// List 按照失败时间返回最多 limit 条消息，limit 小于等于 0 时返回所有消息。
+	List(ctx context.Context, limit int) ([]*Message, error)
+
+	// Delete 删除 ID 对应的消息。
+	Delete(ctx context.Context, id string) error
+}
+
+type memoryDeadLetterStore struct {
+	mutex    sync.Mutex
+	messages map[string]*Message
+}
+
+// NewMemoryDeadLetterStore 创建基于内存的 DeadLetterStore 。
+func NewMemoryDeadLetterStore() DeadLetterStore {
+	return &memoryDeadLetterStore{messages: make(map[string]*Message)}
+}
+
+func (s *memoryDeadLetterStore) Save(ctx context.Context, m *Message) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.messages[m.ID] = m
+	return nil
+}
+
+func (s *memoryDeadLetterStore) Get(ctx context.Context, id string) (*Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.messages[id], nil
+}
+
+func (s *memoryDeadLetterStore) List(ctx context.Context, limit int) ([]*Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	r := make([]*Message, 0, len(s.messages))
+	for _, m := range s.messages {
+		r = append(r, m)
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].FailedAt.Before(r[j].FailedAt) })
+	if limit > 0 && len(r) > limit {
+		r = r[:limit]
+	}
+	return r, nil
+}
+
+func (s *memoryDeadLetterStore) Delete(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.messages, id)
+	return nil
+}
+
+// Sender 将事件签名之后发送给订阅者，失败时按照指数退避重试，最终失败的消息保存到
+// 死信存储，可以通过 Redeliver 重新发送。
+type Sender struct {
+	config SenderConfig
+	store  DeadLetterStore
+	client *http.Client
+	sem    chan struct{}
+
+	mutex   sync.RWMutex
+	subs    map[string]*Subscription
+	pending map[*time.Timer]*Message // 等待重试的消息
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// NewSender 创建 Sender ，store 为空时使用 MemoryDeadLetterStore ，client 为空时使用
+// 默认的 http.Client 。
+func NewSender(config SenderConfig, store DeadLetterStore, client *http.Client) (*Sender, error) {
+	if store == nil {
+		store = NewMemoryDeadLetterStore()
+	}
+	if client == nil {
+		client = &http.Client{}
+	}
+	if config.Concurrency < 1 {
+		config.Concurrency = 1
+	}
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = 1
+	}
+	s := &Sender{
+		config:  config,
+		store:   store,
+		client:  client,
+		sem:     make(chan struct{}, config.Concurrency),
+		subs:    make(map[string]*Subscription),
+		pending: make(map[*time.Timer]*Message),
+	}
+	for i := range config.Subscriptions {
+		if err := s.Subscribe(config.Subscriptions[i]); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Subscribe 添加或者替换订阅。
+func (s *Sender) Subscribe(sub Subscription) error {
+	if sub.ID == "" || sub.URL == "" {
+		return errors.New("webhook: subscription id and url are required")
+	}
+	if len(sub.Events) == 0 {
+		sub.Events = []string{"*"}
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.subs[sub.ID] = &sub
+	return nil
+}
+
+// Unsubscribe 删除订阅，正在重试的消息仍然会继续发送。
+func (s *Sender) Unsubscribe(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.subs, id)
+}
+
+// Subscriptions 返回所有的订阅。
+func (s *Sender) Subscriptions() []Subscription {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	r := make([]Subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		r = append(r, *sub)
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].ID < r[j].ID })
+	return r
+}
+
+// Listener 返回将事件总线上的 Event 发送给订阅者的监听器，例如：
+//
+//	gs.Provide((*webhook.Sender).Listener, (*webhook.Sender)(nil))
+func (s *Sender) Listener() *event.Listener {
+	return event.Listen("webhook", func(ctx context.Context, e Event) error {
+		return s.Send(ctx, e)
+	})
+}
+
+// Send 在后台将事件发送给所有订阅了该类型事件的订阅者。
+func (s *Sender) Send(ctx context.Context, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	eventID := uuid.New().String()
+	now := time.Now()
+	body, err := json.Marshal(map[string]interface{}{
+		"id":      eventID,
+		"event":   e.EventType(),
+		"created": now.Unix(),
+		"data":    json.RawMessage(data),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.closed {
+		return ErrSenderClosed
+	}
+	for _, sub := range s.subs {
+		if !sub.Match(e.EventType()) {
+			continue
+		}
+		m := &Message{
+			ID:           eventID + "." + sub.ID,
+			EventID:      eventID,
+			Event:        e.EventType(),
+			Subscription: sub.ID,
+			URL:          sub.URL,
+			Body:         body,
+			CreatedAt:    now,
+		}
+		s.wg.Add(1)
+		go s.attempt(m)
+	}
+	return nil
+}
+
+// attempt 发送一次消息，失败时安排下一次重试或者保存到死信存储。
+func (s *Sender) attempt(m *Message) {
+	defer s.wg.Done()
+
+	s.sem <- struct{}{}
+	err := s.deliver(m)
+	<-s.sem
+
+	ctx := context.Background()
+	if err == nil {
+		logger.WithContext(ctx).Debugf("webhook %s delivered to %s after %d attempts", m.ID, m.URL, m.Attempts)
+		return
+	}
+	m.LastError = err.Error()
+
+	var perm *permanentError
+	if errors.As(err, &perm) || m.Attempts >= s.config.MaxAttempts {
+		s.deadLetter(ctx, m)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		s.deadLetter(ctx, m)
+		return
+	}
+	var t *time.Timer
+	s.wg.Add(1)
+	t = time.AfterFunc(s.backoff(m.Attempts), func() {
+		s.mutex.Lock()
+		_, ok := s.pending[t]
+		delete(s.pending, t)
+		s.mutex.Unlock()
+		if ok {
+			s.attempt(m)
+		}
+	})
+	s.pending[t] = m
+}
+
+// backoff 返回第 attempts 次发送失败之后等待的时间。
+func (s *Sender) backoff(attempts int) time.Duration {
+	d := float64(s.config.Backoff) * math.Pow(s.config.Multiplier, float64(attempts-1))
+	if s.config.MaxBackoff > 0 && d > float64(s.config.MaxBackoff) {
+		return s.config.MaxBackoff
+	}
+	return time.Duration(d)
+}
+
+func (s *Sender) deadLetter(ctx context.Context, m *Message) {
+	m.FailedAt = time.Now()
+	logger.WithContext(ctx).Warnf("webhook %s to %s failed after %d attempts: %s", m.ID, m.URL, m.Attempts, m.LastError)
+	if err := s.store.Save(ctx, m); err != nil {
+		logger.WithContext(ctx).Errorf(log.ERROR, "webhook %s: save dead letter error: %v", m.ID, err)
+	}
+}
+
+// permanentError 不需要重试的错误。
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// deliver 发送一次消息，4xx 的响应(408 和 429 除外)不再重试。
+func (s *Sender) deliver(m *Message) error {
+	m.Attempts++
+
+	s.mutex.RLock()
+	sub, ok := s.subs[m.Subscription]
+	s.mutex.RUnlock()
+	if !ok {
+		return &permanentError{err: fmt.Errorf("subscription %s not found", m.Subscription)}
+	}
+
+	ctx := context.Background()
+	if s.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.URL, bytes.NewReader(m.Body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(web.HeaderContentType, web.MIMEApplicationJSONCharsetUTF8)
+	req.Header.Set(HeaderWebhookID, m.EventID)
+	req.Header.Set(HeaderWebhookEvent, m.Event)
+	req.Header.Set(HeaderWebhookTimestamp, timestamp)
+	if sub.Secret != "" {
+		signature := sign(sha256.New, sub.Secret, []byte(timestamp), []byte{'.'}, m.Body)
+		req.Header.Set(HeaderWebhookSignature, "v1="+hex.EncodeToString(signature))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return &permanentError{err: fmt.Errorf("unexpected status %d", resp.StatusCode)}
+	}
+	return fmt.Errorf("unexpected status %d", resp.StatusCode)
+}
+
+// DeadLetters 返回最多 limit 条发送失败的消息。
+func (s *Sender) DeadLetters(ctx context.Context, limit int) ([]*Message, error) {
+	return s.store.List(ctx, limit)
+}
+
+// Redeliver 从死信存储中取出消息并在后台重新发送，发送次数重新计算。
+func (s *Sender) Redeliver(ctx context.Context, id string) error {
+	m, err := s.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return fmt.Errorf("%w: %s", ErrDeadLetterNotFound, id)
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.closed {
+		return ErrSenderClosed
+	}
+	if err = s.store.Delete(ctx, id); err != nil {
+		return err
+	}
+	m.Attempts, m.LastError, m.FailedAt = 0, "", time.Time{}
+	s.wg.Add(1)
+	go s.attempt(m)
+	return nil
+}
+
+// HandleDeadLetters 返回发送失败的消息，查询参数 limit 限制返回的数量。
+func (s *Sender) HandleDeadLetters(ctx web.Context) {
+	limit, _ := strconv.Atoi(ctx.QueryParam("limit"))
+	messages, err := s.DeadLetters(ctx.Context(), limit)
+	if err != nil {
+		panic(web.NewHttpError(http.StatusInternalServerError, err.Error()))
+	}
+	ctx.JSON(messages)
+}
+
+// HandleRedeliver 重新发送路径参数 id 指定的消息。
+func (s *Sender) HandleRedeliver(ctx web.Context) {
+	if err := s.Redeliver(ctx.Context(), ctx.PathParam("id")); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrDeadLetterNotFound) {
+			status = http.StatusNotFound
+		}
+		panic(web.NewHttpError(status, err.Error()))
+	}
+	ctx.NoContent(http.StatusAccepted)
+}
+
+// Close 停止发送，等待重试的消息被保存到死信存储，然后等待正在发送的消息完成。
+func (s *Sender) Close(ctx context.Context) error {
+	s.mutex.Lock()
+	s.closed = true
+	for t, m := range s.pending {
+		// 已经触发的重试由定时器完成，关闭之后失败的消息同样会被保存到死信存储。
+		if t.Stop() {
+			delete(s.pending, t)
+			s.deadLetter(ctx, m)
+			s.wg.Done()
+		}
+	}
+	s.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// OnAppStart 实现 gs.AppEvent 接口。
+func (s *Sender) OnAppStart(ctx gs.Context) {}
+
+// OnAppStop 应用退出时关闭 Sender 。
+func (s *Sender) OnAppStop(ctx context.Context) {
+	if err := s.Close(ctx); err != nil {
+		logger.WithContext(ctx).Errorf(log.ERROR, "webhook sender close error: %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/event"
+	"github.com/go-spring/spring-core/webhook"
+)
+
+type OrderCreated struct {
+	OrderID int `json:"order_id"`
+}
+
+func (e *OrderCreated) EventType() string { return "order.created" }
+
+type UserDeleted struct{}
+
+func (e *UserDeleted) EventType() string { return "user.deleted" }
+
+// endpoint 记录收到的 webhook 请求，status 依次作为响应码，用完之后返回 200 。
+type endpoint struct {
+	mutex    sync.Mutex
+	status   []int
+	verifier webhook.Provider
+	received []*webhook.Delivery
+	attempts int
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.attempts++
+	if len(e.status) > 0 {
+		w.WriteHeader(e.status[0])
+		e.status = e.status[1:]
+		return
+	}
+	d, err := e.verifier.Verify(r, body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	d.Body = body
+	e.received = append(e.received, d)
+}
+
+func (e *endpoint) count() (attempts, received int) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.attempts, len(e.received)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("timeout")
+}
+
+func newEndpoint(t *testing.T, status ...int) (*endpoint, *httptest.Server) {
+	p, err := webhook.NewProvider(webhook.ProviderConfig{
+		Name:            "sender",
+		Type:            "hmac",
+		Secrets:         []string{"s3cr3t"},
+		Tolerance:       time.Minute,
+		Algorithm:       "sha256",
+		Encoding:        "hex",
+		SignatureHeader: webhook.HeaderWebhookSignature,
+		SignaturePrefix: "v1=",
+		TimestampHeader: webhook.HeaderWebhookTimestamp,
+		EventHeader:     webhook.HeaderWebhookEvent,
+		IDHeader:        webhook.HeaderWebhookID,
+	})
+	assert.Nil(t, err)
+	e := &endpoint{status: status, verifier: p}
+	return e, httptest.NewServer(e)
+}
+
+func newSender(t *testing.T, url string, maxAttempts int, backoff time.Duration) *webhook.Sender {
+	s, err := webhook.NewSender(webhook.SenderConfig{
+		Subscriptions: []webhook.Subscription{{ID: "shop", URL: url, Secret: "s3cr3t", Events: []string{"order.*"}}},
+		Timeout:       time.Second,
+		Concurrency:   2,
+		MaxAttempts:   maxAttempts,
+		Backoff:       backoff,
+		MaxBackoff:    time.Hour,
+		Multiplier:    2,
+	}, nil, nil)
+	assert.Nil(t, err)
+	return s
+}
+
+func TestSender(t *testing.T) {
+
+	e, server := newEndpoint(t, http.StatusInternalServerError, http.StatusTooManyRequests)
+	defer server.Close()
+	s := newSender(t, server.URL, 3, time.Millisecond)
+
+	bus := event.NewBus([]*event.Listener{s.Listener()}, nil)
+	ctx := context.Background()
+	assert.Nil(t, bus.Publish(ctx, &UserDeleted{}))
+	assert.Nil(t, bus.Publish(ctx, &OrderCreated{OrderID: 7}))
+	waitFor(t, func() bool { _, n := e.count(); return n == 1 })
+	assert.Nil(t, s.Close(ctx))
+
+	attempts, _ := e.count()
+	assert.Equal(t, attempts, 3)
+	d := e.received[0]
+	assert.Equal(t, d.Event, "order.created")
+	var payload struct {
+		ID    string       `json:"id"`
+		Event string       `json:"event"`
+		Data  OrderCreated `json:"data"`
+	}
+	assert.Nil(t, json.Unmarshal(d.Body, &payload))
+	assert.Equal(t, payload.ID, d.ID)
+	assert.Equal(t, payload.Event, "order.created")
+	assert.Equal(t, payload.Data.OrderID, 7)
+
+	messages, err := s.DeadLetters(ctx, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, len(messages), 0)
+	assert.Equal(t, s.Send(ctx, &OrderCreated{}), webhook.ErrSenderClosed)
+}
+
+func TestSender_DeadLetter(t *testing.T) {
+
+	e, server := newEndpoint(t, http.StatusBadRequest, http.StatusBadGateway, http.StatusBadGateway)
+	defer server.Close()
+	s := newSender(t, server.URL, 2, time.Millisecond)
+	ctx := context.Background()
+
+	// 4xx 的响应不再重试
+	assert.Nil(t, s.Send(ctx, &OrderCreated{OrderID: 1}))
+	var messages []*webhook.Message
+	waitFor(t, func() bool { messages, _ = s.DeadLetters(ctx, 0); return len(messages) == 1 })
+	assert.Equal(t, messages[0].Attempts, 1)
+	assert.Equal(t, messages[0].LastError, "unexpected status 400")
+
+	// 超过最多发送次数
+	assert.Nil(t, s.Send(ctx, &OrderCreated{OrderID: 2}))
+	waitFor(t, func() bool { messages, _ = s.DeadLetters(ctx, 0); return len(messages) == 2 })
+	assert.Equal(t, messages[1].Attempts, 2)
+	assert.Equal(t, messages[1].LastError, "unexpected status 502")
+
+	for _, m := range messages {
+		assert.Nil(t, s.Redeliver(ctx, m.ID))
+	}
+	waitFor(t, func() bool { _, n := e.count(); return n == 2 })
+	messages, _ = s.DeadLetters(ctx, 0)
+	assert.Equal(t, len(messages), 0)
+	assert.Error(t, s.Redeliver(ctx, "unknown"), "webhook: dead letter not found: unknown")
+	assert.Nil(t, s.Close(ctx))
+}
+
+func TestSender_Close(t *testing.T) {
+
+	_, server := newEndpoint(t, http.StatusServiceUnavailable)
+	defer server.Close()
+	s := newSender(t, server.URL, 5, time.Hour)
+	ctx := context.Background()
+
+	assert.Nil(t, s.Send(ctx, &OrderCreated{OrderID: 1}))
+	time.Sleep(50 * time.Millisecond)
+	assert.Nil(t, s.Close(ctx))
+
+	messages, err := s.DeadLetters(ctx, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, len(messages), 1)
+	assert.Equal(t, messages[0].Attempts, 1)
+	assert.Equal(t, messages[0].Subscription, "shop")
+}
+
+func TestSubscription_Match(t *testing.T) {
+	sub := webhook.Subscription{Events: []string{"order.*", "user.deleted"}}
+	assert.True(t, sub.Match("order.created"))
+	assert.True(t, sub.Match("user.deleted"))
+	assert.False(t, sub.Match("user.created"))
+	assert.False(t, sub.Match("orders"))
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-core/internal/redisutil"
+	"github.com/go-spring/spring-core/redis"
+)
+
+// Store 记录已经处理的投递 ID ，用于拒绝重放的请求。
+type Store interface {
+
+	// Mark 记录投递 ID ，投递 ID 已经存在时返回 false 。
+	Mark(ctx context.Context, key string, ttl time.Duration) (bool, error)
+
+	// Delete 删除投递 ID ，之后相同投递 ID 的请求会被再次处理。
+	Delete(ctx context.Context, key string) error
+}
+
+type memoryStore struct {
+	mutex sync.Mutex
+	items map[string]time.Time
+	sweep time.Time // 下次清理过期记录的时间
+}
+
+// NewMemoryStore 创建基于内存的 Store ，适用于单实例部署。
+func NewMemoryStore() Store {
+	return &memoryStore{items: make(map[string]time.Time)}
+}
+
+func (s *memoryStore) Mark(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	if now.After(s.sweep) {
+		for k, expire := range s.items {
+			if now.After(expire) {
+				delete(s.items, k)
+			}
+		}
+		s.sweep = now.Add(time.Minute)
+	}
+	if expire, ok := s.items[key]; ok && now.Before(expire) {
+		return false, nil
+	}
+	s.items[key] = now.Add(ttl)
+	return true, nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.items, key)
+	return nil
+}
+
+// redisStore 基于 redis 的 Store 实现，适用于多实例部署。
+type redisStore struct {
+	store *redisutil.TTLStore
+}
+
+// NewRedisStore 创建基于 redis 的 Store 。
+func NewRedisStore(client *redis.Client) Store {
+	return &redisStore{store: redisutil.NewTTLStore(client)}
+}
+
+func (s *redisStore) Mark(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return s.store.SetNX(ctx, key, "1", ttl)
+}
+
+func (s *redisStore) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
@@ -0,0 +1,272 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package webhook 实现了 webhook 的接收框架。接收地址为 {path}/{provider} ，过滤器
+// 校验提供方的签名和时间戳，通过投递 ID 拒绝重放的请求，然后按照提供方和事件类型将
+// 请求体解码后分发给处理函数，例如：
+//
+//	gs.Object(webhook.On("github", "push", func(ctx context.Context, e *PushEvent) error {
+//		return ci.Trigger(ctx, e.Repository.FullName, e.After)
+//	}))
+//
+// 处理函数返回错误时响应 500 ，并且清除投递 ID 的记录，以便提供方重试时可以再次处理。
+//
+// Sender 负责反方向的发送，将事件总线上实现了 Event 接口的事件签名之后发送给订阅者，
+// 失败时按照指数退避重试，最终失败的消息保存到死信存储，可以通过 Redeliver 重新发送。
+package webhook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-base/util"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/web"
+)
+
+var logger = log.GetLogger("GS_WEBHOOK")
+
+// HeaderDuplicate 重复投递的请求在响应中携带该请求头。
+const HeaderDuplicate = "X-Webhook-Duplicate"
+
+// deliveryKey 投递信息在请求上下文中的 key 。
+const deliveryKey = "::webhook::"
+
+var (
+	ErrNoSignature      = errors.New("webhook: no signature")
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	ErrExpired          = errors.New("webhook: timestamp is outside the tolerance")
+	ErrInvalidPayload   = errors.New("webhook: invalid payload")
+)
+
+// Delivery 一次经过校验的投递。
+type Delivery struct {
+	Provider  string
+	ID        string    // 投递 ID ，提供方没有给出时为请求体的摘要
+	Event     string    // 事件类型
+	Timestamp time.Time // 签名的时间戳，提供方不签名时间戳时为零值
+	Header    http.Header
+	Body      []byte
+}
+
+// GetDelivery 返回过滤器校验之后保存在请求上下文中的投递信息。
+func GetDelivery(ctx web.Context) *Delivery {
+	d, _ := ctx.Get(deliveryKey).(*Delivery)
+	return d
+}
+
+// Config webhook 的配置，通常配合 webhook 前缀一起使用。
+type Config struct {
+	Path      string           `value:"${path:=/webhooks}"`
+	MaxBody   conf.ByteSize    `value:"${max-body:=1MB}"`    // 请求体的最大字节数
+	Prefix    string           `value:"${prefix:=webhook:}"` // 存储中投递 ID 的前缀
+	ReplayTTL time.Duration    `value:"${replay-ttl:=24h}"`  // 投递 ID 的保存时间
+	Providers []ProviderConfig `value:"${providers:=}"`
+}
+
+// Handler 处理某个提供方的某类事件。
+type Handler struct {
+	provider string
+	event    string
+	t        reflect.Type
+	fn       reflect.Value
+}
+
+var (
+	deliveryType = reflect.TypeOf((*Delivery)(nil))
+	bytesType    = reflect.TypeOf([]byte(nil))
+)
+
+// On 创建处理函数，fn 的形式为 func(ctx context.Context, payload T) error ，T 为
+// *Delivery 时传入投递信息，为 []byte 时传入原始的请求体，其他类型通过 json 解码。
+// event 为 * 时处理该提供方的所有事件。
+func On(provider, event string, fn interface{}) *Handler {
+	t := reflect.TypeOf(fn)
+	if !util.IsFuncType(t) || !util.ReturnOnlyError(t) || t.NumIn() != 2 || !util.IsContextType(t.In(0)) {
+		panic(errors.New("fn should be func(context.Context, T) error"))
+	}
+	return &Handler{provider: provider, event: event, t: t.In(1), fn: reflect.ValueOf(fn)}
+}
+
+func (h *Handler) match(d *Delivery) bool {
+	return h.provider == d.Provider && (h.event == "*" || h.event == d.Event)
+}
+
+func (h *Handler) call(ctx context.Context, d *Delivery) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	var arg reflect.Value
+	switch h.t {
+	case deliveryType:
+		arg = reflect.ValueOf(d)
+	case bytesType:
+		arg = reflect.ValueOf(d.Body)
+	default:
+		ptr := reflect.New(h.t)
+		if err = json.Unmarshal(d.Body, ptr.Interface()); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+		}
+		arg = ptr.Elem()
+	}
+	out := h.fn.Call([]reflect.Value{reflect.ValueOf(ctx), arg})
+	err, _ = out[0].Interface().(error)
+	return err
+}
+
+// Receiver 接收 webhook 请求。
+type Receiver struct {
+	config    Config
+	store     Store
+	providers map[string]Provider
+	handlers  []*Handler
+}
+
+// NewReceiver 创建 Receiver ，配置的提供方和注册为 bean 的提供方合并在一起，store
+// 为空时使用 MemoryStore 。
+func NewReceiver(config Config, providers []Provider, handlers []*Handler, store Store) (*Receiver, error) {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	r := &Receiver{
+		config:    config,
+		store:     store,
+		providers: make(map[string]Provider),
+		handlers:  handlers,
+	}
+	for _, c := range config.Providers {
+		p, err := NewProvider(c)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+	for _, p := range providers {
+		if _, ok := r.providers[p.Name()]; ok {
+			return nil, fmt.Errorf("webhook: duplicate provider %s", p.Name())
+		}
+		r.providers[p.Name()] = p
+	}
+	for _, h := range handlers {
+		if _, ok := r.providers[h.provider]; !ok {
+			return nil, fmt.Errorf("webhook: handler for unknown provider %s", h.provider)
+		}
+	}
+	return r, nil
+}
+
+// Path 返回接收地址，路径参数 provider 为提供方的名称。
+func (r *Receiver) Path() string {
+	return strings.TrimSuffix(r.config.Path, "/") + "/{provider}"
+}
+
+// Handler 返回校验签名并分发投递的处理函数。
+func (r *Receiver) Handler() web.Handler {
+	return web.FUNC(func(ctx web.Context) {
+		web.NewFilterChain([]web.Filter{r.Filter(), web.HandlerFilter(web.FUNC(r.Dispatch))}).Next(ctx)
+	})
+}
+
+// Filter 返回校验签名的过滤器，校验通过的投递信息保存在请求上下文中，可以通过
+// GetDelivery 获取。重复投递的请求直接返回 200 ，后续处理返回 5xx 时清除投递 ID
+// 的记录，以便提供方重试时可以再次处理。
+func (r *Receiver) Filter() web.Filter {
+	return web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+
+		name := ctx.PathParam("provider")
+		p, ok := r.providers[name]
+		if !ok {
+			panic(web.NewHttpError(http.StatusNotFound, "unknown webhook provider"))
+		}
+
+		req := ctx.Request()
+		body, err := ioutil.ReadAll(http.MaxBytesReader(nil, req.Body, int64(r.config.MaxBody)))
+		if err != nil {
+			panic(web.NewHttpError(http.StatusRequestEntityTooLarge, err.Error()))
+		}
+
+		d, err := p.Verify(req, body)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if errors.Is(err, ErrInvalidPayload) {
+				status = http.StatusBadRequest
+			}
+			logger.WithContext(ctx.Context()).Warnf("webhook %s: %v", name, err)
+			panic(web.NewHttpError(status, err.Error()))
+		}
+		d.Provider, d.Header, d.Body = name, req.Header, body
+		if d.ID == "" {
+			sum := sha256.Sum256(body)
+			d.ID = hex.EncodeToString(sum[:])
+		}
+
+		key := r.config.Prefix + name + ":" + d.ID
+		ok, err = r.store.Mark(ctx.Context(), key, r.config.ReplayTTL)
+		util.Panic(err).When(err != nil)
+		if !ok {
+			ctx.SetHeader(HeaderDuplicate, "true")
+			ctx.NoContent(http.StatusOK)
+			return
+		}
+
+		completed := false
+		defer func() {
+			if !completed || ctx.ResponseWriter().Status() >= http.StatusInternalServerError {
+				if err := r.store.Delete(context.Background(), key); err != nil {
+					logger.WithContext(ctx.Context()).Errorf(log.ERROR, "webhook %s: %v", name, err)
+				}
+			}
+		}()
+
+		err = ctx.Set(deliveryKey, d)
+		util.Panic(err).When(err != nil)
+		chain.Next(ctx)
+		completed = true
+	})
+}
+
+// Dispatch 将投递分发给匹配的处理函数，所有处理函数都成功时返回 204 。
+func (r *Receiver) Dispatch(ctx web.Context) {
+	d := GetDelivery(ctx)
+	if d == nil {
+		panic(web.NewHttpError(http.StatusInternalServerError, "webhook filter is missing"))
+	}
+	for _, h := range r.handlers {
+		if !h.match(d) {
+			continue
+		}
+		if err := h.call(ctx.Context(), d); err != nil {
+			logger.WithContext(ctx.Context()).Errorf(log.ERROR, "webhook %s %s %s: %v", d.Provider, d.Event, d.ID, err)
+			if errors.Is(err, ErrInvalidPayload) {
+				panic(web.NewHttpError(http.StatusBadRequest, err.Error()))
+			}
+			panic(web.NewHttpError(http.StatusInternalServerError, err.Error()))
+		}
+	}
+	ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,185 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-base/knife"
+	"github.com/go-spring/spring-core/redis"
+	"github.com/go-spring/spring-core/web"
+	"github.com/go-spring/spring-core/webhook"
+)
+
+// pathContext 支持路径参数 provider 的 web.Context 。
+type pathContext struct {
+	*web.BaseContext
+	provider string
+}
+
+func (ctx *pathContext) PathParam(name string) string {
+	return ctx.provider
+}
+
+// serve 调用 Receiver 并返回响应码，处理函数抛出的 *web.HttpError 转换为响应码。
+func serve(r *webhook.Receiver, provider string, req *http.Request) (status int, w *httptest.ResponseRecorder) {
+	ctx, _ := knife.New(req.Context())
+	req = req.WithContext(ctx)
+	w = httptest.NewRecorder()
+	defer func() {
+		if e, ok := recover().(*web.HttpError); ok {
+			status = e.Code
+		}
+	}()
+	r.Handler().Invoke(&pathContext{web.NewBaseContext("", nil, req, &web.BufferedResponseWriter{ResponseWriter: w}), provider})
+	return w.Code, w
+}
+
+type PushEvent struct {
+	Ref string `json:"ref"`
+}
+
+func TestReceiver(t *testing.T) {
+
+	var (
+		refs  []string
+		fail  = true
+		count int
+	)
+	handlers := []*webhook.Handler{
+		webhook.On("github", "push", func(ctx context.Context, e *PushEvent) error {
+			refs = append(refs, e.Ref)
+			return nil
+		}),
+		webhook.On("github", "*", func(ctx context.Context, d *webhook.Delivery) error {
+			count++
+			if d.Event == "release" && fail {
+				fail = false
+				return errors.New("ci is down")
+			}
+			return nil
+		}),
+	}
+
+	config := webhook.Config{
+		Path:      "/webhooks",
+		MaxBody:   64,
+		Prefix:    "webhook:",
+		ReplayTTL: time.Hour,
+		Providers: []webhook.ProviderConfig{{Name: "github", Type: "github", Secrets: []string{"s"}}},
+	}
+	r, err := webhook.NewReceiver(config, nil, handlers, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, r.Path(), "/webhooks/{provider}")
+
+	request := func(event, id, body string) *http.Request {
+		return newRequest(body, map[string]string{
+			"X-Hub-Signature-256": "sha256=" + hexSign(sha256.New, "s", body),
+			"X-GitHub-Event":      event,
+			"X-GitHub-Delivery":   id,
+		})
+	}
+
+	status, _ := serve(r, "github", request("push", "1", `{"ref":"main"}`))
+	assert.Equal(t, status, http.StatusNoContent)
+	assert.Equal(t, refs, []string{"main"})
+	assert.Equal(t, count, 1)
+
+	status, w := serve(r, "github", request("push", "1", `{"ref":"main"}`))
+	assert.Equal(t, status, http.StatusOK)
+	assert.Equal(t, w.Header().Get(webhook.HeaderDuplicate), "true")
+	assert.Equal(t, refs, []string{"main"})
+
+	status, _ = serve(r, "github", request("release", "2", `{}`))
+	assert.Equal(t, status, http.StatusInternalServerError)
+	status, _ = serve(r, "github", request("release", "2", `{}`))
+	assert.Equal(t, status, http.StatusNoContent)
+	assert.Equal(t, count, 3)
+
+	status, _ = serve(r, "github", request("push", "3", `{"ref":1}`))
+	assert.Equal(t, status, http.StatusBadRequest)
+
+	status, _ = serve(r, "github", request("push", "4", `{"ref":"`+strings.Repeat("x", 64)+`"}`))
+	assert.Equal(t, status, http.StatusRequestEntityTooLarge)
+
+	req := request("push", "5", `{"ref":"dev"}`)
+	req.Header.Set("X-Hub-Signature-256", "sha256=00")
+	status, _ = serve(r, "github", req)
+	assert.Equal(t, status, http.StatusUnauthorized)
+
+	status, _ = serve(r, "gitlab", request("push", "6", `{}`))
+	assert.Equal(t, status, http.StatusNotFound)
+	assert.Equal(t, refs, []string{"main"})
+}
+
+func TestNewReceiver(t *testing.T) {
+	config := webhook.Config{Providers: []webhook.ProviderConfig{{Name: "github", Type: "github", Secrets: []string{"s"}}}}
+	_, err := webhook.NewReceiver(config, nil, []*webhook.Handler{
+		webhook.On("stripe", "*", func(ctx context.Context, b []byte) error { return nil }),
+	}, nil)
+	assert.Error(t, err, "webhook: handler for unknown provider stripe")
+	p, _ := webhook.NewProvider(webhook.ProviderConfig{Name: "github", Type: "github", Secrets: []string{"s"}})
+	_, err = webhook.NewReceiver(config, []webhook.Provider{p}, nil, nil)
+	assert.Error(t, err, "webhook: duplicate provider github")
+}
+
+// connPool 只支持 SET 和 DEL 命令的 redis.ConnPool 实现。
+type connPool struct {
+	data map[string]string
+}
+
+func (p *connPool) Exec(ctx context.Context, cmd string, args []interface{}) (interface{}, error) {
+	key := args[0].(string)
+	switch cmd {
+	case "SET":
+		if _, ok := p.data[key]; ok && args[len(args)-1] == "NX" {
+			return nil, redis.ErrNil()
+		}
+		p.data[key] = args[1].(string)
+		return "OK", nil
+	case "DEL":
+		delete(p.data, key)
+		return int64(1), nil
+	}
+	return nil, nil
+}
+
+func TestStore(t *testing.T) {
+	c, err := redis.NewClient(&connPool{data: map[string]string{}})
+	assert.Nil(t, err)
+	ctx := context.Background()
+	for _, s := range []webhook.Store{webhook.NewMemoryStore(), webhook.NewRedisStore(c)} {
+		ok, err := s.Mark(ctx, "k", time.Minute)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		ok, err = s.Mark(ctx, "k", time.Minute)
+		assert.Nil(t, err)
+		assert.False(t, ok)
+		assert.Nil(t, s.Delete(ctx, "k"))
+		ok, err = s.Mark(ctx, "k", time.Minute)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+	}
+}
@@ -18,6 +18,7 @@
 package SpringEcho
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"runtime/debug"
@@ -189,12 +190,22 @@ func (f *recoveryFilter) Invoke(ctx web.Context, chain web.FilterChain) {
 			case web.HttpError:
 				httpE = e
 			case error:
-				httpE.Message = e.Error()
+				var he *web.HttpError
+				if errors.As(e, &he) { // 如读取请求体时返回的 413 和 408 错误
+					httpE = *he
+				} else {
+					httpE.Message = e.Error()
+				}
 			default:
 				httpE.Message = http.StatusText(httpE.Code)
 				httpE.Internal = err
 			}
 
+			// 只上报服务端的错误，主动返回的 4xx 错误不需要上报。
+			if httpE.Code >= http.StatusInternalServerError {
+				web.ReportPanic(ctx, err)
+			}
+
 			echoCtx := EchoContext(ctx)
 			if echoCtx == nil {
 				f.errHandler.Invoke(ctx, &httpE)
@@ -18,6 +18,7 @@
 package SpringGin
 
 import (
+	"errors"
 	"net"
 	"net/http"
 	"os"
@@ -209,12 +210,22 @@ func (f *recoveryFilter) Invoke(webCtx web.Context, chain web.FilterChain) {
 			case web.HttpError:
 				httpE = e
 			case error:
-				httpE.Message = e.Error()
+				var he *web.HttpError
+				if errors.As(e, &he) { // 如读取请求体时返回的 413 和 408 错误
+					httpE = *he
+				} else {
+					httpE.Message = e.Error()
+				}
 			default:
 				httpE.Message = http.StatusText(httpE.Code)
 				httpE.Internal = err
 			}
 
+			// 只上报服务端的错误，主动返回的 4xx 错误不需要上报。
+			if httpE.Code >= http.StatusInternalServerError {
+				web.ReportPanic(webCtx, err)
+			}
+
 			f.errHandler.Invoke(webCtx, &httpE)
 		}
 	}()
@@ -17,19 +17,25 @@
 package SpringGin_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-spring/spring-base/assert"
 	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-base/util"
 	"github.com/go-spring/spring-core/web"
 	"github.com/go-spring/spring-gin"
 )
@@ -240,3 +246,124 @@ func TestContainer_Static(t *testing.T) {
 		assert.Equal(t, string(b), "hello world!")
 	}
 }
+
+func TestContext_MaxBodySize(t *testing.T) {
+	c := SpringGin.New(web.ServerConfig{Port: 8080, MaxBodySize: 8})
+	c.PostMapping("/", func(webCtx web.Context) {
+		b, err := webCtx.RequestBody()
+		if err != nil {
+			panic(fmt.Errorf("read body: %w", err))
+		}
+		webCtx.String(string(b))
+	})
+	go c.Start()
+	defer c.Stop(context.Background())
+	time.Sleep(10 * time.Millisecond)
+
+	for _, s := range []struct {
+		body   io.Reader
+		status int
+	}{
+		{strings.NewReader("12345678"), http.StatusOK},
+		{strings.NewReader("123456789"), http.StatusRequestEntityTooLarge},
+		{ioutil.NopCloser(strings.NewReader("123456789")), http.StatusRequestEntityTooLarge}, // chunked
+	} {
+		response, err := http.Post("http://127.0.0.1:8080/", "text/plain", s.body)
+		if err != nil {
+			panic(err)
+		}
+		b, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		fmt.Println(response.Status, string(b))
+		assert.Equal(t, response.StatusCode, s.status)
+	}
+}
+
+func do(t *testing.T, req *http.Request) (int, string) {
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	return resp.StatusCode, string(b)
+}
+
+// TestParams 和 spring-core 的 nethttp 包以及 spring-echo 中的同名测试保持一致。
+func TestParams(t *testing.T) {
+
+	c := SpringGin.New(web.ServerConfig{Port: 8080})
+	c.GetMapping("/users/:id", func(ctx web.Context) {
+		id, err := web.ParamInt(ctx, "id")
+		util.Panic(err).When(err != nil)
+		page, err := web.ParamInt(ctx, "page", 1)
+		util.Panic(err).When(err != nil)
+		verbose, err := web.ParamBool(ctx, "verbose", false)
+		util.Panic(err).When(err != nil)
+		ctx.String("id=%d page=%d verbose=%v", id, page, verbose)
+	})
+	c.GetMapping("/files/{*:path}", func(ctx web.Context) {
+		path, err := web.ParamString(ctx, "path")
+		util.Panic(err).When(err != nil)
+		ctx.String("path=%s", path)
+	})
+	c.PostMapping("/form", func(ctx web.Context) {
+		name, err := web.ParamString(ctx, "name")
+		util.Panic(err).When(err != nil)
+		size, err := web.ParamInt64(ctx, "size", 0)
+		util.Panic(err).When(err != nil)
+		ctx.String("name=%s size=%d", name, size)
+	})
+
+	go c.Start()
+	defer c.Stop(context.Background())
+	<-c.Started()
+	time.Sleep(10 * time.Millisecond)
+
+	host := "http://127.0.0.1:8080"
+	for _, d := range []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/users/42?page=2", http.StatusOK, "id=42 page=2 verbose=false"},
+		{"/users/42?verbose=true", http.StatusOK, "id=42 page=1 verbose=true"},
+		{"/users/abc", http.StatusBadRequest, `invalid parameter "id" value "abc"`},
+		{"/users/42?page=x", http.StatusBadRequest, `invalid parameter "page" value "x"`},
+		{"/files/a/b.txt", http.StatusOK, "path=a/b.txt"},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, host+d.path, nil)
+		code, body := do(t, req)
+		assert.Equal(t, code, d.code)
+		assert.Equal(t, body, d.body)
+	}
+
+	form := url.Values{"name": {"jim"}, "size": {"3"}}
+	req, _ := http.NewRequest(http.MethodPost, host+"/form", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	code, body := do(t, req)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "name=jim size=3")
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	_ = w.WriteField("name", "tom")
+	_ = w.Close()
+	req, _ = http.NewRequest(http.MethodPost, host+"/form", &buf)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	code, body = do(t, req)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "name=tom size=0")
+
+	req, _ = http.NewRequest(http.MethodPost, host+"/form", strings.NewReader("size=1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	code, body = do(t, req)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.Equal(t, body, `missing required parameter "name"`)
+
+	// 没有 boundary 的 multipart 请求体无法解析。
+	req, _ = http.NewRequest(http.MethodPost, host+"/form", strings.NewReader("name=tom"))
+	req.Header.Set("Content-Type", "multipart/form-data")
+	code, body = do(t, req)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.True(t, strings.HasPrefix(body, "invalid form: "), fmt.Sprintf("unexpected body %q", body))
+}
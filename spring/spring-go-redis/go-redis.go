@@ -23,6 +23,7 @@ import (
 
 	g "github.com/go-redis/redis/v8"
 	"github.com/go-spring/spring-core/redis"
+	"github.com/go-spring/spring-core/waitfor"
 )
 
 func NewClient(config redis.Config) (*redis.Client, error) {
@@ -45,10 +46,14 @@ func Open(config redis.Config) (redis.ConnPool, error) {
 		ReadTimeout:  time.Duration(config.ReadTimeout) * time.Millisecond,
 		WriteTimeout: time.Duration(config.WriteTimeout) * time.Millisecond,
 		IdleTimeout:  time.Duration(config.IdleTimeout) * time.Millisecond,
+		PoolSize:     config.PoolSize,
 	})
 
 	if config.Ping {
-		if err := client.Ping(context.Background()).Err(); err != nil {
+		err := waitfor.Wait("redis "+address, config.WaitFor, func(ctx context.Context) error {
+			return client.Ping(ctx).Err()
+		})
+		if err != nil {
 			return nil, err
 		}
 	}
@@ -0,0 +1,203 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package SpringGraphQL
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DataLoader 批量加载数据，用于解决 N+1 查询问题。Batch 返回的结果和 keys 一一对应，
+// 某个 key 加载失败时对应的结果可以是 error 。
+type DataLoader interface {
+	Name() string
+	Batch(ctx context.Context, keys []interface{}) ([]interface{}, error)
+}
+
+// batchResult 一个 key 的加载结果。
+type batchResult struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// batch 等待执行的一批 key 。
+type batch struct {
+	keys    []interface{}
+	results []*batchResult
+	timer   *time.Timer
+}
+
+// Loader 在一次请求内合并同一个 DataLoader 的加载请求，在等待窗口结束或者达到最大
+// 数量时批量加载，并且在请求内缓存加载结果。
+type Loader struct {
+	loader   DataLoader
+	wait     time.Duration
+	maxBatch int
+	mutex    sync.Mutex
+	cache    map[interface{}]*batchResult
+	pending  *batch
+}
+
+// NewLoader 创建 Loader ，maxBatch 小于等于 0 时不限制每批的数量。
+func NewLoader(loader DataLoader, wait time.Duration, maxBatch int) *Loader {
+	return &Loader{
+		loader:   loader,
+		wait:     wait,
+		maxBatch: maxBatch,
+		cache:    make(map[interface{}]*batchResult),
+	}
+}
+
+// Load 加载 key 对应的数据，key 必须可以作为 map 的键。
+func (l *Loader) Load(ctx context.Context, key interface{}) (interface{}, error) {
+	l.mutex.Lock()
+	r, ok := l.cache[key]
+	if !ok {
+		r = &batchResult{done: make(chan struct{})}
+		l.cache[key] = r
+		l.enqueue(ctx, key, r)
+	}
+	l.mutex.Unlock()
+
+	select {
+	case <-r.done:
+		return r.value, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// LoadMany 加载多个 key 对应的数据，任意一个 key 加载失败时返回错误。
+func (l *Loader) LoadMany(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+	results := make([]interface{}, len(keys))
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key interface{}) {
+			defer wg.Done()
+			results[i], errs[i] = l.Load(ctx, key)
+		}(i, key)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// enqueue 将 key 加入等待执行的批次，调用时必须持有锁。
+func (l *Loader) enqueue(ctx context.Context, key interface{}, r *batchResult) {
+	b := l.pending
+	if b == nil {
+		b = &batch{}
+		l.pending = b
+		b.timer = time.AfterFunc(l.wait, func() {
+			l.mutex.Lock()
+			if l.pending != b {
+				l.mutex.Unlock()
+				return
+			}
+			l.pending = nil
+			l.mutex.Unlock()
+			l.dispatch(ctx, b)
+		})
+	}
+	b.keys = append(b.keys, key)
+	b.results = append(b.results, r)
+	if l.maxBatch > 0 && len(b.keys) >= l.maxBatch {
+		b.timer.Stop()
+		l.pending = nil
+		go l.dispatch(ctx, b)
+	}
+}
+
+// dispatch 执行一批加载。
+func (l *Loader) dispatch(ctx context.Context, b *batch) {
+	values, err := l.batch(ctx, b.keys)
+	for i, r := range b.results {
+		switch {
+		case err != nil:
+			r.err = err
+		case i >= len(values):
+			r.err = fmt.Errorf("dataloader %s: no result for key %v", l.loader.Name(), b.keys[i])
+		default:
+			if e, ok := values[i].(error); ok {
+				r.err = e
+			} else {
+				r.value = values[i]
+			}
+		}
+		close(r.done)
+	}
+}
+
+func (l *Loader) batch(ctx context.Context, keys []interface{}) (values []interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("dataloader %s: panic: %v", l.loader.Name(), r)
+		}
+	}()
+	values, err = l.loader.Batch(ctx, keys)
+	if err == nil && len(values) != len(keys) {
+		err = fmt.Errorf("dataloader %s: returned %d results for %d keys", l.loader.Name(), len(values), len(keys))
+	}
+	return
+}
+
+type loadersKey struct{}
+
+// WithLoaders 为一次请求创建 Loader 并保存到 context 中。
+func WithLoaders(ctx context.Context, loaders []DataLoader, wait time.Duration, maxBatch int) context.Context {
+	m := make(map[string]*Loader, len(loaders))
+	for _, l := range loaders {
+		m[l.Name()] = NewLoader(l, wait, maxBatch)
+	}
+	return context.WithValue(ctx, loadersKey{}, m)
+}
+
+// GetLoader 返回 context 中指定名称的 Loader 。
+func GetLoader(ctx context.Context, name string) (*Loader, error) {
+	m, _ := ctx.Value(loadersKey{}).(map[string]*Loader)
+	if l, ok := m[name]; ok {
+		return l, nil
+	}
+	return nil, fmt.Errorf("dataloader %s not found", name)
+}
+
+// Load 使用 context 中指定名称的 Loader 加载 key 对应的数据。
+func Load(ctx context.Context, name string, key interface{}) (interface{}, error) {
+	l, err := GetLoader(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return l.Load(ctx, key)
+}
+
+// LoadMany 使用 context 中指定名称的 Loader 加载多个 key 对应的数据。
+func LoadMany(ctx context.Context, name string, keys []interface{}) ([]interface{}, error) {
+	l, err := GetLoader(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return l.LoadMany(ctx, keys)
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package SpringGraphQL_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-graphql"
+)
+
+func TestLoader(t *testing.T) {
+
+	t.Run("cache", func(t *testing.T) {
+		l := &authorLoader{}
+		loader := SpringGraphQL.NewLoader(l, 10*time.Millisecond, 0)
+		ctx := context.Background()
+		r, err := loader.LoadMany(ctx, []interface{}{1, 2, 1})
+		assert.Nil(t, err)
+		assert.Equal(t, len(r), 3)
+		assert.Equal(t, r[0].(*Author).Name, "Author1")
+		assert.Equal(t, r[2], r[0])
+		_, err = loader.Load(ctx, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, l.calls, [][]int{{1, 2}})
+	})
+
+	t.Run("max batch", func(t *testing.T) {
+		l := &authorLoader{}
+		loader := SpringGraphQL.NewLoader(l, time.Hour, 2)
+		r, err := loader.LoadMany(context.Background(), []interface{}{1, 2})
+		assert.Nil(t, err)
+		assert.Equal(t, len(r), 2)
+		assert.Equal(t, l.calls, [][]int{{1, 2}})
+	})
+
+	t.Run("error", func(t *testing.T) {
+		loader := SpringGraphQL.NewLoader(&authorLoader{}, time.Millisecond, 0)
+		_, err := loader.LoadMany(context.Background(), []interface{}{1, 0})
+		assert.Error(t, err, "unknown author")
+		_, err = SpringGraphQL.Load(context.Background(), "author", 1)
+		assert.Error(t, err, "dataloader author not found")
+	})
+
+	t.Run("batch error", func(t *testing.T) {
+		loader := SpringGraphQL.NewLoader(errLoader{}, time.Millisecond, 0)
+		_, err := loader.Load(context.Background(), 1)
+		assert.Error(t, err, "dataloader err: returned 0 results for 1 keys")
+	})
+}
+
+type errLoader struct{}
+
+func (errLoader) Name() string { return "err" }
+
+func (errLoader) Batch(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+	return nil, nil
+}
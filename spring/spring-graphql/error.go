@@ -0,0 +1,73 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package SpringGraphQL
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error GraphQL 响应中的错误。处理函数返回的 *Error 被认为是可以展示给客户端的错误，
+// 开启错误屏蔽时只有这类错误和请求本身的错误会返回原始的错误信息。
+type Error struct {
+	Message    string                 `json:"message"`
+	Locations  []Location             `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+	cause      error                  // 处理函数返回的其他错误
+}
+
+// Errorf 创建可以展示给客户端的错误。
+func Errorf(format string, args ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, args...)}
+}
+
+// WithExtension 设置错误的扩展信息，例如错误码，返回错误本身。
+func (e *Error) WithExtension(key string, value interface{}) *Error {
+	if e.Extensions == nil {
+		e.Extensions = make(map[string]interface{})
+	}
+	e.Extensions[key] = value
+	return e
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Internal 是否为处理函数返回的内部错误。
+func (e *Error) Internal() bool {
+	return e.cause != nil
+}
+
+// fieldError 将处理函数返回的错误转换为 *Error 。
+func fieldError(err error, loc Location, path []interface{}) *Error {
+	r := &Error{Message: err.Error(), cause: err}
+	var e *Error
+	if errors.As(err, &e) {
+		r = &Error{Message: e.Message, Extensions: e.Extensions, cause: e.cause}
+	}
+	if loc.Line > 0 {
+		r.Locations = []Location{loc}
+	}
+	r.Path = append([]interface{}(nil), path...)
+	return r
+}
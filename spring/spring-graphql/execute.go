@@ -0,0 +1,525 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package SpringGraphQL
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/go-spring/spring-base/log"
+)
+
+var logger = log.GetLogger("GS_GRAPHQL")
+
+// Request GraphQL 请求。
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Response GraphQL 响应，请求无法执行时没有 Data 字段。
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// Execute 执行 GraphQL 请求。Query 的字段以及列表的元素并发解析，以便 DataLoader
+// 可以合并请求，Mutation 的根字段按照顺序执行。
+func (s *Schema) Execute(ctx context.Context, req *Request) *Response {
+	return s.execute(ctx, req, true)
+}
+
+// execute 执行 GraphQL 请求，mutation 为 false 时不允许执行 Mutation 操作。
+func (s *Schema) execute(ctx context.Context, req *Request, mutation bool) *Response {
+
+	doc, err := parse(req.Query)
+	if err != nil {
+		return &Response{Errors: []*Error{toError(err)}}
+	}
+
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return &Response{Errors: []*Error{toError(err)}}
+	}
+
+	vars, err := op.variables(req.Variables)
+	if err != nil {
+		return &Response{Errors: []*Error{toError(err)}}
+	}
+
+	typeName := TypeQuery
+	if op.kind == "mutation" {
+		if !mutation {
+			return &Response{Errors: []*Error{{Message: "mutation is not allowed over GET"}}}
+		}
+		typeName = TypeMutation
+		if _, ok := s.types[TypeMutation]; !ok {
+			return &Response{Errors: []*Error{{Message: "schema does not support mutation"}}}
+		}
+	}
+
+	e := &executor{schema: s, doc: doc, vars: vars}
+	data := e.selectionSet(ctx, typeName, reflect.Value{}, op.selections, nil, op.kind == "mutation")
+	sortErrors(e.errors)
+	return &Response{Data: data, Errors: e.errors}
+}
+
+func toError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	return &Error{Message: err.Error()}
+}
+
+// operation 返回要执行的操作，文档中有多个操作时必须指定操作名称。
+func (doc *document) operation(name string) (*operation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, &Error{Message: "operationName is required for documents with multiple operations"}
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, &Error{Message: fmt.Sprintf("unknown operation %q", name)}
+}
+
+// variables 返回操作的变量，没有传入的变量使用默认值，非空变量必须传入。
+func (op *operation) variables(input map[string]interface{}) (map[string]interface{}, error) {
+	vars := make(map[string]interface{})
+	for _, v := range op.vars {
+		if val, ok := input[v.name]; ok && val != nil {
+			vars[v.name] = val
+			continue
+		}
+		if v.hasDefault {
+			vars[v.name] = v.def
+			continue
+		}
+		if v.nonNull {
+			return nil, &Error{Message: fmt.Sprintf("variable $%s of non-null type must be provided", v.name)}
+		}
+	}
+	return vars, nil
+}
+
+// sortErrors 并发解析时错误的顺序不确定，按照错误在文档中的位置排序。
+func sortErrors(errs []*Error) {
+	sort.SliceStable(errs, func(i, j int) bool {
+		var a, b Location
+		if len(errs[i].Locations) > 0 {
+			a = errs[i].Locations[0]
+		}
+		if len(errs[j].Locations) > 0 {
+			b = errs[j].Locations[0]
+		}
+		if a != b {
+			return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+		}
+		return fmt.Sprint(errs[i].Path...) < fmt.Sprint(errs[j].Path...)
+	})
+}
+
+// orderedMap 按照字段的顺序序列化为 JSON 对象。
+type orderedMap struct {
+	keys   []string
+	values []interface{}
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		b, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+		buf.WriteByte(':')
+		if b, err = json.Marshal(m.values[i]); err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// executor 执行一次请求。
+type executor struct {
+	schema *Schema
+	doc    *document
+	vars   map[string]interface{}
+	mutex  sync.Mutex
+	errors []*Error
+}
+
+func (e *executor) addError(err *Error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.errors = append(e.errors, err)
+}
+
+// collected 响应键相同的字段，它们的子字段合并在一起。
+type collected struct {
+	key    string
+	fields []*field
+}
+
+func (c *collected) selections() []selection {
+	var r []selection
+	for _, f := range c.fields {
+		r = append(r, f.selections...)
+	}
+	return r
+}
+
+// collectFields 按照响应键收集字段，展开片段并处理 @skip 和 @include 指令。
+func (e *executor) collectFields(typeName string, sels []selection, r *[]*collected, index map[string]*collected, visited map[string]bool) error {
+	for _, sel := range sels {
+		switch s := sel.(type) {
+		case *field:
+			ok, err := e.included(s.directives)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			if c, ok := index[s.alias]; ok {
+				c.fields = append(c.fields, s)
+				continue
+			}
+			c := &collected{key: s.alias, fields: []*field{s}}
+			index[s.alias] = c
+			*r = append(*r, c)
+		case *inlineFragment:
+			ok, err := e.included(s.directives)
+			if err != nil {
+				return err
+			}
+			if !ok || (s.typeCond != "" && s.typeCond != typeName) {
+				continue
+			}
+			if err = e.collectFields(typeName, s.selections, r, index, visited); err != nil {
+				return err
+			}
+		case *fragmentSpread:
+			ok, err := e.included(s.directives)
+			if err != nil {
+				return err
+			}
+			if !ok || visited[s.name] {
+				continue
+			}
+			f, found := e.doc.fragments[s.name]
+			if !found {
+				return &Error{Message: fmt.Sprintf("unknown fragment %q", s.name)}
+			}
+			if f.typeCond != typeName {
+				continue
+			}
+			visited[s.name] = true
+			err = e.collectFields(typeName, f.selections, r, index, visited)
+			delete(visited, s.name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// included 处理 @skip(if:) 和 @include(if:) 指令。
+func (e *executor) included(directives []*directive) (bool, error) {
+	for _, d := range directives {
+		if d.name != "skip" && d.name != "include" {
+			continue
+		}
+		v, err := e.value(d.args["if"])
+		if err != nil {
+			return false, err
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return false, &Error{Message: fmt.Sprintf("@%s(if:) must be a boolean", d.name)}
+		}
+		if b == (d.name == "skip") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// value 替换参数值中的变量和枚举。
+func (e *executor) value(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case variable:
+		return e.vars[string(x)], nil
+	case enumValue:
+		return string(x), nil
+	case []interface{}:
+		r := make([]interface{}, len(x))
+		for i, elem := range x {
+			val, err := e.value(elem)
+			if err != nil {
+				return nil, err
+			}
+			r[i] = val
+		}
+		return r, nil
+	case map[string]interface{}:
+		r := make(map[string]interface{}, len(x))
+		for k, elem := range x {
+			val, err := e.value(elem)
+			if err != nil {
+				return nil, err
+			}
+			r[k] = val
+		}
+		return r, nil
+	}
+	return v, nil
+}
+
+// selectionSet 解析对象的字段，serial 为 true 时按照顺序解析。
+func (e *executor) selectionSet(ctx context.Context, typeName string, parent reflect.Value, sels []selection, path []interface{}, serial bool) *orderedMap {
+
+	var fields []*collected
+	if err := e.collectFields(typeName, sels, &fields, make(map[string]*collected), make(map[string]bool)); err != nil {
+		e.addError(toError(err))
+		return nil
+	}
+
+	r := &orderedMap{keys: make([]string, len(fields)), values: make([]interface{}, len(fields))}
+	resolve := func(i int) {
+		c := fields[i]
+		r.keys[i] = c.key
+		r.values[i] = e.field(ctx, typeName, parent, c, append(path[:len(path):len(path)], c.key))
+	}
+
+	if serial || len(fields) == 1 {
+		for i := range fields {
+			resolve(i)
+		}
+		return r
+	}
+
+	var wg sync.WaitGroup
+	for i := range fields {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resolve(i)
+		}(i)
+	}
+	wg.Wait()
+	return r
+}
+
+// field 解析字段并补全字段的值，出错时字段的值为 null 。
+func (e *executor) field(ctx context.Context, typeName string, parent reflect.Value, c *collected, path []interface{}) (result interface{}) {
+	f := c.fields[0]
+	defer func() {
+		if r := recover(); r != nil {
+			logger.WithContext(ctx).Errorf(log.ERROR, "graphql: panic when resolving %s.%s: %v", typeName, f.name, r)
+			e.addError(fieldError(fmt.Errorf("panic: %v", r), f.loc, path))
+			result = nil
+		}
+	}()
+
+	if f.name == "__typename" {
+		return typeName
+	}
+
+	args, err := e.value(f.args)
+	if err != nil {
+		e.addError(fieldError(err, f.loc, path))
+		return nil
+	}
+
+	v, err := e.resolve(ctx, typeName, parent, f.name, args.(map[string]interface{}))
+	if err != nil {
+		e.addError(fieldError(err, f.loc, path))
+		return nil
+	}
+
+	r, err := e.complete(ctx, f, reflect.ValueOf(v), c.selections(), path)
+	if err != nil {
+		e.addError(fieldError(err, f.loc, path))
+		return nil
+	}
+	return r
+}
+
+// resolve 依次从 Resolver 、对象的方法、结构体字段以及 map 中获取字段的值。
+func (e *executor) resolve(ctx context.Context, typeName string, parent reflect.Value, name string, args map[string]interface{}) (interface{}, error) {
+
+	if f, ok := e.schema.types[typeName][name]; ok {
+		return f.call(ctx, f.fn, parent, args)
+	}
+
+	if parent.IsValid() {
+		if a, ok := typeAccessors(parent.Type())[name]; ok && a.method != nil {
+			return a.method.call(ctx, parent.Method(a.index), reflect.Value{}, args)
+		}
+		v := parent
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			if a, ok := typeAccessors(v.Type())[name]; ok {
+				if a.method != nil {
+					return a.method.call(ctx, v.Method(a.index), reflect.Value{}, args)
+				}
+				return v.Field(a.index).Interface(), nil
+			}
+		case reflect.Map:
+			if v.Type().Key().Kind() == reflect.String {
+				if r := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key())); r.IsValid() {
+					return r.Interface(), nil
+				}
+				return nil, nil
+			}
+		}
+	}
+	return nil, &Error{Message: fmt.Sprintf("cannot query field %q on type %q", name, typeName)}
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// isScalar 是否为标量，实现了 json.Marshaler 或者 encoding.TextMarshaler 的对象也是标量。
+func isScalar(t reflect.Type) bool {
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return false
+	case reflect.Ptr:
+		return isScalar(t.Elem())
+	}
+	return true
+}
+
+// isList 是否为列表，[]byte 以及实现了序列化接口的切片是标量。
+func isList(t reflect.Type) bool {
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Array:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
+
+// complete 补全字段的值，列表的元素并发补全，对象按照子字段解析。
+func (e *executor) complete(ctx context.Context, f *field, v reflect.Value, sels []selection, path []interface{}) (interface{}, error) {
+
+	for v.IsValid() && v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil, nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+	}
+
+	if isList(v.Type()) {
+		r := make([]interface{}, v.Len())
+		var wg sync.WaitGroup
+		for i := 0; i < v.Len(); i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				elemPath := append(path[:len(path):len(path)], i)
+				elem, err := e.complete(ctx, f, v.Index(i), sels, elemPath)
+				if err != nil {
+					e.addError(fieldError(err, f.loc, elemPath))
+				}
+				r[i] = elem
+			}(i)
+		}
+		wg.Wait()
+		return r, nil
+	}
+
+	if isScalar(v.Type()) {
+		if len(sels) > 0 {
+			return nil, &Error{Message: fmt.Sprintf("field %q of scalar type %s must not have a selection", f.name, v.Type())}
+		}
+		return v.Interface(), nil
+	}
+
+	if len(sels) == 0 {
+		return nil, &Error{Message: fmt.Sprintf("field %q of type %q must have a selection of subfields", f.name, typeNameOf(v))}
+	}
+	return e.selectionSet(ctx, typeNameOf(v), v, sels, path, false), nil
+}
+
+// typeNameOf 返回对象的 GraphQL 类型名称，即 Go 类型的名称。
+func typeNameOf(v reflect.Value) string {
+	t := v.Type()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// bindArgs 通过 json 将参数绑定到参数结构体。
+func bindArgs(args map[string]interface{}, t reflect.Type) (reflect.Value, error) {
+	ptr := t.Kind() == reflect.Ptr
+	if ptr {
+		t = t.Elem()
+	}
+	v := reflect.New(t)
+	b, err := json.Marshal(args)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if err = json.Unmarshal(b, v.Interface()); err != nil {
+		return reflect.Value{}, &Error{Message: "invalid arguments: " + err.Error()}
+	}
+	if ptr {
+		return v, nil
+	}
+	return v.Elem(), nil
+}
@@ -0,0 +1,231 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package SpringGraphQL_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-graphql"
+)
+
+type Book struct {
+	ID       int    `json:"id"`
+	Title    string `json:"title"`
+	AuthorID int    `json:"-"`
+}
+
+type Author struct {
+	ID   int
+	Name string
+}
+
+func (a *Author) Initials() string {
+	return a.Name[:1]
+}
+
+type queryResolver struct {
+	books []*Book
+}
+
+func (r *queryResolver) Type() string { return SpringGraphQL.TypeQuery }
+
+func (r *queryResolver) Books() []*Book {
+	return r.books
+}
+
+func (r *queryResolver) Book(args struct {
+	ID int `json:"id"`
+}) (*Book, error) {
+	for _, b := range r.books {
+		if b.ID == args.ID {
+			return b, nil
+		}
+	}
+	return nil, SpringGraphQL.Errorf("book %d not found", args.ID).WithExtension("code", "NOT_FOUND")
+}
+
+func (r *queryResolver) Boom() (string, error) {
+	return "", errors.New("db is down")
+}
+
+func (r *queryResolver) Panic() string {
+	panic("oops")
+}
+
+type mutationResolver struct {
+	mutex sync.Mutex
+	books *[]*Book
+}
+
+func (r *mutationResolver) Type() string { return SpringGraphQL.TypeMutation }
+
+func (r *mutationResolver) AddBook(args *struct {
+	Title string `json:"title"`
+}) *Book {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	b := &Book{ID: len(*r.books) + 1, Title: args.Title, AuthorID: 1}
+	*r.books = append(*r.books, b)
+	return b
+}
+
+type bookResolver struct{}
+
+func (r *bookResolver) Type() string { return "Book" }
+
+func (r *bookResolver) Author(ctx context.Context, b *Book) (*Author, error) {
+	v, err := SpringGraphQL.Load(ctx, "author", b.AuthorID)
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Author), nil
+}
+
+type authorLoader struct {
+	mutex sync.Mutex
+	calls [][]int
+}
+
+func (l *authorLoader) Name() string { return "author" }
+
+func (l *authorLoader) Batch(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+	var ids []int
+	r := make([]interface{}, len(keys))
+	for i, k := range keys {
+		id := k.(int)
+		ids = append(ids, id)
+		if id == 0 {
+			r[i] = errors.New("unknown author")
+			continue
+		}
+		r[i] = &Author{ID: id, Name: "Author" + strconv.Itoa(id)}
+	}
+	sort.Ints(ids)
+	l.mutex.Lock()
+	l.calls = append(l.calls, ids)
+	l.mutex.Unlock()
+	return r, nil
+}
+
+func newSchema(t *testing.T) *SpringGraphQL.Schema {
+	books := []*Book{
+		{ID: 1, Title: "Go", AuthorID: 1},
+		{ID: 2, Title: "Spring", AuthorID: 2},
+		{ID: 3, Title: "GraphQL", AuthorID: 1},
+	}
+	s, err := SpringGraphQL.NewSchema([]SpringGraphQL.Resolver{
+		&queryResolver{books: books},
+		&mutationResolver{books: &books},
+		&bookResolver{},
+	})
+	assert.Nil(t, err)
+	return s
+}
+
+func execute(s *SpringGraphQL.Schema, ctx context.Context, req *SpringGraphQL.Request) string {
+	b, err := json.Marshal(s.Execute(ctx, req))
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+func TestNewSchema(t *testing.T) {
+	_, err := SpringGraphQL.NewSchema([]SpringGraphQL.Resolver{&bookResolver{}})
+	assert.Error(t, err, "no Query resolver found")
+	_, err = SpringGraphQL.NewSchema([]SpringGraphQL.Resolver{&queryResolver{}, &queryResolver{}})
+	assert.Error(t, err, "duplicate field Query.book")
+}
+
+func TestSchema_Execute(t *testing.T) {
+	s := newSchema(t)
+
+	testcases := []struct {
+		req    SpringGraphQL.Request
+		expect string
+	}{
+		{
+			req:    SpringGraphQL.Request{Query: `{ books { id title } }`},
+			expect: `{"data":{"books":[{"id":1,"title":"Go"},{"id":2,"title":"Spring"},{"id":3,"title":"GraphQL"}]}}`,
+		},
+		{
+			req: SpringGraphQL.Request{
+				Query:     `query Q($id: Int!, $withId: Boolean = false) { b: book(id: $id) { __typename id @include(if: $withId) ...F } }  fragment F on Book { title }`,
+				Variables: map[string]interface{}{"id": 2},
+			},
+			expect: `{"data":{"b":{"__typename":"Book","title":"Spring"}}}`,
+		},
+		{
+			req:    SpringGraphQL.Request{Query: `{ book(id: 1) { ... on Book { title } ... on Author { name } } }`},
+			expect: `{"data":{"book":{"title":"Go"}}}`,
+		},
+		{
+			req:    SpringGraphQL.Request{Query: `{ book(id: 9) { title } boom }`},
+			expect: `{"data":{"book":null,"boom":null},"errors":[{"message":"book 9 not found","locations":[{"line":1,"column":3}],"path":["book"],"extensions":{"code":"NOT_FOUND"}},{"message":"db is down","locations":[{"line":1,"column":25}],"path":["boom"]}]}`,
+		},
+		{
+			req:    SpringGraphQL.Request{Query: `{ panic }`},
+			expect: `{"data":{"panic":null},"errors":[{"message":"panic: oops","locations":[{"line":1,"column":3}],"path":["panic"]}]}`,
+		},
+		{
+			req:    SpringGraphQL.Request{Query: `{ unknown }`},
+			expect: `{"data":{"unknown":null},"errors":[{"message":"cannot query field \"unknown\" on type \"Query\"","locations":[{"line":1,"column":3}],"path":["unknown"]}]}`,
+		},
+		{
+			req:    SpringGraphQL.Request{Query: `{ book(id: 1) }`},
+			expect: `{"data":{"book":null},"errors":[{"message":"field \"book\" of type \"Book\" must have a selection of subfields","locations":[{"line":1,"column":3}],"path":["book"]}]}`,
+		},
+		{
+			req:    SpringGraphQL.Request{Query: `query ($id: Int!) { book(id: $id) { title } }`},
+			expect: `{"errors":[{"message":"variable $id of non-null type must be provided"}]}`,
+		},
+		{
+			req:    SpringGraphQL.Request{Query: `{ books { id }`},
+			expect: `{"errors":[{"message":"syntax error: unexpected end of document","locations":[{"line":1,"column":15}]}]}`,
+		},
+		{
+			req:    SpringGraphQL.Request{Query: `query A { books { id } } query B { boom }`},
+			expect: `{"errors":[{"message":"operationName is required for documents with multiple operations"}]}`,
+		},
+		{
+			req:    SpringGraphQL.Request{Query: `mutation { a: addBook(title: "A") { id } b: addBook(title: "B") { id title } }`},
+			expect: `{"data":{"a":{"id":4},"b":{"id":5,"title":"B"}}}`,
+		},
+	}
+
+	for i, c := range testcases {
+		got := execute(s, context.Background(), &c.req)
+		assert.Equal(t, got, c.expect, strconv.Itoa(i))
+	}
+}
+
+func TestDataLoader(t *testing.T) {
+	s := newSchema(t)
+	l := &authorLoader{}
+	ctx := SpringGraphQL.WithLoaders(context.Background(), []SpringGraphQL.DataLoader{l}, 10*time.Millisecond, 100)
+	got := execute(s, ctx, &SpringGraphQL.Request{Query: `{ books { title author { name initials } } }`})
+	assert.Equal(t, got, `{"data":{"books":[{"title":"Go","author":{"name":"Author1","initials":"A"}},{"title":"Spring","author":{"name":"Author2","initials":"A"}},{"title":"GraphQL","author":{"name":"Author1","initials":"A"}}]}}`)
+	assert.Equal(t, l.calls, [][]int{{1, 2}})
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package SpringGraphQL
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/web"
+)
+
+// Config GraphQL 接口的配置。
+type Config struct {
+	Path           string        `value:"${path:=/graphql}"`
+	Playground     bool          `value:"${playground:=false}"`
+	PlaygroundPath string        `value:"${playground-path:=/graphql/playground}"`
+	MaskErrors     bool          `value:"${mask-errors:=false}"`
+	LoaderWait     time.Duration `value:"${loader.wait:=1ms}"`
+	LoaderMaxBatch int           `value:"${loader.max-batch:=100}"`
+}
+
+// Handler GraphQL 的 HTTP 接口，POST 请求的请求体为 JSON 格式的 Request ，GET 请求
+// 通过 query 、operationName 和 variables 参数传递，GET 请求不能执行 Mutation 。
+type Handler struct {
+	config  Config
+	schema  *Schema
+	loaders []DataLoader
+}
+
+// NewHandler 创建 GraphQL 的 HTTP 接口，每个请求使用独立的 DataLoader 缓存。
+func NewHandler(config Config, schema *Schema, loaders []DataLoader) *Handler {
+	return &Handler{config: config, schema: schema, loaders: loaders}
+}
+
+// Config 返回接口的配置。
+func (h *Handler) Config() Config {
+	return h.config
+}
+
+// Handle 处理 GraphQL 请求，请求可以被执行时总是返回 200 ，错误在响应的 errors 中。
+func (h *Handler) Handle(ctx web.Context) {
+
+	var (
+		req      Request
+		mutation bool
+	)
+
+	switch ctx.Request().Method {
+	case http.MethodPost:
+		b, err := ctx.RequestBody()
+		if err != nil {
+			panic(web.NewHttpError(http.StatusBadRequest, err.Error()))
+		}
+		if strings.HasPrefix(ctx.ContentType(), "application/graphql") {
+			req.Query = string(b)
+		} else if err = json.Unmarshal(b, &req); err != nil {
+			h.badRequest(ctx, "invalid request body: "+err.Error())
+			return
+		}
+		mutation = true
+	case http.MethodGet:
+		req.Query = ctx.QueryParam("query")
+		req.OperationName = ctx.QueryParam("operationName")
+		if s := ctx.QueryParam("variables"); s != "" {
+			if err := json.Unmarshal([]byte(s), &req.Variables); err != nil {
+				h.badRequest(ctx, "invalid variables: "+err.Error())
+				return
+			}
+		}
+	default:
+		panic(web.NewHttpError(http.StatusMethodNotAllowed))
+	}
+
+	if strings.TrimSpace(req.Query) == "" {
+		h.badRequest(ctx, "query is required")
+		return
+	}
+
+	c := WithLoaders(ctx.Context(), h.loaders, h.config.LoaderWait, h.config.LoaderMaxBatch)
+	resp := h.schema.execute(c, &req, mutation)
+	if resp.Data == nil && len(resp.Errors) > 0 {
+		ctx.SetStatus(http.StatusBadRequest)
+	}
+	h.maskErrors(ctx, resp)
+	ctx.JSON(resp)
+}
+
+func (h *Handler) badRequest(ctx web.Context, msg string) {
+	ctx.SetStatus(http.StatusBadRequest)
+	ctx.JSON(&Response{Errors: []*Error{{Message: msg}}})
+}
+
+// maskErrors 开启错误屏蔽时将内部错误的信息替换为统一的提示，原始错误记录到日志。
+func (h *Handler) maskErrors(ctx web.Context, resp *Response) {
+	if !h.config.MaskErrors {
+		return
+	}
+	for i, e := range resp.Errors {
+		if !e.Internal() {
+			continue
+		}
+		logger.WithContext(ctx.Context()).Errorf(log.ERROR, "graphql: %v path=%v", e.cause, e.Path)
+		resp.Errors[i] = &Error{
+			Message:   "internal server error",
+			Locations: e.Locations,
+			Path:      e.Path,
+		}
+	}
+}
+
+// HandlePlayground 返回调试 GraphQL 接口的页面，未开启时返回 404 。
+func (h *Handler) HandlePlayground(ctx web.Context) {
+	if !h.config.Playground {
+		panic(web.NewHttpError(http.StatusNotFound))
+	}
+	var buf strings.Builder
+	if err := playground.Execute(&buf, h.config.Path); err != nil {
+		panic(err)
+	}
+	ctx.HTML(buf.String())
+}
+
+var playground = template.Must(template.New("playground").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>GraphQL Playground</title>
+  <link rel="stylesheet" href="https://unpkg.com/graphiql@1.4.7/graphiql.min.css">
+</head>
+<body style="margin: 0;">
+  <div id="graphiql" style="height: 100vh;"></div>
+  <script src="https://unpkg.com/react@17/umd/react.production.min.js"></script>
+  <script src="https://unpkg.com/react-dom@17/umd/react-dom.production.min.js"></script>
+  <script src="https://unpkg.com/graphiql@1.4.7/graphiql.min.js"></script>
+  <script>
+    var fetcher = GraphiQL.createFetcher({ url: {{.}} });
+    ReactDOM.render(React.createElement(GraphiQL, { fetcher: fetcher }), document.getElementById('graphiql'));
+  </script>
+</body>
+</html>
+`))
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package SpringGraphQL_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/web"
+	"github.com/go-spring/spring-graphql"
+)
+
+func TestHandler(t *testing.T) {
+
+	config := SpringGraphQL.Config{Path: "/graphql", Playground: true, MaskErrors: true, LoaderMaxBatch: 100}
+	h := SpringGraphQL.NewHandler(config, newSchema(t), []SpringGraphQL.DataLoader{&authorLoader{}})
+
+	testcases := []struct {
+		method      string
+		query       string
+		contentType string
+		body        string
+		status      int
+		expect      string
+	}{
+		{
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"query":"query ($id: Int!) { book(id: $id) { title author { name } } }","variables":{"id":1}}`,
+			status:      http.StatusOK,
+			expect:      `{"data":{"book":{"title":"Go","author":{"name":"Author1"}}}}`,
+		},
+		{
+			method:      http.MethodPost,
+			contentType: "application/graphql",
+			body:        `{ boom book(id: 9) { id } }`,
+			status:      http.StatusOK,
+			expect:      `{"data":{"boom":null,"book":null},"errors":[{"message":"internal server error","locations":[{"line":1,"column":3}],"path":["boom"]},{"message":"book 9 not found","locations":[{"line":1,"column":8}],"path":["book"],"extensions":{"code":"NOT_FOUND"}}]}`,
+		},
+		{
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"query":`,
+			status:      http.StatusBadRequest,
+			expect:      `{"errors":[{"message":"invalid request body: unexpected end of JSON input"}]}`,
+		},
+		{
+			method: http.MethodGet,
+			query:  "query=" + url.QueryEscape(`query ($id: Int) { book(id: $id) { id } }`) + "&variables=" + url.QueryEscape(`{"id":2}`),
+			status: http.StatusOK,
+			expect: `{"data":{"book":{"id":2}}}`,
+		},
+		{
+			method: http.MethodGet,
+			query:  "query=" + url.QueryEscape(`mutation { addBook(title: "x") { id } }`),
+			status: http.StatusBadRequest,
+			expect: `{"errors":[{"message":"mutation is not allowed over GET"}]}`,
+		},
+		{
+			method: http.MethodGet,
+			status: http.StatusBadRequest,
+			expect: `{"errors":[{"message":"query is required"}]}`,
+		},
+	}
+
+	for i, c := range testcases {
+		r := httptest.NewRequest(c.method, "/graphql?"+c.query, strings.NewReader(c.body))
+		if c.contentType != "" {
+			r.Header.Set(web.HeaderContentType, c.contentType)
+		}
+		w := httptest.NewRecorder()
+		ctx := web.NewBaseContext("/graphql", web.FUNC(h.Handle), r, &web.BufferedResponseWriter{ResponseWriter: w})
+		h.Handle(ctx)
+		assert.Equal(t, w.Code, c.status, strconv.Itoa(i))
+		assert.Equal(t, strings.TrimSpace(w.Body.String()), c.expect, strconv.Itoa(i))
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/graphql/playground", nil)
+	w := httptest.NewRecorder()
+	h.HandlePlayground(web.NewBaseContext("/graphql/playground", nil, r, &web.BufferedResponseWriter{ResponseWriter: w}))
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Matches(t, w.Body.String(), `createFetcher\(\{ url: "/graphql" \}\)`)
+}
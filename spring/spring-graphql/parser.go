@@ -0,0 +1,695 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package SpringGraphQL
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Location 文档中的位置，行号和列号从 1 开始。
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	kind       string // query 或者 mutation
+	name       string
+	vars       []*varDef
+	selections []selection
+}
+
+type varDef struct {
+	name       string
+	nonNull    bool
+	def        interface{}
+	hasDefault bool
+}
+
+type selection interface{}
+
+type field struct {
+	loc        Location
+	alias      string
+	name       string
+	args       map[string]interface{}
+	directives []*directive
+	selections []selection
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []*directive
+}
+
+type inlineFragment struct {
+	typeCond   string
+	directives []*directive
+	selections []selection
+}
+
+type fragment struct {
+	typeCond   string
+	selections []selection
+}
+
+type directive struct {
+	name string
+	args map[string]interface{}
+}
+
+// variable 值中对变量的引用。
+type variable string
+
+// enumValue 值中的枚举，执行时作为字符串使用。
+type enumValue string
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	loc   Location
+}
+
+// lexer GraphQL 文档的词法分析器，逗号和注释被忽略。
+type lexer struct {
+	src  string
+	pos  int
+	line int
+	col  int
+}
+
+func (l *lexer) errorf(loc Location, format string, args ...interface{}) error {
+	return &Error{
+		Message:   "syntax error: " + fmt.Sprintf(format, args...),
+		Locations: []Location{loc},
+	}
+}
+
+func (l *lexer) advance(n int) {
+	for i := 0; i < n; i++ {
+		if l.src[l.pos] == '\n' {
+			l.line++
+			l.col = 1
+		} else {
+			l.col++
+		}
+		l.pos++
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			l.advance(1)
+			continue
+		}
+		if c == '#' {
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.advance(1)
+			}
+			continue
+		}
+		break
+	}
+	loc := Location{Line: l.line, Column: l.col}
+	if l.pos >= len(l.src) {
+		return token{kind: tokenEOF, loc: loc}, nil
+	}
+	c := l.src[l.pos]
+	switch {
+	case strings.IndexByte("!$()=:@[]{}|&", c) >= 0:
+		l.advance(1)
+		return token{kind: tokenPunct, value: string(c), loc: loc}, nil
+	case c == '.':
+		if strings.HasPrefix(l.src[l.pos:], "...") {
+			l.advance(3)
+			return token{kind: tokenPunct, value: "...", loc: loc}, nil
+		}
+	case c == '_' || isLetter(c):
+		start := l.pos
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.advance(1)
+		}
+		return token{kind: tokenName, value: l.src[start:l.pos], loc: loc}, nil
+	case c == '-' || isDigit(c):
+		return l.number(loc)
+	case c == '"':
+		return l.string(loc)
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return token{}, l.errorf(loc, "unexpected character %q", r)
+}
+
+func (l *lexer) number(loc Location) (token, error) {
+	start := l.pos
+	kind := tokenInt
+	if l.src[l.pos] == '-' {
+		l.advance(1)
+	}
+	digits := func() int {
+		n := 0
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.advance(1)
+			n++
+		}
+		return n
+	}
+	if digits() == 0 {
+		return token{}, l.errorf(loc, "invalid number")
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = tokenFloat
+		l.advance(1)
+		if digits() == 0 {
+			return token{}, l.errorf(loc, "invalid number")
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = tokenFloat
+		l.advance(1)
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.advance(1)
+		}
+		if digits() == 0 {
+			return token{}, l.errorf(loc, "invalid number")
+		}
+	}
+	return token{kind: kind, value: l.src[start:l.pos], loc: loc}, nil
+}
+
+func (l *lexer) string(loc Location) (token, error) {
+	if strings.HasPrefix(l.src[l.pos:], `"""`) {
+		l.advance(3)
+		end := strings.Index(l.src[l.pos:], `"""`)
+		if end < 0 {
+			return token{}, l.errorf(loc, "unterminated string")
+		}
+		s := l.src[l.pos : l.pos+end]
+		l.advance(end + 3)
+		return token{kind: tokenString, value: blockString(s), loc: loc}, nil
+	}
+	l.advance(1)
+	var sb strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch c {
+		case '"':
+			l.advance(1)
+			return token{kind: tokenString, value: sb.String(), loc: loc}, nil
+		case '\n', '\r':
+			return token{}, l.errorf(loc, "unterminated string")
+		case '\\':
+			if l.pos+1 >= len(l.src) {
+				return token{}, l.errorf(loc, "unterminated string")
+			}
+			e := l.src[l.pos+1]
+			switch e {
+			case '"', '\\', '/':
+				sb.WriteByte(e)
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'u':
+				if l.pos+6 > len(l.src) {
+					return token{}, l.errorf(loc, "invalid unicode escape")
+				}
+				n, err := strconv.ParseUint(l.src[l.pos+2:l.pos+6], 16, 32)
+				if err != nil {
+					return token{}, l.errorf(loc, "invalid unicode escape")
+				}
+				sb.WriteRune(rune(n))
+				l.advance(4)
+			default:
+				return token{}, l.errorf(loc, "invalid escape \\%c", e)
+			}
+			l.advance(2)
+		default:
+			sb.WriteByte(c)
+			l.advance(1)
+		}
+	}
+	return token{}, l.errorf(loc, "unterminated string")
+}
+
+// blockString 去掉块字符串的公共缩进以及首尾的空行。
+func blockString(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i := 1; i < len(lines) && indent > 0; i++ {
+		if len(lines[i]) >= indent {
+			lines[i] = lines[i][indent:]
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// parser GraphQL 查询文档的语法分析器，不支持类型系统的定义。
+type parser struct {
+	lexer *lexer
+	tok   token
+}
+
+// parse 解析查询文档。
+func parse(query string) (*document, error) {
+	p := &parser{lexer: &lexer{src: query, line: 1, col: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	doc := &document{fragments: make(map[string]*fragment)}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peek(tokenPunct, "{"):
+			sels, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &operation{kind: "query", selections: sels})
+		case p.peek(tokenName, "query"), p.peek(tokenName, "mutation"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case p.peek(tokenName, "fragment"):
+			if err := p.fragment(doc); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, &Error{Message: "syntax error: no operation found"}
+	}
+	return doc, nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) peek(kind tokenKind, value string) bool {
+	return p.tok.kind == kind && p.tok.value == value
+}
+
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokenEOF {
+		return p.lexer.errorf(p.tok.loc, "unexpected end of document")
+	}
+	return p.lexer.errorf(p.tok.loc, "unexpected %q", p.tok.value)
+}
+
+// skip 当前为指定的标点时跳过并返回 true 。
+func (p *parser) skip(punct string) (bool, error) {
+	if !p.peek(tokenPunct, punct) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.peek(tokenPunct, punct) {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.unexpected()
+	}
+	s := p.tok.value
+	return s, p.advance()
+}
+
+func (p *parser) operation() (*operation, error) {
+	op := &operation{kind: p.tok.value}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokenName {
+		op.name = p.tok.value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if ok, err := p.skip("("); err != nil {
+		return nil, err
+	} else if ok {
+		for !p.peek(tokenPunct, ")") {
+			v, err := p.varDef()
+			if err != nil {
+				return nil, err
+			}
+			op.vars = append(op.vars, v)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	sels, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = sels
+	return op, nil
+}
+
+func (p *parser) varDef() (*varDef, error) {
+	if err := p.expect("$"); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(":"); err != nil {
+		return nil, err
+	}
+	v := &varDef{name: name}
+	if v.nonNull, err = p.typeRef(); err != nil {
+		return nil, err
+	}
+	if ok, err := p.skip("="); err != nil {
+		return nil, err
+	} else if ok {
+		if v.def, err = p.value(true); err != nil {
+			return nil, err
+		}
+		v.hasDefault = true
+	}
+	return v, nil
+}
+
+// typeRef 解析变量的类型，返回是否为非空类型，执行时不校验变量的类型。
+func (p *parser) typeRef() (bool, error) {
+	if ok, err := p.skip("["); err != nil {
+		return false, err
+	} else if ok {
+		if _, err = p.typeRef(); err != nil {
+			return false, err
+		}
+		if err = p.expect("]"); err != nil {
+			return false, err
+		}
+	} else if _, err = p.name(); err != nil {
+		return false, err
+	}
+	return p.skip("!")
+}
+
+func (p *parser) fragment(doc *document) error {
+	if err := p.advance(); err != nil {
+		return err
+	}
+	name, err := p.name()
+	if err != nil {
+		return err
+	}
+	if !p.peek(tokenName, "on") {
+		return p.unexpected()
+	}
+	if err = p.advance(); err != nil {
+		return err
+	}
+	f := &fragment{}
+	if f.typeCond, err = p.name(); err != nil {
+		return err
+	}
+	if _, err = p.directives(); err != nil {
+		return err
+	}
+	if f.selections, err = p.selectionSet(); err != nil {
+		return err
+	}
+	if _, ok := doc.fragments[name]; ok {
+		return &Error{Message: fmt.Sprintf("duplicate fragment %q", name)}
+	}
+	doc.fragments[name] = f
+	return nil
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var sels []selection
+	for !p.peek(tokenPunct, "}") {
+		s, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, s)
+	}
+	if len(sels) == 0 {
+		return nil, p.unexpected()
+	}
+	return sels, p.advance()
+}
+
+func (p *parser) selection() (selection, error) {
+	if ok, err := p.skip("..."); err != nil {
+		return nil, err
+	} else if ok {
+		if p.tok.kind == tokenName && p.tok.value != "on" {
+			s := &fragmentSpread{name: p.tok.value}
+			if err = p.advance(); err != nil {
+				return nil, err
+			}
+			s.directives, err = p.directives()
+			return s, err
+		}
+		s := &inlineFragment{}
+		if p.peek(tokenName, "on") {
+			if err = p.advance(); err != nil {
+				return nil, err
+			}
+			if s.typeCond, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		if s.directives, err = p.directives(); err != nil {
+			return nil, err
+		}
+		s.selections, err = p.selectionSet()
+		return s, err
+	}
+
+	f := &field{loc: p.tok.loc}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := p.skip(":"); err != nil {
+		return nil, err
+	} else if ok {
+		f.alias = name
+		if name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	f.name = name
+	if f.alias == "" {
+		f.alias = name
+	}
+	if f.args, err = p.arguments(); err != nil {
+		return nil, err
+	}
+	if f.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.peek(tokenPunct, "{") {
+		if f.selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (p *parser) arguments() (map[string]interface{}, error) {
+	if ok, err := p.skip("("); err != nil || !ok {
+		return nil, err
+	}
+	args := make(map[string]interface{})
+	for !p.peek(tokenPunct, ")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(":"); err != nil {
+			return nil, err
+		}
+		if args[name], err = p.value(false); err != nil {
+			return nil, err
+		}
+	}
+	return args, p.advance()
+}
+
+func (p *parser) directives() ([]*directive, error) {
+	var r []*directive
+	for p.peek(tokenPunct, "@") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		d := &directive{name: name}
+		if d.args, err = p.arguments(); err != nil {
+			return nil, err
+		}
+		r = append(r, d)
+	}
+	return r, nil
+}
+
+// value 解析参数值，const 为 true 时不允许引用变量。
+func (p *parser) value(isConst bool) (interface{}, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokenInt:
+		n, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, p.lexer.errorf(tok.loc, "invalid int %s", tok.value)
+		}
+		return n, p.advance()
+	case tokenFloat:
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, p.lexer.errorf(tok.loc, "invalid float %s", tok.value)
+		}
+		return f, p.advance()
+	case tokenString:
+		return tok.value, p.advance()
+	case tokenName:
+		var v interface{}
+		switch tok.value {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = enumValue(tok.value)
+		}
+		return v, p.advance()
+	}
+	switch tok.value {
+	case "$":
+		if isConst {
+			return nil, p.unexpected()
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		return variable(name), err
+	case "[":
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		list := make([]interface{}, 0)
+		for !p.peek(tokenPunct, "]") {
+			v, err := p.value(isConst)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, p.advance()
+	case "{":
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		obj := make(map[string]interface{})
+		for !p.peek(tokenPunct, "}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect(":"); err != nil {
+				return nil, err
+			}
+			if obj[name], err = p.value(isConst); err != nil {
+				return nil, err
+			}
+		}
+		return obj, p.advance()
+	}
+	return nil, p.unexpected()
+}
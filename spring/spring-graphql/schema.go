@@ -0,0 +1,230 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package SpringGraphQL 实现了代码优先的 GraphQL 服务。Query 、Mutation 以及对象类型的
+// 字段由注册的 Resolver 提供，其他对象类型的字段直接从 Go 对象的方法、结构体字段或者
+// map 中解析，对象的类型名称即 Go 类型的名称。
+package SpringGraphQL
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 根类型的名称。
+const (
+	TypeQuery    = "Query"
+	TypeMutation = "Mutation"
+)
+
+// Resolver 为 GraphQL 类型提供字段的解析器。Type 返回 Query 、Mutation 或者对象类型的
+// 名称，除 Type 以外的导出方法都是该类型的字段，方法名首字母小写之后作为字段名。方法的
+// 形式为 func([ctx context.Context][, parent *T][, args *Args]) (result[, error]) ，
+// 对象类型的字段方法必须接收父对象，参数通过 json 标签绑定到 Args 结构体。
+type Resolver interface {
+	Type() string
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// signature 字段函数的签名。
+type signature struct {
+	hasCtx    bool
+	hasParent bool
+	argsType  reflect.Type // 参数结构体的类型，可能是指针
+	hasErr    bool
+}
+
+// newSignature 分析函数的签名，跳过前 skip 个参数(即方法的接收者)，parent 为 true 表示
+// 函数需要接收父对象。
+func newSignature(t reflect.Type, skip int, parent bool) (*signature, error) {
+	sig := &signature{hasParent: parent}
+	i := skip
+	if i < t.NumIn() && t.In(i) == contextType {
+		sig.hasCtx = true
+		i++
+	}
+	if parent {
+		if i >= t.NumIn() {
+			return nil, errors.New("should receive the parent object")
+		}
+		i++
+	}
+	if i < t.NumIn() {
+		a := t.In(i)
+		if a.Kind() == reflect.Ptr {
+			a = a.Elem()
+		}
+		if a.Kind() != reflect.Struct {
+			return nil, errors.New("arguments should be a struct")
+		}
+		sig.argsType = t.In(i)
+		i++
+	}
+	if i < t.NumIn() || t.IsVariadic() {
+		return nil, errors.New("too many parameters")
+	}
+	switch t.NumOut() {
+	case 1:
+		if t.Out(0) == errorType {
+			return nil, errors.New("should return a result")
+		}
+	case 2:
+		if t.Out(1) != errorType {
+			return nil, errors.New("the second result should be error")
+		}
+		sig.hasErr = true
+	default:
+		return nil, errors.New("should return (result[, error])")
+	}
+	return sig, nil
+}
+
+// call 调用字段函数，args 为已经替换了变量的参数。
+func (sig *signature) call(ctx context.Context, fn reflect.Value, parent reflect.Value, args map[string]interface{}) (interface{}, error) {
+	var in []reflect.Value
+	if sig.hasCtx {
+		in = append(in, reflect.ValueOf(ctx))
+	}
+	if sig.hasParent {
+		pt := fn.Type().In(len(in))
+		switch {
+		case !parent.IsValid():
+			parent = reflect.Zero(pt)
+		case parent.Type().AssignableTo(pt):
+		case parent.Kind() == reflect.Ptr && parent.Elem().Type().AssignableTo(pt):
+			parent = parent.Elem()
+		default:
+			return nil, fmt.Errorf("parent %s is not assignable to %s", parent.Type(), pt)
+		}
+		in = append(in, parent)
+	}
+	if sig.argsType != nil {
+		v, err := bindArgs(args, sig.argsType)
+		if err != nil {
+			return nil, err
+		}
+		in = append(in, v)
+	}
+	out := fn.Call(in)
+	if sig.hasErr && !out[1].IsNil() {
+		return nil, out[1].Interface().(error)
+	}
+	return out[0].Interface(), nil
+}
+
+// fieldFunc Resolver 提供的字段。
+type fieldFunc struct {
+	*signature
+	fn reflect.Value
+}
+
+// Schema 由 Resolver 组成的 GraphQL 服务。
+type Schema struct {
+	types map[string]map[string]*fieldFunc
+}
+
+// NewSchema 根据 Resolver 创建 Schema ，必须至少有一个 Query 类型的 Resolver ，相同
+// 类型的多个 Resolver 的字段合并在一起，字段名不能重复。
+func NewSchema(resolvers []Resolver) (*Schema, error) {
+	s := &Schema{types: make(map[string]map[string]*fieldFunc)}
+	for _, r := range resolvers {
+		typeName := r.Type()
+		fields := s.types[typeName]
+		if fields == nil {
+			fields = make(map[string]*fieldFunc)
+			s.types[typeName] = fields
+		}
+		parent := typeName != TypeQuery && typeName != TypeMutation
+		v := reflect.ValueOf(r)
+		t := v.Type()
+		for i := 0; i < t.NumMethod(); i++ {
+			m := t.Method(i)
+			if m.Name == "Type" {
+				continue
+			}
+			name := lowerFirst(m.Name)
+			if _, ok := fields[name]; ok {
+				return nil, fmt.Errorf("duplicate field %s.%s", typeName, name)
+			}
+			sig, err := newSignature(m.Type, 1, parent)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", typeName, name, err)
+			}
+			fields[name] = &fieldFunc{signature: sig, fn: v.Method(i)}
+		}
+	}
+	if _, ok := s.types[TypeQuery]; !ok {
+		return nil, errors.New("no Query resolver found")
+	}
+	return s, nil
+}
+
+func lowerFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[n:]
+}
+
+// accessor 从 Go 对象中读取字段的方式。
+type accessor struct {
+	method *signature // 对象的方法，不需要父对象
+	index  int        // 方法或者结构体字段的下标
+}
+
+var accessors sync.Map // map[reflect.Type]map[string]*accessor
+
+// typeAccessors 返回 Go 类型的字段，方法优先于结构体字段，结构体字段名使用 json
+// 标签或者首字母小写的字段名。
+func typeAccessors(t reflect.Type) map[string]*accessor {
+	if v, ok := accessors.Load(t); ok {
+		return v.(map[string]*accessor)
+	}
+	m := make(map[string]*accessor)
+	if t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" || sf.Anonymous {
+				continue
+			}
+			name := lowerFirst(sf.Name)
+			if tag, ok := sf.Tag.Lookup("json"); ok {
+				if tag = strings.Split(tag, ",")[0]; tag == "-" {
+					continue
+				} else if tag != "" {
+					name = tag
+				}
+			}
+			m[name] = &accessor{index: i}
+		}
+	}
+	for i := 0; i < t.NumMethod(); i++ {
+		method := t.Method(i)
+		if sig, err := newSignature(method.Type, 1, false); err == nil {
+			m[lowerFirst(method.Name)] = &accessor{method: sig, index: i}
+		}
+	}
+	accessors.Store(t, m)
+	return m
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package SpringProtobuf 为 BIND 形式的 Web 处理函数提供 protobuf 和 proto-JSON 编解码器，
+// 处理函数可以直接使用生成的 proto 消息作为参数和返回值，和 gRPC 服务共用相同的类型。
+// 导入该包时自动注册编解码器。
+package SpringProtobuf
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/go-spring/spring-core/web"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// MIMEApplicationXProtobuf protobuf 常用的另一种媒体类型。
+const MIMEApplicationXProtobuf = "application/x-protobuf"
+
+var messageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
+var errNotMessage = errors.New("value is not a proto.Message")
+
+func init() {
+	web.RegisterCodec(Codec{})
+	web.RegisterCodec(JSONCodec{
+		MarshalOptions:   protojson.MarshalOptions{EmitUnpopulated: true},
+		UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+	})
+}
+
+// supports 是否为 proto 消息类型。
+func supports(t reflect.Type) bool {
+	return t != nil && t.Implements(messageType)
+}
+
+// Codec 使用 protobuf 二进制格式的编解码器。
+type Codec struct{}
+
+func (Codec) MediaTypes() []string {
+	return []string{web.MIMEApplicationProtobuf, MIMEApplicationXProtobuf}
+}
+
+func (Codec) Supports(t reflect.Type) bool {
+	return supports(t)
+}
+
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, errNotMessage
+	}
+	return proto.Marshal(m)
+}
+
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return errNotMessage
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// JSONCodec 使用 proto-JSON 格式的编解码器，字段名、枚举以及 Timestamp 等内置类型
+// 的格式和 gRPC-Gateway 保持一致。
+type JSONCodec struct {
+	MarshalOptions   protojson.MarshalOptions
+	UnmarshalOptions protojson.UnmarshalOptions
+}
+
+func (JSONCodec) MediaTypes() []string {
+	return []string{web.MIMEApplicationJSON}
+}
+
+func (JSONCodec) Supports(t reflect.Type) bool {
+	return supports(t)
+}
+
+func (c JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, errNotMessage
+	}
+	return c.MarshalOptions.Marshal(m)
+}
+
+func (c JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return errNotMessage
+	}
+	return c.UnmarshalOptions.Unmarshal(data, m)
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package SpringProtobuf_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/web"
+	"github.com/go-spring/spring-protobuf"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func invoke(contentType, accept string, body []byte) *httptest.ResponseRecorder {
+	h := web.BIND(func(ctx context.Context, req *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		return wrapperspb.String("hello " + req.GetValue()), nil
+	})
+	r := httptest.NewRequest(http.MethodPost, "/hello", bytes.NewReader(body))
+	r.Header.Set(web.HeaderContentType, contentType)
+	if accept != "" {
+		r.Header.Set(web.HeaderAccept, accept)
+	}
+	w := httptest.NewRecorder()
+	h.Invoke(web.NewBaseContext("/hello", h, r, &web.BufferedResponseWriter{ResponseWriter: w}))
+	return w
+}
+
+func TestCodec(t *testing.T) {
+
+	b, err := proto.Marshal(wrapperspb.String("jim"))
+	assert.Nil(t, err)
+
+	w := invoke(web.MIMEApplicationProtobuf, SpringProtobuf.MIMEApplicationXProtobuf, b)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Header().Get(web.HeaderContentType), SpringProtobuf.MIMEApplicationXProtobuf)
+	resp := new(wrapperspb.StringValue)
+	assert.Nil(t, proto.Unmarshal(w.Body.Bytes(), resp))
+	assert.Equal(t, resp.GetValue(), "hello jim")
+
+	// StringValue 的 proto-JSON 格式是一个字符串。
+	w = invoke(web.MIMEApplicationProtobuf, "", b)
+	assert.Equal(t, w.Header().Get(web.HeaderContentType), web.MIMEApplicationJSON)
+	assert.Equal(t, w.Body.String(), `"hello jim"`)
+
+	w = invoke(web.MIMEApplicationJSONCharsetUTF8, web.MIMEApplicationProtobuf, []byte(`"tom"`))
+	assert.Nil(t, proto.Unmarshal(w.Body.Bytes(), resp))
+	assert.Equal(t, resp.GetValue(), "hello tom")
+
+	assert.Panic(t, func() {
+		invoke(web.MIMEApplicationJSON, "", []byte(`{`))
+	}, "invalid value for string type")
+
+	c := SpringProtobuf.Codec{}
+	_, err = c.Marshal(strings.NewReader(""))
+	assert.Error(t, err, "value is not a proto.Message")
+}
@@ -3,6 +3,7 @@ module github.com/go-spring/spring-redigo
 go 1.14
 
 require (
+	github.com/go-spring/spring-base v1.1.0-rc3
 	github.com/go-spring/spring-core v1.1.0-rc3
 	github.com/gomodule/redigo v1.8.5
 )
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/go-spring/spring-core/redis"
+	"github.com/go-spring/spring-core/waitfor"
 	g "github.com/gomodule/redigo/redis"
 )
 
@@ -36,26 +37,39 @@ func NewClient(config redis.Config) (*redis.Client, error) {
 func Open(config redis.Config) (redis.ConnPool, error) {
 
 	address := fmt.Sprintf("%s:%d", config.Host, config.Port)
-	conn, err := g.Dial("tcp", address,
-		g.DialUsername(config.Username),
-		g.DialPassword(config.Password),
-		g.DialDatabase(config.Database),
-		g.DialConnectTimeout(time.Duration(config.ConnectTimeout)*time.Millisecond),
-		g.DialReadTimeout(time.Duration(config.ReadTimeout)*time.Millisecond),
-		g.DialWriteTimeout(time.Duration(config.WriteTimeout)*time.Millisecond))
+	var conn g.Conn
+	err := waitfor.Wait("redis "+address, config.WaitFor, func(ctx context.Context) error {
+		c, err := g.Dial("tcp", address, dialOptions(config)...)
+		if err != nil {
+			return err
+		}
+		if config.Ping {
+			if _, err = c.Do("PING"); err != nil {
+				_ = c.Close()
+				return err
+			}
+		}
+		conn = c
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if config.Ping {
-		if _, err = conn.Do("PING"); err != nil {
-			return nil, err
-		}
-	}
-
 	return &Conn{conn: conn}, nil
 }
 
+func dialOptions(config redis.Config) []g.DialOption {
+	return []g.DialOption{
+		g.DialUsername(config.Username),
+		g.DialPassword(config.Password),
+		g.DialDatabase(config.Database),
+		g.DialConnectTimeout(time.Duration(config.ConnectTimeout) * time.Millisecond),
+		g.DialReadTimeout(time.Duration(config.ReadTimeout) * time.Millisecond),
+		g.DialWriteTimeout(time.Duration(config.WriteTimeout) * time.Millisecond),
+	}
+}
+
 type Conn struct {
 	conn g.Conn
 }
@@ -0,0 +1,183 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package SpringRedigo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/redis"
+	g "github.com/gomodule/redigo/redis"
+)
+
+type dialFunc func(ctx context.Context, options ...g.DialOption) (g.Conn, error)
+
+// Relay 基于 redis 的 PUBLISH 和 PSUBSCRIBE 实现的 pubsub.Relay ，主题 topic
+// 的消息发布到 prefix+topic 频道。订阅使用单独的长连接，连接断开时自动重新订阅，
+// 断开期间发布的消息会丢失，与 redis 的发布订阅语义一致。
+type Relay struct {
+	pool   *g.Pool
+	dial   dialFunc
+	prefix string
+	retry  time.Duration // 重新订阅的间隔
+}
+
+// NewRelay 创建基于 redis 的 pubsub.Relay ，例如：
+//
+//	gs.Provide(SpringRedigo.NewRelay, "${redis}", "${pubsub.relay.prefix:=pubsub:}").
+//		Export((*pubsub.Relay)(nil)).
+//		Destroy(func(r *SpringRedigo.Relay) { _ = r.Close() })
+func NewRelay(config redis.Config, prefix string) *Relay {
+	address := fmt.Sprintf("%s:%d", config.Host, config.Port)
+	return newRelay(func(ctx context.Context, options ...g.DialOption) (g.Conn, error) {
+		return g.DialContext(ctx, "tcp", address, append(dialOptions(config), options...)...)
+	}, prefix)
+}
+
+func newRelay(dial dialFunc, prefix string) *Relay {
+	return &Relay{
+		pool: &g.Pool{
+			MaxIdle:     2,
+			IdleTimeout: time.Minute,
+			DialContext: func(ctx context.Context) (g.Conn, error) { return dial(ctx) },
+		},
+		dial:   dial,
+		prefix: prefix,
+		retry:  time.Second,
+	}
+}
+
+// Publish 发布消息到主题对应的频道。
+func (r *Relay) Publish(ctx context.Context, topic string, data []byte) error {
+	c, err := r.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	_, err = c.Do("PUBLISH", r.prefix+topic, data)
+	return err
+}
+
+// Subscribe 订阅所有主题的消息，fn 在接收消息的 goroutine 中串行执行。
+func (r *Relay) Subscribe(fn func(topic string, data []byte)) (cancel func(), err error) {
+	c, err := r.subscribe()
+	if err != nil {
+		return nil, err
+	}
+	s := &subscription{relay: r, fn: fn, conn: c, done: make(chan struct{})}
+	go s.run()
+	return s.close, nil
+}
+
+// subscribe 创建没有读超时的连接并订阅所有主题的频道。
+func (r *Relay) subscribe() (g.Conn, error) {
+	c, err := r.dial(context.Background(), g.DialReadTimeout(0))
+	if err != nil {
+		return nil, err
+	}
+	if err = (g.PubSubConn{Conn: c}).PSubscribe(r.prefix + "*"); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close 关闭发布消息使用的连接池。
+func (r *Relay) Close() error {
+	return r.pool.Close()
+}
+
+// subscription 一个订阅，连接断开之后每隔 retry 重新订阅一次，直到被取消。
+type subscription struct {
+	relay *Relay
+	fn    func(topic string, data []byte)
+	mutex sync.Mutex
+	conn  g.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func (s *subscription) run() {
+	for {
+		err := s.receive()
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		log.Errorf("redis relay %s* disconnected: %v", s.relay.prefix, err)
+		if !s.reconnect() {
+			return
+		}
+	}
+}
+
+// receive 接收并分发消息，直到连接出错。
+func (s *subscription) receive() error {
+	s.mutex.Lock()
+	psc := g.PubSubConn{Conn: s.conn}
+	s.mutex.Unlock()
+	for {
+		switch v := psc.Receive().(type) {
+		case g.Message:
+			s.fn(strings.TrimPrefix(v.Channel, s.relay.prefix), v.Data)
+		case error:
+			_ = psc.Close()
+			return v
+		}
+	}
+}
+
+// reconnect 重新订阅直到成功，订阅被取消时返回 false 。
+func (s *subscription) reconnect() bool {
+	for {
+		select {
+		case <-s.done:
+			return false
+		case <-time.After(s.relay.retry):
+		}
+		c, err := s.relay.subscribe()
+		if err != nil {
+			log.Errorf("redis relay %s* resubscribe error: %v", s.relay.prefix, err)
+			continue
+		}
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		select {
+		case <-s.done:
+			_ = c.Close()
+			return false
+		default:
+		}
+		s.conn = c
+		return true
+	}
+}
+
+// close 取消订阅，关闭连接使阻塞的 Receive 返回。
+func (s *subscription) close() {
+	s.once.Do(func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		close(s.done)
+		_ = s.conn.Close()
+	})
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package SpringRedigo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	g "github.com/gomodule/redigo/redis"
+)
+
+// fakeServer 模拟 redis 的 PUBLISH 和 PSUBSCRIBE 命令。
+type fakeServer struct {
+	mutex sync.Mutex
+	subs  map[*fakeConn]string
+}
+
+func (s *fakeServer) dial(ctx context.Context, options ...g.DialOption) (g.Conn, error) {
+	return &fakeConn{server: s, replies: make(chan []interface{}, 16), closed: make(chan struct{})}, nil
+}
+
+func (s *fakeServer) publish(channel string, data []byte) int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var n int64
+	for c, pattern := range s.subs {
+		if strings.HasPrefix(channel, strings.TrimSuffix(pattern, "*")) {
+			c.replies <- []interface{}{[]byte("pmessage"), []byte(pattern), []byte(channel), data}
+			n++
+		}
+	}
+	return n
+}
+
+func (s *fakeServer) subscribers() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.subs)
+}
+
+// disconnect 断开所有订阅连接。
+func (s *fakeServer) disconnect() {
+	s.mutex.Lock()
+	subs := s.subs
+	s.subs = nil
+	s.mutex.Unlock()
+	for c := range subs {
+		_ = c.Close()
+	}
+}
+
+type fakeConn struct {
+	server  *fakeServer
+	replies chan []interface{}
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func (c *fakeConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+		c.server.mutex.Lock()
+		defer c.server.mutex.Unlock()
+		delete(c.server.subs, c)
+	})
+	return nil
+}
+
+func (c *fakeConn) Err() error { return nil }
+
+func (c *fakeConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if cmd == "PUBLISH" {
+		return c.server.publish(args[0].(string), args[1].([]byte)), nil
+	}
+	return nil, nil
+}
+
+func (c *fakeConn) Send(cmd string, args ...interface{}) error {
+	if cmd == "PSUBSCRIBE" {
+		pattern := args[0].(string)
+		c.server.mutex.Lock()
+		defer c.server.mutex.Unlock()
+		if c.server.subs == nil {
+			c.server.subs = make(map[*fakeConn]string)
+		}
+		c.server.subs[c] = pattern
+		c.replies <- []interface{}{[]byte("psubscribe"), []byte(pattern), int64(1)}
+	}
+	return nil
+}
+
+func (c *fakeConn) Flush() error { return nil }
+
+func (c *fakeConn) Receive() (interface{}, error) {
+	select {
+	case r := <-c.replies:
+		return r, nil
+	case <-c.closed:
+		return nil, errors.New("use of closed connection")
+	}
+}
+
+func TestRelay(t *testing.T) {
+
+	server := &fakeServer{}
+	r := newRelay(server.dial, "pubsub:")
+	r.retry = 10 * time.Millisecond
+	defer r.Close()
+
+	messages := make(chan string, 4)
+	cancel, err := r.Subscribe(func(topic string, data []byte) {
+		messages <- topic + " " + string(data)
+	})
+	assert.Nil(t, err)
+
+	err = r.Publish(context.Background(), "chat", []byte(`"hi"`))
+	assert.Nil(t, err)
+	assert.Equal(t, <-messages, `chat "hi"`)
+
+	// 连接断开之后重新订阅。
+	server.disconnect()
+	for server.subscribers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	err = r.Publish(context.Background(), "orders", []byte(`{"id":7}`))
+	assert.Nil(t, err)
+	assert.Equal(t, <-messages, `orders {"id":7}`)
+
+	cancel()
+	assert.Equal(t, server.subscribers(), 0)
+}
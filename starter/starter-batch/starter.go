@@ -0,0 +1,40 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package StarterBatch
+
+import (
+	"github.com/go-spring/spring-core/batch"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
+)
+
+func init() {
+	gs.Provide(batch.NewLauncher, "${batch}", "?", "*?").
+		Export((*gs.AppEvent)(nil))
+	gs.Object(new(endpoint)).
+		On(cond.OnProperty("batch.http.enabled", cond.HavingValue("true"))).
+		Init(func(e *endpoint) {
+			gs.PostMapping(e.Path+"/{name}", e.Launcher.HandleStart)
+			gs.GetMapping(e.Path+"/{name}", e.Launcher.HandleStatus)
+		})
+}
+
+// endpoint 通过 HTTP 接口运行作业和查询作业的执行记录。
+type endpoint struct {
+	Launcher *batch.Launcher `autowire:""`
+	Path     string          `value:"${batch.http.path:=/batch/jobs}"`
+}
@@ -0,0 +1,237 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package aws 实现了从 AWS Systems Manager Parameter Store 和 Secrets Manager
+// 加载属性的 Loader ，请求使用 Signature Version 4 签名。
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config AWS 客户端的配置，为空的字段使用 AWS SDK 约定的环境变量。
+type Config struct {
+	Region          string        `value:"${region:=}"`
+	AccessKeyID     string        `value:"${access-key-id:=}"`
+	SecretAccessKey string        `value:"${secret-access-key:=}"`
+	SessionToken    string        `value:"${session-token:=}"`
+	Endpoint        string        `value:"${endpoint:=}"` // 覆盖服务地址，例如 LocalStack 。
+	Timeout         time.Duration `value:"${timeout:=10s}"`
+}
+
+// ConfigFromEnv 使用环境变量 AWS_REGION 、AWS_DEFAULT_REGION 、AWS_ACCESS_KEY_ID 、
+// AWS_SECRET_ACCESS_KEY 、AWS_SESSION_TOKEN 和 AWS_ENDPOINT_URL 创建配置。
+func ConfigFromEnv() Config {
+	return Config{Timeout: 10 * time.Second}.withEnv()
+}
+
+func (c Config) withEnv() Config {
+	env := func(s *string, keys ...string) {
+		for _, key := range keys {
+			if *s != "" {
+				return
+			}
+			*s = os.Getenv(key)
+		}
+	}
+	env(&c.Region, "AWS_REGION", "AWS_DEFAULT_REGION")
+	env(&c.AccessKeyID, "AWS_ACCESS_KEY_ID")
+	env(&c.SecretAccessKey, "AWS_SECRET_ACCESS_KEY")
+	env(&c.SessionToken, "AWS_SESSION_TOKEN")
+	env(&c.Endpoint, "AWS_ENDPOINT_URL")
+	return c
+}
+
+// Error AWS 服务返回的错误。
+type Error struct {
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("aws: %d %s: %s", e.StatusCode, e.Type, e.Message)
+}
+
+// Client 调用 AWS JSON 1.1 协议的服务。
+type Client struct {
+	config  Config
+	service string
+	prefix  string
+	client  *http.Client
+	now     func() time.Time
+}
+
+func newClient(config Config, service, prefix string) (*Client, error) {
+	config = config.withEnv()
+	if config.Region == "" {
+		return nil, errors.New("aws: region is required")
+	}
+	if config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, errors.New("aws: credentials are required")
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com", service, config.Region)
+	}
+	return &Client{
+		config:  config,
+		service: service,
+		prefix:  prefix,
+		client:  &http.Client{Timeout: config.Timeout},
+		now:     time.Now,
+	}, nil
+}
+
+func (c *Client) call(ctx context.Context, action string, in, out interface{}) error {
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.config.Endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", c.prefix+"."+action)
+	sign(req, body, c.config, c.service, c.now())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Type     string `json:"__type"`
+			Message  string `json:"message"`
+			Message2 string `json:"Message"`
+		}
+		_ = json.Unmarshal(b, &e)
+		if i := strings.LastIndex(e.Type, "#"); i >= 0 {
+			e.Type = e.Type[i+1:]
+		}
+		if e.Message == "" {
+			e.Message = e.Message2
+		}
+		return &Error{StatusCode: resp.StatusCode, Type: e.Type, Message: e.Message}
+	}
+	return json.Unmarshal(b, out)
+}
+
+// sign 使用 Signature Version 4 对请求签名。
+func sign(req *http.Request, body []byte, config Config, service string, now time.Time) {
+
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", config.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + config.Region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+config.SecretAccessKey), date)
+	key = hmacSHA256(key, config.Region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(v url.Values) string {
+	var pairs []string
+	for k, values := range v {
+		for _, s := range values {
+			pairs = append(pairs, escape(k)+"="+escape(s))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func escape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+// propertyName 将参数或者密钥的名称去掉前缀之后转换为属性名，例如前缀为
+// /config/app/ 时 /config/app/db/password 对应 db.password 。
+func propertyName(name, prefix string) string {
+	name = strings.TrimPrefix(name, prefix)
+	return strings.Replace(strings.Trim(name, "/"), "/", ".", -1)
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aws_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/starter-cloud-config/aws"
+)
+
+// fakeServer 按照 X-Amz-Target 分发请求，handlers 返回响应的 JSON 对象。
+func fakeServer(t *testing.T, handlers map[string]func(in map[string]interface{}) (int, interface{})) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		var in map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		code, out := handlers[r.Header.Get("X-Amz-Target")](in)
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(out)
+	}))
+}
+
+func config(endpoint string) aws.Config {
+	return aws.Config{
+		Region:          "us-east-1",
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "SECRET",
+		Endpoint:        endpoint,
+	}
+}
+
+func TestParameterStore(t *testing.T) {
+
+	s := fakeServer(t, map[string]func(map[string]interface{}) (int, interface{}){
+		"AmazonSSM.GetParametersByPath": func(in map[string]interface{}) (int, interface{}) {
+			assert.Equal(t, in["Path"], "/config/app/")
+			assert.Equal(t, in["Recursive"], true)
+			assert.Equal(t, in["WithDecryption"], true)
+			if in["NextToken"] == nil {
+				return http.StatusOK, map[string]interface{}{
+					"Parameters": []map[string]string{
+						{"Name": "/config/app/db/url", "Value": "mysql://db"},
+					},
+					"NextToken": "next",
+				}
+			}
+			return http.StatusOK, map[string]interface{}{
+				"Parameters": []map[string]string{
+					{"Name": "/config/app/db/password", "Value": "123456"},
+				},
+			}
+		},
+	})
+	defer s.Close()
+
+	p, err := aws.NewParameterStore(config(s.URL))
+	assert.Nil(t, err)
+	m, err := p.Load(context.Background(), "/config/app/")
+	assert.Nil(t, err)
+	assert.Equal(t, m, map[string]string{
+		"db.url":      "mysql://db",
+		"db.password": "123456",
+	})
+}
+
+func TestSecretsManager(t *testing.T) {
+
+	s := fakeServer(t, map[string]func(map[string]interface{}) (int, interface{}){
+		"secretsmanager.ListSecrets": func(in map[string]interface{}) (int, interface{}) {
+			return http.StatusOK, map[string]interface{}{
+				"SecretList": []map[string]string{
+					{"Name": "app/db"},
+					{"Name": "app/token"},
+				},
+			}
+		},
+		"secretsmanager.GetSecretValue": func(in map[string]interface{}) (int, interface{}) {
+			switch in["SecretId"] {
+			case "app/db":
+				return http.StatusOK, map[string]string{
+					"SecretString": `{"db":{"username":"root","password":"123456"}}`,
+				}
+			case "app/token":
+				return http.StatusOK, map[string]string{"SecretString": "abc"}
+			}
+			return http.StatusBadRequest, map[string]string{
+				"__type":  "ResourceNotFoundException",
+				"message": "not found",
+			}
+		},
+	})
+	defer s.Close()
+
+	p, err := aws.NewSecretsManager(config(s.URL))
+	assert.Nil(t, err)
+	m, err := p.Load(context.Background(), "app/")
+	assert.Nil(t, err)
+	assert.Equal(t, m, map[string]string{
+		"db.username": "root",
+		"db.password": "123456",
+		"token":       "abc",
+	})
+}
+
+func TestError(t *testing.T) {
+
+	s := fakeServer(t, map[string]func(map[string]interface{}) (int, interface{}){
+		"AmazonSSM.GetParametersByPath": func(in map[string]interface{}) (int, interface{}) {
+			return http.StatusBadRequest, map[string]string{
+				"__type":  "com.amazonaws.ssm#AccessDeniedException",
+				"message": "denied",
+			}
+		},
+	})
+	defer s.Close()
+
+	p, err := aws.NewParameterStore(config(s.URL))
+	assert.Nil(t, err)
+	_, err = p.Load(context.Background(), "/config/app/")
+	assert.Error(t, err, "aws: 400 AccessDeniedException: denied")
+
+	_, err = aws.NewParameterStore(aws.Config{Region: "us-east-1", Endpoint: s.URL})
+	if err != nil { // 环境变量中可能配置了凭证
+		assert.Error(t, err, "aws: credentials are required")
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aws
+
+import (
+	"context"
+
+	"github.com/go-spring/starter-cloud-config/cloudconfig"
+)
+
+// ParameterStore 从 Parameter Store 递归加载路径下的所有参数，SecureString
+// 类型的参数会被解密。
+type ParameterStore struct {
+	client *Client
+}
+
+func NewParameterStore(config Config) (*ParameterStore, error) {
+	client, err := newClient(config, "ssm", "AmazonSSM")
+	if err != nil {
+		return nil, err
+	}
+	return &ParameterStore{client: client}, nil
+}
+
+func (s *ParameterStore) Load(ctx context.Context, prefix string) (map[string]string, error) {
+
+	type input struct {
+		Path           string
+		Recursive      bool
+		WithDecryption bool
+		NextToken      string `json:",omitempty"`
+	}
+
+	type output struct {
+		Parameters []struct {
+			Name  string
+			Value string
+		}
+		NextToken string
+	}
+
+	m := make(map[string]string)
+	in := input{Path: prefix, Recursive: true, WithDecryption: true}
+	for {
+		var out output
+		if err := s.client.call(ctx, "GetParametersByPath", in, &out); err != nil {
+			return nil, err
+		}
+		for _, p := range out.Parameters {
+			m[propertyName(p.Name, prefix)] = p.Value
+		}
+		if out.NextToken == "" {
+			return m, nil
+		}
+		in.NextToken = out.NextToken
+	}
+}
+
+// SecretsManager 从 Secrets Manager 加载名称以前缀开头的所有密钥，值为 JSON 对象的
+// 密钥展开为对象中的属性，其他密钥使用去掉前缀之后的名称作为属性名。
+type SecretsManager struct {
+	client *Client
+}
+
+func NewSecretsManager(config Config) (*SecretsManager, error) {
+	client, err := newClient(config, "secretsmanager", "secretsmanager")
+	if err != nil {
+		return nil, err
+	}
+	return &SecretsManager{client: client}, nil
+}
+
+func (s *SecretsManager) Load(ctx context.Context, prefix string) (map[string]string, error) {
+
+	type filter struct {
+		Key    string
+		Values []string
+	}
+
+	type listInput struct {
+		Filters   []filter
+		NextToken string `json:",omitempty"`
+	}
+
+	type listOutput struct {
+		SecretList []struct {
+			Name string
+		}
+		NextToken string
+	}
+
+	type getInput struct {
+		SecretId string
+	}
+
+	type getOutput struct {
+		SecretString string
+	}
+
+	var names []string
+	in := listInput{Filters: []filter{{Key: "name", Values: []string{prefix}}}}
+	for {
+		var out listOutput
+		if err := s.client.call(ctx, "ListSecrets", in, &out); err != nil {
+			return nil, err
+		}
+		for _, secret := range out.SecretList {
+			names = append(names, secret.Name)
+		}
+		if out.NextToken == "" {
+			break
+		}
+		in.NextToken = out.NextToken
+	}
+
+	m := make(map[string]string)
+	for _, name := range names {
+		var out getOutput
+		if err := s.client.call(ctx, "GetSecretValue", getInput{SecretId: name}, &out); err != nil {
+			return nil, err
+		}
+		ok, err := cloudconfig.Flatten(out.SecretString, m)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			m[propertyName(name, prefix)] = out.SecretString
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aws
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+)
+
+// 使用 AWS 文档中的示例请求验证签名。
+func TestSign(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	assert.Nil(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	config := Config{
+		Region:          "us-east-1",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	sign(req, nil, config, "iam", now)
+	assert.Equal(t, req.Header.Get("X-Amz-Date"), "20150830T123600Z")
+	assert.Equal(t, req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "+
+		"Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, "+
+		"SignedHeaders=content-type;host;x-amz-date, "+
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7")
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package cloudconfig 定义了从云厂商的参数存储和密钥管理服务中按前缀加载属性的
+// Loader ，它们既可以通过 spring.config.import 在属性文件加载时导入，也可以通过
+// Refresher 在运行时周期性地刷新。
+package cloudconfig
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/gs"
+)
+
+var logger = log.GetLogger("GS_CLOUD_CONFIG")
+
+// Loader 加载名称以 prefix 开头的参数或者密钥，返回属性名和属性值的映射。
+type Loader interface {
+	Load(ctx context.Context, prefix string) (map[string]string, error)
+}
+
+// Importer 返回使用 Loader 导入属性的 gs.ConfigImporter ，location 是参数或者
+// 密钥名称的前缀。newLoader 在导入时才被调用，这样没有使用对应导入项的应用不需要
+// 配置云厂商的凭证。前缀下没有任何属性时返回 os.ErrNotExist 错误。
+func Importer(newLoader func() (Loader, error), timeout time.Duration) gs.ConfigImporter {
+	return func(location string) (*conf.Properties, error) {
+		loader, err := newLoader()
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		m, err := loader.Load(ctx, location)
+		if err != nil {
+			return nil, err
+		}
+		if len(m) == 0 {
+			return nil, os.ErrNotExist
+		}
+		p := conf.New()
+		for k, v := range m {
+			if err = p.Set(k, v); err != nil {
+				return nil, err
+			}
+		}
+		return p, nil
+	}
+}
+
+// Flatten 将 JSON 对象形式的密钥展开为属性，不是 JSON 对象时返回 false 。
+func Flatten(s string, m map[string]string) (bool, error) {
+	var v map[string]interface{}
+	if json.Unmarshal([]byte(s), &v) != nil {
+		return false, nil
+	}
+	p := conf.New()
+	for key, val := range v {
+		if err := p.Set(key, val); err != nil {
+			return true, err
+		}
+	}
+	for _, key := range p.Keys() {
+		m[key] = p.Get(key)
+	}
+	return true, nil
+}
+
+// RefreshConfig 周期性刷新的配置，Prefixes 是参数或者密钥名称的前缀。
+type RefreshConfig struct {
+	Prefixes []string      `value:"${prefixes}"`
+	Interval time.Duration `value:"${interval:=1m}"`
+}
+
+// Refresher 周期性地重新加载属性。容器中的属性在刷新之后不再变化，需要感知变化的
+// 组件通过 Get 读取最新的属性值，或者通过 OnChange 注册变化的通知。
+type Refresher struct {
+	loader    Loader
+	config    RefreshConfig
+	mutex     sync.RWMutex
+	values    map[string]string
+	listeners []func(changed map[string]string)
+	stop      context.CancelFunc
+	done      chan struct{}
+}
+
+func NewRefresher(loader Loader, config RefreshConfig) *Refresher {
+	return &Refresher{loader: loader, config: config, values: map[string]string{}}
+}
+
+// Get 返回最新的属性值。
+func (r *Refresher) Get(key string) (string, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	v, ok := r.values[key]
+	return v, ok
+}
+
+// OnChange 注册属性变化的通知，被删除的属性的值为空字符串。
+func (r *Refresher) OnChange(fn func(changed map[string]string)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// Refresh 立即重新加载所有前缀的属性，任何前缀加载失败时保留原来的属性值。
+func (r *Refresher) Refresh(ctx context.Context) error {
+
+	values := make(map[string]string)
+	for _, prefix := range r.config.Prefixes {
+		m, err := r.loader.Load(ctx, prefix)
+		if err != nil {
+			return err
+		}
+		for k, v := range m {
+			values[k] = v
+		}
+	}
+
+	r.mutex.Lock()
+	changed := make(map[string]string)
+	for k, v := range values {
+		if old, ok := r.values[k]; !ok || old != v {
+			changed[k] = v
+		}
+	}
+	for k := range r.values {
+		if _, ok := values[k]; !ok {
+			changed[k] = ""
+		}
+	}
+	r.values = values
+	listeners := r.listeners
+	r.mutex.Unlock()
+
+	if len(changed) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(changed))
+	for k := range changed {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	logger.WithContext(ctx).Infof("properties changed: %v", names)
+	for _, fn := range listeners {
+		fn(changed)
+	}
+	return nil
+}
+
+// OnAppStart 加载属性并开始周期性地刷新。
+func (r *Refresher) OnAppStart(ctx gs.Context) {
+	var c context.Context
+	c, r.stop = context.WithCancel(context.Background())
+	r.done = make(chan struct{})
+	if err := r.Refresh(c); err != nil {
+		logger.WithContext(c).Error(log.ERROR, err)
+	}
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := r.Refresh(c); err != nil {
+				logger.WithContext(c).Error(log.ERROR, err)
+			}
+		}
+	}()
+}
+
+// OnAppStop 停止刷新。
+func (r *Refresher) OnAppStop(ctx context.Context) {
+	if r.stop == nil {
+		return
+	}
+	r.stop()
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+	}
+}
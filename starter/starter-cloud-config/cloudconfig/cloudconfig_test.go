@@ -0,0 +1,147 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cloudconfig_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/starter-cloud-config/cloudconfig"
+)
+
+type fakeLoader struct {
+	mutex  sync.Mutex
+	values map[string]map[string]string
+	err    error
+}
+
+func (l *fakeLoader) set(prefix string, m map[string]string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.values[prefix] = m
+}
+
+func (l *fakeLoader) Load(ctx context.Context, prefix string) (map[string]string, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.err != nil {
+		return nil, l.err
+	}
+	m := make(map[string]string)
+	for k, v := range l.values[prefix] {
+		m[k] = v
+	}
+	return m, nil
+}
+
+func TestImporter(t *testing.T) {
+
+	loader := &fakeLoader{values: map[string]map[string]string{
+		"/app/": {"db.url": "mysql://db", "db.hosts[0]": "a"},
+	}}
+	importer := cloudconfig.Importer(func() (cloudconfig.Loader, error) {
+		return loader, nil
+	}, time.Second)
+
+	p, err := importer("/app/")
+	assert.Nil(t, err)
+	assert.Equal(t, p.Get("db.url"), "mysql://db")
+	assert.Equal(t, p.Get("db.hosts[0]"), "a")
+
+	_, err = importer("/none/")
+	assert.True(t, os.IsNotExist(err))
+
+	loader.err = errors.New("access denied")
+	_, err = importer("/app/")
+	assert.Error(t, err, "access denied")
+}
+
+func TestFlatten(t *testing.T) {
+	m := make(map[string]string)
+	ok, err := cloudconfig.Flatten(`{"db":{"port":3306,"hosts":["a","b"]}}`, m)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, m, map[string]string{
+		"db.port":     "3306",
+		"db.hosts[0]": "a",
+		"db.hosts[1]": "b",
+	})
+	ok, err = cloudconfig.Flatten("plain", m)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestRefresher(t *testing.T) {
+
+	loader := &fakeLoader{values: map[string]map[string]string{
+		"/a/": {"x": "1", "y": "2"},
+		"/b/": {"z": "3"},
+	}}
+	r := cloudconfig.NewRefresher(loader, cloudconfig.RefreshConfig{
+		Prefixes: []string{"/a/", "/b/"},
+		Interval: 10 * time.Millisecond,
+	})
+
+	var mutex sync.Mutex
+	var events []map[string]string
+	r.OnChange(func(changed map[string]string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		events = append(events, changed)
+	})
+
+	r.OnAppStart(nil)
+	defer r.OnAppStop(context.Background())
+
+	v, ok := r.Get("z")
+	assert.True(t, ok)
+	assert.Equal(t, v, "3")
+
+	loader.set("/a/", map[string]string{"x": "10"})
+	for i := 0; ; i++ {
+		if v, _ = r.Get("x"); v == "10" {
+			break
+		}
+		if i > 100 {
+			t.Fatal("refresh timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, ok = r.Get("y")
+	assert.False(t, ok)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, events, []map[string]string{
+		{"x": "1", "y": "2", "z": "3"},
+		{"x": "10", "y": ""},
+	})
+
+	// 加载失败时保留原来的属性值。
+	loader.mutex.Lock()
+	loader.err = errors.New("access denied")
+	loader.mutex.Unlock()
+	assert.Error(t, r.Refresh(context.Background()), "access denied")
+	v, _ = r.Get("x")
+	assert.Equal(t, v, "10")
+}
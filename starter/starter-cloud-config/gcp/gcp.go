@@ -0,0 +1,256 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package gcp 实现了从 GCP Secret Manager 加载属性的 Loader ，访问令牌和项目
+// 来自环境变量或者 GCE/GKE 的元数据服务。
+package gcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-spring/starter-cloud-config/cloudconfig"
+)
+
+// Config GCP 客户端的配置，为空的字段使用环境变量或者元数据服务。
+type Config struct {
+	Project      string        `value:"${project:=}"`
+	AccessToken  string        `value:"${access-token:=}"`
+	Endpoint     string        `value:"${endpoint:=https://secretmanager.googleapis.com}"`
+	MetadataHost string        `value:"${metadata-host:=}"`
+	Timeout      time.Duration `value:"${timeout:=10s}"`
+}
+
+// ConfigFromEnv 使用环境变量 GOOGLE_CLOUD_PROJECT 、GOOGLE_OAUTH_ACCESS_TOKEN 和
+// GCE_METADATA_HOST 创建配置。
+func ConfigFromEnv() Config {
+	return Config{
+		Endpoint: "https://secretmanager.googleapis.com",
+		Timeout:  10 * time.Second,
+	}.withEnv()
+}
+
+func (c Config) withEnv() Config {
+	if c.Project == "" {
+		c.Project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+	if c.AccessToken == "" {
+		c.AccessToken = os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	}
+	if c.MetadataHost == "" {
+		c.MetadataHost = os.Getenv("GCE_METADATA_HOST")
+	}
+	if c.MetadataHost == "" {
+		c.MetadataHost = "metadata.google.internal"
+	}
+	return c
+}
+
+// Error GCP 服务返回的错误。
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("gcp: %d %s", e.StatusCode, e.Message)
+}
+
+// SecretManager 加载 ID 以前缀开头的所有密钥的最新版本，值为 JSON 对象的密钥展开为
+// 对象中的属性，其他密钥去掉前缀并将 __ 替换为 . 作为属性名，例如前缀为 app-
+// 时 app-db__password 对应 db.password 。
+type SecretManager struct {
+	config Config
+	client *http.Client
+
+	mutex   sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func NewSecretManager(config Config) (*SecretManager, error) {
+	config = config.withEnv()
+	s := &SecretManager{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+	if s.config.Project == "" {
+		b, err := s.metadata(context.Background(), "project/project-id")
+		if err != nil {
+			return nil, fmt.Errorf("gcp: project is required: %w", err)
+		}
+		s.config.Project = string(b)
+	}
+	return s, nil
+}
+
+func (s *SecretManager) Load(ctx context.Context, prefix string) (map[string]string, error) {
+
+	type listOutput struct {
+		Secrets []struct {
+			Name string `json:"name"`
+		} `json:"secrets"`
+		NextPageToken string `json:"nextPageToken"`
+	}
+
+	type accessOutput struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+
+	base := s.config.Endpoint + "/v1/projects/" + url.PathEscape(s.config.Project) + "/secrets"
+
+	var ids []string
+	query := url.Values{"filter": {"name:" + prefix}, "pageSize": {"250"}}
+	for {
+		var out listOutput
+		if err := s.get(ctx, base+"?"+query.Encode(), &out); err != nil {
+			return nil, err
+		}
+		for _, secret := range out.Secrets {
+			id := secret.Name[strings.LastIndex(secret.Name, "/")+1:]
+			if strings.HasPrefix(id, prefix) {
+				ids = append(ids, id)
+			}
+		}
+		if out.NextPageToken == "" {
+			break
+		}
+		query.Set("pageToken", out.NextPageToken)
+	}
+
+	m := make(map[string]string)
+	for _, id := range ids {
+		var out accessOutput
+		if err := s.get(ctx, base+"/"+id+"/versions/latest:access", &out); err != nil {
+			return nil, err
+		}
+		b, err := base64.StdEncoding.DecodeString(out.Payload.Data)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := cloudconfig.Flatten(string(b), m)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			key := strings.Replace(strings.TrimPrefix(id, prefix), "__", ".", -1)
+			m[key] = string(b)
+		}
+	}
+	return m, nil
+}
+
+func (s *SecretManager) get(ctx context.Context, rawURL string, out interface{}) error {
+
+	token, err := s.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(b, &e)
+		return &Error{StatusCode: resp.StatusCode, Message: e.Error.Message}
+	}
+	return json.Unmarshal(b, out)
+}
+
+// accessToken 返回配置的访问令牌，没有配置时从元数据服务获取默认服务账号的令牌，
+// 并在过期前一分钟重新获取。
+func (s *SecretManager) accessToken(ctx context.Context) (string, error) {
+	if s.config.AccessToken != "" {
+		return s.config.AccessToken, nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.token != "" && time.Now().Before(s.expires) {
+		return s.token, nil
+	}
+
+	b, err := s.metadata(ctx, "instance/service-accounts/default/token")
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err = json.Unmarshal(b, &out); err != nil {
+		return "", err
+	}
+	s.token = out.AccessToken
+	s.expires = time.Now().Add(time.Duration(out.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
+
+func (s *SecretManager) metadata(ctx context.Context, path string) ([]byte, error) {
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+s.config.MetadataHost+"/computeMetadata/v1/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(b))}
+	}
+	return b, nil
+}
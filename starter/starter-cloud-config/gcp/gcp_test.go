@@ -0,0 +1,100 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/starter-cloud-config/gcp"
+)
+
+func TestSecretManager(t *testing.T) {
+
+	secrets := map[string]string{
+		"app-db__url":  "mysql://db",
+		"app-redis":    `{"redis":{"password":"123456"}}`,
+		"other-secret": "ignored",
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/computeMetadata/v1/project/project-id":
+			assert.Equal(t, r.Header.Get("Metadata-Flavor"), "Google")
+			_, _ = w.Write([]byte("demo"))
+			return
+		case r.URL.Path == "/computeMetadata/v1/instance/service-accounts/default/token":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token": "token",
+				"expires_in":   3600,
+			})
+			return
+		}
+		assert.Equal(t, r.Header.Get("Authorization"), "Bearer token")
+		const base = "/v1/projects/demo/secrets"
+		if r.URL.Path == base {
+			assert.Equal(t, r.URL.Query().Get("filter"), "name:app-")
+			if r.URL.Query().Get("pageToken") == "" {
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{
+					"secrets": []map[string]string{
+						{"name": "projects/1/secrets/app-db__url"},
+						{"name": "projects/1/secrets/other-secret"},
+					},
+					"nextPageToken": "next",
+				})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"secrets": []map[string]string{
+					{"name": "projects/1/secrets/app-redis"},
+				},
+			})
+			return
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, base+"/"), "/versions/latest:access")
+		v, ok := secrets[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": map[string]string{"message": "not found"},
+			})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"payload": map[string]string{"data": base64.StdEncoding.EncodeToString([]byte(v))},
+		})
+	}))
+	defer s.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+	m, err := gcp.NewSecretManager(gcp.Config{Endpoint: s.URL, MetadataHost: host})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := m.Load(context.Background(), "app-")
+	assert.Nil(t, err)
+	assert.Equal(t, r, map[string]string{
+		"db.url":         "mysql://db",
+		"redis.password": "123456",
+	})
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package StarterCloudConfig
+
+import (
+	"time"
+
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
+	"github.com/go-spring/starter-cloud-config/aws"
+	"github.com/go-spring/starter-cloud-config/cloudconfig"
+	"github.com/go-spring/starter-cloud-config/gcp"
+)
+
+const importTimeout = 30 * time.Second
+
+func init() {
+
+	gs.RegisterConfigImporter("aws-parameterstore", cloudconfig.Importer(func() (cloudconfig.Loader, error) {
+		return aws.NewParameterStore(aws.ConfigFromEnv())
+	}, importTimeout))
+
+	gs.RegisterConfigImporter("aws-secretsmanager", cloudconfig.Importer(func() (cloudconfig.Loader, error) {
+		return aws.NewSecretsManager(aws.ConfigFromEnv())
+	}, importTimeout))
+
+	gs.RegisterConfigImporter("gcp-secretmanager", cloudconfig.Importer(func() (cloudconfig.Loader, error) {
+		return gcp.NewSecretManager(gcp.ConfigFromEnv())
+	}, importTimeout))
+
+	gs.Provide(func(config aws.Config, refresh cloudconfig.RefreshConfig) (*cloudconfig.Refresher, error) {
+		loader, err := aws.NewParameterStore(config)
+		if err != nil {
+			return nil, err
+		}
+		return cloudconfig.NewRefresher(loader, refresh), nil
+	}, "${cloud.aws}", "${cloud.aws.parameter-store.refresh}").
+		Name("aws-parameter-store").
+		Export((*gs.AppEvent)(nil)).
+		On(cond.OnProperty("cloud.aws.parameter-store.refresh.prefixes"))
+
+	gs.Provide(func(config aws.Config, refresh cloudconfig.RefreshConfig) (*cloudconfig.Refresher, error) {
+		loader, err := aws.NewSecretsManager(config)
+		if err != nil {
+			return nil, err
+		}
+		return cloudconfig.NewRefresher(loader, refresh), nil
+	}, "${cloud.aws}", "${cloud.aws.secrets-manager.refresh}").
+		Name("aws-secrets-manager").
+		Export((*gs.AppEvent)(nil)).
+		On(cond.OnProperty("cloud.aws.secrets-manager.refresh.prefixes"))
+
+	gs.Provide(func(config gcp.Config, refresh cloudconfig.RefreshConfig) (*cloudconfig.Refresher, error) {
+		loader, err := gcp.NewSecretManager(config)
+		if err != nil {
+			return nil, err
+		}
+		return cloudconfig.NewRefresher(loader, refresh), nil
+	}, "${cloud.gcp}", "${cloud.gcp.secret-manager.refresh}").
+		Name("gcp-secret-manager").
+		Export((*gs.AppEvent)(nil)).
+		On(cond.OnProperty("cloud.gcp.secret-manager.refresh.prefixes"))
+}
@@ -0,0 +1,364 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package diagnostics 在独立的管理端口上提供 pprof、运行时统计以及按需导出
+// 协程和堆信息的诊断接口。
+package diagnostics
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/conf"
+)
+
+var logger = log.GetLogger("GS_DIAGNOSTICS")
+
+type Config struct {
+	Host     string `value:"${host:=127.0.0.1}"` // 监听 IP，默认只允许本机访问
+	Port     int    `value:"${port:=9090}"`      // 管理端口
+	Username string `value:"${username:=}"`      // 为空时不进行认证
+	Password string `value:"${password:=}"`
+	Ops      bool   `value:"${ops.enabled:=false}"` // 是否开放 /ops 接口
+
+	// 属性名包含这些关键字时 /debug/env 隐藏属性值，为空时使用 conf.DefaultSanitizeKeys
+	SanitizeKeys []string `value:"${sanitize.keys:=}"`
+}
+
+// Server 诊断服务器，诊断接口位于 /debug 路径下，开启 ops 时运维操作位于 /ops 路径下，
+// 设置探针之后就绪检查和排空接口分别位于 /ready 和 /drain 路径下。
+type Server struct {
+	config Config
+	mutex  sync.Mutex
+	server *http.Server
+	closed bool
+	ops    []*Operations
+	ready  func() bool
+	drain  func()
+	env    map[string]string
+	origin map[string]string
+	dump   func() (interface{}, error)
+	memory func() (interface{}, error)
+}
+
+func NewServer(config Config) *Server {
+	return &Server{config: config}
+}
+
+// Expose 注册允许通过 /ops 接口调用的方法，需要在 Start 之前调用。
+func (s *Server) Expose(ops ...*Operations) {
+	s.ops = append(s.ops, ops...)
+}
+
+// Env 设置 /debug/env 接口返回的属性，属性值经过 sanitizer 处理之后才会保存，
+// sanitizer 为 nil 时按照配置的关键字隐藏敏感属性，需要在 Start 之前调用。
+func (s *Server) Env(props map[string]string, sanitizer conf.Sanitizer) {
+	if sanitizer == nil {
+		sanitizer = conf.NewSanitizer(s.config.SanitizeKeys...)
+	}
+	s.env = make(map[string]string, len(props))
+	for k, v := range props {
+		s.env[k] = sanitizer.Sanitize(k, v)
+	}
+}
+
+// Origin 设置属性的来源，/debug/env?origin=true 时同时返回属性值和来源，需要在 Start
+// 之前调用。
+func (s *Server) Origin(origin map[string]string) {
+	s.origin = origin
+}
+
+// Snapshot 设置 /debug/snapshot 接口使用的快照函数，每次请求时生成新的应用上下文
+// 快照，需要在 Start 之前调用。
+func (s *Server) Snapshot(fn func() (interface{}, error)) {
+	s.dump = fn
+}
+
+// Footprint 设置 /debug/footprint 接口使用的函数，每次请求时重新估算各个 bean 占用
+// 的内存，需要在 Start 之前调用。
+func (s *Server) Footprint(fn func() (interface{}, error)) {
+	s.memory = fn
+}
+
+// Probe 设置就绪检查函数和排空函数，需要在 Start 之前调用。/ready 接口不需要认证，
+// 以便负载均衡或者 Kubernetes 的 readinessProbe 访问，/drain 接口在排空结束之后才返回，
+// 可以配置为 Kubernetes 的 preStop 钩子。
+func (s *Server) Probe(ready func() bool, drain func()) {
+	s.ready = ready
+	s.drain = drain
+}
+
+// Address 返回监听地址
+func (s *Server) Address() string {
+	return fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
+}
+
+// Handler 返回诊断接口的处理器，配置了用户名时需要进行 http 基础认证。
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/runtime", handleRuntime)
+	mux.HandleFunc("/debug/dump/goroutine", handleGoroutineDump)
+	mux.HandleFunc("/debug/dump/heap", handleHeapDump)
+	if s.env != nil {
+		mux.HandleFunc("/debug/env", s.handleEnv)
+	}
+	if s.dump != nil {
+		mux.HandleFunc("/debug/snapshot", handleFunc(s.dump))
+	}
+	if s.memory != nil {
+		mux.HandleFunc("/debug/footprint", handleFunc(s.memory))
+	}
+	if s.config.Ops {
+		mux.HandleFunc("/ops", s.handleOps)
+		mux.HandleFunc("/ops/", s.handleOps)
+	}
+	if s.drain != nil {
+		mux.HandleFunc("/drain", s.handleDrain)
+	}
+	var h http.Handler = mux
+	if s.config.Username != "" {
+		h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !s.authorized(r) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="diagnostics"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			mux.ServeHTTP(w, r)
+		})
+	}
+	if s.ready == nil {
+		return h
+	}
+	root := http.NewServeMux()
+	root.HandleFunc("/ready", s.handleReady)
+	root.Handle("/", h)
+	return root
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	u := subtle.ConstantTimeCompare([]byte(username), []byte(s.config.Username))
+	p := subtle.ConstantTimeCompare([]byte(password), []byte(s.config.Password))
+	return u&p == 1
+}
+
+// Start 启动诊断服务器
+func (s *Server) Start() error {
+	if s.config.Username == "" {
+		if ip := net.ParseIP(s.config.Host); ip == nil || !ip.IsLoopback() {
+			logger.Warnf("diagnostics server on %s is not protected by authentication", s.Address())
+		} else if s.config.Ops {
+			logger.Warnf("ops endpoint on %s is not protected by authentication", s.Address())
+		}
+	}
+	server, err := s.newServer()
+	if err != nil {
+		return err
+	}
+	logger.Info("⇨ diagnostics server started on ", s.Address())
+	return server.ListenAndServe()
+}
+
+// newServer 创建 http 服务器，Start 和 Stop 通常在不同的 goroutine 中执行，因此
+// 需要加锁，Stop 之后不再创建新的服务器。
+func (s *Server) newServer() (*http.Server, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return nil, http.ErrServerClosed
+	}
+	s.server = &http.Server{Addr: s.Address(), Handler: s.Handler()}
+	return s.server, nil
+}
+
+// Stop 停止诊断服务器
+func (s *Server) Stop(ctx context.Context) error {
+	s.mutex.Lock()
+	server := s.server
+	s.closed = true
+	s.mutex.Unlock()
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
+}
+
+// EnvProperty 带有来源的属性值。
+type EnvProperty struct {
+	Value  string `json:"value"`
+	Origin string `json:"origin,omitempty"`
+}
+
+// handleEnv 返回隐藏了敏感属性值的属性列表，请求参数 origin=true 时同时返回属性的来源。
+func (s *Server) handleEnv(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("origin") != "true" {
+		writeJSON(w, http.StatusOK, s.env)
+		return
+	}
+	m := make(map[string]EnvProperty, len(s.env))
+	for k, v := range s.env {
+		m[k] = EnvProperty{Value: v, Origin: s.origin[k]}
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+// handleFunc 返回以 JSON 格式输出 fn 结果的处理器，如应用上下文的快照等。
+func handleFunc(fn func() (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		v, err := fn()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, v)
+	}
+}
+
+// handleReady 应用就绪时返回 200 ，否则返回 503 。
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if !s.ready() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = w.Write([]byte("ready"))
+}
+
+// handleDrain 使应用退出就绪状态并等待排空结束，Kubernetes 的 preStop 钩子只支持
+// GET 请求，因此同时接受 GET 和 POST 请求。
+func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	logger.Infof("drain requested by %s", r.RemoteAddr)
+	s.drain()
+	_, _ = w.Write([]byte("drained"))
+}
+
+// RuntimeStats 运行时统计信息
+type RuntimeStats struct {
+	GoVersion    string  `json:"go_version"`
+	NumCPU       int     `json:"num_cpu"`
+	GOMAXPROCS   int     `json:"gomaxprocs"`
+	NumGoroutine int     `json:"num_goroutine"`
+	NumCgoCall   int64   `json:"num_cgo_call"`
+	GC           GCStats `json:"gc"`
+	Memory       MemStat `json:"memory"`
+}
+
+type GCStats struct {
+	NumGC         uint32    `json:"num_gc"`
+	NumForcedGC   uint32    `json:"num_forced_gc"`
+	PauseTotal    string    `json:"pause_total"`
+	LastGC        time.Time `json:"last_gc"`
+	NextGC        uint64    `json:"next_gc"`
+	GCCPUFraction float64   `json:"gc_cpu_fraction"`
+}
+
+type MemStat struct {
+	Alloc       uint64 `json:"alloc"`
+	TotalAlloc  uint64 `json:"total_alloc"`
+	Sys         uint64 `json:"sys"`
+	Mallocs     uint64 `json:"mallocs"`
+	Frees       uint64 `json:"frees"`
+	HeapAlloc   uint64 `json:"heap_alloc"`
+	HeapSys     uint64 `json:"heap_sys"`
+	HeapIdle    uint64 `json:"heap_idle"`
+	HeapInuse   uint64 `json:"heap_inuse"`
+	HeapObjects uint64 `json:"heap_objects"`
+	StackInuse  uint64 `json:"stack_inuse"`
+}
+
+// ReadRuntimeStats 读取当前的运行时统计信息，会短暂地暂停整个程序。
+func ReadRuntimeStats() RuntimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return RuntimeStats{
+		GoVersion:    runtime.Version(),
+		NumCPU:       runtime.NumCPU(),
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+		NumGoroutine: runtime.NumGoroutine(),
+		NumCgoCall:   runtime.NumCgoCall(),
+		GC: GCStats{
+			NumGC:         m.NumGC,
+			NumForcedGC:   m.NumForcedGC,
+			PauseTotal:    time.Duration(m.PauseTotalNs).String(),
+			LastGC:        time.Unix(0, int64(m.LastGC)),
+			NextGC:        m.NextGC,
+			GCCPUFraction: m.GCCPUFraction,
+		},
+		Memory: MemStat{
+			Alloc:       m.Alloc,
+			TotalAlloc:  m.TotalAlloc,
+			Sys:         m.Sys,
+			Mallocs:     m.Mallocs,
+			Frees:       m.Frees,
+			HeapAlloc:   m.HeapAlloc,
+			HeapSys:     m.HeapSys,
+			HeapIdle:    m.HeapIdle,
+			HeapInuse:   m.HeapInuse,
+			HeapObjects: m.HeapObjects,
+			StackInuse:  m.StackInuse,
+		},
+	}
+}
+
+func handleRuntime(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(ReadRuntimeStats())
+}
+
+func attachment(w http.ResponseWriter, name string) {
+	filename := fmt.Sprintf("%s-%s", name, time.Now().Format("20060102150405"))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+}
+
+// handleGoroutineDump 导出所有协程的完整调用栈。
+func handleGoroutineDump(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+	attachment(w, "goroutine.txt")
+	_ = rpprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// handleHeapDump 导出 pprof 格式的堆信息，携带 gc=1 参数时先执行一次垃圾回收。
+func handleHeapDump(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("gc") == "1" {
+		runtime.GC()
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	attachment(w, "heap.pprof")
+	_ = rpprof.Lookup("heap").WriteTo(w, 0)
+}
@@ -0,0 +1,225 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package diagnostics_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/starter-diagnostics/diagnostics"
+)
+
+func TestServer(t *testing.T) {
+
+	s := diagnostics.NewServer(diagnostics.Config{Username: "admin", Password: "secret"})
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	get := func(path string, auth bool) *http.Response {
+		r, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if auth {
+			r.SetBasicAuth("admin", "secret")
+		}
+		resp, err := http.DefaultClient.Do(r)
+		assert.Nil(t, err)
+		return resp
+	}
+
+	resp := get("/debug/runtime", false)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusUnauthorized)
+
+	resp = get("/debug/runtime", true)
+	var stats diagnostics.RuntimeStats
+	err := json.NewDecoder(resp.Body).Decode(&stats)
+	resp.Body.Close()
+	assert.Nil(t, err)
+	assert.True(t, stats.NumGoroutine > 0)
+	assert.True(t, stats.Memory.HeapAlloc > 0)
+
+	resp = get("/debug/dump/goroutine", true)
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(b), "goroutine "))
+	assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Disposition"), "attachment"))
+
+	resp = get("/debug/dump/heap?gc=1", true)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+
+	resp = get("/debug/pprof/goroutine?debug=1", true)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+}
+
+func TestStartStop(t *testing.T) {
+	s := diagnostics.NewServer(diagnostics.Config{Host: "127.0.0.1"})
+	errChan := make(chan error, 1)
+	go func() { errChan <- s.Start() }()
+	assert.Nil(t, s.Stop(context.Background()))
+	assert.Equal(t, <-errChan, http.ErrServerClosed)
+}
+
+func TestProbe(t *testing.T) {
+
+	ready := true
+	drained := 0
+	s := diagnostics.NewServer(diagnostics.Config{Username: "admin", Password: "secret"})
+	s.Probe(func() bool { return ready }, func() { ready = false; drained++ })
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/ready")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+
+	resp, err = http.Get(ts.URL + "/drain")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusUnauthorized)
+	assert.Equal(t, drained, 0)
+
+	r, _ := http.NewRequest(http.MethodGet, ts.URL+"/drain", nil)
+	r.SetBasicAuth("admin", "secret")
+	resp, err = http.DefaultClient.Do(r)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	assert.Equal(t, drained, 1)
+
+	resp, err = http.Get(ts.URL + "/ready")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusServiceUnavailable)
+}
+
+func TestEnv(t *testing.T) {
+
+	props := map[string]string{
+		"db.url":      "mysql://",
+		"db.password": "123",
+		"app.license": "abc",
+	}
+
+	get := func(s *diagnostics.Server) map[string]string {
+		ts := httptest.NewServer(s.Handler())
+		defer ts.Close()
+		resp, err := http.Get(ts.URL + "/debug/env")
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		var m map[string]string
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&m))
+		return m
+	}
+
+	s := diagnostics.NewServer(diagnostics.Config{})
+	s.Env(props, nil)
+	assert.Equal(t, get(s), map[string]string{
+		"db.url":      "mysql://",
+		"db.password": conf.MaskedValue,
+		"app.license": "abc",
+	})
+
+	s = diagnostics.NewServer(diagnostics.Config{SanitizeKeys: []string{"password", "license"}})
+	s.Env(props, nil)
+	assert.Equal(t, get(s)["app.license"], conf.MaskedValue)
+
+	s = diagnostics.NewServer(diagnostics.Config{})
+	s.Env(props, conf.NewSanitizer("url"))
+	assert.Equal(t, get(s), map[string]string{
+		"db.url":      conf.MaskedValue,
+		"db.password": "123",
+		"app.license": "abc",
+	})
+
+	s = diagnostics.NewServer(diagnostics.Config{})
+	s.Env(props, nil)
+	s.Origin(map[string]string{"db.url": "defaults:db"})
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/debug/env?origin=true")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	var m map[string]diagnostics.EnvProperty
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&m))
+	assert.Equal(t, m, map[string]diagnostics.EnvProperty{
+		"db.url":      {Value: "mysql://", Origin: "defaults:db"},
+		"db.password": {Value: conf.MaskedValue},
+		"app.license": {Value: "abc"},
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+
+	s := diagnostics.NewServer(diagnostics.Config{})
+	ts := httptest.NewServer(s.Handler())
+	resp, err := http.Get(ts.URL + "/debug/snapshot")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusNotFound)
+	ts.Close()
+
+	var fail bool
+	s.Snapshot(func() (interface{}, error) {
+		if fail {
+			return nil, errors.New("not refreshed")
+		}
+		return map[string]string{"go-spring": "v1"}, nil
+	})
+	ts = httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	resp, err = http.Get(ts.URL + "/debug/snapshot")
+	assert.Nil(t, err)
+	var m map[string]string
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&m))
+	resp.Body.Close()
+	assert.Equal(t, m, map[string]string{"go-spring": "v1"})
+
+	fail = true
+	resp, err = http.Get(ts.URL + "/debug/snapshot")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusInternalServerError)
+}
+
+func TestFootprint(t *testing.T) {
+
+	s := diagnostics.NewServer(diagnostics.Config{})
+	s.Footprint(func() (interface{}, error) {
+		return map[string]int64{"total": 1024}, nil
+	})
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/debug/footprint")
+	assert.Nil(t, err)
+	var m map[string]int64
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&m))
+	resp.Body.Close()
+	assert.Equal(t, m, map[string]int64{"total": 1024})
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-spring/spring-core/report"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Operations 允许通过管理端口调用的 bean 方法，只有注册时列出的方法才会被暴露。
+type Operations struct {
+	name    string
+	methods map[string]reflect.Value
+}
+
+// Expose 暴露 bean 的指定方法，用于执行清空缓存、轮换密钥等运维操作。方法的第一个
+// 参数可以是 context.Context ，其余参数按顺序从请求体的 JSON 数组中绑定，返回值
+// 最多包含一个结果和一个 error 。方法不存在或者不满足要求时 panic 。
+//
+//	gs.Provide(func(c *Cache) *diagnostics.Operations {
+//		return diagnostics.Expose("cache", c, "Flush", "Evict")
+//	})
+func Expose(name string, bean interface{}, methods ...string) *Operations {
+	ops := &Operations{name: name, methods: make(map[string]reflect.Value)}
+	v := reflect.ValueOf(bean)
+	for _, method := range methods {
+		m := v.MethodByName(method)
+		if !m.IsValid() {
+			panic(fmt.Errorf("method %s not found in %s", method, v.Type()))
+		}
+		t := m.Type()
+		if t.NumOut() > 2 || (t.NumOut() == 2 && t.Out(1) != errorType) {
+			panic(fmt.Errorf("method %s of %s should return at most a result and an error", method, v.Type()))
+		}
+		ops.methods[method] = m
+	}
+	return ops
+}
+
+// OperationInfo 可以调用的方法的描述。
+type OperationInfo struct {
+	Name string   `json:"name"`
+	Path string   `json:"path"`
+	Args []string `json:"args"`
+}
+
+func (s *Server) operations() []OperationInfo {
+	ret := []OperationInfo{}
+	for _, ops := range s.ops {
+		for method, m := range ops.methods {
+			info := OperationInfo{
+				Name: ops.name + "." + method,
+				Path: "/ops/" + ops.name + "/" + method,
+				Args: []string{},
+			}
+			for _, t := range argTypes(m.Type()) {
+				info.Args = append(info.Args, t.String())
+			}
+			ret = append(ret, info)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// argTypes 返回需要从请求体中绑定的参数类型。
+func argTypes(t reflect.Type) []reflect.Type {
+	var ret []reflect.Type
+	for i := 0; i < t.NumIn(); i++ {
+		if i == 0 && t.In(i) == contextType {
+			continue
+		}
+		ret = append(ret, t.In(i))
+	}
+	return ret
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// handleOps GET /ops 返回可以调用的方法，POST /ops/{name}/{method} 调用方法。
+func (s *Server) handleOps(w http.ResponseWriter, r *http.Request) {
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/ops"), "/")
+	if path == "" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		writeJSON(w, http.StatusOK, s.operations())
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	var m reflect.Value
+	if ss := strings.Split(path, "/"); len(ss) == 2 {
+		for _, ops := range s.ops {
+			if ops.name == ss[0] {
+				m = ops.methods[ss[1]]
+				break
+			}
+		}
+	}
+	if !m.IsValid() {
+		writeError(w, http.StatusNotFound, fmt.Errorf("operation %s not found", path))
+		return
+	}
+
+	args, err := bindArgs(w, r, m.Type())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	logger.WithContext(r.Context()).Infof("operation %s invoked by %s", path, r.RemoteAddr)
+	result, err := invoke(r.Context(), m, args)
+	if err != nil {
+		logger.WithContext(r.Context()).Warnf("operation %s failed: %v", path, err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"result": result})
+}
+
+// bindArgs 从请求体的 JSON 数组中按顺序绑定参数，没有参数时请求体可以为空。
+func bindArgs(w http.ResponseWriter, r *http.Request, t reflect.Type) ([]reflect.Value, error) {
+
+	b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []json.RawMessage
+	if len(strings.TrimSpace(string(b))) > 0 {
+		if err = json.Unmarshal(b, &raw); err != nil {
+			return nil, fmt.Errorf("request body should be a json array: %w", err)
+		}
+	}
+
+	types := argTypes(t)
+	if len(raw) != len(types) {
+		return nil, fmt.Errorf("expect %d args but got %d", len(types), len(raw))
+	}
+
+	var args []reflect.Value
+	if t.NumIn() > 0 && t.In(0) == contextType {
+		args = append(args, reflect.ValueOf(r.Context()))
+	}
+	for i, at := range types {
+		v := reflect.New(at)
+		if err = json.Unmarshal(raw[i], v.Interface()); err != nil {
+			return nil, fmt.Errorf("bind arg %d error: %w", i, err)
+		}
+		args = append(args, v.Elem())
+	}
+	return args, nil
+}
+
+func invoke(ctx context.Context, m reflect.Value, args []reflect.Value) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			report.Panic(ctx, report.SourceWeb, r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	out := m.Call(args)
+	if n := len(out); n > 0 && m.Type().Out(n-1) == errorType {
+		if e := out[n-1].Interface(); e != nil {
+			return nil, e.(error)
+		}
+		out = out[:n-1]
+	}
+	if len(out) > 0 {
+		result = out[0].Interface()
+	}
+	return result, nil
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package diagnostics_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/starter-diagnostics/diagnostics"
+)
+
+type Cache struct {
+	data map[string]string
+}
+
+func (c *Cache) Flush(ctx context.Context) int {
+	n := len(c.data)
+	c.data = map[string]string{}
+	return n
+}
+
+func (c *Cache) Evict(key string, force bool) error {
+	if _, ok := c.data[key]; !ok && !force {
+		return errors.New("key not found")
+	}
+	delete(c.data, key)
+	return nil
+}
+
+func (c *Cache) Dump() map[string]string {
+	return c.data
+}
+
+func TestServer_Ops(t *testing.T) {
+
+	c := &Cache{data: map[string]string{"a": "1", "b": "2"}}
+
+	newServer := func(enabled bool) *httptest.Server {
+		s := diagnostics.NewServer(diagnostics.Config{Ops: enabled})
+		s.Expose(diagnostics.Expose("cache", c, "Flush", "Evict"))
+		return httptest.NewServer(s.Handler())
+	}
+
+	do := func(ts *httptest.Server, method, path, body string) (int, string) {
+		r, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(r)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		assert.Nil(t, err)
+		return resp.StatusCode, strings.TrimSpace(string(b))
+	}
+
+	ts := newServer(false)
+	code, _ := do(ts, http.MethodGet, "/ops", "")
+	assert.Equal(t, code, http.StatusNotFound)
+	ts.Close()
+
+	ts = newServer(true)
+	defer ts.Close()
+
+	code, body := do(ts, http.MethodGet, "/ops", "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, `[{"name":"cache.Evict","path":"/ops/cache/Evict","args":["string","bool"]},`+
+		`{"name":"cache.Flush","path":"/ops/cache/Flush","args":[]}]`)
+
+	code, body = do(ts, http.MethodPost, "/ops/cache/Dump", "")
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.Equal(t, body, `{"error":"operation cache/Dump not found"}`)
+
+	code, _ = do(ts, http.MethodGet, "/ops/cache/Flush", "")
+	assert.Equal(t, code, http.StatusMethodNotAllowed)
+
+	code, body = do(ts, http.MethodPost, "/ops/cache/Evict", `["a"]`)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.Equal(t, body, `{"error":"expect 2 args but got 1"}`)
+
+	code, body = do(ts, http.MethodPost, "/ops/cache/Evict", `["a", "yes"]`)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.True(t, strings.HasPrefix(body, `{"error":"bind arg 1 error`))
+
+	code, body = do(ts, http.MethodPost, "/ops/cache/Evict", `["x", false]`)
+	assert.Equal(t, code, http.StatusInternalServerError)
+	assert.Equal(t, body, `{"error":"key not found"}`)
+
+	code, body = do(ts, http.MethodPost, "/ops/cache/Evict", `["a", false]`)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, `{"result":null}`)
+
+	code, body = do(ts, http.MethodPost, "/ops/cache/Flush", "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, `{"result":1}`)
+}
+
+func TestExpose(t *testing.T) {
+	assert.Panic(t, func() {
+		diagnostics.Expose("cache", &Cache{}, "Clear")
+	}, "method Clear not found in \\*diagnostics_test.Cache")
+}
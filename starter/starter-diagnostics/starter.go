@@ -0,0 +1,72 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package StarterDiagnostics
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
+	"github.com/go-spring/starter-diagnostics/diagnostics"
+)
+
+func init() {
+	c := cond.OnProperty("management.diagnostics.enabled", cond.HavingValue("true"))
+	gs.Provide(diagnostics.NewServer, "${management.diagnostics}").On(c)
+	gs.Object(new(Starter)).Export((*gs.AppEvent)(nil)).On(c)
+}
+
+// Starter 诊断服务器启动器
+type Starter struct {
+	Server     *diagnostics.Server       `autowire:""`
+	Operations []*diagnostics.Operations `autowire:"*?"`
+	Sanitizer  conf.Sanitizer            `autowire:"?"`
+}
+
+// OnAppStart 应用程序启动事件。
+func (s *Starter) OnAppStart(ctx gs.Context) {
+	s.Server.Expose(s.Operations...)
+	s.Server.Probe(gs.Ready, gs.Drain)
+	props := make(map[string]string)
+	origin := make(map[string]string)
+	for _, k := range ctx.Keys() {
+		props[k] = ctx.Prop(k)
+		if source := ctx.PropertySource(k); source != "" {
+			origin[k] = source
+		}
+	}
+	s.Server.Env(props, s.Sanitizer)
+	s.Server.Origin(origin)
+	s.Server.Snapshot(func() (interface{}, error) {
+		return ctx.Snapshot()
+	})
+	s.Server.Footprint(func() (interface{}, error) {
+		return ctx.Footprint()
+	})
+	ctx.Go(func(_ context.Context) {
+		if err := s.Server.Start(); err != nil && err != http.ErrServerClosed {
+			gs.ShutDown(err.Error())
+		}
+	})
+}
+
+// OnAppStop 应用程序结束事件。
+func (s *Starter) OnAppStop(ctx context.Context) {
+	_ = s.Server.Stop(ctx)
+}
@@ -18,9 +18,12 @@ package StarterEcho
 
 import (
 	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
 	"github.com/go-spring/spring-echo"
 )
 
 func init() {
-	gs.Provide(SpringEcho.New, "${web.server}")
+	gs.Provide(SpringEcho.New, "${web.server}").
+		On(cond.OnWebContainer("echo")).
+		Describe("web container based on echo, configured by web.server.*")
 }
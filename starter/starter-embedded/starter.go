@@ -0,0 +1,77 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package StarterEmbedded
+
+import (
+	"context"
+
+	"github.com/go-spring/spring-base/util"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
+	"github.com/go-spring/spring-core/mq"
+	"github.com/go-spring/spring-core/redis"
+)
+
+func init() {
+
+	gs.Provide(redis.NewMemoryClient).
+		Name("RedisClient").
+		On(onEmbedded("redis.host"))
+
+	gs.Provide(mq.NewMemoryBroker).
+		Export((*mq.Producer)(nil)).
+		On(onEmbedded("amqp.server.url")).
+		Destroy(func(b *mq.MemoryBroker) { b.Close() })
+
+	gs.Object(new(Starter)).
+		Export((*gs.AppEvent)(nil)).
+		On(onEmbedded("amqp.server.url"))
+}
+
+// onEmbedded 在 dev 或者 test 环境下并且没有配置外部服务的地址时成立。
+func onEmbedded(property string) cond.Condition {
+	profile := cond.Group(cond.Or, cond.OnProfile("dev"), cond.OnProfile("test"))
+	return cond.On(profile).OnMissingProperty(property)
+}
+
+// Starter 在应用启动时将全部消费者订阅到内存消息代理上。
+type Starter struct {
+	Broker *mq.MemoryBroker `autowire:""`
+}
+
+func (starter *Starter) OnAppStart(ctx gs.Context) {
+
+	var consumers []mq.Consumer
+	err := ctx.Get(&consumers)
+	util.Panic(err).When(err != nil)
+
+	var bindConsumers *gs.Consumers
+	err = ctx.Get(&bindConsumers)
+	util.Panic(err).When(err != nil)
+
+	bindConsumers.ForEach(func(c mq.Consumer) {
+		consumers = append(consumers, c)
+	})
+
+	for _, c := range consumers {
+		starter.Broker.Subscribe(c)
+	}
+}
+
+func (starter *Starter) OnAppStop(ctx context.Context) {
+
+}
@@ -0,0 +1,10 @@
+module github.com/go-spring/starter-gateway
+
+go 1.14
+
+require github.com/go-spring/spring-core v1.1.0-rc3
+
+replace (
+	github.com/go-spring/spring-base => ../../spring/spring-base
+	github.com/go-spring/spring-core => ../../spring/spring-core
+)
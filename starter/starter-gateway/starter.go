@@ -0,0 +1,33 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package StarterGateway
+
+import (
+	"github.com/go-spring/spring-core/gateway"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
+)
+
+func init() {
+	gs.Object(new(gateway.StaticDiscovery)).
+		Export((*gateway.Discovery)(nil)).
+		On(cond.OnProperty("gateway.services"))
+	gs.Provide(gateway.New, "${gateway}", "?").
+		On(cond.OnProperty("gateway.routes"))
+	gs.Provide(gateway.NewPrefilter).
+		On(cond.OnProperty("gateway.routes"))
+}
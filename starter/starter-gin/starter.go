@@ -18,9 +18,12 @@ package StarterGin
 
 import (
 	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
 	"github.com/go-spring/spring-gin"
 )
 
 func init() {
-	gs.Provide(SpringGin.New, "${web.server}")
+	gs.Provide(SpringGin.New, "${web.server}").
+		On(cond.OnWebContainer("gin")).
+		Describe("web container based on gin, configured by web.server.*")
 }
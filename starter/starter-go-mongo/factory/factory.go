@@ -21,6 +21,7 @@ import (
 
 	"github.com/go-spring/spring-base/log"
 	"github.com/go-spring/spring-core/mongo"
+	"github.com/go-spring/spring-core/waitfor"
 	g "go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -34,7 +35,10 @@ func NewClient(config mongo.ClientConfig) (*g.Client, error) {
 		return nil, err
 	}
 	if config.Ping {
-		if err = client.Ping(context.Background(), readpref.Primary()); err != nil {
+		err = waitfor.Wait("mongo", config.WaitFor, func(ctx context.Context) error {
+			return client.Ping(ctx, readpref.Primary())
+		})
+		if err != nil {
 			return nil, err
 		}
 	}
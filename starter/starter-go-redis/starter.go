@@ -26,5 +26,6 @@ import (
 func init() {
 	gs.Provide(SpringGoRedis.NewClient, "${redis}").
 		Name("RedisClient").
+		Describe("redis client based on go-redis, configured by redis.*").
 		On(cond.OnMissingBean(gs.BeanID((*redis.Client)(nil), "RedisClient")))
 }
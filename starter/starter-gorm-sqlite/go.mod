@@ -0,0 +1,16 @@
+module github.com/go-spring/starter-gorm-sqlite
+
+go 1.14
+
+require (
+	github.com/go-spring/spring-base v1.1.0-rc3
+	github.com/go-spring/spring-core v1.1.0-rc3
+	github.com/mattn/go-sqlite3 v1.14.6 // indirect
+	gorm.io/driver/sqlite v1.1.4
+	gorm.io/gorm v1.22.4
+)
+
+replace (
+	github.com/go-spring/spring-base => ../../spring/spring-base
+	github.com/go-spring/spring-core => ../../spring/spring-core
+)
@@ -0,0 +1,53 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package StarterSqliteGorm 在 dev 或者 test 环境下并且没有配置 gorm.url 时，
+// 使用嵌入式的 sqlite 内存数据库替代真实的数据库。
+package StarterSqliteGorm
+
+import (
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func init() {
+	profile := cond.Group(cond.Or, cond.OnProfile("dev"), cond.OnProfile("test"))
+	gs.Provide(createDB, "${gorm.embedded}", "*?").
+		Name("GormDB").
+		On(cond.On(profile).OnMissingProperty("gorm.url"))
+}
+
+// Config 嵌入式数据库配置，默认使用共享缓存的内存数据库，进程退出后数据即丢失。
+type Config struct {
+	Url string `value:"${url:=file::memory:?cache=shared}"`
+}
+
+func createDB(config Config, plugins []gorm.Plugin) (*gorm.DB, error) {
+	log.Infof("open gorm sqlite %s", config.Url)
+	db, err := gorm.Open(sqlite.Open(config.Url))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range plugins {
+		if err = db.Use(p); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package audit 为 gorm 实体自动维护审计字段、软删除和乐观锁，约定的字段如下：
+//
+//	CreatedBy string         // 创建时填入当前用户
+//	UpdatedBy string         // 创建和修改时填入当前用户
+//	DeletedBy string         // 配合 gorm.DeletedAt 使用，软删除时填入当前用户
+//	Version   int64          // 修改时检查版本号并且加 1 ，版本号不一致时返回 ErrStaleObject
+//
+// 当前用户通过 SetPrincipal 或者 NewFilter 保存在请求上下文中，使用 db.WithContext
+// 传给 gorm 。实体的字段名可以通过 Plugin.Entity 单独配置，没有对应字段时不做处理。
+package audit
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-spring/spring-base/knife"
+	"github.com/go-spring/spring-core/web"
+)
+
+// ctxKey 当前用户在请求上下文中的 key 。
+const ctxKey = "::audit-principal::"
+
+// SetPrincipal 将当前用户保存到上下文中，ctx 必须是 knife 上下文。
+func SetPrincipal(ctx context.Context, principal string) error {
+	return knife.Store(ctx, ctxKey, principal)
+}
+
+// Principal 返回上下文中保存的当前用户。
+func Principal(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	v, err := knife.Load(ctx, ctxKey)
+	if err != nil || v == nil {
+		return "", false
+	}
+	return v.(string), true
+}
+
+// NewFilter 创建解析当前用户并将其保存到请求上下文的过滤器，resolve 返回空字符串
+// 表示匿名用户，返回错误时拒绝请求。
+func NewFilter(resolve func(ctx web.Context) (string, error)) web.Filter {
+	return web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		principal, err := resolve(ctx)
+		if err != nil {
+			ctx.SetStatus(http.StatusUnauthorized)
+			ctx.String(err.Error())
+			return
+		}
+		if principal != "" {
+			if err = ctx.Set(ctxKey, principal); err != nil {
+				panic(err)
+			}
+		}
+		chain.Continue(ctx)
+	})
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-base/knife"
+	"github.com/go-spring/starter-gorm/audit"
+	"github.com/go-spring/starter-gorm/mysql/factory"
+	"gorm.io/gorm"
+)
+
+type Document struct {
+	ID        int
+	Title     string
+	CreatedBy string
+	UpdatedBy string
+	DeletedBy string
+	DeletedAt gorm.DeletedAt
+	Version   int64
+}
+
+func open(t *testing.T, p *audit.Plugin) (*gorm.DB, context.Context) {
+	db, _, err := factory.MockDB()
+	assert.Nil(t, err)
+	assert.Nil(t, db.Use(p))
+	ctx, _ := knife.New(context.Background())
+	assert.Nil(t, audit.SetPrincipal(ctx, "jim"))
+	return db.Session(&gorm.Session{DryRun: true, Context: ctx}), ctx
+}
+
+func TestPrincipal(t *testing.T) {
+	_, ok := audit.Principal(context.Background())
+	assert.False(t, ok)
+	ctx, _ := knife.New(context.Background())
+	assert.Nil(t, audit.SetPrincipal(ctx, "jim"))
+	principal, ok := audit.Principal(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, principal, "jim")
+}
+
+func TestCreate(t *testing.T) {
+	db, _ := open(t, audit.NewPlugin())
+	doc := &Document{Title: "a"}
+	assert.Nil(t, db.Create(doc).Error)
+	assert.Equal(t, doc.CreatedBy, "jim")
+	assert.Equal(t, doc.UpdatedBy, "jim")
+	assert.Equal(t, doc.Version, int64(1))
+
+	db, _ = open(t, audit.NewPlugin().Entity(&Document{}, audit.Options{}))
+	doc = &Document{Title: "a"}
+	assert.Nil(t, db.Create(doc).Error)
+	assert.Equal(t, doc.CreatedBy, "")
+	assert.Equal(t, doc.Version, int64(0))
+}
+
+func TestUpdate(t *testing.T) {
+	db, _ := open(t, audit.NewPlugin())
+	doc := &Document{ID: 1, Title: "a", Version: 3}
+	stmt := db.Model(doc).Updates(map[string]interface{}{"title": "b"}).Statement
+	assert.Matches(t, stmt.SQL.String(), "^UPDATE `documents` SET `title`=\\?,`updated_by`=\\?,`version`=\\? WHERE .*`documents`.`version` = \\?")
+	assert.Equal(t, stmt.Vars[:4], []interface{}{"b", "jim", int64(4), int64(3)})
+	assert.Equal(t, doc.Version, int64(4))
+}
+
+func TestDelete(t *testing.T) {
+	db, _ := open(t, audit.NewPlugin())
+	stmt := db.Delete(&Document{ID: 1}).Statement
+	assert.Equal(t, stmt.SQL.String(), "UPDATE `documents` SET `deleted_at`=?,`deleted_by`=? WHERE `documents`.`id` = ? AND `documents`.`deleted_at` IS NULL")
+	assert.Equal(t, stmt.Vars[1], "jim")
+
+	stmt = db.Unscoped().Delete(&Document{ID: 1}).Statement
+	assert.Equal(t, stmt.SQL.String(), "DELETE FROM `documents` WHERE `documents`.`id` = ?")
+}
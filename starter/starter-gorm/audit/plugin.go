@@ -0,0 +1,257 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrStaleObject 实体已经被其他人修改或者删除。
+var ErrStaleObject = errors.New("audit: stale object")
+
+var deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+
+// versionKey 保存修改前的版本号。
+const versionKey = "audit:version"
+
+// Options 实体的审计选项，值为字段名，为空时关闭对应的功能。
+type Options struct {
+	CreatedBy string
+	UpdatedBy string
+	DeletedBy string
+	Version   string
+}
+
+// DefaultOptions 返回按照约定命名的选项。
+func DefaultOptions() Options {
+	return Options{
+		CreatedBy: "CreatedBy",
+		UpdatedBy: "UpdatedBy",
+		DeletedBy: "DeletedBy",
+		Version:   "Version",
+	}
+}
+
+// Plugin 维护审计字段的 gorm 插件，通过 db.Use 注册。
+type Plugin struct {
+	defaults Options
+	entities map[reflect.Type]Options
+}
+
+// NewPlugin 创建 Plugin ，所有的实体默认使用 DefaultOptions 。
+func NewPlugin() *Plugin {
+	return &Plugin{
+		defaults: DefaultOptions(),
+		entities: make(map[reflect.Type]Options),
+	}
+}
+
+// Defaults 设置实体的默认选项。
+func (p *Plugin) Defaults(opts Options) *Plugin {
+	p.defaults = opts
+	return p
+}
+
+// Entity 设置 model 实体的选项，Options{} 表示不处理该实体。需要在 db.Use 之前调用。
+func (p *Plugin) Entity(model interface{}, opts Options) *Plugin {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	p.entities[t] = opts
+	return p
+}
+
+// Name 返回插件的名称。
+func (p *Plugin) Name() string {
+	return "go-spring:audit"
+}
+
+// Initialize 注册创建、修改和删除的回调。
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("audit:before_create", p.beforeCreate); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("audit:before_update", p.beforeUpdate); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("audit:after_update", p.afterUpdate); err != nil {
+		return err
+	}
+	return cb.Delete().Before("gorm:delete").Register("audit:before_delete", p.beforeDelete)
+}
+
+type fields struct {
+	createdBy *schema.Field
+	updatedBy *schema.Field
+	deletedBy *schema.Field
+	deletedAt *schema.Field
+	version   *schema.Field
+}
+
+func (p *Plugin) fields(s *schema.Schema) fields {
+	opts, ok := p.entities[s.ModelType]
+	if !ok {
+		opts = p.defaults
+	}
+	lookup := func(name string) *schema.Field {
+		if name == "" {
+			return nil
+		}
+		return s.LookUpField(name)
+	}
+	f := fields{
+		createdBy: lookup(opts.CreatedBy),
+		updatedBy: lookup(opts.UpdatedBy),
+		deletedBy: lookup(opts.DeletedBy),
+		version:   lookup(opts.Version),
+	}
+	for _, field := range s.Fields {
+		if field.FieldType == deletedAtType {
+			f.deletedAt = field
+		}
+	}
+	return f
+}
+
+// setIfZero 为实体或者实体切片中值为零的字段赋值。
+func setIfZero(rv reflect.Value, f *schema.Field, value interface{}) {
+	if f == nil {
+		return
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			setIfZero(reflect.Indirect(rv.Index(i)), f, value)
+		}
+	case reflect.Struct:
+		if _, zero := f.ValueOf(rv); zero {
+			_ = f.Set(rv, value)
+		}
+	}
+}
+
+func (p *Plugin) beforeCreate(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return
+	}
+	f := p.fields(stmt.Schema)
+	if principal, ok := Principal(stmt.Context); ok {
+		setIfZero(stmt.ReflectValue, f.createdBy, principal)
+		setIfZero(stmt.ReflectValue, f.updatedBy, principal)
+	}
+	setIfZero(stmt.ReflectValue, f.version, int64(1))
+}
+
+// omitted 返回字段是否被 Omit 排除。
+func omitted(stmt *gorm.Statement, f *schema.Field) bool {
+	columns, _ := stmt.SelectAndOmitColumns(false, true)
+	v, ok := columns[f.DBName]
+	return ok && !v
+}
+
+func (p *Plugin) beforeUpdate(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return
+	}
+	f := p.fields(stmt.Schema)
+	if f.updatedBy != nil && !omitted(stmt, f.updatedBy) {
+		if principal, ok := Principal(stmt.Context); ok {
+			stmt.SetColumn(f.updatedBy.DBName, principal, true)
+		}
+	}
+	// 只有修改单个已经加载的实体时才检查版本号。
+	if f.version == nil || stmt.ReflectValue.Kind() != reflect.Struct {
+		return
+	}
+	v, zero := f.version.ValueOf(stmt.ReflectValue)
+	if zero {
+		return
+	}
+	version := reflect.ValueOf(v)
+	var old int64
+	switch version.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		old = version.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		old = int64(version.Uint())
+	default:
+		return
+	}
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: f.version.DBName}, Value: old},
+	}})
+	stmt.SetColumn(f.version.DBName, old+1, true)
+	db.InstanceSet(versionKey, old)
+}
+
+// afterUpdate 没有修改任何记录时说明版本号已经变化，恢复实体的版本号并返回错误。
+func (p *Plugin) afterUpdate(db *gorm.DB) {
+	old, ok := db.InstanceGet(versionKey)
+	if !ok || db.DryRun || (db.Error == nil && db.RowsAffected > 0) {
+		return
+	}
+	if f := p.fields(db.Statement.Schema).version; f != nil {
+		_ = f.Set(db.Statement.ReflectValue, old)
+	}
+	if db.Error == nil {
+		_ = db.AddError(ErrStaleObject)
+	}
+}
+
+// beforeDelete 软删除时同时记录删除人，生成的语句和 gorm.DeletedAt 相同。
+func (p *Plugin) beforeDelete(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || stmt.Unscoped || stmt.SQL.Len() > 0 {
+		return
+	}
+	f := p.fields(stmt.Schema)
+	if f.deletedBy == nil || f.deletedAt == nil {
+		return
+	}
+	principal, ok := Principal(stmt.Context)
+	if !ok {
+		return
+	}
+
+	now := db.NowFunc()
+	stmt.AddClause(clause.Set{
+		{Column: clause.Column{Name: f.deletedAt.DBName}, Value: now},
+		{Column: clause.Column{Name: f.deletedBy.DBName}, Value: principal},
+	})
+	stmt.SetColumn(f.deletedAt.DBName, now, true)
+	stmt.SetColumn(f.deletedBy.DBName, principal, true)
+
+	if stmt.ReflectValue.IsValid() {
+		_, queryValues := schema.GetIdentityFieldValuesMap(stmt.ReflectValue, stmt.Schema.PrimaryFields)
+		column, values := schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, queryValues)
+		if len(values) > 0 {
+			stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.IN{Column: column, Values: values}}})
+		}
+	}
+	gorm.SoftDeleteQueryClause{Field: f.deletedAt}.ModifyStatement(stmt)
+	stmt.AddClauseIfNotExists(clause.Update{})
+	stmt.Build(db.Callback().Update().Clauses...)
+}
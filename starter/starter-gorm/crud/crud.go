@@ -0,0 +1,514 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package crud 根据 gorm 实体自动注册 REST 风格的增删改查接口，适用于管理后台等
+// 样板代码较多的场景，例如：
+//
+//	gs.Object(crud.New("/users", &User{}).Filter("name", "status"))
+//
+// 注册的接口如下，其中 {id} 为实体的主键：
+//
+//	GET    /users       分页查询，支持 page、size、sort、fields 和过滤参数
+//	GET    /users/{id}  查询一个实体，支持 fields 参数
+//	POST   /users       创建实体
+//	PUT    /users/{id}  修改实体，请求体中没有的字段保持不变
+//	DELETE /users/{id}  删除实体
+//
+// 查询参数和请求体使用实体的 json 字段名，fields 参数只返回指定的字段，例如
+// ?fields=id,name 。创建和修改的实体在保存之前使用 validator.Validate 校验。
+package crud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/validator"
+	"github.com/go-spring/spring-core/web"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Operation 增删改查操作，可以使用 | 组合多个操作。
+type Operation int
+
+const (
+	List Operation = 1 << iota
+	Get
+	Create
+	Update
+	Delete
+	All = List | Get | Create | Update | Delete
+)
+
+// Page 分页查询的结果。
+type Page struct {
+	Items interface{} `json:"items"`
+	Page  int         `json:"page"`
+	Size  int         `json:"size"`
+	Total int64       `json:"total"`
+}
+
+// Scope 根据请求修改查询条件，例如只查询当前租户的数据。
+type Scope func(ctx web.Context, db *gorm.DB) *gorm.DB
+
+// Resource 基于 gorm 实体的 REST 资源。
+type Resource struct {
+	prefix   string
+	t        reflect.Type
+	ops      Operation
+	filters  []string
+	sorts    []string
+	scopes   []Scope
+	handlers map[Operation]web.HandlerFunc
+	selector string
+
+	db      *gorm.DB
+	fields  map[string]*schema.Field // json 字段名 -> 字段
+	primary *schema.Field
+}
+
+// New 创建 REST 资源，model 为 gorm 实体或者实体的指针，prefix 为接口的路径前缀。
+func New(prefix string, model interface{}) *Resource {
+	t := reflect.TypeOf(model)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		panic(errors.New("model should be a struct or a pointer to struct"))
+	}
+	return &Resource{
+		prefix:   strings.TrimSuffix(prefix, "/"),
+		t:        t,
+		ops:      All,
+		handlers: make(map[Operation]web.HandlerFunc),
+	}
+}
+
+// Only 只注册指定的操作，默认注册所有的操作。
+func (r *Resource) Only(ops Operation) *Resource {
+	r.ops = ops
+	return r
+}
+
+// Filter 允许作为过滤条件的字段，分页查询时 ?status=paid 查询 status 等于 paid 的
+// 实体，同一个字段出现多次时查询等于其中任何一个值的实体。
+func (r *Resource) Filter(fields ...string) *Resource {
+	r.filters = append(r.filters, fields...)
+	return r
+}
+
+// Sort 允许排序的字段，默认允许所有的字段。
+func (r *Resource) Sort(fields ...string) *Resource {
+	r.sorts = append(r.sorts, fields...)
+	return r
+}
+
+// Scope 添加查询、修改和删除时使用的查询条件，不影响创建。
+func (r *Resource) Scope(fn Scope) *Resource {
+	r.scopes = append(r.scopes, fn)
+	return r
+}
+
+// Override 使用 fn 代替指定操作的默认实现。
+func (r *Resource) Override(op Operation, fn web.HandlerFunc) *Resource {
+	r.handlers[op] = fn
+	return r
+}
+
+// Using 使用指定名称的 *gorm.DB ，容器中有多个数据库时使用。
+func (r *Resource) Using(selector string) *Resource {
+	r.selector = selector
+	return r
+}
+
+// OnInit 从容器中获取 *gorm.DB 和 web.Router 并注册接口。
+func (r *Resource) OnInit(ctx gs.Context) error {
+	var selectors []gs.BeanSelector
+	if r.selector != "" {
+		selectors = append(selectors, r.selector)
+	}
+	var db *gorm.DB
+	if err := ctx.Get(&db, selectors...); err != nil {
+		return err
+	}
+	var router web.Router
+	if err := ctx.Get(&router); err != nil {
+		return err
+	}
+	return r.Register(router, db)
+}
+
+// Register 在 router 上注册接口。
+func (r *Resource) Register(router web.Router, db *gorm.DB) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(reflect.New(r.t).Interface()); err != nil {
+		return err
+	}
+	r.primary = stmt.Schema.PrioritizedPrimaryField
+	if r.primary == nil {
+		return fmt.Errorf("crud: %s has no primary key", r.t)
+	}
+	r.fields = make(map[string]*schema.Field)
+	for _, f := range stmt.Schema.Fields {
+		if f.DBName == "" {
+			continue
+		}
+		if name := jsonName(f.StructField); name != "-" {
+			r.fields[name] = f
+		}
+	}
+	for _, name := range append(append([]string{}, r.filters...), r.sorts...) {
+		if _, ok := r.fields[name]; !ok {
+			return fmt.Errorf("crud: %s has no field %q", r.t, name)
+		}
+	}
+	r.db = db
+
+	item := r.prefix + "/{id}"
+	if r.ops&List != 0 {
+		router.GetMapping(r.prefix, r.handler(List, r.list))
+	}
+	if r.ops&Get != 0 {
+		router.GetMapping(item, r.handler(Get, r.get))
+	}
+	if r.ops&Create != 0 {
+		router.PostMapping(r.prefix, r.handler(Create, r.create))
+	}
+	if r.ops&Update != 0 {
+		router.PutMapping(item, r.handler(Update, r.update))
+	}
+	if r.ops&Delete != 0 {
+		router.DeleteMapping(item, r.handler(Delete, r.delete))
+	}
+	return nil
+}
+
+func (r *Resource) handler(op Operation, fn web.HandlerFunc) web.HandlerFunc {
+	if h, ok := r.handlers[op]; ok {
+		return h
+	}
+	return fn
+}
+
+// jsonName 返回字段序列化之后的名称。
+func jsonName(f reflect.StructField) string {
+	if tag, ok := f.Tag.Lookup("json"); ok {
+		if name := strings.Split(tag, ",")[0]; name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+func badRequest(err error) error {
+	return web.NewHttpError(http.StatusBadRequest, err.Error())
+}
+
+func internalError(err error) error {
+	return web.NewHttpError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).SetInternal(err)
+}
+
+// session 返回应用了 Scope 的数据库会话。
+func (r *Resource) session(ctx web.Context) *gorm.DB {
+	db := r.db.WithContext(ctx.Context())
+	for _, fn := range r.scopes {
+		db = fn(ctx, db)
+	}
+	return db
+}
+
+func column(f *schema.Field) clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: f.DBName}
+}
+
+// byID 返回按照主键查询的条件，主键的格式不正确时返回错误。
+func (r *Resource) byID(ctx web.Context) (clause.Expression, error) {
+	id := ctx.PathParam("id")
+	var value interface{} = id
+	switch r.primary.IndirectFieldType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %q", id)
+		}
+		value = n
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %q", id)
+		}
+		value = n
+	}
+	return clause.Eq{Column: column(r.primary), Value: value}, nil
+}
+
+// find 按照主键查询实体，不存在时返回 404 错误。
+func (r *Resource) find(ctx web.Context) (reflect.Value, error) {
+	cond, err := r.byID(ctx)
+	if err != nil {
+		return reflect.Value{}, badRequest(err)
+	}
+	v := reflect.New(r.t)
+	err = r.session(ctx).Clauses(clause.Where{Exprs: []clause.Expression{cond}}).First(v.Interface()).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return reflect.Value{}, web.NewHttpError(http.StatusNotFound, "not found")
+	}
+	if err != nil {
+		return reflect.Value{}, internalError(err)
+	}
+	return v, nil
+}
+
+// selected 解析 fields 参数，返回需要返回的字段。
+func (r *Resource) selected(ctx web.Context) ([]string, error) {
+	s := ctx.QueryParam("fields")
+	if s == "" {
+		return nil, nil
+	}
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if _, ok := r.fields[name]; !ok {
+			return nil, fmt.Errorf("invalid field %q", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// columns 返回查询的列，总是包含主键。
+func (r *Resource) columns(names []string) []string {
+	columns := []string{r.primary.DBName}
+	for _, name := range names {
+		if f := r.fields[name]; f != r.primary {
+			columns = append(columns, f.DBName)
+		}
+	}
+	return columns
+}
+
+// project 只保留 v 序列化之后的指定字段，v 可以是实体或者实体的切片。
+func project(v interface{}, names []string) (interface{}, error) {
+	if len(names) == 0 {
+		return v, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	pick := func(m map[string]json.RawMessage) map[string]json.RawMessage {
+		r := make(map[string]json.RawMessage, len(names))
+		for _, name := range names {
+			if value, ok := m[name]; ok {
+				r[name] = value
+			}
+		}
+		return r
+	}
+	if reflect.TypeOf(v).Kind() == reflect.Slice {
+		var items []map[string]json.RawMessage
+		if err = json.Unmarshal(b, &items); err != nil {
+			return nil, err
+		}
+		result := make([]map[string]json.RawMessage, len(items))
+		for i, m := range items {
+			result[i] = pick(m)
+		}
+		return result, nil
+	}
+	var m map[string]json.RawMessage
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return pick(m), nil
+}
+
+// where 返回过滤参数对应的查询条件。
+func (r *Resource) where(ctx web.Context) []clause.Expression {
+	var exprs []clause.Expression
+	params := ctx.QueryParams()
+	for _, name := range r.filters {
+		values := params[name]
+		switch len(values) {
+		case 0:
+			continue
+		case 1:
+			exprs = append(exprs, clause.Eq{Column: column(r.fields[name]), Value: values[0]})
+		default:
+			in := clause.IN{Column: column(r.fields[name])}
+			for _, v := range values {
+				in.Values = append(in.Values, v)
+			}
+			exprs = append(exprs, in)
+		}
+	}
+	return exprs
+}
+
+// orderBy 将排序参数转换成排序条件，没有排序参数时按照主键排序以保证分页稳定。
+func (r *Resource) orderBy(sort web.Sort) (clause.OrderBy, error) {
+	var orderBy clause.OrderBy
+	for _, o := range sort {
+		f, ok := r.fields[o.Field]
+		if ok && len(r.sorts) > 0 {
+			ok = false
+			for _, s := range r.sorts {
+				ok = ok || s == o.Field
+			}
+		}
+		if !ok {
+			return orderBy, fmt.Errorf("invalid sort field %q", o.Field)
+		}
+		orderBy.Columns = append(orderBy.Columns, clause.OrderByColumn{Column: column(f), Desc: o.Desc})
+	}
+	if len(orderBy.Columns) == 0 {
+		orderBy.Columns = append(orderBy.Columns, clause.OrderByColumn{Column: column(r.primary)})
+	}
+	return orderBy, nil
+}
+
+func (r *Resource) list(ctx web.Context) {
+	p, err := web.GetPageable(ctx)
+	if err != nil {
+		panic(badRequest(err))
+	}
+	orderBy, err := r.orderBy(p.Sort)
+	if err != nil {
+		panic(badRequest(err))
+	}
+	names, err := r.selected(ctx)
+	if err != nil {
+		panic(badRequest(err))
+	}
+
+	where := r.where(ctx)
+	filter := func(db *gorm.DB) *gorm.DB {
+		if len(where) == 0 {
+			return db
+		}
+		return db.Clauses(clause.Where{Exprs: where})
+	}
+
+	var total int64
+	err = r.session(ctx).Model(reflect.New(r.t).Interface()).Scopes(filter).Count(&total).Error
+	if err != nil {
+		panic(internalError(err))
+	}
+
+	items := reflect.New(reflect.SliceOf(r.t))
+	db := r.session(ctx).Scopes(filter).Clauses(orderBy).Offset(p.Offset()).Limit(p.Size)
+	if len(names) > 0 {
+		db = db.Select(r.columns(names))
+	}
+	if err = db.Find(items.Interface()).Error; err != nil {
+		panic(internalError(err))
+	}
+	result, err := project(items.Elem().Interface(), names)
+	if err != nil {
+		panic(internalError(err))
+	}
+	ctx.JSON(&Page{Items: result, Page: p.Page, Size: p.Size, Total: total})
+}
+
+func (r *Resource) get(ctx web.Context) {
+	names, err := r.selected(ctx)
+	if err != nil {
+		panic(badRequest(err))
+	}
+	v, err := r.find(ctx)
+	if err != nil {
+		panic(err)
+	}
+	result, err := project(v.Interface(), names)
+	if err != nil {
+		panic(internalError(err))
+	}
+	ctx.JSON(result)
+}
+
+// decode 将 json 格式的请求体解码到 v 中。
+func decode(ctx web.Context, v interface{}) error {
+	body, err := ctx.RequestBody()
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		return badRequest(err)
+	}
+	if err = validator.Validate(v); err != nil {
+		return badRequest(err)
+	}
+	return nil
+}
+
+func (r *Resource) create(ctx web.Context) {
+	v := reflect.New(r.t)
+	if err := decode(ctx, v.Interface()); err != nil {
+		panic(err)
+	}
+	// 自增的主键由数据库生成。
+	if r.primary.AutoIncrement {
+		pk := v.Elem().FieldByIndex(r.primary.StructField.Index)
+		pk.Set(reflect.Zero(pk.Type()))
+	}
+	if err := r.db.WithContext(ctx.Context()).Create(v.Interface()).Error; err != nil {
+		panic(internalError(err))
+	}
+	ctx.SetContentType(web.MIMEApplicationJSONCharsetUTF8)
+	ctx.SetStatus(http.StatusCreated)
+	ctx.JSON(v.Interface())
+}
+
+func (r *Resource) update(ctx web.Context) {
+	v, err := r.find(ctx)
+	if err != nil {
+		panic(err)
+	}
+	pk := v.Elem().FieldByIndex(r.primary.StructField.Index)
+	id := reflect.ValueOf(pk.Interface())
+	if err = decode(ctx, v.Interface()); err != nil {
+		panic(err)
+	}
+	pk.Set(id) // 主键不能被请求体修改
+	if err = r.db.WithContext(ctx.Context()).Save(v.Interface()).Error; err != nil {
+		panic(internalError(err))
+	}
+	ctx.JSON(v.Interface())
+}
+
+func (r *Resource) delete(ctx web.Context) {
+	cond, err := r.byID(ctx)
+	if err != nil {
+		panic(badRequest(err))
+	}
+	db := r.session(ctx).Clauses(clause.Where{Exprs: []clause.Expression{cond}}).Delete(reflect.New(r.t).Interface())
+	if db.Error != nil {
+		panic(internalError(db.Error))
+	}
+	if db.RowsAffected == 0 {
+		panic(web.NewHttpError(http.StatusNotFound, "not found"))
+	}
+	ctx.NoContent(http.StatusNoContent)
+}
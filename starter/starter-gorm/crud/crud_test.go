@@ -0,0 +1,162 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crud_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/web"
+	"github.com/go-spring/starter-gorm/crud"
+	"github.com/go-spring/starter-gorm/mysql/factory"
+)
+
+type User struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name" validate:"required"`
+	Status string `json:"status"`
+}
+
+type context struct {
+	*web.BaseContext
+	id string
+}
+
+func (c *context) PathParam(name string) string {
+	return c.id
+}
+
+func serve(h web.HandlerFunc, method, target, id, body string) (code int, resp string) {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	ctx := &context{BaseContext: web.NewBaseContext("", nil, r, &web.BufferedResponseWriter{ResponseWriter: w}), id: id}
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				code = r.(*web.HttpError).Code
+			}
+		}()
+		h(ctx)
+		code = w.Code
+	}()
+	return code, w.Body.String()
+}
+
+func handler(router web.Router, method uint32, path string) web.HandlerFunc {
+	for _, m := range router.Mappers() {
+		if m.Method() == method && m.Path() == path {
+			return func(ctx web.Context) { m.Handler().Invoke(ctx) }
+		}
+	}
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	db, _, err := factory.MockDB()
+	assert.Nil(t, err)
+
+	router := web.NewRouter()
+	err = crud.New("/users", &User{}).Only(crud.List|crud.Get).Register(router, db)
+	assert.Nil(t, err)
+	assert.Equal(t, len(router.Mappers()), 2)
+	assert.NotNil(t, handler(router, web.MethodGet, "/users"))
+	assert.NotNil(t, handler(router, web.MethodGet, "/users/{id}"))
+
+	err = crud.New("/users", User{}).Filter("age").Register(web.NewRouter(), db)
+	assert.Error(t, err, "has no field \"age\"")
+}
+
+func TestList(t *testing.T) {
+	db, mock, err := factory.MockDB()
+	assert.Nil(t, err)
+
+	router := web.NewRouter()
+	err = crud.New("/users", &User{}).Filter("status").Register(router, db)
+	assert.Nil(t, err)
+	list := handler(router, web.MethodGet, "/users")
+
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `users` WHERE `users`.`status` = \\?").
+		WithArgs("active").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(11))
+	mock.ExpectQuery("SELECT `id`,`name` FROM `users` WHERE `users`.`status` = \\? ORDER BY `users`.`name` DESC LIMIT 10 OFFSET 10").
+		WithArgs("active").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(11, "jim"))
+
+	code, body := serve(list, http.MethodGet, "/users?page=2&size=10&sort=name,desc&status=active&fields=name", "", "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.JsonEqual(t, body, `{"items":[{"name":"jim"}],"page":2,"size":10,"total":11}`)
+	assert.Nil(t, mock.ExpectationsWereMet())
+
+	code, _ = serve(list, http.MethodGet, "/users?sort=password", "", "")
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestGet(t *testing.T) {
+	db, mock, err := factory.MockDB()
+	assert.Nil(t, err)
+
+	router := web.NewRouter()
+	assert.Nil(t, crud.New("/users", &User{}).Register(router, db))
+	get := handler(router, web.MethodGet, "/users/{id}")
+
+	mock.ExpectQuery("SELECT \\* FROM `users` WHERE `users`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}).AddRow(1, "jim", "active"))
+	code, body := serve(get, http.MethodGet, "/users/1", "1", "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.JsonEqual(t, body, `{"id":1,"name":"jim","status":"active"}`)
+
+	mock.ExpectQuery("SELECT \\* FROM `users` WHERE `users`.`id` = \\?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}))
+	code, _ = serve(get, http.MethodGet, "/users/2", "2", "")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _ = serve(get, http.MethodGet, "/users/x", "x", "")
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestCreate(t *testing.T) {
+	db, _, err := factory.MockDB()
+	assert.Nil(t, err)
+
+	router := web.NewRouter()
+	assert.Nil(t, crud.New("/users", &User{}).Register(router, db))
+	create := handler(router, web.MethodPost, "/users")
+
+	code, _ := serve(create, http.MethodPost, "/users", "", `{"status":"active"}`)
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestOverride(t *testing.T) {
+	db, _, err := factory.MockDB()
+	assert.Nil(t, err)
+
+	router := web.NewRouter()
+	r := crud.New("/users", &User{}).Override(crud.Delete, func(ctx web.Context) {
+		ctx.NoContent(http.StatusForbidden)
+	})
+	assert.Nil(t, r.Register(router, db))
+
+	code, _ := serve(handler(router, web.MethodDelete, "/users/{id}"), http.MethodDelete, "/users/1", "1", "")
+	assert.Equal(t, code, http.StatusForbidden)
+}
@@ -17,21 +17,94 @@
 package StarterMySqlGorm
 
 import (
+	"context"
+	"database/sql"
+
 	"github.com/go-spring/spring-base/log"
 	"github.com/go-spring/spring-core/database"
 	"github.com/go-spring/spring-core/gs"
 	"github.com/go-spring/spring-core/gs/cond"
+	"github.com/go-spring/spring-core/waitfor"
+	"github.com/go-spring/starter-gorm/audit"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
 func init() {
-	gs.Provide(createDB, "${gorm}").
+	gs.Provide(createDB, "${gorm}", "${db.replicas}", "*?", "*?").
 		Name("GormDB").
 		On(cond.OnMissingBean(gs.BeanID((*gorm.DB)(nil), "GormDB")))
+	gs.Provide(database.NewMetrics, "${db.metrics}").
+		Export((*database.Interceptor)(nil))
+	gs.Provide(audit.NewPlugin).
+		Export((*gorm.Plugin)(nil)).
+		On(cond.OnProperty("gorm.audit.enabled", cond.HavingValue("true")))
 }
 
-func createDB(config database.ClientConfig) (*gorm.DB, error) {
+func createDB(config database.ClientConfig, replicas database.ReplicaConfig, interceptors []database.Interceptor, plugins []gorm.Plugin) (*gorm.DB, error) {
 	log.Infof("open gorm mysql %s", config.Url)
-	return gorm.Open(mysql.Open(config.Url))
+	conn, err := openConn(config, replicas)
+	if err != nil {
+		return nil, err
+	}
+	err = waitfor.Wait("mysql", config.WaitFor, func(ctx context.Context) error {
+		return primary(conn).PingContext(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var pool gorm.ConnPool = conn
+	if len(interceptors) > 0 {
+		pool = &connPool{database.Intercept(conn, interceptors...)}
+	}
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: config.Url, Conn: pool}))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range plugins {
+		if err = db.Use(p); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// openConn 打开数据库，配置了副本时返回读写分离的数据库。
+func openConn(config database.ClientConfig, replicas database.ReplicaConfig) (database.Conn, error) {
+	if len(replicas.Urls) == 0 {
+		db, err := sql.Open("mysql", config.Url)
+		if err != nil {
+			return nil, err
+		}
+		db.SetMaxOpenConns(config.MaxOpenConns)
+		db.SetMaxIdleConns(config.MaxIdleConns)
+		return db, nil
+	}
+	log.Infof("open gorm mysql with %d replicas", len(replicas.Urls))
+	var lag database.LagFunc = database.MySQLLag
+	if replicas.LagQuery != "" {
+		lag = database.QueryLag(replicas.LagQuery)
+	}
+	return database.OpenRoutingDB("mysql", config, replicas, lag)
+}
+
+// primary 返回主库。
+func primary(conn database.Conn) *sql.DB {
+	if r, ok := conn.(*database.RoutingDB); ok {
+		return r.Primary()
+	}
+	return conn.(*sql.DB)
+}
+
+// connPool 使用拦截器执行 SQL 的 gorm 连接池，事务中的 SQL 同样经过拦截器。
+type connPool struct {
+	*database.InterceptedConn
+}
+
+func (p *connPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	tx, err := p.InterceptedConn.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
 }
@@ -0,0 +1,49 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package page 将 web.Pageable 和 web.Sort 转换成 gorm 的查询条件。
+package page
+
+import (
+	"github.com/go-spring/spring-core/web"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Paginate 返回设置排序、偏移量和数量的 gorm 作用域，例如
+// db.Scopes(page.Paginate(req.Pageable)).Find(&users) 。
+func Paginate(p web.Pageable) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return OrderBy(p.Sort)(db).Offset(p.Offset()).Limit(p.Size)
+	}
+}
+
+// OrderBy 返回设置排序的 gorm 作用域，字段名会被转义。
+func OrderBy(s web.Sort) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(s) == 0 {
+			return db
+		}
+		var columns []clause.OrderByColumn
+		for _, o := range s {
+			columns = append(columns, clause.OrderByColumn{
+				Column: clause.Column{Name: o.Field},
+				Desc:   o.Desc,
+			})
+		}
+		return db.Clauses(clause.OrderBy{Columns: columns})
+	}
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package page_test
+
+import (
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/web"
+	"github.com/go-spring/starter-gorm/mysql/factory"
+	"github.com/go-spring/starter-gorm/page"
+	"gorm.io/gorm"
+)
+
+type User struct {
+	ID   int
+	Name string
+}
+
+func TestPaginate(t *testing.T) {
+	db, _, err := factory.MockDB()
+	assert.Nil(t, err)
+	db = db.Session(&gorm.Session{DryRun: true})
+
+	p := web.Pageable{Page: 2, Size: 10, Sort: web.Sort{{Field: "name", Desc: true}, {Field: "id"}}}
+	stmt := db.Scopes(page.Paginate(p)).Find(&[]User{}).Statement
+	assert.Equal(t, stmt.SQL.String(), "SELECT * FROM `users` ORDER BY `name` DESC,`id` LIMIT 10 OFFSET 20")
+
+	stmt = db.Scopes(page.Paginate(web.Pageable{Size: 20})).Find(&[]User{}).Statement
+	assert.Equal(t, stmt.SQL.String(), "SELECT * FROM `users` LIMIT 20")
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sharding 在多个 *gorm.DB 上实现分片，分片的名称为 *gorm.DB bean 的名称，
+// 分片规则参见 github.com/go-spring/spring-core/sharding 。引入该包之后可以注入
+// *sharding.DB 使用:
+//
+//	db, err := s.DB.For(ctx, "orders", order)
+//	err = s.DB.Find(ctx, "orders", &orders, func(db *gorm.DB) *gorm.DB {
+//		return db.Where("status = ?", "paid")
+//	})
+package sharding
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/sharding"
+	"gorm.io/gorm"
+)
+
+func init() {
+	gs.Provide(sharding.NewRouter, "${sharding}", "*?")
+	gs.Provide(New, "")
+}
+
+// DB 分片的 gorm 数据库。
+type DB struct {
+	router *sharding.Router
+	dbs    map[string]*gorm.DB
+}
+
+// New 创建 DB ，分片对应的 *gorm.DB 在 OnInit 时从容器中获取。
+func New(router *sharding.Router) *DB {
+	return &DB{router: router, dbs: make(map[string]*gorm.DB)}
+}
+
+// OnInit 从容器中获取所有分片对应的 *gorm.DB 。
+func (d *DB) OnInit(ctx gs.Context) error {
+	for _, name := range d.router.Tables() {
+		shards, err := d.router.Shards(name)
+		if err != nil {
+			return err
+		}
+		for _, shard := range shards {
+			if _, ok := d.dbs[shard]; ok {
+				continue
+			}
+			var db *gorm.DB
+			if err = ctx.Get(&db, shard); err != nil {
+				return err
+			}
+			d.dbs[shard] = db
+		}
+	}
+	return nil
+}
+
+// Shard 返回指定名称的分片。
+func (d *DB) Shard(ctx context.Context, shard string) (*gorm.DB, error) {
+	db, ok := d.dbs[shard]
+	if !ok {
+		return nil, errors.New("sharding: unknown shard " + shard)
+	}
+	return db.WithContext(ctx), nil
+}
+
+// For 返回 v 所在的分片，v 可以是实体也可以是分片键。
+func (d *DB) For(ctx context.Context, table string, v interface{}) (*gorm.DB, error) {
+	shard, err := d.router.Route(table, v)
+	if err != nil {
+		return nil, err
+	}
+	return d.Shard(ctx, shard)
+}
+
+// Find 在 table 的所有分片上并发执行查询，并将结果按照分片的顺序追加到 dest 中，
+// dest 必须是切片的指针。排序和分页只在单个分片内有效，需要时在合并之后处理。
+func (d *DB) Find(ctx context.Context, table string, dest interface{}, query func(db *gorm.DB) *gorm.DB) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return errors.New("sharding: dest should be a pointer to slice")
+	}
+	shards, err := d.router.Shards(table)
+	if err != nil {
+		return err
+	}
+	results, err := sharding.Gather(ctx, shards, func(ctx context.Context, shard string) (interface{}, error) {
+		db, err := d.Shard(ctx, shard)
+		if err != nil {
+			return nil, err
+		}
+		items := reflect.New(v.Elem().Type())
+		if err = query(db).Find(items.Interface()).Error; err != nil {
+			return nil, err
+		}
+		return items.Elem(), nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		v.Elem().Set(reflect.AppendSlice(v.Elem(), r.(reflect.Value)))
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+module github.com/go-spring/starter-graphql
+
+go 1.14
+
+require (
+	github.com/go-spring/spring-core v1.1.0-rc3
+	github.com/go-spring/spring-graphql v1.1.0-rc3
+)
+
+replace (
+	github.com/go-spring/spring-base => ../../spring/spring-base
+	github.com/go-spring/spring-core => ../../spring/spring-core
+	github.com/go-spring/spring-graphql => ../../spring/spring-graphql
+)
@@ -0,0 +1,55 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package StarterGraphQL
+
+import (
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/web"
+	"github.com/go-spring/spring-graphql"
+)
+
+func init() {
+	gs.Provide(SpringGraphQL.NewSchema, "*?")
+	gs.Provide(newHandler, "${graphql}", "", "*?", "${spring.profiles.active:=}")
+	gs.Object(new(endpoint)).Init(func(e *endpoint) {
+		config := e.Handler.Config()
+		gs.HandlePost(config.Path, web.FUNC(e.Handler.Handle))
+		gs.HandleGet(config.Path, web.FUNC(e.Handler.Handle))
+		if config.Playground {
+			gs.HandleGet(config.PlaygroundPath, web.FUNC(e.Handler.HandlePlayground))
+		}
+	})
+}
+
+// newHandler 创建 GraphQL 接口，dev 环境下开启调试页面，prod 环境下屏蔽内部错误。
+func newHandler(config SpringGraphQL.Config, schema *SpringGraphQL.Schema, loaders []SpringGraphQL.DataLoader, profiles []string) *SpringGraphQL.Handler {
+	for _, p := range profiles {
+		switch p {
+		case "dev":
+			config.Playground = true
+		case "prod":
+			config.Playground = false
+			config.MaskErrors = true
+		}
+	}
+	return SpringGraphQL.NewHandler(config, schema, loaders)
+}
+
+// endpoint 在应用的 Web 服务器上注册 GraphQL 接口。
+type endpoint struct {
+	Handler *SpringGraphQL.Handler `autowire:""`
+}
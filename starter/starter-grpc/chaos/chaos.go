@@ -0,0 +1,74 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package chaos 提供了为 gRPC 请求注入故障的拦截器，只应该在 chaos 配置环境下使用。
+package chaos
+
+import (
+	"context"
+
+	"github.com/go-spring/spring-core/chaos"
+	g "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// inject 注入故障，错误返回 Internal 状态，连接重置返回 Unavailable 状态。
+func inject(ctx context.Context, i *chaos.Injector, method string) error {
+	if !i.Match(method) {
+		return nil
+	}
+	switch i.Inject(ctx) {
+	case chaos.Error:
+		return status.Error(codes.Internal, chaos.ErrInjected.Error())
+	case chaos.Reset:
+		return status.Error(codes.Unavailable, chaos.ErrConnReset.Error())
+	}
+	return nil
+}
+
+// UnaryServerInterceptor 返回为服务端一元调用注入故障的拦截器。
+func UnaryServerInterceptor(config chaos.Config) g.UnaryServerInterceptor {
+	i := chaos.NewInjector(config)
+	return func(ctx context.Context, req interface{}, info *g.UnaryServerInfo, handler g.UnaryHandler) (interface{}, error) {
+		if err := inject(ctx, i, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor 返回为服务端流式调用注入故障的拦截器。
+func StreamServerInterceptor(config chaos.Config) g.StreamServerInterceptor {
+	i := chaos.NewInjector(config)
+	return func(srv interface{}, ss g.ServerStream, info *g.StreamServerInfo, handler g.StreamHandler) error {
+		if err := inject(ss.Context(), i, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// UnaryClientInterceptor 返回为客户端一元调用注入故障的拦截器。
+func UnaryClientInterceptor(config chaos.Config) g.UnaryClientInterceptor {
+	i := chaos.NewInjector(config)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *g.ClientConn, invoker g.UnaryInvoker, opts ...g.CallOption) error {
+		if err := inject(ctx, i, method); err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
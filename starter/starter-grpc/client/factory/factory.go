@@ -17,11 +17,89 @@
 package factory
 
 import (
+	"errors"
+
+	"github.com/go-spring/spring-core/chaos"
 	"github.com/go-spring/spring-core/grpc"
+	"github.com/go-spring/spring-core/resilience"
+	"github.com/go-spring/spring-core/tlsconfig"
+	StarterChaos "github.com/go-spring/starter-grpc/chaos"
+	"github.com/go-spring/starter-grpc/client/lb"
+	StarterResilience "github.com/go-spring/starter-grpc/resilience"
 	g "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/health" // 开启客户端健康检查
 )
 
-// NewClient 根据配置创建 grpc.ClientConnInterface 对象
-func NewClient(config grpc.EndpointConfig) (g.ClientConnInterface, error) {
-	return g.Dial(config.Address, g.WithInsecure())
+// NewClient 根据配置创建 grpc.ClientConnInterface 对象，开启 TLS 时 p 不能为 nil ，
+// 设置了调用策略时 r 不能为 nil 。
+func NewClient(config grpc.EndpointConfig, p *tlsconfig.Provider, r *resilience.Registry) (g.ClientConnInterface, error) {
+	target, opts, err := dialOptions(config, p, r)
+	if err != nil {
+		return nil, err
+	}
+	return g.Dial(target, opts...)
+}
+
+// NewChaosClient 创建为所有请求注入故障的 grpc.ClientConnInterface 对象，用于韧性测试。
+func NewChaosClient(config grpc.EndpointConfig, chaosConfig chaos.Config, p *tlsconfig.Provider, r *resilience.Registry) (g.ClientConnInterface, error) {
+	target, opts, err := dialOptions(config, p, r)
+	if err != nil {
+		return nil, err
+	}
+	interceptor := StarterChaos.UnaryClientInterceptor(chaosConfig)
+	return g.Dial(target, append(opts, g.WithChainUnaryInterceptor(interceptor))...)
+}
+
+// dialOptions 根据配置返回连接地址以及传输安全、负载均衡、并发限制、调用策略相关的选项。
+func dialOptions(config grpc.EndpointConfig, p *tlsconfig.Provider, r *resilience.Registry) (string, []g.DialOption, error) {
+
+	sc, err := lb.ServiceConfig(config.Balancer, config.HealthCheck, config.HealthService)
+	if err != nil {
+		return "", nil, err
+	}
+
+	target := config.Address
+	opts := []g.DialOption{g.WithDefaultServiceConfig(sc)}
+
+	if !config.TLS {
+		opts = append(opts, g.WithInsecure())
+	} else if p != nil {
+		opts = append(opts, g.WithTransportCredentials(credentials.NewTLS(p.ClientConfig())))
+	} else {
+		return "", nil, errors.New("grpc: tls is enabled but no tls.Provider found")
+	}
+
+	if len(config.Endpoints) > 0 {
+		var endpoints []lb.Endpoint
+		for _, e := range config.Endpoints {
+			endpoints = append(endpoints, lb.Endpoint{Address: e.Address, Weight: e.Weight})
+		}
+		r := lb.StaticResolver(endpoints)
+		target = r.Scheme() + ":///"
+		opts = append(opts, g.WithResolvers(r))
+	}
+
+	if config.HashHeader != "" {
+		opts = append(opts,
+			g.WithChainUnaryInterceptor(lb.UnaryHashKeyInterceptor(config.HashHeader)),
+			g.WithChainStreamInterceptor(lb.StreamHashKeyInterceptor(config.HashHeader)))
+	}
+
+	if config.Bulkhead.MaxConcurrent > 0 {
+		b := resilience.NewBulkhead("grpc.client:"+target, config.Bulkhead)
+		opts = append(opts, g.WithChainUnaryInterceptor(StarterResilience.UnaryClientInterceptor(b)))
+	}
+
+	if config.Policy != "" {
+		if r == nil {
+			return "", nil, errors.New("grpc: policy is set but no resilience.Registry found")
+		}
+		e, err := r.Get(config.Policy)
+		if err != nil {
+			return "", nil, err
+		}
+		opts = append(opts, g.WithChainUnaryInterceptor(StarterResilience.PolicyUnaryClientInterceptor(e)))
+	}
+	return target, opts, nil
 }
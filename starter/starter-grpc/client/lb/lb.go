@@ -0,0 +1,282 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package lb 提供 gRPC 客户端的负载均衡策略，包括加权轮询、最少请求和基于请求头的
+// 一致性哈希。这些策略只在就绪的连接之间选择，开启健康检查之后不健康的端点会被
+// 自动剔除，恢复之后重新加入。
+package lb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/balancer/weightedroundrobin"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+const (
+	PickFirst      = "pick_first"
+	RoundRobin     = "round_robin"
+	WeightedRobin  = weightedroundrobin.Name // 按照端点的权重轮询
+	LeastRequest   = "least_request"         // 选择正在处理的请求最少的端点
+	ConsistentHash = "consistent_hash"       // 按照哈希键选择端点，参见 WithHashKey
+)
+
+// virtualNodes 一致性哈希中每个端点的虚拟节点数量。
+const virtualNodes = 100
+
+func init() {
+	config := base.Config{HealthCheck: true}
+	balancer.Register(base.NewBalancerBuilder(WeightedRobin, &weightedPickerBuilder{}, config))
+	balancer.Register(base.NewBalancerBuilder(LeastRequest, &leastRequestPickerBuilder{}, config))
+	balancer.Register(base.NewBalancerBuilder(ConsistentHash, &hashPickerBuilder{}, config))
+}
+
+// Endpoint 静态端点。
+type Endpoint struct {
+	Address string
+	Weight  int
+}
+
+// StaticResolver 返回解析静态端点列表的 resolver ，需要配合 grpc.WithResolvers 和
+// static:/// 形式的地址使用。端点的权重只对 WeightedRobin 策略有效。
+func StaticResolver(endpoints []Endpoint) resolver.Builder {
+	var addrs []resolver.Address
+	for _, e := range endpoints {
+		addr := resolver.Address{Addr: e.Address}
+		if e.Weight > 0 {
+			addr = weightedroundrobin.SetAddrInfo(addr, weightedroundrobin.AddrInfo{Weight: uint32(e.Weight)})
+		}
+		addrs = append(addrs, addr)
+	}
+	r := manual.NewBuilderWithScheme("static")
+	r.InitialState(resolver.State{Addresses: addrs})
+	return r
+}
+
+type hashKeyType struct{}
+
+// WithHashKey 设置一致性哈希策略使用的哈希键。
+func WithHashKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, hashKeyType{}, key)
+}
+
+func hashKey(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(hashKeyType{}).(string)
+	return key, ok && key != ""
+}
+
+// withHeaderHashKey 没有设置哈希键时使用请求头 header 的值作为哈希键。
+func withHeaderHashKey(ctx context.Context, header string) context.Context {
+	if _, ok := hashKey(ctx); ok {
+		return ctx
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if v := md.Get(header); len(v) > 0 {
+		return WithHashKey(ctx, v[0])
+	}
+	return ctx
+}
+
+// UnaryHashKeyInterceptor 使用请求头 header 的值作为一致性哈希的哈希键。
+func UnaryHashKeyInterceptor(header string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withHeaderHashKey(ctx, header), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamHashKeyInterceptor 使用请求头 header 的值作为一致性哈希的哈希键。
+func StreamHashKeyInterceptor(header string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withHeaderHashKey(ctx, header), desc, cc, method, opts...)
+	}
+}
+
+// ServiceConfig 返回选择负载均衡策略的服务配置，healthService 不为空时开启健康检查。
+func ServiceConfig(policy string, healthCheck bool, healthService string) (string, error) {
+	if balancer.Get(policy) == nil {
+		return "", fmt.Errorf("unknown balancer %q", policy)
+	}
+	if !healthCheck {
+		return fmt.Sprintf(`{"loadBalancingConfig":[{%q:{}}]}`, policy), nil
+	}
+	if policy == PickFirst {
+		return "", errors.New("health check requires a balancer other than pick_first")
+	}
+	return fmt.Sprintf(`{"loadBalancingConfig":[{%q:{}}],"healthCheckConfig":{"serviceName":%q}}`, policy, healthService), nil
+}
+
+// sortedSubConns 按照地址对就绪的连接排序，使得选择结果稳定。
+func sortedSubConns(info base.PickerBuildInfo) ([]balancer.SubConn, []resolver.Address) {
+	var (
+		scs   []balancer.SubConn
+		addrs []resolver.Address
+	)
+	for sc, i := range info.ReadySCs {
+		scs = append(scs, sc)
+		addrs = append(addrs, i.Address)
+	}
+	sort.Sort(&subConnSorter{scs, addrs})
+	return scs, addrs
+}
+
+type subConnSorter struct {
+	scs   []balancer.SubConn
+	addrs []resolver.Address
+}
+
+func (s *subConnSorter) Len() int           { return len(s.scs) }
+func (s *subConnSorter) Less(i, j int) bool { return s.addrs[i].Addr < s.addrs[j].Addr }
+func (s *subConnSorter) Swap(i, j int) {
+	s.scs[i], s.scs[j] = s.scs[j], s.scs[i]
+	s.addrs[i], s.addrs[j] = s.addrs[j], s.addrs[i]
+}
+
+type weightedPickerBuilder struct{}
+
+func (*weightedPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	scs, addrs := sortedSubConns(info)
+	p := &weightedPicker{scs: scs}
+	for _, addr := range addrs {
+		w := int(weightedroundrobin.GetAddrInfo(addr).Weight)
+		if w <= 0 {
+			w = 1
+		}
+		p.weights = append(p.weights, w)
+		p.total += w
+	}
+	p.current = make([]int, len(scs))
+	return p
+}
+
+// weightedPicker 平滑加权轮询，权重高的端点不会连续被选中。
+type weightedPicker struct {
+	mutex   sync.Mutex
+	scs     []balancer.SubConn
+	weights []int
+	current []int
+	total   int
+}
+
+func (p *weightedPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	best := 0
+	for i, w := range p.weights {
+		p.current[i] += w
+		if p.current[i] > p.current[best] {
+			best = i
+		}
+	}
+	p.current[best] -= p.total
+	return balancer.PickResult{SubConn: p.scs[best]}, nil
+}
+
+type leastRequestPickerBuilder struct{}
+
+func (*leastRequestPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	scs, _ := sortedSubConns(info)
+	return &leastRequestPicker{scs: scs, inflight: make([]int64, len(scs))}
+}
+
+// leastRequestPicker 选择正在处理的请求最少的端点，数量相同时轮流选择。连接发生
+// 变化时重新计数。
+type leastRequestPicker struct {
+	scs      []balancer.SubConn
+	inflight []int64
+	next     uint32
+}
+
+func (p *leastRequestPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	n := len(p.scs)
+	start := int(atomic.AddUint32(&p.next, 1) % uint32(n))
+	best := start
+	for i := 1; i < n; i++ {
+		j := (start + i) % n
+		if atomic.LoadInt64(&p.inflight[j]) < atomic.LoadInt64(&p.inflight[best]) {
+			best = j
+		}
+	}
+	atomic.AddInt64(&p.inflight[best], 1)
+	return balancer.PickResult{
+		SubConn: p.scs[best],
+		Done: func(balancer.DoneInfo) {
+			atomic.AddInt64(&p.inflight[best], -1)
+		},
+	}, nil
+}
+
+type hashPickerBuilder struct{}
+
+func (*hashPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	scs, addrs := sortedSubConns(info)
+	p := &hashPicker{scs: scs}
+	for i, addr := range addrs {
+		for v := 0; v < virtualNodes; v++ {
+			h := crc32.ChecksumIEEE([]byte(addr.Addr + "#" + strconv.Itoa(v)))
+			p.ring = append(p.ring, hashNode{hash: h, index: i})
+		}
+	}
+	sort.Slice(p.ring, func(i, j int) bool { return p.ring[i].hash < p.ring[j].hash })
+	return p
+}
+
+type hashNode struct {
+	hash  uint32
+	index int
+}
+
+// hashPicker 一致性哈希，端点被剔除时只有原本分配给该端点的哈希键会改变端点。
+// 没有哈希键的请求轮流选择端点。
+type hashPicker struct {
+	scs  []balancer.SubConn
+	ring []hashNode
+	next uint32
+}
+
+func (p *hashPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	key, ok := hashKey(info.Ctx)
+	if !ok {
+		i := atomic.AddUint32(&p.next, 1) % uint32(len(p.scs))
+		return balancer.PickResult{SubConn: p.scs[i]}, nil
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= h })
+	if i == len(p.ring) {
+		i = 0
+	}
+	return balancer.PickResult{SubConn: p.scs[p.ring[i].index]}, nil
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lb_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/starter-grpc/client/lb"
+	pb "github.com/go-spring/starter-grpc/example/helloworld"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+type greeter struct {
+	pb.UnimplementedGreeterServer
+	name string
+}
+
+func (s *greeter) SayHello(ctx context.Context, in *pb.HelloRequest) (*pb.HelloReply, error) {
+	return &pb.HelloReply{Message: s.name}, nil
+}
+
+type server struct {
+	addr   string
+	health *health.Server
+}
+
+func startServer(t *testing.T, name string) *server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	s := grpc.NewServer()
+	h := health.NewServer()
+	healthpb.RegisterHealthServer(s, h)
+	pb.RegisterGreeterServer(s, &greeter{name: name})
+	go func() { _ = s.Serve(l) }()
+	t.Cleanup(s.Stop)
+	return &server{addr: l.Addr().String(), health: h}
+}
+
+func dial(t *testing.T, policy string, endpoints []lb.Endpoint) pb.GreeterClient {
+	sc, err := lb.ServiceConfig(policy, true, "")
+	assert.Nil(t, err)
+	r := lb.StaticResolver(endpoints)
+	conn, err := grpc.Dial(r.Scheme()+":///", grpc.WithInsecure(), grpc.WithResolvers(r),
+		grpc.WithDefaultServiceConfig(sc),
+		grpc.WithChainUnaryInterceptor(lb.UnaryHashKeyInterceptor("x-user-id")))
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return pb.NewGreeterClient(conn)
+}
+
+// call 返回处理请求的服务器名称，所有端点都连接成功之前先等待。
+func call(t *testing.T, c pb.GreeterClient, userID string) string {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-user-id", userID)
+	reply, err := c.SayHello(ctx, &pb.HelloRequest{}, grpc.WaitForReady(true))
+	assert.Nil(t, err)
+	return reply.Message
+}
+
+// distribution 统计 n 次请求在各个服务器上的分布。
+func distribution(t *testing.T, c pb.GreeterClient, n int) map[string]int {
+	// 等待所有连接就绪
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		m := make(map[string]int)
+		for i := 0; i < n; i++ {
+			m[call(t, c, "")]++
+		}
+		if len(m) > 1 {
+			return m
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("endpoints are not ready")
+	return nil
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	a, b := startServer(t, "a"), startServer(t, "b")
+	c := dial(t, lb.WeightedRobin, []lb.Endpoint{{Address: a.addr, Weight: 3}, {Address: b.addr, Weight: 1}})
+	distribution(t, c, 8)
+	assert.Equal(t, distribution(t, c, 8), map[string]int{"a": 6, "b": 2})
+}
+
+func TestLeastRequest(t *testing.T) {
+	a, b := startServer(t, "a"), startServer(t, "b")
+	c := dial(t, lb.LeastRequest, []lb.Endpoint{{Address: a.addr}, {Address: b.addr}})
+	distribution(t, c, 8)
+	assert.Equal(t, distribution(t, c, 8), map[string]int{"a": 4, "b": 4})
+}
+
+func TestConsistentHash(t *testing.T) {
+	a, b := startServer(t, "a"), startServer(t, "b")
+	c := dial(t, lb.ConsistentHash, []lb.Endpoint{{Address: a.addr}, {Address: b.addr}})
+	distribution(t, c, 8)
+
+	// 相同的哈希键总是选择相同的端点
+	owner := call(t, c, "user-1")
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, call(t, c, "user-1"), owner)
+	}
+
+	// 健康检查失败的端点被剔除，恢复之后重新加入
+	servers := map[string]*server{"a": a, "b": b}
+	servers[owner].health.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	other := map[string]string{"a": "b", "b": "a"}[owner]
+	assert.True(t, waitFor(func() bool { return call(t, c, "user-1") == other }))
+	servers[owner].health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	assert.True(t, waitFor(func() bool { return call(t, c, "user-1") == owner }))
+}
+
+func waitFor(fn func() bool) bool {
+	for i := 0; i < 100; i++ {
+		if fn() {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false
+}
+
+func TestServiceConfig(t *testing.T) {
+	sc, err := lb.ServiceConfig(lb.RoundRobin, false, "")
+	assert.Nil(t, err)
+	assert.Equal(t, sc, `{"loadBalancingConfig":[{"round_robin":{}}]}`)
+	sc, err = lb.ServiceConfig(lb.ConsistentHash, true, "greeter")
+	assert.Nil(t, err)
+	assert.Equal(t, sc, `{"loadBalancingConfig":[{"consistent_hash":{}}],"healthCheckConfig":{"serviceName":"greeter"}}`)
+	_, err = lb.ServiceConfig("random", false, "")
+	assert.Error(t, err, `unknown balancer "random"`)
+	_, err = lb.ServiceConfig(lb.PickFirst, true, "")
+	assert.Error(t, err, "health check requires a balancer other than pick_first")
+	assert.NotNil(t, balancer.Get(lb.WeightedRobin))
+}
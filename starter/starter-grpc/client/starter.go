@@ -17,16 +17,23 @@
 package StarterGrpcClient
 
 import (
+	"github.com/go-spring/spring-core/chaos"
 	"github.com/go-spring/spring-core/grpc"
 	"github.com/go-spring/spring-core/gs"
 	"github.com/go-spring/spring-core/gs/arg"
+	"github.com/go-spring/spring-core/gs/cond"
 	"github.com/go-spring/starter-grpc/client/factory"
 )
 
 func init() {
 	gs.OnProperty("grpc.endpoint", func(endpoints map[string]grpc.EndpointConfig) {
 		for endpoint, config := range endpoints {
-			gs.Provide(factory.NewClient, arg.Value(config)).Name(endpoint)
+			gs.Provide(factory.NewClient, arg.Value(config), "?", "?").
+				Name(endpoint).
+				On(cond.Not(cond.OnProfile(chaos.Profile)))
+			gs.Provide(factory.NewChaosClient, arg.Value(config), "${chaos}", "?", "?").
+				Name(endpoint).
+				On(cond.OnProfile(chaos.Profile))
 		}
 	})
 }
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package resilience 提供了限制 gRPC 请求并发数量以及按照调用策略执行 gRPC 请求的拦截器。
+package resilience
+
+import (
+	"context"
+
+	"github.com/go-spring/spring-core/resilience"
+	g "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// acquire 获取执行许可，舱壁已满时返回 ResourceExhausted 状态。
+func acquire(ctx context.Context, b *resilience.Bulkhead) (func(), error) {
+	release, err := b.Acquire(ctx)
+	switch {
+	case err == nil:
+		return release, nil
+	case err == resilience.ErrBulkheadFull:
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	case err == context.DeadlineExceeded:
+		return nil, status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return nil, status.Error(codes.Canceled, err.Error())
+	}
+}
+
+// UnaryServerInterceptor 返回限制服务端一元调用并发数量的拦截器。
+func UnaryServerInterceptor(b *resilience.Bulkhead) g.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *g.UnaryServerInfo, handler g.UnaryHandler) (interface{}, error) {
+		release, err := acquire(ctx, b)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor 返回限制服务端流式调用并发数量的拦截器，许可在流结束时释放。
+func StreamServerInterceptor(b *resilience.Bulkhead) g.StreamServerInterceptor {
+	return func(srv interface{}, ss g.ServerStream, info *g.StreamServerInfo, handler g.StreamHandler) error {
+		release, err := acquire(ss.Context(), b)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return handler(srv, ss)
+	}
+}
+
+// UnaryClientInterceptor 返回限制客户端一元调用并发数量的拦截器。
+func UnaryClientInterceptor(b *resilience.Bulkhead) g.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *g.ClientConn, invoker g.UnaryInvoker, opts ...g.CallOption) error {
+		release, err := acquire(ctx, b)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resilience_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/resilience"
+	StarterResilience "github.com/go-spring/starter-grpc/resilience"
+	g "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+
+	b := resilience.NewBulkhead("grpc", resilience.BulkheadConfig{MaxConcurrent: 1})
+	interceptor := StarterResilience.UnaryServerInterceptor(b)
+	info := &g.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+
+	var inner error
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		_, inner = interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return "ok", nil
+	}
+
+	resp, err := interceptor(context.Background(), nil, info, handler)
+	assert.Nil(t, err)
+	assert.Equal(t, resp, "ok")
+	assert.Equal(t, status.Code(inner), codes.ResourceExhausted)
+	assert.Equal(t, b.Stats(), resilience.BulkheadStats{Name: "grpc", Accepted: 1, Rejected: 1})
+}
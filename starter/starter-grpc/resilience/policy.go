@@ -0,0 +1,57 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resilience
+
+import (
+	"context"
+
+	"github.com/go-spring/spring-core/resilience"
+	g "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// classify 根据 gRPC 状态码标记错误，服务端暂时不可用时可以重试，调用方自身导致
+// 的错误不计入熔断器的失败次数，其他错误不重试但是计入失败次数。
+func classify(err error) error {
+	switch status.Code(err) {
+	case codes.OK:
+		return nil
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Aborted:
+		return err
+	case codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.FailedPrecondition,
+		codes.OutOfRange, codes.Canceled:
+		return resilience.Ignore(err)
+	default:
+		return resilience.Permanent(err)
+	}
+}
+
+// PolicyUnaryClientInterceptor 返回按照调用策略执行客户端一元调用的拦截器，熔断器
+// 打开时返回 Unavailable 状态。
+func PolicyUnaryClientInterceptor(e *resilience.Executor) g.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *g.ClientConn, invoker g.UnaryInvoker, opts ...g.CallOption) error {
+		err := e.Execute(ctx, func(ctx context.Context) error {
+			return classify(invoker(ctx, method, req, reply, cc, opts...))
+		})
+		if err == resilience.ErrCircuitOpen {
+			return status.Error(codes.Unavailable, err.Error())
+		}
+		return err
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resilience_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/resilience"
+	StarterResilience "github.com/go-spring/starter-grpc/resilience"
+	g "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPolicyUnaryClientInterceptor(t *testing.T) {
+
+	e := resilience.NewExecutor("payments", resilience.Policy{
+		Retry: resilience.RetryPolicy{MaxAttempts: 3},
+		Circuit: resilience.CircuitPolicy{
+			FailureRate:  0.5,
+			MinRequests:  5,
+			Window:       time.Minute,
+			OpenDuration: time.Minute,
+		},
+	})
+	interceptor := StarterResilience.PolicyUnaryClientInterceptor(e)
+
+	call := func(code codes.Code) (int, error) {
+		calls := 0
+		err := interceptor(context.Background(), "/helloworld.Greeter/SayHello", nil, nil, nil,
+			func(ctx context.Context, method string, req, reply interface{}, cc *g.ClientConn, opts ...g.CallOption) error {
+				calls++
+				if code == codes.OK {
+					return nil
+				}
+				return status.Error(code, code.String())
+			})
+		return calls, err
+	}
+
+	calls, err := call(codes.InvalidArgument)
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+	assert.Equal(t, calls, 1)
+
+	calls, err = call(codes.Internal)
+	assert.Equal(t, status.Code(err), codes.Internal)
+	assert.Equal(t, calls, 1)
+
+	calls, err = call(codes.Unavailable)
+	assert.Equal(t, status.Code(err), codes.Unavailable)
+	assert.Equal(t, calls, 3)
+	assert.Equal(t, e.State(), "open")
+
+	calls, err = call(codes.OK)
+	assert.Equal(t, status.Code(err), codes.Unavailable)
+	assert.Equal(t, calls, 0)
+}
@@ -18,6 +18,7 @@ package factory
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"reflect"
@@ -25,9 +26,18 @@ import (
 
 	"github.com/go-spring/spring-base/log"
 	"github.com/go-spring/spring-base/util"
+	"github.com/go-spring/spring-core/chaos"
 	"github.com/go-spring/spring-core/grpc"
 	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/resilience"
+	"github.com/go-spring/spring-core/tlsconfig"
+	StarterChaos "github.com/go-spring/starter-grpc/chaos"
+	StarterResilience "github.com/go-spring/starter-grpc/resilience"
 	g "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
 // Starter gRPC 服务器启动器
@@ -35,14 +45,40 @@ type Starter struct {
 	config  grpc.ServerConfig
 	server  *g.Server
 	Servers *gs.GrpcServers `autowire:""`
+	Health  *health.Server  `autowire:"?"`
 }
 
-// NewStarter Starter 的构造函数
-func NewStarter(config grpc.ServerConfig) *Starter {
+// NewStarter Starter 的构造函数，开启 TLS 时 p 不能为 nil 。
+func NewStarter(config grpc.ServerConfig, p *tlsconfig.Provider) (*Starter, error) {
+	return newStarter(config, p)
+}
+
+// NewChaosStarter 创建为所有请求注入故障的 Starter ，用于韧性测试。
+func NewChaosStarter(config grpc.ServerConfig, chaosConfig chaos.Config, p *tlsconfig.Provider) (*Starter, error) {
+	return newStarter(config, p,
+		g.ChainUnaryInterceptor(StarterChaos.UnaryServerInterceptor(chaosConfig)),
+		g.ChainStreamInterceptor(StarterChaos.StreamServerInterceptor(chaosConfig)),
+	)
+}
+
+func newStarter(config grpc.ServerConfig, p *tlsconfig.Provider, opts ...g.ServerOption) (*Starter, error) {
+	if config.TLS {
+		if p == nil {
+			return nil, errors.New("grpc: tls is enabled but no tls.Provider found")
+		}
+		opts = append(opts, g.Creds(credentials.NewTLS(p.ServerConfig())))
+	}
+	if config.Bulkhead.MaxConcurrent > 0 {
+		b := resilience.NewBulkhead("grpc.server", config.Bulkhead)
+		opts = append([]g.ServerOption{
+			g.ChainUnaryInterceptor(StarterResilience.UnaryServerInterceptor(b)),
+			g.ChainStreamInterceptor(StarterResilience.StreamServerInterceptor(b)),
+		}, opts...)
+	}
 	return &Starter{
 		config: config,
-		server: g.NewServer(),
-	}
+		server: g.NewServer(opts...),
+	}, nil
 }
 
 func (starter *Starter) OnAppStart(ctx gs.Context) {
@@ -68,6 +104,18 @@ func (starter *Starter) OnAppStart(ctx gs.Context) {
 		}
 	}
 
+	// 健康检查服务报告整个服务器以及每个服务的状态，应用可以注入 *health.Server 修改状态。
+	if starter.Health != nil {
+		for service := range srvMap {
+			starter.Health.SetServingStatus(service, grpc_health_v1.HealthCheckResponse_SERVING)
+		}
+		grpc_health_v1.RegisterHealthServer(starter.server, starter.Health)
+	}
+
+	if starter.config.Reflection {
+		reflection.Register(starter.server)
+	}
+
 	addr := fmt.Sprintf(":%d", starter.config.Port)
 	listener, err := net.Listen("tcp", addr)
 	util.Panic(err).When(err != nil)
@@ -80,5 +128,8 @@ func (starter *Starter) OnAppStart(ctx gs.Context) {
 }
 
 func (starter *Starter) OnAppStop(ctx context.Context) {
+	if starter.Health != nil {
+		starter.Health.Shutdown()
+	}
 	starter.server.GracefulStop()
 }
@@ -17,10 +17,20 @@
 package StarterGrpcServer
 
 import (
+	"github.com/go-spring/spring-core/chaos"
 	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
 	"github.com/go-spring/starter-grpc/server/factory"
+	"google.golang.org/grpc/health"
 )
 
 func init() {
-	gs.Provide(factory.NewStarter, "${grpc.server}").Export((*gs.AppEvent)(nil))
+	gs.Provide(health.NewServer).
+		On(cond.OnProperty("grpc.server.health.enabled", cond.HavingValue("true"), cond.MatchIfMissing()))
+	gs.Provide(factory.NewStarter, "${grpc.server}", "?").
+		On(cond.Not(cond.OnProfile(chaos.Profile))).
+		Export((*gs.AppEvent)(nil))
+	gs.Provide(factory.NewChaosStarter, "${grpc.server}", "${chaos}", "?").
+		On(cond.OnProfile(chaos.Profile)).
+		Export((*gs.AppEvent)(nil))
 }
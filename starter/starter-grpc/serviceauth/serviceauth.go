@@ -0,0 +1,114 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package serviceauth 提供了在 gRPC 请求中传递和校验服务令牌的客户端凭证和服务端
+// 拦截器。
+package serviceauth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-spring/spring-base/knife"
+	"github.com/go-spring/spring-core/serviceauth"
+	g "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// metadataKey 携带服务令牌的元数据，gRPC 元数据的键只能使用小写字母。
+var metadataKey = strings.ToLower(serviceauth.HeaderServiceToken)
+
+type perRPCCredentials struct {
+	signer   *serviceauth.Signer
+	audience string
+}
+
+// Credentials 返回为调用 audience 服务的每个请求添加令牌的凭证，配合
+// grpc.WithPerRPCCredentials 使用。
+func Credentials(signer *serviceauth.Signer, audience string) credentials.PerRPCCredentials {
+	return &perRPCCredentials{signer: signer, audience: audience}
+}
+
+func (c *perRPCCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := c.signer.Sign(c.audience)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{metadataKey: token}, nil
+}
+
+// RequireTransportSecurity 服务令牌有效期很短，允许在内网中明文传输。
+func (c *perRPCCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// verify 校验令牌并返回保存了调用方服务名称的上下文，令牌缺失或者无效时返回
+// Unauthenticated 状态，调用方不被允许时返回 PermissionDenied 状态。
+func verify(ctx context.Context, v *serviceauth.Verifier) (context.Context, error) {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ss := md.Get(metadataKey); len(ss) > 0 {
+			token = ss[0]
+		}
+	}
+	c, err := v.Verify(token)
+	if err == serviceauth.ErrDenied {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	ctx, _ = knife.New(ctx)
+	if err = serviceauth.Set(ctx, c.Issuer); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return ctx, nil
+}
+
+// UnaryServerInterceptor 返回校验服务端一元调用的服务令牌的拦截器。
+func UnaryServerInterceptor(v *serviceauth.Verifier) g.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *g.UnaryServerInfo, handler g.UnaryHandler) (interface{}, error) {
+		ctx, err := verify(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// serverStream 替换了上下文的 grpc.ServerStream 。
+type serverStream struct {
+	g.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamServerInterceptor 返回校验服务端流式调用的服务令牌的拦截器。
+func StreamServerInterceptor(v *serviceauth.Verifier) g.StreamServerInterceptor {
+	return func(srv interface{}, ss g.ServerStream, info *g.StreamServerInfo, handler g.StreamHandler) error {
+		ctx, err := verify(ss.Context(), v)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
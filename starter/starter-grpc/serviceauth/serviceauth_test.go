@@ -0,0 +1,82 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package serviceauth_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/serviceauth"
+	pb "github.com/go-spring/starter-grpc/example/helloworld"
+	StarterServiceAuth "github.com/go-spring/starter-grpc/serviceauth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type greeter struct {
+	pb.UnimplementedGreeterServer
+}
+
+func (s *greeter) SayHello(ctx context.Context, in *pb.HelloRequest) (*pb.HelloReply, error) {
+	caller, _ := serviceauth.Get(ctx)
+	return &pb.HelloReply{Message: "hello " + caller}, nil
+}
+
+func TestServiceAuth(t *testing.T) {
+
+	keys := map[string]string{"k1": "secret1"}
+	v, err := serviceauth.NewVerifier(serviceauth.Config{Service: "greeter", Keys: keys, Deny: []string{"payment"}})
+	assert.Nil(t, err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	s := grpc.NewServer(grpc.UnaryInterceptor(StarterServiceAuth.UnaryServerInterceptor(v)))
+	pb.RegisterGreeterServer(s, &greeter{})
+	go func() { _ = s.Serve(l) }()
+	defer s.Stop()
+
+	call := func(service string) (string, error) {
+		opts := []grpc.DialOption{grpc.WithInsecure()}
+		if service != "" {
+			signer, err := serviceauth.NewSigner(serviceauth.Config{Service: service, KeyID: "k1", Keys: keys, TTL: time.Minute})
+			assert.Nil(t, err)
+			opts = append(opts, grpc.WithPerRPCCredentials(StarterServiceAuth.Credentials(signer, "greeter")))
+		}
+		conn, err := grpc.Dial(l.Addr().String(), opts...)
+		assert.Nil(t, err)
+		defer conn.Close()
+		reply, err := pb.NewGreeterClient(conn).SayHello(context.Background(), &pb.HelloRequest{})
+		if err != nil {
+			return "", err
+		}
+		return reply.Message, nil
+	}
+
+	msg, err := call("order")
+	assert.Nil(t, err)
+	assert.Equal(t, msg, "hello order")
+
+	_, err = call("payment")
+	assert.Equal(t, status.Code(err), codes.PermissionDenied)
+
+	_, err = call("")
+	assert.Equal(t, status.Code(err), codes.Unauthenticated)
+}
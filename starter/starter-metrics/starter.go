@@ -0,0 +1,41 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package StarterMetrics
+
+import (
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
+	"github.com/go-spring/spring-core/metrics"
+	"github.com/go-spring/spring-core/web"
+)
+
+func init() {
+	gs.Provide(metrics.New, "${metrics}", "*?")
+	gs.Provide(metrics.NewFilter).
+		On(cond.OnProperty("metrics.enabled", cond.HavingValue("true"), cond.MatchIfMissing())).
+		Export((*web.Filter)(nil))
+	gs.Object(new(endpoint)).
+		On(cond.OnProperty("metrics.enabled", cond.HavingValue("true"), cond.MatchIfMissing())).
+		Init(func(e *endpoint) {
+			gs.HandleGet(e.Registry.Config().Path, e.Registry.Handler())
+		})
+}
+
+// endpoint 在应用的 Web 服务器上注册输出指标的地址。
+type endpoint struct {
+	Registry *metrics.Registry `autowire:""`
+}
@@ -0,0 +1,35 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package StarterPubSub
+
+import (
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/pubsub"
+)
+
+func init() {
+	gs.Provide(pubsub.NewBroker, "${pubsub}", "*?", "?").
+		Destroy(func(b *pubsub.Broker) { b.Close() })
+	gs.Object(new(endpoint)).Init(func(e *endpoint) {
+		gs.HandleGet(e.Broker.Path(), e.Broker.Handler())
+	})
+}
+
+// endpoint 在应用的 Web 服务器上注册 WebSocket 的地址。
+type endpoint struct {
+	Broker *pubsub.Broker `autowire:""`
+}
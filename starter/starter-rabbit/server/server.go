@@ -18,17 +18,24 @@
 package StarterRabbitServer
 
 import (
+	"context"
+
 	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
+	"github.com/go-spring/spring-core/waitfor"
 	"github.com/streadway/amqp"
 )
 
 func init() {
-	gs.Provide(CreateServer).Destroy(DestroyServer)
+	gs.Provide(CreateServer).
+		On(cond.OnProperty("amqp.server.url")).
+		Destroy(DestroyServer)
 }
 
 type AMQPServerConfig struct {
-	URL         string   `value:"${amqp.server.url}"`
-	QueueTopics []string `value:"${amqp.queue.topics}"`
+	URL         string         `value:"${amqp.server.url}"`
+	QueueTopics []string       `value:"${amqp.queue.topics}"`
+	WaitFor     waitfor.Config `value:"${amqp.server.wait-for}"`
 }
 
 type AMQPServer struct {
@@ -39,7 +46,11 @@ type AMQPServer struct {
 // CreateServer 创建 AMQPServer 对象，采用预先声明的方式避免运行时锁消耗
 func CreateServer(config AMQPServerConfig) (*AMQPServer, error) {
 
-	conn, err := amqp.Dial(config.URL)
+	var conn *amqp.Connection
+	err := waitfor.Wait("amqp", config.WaitFor, func(ctx context.Context) (err error) {
+		conn, err = amqp.Dial(config.URL)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -26,5 +26,6 @@ import (
 func init() {
 	gs.Provide(SpringRedigo.NewClient, "${redis}").
 		Name("RedisClient").
+		Describe("redis client based on redigo, configured by redis.*").
 		On(cond.OnMissingBean(gs.BeanID((*redis.Client)(nil), "RedisClient")))
 }
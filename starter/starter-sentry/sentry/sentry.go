@@ -0,0 +1,226 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sentry 实现了将错误发送到 Sentry 的 report.Reporter ，使用 Sentry 的
+// store 接口，不依赖 Sentry 的 SDK 。
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/report"
+)
+
+var logger = log.GetLogger("GS_SENTRY")
+
+// Config Sentry 的配置。
+type Config struct {
+	DSN         string        `value:"${dsn}"`
+	Environment string        `value:"${environment:=${spring.profiles.active:=}}"`
+	Release     string        `value:"${release:=}"`
+	ServerName  string        `value:"${server-name:=}"`
+	SampleRate  float64       `value:"${sample-rate:=1}"`
+	QueueSize   int           `value:"${queue-size:=100}"`
+	Timeout     time.Duration `value:"${timeout:=5s}"`
+}
+
+// Reporter 在后台将错误发送到 Sentry ，队列满时丢弃新的错误，不会阻塞发生错误的
+// goroutine 。
+type Reporter struct {
+	config Config
+	store  string
+	auth   string
+	client *http.Client
+	events chan *report.Event
+	done   chan struct{}
+}
+
+// NewReporter 创建 Reporter ，DSN 的格式为 https://<key>@<host>/<project> 。
+func NewReporter(config Config) (*Reporter, error) {
+
+	u, err := url.Parse(config.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, errors.New("sentry: dsn has no public key")
+	}
+	i := strings.LastIndex(u.Path, "/")
+	if i < 0 || u.Path[i+1:] == "" {
+		return nil, errors.New("sentry: dsn has no project id")
+	}
+
+	auth := fmt.Sprintf("Sentry sentry_version=7, sentry_client=%s, sentry_key=%s", gs.Version, u.User.Username())
+	if secret, ok := u.User.Password(); ok {
+		auth += ", sentry_secret=" + secret
+	}
+
+	if config.ServerName == "" {
+		config.ServerName, _ = os.Hostname()
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 100
+	}
+
+	return &Reporter{
+		config: config,
+		store:  fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, u.Path[:i], u.Path[i+1:]),
+		auth:   auth,
+		client: &http.Client{Timeout: config.Timeout},
+		events: make(chan *report.Event, config.QueueSize),
+	}, nil
+}
+
+// Report 将错误放入发送队列。
+func (r *Reporter) Report(ctx context.Context, e *report.Event) {
+	if r.config.SampleRate < 1 && rand.Float64() >= r.config.SampleRate {
+		return
+	}
+	select {
+	case r.events <- e:
+	default:
+		logger.WithContext(ctx).Warnf("sentry queue is full, event %s dropped", e.ID)
+	}
+}
+
+// OnAppStart 注册 Reporter 并开始发送错误。
+func (r *Reporter) OnAppStart(ctx gs.Context) {
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		for e := range r.events {
+			if err := r.send(e); err != nil {
+				logger.Error(log.ERROR, err)
+			}
+		}
+	}()
+	report.Register(r)
+}
+
+// OnAppStop 注销 Reporter 并等待队列中的错误发送完成。
+func (r *Reporter) OnAppStop(ctx context.Context) {
+	if r.done == nil {
+		return
+	}
+	report.Unregister(r)
+	close(r.events)
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+	}
+}
+
+type frame struct {
+	Function string `json:"function"`
+	Filename string `json:"filename"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+type exception struct {
+	Type       string `json:"type"`
+	Value      string `json:"value"`
+	Stacktrace struct {
+		Frames []frame `json:"frames"`
+	} `json:"stacktrace"`
+}
+
+type event struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Logger      string            `json:"logger"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Exception   struct {
+		Values []exception `json:"values"`
+	} `json:"exception"`
+}
+
+// newEvent 将 report.Event 转换为 Sentry 的事件，Sentry 要求调用栈最外层的调用在前。
+func (r *Reporter) newEvent(e *report.Event) *event {
+	ex := exception{Type: e.Type, Value: e.Message}
+	goroot := filepath.ToSlash(runtime.GOROOT())
+	for i := len(e.Stack) - 1; i >= 0; i-- {
+		f := e.Stack[i]
+		ex.Stacktrace.Frames = append(ex.Stacktrace.Frames, frame{
+			Function: f.Function,
+			Filename: filepath.Base(f.File),
+			AbsPath:  f.File,
+			Lineno:   f.Line,
+			InApp:    goroot == "" || !strings.HasPrefix(f.File, goroot),
+		})
+	}
+	v := &event{
+		EventID:     e.ID,
+		Timestamp:   e.Time.UTC().Format(time.RFC3339),
+		Level:       e.Level,
+		Platform:    "go",
+		Logger:      e.Source,
+		ServerName:  r.config.ServerName,
+		Environment: r.config.Environment,
+		Release:     r.config.Release,
+		Tags:        e.Tags,
+	}
+	v.Exception.Values = []exception{ex}
+	return v
+}
+
+func (r *Reporter) send(e *report.Event) error {
+
+	b, err := json.Marshal(r.newEvent(e))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.store, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", r.auth)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("sentry: send event %s error: %d %s", e.ID, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
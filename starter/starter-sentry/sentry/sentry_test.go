@@ -0,0 +1,104 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sentry_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/report"
+	"github.com/go-spring/starter-sentry/sentry"
+)
+
+func TestReporter(t *testing.T) {
+
+	var (
+		mutex  sync.Mutex
+		auth   string
+		events []map[string]interface{}
+	)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.URL.Path, "/sentry/api/42/store/")
+		var v map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&v)
+		mutex.Lock()
+		defer mutex.Unlock()
+		auth = r.Header.Get("X-Sentry-Auth")
+		events = append(events, v)
+	}))
+	defer s.Close()
+
+	dsn := strings.Replace(s.URL, "http://", "http://public@", 1) + "/sentry/42"
+	r, err := sentry.NewReporter(sentry.Config{
+		DSN:         dsn,
+		Environment: "prod",
+		Release:     "v1.0.0",
+		ServerName:  "host-1",
+		SampleRate:  1,
+	})
+	assert.Nil(t, err)
+
+	r.OnAppStart(nil)
+	func() {
+		defer func() {
+			ctx := report.WithTags(context.Background(), "request_id", "abc")
+			report.Panic(ctx, report.SourceWeb, recover())
+		}()
+		panic(errors.New("boom"))
+	}()
+	r.OnAppStop(context.Background())
+
+	// 停止之后不再上报
+	report.Error(context.Background(), report.SourceGo, errors.New("ignored"))
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.True(t, strings.Contains(auth, "sentry_key=public"))
+	assert.Equal(t, len(events), 1)
+
+	e := events[0]
+	assert.Equal(t, e["level"], "fatal")
+	assert.Equal(t, e["platform"], "go")
+	assert.Equal(t, e["logger"], "web")
+	assert.Equal(t, e["environment"], "prod")
+	assert.Equal(t, e["release"], "v1.0.0")
+	assert.Equal(t, e["server_name"], "host-1")
+	assert.Equal(t, e["tags"], map[string]interface{}{"source": "web", "request_id": "abc"})
+
+	ex := e["exception"].(map[string]interface{})["values"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, ex["type"], "*errors.errorString")
+	assert.Equal(t, ex["value"], "boom")
+	frames := ex["stacktrace"].(map[string]interface{})["frames"].([]interface{})
+	last := frames[len(frames)-1].(map[string]interface{})
+	assert.True(t, strings.HasSuffix(last["function"].(string), "TestReporter.func2"))
+	assert.Equal(t, last["in_app"], true)
+}
+
+func TestNewReporter(t *testing.T) {
+	_, err := sentry.NewReporter(sentry.Config{DSN: "https://sentry.io/42"})
+	assert.Error(t, err, "sentry: dsn has no public key")
+	_, err = sentry.NewReporter(sentry.Config{DSN: "https://key@sentry.io/"})
+	assert.Error(t, err, "sentry: dsn has no project id")
+}
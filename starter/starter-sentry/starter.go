@@ -0,0 +1,33 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package StarterSentry
+
+import (
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
+	"github.com/go-spring/starter-sentry/sentry"
+)
+
+func init() {
+	// 默认只在 prod 环境上报错误，其他环境可以通过 sentry.enabled 开启。
+	gs.Provide(sentry.NewReporter, "${sentry}").
+		Export((*gs.AppEvent)(nil)).
+		On(cond.OnProperty("sentry.dsn").On(cond.Group(cond.Or,
+			cond.OnProperty("sentry.enabled", cond.HavingValue("true")),
+			cond.OnMissingProperty("sentry.enabled").OnProfile("prod"),
+		)))
+}
@@ -0,0 +1,14 @@
+module github.com/go-spring/starter-service
+
+go 1.14
+
+require (
+	github.com/go-spring/spring-base v1.1.0-rc3
+	github.com/go-spring/spring-core v1.1.0-rc3
+	golang.org/x/sys v0.0.0-20210910150752-751e447fb3d0
+)
+
+replace (
+	github.com/go-spring/spring-base => ../../spring/spring-base
+	github.com/go-spring/spring-core => ../../spring/spring-core
+)
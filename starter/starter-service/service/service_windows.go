@@ -0,0 +1,109 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-spring/spring-core/gs"
+	"golang.org/x/sys/windows/svc"
+)
+
+// WindowsService 以 Windows 服务的方式运行应用，启动时报告 StartPending 状态，
+// 就绪之后报告 Running 状态，收到 Stop 或者 Shutdown 控制命令时报告 StopPending
+// 状态并关闭应用。不是由服务控制管理器启动时不做任何事情。
+type WindowsService struct {
+	App  *gs.App `autowire:""`
+	Name string  `value:"${spring.application.name:=go-spring}"`
+
+	once  sync.Once
+	ready chan struct{}
+}
+
+// NewWindowsService 创建 Windows 服务。
+func NewWindowsService() *WindowsService {
+	return &WindowsService{ready: make(chan struct{})}
+}
+
+// OnAppStart 由服务控制管理器启动时开始处理控制命令。
+func (s *WindowsService) OnAppStart(ctx gs.Context) {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		logger.Warnf("detect windows service error: %v", err)
+		return
+	}
+	if !isService {
+		return
+	}
+	ctx.Go(func(ctx context.Context) {
+		h := &handler{ctx: ctx, app: s.App, ready: s.ready}
+		if err := svc.Run(s.Name, h); err != nil {
+			logger.Errorf("run windows service %s error: %v", s.Name, err)
+		}
+	})
+}
+
+// OnAppStop 应用程序结束事件。
+func (s *WindowsService) OnAppStop(ctx context.Context) {}
+
+// OnReady 通知服务控制管理器应用已经就绪。
+func (s *WindowsService) OnReady(ctx context.Context) {
+	s.once.Do(func() { close(s.ready) })
+}
+
+type handler struct {
+	ctx   context.Context
+	app   *gs.App
+	ready chan struct{}
+}
+
+const accepts = svc.AcceptStop | svc.AcceptShutdown
+
+// Execute 实现 svc.Handler 接口，应用退出时返回。
+func (h *handler) Execute(args []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
+	status := svc.Status{State: svc.StartPending}
+	s <- status
+	ready := h.ready
+	for {
+		select {
+		case <-h.ctx.Done():
+			s <- svc.Status{State: svc.StopPending}
+			return false, 0
+		case <-ready:
+			ready = nil
+			status = svc.Status{State: svc.Running, Accepts: accepts}
+			s <- status
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				s <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status = svc.Status{State: svc.StopPending}
+				s <- status
+				h.app.ShutDown("windows service control " + cmdName(c.Cmd))
+			}
+		}
+	}
+}
+
+func cmdName(cmd svc.Cmd) string {
+	if cmd == svc.Shutdown {
+		return "shutdown"
+	}
+	return "stop"
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package service 将应用的生命周期通知给操作系统的服务管理器，支持 systemd 的
+// sd_notify 协议以及 Windows 服务。
+package service
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/gs"
+)
+
+var logger = log.GetLogger("GS_SERVICE")
+
+// Notify 按照 sd_notify 协议向 systemd 发送状态，例如 READY=1 、STOPPING=1 等，
+// 没有设置 NOTIFY_SOCKET 环境变量即不是由 systemd 启动时返回 false 。
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// 以 @ 开头的是 Linux 的抽象命名空间套接字。
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WatchdogInterval 返回 systemd 要求的看门狗通知间隔，没有开启看门狗或者看门狗
+// 不是针对当前进程时返回 0 。
+func WatchdogInterval() time.Duration {
+	s := os.Getenv("WATCHDOG_USEC")
+	if s == "" {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Systemd 在应用就绪时发送 READY=1 ，开始关闭时发送 STOPPING=1 ，开启了看门狗时
+// 按照一半的间隔发送 WATCHDOG=1 。需要在 systemd 的 unit 中设置 Type=notify 。
+type Systemd struct{}
+
+func notify(state string) {
+	if _, err := Notify(state); err != nil {
+		logger.Warnf("sd_notify %q error: %v", state, err)
+	}
+}
+
+// OnAppStart 开启了看门狗时启动通知看门狗的 goroutine 。
+func (s *Systemd) OnAppStart(ctx gs.Context) {
+	interval := WatchdogInterval()
+	if interval <= 0 {
+		return
+	}
+	ctx.Go(func(ctx context.Context) {
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				notify("WATCHDOG=1")
+			}
+		}
+	})
+}
+
+// OnAppStop 应用程序结束事件。
+func (s *Systemd) OnAppStop(ctx context.Context) {}
+
+// OnReady 通知 systemd 应用已经就绪。
+func (s *Systemd) OnReady(ctx context.Context) {
+	notify("READY=1\nMAINPID=" + strconv.Itoa(os.Getpid()))
+}
+
+// OnShutdown 通知 systemd 应用正在关闭。
+func (s *Systemd) OnShutdown(ctx context.Context) {
+	notify("STOPPING=1")
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/starter-service/service"
+)
+
+func TestNotify(t *testing.T) {
+
+	t.Run("no socket", func(t *testing.T) {
+		os.Unsetenv("NOTIFY_SOCKET")
+		sent, err := service.Notify("READY=1")
+		assert.Nil(t, err)
+		assert.False(t, sent)
+	})
+
+	dir, err := ioutil.TempDir("", "notify")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", socket)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+
+	read := func() string {
+		buf := make([]byte, 1024)
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFromUnix(buf)
+		assert.Nil(t, err)
+		return string(buf[:n])
+	}
+
+	s := new(service.Systemd)
+	s.OnReady(context.Background())
+	assert.Equal(t, read(), "READY=1\nMAINPID="+strconv.Itoa(os.Getpid()))
+	s.OnShutdown(context.Background())
+	assert.Equal(t, read(), "STOPPING=1")
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	defer os.Unsetenv("WATCHDOG_USEC")
+	defer os.Unsetenv("WATCHDOG_PID")
+
+	os.Unsetenv("WATCHDOG_USEC")
+	assert.Equal(t, service.WatchdogInterval(), time.Duration(0))
+
+	os.Setenv("WATCHDOG_USEC", "3000000")
+	assert.Equal(t, service.WatchdogInterval(), 3*time.Second)
+
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	assert.Equal(t, service.WatchdogInterval(), time.Duration(0))
+}
@@ -0,0 +1,30 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package StarterService
+
+import (
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
+	"github.com/go-spring/starter-service/service"
+)
+
+func init() {
+	// 没有设置 NOTIFY_SOCKET 环境变量时 systemd 通知不做任何事情。
+	gs.Object(new(service.Systemd)).
+		Export((*gs.AppEvent)(nil), (*gs.ReadyHook)(nil), (*gs.ShutdownHook)(nil)).
+		On(cond.OnProperty("spring.service.enabled", cond.HavingValue("true"), cond.MatchIfMissing()))
+}
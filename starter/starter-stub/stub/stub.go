@@ -0,0 +1,285 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package stub 提供了集成测试使用的桩服务器。桩服务器通过配置文件声明，每个路由返回
+// 预先设定的响应，并且可以模拟延迟和按照比例注入失败，使得服务测试不再依赖外部服务。
+package stub
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Config 桩服务器的配置。
+type Config struct {
+	HTTP []HTTPServer `value:"${http:=}"`
+	GRPC []GRPCServer `value:"${grpc:=}"`
+}
+
+// Fault 模拟延迟和故障注入的配置。
+type Fault struct {
+	Latency     time.Duration `value:"${latency:=0}"`
+	FailureRate float64       `value:"${failure-rate:=0}"` // 返回失败响应的比例，取值 0~1
+}
+
+// inject 执行模拟延迟，需要返回失败响应时返回 true 。
+func (f Fault) inject(ctx context.Context) bool {
+	if f.Latency > 0 {
+		select {
+		case <-time.After(f.Latency):
+		case <-ctx.Done():
+		}
+	}
+	return f.FailureRate > 0 && rand.Float64() < f.FailureRate
+}
+
+// HTTPServer HTTP 桩服务器的配置，端口为 0 时随机选择可用端口。
+type HTTPServer struct {
+	Name   string      `value:"${name}"`
+	Port   int         `value:"${port:=0}"`
+	Routes []HTTPRoute `value:"${routes:=}"`
+}
+
+// HTTPRoute HTTP 路由及其响应，Method 为空时匹配所有的请求方法。
+type HTTPRoute struct {
+	Method        string   `value:"${method:=}"`
+	Path          string   `value:"${path}"`
+	Status        int      `value:"${status:=200}"`
+	Headers       []string `value:"${headers:=}"` // Key: Value 形式的响应头
+	Body          string   `value:"${body:=}"`
+	FailureStatus int      `value:"${failure-status:=503}"`
+	Fault
+}
+
+// GRPCServer gRPC 桩服务器的配置，端口为 0 时随机选择可用端口。
+type GRPCServer struct {
+	Name    string      `value:"${name}"`
+	Port    int         `value:"${port:=0}"`
+	Methods []GRPCRoute `value:"${methods:=}"`
+}
+
+// GRPCRoute gRPC 方法及其响应。Method 为 /package.Service/Method 形式的全名，
+// Response 为 base64 编码的 protobuf 格式的响应消息。
+type GRPCRoute struct {
+	Method      string `value:"${method}"`
+	Response    string `value:"${response:=}"`
+	Code        int    `value:"${code:=0}"`
+	Message     string `value:"${message:=}"`
+	FailureCode int    `value:"${failure-code:=14}"`
+	Fault
+}
+
+// Servers 所有已经启动的桩服务器。
+type Servers struct {
+	mutex sync.Mutex
+	addrs map[string]string
+	http  []*http.Server
+	grpc  []*grpc.Server
+}
+
+// NewServers 根据配置启动所有的桩服务器，任何一个启动失败都会关闭已经启动的服务器。
+func NewServers(config Config) (*Servers, error) {
+	s := &Servers{addrs: make(map[string]string)}
+	for _, c := range config.HTTP {
+		if err := s.startHTTP(c.Name, c); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	for _, c := range config.GRPC {
+		if err := s.startGRPC(c.Name, c); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Addr 返回桩服务器的监听地址，服务器不存在时返回空字符串。
+func (s *Servers) Addr(name string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.addrs[name]
+}
+
+// URL 返回 HTTP 桩服务器的地址，如 http://127.0.0.1:8080 。
+func (s *Servers) URL(name string) string {
+	if addr := s.Addr(name); addr != "" {
+		return "http://" + addr
+	}
+	return ""
+}
+
+// Close 关闭所有的桩服务器。
+func (s *Servers) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, svr := range s.http {
+		_ = svr.Close()
+	}
+	for _, svr := range s.grpc {
+		svr.Stop()
+	}
+	s.http, s.grpc = nil, nil
+}
+
+func (s *Servers) listen(name string, port int) (net.Listener, error) {
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("stub server %q: %w", name, err)
+	}
+	s.mutex.Lock()
+	s.addrs[name] = l.Addr().String()
+	s.mutex.Unlock()
+	log.Infof("stub server %q listens on %s", name, l.Addr())
+	return l, nil
+}
+
+func (s *Servers) startHTTP(name string, c HTTPServer) error {
+
+	l, err := s.listen(name, c.Port)
+	if err != nil {
+		return err
+	}
+
+	svr := &http.Server{Handler: httpHandler(c.Routes)}
+	s.mutex.Lock()
+	s.http = append(s.http, svr)
+	s.mutex.Unlock()
+
+	go func() {
+		if err := svr.Serve(l); err != nil && err != http.ErrServerClosed {
+			log.Error(nil, err)
+		}
+	}()
+	return nil
+}
+
+func httpHandler(routes []HTTPRoute) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, route := range routes {
+			if route.Path != r.URL.Path || (route.Method != "" && route.Method != r.Method) {
+				continue
+			}
+			if route.inject(r.Context()) {
+				w.WriteHeader(route.FailureStatus)
+				return
+			}
+			for _, h := range route.Headers {
+				if i := strings.Index(h, ":"); i > 0 {
+					w.Header().Set(strings.TrimSpace(h[:i]), strings.TrimSpace(h[i+1:]))
+				}
+			}
+			w.WriteHeader(route.Status)
+			_, _ = w.Write([]byte(route.Body))
+			return
+		}
+		http.NotFound(w, r)
+	})
+}
+
+func (s *Servers) startGRPC(name string, c GRPCServer) error {
+
+	routes := make(map[string]GRPCRoute)
+	for _, m := range c.Methods {
+		if _, err := base64.StdEncoding.DecodeString(m.Response); err != nil {
+			return fmt.Errorf("stub server %q: invalid response of %s: %w", name, m.Method, err)
+		}
+		routes[m.Method] = m
+	}
+
+	l, err := s.listen(name, c.Port)
+	if err != nil {
+		return err
+	}
+
+	svr := grpc.NewServer(
+		grpc.ForceServerCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(grpcHandler(routes)),
+	)
+	s.mutex.Lock()
+	s.grpc = append(s.grpc, svr)
+	s.mutex.Unlock()
+
+	go func() {
+		if err := svr.Serve(l); err != nil {
+			log.Error(nil, err)
+		}
+	}()
+	return nil
+}
+
+func grpcHandler(routes map[string]GRPCRoute) grpc.StreamHandler {
+	return func(srv interface{}, stream grpc.ServerStream) error {
+
+		method, _ := grpc.MethodFromServerStream(stream)
+		route, ok := routes[method]
+		if !ok {
+			return status.Errorf(codes.Unimplemented, "stub method %s not found", method)
+		}
+
+		var req []byte
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+
+		if route.inject(stream.Context()) {
+			return status.Error(codes.Code(route.FailureCode), "stub failure injected")
+		}
+		if route.Code != 0 {
+			return status.Error(codes.Code(route.Code), route.Message)
+		}
+
+		resp, _ := base64.StdEncoding.DecodeString(route.Response)
+		return stream.SendMsg(&resp)
+	}
+}
+
+// rawCodec 不解析消息内容，直接收发原始字节。
+type rawCodec struct{}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("unsupported message type %T", v)
+	}
+	return *b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unsupported message type %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stub_test
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/starter-stub/stub"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const properties = `
+stub.http[0].name=user
+stub.http[0].routes[0].method=GET
+stub.http[0].routes[0].path=/users/1
+stub.http[0].routes[0].headers=Content-Type: application/json
+stub.http[0].routes[0].body={"id":1}
+stub.http[0].routes[0].latency=50ms
+stub.http[0].routes[1].path=/orders
+stub.http[0].routes[1].failure-rate=1
+stub.grpc[0].name=greeter
+stub.grpc[0].methods[0].method=/helloworld.Greeter/SayHello
+stub.grpc[0].methods[0].response=CgVoZWxsbw==
+stub.grpc[0].methods[1].method=/helloworld.Greeter/SayBye
+stub.grpc[0].methods[1].code=5
+stub.grpc[0].methods[1].message=not found
+`
+
+func newServers(t *testing.T) *stub.Servers {
+	p, err := conf.Bytes([]byte(properties), ".properties")
+	assert.Nil(t, err)
+	var config stub.Config
+	err = p.Bind(&config, conf.Tag("${stub}"))
+	assert.Nil(t, err)
+	s, err := stub.NewServers(config)
+	assert.Nil(t, err)
+	return s
+}
+
+func TestHTTPServer(t *testing.T) {
+	s := newServers(t)
+	defer s.Close()
+
+	start := time.Now()
+	resp, err := http.Get(s.URL("user") + "/users/1")
+	assert.Nil(t, err)
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	assert.Equal(t, resp.Header.Get("Content-Type"), "application/json")
+	assert.Equal(t, string(b), `{"id":1}`)
+
+	resp, err = http.Post(s.URL("user")+"/users/1", "text/plain", nil)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusNotFound)
+
+	resp, err = http.Get(s.URL("user") + "/orders")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusServiceUnavailable)
+}
+
+type rawCodec struct{}
+
+func (rawCodec) Name() string { return "proto" }
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) { return *v.(*[]byte), nil }
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*[]byte) = data
+	return nil
+}
+
+func TestGRPCServer(t *testing.T) {
+	s := newServers(t)
+	defer s.Close()
+
+	conn, err := grpc.Dial(s.Addr("greeter"), grpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	ctx := context.Background()
+	req := []byte{}
+	var resp []byte
+	err = conn.Invoke(ctx, "/helloworld.Greeter/SayHello", &req, &resp, grpc.ForceCodec(rawCodec{}))
+	assert.Nil(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(resp), "CgVoZWxsbw==")
+
+	err = conn.Invoke(ctx, "/helloworld.Greeter/SayBye", &req, &resp, grpc.ForceCodec(rawCodec{}))
+	assert.Equal(t, status.Code(err), codes.NotFound)
+
+	err = conn.Invoke(ctx, "/helloworld.Greeter/Unknown", &req, &resp, grpc.ForceCodec(rawCodec{}))
+	assert.Equal(t, status.Code(err), codes.Unimplemented)
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vault
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+)
+
+// Credentials 数据库动态凭证，通常作为 Renewer.Watch 的回调保持最新，例如:
+//
+//	creds := new(vault.Credentials)
+//	err := renewer.Watch(ctx, "database/creds/app", creds.Update)
+type Credentials struct {
+	mutex    sync.RWMutex
+	username string
+	password string
+}
+
+// Update 使用 secret 中的 username 和 password 更新凭证。
+func (c *Credentials) Update(s *Secret) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.username = fmt.Sprint(s.Data["username"])
+	c.password = fmt.Sprint(s.Data["password"])
+}
+
+// Get 返回当前的用户名和密码。
+func (c *Credentials) Get() (username, password string) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.username, c.password
+}
+
+// Connector 返回每次建立连接都使用最新凭证的 driver.Connector ，dsn 根据用户名和
+// 密码生成数据源。配合 sql.DB.SetConnMaxLifetime 可以让使用旧凭证的连接在凭证
+// 过期之前被关闭。
+func (c *Credentials) Connector(d driver.Driver, dsn func(username, password string) string) driver.Connector {
+	return &connector{creds: c, driver: d, dsn: dsn}
+}
+
+type connector struct {
+	creds  *Credentials
+	driver driver.Driver
+	dsn    func(username, password string) string
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	name := c.dsn(c.creds.Get())
+	if d, ok := c.driver.(driver.DriverContext); ok {
+		connector, err := d.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+		return connector.Connect(ctx)
+	}
+	return c.driver.Open(name)
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
@@ -0,0 +1,193 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vault
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-core/gs"
+)
+
+var logger = log.GetLogger("GS_VAULT")
+
+// retryInterval 续期或者重新读取 secret 失败时的重试间隔。
+var retryInterval = 5 * time.Second
+
+// Renewer 在后台续期 token 以及通过 Watch 读取的 secret 的租约，租约不能再续期时
+// 重新读取 secret 并通知调用方，从而实现数据库动态凭证等 secret 的轮换。
+type Renewer struct {
+	client  *Client
+	mutex   sync.Mutex
+	watches []*watch
+	started bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+type watch struct {
+	path   string
+	fn     func(*Secret)
+	secret *Secret
+}
+
+func NewRenewer(client *Client) *Renewer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Renewer{client: client, ctx: ctx, cancel: cancel}
+}
+
+// Watch 读取 path 对应的 secret 并立即调用 fn ，之后在租约到期之前续期，续期失败
+// 或者剩余的有效期已经不足一个租约周期时重新读取 secret 并再次调用 fn 。
+func (r *Renewer) Watch(ctx context.Context, path string, fn func(*Secret)) error {
+	secret, err := r.client.ReadSecret(ctx, path)
+	if err != nil {
+		return err
+	}
+	fn(secret)
+	w := &watch{path: path, fn: fn, secret: secret}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.watches = append(r.watches, w)
+	if r.started {
+		r.goWatch(w)
+	}
+	return nil
+}
+
+func (r *Renewer) goWatch(w *watch) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.runWatch(w)
+	}()
+}
+
+// sleep 等待 d 时间，返回 false 表示 Renewer 已经停止。
+func (r *Renewer) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-r.ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+func (r *Renewer) runWatch(w *watch) {
+	for {
+		d := w.secret.LeaseDuration
+		if d <= 0 {
+			return // 没有租约的 secret 不需要续期
+		}
+		if !r.sleep(d * 2 / 3) {
+			return
+		}
+		if w.secret.Renewable {
+			s, err := r.client.RenewLease(r.ctx, w.secret.LeaseID, d)
+			if err == nil && s.LeaseDuration >= d {
+				continue
+			}
+			if err != nil {
+				logger.WithContext(r.ctx).Warnf("renew lease of %s error: %v", w.path, err)
+			}
+		}
+		for {
+			s, err := r.client.ReadSecret(r.ctx, w.path)
+			if err == nil {
+				w.secret = s
+				w.fn(s)
+				logger.WithContext(r.ctx).Infof("secret %s rotated", w.path)
+				break
+			}
+			logger.WithContext(r.ctx).Errorf(log.ERROR, "read secret %s error: %v", w.path, err)
+			if !r.sleep(retryInterval) {
+				return
+			}
+		}
+	}
+}
+
+func (r *Renewer) runToken() {
+	for {
+		ttl, renewable := r.client.tokenInfo()
+		if ttl <= 0 {
+			return // token 永不过期
+		}
+		if !r.sleep(ttl * 2 / 3) {
+			return
+		}
+		if renewable {
+			_, err := r.client.RenewToken(r.ctx)
+			if err == nil {
+				continue
+			}
+			logger.WithContext(r.ctx).Warnf("renew vault token error: %v", err)
+		}
+		if r.client.config.Auth != AuthKubernetes {
+			logger.WithContext(r.ctx).Warnf("vault token will expire in %s", ttl/3)
+			return
+		}
+		for {
+			err := r.client.Login(r.ctx)
+			if err == nil {
+				break
+			}
+			logger.WithContext(r.ctx).Errorf(log.ERROR, "vault login error: %v", err)
+			if !r.sleep(retryInterval) {
+				return
+			}
+		}
+	}
+}
+
+// OnAppStart 开始在后台续期 token 和租约。
+func (r *Renewer) OnAppStart(ctx gs.Context) {
+	if r.client.config.Auth == AuthToken {
+		if err := r.client.LookupToken(r.ctx); err != nil {
+			logger.WithContext(r.ctx).Warnf("lookup vault token error: %v", err)
+		}
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.started = true
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.runToken()
+	}()
+	for _, w := range r.watches {
+		r.goWatch(w)
+	}
+}
+
+// OnAppStop 停止续期并等待后台任务结束。
+func (r *Renewer) OnAppStop(ctx context.Context) {
+	r.cancel()
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vault
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"github.com/go-spring/spring-core/tlsconfig"
+)
+
+// TLSSource 从 Vault 读取证书的 tlsconfig.Source ，secret 的格式与 PKI 引擎签发证书
+// 的返回值相同，包含 certificate 、private_key 以及可选的 issuing_ca 、ca_chain 字段，
+// 也可以事先保存在 KV 引擎中。
+type TLSSource struct {
+	provider SecretProvider
+	path     string
+}
+
+// NewTLSSource TLSSource 的构造函数。
+func NewTLSSource(provider SecretProvider, path string) *TLSSource {
+	return &TLSSource{provider: provider, path: path}
+}
+
+func (s *TLSSource) Name() string {
+	return "vault"
+}
+
+func (s *TLSSource) Load(ctx context.Context) (*tlsconfig.Bundle, error) {
+
+	secret, err := s.provider.ReadSecret(ctx, s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	certPEM, _ := secret.Data["certificate"].(string)
+	keyPEM, _ := secret.Data["private_key"].(string)
+	cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path, err)
+	}
+	b := &tlsconfig.Bundle{Certificate: &cert}
+
+	var ca []string
+	if v, ok := secret.Data["issuing_ca"].(string); ok {
+		ca = append(ca, v)
+	}
+	if v, ok := secret.Data["ca_chain"].([]interface{}); ok {
+		for _, c := range v {
+			if s, ok := c.(string); ok {
+				ca = append(ca, s)
+			}
+		}
+	}
+	if len(ca) > 0 {
+		if b.Roots, err = tlsconfig.NewCertPool([]byte(strings.Join(ca, "\n"))); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.path, err)
+		}
+	}
+	return b, nil
+}
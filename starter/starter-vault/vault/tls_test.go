@@ -0,0 +1,84 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vault_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/starter-vault/vault"
+)
+
+type secrets map[string]*vault.Secret
+
+func (s secrets) ReadSecret(ctx context.Context, path string) (*vault.Secret, error) {
+	return s[path], nil
+}
+
+// selfSigned 返回 PEM 格式的自签名证书和私钥。
+func selfSigned(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "order"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	b, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b}))
+}
+
+func TestTLSSource(t *testing.T) {
+
+	cert, key := selfSigned(t)
+	s := secrets{
+		"pki/issue/order": {Data: map[string]interface{}{
+			"certificate": cert,
+			"private_key": key,
+			"ca_chain":    []interface{}{cert},
+		}},
+		"secret/bad": {Data: map[string]interface{}{"certificate": cert}},
+	}
+
+	src := vault.NewTLSSource(s, "pki/issue/order")
+	assert.Equal(t, src.Name(), "vault")
+	b, err := src.Load(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, len(b.Certificate.Certificate), 1)
+	assert.NotNil(t, b.Roots)
+
+	_, err = vault.NewTLSSource(s, "secret/bad").Load(context.Background())
+	assert.Error(t, err, "secret/bad: tls: failed to find any PEM data in key input")
+}
@@ -0,0 +1,350 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package vault 通过 HTTP API 访问 HashiCorp Vault ，支持 token 和 kubernetes 两种认证
+// 方式，可以读取 KV 以及数据库动态凭证等各种 secret ，并在后台续期 token 和租约。
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-core/conf"
+)
+
+const (
+	AuthToken      = "token"
+	AuthKubernetes = "kubernetes"
+)
+
+type KubernetesConfig struct {
+	Role      string `value:"${role:=}"`
+	Mount     string `value:"${mount:=kubernetes}"`
+	TokenPath string `value:"${token-path:=/var/run/secrets/kubernetes.io/serviceaccount/token}"`
+}
+
+type Config struct {
+	Address    string           `value:"${address:=http://127.0.0.1:8200}"`
+	Namespace  string           `value:"${namespace:=}"` // Vault 企业版的命名空间
+	Auth       string           `value:"${auth:=token}"` // 认证方式，token 或者 kubernetes
+	Token      string           `value:"${token:=}"`
+	Kubernetes KubernetesConfig `value:"${kubernetes}"`
+	Timeout    time.Duration    `value:"${timeout:=5s}"`
+}
+
+func NewConfig() Config {
+	return Config{
+		Address: "http://127.0.0.1:8200",
+		Auth:    AuthToken,
+		Kubernetes: KubernetesConfig{
+			Mount:     "kubernetes",
+			TokenPath: "/var/run/secrets/kubernetes.io/serviceaccount/token",
+		},
+		Timeout: 5 * time.Second,
+	}
+}
+
+// ConfigFromEnv 从 Vault 命令行工具使用的 VAULT_ADDR 、VAULT_TOKEN 、VAULT_NAMESPACE
+// 以及 VAULT_AUTH 、VAULT_K8S_ROLE 、VAULT_K8S_MOUNT 环境变量获取配置，用于在属性
+// 文件加载之前导入 secret 。
+func ConfigFromEnv() Config {
+	config := NewConfig()
+	set := func(s *string, env string) {
+		if v, ok := os.LookupEnv(env); ok && v != "" {
+			*s = v
+		}
+	}
+	set(&config.Address, "VAULT_ADDR")
+	set(&config.Token, "VAULT_TOKEN")
+	set(&config.Namespace, "VAULT_NAMESPACE")
+	set(&config.Auth, "VAULT_AUTH")
+	set(&config.Kubernetes.Role, "VAULT_K8S_ROLE")
+	set(&config.Kubernetes.Mount, "VAULT_K8S_MOUNT")
+	return config
+}
+
+// Secret Vault 返回的 secret ，KV v2 的数据已经被展开。
+type Secret struct {
+	LeaseID       string
+	LeaseDuration time.Duration
+	Renewable     bool
+	Data          map[string]interface{}
+}
+
+// SecretProvider 根据路径读取 secret 。
+type SecretProvider interface {
+	ReadSecret(ctx context.Context, path string) (*Secret, error)
+}
+
+// Error Vault 返回的错误。
+type Error struct {
+	StatusCode int
+	Errors     []string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("vault error %d: %s", e.StatusCode, strings.Join(e.Errors, "; "))
+}
+
+// Unwrap 路径不存在时返回 os.ErrNotExist 。
+func (e *Error) Unwrap() error {
+	if e.StatusCode == http.StatusNotFound {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+type response struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int64                  `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int64  `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+// Client Vault 客户端
+type Client struct {
+	config Config
+	client *http.Client
+
+	mutex          sync.RWMutex
+	token          string
+	tokenTTL       time.Duration
+	tokenRenewable bool
+}
+
+// NewClient 创建 Vault 客户端，使用 kubernetes 认证时立即登录。
+func NewClient(config Config) (*Client, error) {
+	c := &Client{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		token:  config.Token,
+	}
+	switch config.Auth {
+	case AuthToken:
+		if config.Token == "" {
+			return nil, fmt.Errorf("vault token can't be empty")
+		}
+	case AuthKubernetes:
+		if err := c.Login(context.Background()); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported vault auth %q", config.Auth)
+	}
+	return c, nil
+}
+
+// Login 使用 kubernetes service account 的 JWT 登录，获取新的 token 。
+func (c *Client) Login(ctx context.Context) error {
+	if c.config.Auth != AuthKubernetes {
+		return nil
+	}
+	jwt, err := ioutil.ReadFile(c.config.Kubernetes.TokenPath)
+	if err != nil {
+		return err
+	}
+	body := map[string]string{
+		"role": c.config.Kubernetes.Role,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	}
+	path := "auth/" + c.config.Kubernetes.Mount + "/login"
+	resp, err := c.do(ctx, http.MethodPost, path, body)
+	if err != nil {
+		return err
+	}
+	return c.setToken(resp)
+}
+
+func (c *Client) setToken(resp *response) error {
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return fmt.Errorf("vault response contains no token")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.token = resp.Auth.ClientToken
+	c.tokenTTL = time.Duration(resp.Auth.LeaseDuration) * time.Second
+	c.tokenRenewable = resp.Auth.Renewable
+	return nil
+}
+
+// RenewToken 续期当前的 token ，返回新的有效期。
+func (c *Client) RenewToken(ctx context.Context) (time.Duration, error) {
+	resp, err := c.do(ctx, http.MethodPost, "auth/token/renew-self", map[string]string{})
+	if err != nil {
+		return 0, err
+	}
+	if err = c.setToken(resp); err != nil {
+		return 0, err
+	}
+	return time.Duration(resp.Auth.LeaseDuration) * time.Second, nil
+}
+
+// LookupToken 查询当前 token 的有效期以及是否可以续期。
+func (c *Client) LookupToken(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodGet, "auth/token/lookup-self", nil)
+	if err != nil {
+		return err
+	}
+	ttl, _ := resp.Data["ttl"].(float64)
+	renewable, _ := resp.Data["renewable"].(bool)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.tokenTTL = time.Duration(ttl) * time.Second
+	c.tokenRenewable = renewable
+	return nil
+}
+
+// tokenInfo 返回 token 的有效期以及是否可以续期，有效期为 0 表示 token 永不过期。
+func (c *Client) tokenInfo() (time.Duration, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.tokenTTL, c.tokenRenewable
+}
+
+// ReadSecret 读取 path 对应的 secret ，path 不包含 /v1/ 前缀，如 secret/data/app 。
+func (c *Client) ReadSecret(ctx context.Context, path string) (*Secret, error) {
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return toSecret(resp), nil
+}
+
+// RenewLease 续期动态 secret 的租约，返回续期后的 secret ，其 Data 为空。
+func (c *Client) RenewLease(ctx context.Context, leaseID string, increment time.Duration) (*Secret, error) {
+	body := map[string]interface{}{
+		"lease_id":  leaseID,
+		"increment": int64(increment / time.Second),
+	}
+	resp, err := c.do(ctx, http.MethodPut, "sys/leases/renew", body)
+	if err != nil {
+		return nil, err
+	}
+	return toSecret(resp), nil
+}
+
+func toSecret(resp *response) *Secret {
+	data := resp.Data
+	// KV v2 的数据保存在 data.data 中，版本信息保存在 data.metadata 中。
+	if len(data) == 2 && data["metadata"] != nil {
+		if m, ok := data["data"].(map[string]interface{}); ok {
+			data = m
+		}
+	}
+	return &Secret{
+		LeaseID:       resp.LeaseID,
+		LeaseDuration: time.Duration(resp.LeaseDuration) * time.Second,
+		Renewable:     resp.Renewable,
+		Data:          data,
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body interface{}) (*response, error) {
+
+	var reader *bytes.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	url := strings.TrimSuffix(c.config.Address, "/") + "/v1/" + strings.TrimPrefix(path, "/")
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.RLock()
+	token := c.token
+	c.mutex.RUnlock()
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if c.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.config.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		e := &Error{StatusCode: resp.StatusCode}
+		var r struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(b, &r) == nil {
+			e.Errors = r.Errors
+		}
+		return nil, e
+	}
+
+	ret := new(response)
+	if len(b) > 0 {
+		if err = json.Unmarshal(b, ret); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// Import 读取 location 对应的 secret 作为属性列表，location 的格式为 //path ，
+// 如 spring.config.import=vault://secret/data/app ，客户端配置通过 ConfigFromEnv 获取。
+func Import(location string) (*conf.Properties, error) {
+	client, err := NewClient(ConfigFromEnv())
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), client.config.Timeout)
+	defer cancel()
+	secret, err := client.ReadSecret(ctx, strings.TrimPrefix(location, "//"))
+	if err != nil {
+		return nil, err
+	}
+	p := conf.New()
+	for k, v := range secret.Data {
+		if err = p.Set(k, v); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
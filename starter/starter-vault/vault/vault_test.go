@@ -0,0 +1,222 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vault_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/starter-vault/vault"
+)
+
+// fakeVault 模拟 Vault 的 HTTP API ，每次读取数据库凭证都会生成新的用户名。
+type fakeVault struct {
+	mutex    sync.Mutex
+	creds    int
+	renewals int
+	requests []string
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.requests = append(v.requests, r.Method+" "+r.URL.Path)
+
+	reply := func(status int, i interface{}) {
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(i)
+	}
+
+	if r.URL.Path == "/v1/auth/kubernetes/login" {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["role"] != "app" || body["jwt"] != "jwt-token" {
+			reply(http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+			return
+		}
+		reply(http.StatusOK, map[string]interface{}{
+			"auth": map[string]interface{}{"client_token": "k8s-token", "lease_duration": 3600, "renewable": true},
+		})
+		return
+	}
+
+	token := r.Header.Get("X-Vault-Token")
+	if token != "root" && token != "k8s-token" {
+		reply(http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v1/secret/data/app":
+		reply(http.StatusOK, map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     map[string]interface{}{"db.password": "s3cr3t", "server": map[string]interface{}{"port": 8080}},
+				"metadata": map[string]interface{}{"version": 3},
+			},
+		})
+	case "/v1/database/creds/app":
+		v.creds++
+		reply(http.StatusOK, map[string]interface{}{
+			"lease_id":       "database/creds/app/" + string(rune('a'+v.creds)),
+			"lease_duration": 1,
+			"renewable":      true,
+			"data":           map[string]interface{}{"username": "user-" + string(rune('a'+v.creds)), "password": "pass"},
+		})
+	case "/v1/sys/leases/renew":
+		v.renewals++
+		reply(http.StatusOK, map[string]interface{}{"lease_duration": 0, "renewable": true})
+	default:
+		reply(http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+	}
+}
+
+func TestClient(t *testing.T) {
+
+	server := httptest.NewServer(new(fakeVault))
+	defer server.Close()
+
+	config := vault.NewConfig()
+	config.Address = server.URL
+	config.Token = "root"
+	client, err := vault.NewClient(config)
+	assert.Nil(t, err)
+
+	secret, err := client.ReadSecret(context.Background(), "secret/data/app")
+	assert.Nil(t, err)
+	assert.Equal(t, secret.Data["db.password"], "s3cr3t")
+
+	_, err = client.ReadSecret(context.Background(), "secret/data/none")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	config.Token = "bad"
+	client, err = vault.NewClient(config)
+	assert.Nil(t, err)
+	_, err = client.ReadSecret(context.Background(), "secret/data/app")
+	assert.Error(t, err, "vault error 403: permission denied")
+}
+
+func TestClient_Kubernetes(t *testing.T) {
+
+	server := httptest.NewServer(new(fakeVault))
+	defer server.Close()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	assert.Nil(t, ioutil.WriteFile(tokenPath, []byte("jwt-token\n"), 0600))
+
+	config := vault.NewConfig()
+	config.Address = server.URL
+	config.Auth = vault.AuthKubernetes
+	config.Kubernetes.Role = "app"
+	config.Kubernetes.TokenPath = tokenPath
+	client, err := vault.NewClient(config)
+	assert.Nil(t, err)
+
+	secret, err := client.ReadSecret(context.Background(), "secret/data/app")
+	assert.Nil(t, err)
+	assert.Equal(t, secret.Data["db.password"], "s3cr3t")
+
+	config.Kubernetes.Role = "other"
+	_, err = vault.NewClient(config)
+	assert.Error(t, err, "permission denied")
+}
+
+func TestImport(t *testing.T) {
+
+	server := httptest.NewServer(new(fakeVault))
+	defer server.Close()
+
+	os.Setenv("VAULT_ADDR", server.URL)
+	os.Setenv("VAULT_TOKEN", "root")
+	defer os.Unsetenv("VAULT_ADDR")
+	defer os.Unsetenv("VAULT_TOKEN")
+
+	p, err := vault.Import("//secret/data/app")
+	assert.Nil(t, err)
+	assert.Equal(t, p.Get("db.password"), "s3cr3t")
+	assert.Equal(t, p.Get("server.port"), "8080")
+
+	_, err = vault.Import("//secret/data/none")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+type fakeDriver struct {
+	names chan string
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	d.names <- name
+	return nil, errors.New("not implemented")
+}
+
+func TestRenewer_Rotation(t *testing.T) {
+
+	v := new(fakeVault)
+	server := httptest.NewServer(v)
+	defer server.Close()
+
+	config := vault.NewConfig()
+	config.Address = server.URL
+	config.Token = "root"
+	client, err := vault.NewClient(config)
+	assert.Nil(t, err)
+
+	creds := new(vault.Credentials)
+	rotated := make(chan struct{}, 1)
+	renewer := vault.NewRenewer(client)
+	err = renewer.Watch(context.Background(), "database/creds/app", func(s *vault.Secret) {
+		creds.Update(s)
+		select {
+		case rotated <- struct{}{}:
+		default:
+		}
+	})
+	assert.Nil(t, err)
+	<-rotated
+
+	d := &fakeDriver{names: make(chan string, 1)}
+	connector := creds.Connector(d, func(username, password string) string {
+		return username + ":" + password + "@tcp(127.0.0.1:3306)/app"
+	})
+	_, _ = connector.Connect(context.Background())
+	assert.Equal(t, <-d.names, "user-b:pass@tcp(127.0.0.1:3306)/app")
+
+	renewer.OnAppStart(nil)
+	defer renewer.OnAppStop(context.Background())
+
+	select {
+	case <-rotated:
+	case <-time.After(3 * time.Second):
+		t.Fatal("secret not rotated")
+	}
+	_, _ = connector.Connect(context.Background())
+	assert.Equal(t, <-d.names, "user-c:pass@tcp(127.0.0.1:3306)/app")
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	assert.Equal(t, v.renewals, 1)
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package StarterWebhook
+
+import (
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
+	"github.com/go-spring/spring-core/webhook"
+)
+
+func init() {
+	gs.Provide(webhook.NewReceiver, "${webhook}", "*?", "*?", "?")
+	gs.Object(new(endpoint)).Init(func(e *endpoint) {
+		gs.HandlePost(e.Receiver.Path(), e.Receiver.Handler())
+	})
+	gs.Provide(webhook.NewSender, "${webhook.delivery}", "?", "?").
+		Export((*gs.AppEvent)(nil))
+	gs.Provide((*webhook.Sender).Listener, (*webhook.Sender)(nil))
+	gs.Object(new(deliveryEndpoint)).
+		On(cond.OnProperty("webhook.delivery.http.enabled", cond.HavingValue("true"))).
+		Init(func(e *deliveryEndpoint) {
+			gs.GetMapping(e.Path, e.Sender.HandleDeadLetters)
+			gs.PostMapping(e.Path+"/{id}", e.Sender.HandleRedeliver)
+		})
+}
+
+// endpoint 在应用的 Web 服务器上注册 webhook 的接收地址。
+type endpoint struct {
+	Receiver *webhook.Receiver `autowire:""`
+}
+
+// deliveryEndpoint 通过 HTTP 接口查询发送失败的消息并重新发送。
+type deliveryEndpoint struct {
+	Sender *webhook.Sender `autowire:""`
+	Path   string          `value:"${webhook.delivery.http.path:=/webhook-deliveries/dead-letters}"`
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// configmeta 分析源码中通过 gs.Object 和 gs.Provide 注册的 bean 绑定的属性，生成 JSON
+// 格式的配置元数据文件，可供编辑器补全和配置校验使用。分析的范围包括 bean 结构体以及
+// 构造函数的结构体参数中 value 标签的字段 (递归分析嵌套的结构体)，和构造函数通过
+// "${key:=def}" 参数绑定的属性；没有注册为 bean 的结构体、通过 conf.Bind 手动绑定的
+// 属性以及构造函数不在分析目录中的 bean 不会出现在元数据中。推荐通过 go:generate 使用：
+//
+//	//go:generate go run github.com/go-spring/go-spring/tools/configmeta -o config-metadata.json .
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+var config struct {
+	Output string
+}
+
+func init() {
+	flag.StringVar(&config.Output, "o", "", "output file, default is stdout")
+}
+
+func main() {
+	flag.Parse()
+
+	dirs := flag.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	m, err := Analyze(dirs...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	b = append(b, '\n')
+
+	if config.Output == "" {
+		_, _ = os.Stdout.Write(b)
+		return
+	}
+	if err = ioutil.WriteFile(config.Output, b, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
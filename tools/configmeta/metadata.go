@@ -0,0 +1,396 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-spring/go-spring/tools/internal/astutil"
+)
+
+// Property 属性的元数据。
+type Property struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	DefaultValue string `json:"defaultValue,omitempty"`
+	HasDefault   bool   `json:"hasDefault"`
+	Description  string `json:"description,omitempty"`
+	SourceType   string `json:"sourceType"`
+}
+
+// Metadata 配置元数据文件的内容。
+type Metadata struct {
+	Properties []Property `json:"properties"`
+}
+
+// file 源文件及其所在的包名。
+type file struct {
+	pkg     string
+	imports map[string]string // 导入别名到包名的映射
+	ast     *ast.File
+}
+
+// structType 包内定义的结构体。
+type structType struct {
+	pkg  string
+	name string
+	file *file
+	node *ast.StructType
+}
+
+// funcType 包内定义的函数，用于解析 gs.Provide 的构造函数。
+type funcType struct {
+	name string
+	file *file
+	node *ast.FuncType
+}
+
+// analyzer 分析注册的 bean 通过 value 标签绑定的属性。
+type analyzer struct {
+	fset    *token.FileSet
+	files   []*file
+	structs map[string]*structType // key 为 pkg.name
+	funcs   map[string]*funcType   // key 为 pkg.name
+	props   map[string]Property
+}
+
+// Analyze 分析 dirs 目录下的源码，返回通过 gs.Object 和 gs.Provide 注册的 bean 绑定
+// 的属性的元数据，包括 bean 结构体的 value 标签字段，构造函数的结构体参数的 value 标签
+// 字段，以及构造函数通过 "${key}" 参数绑定的属性。没有注册为 bean 的结构体不会被分析。
+func Analyze(dirs ...string) (*Metadata, error) {
+	a := &analyzer{
+		fset:    token.NewFileSet(),
+		structs: make(map[string]*structType),
+		funcs:   make(map[string]*funcType),
+		props:   make(map[string]Property),
+	}
+	for _, dir := range dirs {
+		if err := a.parseDir(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	a.collect()
+	for _, f := range a.files {
+		ast.Inspect(f.ast, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				switch astutil.GsFunc(call) {
+				case "Object":
+					a.object(f, call)
+				case "Provide":
+					a.provide(f, call)
+				}
+			}
+			return true
+		})
+	}
+
+	m := &Metadata{Properties: []Property{}}
+	for _, p := range a.props {
+		m.Properties = append(m.Properties, p)
+	}
+	sort.Slice(m.Properties, func(i, j int) bool {
+		return m.Properties[i].Name < m.Properties[j].Name
+	})
+	return m, nil
+}
+
+func (a *analyzer) parseDir(dir string) error {
+	filter := func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}
+	pkgs, err := parser.ParseDir(a.fset, dir, filter, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		var names []string
+		for name := range pkg.Files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			f := pkg.Files[name]
+			imports := make(map[string]string)
+			for _, s := range f.Imports {
+				path, _ := strconv.Unquote(s.Path.Value)
+				alias := path[strings.LastIndex(path, "/")+1:]
+				if s.Name != nil {
+					alias = s.Name.Name
+				}
+				imports[alias] = path[strings.LastIndex(path, "/")+1:]
+			}
+			a.files = append(a.files, &file{pkg: pkg.Name, imports: imports, ast: f})
+		}
+	}
+	return nil
+}
+
+// collect 收集所有的结构体和函数定义。
+func (a *analyzer) collect() {
+	for _, f := range a.files {
+		for _, decl := range f.ast.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					ts, ok := spec.(*ast.TypeSpec)
+					if !ok {
+						continue
+					}
+					if st, ok := ts.Type.(*ast.StructType); ok {
+						key := f.pkg + "." + ts.Name.Name
+						a.structs[key] = &structType{pkg: f.pkg, name: ts.Name.Name, file: f, node: st}
+					}
+				}
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					key := f.pkg + "." + d.Name.Name
+					a.funcs[key] = &funcType{name: key, file: f, node: d.Type}
+				}
+			}
+		}
+	}
+}
+
+// typeKey 返回类型表达式的全限定名称，如 app.Config ，无法确定时返回空字符串。
+func (f *file) typeKey(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return f.pkg + "." + e.Name
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok {
+			if pkg, ok := f.imports[x.Name]; ok {
+				return pkg + "." + e.Sel.Name
+			}
+		}
+	}
+	return ""
+}
+
+// object 分析 gs.Object(&T{}) 和 gs.Object(new(T)) 注册的 bean 的字段。
+func (a *analyzer) object(f *file, call *ast.CallExpr) {
+	if len(call.Args) == 0 {
+		return
+	}
+	var typ ast.Expr
+	switch e := call.Args[0].(type) {
+	case *ast.UnaryExpr:
+		if lit, ok := e.X.(*ast.CompositeLit); ok && e.Op == token.AND {
+			typ = lit.Type
+		}
+	case *ast.CallExpr:
+		if ident, ok := e.Fun.(*ast.Ident); ok && ident.Name == "new" && len(e.Args) == 1 {
+			typ = e.Args[0]
+		}
+	}
+	if s, ok := a.structs[f.typeKey(typ)]; ok && typ != nil {
+		a.walk(s, "", map[string]bool{})
+	}
+}
+
+// provide 分析 gs.Provide(fn, args...) 注册的 bean ，包括构造函数返回的结构体的字段、
+// 结构体类型的参数绑定的字段，以及通过 "${key:=def}" 参数直接绑定的属性。与运行时相同，
+// 没有指定参数的结构体类型的参数绑定到根路径。
+func (a *analyzer) provide(f *file, call *ast.CallExpr) {
+	if len(call.Args) == 0 {
+		return
+	}
+	fn, ok := a.funcs[f.typeKey(call.Args[0])]
+	if !ok {
+		return
+	}
+
+	type param struct {
+		name string
+		typ  ast.Expr
+	}
+	var params []param
+	for _, p := range fn.node.Params.List {
+		if len(p.Names) == 0 {
+			params = append(params, param{strconv.Itoa(len(params)), p.Type})
+			continue
+		}
+		for _, name := range p.Names {
+			params = append(params, param{name.Name, p.Type})
+		}
+	}
+
+	for i, p := range params {
+		var arg string
+		if i+1 < len(call.Args) {
+			lit, ok := call.Args[i+1].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				continue
+			}
+			arg, _ = strconv.Unquote(lit.Value)
+		}
+		if s, ok := a.structs[fn.file.typeKey(p.typ)]; ok {
+			prefix := ""
+			if arg != "" {
+				if prefix, _, _, ok = parseTag(arg); !ok {
+					continue
+				}
+			}
+			a.walk(s, prefix, map[string]bool{})
+			continue
+		}
+		if !isValueType(p.typ) {
+			continue
+		}
+		name, def, hasDef, ok := parseTag(arg)
+		if !ok || name == "" {
+			continue
+		}
+		a.props[name] = Property{
+			Name:         name,
+			Type:         a.typeString(p.typ),
+			DefaultValue: def,
+			HasDefault:   hasDef,
+			SourceType:   fn.name + "." + p.name,
+		}
+	}
+
+	if results := fn.node.Results; results != nil && len(results.List) > 0 {
+		typ := results.List[0].Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		if s, ok := a.structs[fn.file.typeKey(typ)]; ok {
+			a.walk(s, "", map[string]bool{})
+		}
+	}
+}
+
+// isValueType 返回参数是否按照属性绑定，指针、接口、函数等类型按照 bean 注入。
+func isValueType(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return true
+	case *ast.SelectorExpr:
+		return true
+	case *ast.ArrayType:
+		return isValueType(e.Elt)
+	case *ast.MapType:
+		return true
+	}
+	return false
+}
+
+// walk 遍历结构体的字段，prefix 是结构体绑定的属性前缀。
+func (a *analyzer) walk(s *structType, prefix string, visiting map[string]bool) {
+
+	key := s.pkg + "." + s.name
+	if visiting[key] {
+		return
+	}
+	visiting[key] = true
+	defer delete(visiting, key)
+
+	for _, f := range s.node.Fields.List {
+		tag, ok := valueTag(f)
+		if !ok {
+			continue
+		}
+		name, def, hasDef, ok := parseTag(tag)
+		if !ok {
+			continue
+		}
+		if prefix != "" && name != "" {
+			name = prefix + "." + name
+		} else if name == "" {
+			name = prefix
+		}
+		if nested, ok := a.structs[s.file.typeKey(f.Type)]; ok {
+			a.walk(nested, name, visiting)
+			continue
+		}
+		if name == "" {
+			continue
+		}
+		fieldName := "_"
+		if len(f.Names) > 0 {
+			fieldName = f.Names[0].Name
+		}
+		a.props[name] = Property{
+			Name:         name,
+			Type:         a.typeString(f.Type),
+			DefaultValue: def,
+			HasDefault:   hasDef,
+			Description:  description(f),
+			SourceType:   key + "." + fieldName,
+		}
+	}
+}
+
+func (a *analyzer) typeString(expr ast.Expr) string {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, a.fset, expr)
+	return buf.String()
+}
+
+// valueTag 返回字段的 value 标签。
+func valueTag(f *ast.Field) (string, bool) {
+	if f.Tag == nil {
+		return "", false
+	}
+	s, err := strconv.Unquote(f.Tag.Value)
+	if err != nil {
+		return "", false
+	}
+	return reflect.StructTag(s).Lookup("value")
+}
+
+// description 优先使用 desc 标签作为属性描述，其次使用字段的注释。
+func description(f *ast.Field) string {
+	if f.Tag != nil {
+		if s, err := strconv.Unquote(f.Tag.Value); err == nil {
+			if desc, ok := reflect.StructTag(s).Lookup("desc"); ok {
+				return desc
+			}
+		}
+	}
+	if f.Doc != nil {
+		return strings.TrimSpace(f.Doc.Text())
+	}
+	if f.Comment != nil {
+		return strings.TrimSpace(f.Comment.Text())
+	}
+	return ""
+}
+
+// parseTag 解析 ${key:=def}|split 格式的标签，与 conf.ParseTag 的语法保持一致。
+func parseTag(tag string) (key, def string, hasDef bool, ok bool) {
+	j := strings.LastIndex(tag, "}")
+	k := strings.Index(tag, "${")
+	if j <= 0 || k < 0 || k > j {
+		return "", "", false, false
+	}
+	ss := strings.SplitN(tag[k+2:j], ":=", 2)
+	if len(ss) > 1 {
+		return ss[0], ss[1], true, true
+	}
+	return ss[0], "", false, true
+}
@@ -0,0 +1,42 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	m, err := Analyze("testdata/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []Property{
+		{Name: "app.name", Type: "string", DefaultValue: "demo", HasDefault: true, Description: "应用名称", SourceType: "app.AppConfig.Name"},
+		{Name: "app.port", Type: "int", DefaultValue: "8080", HasDefault: true, SourceType: "app.NewApp.port"},
+		{Name: "app.tags", Type: "[]string", HasDefault: true, SourceType: "app.AppConfig.Tags"},
+		{Name: "cache.debug", Type: "bool", DefaultValue: "false", HasDefault: true, SourceType: "app.Cache.Debug"},
+		{Name: "cache.size", Type: "int", DefaultValue: "1024", HasDefault: true, SourceType: "app.CacheConfig.Size"},
+		{Name: "db.timeout", Type: "time.Duration", DefaultValue: "3s", HasDefault: true, Description: "connect timeout", SourceType: "app.DBConfig.Timeout"},
+		{Name: "db.url", Type: "string", Description: "数据库地址", SourceType: "app.DBConfig.URL"},
+		{Name: "web.prefix", Type: "string", DefaultValue: "/api", HasDefault: true, SourceType: "app.Controller.Prefix"},
+	}
+	if !reflect.DeepEqual(m.Properties, expect) {
+		t.Fatalf("got %v but expect %v", m.Properties, expect)
+	}
+}
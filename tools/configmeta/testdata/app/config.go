@@ -0,0 +1,53 @@
+package app
+
+import (
+	"time"
+
+	"github.com/go-spring/spring-core/gs"
+)
+
+func init() {
+	gs.Object(&Controller{})
+	gs.Provide(NewApp, "${}", "${app.port:=8080}")
+	gs.Provide(NewCache, "${cache}")
+}
+
+type DBConfig struct {
+	// 数据库地址
+	URL     string        `value:"${url}"`
+	Timeout time.Duration `value:"${timeout:=3s}" desc:"connect timeout"`
+}
+
+type AppConfig struct {
+	Name    string   `value:"${app.name:=demo}"` // 应用名称
+	Tags    []string `value:"${app.tags:=}"`
+	DB      DBConfig `value:"${db}"`
+	ignored int
+}
+
+type App struct{}
+
+func NewApp(config AppConfig, port int) *App {
+	return &App{}
+}
+
+type CacheConfig struct {
+	Size int `value:"${size:=1024}"`
+}
+
+type Cache struct {
+	Debug bool `value:"${cache.debug:=false}"`
+}
+
+func NewCache(config CacheConfig) *Cache {
+	return &Cache{}
+}
+
+type Controller struct {
+	Prefix string `value:"${web.prefix:=/api}"`
+}
+
+// UnusedConfig 没有被注册的 bean 使用，不会出现在元数据中。
+type UnusedConfig struct {
+	Level string `value:"${unused.level:=info}"`
+}
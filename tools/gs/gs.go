@@ -38,12 +38,22 @@ func init() {
 	log.SetFlags(log.Lshortfile)
 }
 
-const help = `(v0.0.2) command:
+const help = `(v0.0.3) command:
+  gs init module [dir]
+  gs add web/redis/gorm
+  gs report binary [args...]
   gs pull spring-*/starter-* [branch]
   gs push spring-*/starter-*
   gs remove spring-*/starter-*
   gs release tag`
 
+// userCommands 面向框架使用者的命令，在应用的工程目录下执行。
+var userCommands = map[string]func(){
+	"init":   initProject,
+	"add":    addStarter,
+	"report": report,
+}
+
 var commands = map[string]func(rootDir string){
 	"pull":    pull,
 	"push":    push,
@@ -78,6 +88,11 @@ func main() {
 	}()
 
 	cmd := arg(1)
+	if fn, ok := userCommands[cmd]; ok {
+		fn()
+		return
+	}
+
 	fn, ok := commands[cmd]
 	if !ok {
 		panic("error command " + cmd)
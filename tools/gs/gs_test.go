@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -73,3 +74,50 @@ require (
 	}
 	fmt.Println("test success")
 }
+
+func TestScaffold(t *testing.T) {
+	dir := t.TempDir()
+	if err := scaffold(dir, "github.com/foo/demo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := scaffold(dir, "github.com/foo/demo"); err == nil {
+		t.Fatal("should fail when go.mod exists")
+	}
+	if err := addStarterTo(dir, "web"); err != nil {
+		t.Fatal(err)
+	}
+	if err := addStarterTo(dir, "web"); err != nil {
+		t.Fatal(err)
+	}
+	if err := addStarterTo(dir, "mq"); err == nil {
+		t.Fatal("should fail for unknown starter")
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "main.go"))
+	if bytes.Count(b, []byte(`_ "github.com/go-spring/starter-gin"`)) != 1 {
+		t.Fatalf("unexpected main.go %s", b)
+	}
+	b, _ = ioutil.ReadFile(filepath.Join(dir, "config", "application.properties"))
+	expect := "spring.application.name=demo\nweb.server.port=8080\n"
+	if string(b) != expect {
+		t.Fatalf("got %q but expect %q", b, expect)
+	}
+}
+
+func TestPrintReport(t *testing.T) {
+	var buf bytes.Buffer
+	printReport(&buf, []beanReport{
+		{ID: "a", Source: "a.go:1", Status: "Wired", Depends: []string{"b"}},
+		{ID: "b", Source: "b.go:1", Status: "Wired"},
+		{ID: "c", Source: "c.go:1", Status: "Deleted", Reason: "condition not matched"},
+	})
+	expect := `bean graph:
+  a (a.go:1)
+    -> b
+  b (b.go:1)
+condition report:
+  c excluded: condition not matched (c.go:1)
+`
+	if buf.String() != expect {
+		t.Fatalf("got %q but expect %q", buf.String(), expect)
+	}
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// beanReport 与 gs.BeanReport 的 JSON 格式保持一致。
+type beanReport struct {
+	ID      string   `json:"id"`
+	Type    string   `json:"type"`
+	Source  string   `json:"source"`
+	Status  string   `json:"status"`
+	Reason  string   `json:"reason"`
+	Depends []string `json:"depends"`
+}
+
+// report 运行编译好的应用，打印 bean 依赖关系和条件判断报告
+func report() {
+
+	binary := arg(2)
+
+	dir, err := ioutil.TempDir("", "gs-report")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "report.json")
+	cmd := exec.Command(binary, os.Args[3:]...)
+	cmd.Env = append(os.Environ(), "GS_SPRING_REPORT_FILE="+file)
+	if b, err := cmd.CombinedOutput(); err != nil {
+		panic(fmt.Errorf("err %v with output %s", err, b))
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		panic(err)
+	}
+
+	var r struct {
+		Beans []beanReport `json:"beans"`
+	}
+	if err = json.Unmarshal(b, &r); err != nil {
+		panic(err)
+	}
+	printReport(os.Stdout, r.Beans)
+}
+
+// printReport 先打印 bean 的依赖关系，再打印被条件排除的 bean 。
+func printReport(w io.Writer, beans []beanReport) {
+
+	fmt.Fprintln(w, "bean graph:")
+	for _, b := range beans {
+		if b.Status == "Deleted" {
+			continue
+		}
+		fmt.Fprintf(w, "  %s (%s)\n", b.ID, b.Source)
+		for _, d := range b.Depends {
+			fmt.Fprintf(w, "    -> %s\n", d)
+		}
+	}
+
+	fmt.Fprintln(w, "condition report:")
+	for _, b := range beans {
+		if b.Status == "Deleted" {
+			fmt.Fprintf(w, "  %s excluded: %s (%s)\n", b.ID, b.Reason, b.Source)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const mainTemplate = `package main
+
+import (
+	"log"
+
+	"github.com/go-spring/spring-core/gs"
+)
+
+func main() {
+	if err := gs.Run(); err != nil {
+		log.Fatal(err)
+	}
+}
+`
+
+const modTemplate = `module %s
+
+go 1.14
+`
+
+// starter 启动器的导入路径和默认属性。
+type starter struct {
+	pkg   string
+	props map[string]string
+}
+
+var starters = map[string]starter{
+	"web": {
+		pkg:   "github.com/go-spring/starter-gin",
+		props: map[string]string{"web.server.port": "8080"},
+	},
+	"redis": {
+		pkg: "github.com/go-spring/starter-go-redis",
+		props: map[string]string{
+			"redis.host": "127.0.0.1",
+			"redis.port": "6379",
+		},
+	},
+	"gorm": {
+		pkg:   "github.com/go-spring/starter-gorm/mysql",
+		props: map[string]string{"gorm.url": "root:root@/test?charset=utf8&parseTime=True&loc=Local"},
+	},
+}
+
+// initProject 创建一个新的应用工程
+func initProject() {
+
+	module := arg(2)
+	dir := path.Base(module)
+	if len(os.Args) > 3 {
+		dir = os.Args[3]
+	}
+
+	if err := scaffold(dir, module); err != nil {
+		panic(err)
+	}
+	fmt.Printf("project %s created in %s, run `go mod tidy` to download dependencies\n", module, dir)
+}
+
+// scaffold 在 dir 目录下生成 main.go、go.mod 以及 config 目录。
+func scaffold(dir, module string) error {
+
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+		return fmt.Errorf("%s already exists", filepath.Join(dir, "go.mod"))
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "config"), os.ModePerm); err != nil {
+		return err
+	}
+
+	files := map[string]string{
+		"go.mod":  fmt.Sprintf(modTemplate, module),
+		"main.go": mainTemplate,
+		filepath.Join("config", "application.properties"): "spring.application.name=" + path.Base(module) + "\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addStarter 为当前工程添加启动器
+func addStarter() {
+	name := arg(2)
+	if err := addStarterTo(".", name); err != nil {
+		panic(err)
+	}
+	fmt.Printf("starter %s added, run `go mod tidy` to download dependencies\n", name)
+}
+
+// addStarterTo 在 main.go 中导入启动器并在配置文件中添加启动器的默认属性。
+func addStarterTo(dir, name string) error {
+
+	s, ok := starters[name]
+	if !ok {
+		var names []string
+		for k := range starters {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown starter %q, should be one of %v", name, names)
+	}
+
+	mainFile := filepath.Join(dir, "main.go")
+	b, err := ioutil.ReadFile(mainFile)
+	if err != nil {
+		return err
+	}
+
+	importLine := fmt.Sprintf("_ %q", s.pkg)
+	if !bytes.Contains(b, []byte(importLine)) {
+		i := bytes.Index(b, []byte("import ("))
+		if i < 0 {
+			return fmt.Errorf("can't find import block in %s", mainFile)
+		}
+		i += len("import (")
+		b = append(b[:i:i], append([]byte("\n\t"+importLine), b[i:]...)...)
+		if b, err = format.Source(b); err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(mainFile, b, 0644); err != nil {
+			return err
+		}
+	}
+
+	propFile := filepath.Join(dir, "config", "application.properties")
+	b, err = ioutil.ReadFile(propFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var keys []string
+	for k := range s.props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf := bytes.NewBuffer(b)
+	for _, k := range keys {
+		if strings.Contains(string(b), "\n"+k+"=") || strings.HasPrefix(string(b), k+"=") {
+			continue
+		}
+		if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(k + "=" + s.props[k] + "\n")
+	}
+	if err = os.MkdirAll(filepath.Dir(propFile), os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(propFile, buf.Bytes(), 0644)
+}
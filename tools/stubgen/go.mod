@@ -0,0 +1,3 @@
+module github.com/go-spring/go-spring/tools/stubgen
+
+go 1.14
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// stubgen 为包内的接口生成桩实现，桩实现的方法只记录调用并返回零值。生成的桩实现
+// 以 FallbackFor 的方式注册，只有 spring.stub.missing-beans 属性为 true 并且接口
+// 没有其他实现时才会被注入，这样只完成了部分功能的应用也可以在开发环境下启动。推荐
+// 通过 go:generate 使用：
+//
+//	//go:generate go run github.com/go-spring/go-spring/tools/stubgen -type UserService,OrderService
+//
+// 不指定 -type 时为包内所有导出的接口生成桩实现。接口中嵌入的其他接口的方法没有桩
+// 实现，调用时会因为空指针而 panic 。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OutputFile 生成的代码文件名。
+const OutputFile = "zz_gs_stub.go"
+
+func main() {
+	types := flag.String("type", "", "comma separated interface names")
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	var names []string
+	for _, s := range strings.Split(*types, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			names = append(names, s)
+		}
+	}
+
+	b, err := Generate(dir, names)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(dir, OutputFile), b, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
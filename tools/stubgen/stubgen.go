@@ -0,0 +1,274 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 生成代码使用的包，接口方法的签名不能引用同名的包。
+var stubImports = map[string]string{
+	"cond": "github.com/go-spring/spring-core/gs/cond",
+	"gs":   "github.com/go-spring/spring-core/gs",
+	"log":  "github.com/go-spring/spring-base/log",
+}
+
+// iface 需要生成桩实现的接口。
+type iface struct {
+	name string
+	typ  *ast.InterfaceType
+	file *ast.File
+}
+
+// generator 分析包内的接口并生成桩实现。
+type generator struct {
+	fset    *token.FileSet
+	pkg     string
+	ifaces  []*iface
+	imports map[string]string // 方法签名引用的包
+}
+
+// Generate 为 dir 目录下的接口生成桩实现，names 为空时为所有导出的接口生成。
+func Generate(dir string, names []string) ([]byte, error) {
+
+	g := &generator{
+		fset:    token.NewFileSet(),
+		imports: make(map[string]string),
+	}
+
+	filter := func(fi os.FileInfo) bool {
+		name := fi.Name()
+		return !strings.HasSuffix(name, "_test.go") && name != OutputFile
+	}
+	pkgs, err := parser.ParseDir(g.fset, dir, filter, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("found %d packages in %s", len(pkgs), dir)
+	}
+
+	found := make(map[string]*iface)
+	for _, pkg := range pkgs {
+		g.pkg = pkg.Name
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				d, ok := decl.(*ast.GenDecl)
+				if !ok || d.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range d.Specs {
+					ts := spec.(*ast.TypeSpec)
+					if it, ok := ts.Type.(*ast.InterfaceType); ok {
+						found[ts.Name.Name] = &iface{name: ts.Name.Name, typ: it, file: file}
+					}
+				}
+			}
+		}
+	}
+
+	if len(names) == 0 {
+		for name := range found {
+			if ast.IsExported(name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		i, ok := found[name]
+		if !ok {
+			return nil, fmt.Errorf("can't find interface %s in %s", name, dir)
+		}
+		g.ifaces = append(g.ifaces, i)
+	}
+	if len(g.ifaces) == 0 {
+		return nil, fmt.Errorf("no interface found in %s", dir)
+	}
+
+	return g.emit()
+}
+
+func (g *generator) typeString(expr ast.Expr) string {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, g.fset, expr)
+	return buf.String()
+}
+
+// importName 返回导入的包在文件中使用的名称，未指定别名时使用路径的最后一段。
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	s, _ := strconv.Unquote(spec.Path.Value)
+	name := path.Base(s)
+	if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = path.Base(path.Dir(s))
+	}
+	if i := strings.Index(name, ".v"); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// resolveImports 记录类型表达式引用的包。
+func (g *generator) resolveImports(i *iface, expr ast.Expr) error {
+	var err error
+	ast.Inspect(expr, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok || err != nil {
+			return err == nil
+		}
+		x, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if _, ok = stubImports[x.Name]; ok {
+			err = fmt.Errorf("interface %s: package name %s conflicts with generated code", i.name, x.Name)
+			return false
+		}
+		for _, spec := range i.file.Imports {
+			if importName(spec) == x.Name {
+				s, _ := strconv.Unquote(spec.Path.Value)
+				if spec.Name != nil {
+					s = spec.Name.Name + " " + strconv.Quote(s)
+				} else {
+					s = strconv.Quote(s)
+				}
+				g.imports[x.Name] = s
+				return false
+			}
+		}
+		err = fmt.Errorf("interface %s: can't resolve package %s", i.name, x.Name)
+		return false
+	})
+	return err
+}
+
+// fieldList 返回方法的参数或者返回值列表，参数名统一使用 _ ，返回值依次命名为
+// r0 、r1 等，这样无需知道具体类型就可以返回零值。
+func (g *generator) fieldList(i *iface, list *ast.FieldList, result bool) ([]string, error) {
+	if list == nil {
+		return nil, nil
+	}
+	var ret []string
+	for _, f := range list.List {
+		if err := g.resolveImports(i, f.Type); err != nil {
+			return nil, err
+		}
+		n := len(f.Names)
+		if n == 0 {
+			n = 1
+		}
+		for j := 0; j < n; j++ {
+			name := "_"
+			if result {
+				name = fmt.Sprintf("r%d", len(ret))
+			}
+			ret = append(ret, name+" "+g.typeString(f.Type))
+		}
+	}
+	return ret, nil
+}
+
+// emit 生成所有接口的桩实现以及注册代码。
+func (g *generator) emit() ([]byte, error) {
+
+	var body bytes.Buffer
+	for _, i := range g.ifaces {
+		stub := "stub" + strings.Title(i.name)
+		fmt.Fprintf(&body, "// %s %s 的桩实现，所有方法只记录调用并返回零值。\n", stub, i.name)
+		fmt.Fprintf(&body, "type %s struct {\n\t%s\n}\n\n", stub, i.name)
+		for _, m := range i.typ.Methods.List {
+			ft, ok := m.Type.(*ast.FuncType)
+			if !ok || len(m.Names) == 0 {
+				continue // 嵌入的接口
+			}
+			params, err := g.fieldList(i, ft.Params, false)
+			if err != nil {
+				return nil, err
+			}
+			results, err := g.fieldList(i, ft.Results, true)
+			if err != nil {
+				return nil, err
+			}
+			name := m.Names[0].Name
+			fmt.Fprintf(&body, "func (s *%s) %s(%s) ", stub, name, strings.Join(params, ", "))
+			if len(results) > 0 {
+				fmt.Fprintf(&body, "(%s) ", strings.Join(results, ", "))
+			}
+			fmt.Fprintf(&body, "{\n\tlog.Warnf(\"stub %s.%s called\")\n", i.name, name)
+			if len(results) > 0 {
+				fmt.Fprintf(&body, "\treturn\n")
+			}
+			fmt.Fprintf(&body, "}\n\n")
+		}
+	}
+
+	fmt.Fprintf(&body, "func init() {\n")
+	for _, i := range g.ifaces {
+		fmt.Fprintf(&body, "\tgs.Object(new(stub%s)).\n", strings.Title(i.name))
+		fmt.Fprintf(&body, "\t\tFallbackFor((*%s)(nil)).\n", i.name)
+		fmt.Fprintf(&body, "\t\tOn(cond.OnProperty(gs.SpringStubMissingBeans, cond.HavingValue(\"true\"))).\n")
+		fmt.Fprintf(&body, "\t\tTag(gs.StubTag)\n")
+	}
+	fmt.Fprintf(&body, "}\n")
+
+	for name, s := range stubImports {
+		g.imports[name] = strconv.Quote(s)
+	}
+	// 标准库的包和其他包分为两组。
+	var std, others []string
+	for _, s := range g.imports {
+		p := s[strings.Index(s, "\"")+1:]
+		if strings.Contains(strings.Split(p, "/")[0], ".") {
+			others = append(others, s)
+		} else {
+			std = append(std, s)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(others)
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by stubgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\nimport (\n", g.pkg)
+	for _, s := range std {
+		fmt.Fprintf(&out, "\t%s\n", s)
+	}
+	if len(std) > 0 {
+		fmt.Fprintf(&out, "\n")
+	}
+	for _, s := range others {
+		fmt.Fprintf(&out, "\t%s\n", s)
+	}
+	fmt.Fprintf(&out, ")\n\n")
+	out.Write(body.Bytes())
+	return format.Source(out.Bytes())
+}
@@ -0,0 +1,43 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	b, err := Generate("testdata/app", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, err := ioutil.ReadFile("testdata/app/zz_gs_stub.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != string(expect) {
+		t.Fatalf("got\n%s\nbut expect\n%s", b, expect)
+	}
+}
+
+func TestGenerateUnknownType(t *testing.T) {
+	_, err := Generate("testdata/app", []string{"OrderService"})
+	if err == nil || err.Error() != "can't find interface OrderService in testdata/app" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
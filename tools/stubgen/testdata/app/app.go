@@ -0,0 +1,27 @@
+package app
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+type User struct {
+	ID   int64
+	Name string
+}
+
+type UserService interface {
+	Get(ctx context.Context, id int64) (*User, error)
+	List(ctx context.Context, ids ...int64) ([]*User, error)
+	Export(w io.Writer, format string)
+}
+
+type Notifier interface {
+	io.Closer
+	Notify(r *http.Request, to, msg string) error
+}
+
+type internalCache interface {
+	Get(key string) string
+}
@@ -0,0 +1,542 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-spring/go-spring/tools/internal/astutil"
+)
+
+// Diagnostic 检查发现的问题。
+type Diagnostic struct {
+	Pos     token.Position
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Pos, d.Message)
+}
+
+// file 源文件及其所在的包名。
+type file struct {
+	pkg     string
+	imports map[string]string // 导入别名到包名的映射
+	ast     *ast.File
+}
+
+// bean 通过 gs.Object 或者 gs.Provide 注册的 bean 。
+type bean struct {
+	typ     string // bean 的类型，如 *app.Service ，无法确定时为空
+	name    string
+	cond    bool // 是否设置了条件
+	exports []string
+	file    *file
+	call    *ast.CallExpr
+}
+
+// checker 保存分析过程中收集到的类型和 bean 信息。
+type checker struct {
+	fset       *token.FileSet
+	files      []*file
+	structs    map[string]*ast.StructType
+	interfaces map[string][]string
+	methods    map[string]map[string]bool
+	funcs      map[string]*ast.FuncType
+	beans      []*bean
+	props      properties
+	diags      []Diagnostic
+}
+
+// Check 检查 dirs 目录下的 bean 注册代码，dir 以 /... 结尾时包含所有子目录。
+// confDirs 为空时不检查属性是否存在。
+func Check(confDirs []string, dirs ...string) ([]Diagnostic, error) {
+
+	c := &checker{
+		fset:       token.NewFileSet(),
+		structs:    make(map[string]*ast.StructType),
+		interfaces: make(map[string][]string),
+		methods:    make(map[string]map[string]bool),
+		funcs:      make(map[string]*ast.FuncType),
+	}
+
+	if len(confDirs) > 0 {
+		p, err := loadProperties(confDirs)
+		if err != nil {
+			return nil, err
+		}
+		c.props = p
+	}
+
+	pkgDirs, err := expandDirs(dirs)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range pkgDirs {
+		if err = c.parseDir(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	c.collect()
+	c.check()
+
+	sort.SliceStable(c.diags, func(i, j int) bool {
+		a, b := c.diags[i].Pos, c.diags[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Line < b.Line
+	})
+	return c.diags, nil
+}
+
+func expandDirs(dirs []string) ([]string, error) {
+	var ret []string
+	for _, dir := range dirs {
+		if !strings.HasSuffix(dir, "/...") {
+			ret = append(ret, dir)
+			continue
+		}
+		root := strings.TrimSuffix(dir, "/...")
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				return nil
+			}
+			name := info.Name()
+			if path != root && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			ret = append(ret, path)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func (c *checker) parseDir(dir string) error {
+	filter := func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}
+	pkgs, err := parser.ParseDir(c.fset, dir, filter, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		var names []string
+		for name := range pkg.Files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			f := pkg.Files[name]
+			imports := make(map[string]string)
+			for _, s := range f.Imports {
+				path, _ := strconv.Unquote(s.Path.Value)
+				alias := path[strings.LastIndex(path, "/")+1:]
+				if s.Name != nil {
+					alias = s.Name.Name
+				}
+				imports[alias] = path[strings.LastIndex(path, "/")+1:]
+			}
+			c.files = append(c.files, &file{pkg: pkg.Name, imports: imports, ast: f})
+		}
+	}
+	return nil
+}
+
+// typeKey 返回类型表达式的全限定名称，如 *app.Service ，无法确定时返回空字符串。
+func (f *file) typeKey(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if !isBuiltin(e.Name) {
+			return f.pkg + "." + e.Name
+		}
+		return e.Name
+	case *ast.StarExpr:
+		if s := f.typeKey(e.X); s != "" {
+			return "*" + s
+		}
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok {
+			if pkg, ok := f.imports[x.Name]; ok {
+				return pkg + "." + e.Sel.Name
+			}
+		}
+	}
+	return ""
+}
+
+func isBuiltin(name string) bool {
+	switch name {
+	case "bool", "string", "error", "byte", "rune", "int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "float32", "float64",
+		"complex64", "complex128":
+		return true
+	}
+	return false
+}
+
+// collect 收集所有的类型定义、函数和 bean 注册。
+func (c *checker) collect() {
+
+	for _, f := range c.files {
+		for _, decl := range f.ast.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					ts, ok := spec.(*ast.TypeSpec)
+					if !ok {
+						continue
+					}
+					key := f.pkg + "." + ts.Name.Name
+					switch t := ts.Type.(type) {
+					case *ast.StructType:
+						c.structs[key] = t
+					case *ast.InterfaceType:
+						var methods []string
+						for _, m := range t.Methods.List {
+							for _, name := range m.Names {
+								methods = append(methods, name.Name)
+							}
+						}
+						c.interfaces[key] = methods
+					}
+				}
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					c.funcs[f.pkg+"."+d.Name.Name] = d.Type
+					continue
+				}
+				recv := strings.TrimPrefix(f.typeKey(d.Recv.List[0].Type), "*")
+				if c.methods[recv] == nil {
+					c.methods[recv] = make(map[string]bool)
+				}
+				c.methods[recv][d.Name.Name] = true
+			}
+		}
+	}
+
+	for _, f := range c.files {
+		seen := make(map[*ast.CallExpr]bool)
+		ast.Inspect(f.ast, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || seen[call] {
+				return true
+			}
+			root, chain := unwind(call)
+			for _, m := range chain {
+				seen[m] = true
+			}
+			seen[root] = true
+			switch astutil.GsFunc(root) {
+			case "Object", "Provide":
+				c.beans = append(c.beans, c.newBean(f, root, chain))
+			case "Property":
+				if c.props != nil && len(root.Args) > 0 {
+					if lit, ok := root.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+						key, _ := strconv.Unquote(lit.Value)
+						c.props[key] = struct{}{}
+					}
+				}
+			}
+			return true
+		})
+	}
+}
+
+// unwind 展开 gs.Object(...).Name(...).On(...) 形式的链式调用。
+func unwind(call *ast.CallExpr) (root *ast.CallExpr, chain []*ast.CallExpr) {
+	for {
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return call, chain
+		}
+		x, ok := sel.X.(*ast.CallExpr)
+		if !ok {
+			return call, chain
+		}
+		chain = append(chain, call)
+		call = x
+	}
+}
+
+func (c *checker) newBean(f *file, call *ast.CallExpr, chain []*ast.CallExpr) *bean {
+
+	b := &bean{file: f, call: call}
+	if len(call.Args) > 0 {
+		switch e := call.Args[0].(type) {
+		case *ast.UnaryExpr:
+			if lit, ok := e.X.(*ast.CompositeLit); ok && e.Op == token.AND {
+				b.typ = "*" + f.typeKey(lit.Type)
+			}
+		case *ast.CallExpr:
+			if ident, ok := e.Fun.(*ast.Ident); ok && ident.Name == "new" && len(e.Args) == 1 {
+				b.typ = "*" + f.typeKey(e.Args[0])
+			}
+		case *ast.Ident, *ast.SelectorExpr:
+			if fn := c.funcs[f.typeKey(e)]; fn != nil && fn.Results != nil {
+				b.typ = f.typeKey(fn.Results.List[0].Type)
+			}
+		}
+	}
+	if b.typ == "*" {
+		b.typ = ""
+	}
+
+	s := strings.Split(b.typ, ".")
+	b.name = strings.TrimPrefix(s[len(s)-1], "*")
+
+	for _, m := range chain {
+		sel := m.Fun.(*ast.SelectorExpr)
+		switch sel.Sel.Name {
+		case "Name":
+			if lit, ok := m.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				b.name, _ = strconv.Unquote(lit.Value)
+			} else {
+				b.name = ""
+			}
+		case "On":
+			b.cond = true
+		case "Export":
+			for _, arg := range m.Args {
+				if p, ok := arg.(*ast.CallExpr); ok && len(p.Args) == 1 {
+					if paren, ok := p.Fun.(*ast.ParenExpr); ok {
+						if star, ok := paren.X.(*ast.StarExpr); ok {
+							b.exports = append(b.exports, f.typeKey(star.X))
+						}
+					}
+				}
+			}
+		}
+	}
+	return b
+}
+
+func (c *checker) report(pos token.Pos, format string, args ...interface{}) {
+	c.diags = append(c.diags, Diagnostic{Pos: c.fset.Position(pos), Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) check() {
+
+	// 没有设置条件的 bean 的类型和名称都相同时运行时会报告重复的 bean 。
+	ids := make(map[string]*bean)
+	for _, b := range c.beans {
+		if b.typ == "" || b.name == "" || b.cond {
+			continue
+		}
+		id := b.typ + ":" + b.name
+		if d, ok := ids[id]; ok {
+			c.report(b.call.Pos(), "duplicate bean name %q of type %s, first registered at %s",
+				b.name, b.typ, c.fset.Position(d.call.Pos()))
+			continue
+		}
+		ids[id] = b
+	}
+
+	for _, b := range c.beans {
+		if b.typ == "" {
+			continue
+		}
+		if astutil.GsFunc(b.call) == "Provide" {
+			c.checkParams(b)
+			c.checkArgs(b)
+		}
+		if st, ok := c.structs[strings.TrimPrefix(b.typ, "*")]; ok {
+			c.checkStruct(b, st, "", make(map[*ast.StructType]bool))
+		}
+	}
+}
+
+// checkParams 检查没有指定参数的构造函数的参数是否都有提供者。
+func (c *checker) checkParams(b *bean) {
+	if len(b.call.Args) > 1 {
+		return
+	}
+	fn := c.funcs[b.file.typeKey(b.call.Args[0])]
+	if fn == nil {
+		return
+	}
+	for _, p := range fn.Params.List {
+		key := b.file.typeKey(p.Type)
+		if c.known(key) && len(c.providers(key)) == 0 {
+			c.report(b.call.Pos(), "no bean provides %s for constructor of %s", key, b.typ)
+		}
+	}
+}
+
+// checkArgs 检查构造函数参数中的属性引用。
+func (c *checker) checkArgs(b *bean) {
+	for _, arg := range b.call.Args[1:] {
+		if lit, ok := arg.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			s, _ := strconv.Unquote(lit.Value)
+			c.checkProperty(lit.Pos(), s, "")
+		}
+	}
+}
+
+// checkStruct 检查结构体字段的 autowire 和 value 标签。
+func (c *checker) checkStruct(b *bean, st *ast.StructType, prefix string, visited map[*ast.StructType]bool) {
+
+	if visited[st] {
+		return
+	}
+	visited[st] = true
+	defer delete(visited, st)
+
+	for _, f := range st.Fields.List {
+		if f.Tag == nil {
+			continue
+		}
+		s, _ := strconv.Unquote(f.Tag.Value)
+		tag := reflect.StructTag(s)
+
+		if v, ok := tag.Lookup("value"); ok {
+			key := c.checkProperty(f.Pos(), v, prefix)
+			if nested, ok := c.structs[b.file.typeKey(f.Type)]; ok {
+				c.checkStruct(b, nested, key, visited)
+			}
+			continue
+		}
+
+		v, ok := tag.Lookup("autowire")
+		if !ok {
+			v, ok = tag.Lookup("inject")
+		}
+		if ok {
+			c.checkAutowire(b, f, v)
+		}
+	}
+}
+
+// checkAutowire 检查自动注入的字段是否有提供者。
+func (c *checker) checkAutowire(b *bean, f *ast.Field, v string) {
+
+	if strings.HasSuffix(v, "?") || strings.Contains(v, "${") || strings.Contains(v, ",") {
+		return
+	}
+
+	key := b.file.typeKey(f.Type)
+	if !c.known(key) {
+		return
+	}
+
+	var fieldName string
+	if len(f.Names) > 0 {
+		fieldName = f.Names[0].Name
+	}
+
+	providers := c.providers(key)
+	if len(providers) == 0 {
+		c.report(f.Pos(), "no bean provides %s for field %s of %s", key, fieldName, b.typ)
+		return
+	}
+
+	if i := strings.Index(v, ":"); i >= 0 {
+		v = v[i+1:]
+	}
+	if v == "" {
+		return
+	}
+	for _, p := range providers {
+		if p.name == v || p.name == "" {
+			return
+		}
+	}
+	c.report(f.Pos(), "no bean named %q provides %s for field %s of %s", v, key, fieldName, b.typ)
+}
+
+// known 类型是被分析的包中定义的结构体指针或者接口时返回 true 。
+func (c *checker) known(key string) bool {
+	if _, ok := c.interfaces[key]; ok {
+		return true
+	}
+	_, ok := c.structs[strings.TrimPrefix(key, "*")]
+	return strings.HasPrefix(key, "*") && ok
+}
+
+// providers 返回能够注入到指定类型的所有 bean 。
+func (c *checker) providers(key string) []*bean {
+	var ret []*bean
+	methods, isInterface := c.interfaces[key]
+	for _, b := range c.beans {
+		if b.typ == "" {
+			continue
+		}
+		match := b.typ == key
+		for _, e := range b.exports {
+			match = match || e == key
+		}
+		if !match && isInterface {
+			match = c.implements(b.typ, methods)
+		}
+		if match {
+			ret = append(ret, b)
+		}
+	}
+	return ret
+}
+
+// implements 根据方法名判断类型是否实现了接口。
+func (c *checker) implements(typ string, methods []string) bool {
+	set := c.methods[strings.TrimPrefix(typ, "*")]
+	for _, m := range methods {
+		if !set[m] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkProperty 检查 ${key} 引用的属性是否存在，返回完整的属性名。
+func (c *checker) checkProperty(pos token.Pos, tag string, prefix string) string {
+	if !strings.HasPrefix(tag, "${") || !strings.HasSuffix(tag, "}") {
+		return ""
+	}
+	key := tag[2 : len(tag)-1]
+	hasDefault := false
+	if i := strings.Index(key, ":="); i >= 0 {
+		key, hasDefault = key[:i], true
+	}
+	if prefix != "" && key != "" {
+		key = prefix + "." + key
+	} else if key == "" {
+		key = prefix
+	}
+	if c.props == nil || hasDefault || key == "" || strings.Contains(key, "${") {
+		return key
+	}
+	if !c.props.has(key) {
+		c.report(pos, "property %q not found", key)
+	}
+	return key
+}
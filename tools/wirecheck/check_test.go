@@ -0,0 +1,67 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+
+	messages := func(confDirs []string) []string {
+		diags, err := Check(confDirs, "testdata/app/...")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ret []string
+		for _, d := range diags {
+			ret = append(ret, d.Message)
+		}
+		return ret
+	}
+
+	expect := []string{
+		`duplicate bean name "backup" of type *app.Dao, first registered at testdata/app/app.go:11:2`,
+		`no bean provides *app.Cache for constructor of *app.Service`,
+		`no bean named "master" provides *app.Dao for field Master of *app.Controller`,
+		`no bean provides app.Writer for field Writer of *app.Controller`,
+	}
+	if got := messages(nil); !reflect.DeepEqual(got, expect) {
+		t.Fatalf("got %q but expect %q", got, expect)
+	}
+
+	expect = append(expect[:2:2], append([]string{`property "db.user" not found`}, expect[2:]...)...)
+	if got := messages([]string{"testdata/conf"}); !reflect.DeepEqual(got, expect) {
+		t.Fatalf("got %q but expect %q", got, expect)
+	}
+}
+
+func TestReadYaml(t *testing.T) {
+	p := make(properties)
+	if err := readYaml("testdata/conf/app.yaml", p); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"db", "db.url", "server.port"} {
+		if !p.has(key) {
+			t.Fatalf("property %q not found", key)
+		}
+	}
+	if p.has("db.user") {
+		t.Fatal("unexpected property db.user")
+	}
+}
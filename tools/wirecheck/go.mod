@@ -0,0 +1,7 @@
+module github.com/go-spring/go-spring/tools/wirecheck
+
+go 1.14
+
+require github.com/go-spring/go-spring/tools/internal v0.0.0
+
+replace github.com/go-spring/go-spring/tools/internal => ../internal
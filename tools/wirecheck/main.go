@@ -0,0 +1,66 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// wirecheck 静态检查 bean 注册代码中的常见错误，包括没有提供者的自动注入字段和构造
+// 函数参数，重复的 bean 名称，以及配置文件中不存在的属性，在运行时 panic 之前让构建
+// 失败。使用方式类似 go vet ，发现问题时打印问题的位置并以非零状态码退出：
+//
+//	go run github.com/go-spring/go-spring/tools/wirecheck -conf config ./...
+//
+// 只有指定了 -conf 参数时才会检查属性是否存在。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var config struct {
+	Conf string
+}
+
+func init() {
+	flag.StringVar(&config.Conf, "conf", "", "comma-separated config dirs, properties are checked only when it's set")
+}
+
+func main() {
+	flag.Parse()
+
+	dirs := flag.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	var confDirs []string
+	if config.Conf != "" {
+		confDirs = strings.Split(config.Conf, ",")
+	}
+
+	diags, err := Check(confDirs, dirs...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	for _, d := range diags {
+		fmt.Fprintln(os.Stderr, d)
+	}
+	if len(diags) > 0 {
+		os.Exit(1)
+	}
+}
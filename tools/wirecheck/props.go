@@ -0,0 +1,119 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// properties 配置文件中定义的属性的集合。
+type properties map[string]struct{}
+
+// has 属性存在或者是某个属性的前缀时返回 true 。
+func (p properties) has(key string) bool {
+	if _, ok := p[key]; ok {
+		return true
+	}
+	for k := range p {
+		if strings.HasPrefix(k, key+".") || strings.HasPrefix(k, key+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// loadProperties 加载目录下所有 properties 和 yaml 格式的配置文件中的属性名。
+func loadProperties(dirs []string) (properties, error) {
+	p := make(properties)
+	for _, dir := range dirs {
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			file := filepath.Join(dir, info.Name())
+			switch filepath.Ext(file) {
+			case ".properties":
+				err = readProperties(file, p)
+			case ".yaml", ".yml":
+				err = readYaml(file, p)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return p, nil
+}
+
+func readLines(file string, fn func(line string)) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fn(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+func readProperties(file string, p properties) error {
+	return readLines(file, func(line string) {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			return
+		}
+		if i := strings.IndexAny(line, "=:"); i > 0 {
+			p[strings.TrimSpace(line[:i])] = struct{}{}
+		}
+	})
+}
+
+// readYaml 只解析 yaml 文件中键的层级关系，对于属性名检查来说已经足够。
+func readYaml(file string, p properties) error {
+	type level struct {
+		indent int
+		key    string
+	}
+	var stack []level
+	return readLines(file, func(line string) {
+		s := strings.TrimLeft(line, " ")
+		if s == "" || s[0] == '#' || strings.HasPrefix(s, "- ") || s == "-" {
+			return
+		}
+		i := strings.Index(s, ":")
+		if i <= 0 {
+			return
+		}
+		indent := len(line) - len(s)
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		key := strings.Trim(strings.TrimSpace(s[:i]), `"'`)
+		if len(stack) > 0 {
+			key = stack[len(stack)-1].key + "." + key
+		}
+		stack = append(stack, level{indent, key})
+		p[key] = struct{}{}
+	})
+}
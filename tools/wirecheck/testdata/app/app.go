@@ -0,0 +1,69 @@
+package app
+
+import (
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
+)
+
+func init() {
+	gs.Property("app.debug", true)
+	gs.Object(&Dao{})
+	gs.Object(&Dao{}).Name("backup")
+	gs.Object(&Dao{}).Name("backup")
+	gs.Object(&Dao{}).Name("backup").On(cond.OnProfile("test"))
+	gs.Provide(NewService)
+	gs.Provide(NewServer, "${server.port}")
+	gs.Object(&Controller{})
+	gs.Object(&Handler{}).Export((*Logger)(nil))
+}
+
+type Logger interface {
+	Log(msg string)
+}
+
+type Printer interface {
+	Print(msg string)
+}
+
+type Writer interface {
+	Write(b []byte)
+}
+
+type Handler struct{}
+
+func (h *Handler) Print(msg string) {}
+
+type Dao struct{}
+
+type Cache struct{}
+
+type Service struct{}
+
+func NewService(dao *Dao, cache *Cache) *Service {
+	return &Service{}
+}
+
+type Server struct{}
+
+func NewServer(port int) *Server {
+	return &Server{}
+}
+
+type DBConfig struct {
+	URL     string `value:"${url}"`
+	Timeout int    `value:"${timeout:=3}"`
+	User    string `value:"${user}"`
+}
+
+type Controller struct {
+	Service *Service `autowire:""`
+	Backup  *Dao     `autowire:"backup"`
+	Master  *Dao     `autowire:"master"`
+	Cache   *Cache   `autowire:"?"`
+	Logger  Logger   `autowire:""`
+	Printer Printer  `autowire:""`
+	Writer  Writer   `autowire:""`
+	Debug   bool     `value:"${app.debug}"`
+	Name    string   `value:"${app.name}"`
+	DB      DBConfig `value:"${db}"`
+}
@@ -0,0 +1,3 @@
+module github.com/go-spring/go-spring/tools/wiregen
+
+go 1.14
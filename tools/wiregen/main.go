@@ -0,0 +1,67 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// wiregen 分析包内通过 gs.Object 和 gs.Provide 注册的 bean，生成不使用反射的静态
+// 注入代码。生成的 NewStaticBeans 函数只有在指定 gs_static 构建标签时才会参与编译，
+// 容器本身不会使用它，应用需要在同样以 gs_static 构建标签区分的入口文件中调用它来
+// 代替 gs.Run ，从而在构建时选择使用反射注入还是静态注入。推荐通过 go:generate 使用：
+//
+//	//go:generate go run github.com/go-spring/go-spring/tools/wiregen
+//
+// 静态注入的入口文件，例如 main_static.go ，反射注入的入口文件使用 !gs_static 标签：
+//
+//	//go:build gs_static
+//
+//	func main() {
+//		beans, err := app.NewStaticBeans()
+//		...
+//	}
+//
+// 目前只支持 &T{}、new(T) 形式的对象，本包内无额外参数的构造函数，按类型注入的
+// autowire:"" 字段，以及链式调用 Name 和 Init ；Destroy 、On 、Export 等其他链式调用
+// 以及属性绑定无法静态生成，遇到不支持的注册方式时会报错退出，而不是忽略它们。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// OutputFile 生成的代码文件名。
+const OutputFile = "zz_gs_static.go"
+
+func main() {
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	b, err := Generate(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(dir, OutputFile), b, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"bytes"
+
+	"github.com/go-spring/spring-core/gs"
+)
+
+//go:generate go run github.com/go-spring/go-spring/tools/wiregen
+
+func init() {
+	gs.Object(&Dao{}).Init(initDao)
+	gs.Provide(NewService)
+	gs.Object(new(Controller)).Init(func(c *Controller) {
+		c.ready = true
+	})
+	gs.Object(&bytes.Buffer{}).Name("log-buffer")
+}
+
+type Dao struct{}
+
+func initDao(dao *Dao) error {
+	return nil
+}
+
+type Service struct {
+	dao *Dao
+}
+
+func NewService(dao *Dao) (*Service, error) {
+	return &Service{dao: dao}, nil
+}
+
+type Controller struct {
+	Service *Service      `autowire:""`
+	Buffer  *bytes.Buffer `autowire:""`
+	dao     *Dao          `inject:""`
+	ready   bool
+}
@@ -0,0 +1,508 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// BuildTag 生成的代码只有在指定该构建标签时才会参与编译。
+const BuildTag = "gs_static"
+
+// field 需要注入的字段。
+type field struct {
+	name string
+	typ  string
+}
+
+// bean 通过 gs.Object 或者 gs.Provide 注册的 bean 。
+type bean struct {
+	typ     string   // bean 的类型，如 *Service
+	name    string   // 生成代码中的变量名
+	object  string   // Object 形式注册时的创建表达式
+	ctor    string   // Provide 形式注册时的构造函数
+	params  []string // 构造函数的参数类型
+	err     bool     // 构造函数是否返回 error
+	init    string   // Init 方法设置的初始化函数
+	initErr bool     // 初始化函数是否返回 error
+	pos     string   // 注册位置
+	created bool
+}
+
+// generator 分析包内的 bean 注册代码并生成静态注入代码。
+type generator struct {
+	fset    *token.FileSet
+	pkg     string
+	imports map[string]string // 导入别名到导入路径的映射
+	used    map[string]bool   // 生成代码用到的导入别名
+	structs map[string]*ast.StructType
+	funcs   map[string]*ast.FuncType
+	beans   []*bean
+}
+
+// Generate 分析 dir 目录下的 bean 注册代码，返回生成的静态注入代码。
+func Generate(dir string) ([]byte, error) {
+
+	g := &generator{
+		fset:    token.NewFileSet(),
+		imports: make(map[string]string),
+		used:    make(map[string]bool),
+		structs: make(map[string]*ast.StructType),
+		funcs:   make(map[string]*ast.FuncType),
+	}
+
+	filter := func(fi os.FileInfo) bool {
+		name := fi.Name()
+		return !strings.HasSuffix(name, "_test.go") && name != OutputFile
+	}
+	pkgs, err := parser.ParseDir(g.fset, dir, filter, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("found %d packages in %s", len(pkgs), dir)
+	}
+
+	var files []*ast.File
+	for _, pkg := range pkgs {
+		g.pkg = pkg.Name
+		var names []string
+		for name := range pkg.Files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			files = append(files, pkg.Files[name])
+		}
+	}
+
+	for _, file := range files {
+		for _, spec := range file.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			alias := path[strings.LastIndex(path, "/")+1:]
+			if spec.Name != nil {
+				alias = spec.Name.Name
+			}
+			g.imports[alias] = path
+		}
+		for _, decl := range file.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						if st, ok := ts.Type.(*ast.StructType); ok {
+							g.structs[ts.Name.Name] = st
+						}
+					}
+				}
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					g.funcs[d.Name.Name] = d.Type
+				}
+			}
+		}
+	}
+
+	for _, file := range files {
+		var err error
+		seen := make(map[*ast.CallExpr]bool)
+		ast.Inspect(file, func(n ast.Node) bool {
+			if err != nil {
+				return false
+			}
+			call, ok := n.(*ast.CallExpr)
+			if !ok || seen[call] {
+				return true
+			}
+			root, chain := unwind(call)
+			for _, c := range chain {
+				seen[c] = true
+			}
+			seen[root] = true
+			err = g.register(root, chain)
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return g.emit()
+}
+
+// unwind 展开 gs.Object(...).Name(...).Init(...) 形式的链式调用，chain 按照调用的
+// 顺序排列。
+func unwind(call *ast.CallExpr) (root *ast.CallExpr, chain []*ast.CallExpr) {
+	for {
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return call, chain
+		}
+		x, ok := sel.X.(*ast.CallExpr)
+		if !ok {
+			return call, chain
+		}
+		chain = append([]*ast.CallExpr{call}, chain...)
+		call = x
+	}
+}
+
+// register 记录 gs.Object 和 gs.Provide 形式的 bean 注册，链式调用只支持 Name 和
+// Init ，其他的链式调用无法在生成的代码中表达，直接报错。
+func (g *generator) register(call *ast.CallExpr, chain []*ast.CallExpr) error {
+
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	if x, ok := sel.X.(*ast.Ident); !ok || x.Name != "gs" {
+		return nil
+	}
+
+	pos := g.fset.Position(call.Pos()).String()
+	var b *bean
+	switch sel.Sel.Name {
+	case "Object":
+		if len(call.Args) != 1 {
+			return fmt.Errorf("%s: gs.Object needs exactly one argument", pos)
+		}
+		typ, expr, ok := g.objectType(call.Args[0])
+		if !ok {
+			return fmt.Errorf("%s: unsupported object expression", pos)
+		}
+		b = &bean{typ: typ, object: expr, pos: pos}
+	case "Provide":
+		if len(call.Args) == 0 {
+			return fmt.Errorf("%s: gs.Provide needs a constructor", pos)
+		}
+		if len(call.Args) > 1 {
+			return fmt.Errorf("%s: constructor arguments are not supported", pos)
+		}
+		ident, ok := call.Args[0].(*ast.Ident)
+		if !ok {
+			return fmt.Errorf("%s: constructor must be a function of this package", pos)
+		}
+		fn, ok := g.funcs[ident.Name]
+		if !ok || fn.Results == nil || len(fn.Results.List) == 0 {
+			return fmt.Errorf("%s: can't find constructor %s", pos, ident.Name)
+		}
+		b = &bean{typ: g.typeString(fn.Results.List[0].Type), ctor: ident.Name, pos: pos}
+		if n := len(fn.Results.List); n == 2 {
+			b.err = g.typeString(fn.Results.List[1].Type) == "error"
+		}
+		for _, p := range fn.Params.List {
+			n := len(p.Names)
+			if n == 0 {
+				n = 1
+			}
+			for i := 0; i < n; i++ {
+				b.params = append(b.params, g.typeString(p.Type))
+			}
+		}
+	default:
+		if len(chain) > 0 {
+			return fmt.Errorf("%s: gs.%s with chained calls is not supported", pos, sel.Sel.Name)
+		}
+		return nil
+	}
+
+	for _, c := range chain {
+		method := c.Fun.(*ast.SelectorExpr).Sel.Name
+		pos := g.fset.Position(c.Pos()).String()
+		switch method {
+		case "Name":
+			if len(c.Args) != 1 {
+				return fmt.Errorf("%s: Name needs exactly one argument", pos)
+			}
+			lit, ok := c.Args[0].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return fmt.Errorf("%s: bean name must be a string literal", pos)
+			}
+			name, _ := strconv.Unquote(lit.Value)
+			if b.name = identifier(name); b.name == "" {
+				return fmt.Errorf("%s: can't use bean name %q as an identifier", pos, name)
+			}
+		case "Init":
+			if len(c.Args) != 1 {
+				return fmt.Errorf("%s: Init needs exactly one argument", pos)
+			}
+			var fn *ast.FuncType
+			switch e := c.Args[0].(type) {
+			case *ast.FuncLit:
+				fn = e.Type
+			case *ast.Ident:
+				fn = g.funcs[e.Name]
+			}
+			if fn == nil {
+				return fmt.Errorf("%s: init function must be a function literal or a function of this package", pos)
+			}
+			b.init = g.typeString(c.Args[0])
+			if _, ok := c.Args[0].(*ast.FuncLit); ok {
+				b.init = "(" + b.init + ")"
+			}
+			if fn.Results != nil && len(fn.Results.List) > 0 {
+				b.initErr = g.typeString(fn.Results.List[0].Type) == "error"
+			}
+		default:
+			return fmt.Errorf("%s: %s of %s is not supported by static wiring", pos, method, b.typ)
+		}
+	}
+
+	g.beans = append(g.beans, b)
+	return nil
+}
+
+// identifier 将 bean 名称或者类型名称转换为导出的标识符，例如 *pkg.T 转换为 T ，
+// my-service 转换为 MyService ，无法转换时返回空字符串。
+func identifier(s string) string {
+	s = strings.TrimPrefix(s, "*")
+	if i := strings.LastIndex(s, "."); i >= 0 {
+		s = s[i+1:]
+	}
+	var sb strings.Builder
+	upper := true
+	for _, c := range s {
+		switch {
+		case c == '_' || c == '-' || c == ' ':
+			upper = true
+		case unicode.IsLetter(c) || (unicode.IsDigit(c) && sb.Len() > 0):
+			if upper {
+				c = unicode.ToUpper(c)
+				upper = false
+			}
+			sb.WriteRune(c)
+		default:
+			return ""
+		}
+	}
+	return sb.String()
+}
+
+// objectType 解析 &T{} 或者 new(T) 形式的对象表达式。
+func (g *generator) objectType(expr ast.Expr) (typ string, s string, ok bool) {
+	switch e := expr.(type) {
+	case *ast.UnaryExpr:
+		if lit, ok := e.X.(*ast.CompositeLit); ok && e.Op == token.AND {
+			return "*" + g.typeString(lit.Type), g.typeString(e), true
+		}
+	case *ast.CallExpr:
+		if ident, ok := e.Fun.(*ast.Ident); ok && ident.Name == "new" && len(e.Args) == 1 {
+			return "*" + g.typeString(e.Args[0]), g.typeString(e), true
+		}
+	}
+	return "", "", false
+}
+
+// typeString 返回表达式的源码，同时记录用到的导入包。
+func (g *generator) typeString(expr ast.Expr) string {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok {
+				if _, ok = g.imports[x.Name]; ok && x.Obj == nil {
+					g.used[x.Name] = true
+				}
+			}
+		}
+		return true
+	})
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, g.fset, expr)
+	return buf.String()
+}
+
+// fields 返回 bean 需要注入的字段，只支持 autowire:"" 形式的按类型注入。
+func (g *generator) fields(b *bean) ([]field, error) {
+	st, ok := g.structs[strings.TrimPrefix(b.typ, "*")]
+	if !ok || !strings.HasPrefix(b.typ, "*") {
+		return nil, nil
+	}
+	var ret []field
+	for _, f := range st.Fields.List {
+		if f.Tag == nil {
+			continue
+		}
+		s, _ := strconv.Unquote(f.Tag.Value)
+		tag := reflect.StructTag(s)
+		if _, ok := tag.Lookup("value"); ok {
+			return nil, fmt.Errorf("%s: property binding of %s is not supported", b.pos, b.typ)
+		}
+		v, ok := tag.Lookup("autowire")
+		if !ok {
+			v, ok = tag.Lookup("inject")
+		}
+		if !ok {
+			continue
+		}
+		if v != "" {
+			return nil, fmt.Errorf("%s: autowire tag %q of %s is not supported", b.pos, v, b.typ)
+		}
+		for _, name := range f.Names {
+			ret = append(ret, field{name: name.Name, typ: g.typeString(f.Type)})
+		}
+	}
+	return ret, nil
+}
+
+// find 返回指定类型的唯一 bean 。
+func (g *generator) find(typ string) (*bean, error) {
+	var found []*bean
+	for _, b := range g.beans {
+		if b.typ == typ {
+			found = append(found, b)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("can't find bean of type %s", typ)
+	case 1:
+		return found[0], nil
+	default:
+		return nil, fmt.Errorf("found %d beans of type %s", len(found), typ)
+	}
+}
+
+// emit 按照依赖关系生成创建 bean 和注入字段的代码。
+func (g *generator) emit() ([]byte, error) {
+
+	names := make(map[string]*bean)
+	for _, b := range g.beans {
+		if b.name == "" {
+			b.name = identifier(b.typ)
+		}
+		if b.name == "" {
+			return nil, fmt.Errorf("%s: can't generate identifier for %s", b.pos, b.typ)
+		}
+		if d, ok := names[b.name]; ok {
+			return nil, fmt.Errorf("%s: bean name %s conflicts with %s", b.pos, b.name, d.pos)
+		}
+		names[b.name] = b
+		if _, err := g.find(b.typ); err != nil {
+			return nil, fmt.Errorf("%s: %v", b.pos, err)
+		}
+	}
+
+	var body bytes.Buffer
+	var create func(b *bean, stack []*bean) error
+	create = func(b *bean, stack []*bean) error {
+		if b.created {
+			return nil
+		}
+		for _, s := range stack {
+			if s == b {
+				return fmt.Errorf("%s: found circle constructor dependency on %s", b.pos, b.typ)
+			}
+		}
+		var args []string
+		for _, p := range b.params {
+			d, err := g.find(p)
+			if err != nil {
+				return fmt.Errorf("%s: %v", b.pos, err)
+			}
+			if err = create(d, append(stack, b)); err != nil {
+				return err
+			}
+			args = append(args, "b."+d.name)
+		}
+		switch {
+		case b.object != "":
+			fmt.Fprintf(&body, "\tb.%s = %s\n", b.name, b.object)
+		case b.err:
+			fmt.Fprintf(&body, "\tif b.%s, err = %s(%s); err != nil {\n\t\treturn nil, err\n\t}\n", b.name, b.ctor, strings.Join(args, ", "))
+		default:
+			fmt.Fprintf(&body, "\tb.%s = %s(%s)\n", b.name, b.ctor, strings.Join(args, ", "))
+		}
+		b.created = true
+		return nil
+	}
+
+	for _, b := range g.beans {
+		if err := create(b, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, b := range g.beans {
+		fields, err := g.fields(b)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			d, err := g.find(f.typ)
+			if err != nil {
+				return nil, fmt.Errorf("%s: field %s of %s: %v", b.pos, f.name, b.typ, err)
+			}
+			fmt.Fprintf(&body, "\tb.%s.%s = b.%s\n", b.name, f.name, d.name)
+		}
+	}
+
+	// 和容器一样在完成注入之后按照注册顺序执行初始化函数。
+	for _, b := range g.beans {
+		switch {
+		case b.init == "":
+		case b.initErr:
+			fmt.Fprintf(&body, "\tif err = %s(b.%s); err != nil {\n\t\treturn nil, err\n\t}\n", b.init, b.name)
+		default:
+			fmt.Fprintf(&body, "\t%s(b.%s)\n", b.init, b.name)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by wiregen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "//go:build %s\n// +build %s\n\npackage %s\n\n", BuildTag, BuildTag, g.pkg)
+	if len(g.used) > 0 {
+		var aliases []string
+		for alias := range g.used {
+			aliases = append(aliases, alias)
+		}
+		sort.Strings(aliases)
+		fmt.Fprintf(&out, "import (\n")
+		for _, alias := range aliases {
+			path := g.imports[alias]
+			if alias == path[strings.LastIndex(path, "/")+1:] {
+				fmt.Fprintf(&out, "\t%q\n", path)
+			} else {
+				fmt.Fprintf(&out, "\t%s %q\n", alias, path)
+			}
+		}
+		fmt.Fprintf(&out, ")\n\n")
+	}
+	fmt.Fprintf(&out, "// StaticBeans 静态注入生成的所有 bean 。\ntype StaticBeans struct {\n")
+	for _, b := range g.beans {
+		fmt.Fprintf(&out, "\t%s %s\n", b.name, b.typ)
+	}
+	fmt.Fprintf(&out, "}\n\n")
+	fmt.Fprintf(&out, "// NewStaticBeans 不使用反射创建所有的 bean 并完成依赖注入。\n")
+	fmt.Fprintf(&out, "func NewStaticBeans() (*StaticBeans, error) {\n\tb := &StaticBeans{}\n")
+	if bytes.Contains(body.Bytes(), []byte("err != nil")) {
+		fmt.Fprintf(&out, "\tvar err error\n")
+	}
+	out.Write(body.Bytes())
+	fmt.Fprintf(&out, "\treturn b, nil\n}\n")
+	return format.Source(out.Bytes())
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	b, err := Generate("testdata/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, err := ioutil.ReadFile("testdata/app/zz_gs_static.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != string(expect) {
+		t.Fatalf("got\n%s\nbut expect\n%s", b, expect)
+	}
+}
+
+func TestGenerateError(t *testing.T) {
+	testcases := []struct {
+		src   string
+		error string
+	}{
+		{
+			src:   "gs.Object(&A{})\n}\ntype A struct{ B *B `autowire:\"\"` }\ntype B struct{}",
+			error: "can't find bean of type *B",
+		},
+		{
+			src:   "gs.Object(&A{})\ngs.Object(&A{})\n}\ntype A struct{}",
+			error: "found 2 beans of type *A",
+		},
+		{
+			src:   "gs.Object(&A{})\n}\ntype A struct{ N int `value:\"${n}\"` }",
+			error: "property binding of *A is not supported",
+		},
+		{
+			src:   "gs.Provide(NewA)\ngs.Provide(NewB)\n}\ntype A struct{}\ntype B struct{}\nfunc NewA(*B) *A { return nil }\nfunc NewB(*A) *B { return nil }",
+			error: "found circle constructor dependency",
+		},
+		{
+			src:   "gs.Object(&A{}).Destroy(func(*A) {})\n}\ntype A struct{}",
+			error: "Destroy of *A is not supported by static wiring",
+		},
+		{
+			src:   "gs.Object(&A{}).Name(\"a\").On(nil)\n}\ntype A struct{}",
+			error: "On of *A is not supported by static wiring",
+		},
+		{
+			src:   "gs.Object()\n}",
+			error: "gs.Object needs exactly one argument",
+		},
+		{
+			src:   "gs.Provide()\n}",
+			error: "gs.Provide needs a constructor",
+		},
+		{
+			src:   "gs.Object(&A{}).Init(a.Init)\n}\ntype A struct{}\nvar a A",
+			error: "init function must be a function literal or a function of this package",
+		},
+	}
+	for i, c := range testcases {
+		dir, err := ioutil.TempDir("", "wiregen")
+		if err != nil {
+			t.Fatal(err)
+		}
+		src := "package app\nimport \"github.com/go-spring/spring-core/gs\"\nfunc init() {\n" + c.src + "\n"
+		if err = ioutil.WriteFile(filepath.Join(dir, "app.go"), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err = Generate(dir)
+		os.RemoveAll(dir)
+		if err == nil || !strings.Contains(err.Error(), c.error) {
+			t.Fatalf("%d: got error %v but expect %q", i, err, c.error)
+		}
+	}
+}